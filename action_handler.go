@@ -0,0 +1,325 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gengo/goship/lib/acl"
+	"github.com/gengo/goship/lib/auth"
+	"github.com/gengo/goship/lib/config"
+	deploypkg "github.com/gengo/goship/lib/deploy"
+	"github.com/gengo/goship/lib/inventory"
+	"github.com/gengo/goship/lib/logging"
+	"github.com/gengo/goship/lib/notification"
+	"github.com/gengo/goship/lib/secrets"
+	helpers "github.com/gengo/goship/lib/view-helpers"
+	"github.com/golang/glog"
+	"golang.org/x/net/context"
+)
+
+// ActionHandler runs a quick action of an environment, e.g. restarting a service or clearing a cache, without deploying.
+// Users need the role of the action in the environment, and must add confirm=true to run actions which ask for confirmation.
+// Actions are blocked by locks and by reservations of other users, but not by freezes or pins because they deploy nothing.
+//
+// e.g. POST http://127.0.0.1:8000/action?project=admin&environment=production&action=clear-cache&confirm=true
+type ActionHandler struct {
+	dh DeployHandler
+}
+
+func (h ActionHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	ctx := logging.FromRequest(context.Background(), r)
+	log := logging.FromContext(ctx)
+	u, err := auth.CurrentUser(r)
+	if err != nil {
+		log.Errorf("Failed to get current user: %v", err)
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return
+	}
+	c, err := config.Load(h.dh.ecl)
+	if err != nil {
+		log.Errorf("Failed to load configuration: %v", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	projName, envName, name := r.FormValue("project"), r.FormValue("environment"), r.FormValue("action")
+	proj, err := config.ProjectFromName(c.Projects, projName)
+	if err != nil {
+		http.Error(w, "no such project", http.StatusNotFound)
+		return
+	}
+	env, err := config.EnvironmentFromName(c.Projects, projName, envName)
+	if err != nil {
+		http.Error(w, "no such project/environment", http.StatusNotFound)
+		return
+	}
+	a, ok := env.Action(name)
+	if !ok {
+		http.Error(w, "no such action", http.StatusNotFound)
+		return
+	}
+	if !acl.Permitted(h.dh.ac, c, proj, *env, u, a.RequiredRole()) {
+		log.Infof("Rejected %s of %s-%s requested by %s: not a %s", a.Name, projName, envName, u.Name, a.RequiredRole())
+		http.Error(w, fmt.Sprintf("%s role is required", a.RequiredRole()), http.StatusForbidden)
+		return
+	}
+	if a.Confirm != "" && r.FormValue("confirm") != "true" {
+		http.Error(w, fmt.Sprintf("confirm=true is required to run %s: %s", a.Name, a.Confirm), http.StatusBadRequest)
+		return
+	}
+	msg, err := h.blocked(proj, *env, u)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if msg != "" {
+		log.Infof("Rejected %s of %s-%s requested by %s: %s", a.Name, projName, envName, u.Name, msg)
+		http.Error(w, msg, http.StatusConflict)
+		return
+	}
+
+	req := deploypkg.Request{Project: proj, Environment: *env, User: u.Name, Action: &a}
+	err = h.dh.runAction(ctx, req)
+	if err == deploypkg.ErrBusy {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+	if err == deploypkg.ErrShuttingDown {
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	http.Redirect(w, r, fmt.Sprintf("/deployLog/%s-%s", projName, envName), http.StatusSeeOther)
+}
+
+// blocked returns why "u" cannot run actions in "env" of "proj" now, or an empty string if "u" can.
+func (h ActionHandler) blocked(proj config.Project, env config.Environment, u auth.User) (string, error) {
+	lock, err := config.LoadLock(h.dh.ecl, proj.Name, env.Name)
+	if err != nil {
+		glog.Errorf("Failed to load lock of %s-%s: %v", proj.Name, env.Name, err)
+		return "", err
+	}
+	if lock != nil {
+		return fmt.Sprintf("%s-%s is locked by %s: %s", proj.Name, env.Name, lock.User, lock.Reason), nil
+	}
+	if env.IsLocked {
+		return fmt.Sprintf("%s-%s is locked", proj.Name, env.Name), nil
+	}
+	res, err := config.ActiveReservation(h.dh.ecl, proj.Name, env.Name)
+	if err != nil {
+		glog.Errorf("Failed to load reservations of %s-%s: %v", proj.Name, env.Name, err)
+		return "", err
+	}
+	if res != nil && res.User != u.Name {
+		return fmt.Sprintf("%s-%s is reserved by %s for %s", proj.Name, env.Name, res.User, res.Window()), nil
+	}
+	return "", nil
+}
+
+// actionStatus describes a quick action to users.
+type actionStatus struct {
+	config.Action
+	// Permitted is true if the current user can run the action.
+	Permitted bool `json:"permitted"`
+}
+
+// actionList is the quick actions of an environment and their history.
+type actionList struct {
+	Actions []actionStatus `json:"actions"`
+	// Runs are the past runs of the actions, oldest first.
+	Runs []config.ActionRun `json:"runs"`
+}
+
+// ActionListHandler serves the quick actions of an environment and their history as JSON.
+// Only users who can read the project can see them.
+//
+// e.g. http://127.0.0.1:8000/api/actions?project=admin&environment=production
+type ActionListHandler struct {
+	ac  acl.AccessControl
+	ecl config.ETCDInterface
+}
+
+func (h ActionListHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	u, err := auth.CurrentUser(r)
+	if err != nil {
+		glog.Errorf("Failed to get current user: %v", err)
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return
+	}
+	c, err := config.Load(h.ecl)
+	if err != nil {
+		glog.Errorf("Failed to load configuration: %v", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	projName, envName := r.FormValue("project"), r.FormValue("environment")
+	proj, err := config.ProjectFromName(acl.ReadableProjects(h.ac, c.Projects, u), projName)
+	if err != nil {
+		http.Error(w, "no such project", http.StatusNotFound)
+		return
+	}
+	env, err := config.EnvironmentFromName([]config.Project{proj}, projName, envName)
+	if err != nil {
+		http.Error(w, "no such project/environment", http.StatusNotFound)
+		return
+	}
+	l := actionList{Actions: []actionStatus{}}
+	for _, a := range env.Actions {
+		l.Actions = append(l.Actions, actionStatus{Action: a, Permitted: acl.Permitted(h.ac, c, proj, *env, u, a.RequiredRole())})
+	}
+	if l.Runs, err = config.LoadActionRuns(h.ecl, projName, envName); err != nil {
+		glog.Errorf("Failed to load action runs of %s-%s: %v", projName, envName, err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if l.Runs == nil {
+		l.Runs = []config.ActionRun{}
+	}
+	helpers.RespondWithJSON(w, http.StatusOK, l)
+}
+
+// runAction runs the quick action of "req" in the same way as deployments:
+// it waits for or rejects the deployments in progress in the environment, and its output is streamed to web clients and kept with the outputs of deployments.
+// Its start and its result are notified, and the result is recorded in the history of actions of the environment instead of the deploy log.
+// It returns deploypkg.ErrBusy and deploypkg.ErrShuttingDown in the same way as DeployHandler.deploy.
+// Failures of the action itself are recorded rather than returned.
+func (h DeployHandler) runAction(ctx context.Context, req deploypkg.Request) error {
+	var (
+		user      = req.User
+		proj, env = req.Project, req.Environment
+		a         = *req.Action
+	)
+	if logging.CorrelationID(ctx) == "" {
+		ctx = logging.WithCorrelationID(ctx, logging.NewID())
+	}
+	ctx = logging.With(ctx, "project", proj.Name, "environment", env.Name, "user", user, "action", a.Name)
+	log := logging.FromContext(ctx)
+	done, err := h.drain.Add()
+	if err != nil {
+		log.Infof("Rejected %s of %s-%s requested by %s: %v", a.Name, proj.Name, env.Name, user, err)
+		h.broadcast(outputMessage{Project: proj.Name, Environment: env.Name, StdoutLine: err.Error()})
+		return err
+	}
+	defer done()
+	release, err := h.acquire(ctx, proj, env, user)
+	if err == deploypkg.ErrBusy {
+		log.Infof("Rejected %s of %s-%s requested by %s: %v", a.Name, proj.Name, env.Name, user, err)
+		h.broadcast(deploypkg.Progress{Project: proj.Name, Environment: env.Name, State: deploypkg.StateRejected, Time: time.Now()})
+		return err
+	}
+	if err != nil {
+		log.Errorf("Failed to wait for preceding deployments of %s-%s: %v", proj.Name, env.Name, err)
+		return err
+	}
+	defer release()
+	if h.drain.Stopping() {
+		log.Infof("Canceled queued %s of %s-%s requested by %s: %v", a.Name, proj.Name, env.Name, user, deploypkg.ErrShuttingDown)
+		h.broadcast(outputMessage{Project: proj.Name, Environment: env.Name, StdoutLine: deploypkg.ErrShuttingDown.Error()})
+		return deploypkg.ErrShuttingDown
+	}
+
+	if env, err = inventory.Resolve(ctx, env); err != nil {
+		log.Errorf("Failed to resolve hosts of %s-%s: %v", proj.Name, env.Name, err)
+		h.broadcast(outputMessage{Project: proj.Name, Environment: env.Name, StdoutLine: err.Error()})
+		return err
+	}
+	req.Environment = env
+	secretEnv, secretValues, err := secrets.Resolve(ctx, env.Secrets)
+	if err != nil {
+		log.Errorf("Failed to fetch secrets of %s-%s: %v", proj.Name, env.Name, err)
+		h.broadcast(outputMessage{Project: proj.Name, Environment: env.Name, StdoutLine: err.Error()})
+		return err
+	}
+	req.Env = append(env.EnvVars(), secretEnv...)
+	redact := secrets.NewRedactor(secretValues)
+
+	// runCtx is canceled when the action is canceled or timed out.
+	runCtx, run, finish := h.running.Start(ctx, proj.Name, env.Name, user)
+	defer finish()
+	ctx, runCtx = logging.With(ctx, "deploy_id", run.ID), logging.With(runCtx, "deploy_id", run.ID)
+	log = logging.FromContext(ctx)
+	if d := proj.Timeout(); d > 0 {
+		var cancel context.CancelFunc
+		runCtx, cancel = context.WithTimeout(runCtx, d)
+		defer cancel()
+	}
+	report := func(p deploypkg.Progress) {
+		p.DeployID, p.Owner = run.ID, h.owner(run)
+		h.report(proj.Name, env.Name, p)
+	}
+	runCtx = deploypkg.WithReporter(runCtx, report)
+
+	ev := notification.Event{
+		Type:        notification.ActionStarted,
+		Project:     proj.Name,
+		Environment: env.Name,
+		User:        user,
+		Owner:       user,
+		Action:      a.Name,
+	}
+	notification.NotifyAll(ctx, proj, ev)
+
+	start := time.Now()
+	log.Infof("Running %s of %s-%s; requested by %s", a.Name, proj.Name, env.Name, user)
+	stdout, stdoutW := io.Pipe()
+	stderr, stderrW := io.Pipe()
+	write, finishOutput := h.outputs.Start(run)
+	outLog, err := createOutputLog(fmt.Sprintf("%s-%s", proj.Name, env.Name), start)
+	if err != nil {
+		log.Errorf("Failed to create output log of %s-%s: %v", proj.Name, env.Name, err)
+	}
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go h.sendOutput(logging.With(ctx, "stream", "stdout"), &wg, bufio.NewScanner(stdout), run, outLog, write, redact)
+	go h.sendOutput(logging.With(ctx, "stream", "stderr"), &wg, bufio.NewScanner(stderr), run, outLog, write, redact)
+
+	fmt.Fprintf(stdoutW, "$ %s\n", a.Command)
+	report(deploypkg.Progress{State: deploypkg.StateDeploying, HostCount: len(env.Hosts)})
+	err = deploypkg.Roll(runCtx, h.executor, deploypkg.HealthChecker{}, req, stdoutW, stderrW)
+	switch runCtx.Err() {
+	case context.DeadlineExceeded:
+		fmt.Fprintf(stderrW, "Action timed out after %s\n", proj.DeployTimeout)
+	case context.Canceled:
+		if r, err := h.running.Get(run.ID); err == nil && r.CanceledBy != "" {
+			fmt.Fprintf(stderrW, "Action canceled by %s\n", r.CanceledBy)
+		}
+	}
+	stdoutW.Close()
+	stderrW.Close()
+	wg.Wait()
+	finishOutput()
+	if outLog != nil {
+		h.drain.Go(func() { outLog.close(h.outputStore) })
+	}
+	duration := time.Since(start)
+
+	success := err == nil
+	ev.Owner, ev.Time = h.owner(run), time.Now()
+	if success {
+		ev.Type = notification.ActionSucceeded
+		report(deploypkg.Progress{State: deploypkg.StateDone, HostCount: len(env.Hosts)})
+		log.Infof("Successfully ran %s of %s-%s", a.Name, proj.Name, env.Name)
+	} else {
+		ev.Type = notification.ActionFailed
+		report(deploypkg.Progress{State: deploypkg.StateFailed, HostCount: len(env.Hosts)})
+		log.Errorf("%s of %s-%s failed: %v", a.Name, proj.Name, env.Name, err)
+	}
+	notification.NotifyAll(ctx, proj, ev)
+
+	ar := config.ActionRun{Action: a.Name, User: user, Success: success, Time: start, Duration: duration}
+	if err := config.AddActionRun(h.ecl, proj.Name, env.Name, ar); err != nil {
+		log.Errorf("Failed to record %s of %s-%s: %v", a.Name, proj.Name, env.Name, err)
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+
+	"github.com/gengo/goship/lib/acl"
+	"github.com/gengo/goship/lib/auth"
+	"github.com/gengo/goship/lib/cluster"
+	"github.com/gengo/goship/lib/config"
+	helpers "github.com/gengo/goship/lib/view-helpers"
+	"github.com/golang/glog"
+	"golang.org/x/net/context"
+)
+
+// ClusterHandler serves the members of the cluster, its leader and the deployments running on each member.
+// Deployments are limited to the projects which the user can see.
+//
+// e.g. GET http://127.0.0.1:8000/api/cluster
+type ClusterHandler struct {
+	ac      acl.AccessControl
+	ecl     config.ETCDInterface
+	cluster *cluster.Cluster
+}
+
+type clusterStatus struct {
+	Self    string           `json:"self"`
+	Leader  string           `json:"leader"`
+	Members []cluster.Member `json:"members"`
+	Deploys []cluster.Lease  `json:"deploys"`
+}
+
+func (h ClusterHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	u, err := auth.CurrentUser(r)
+	if err != nil {
+		glog.Errorf("Failed to get current user: %v", err)
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return
+	}
+	c, err := config.Load(h.ecl)
+	if err != nil {
+		glog.Errorf("Failed to load configuration: %v", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	leases, err := h.cluster.Deploys()
+	if err != nil {
+		glog.Errorf("Failed to load deploy leases of the cluster: %v", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	visible := make(map[string]bool)
+	for _, p := range c.VisibleProjects(acl.ReadableProjects(h.ac, c.Projects, u), u.Name) {
+		visible[p.Name] = true
+	}
+	status := clusterStatus{
+		Self:    h.cluster.Self().ID,
+		Leader:  h.cluster.Leader(),
+		Members: h.cluster.Members(),
+		Deploys: []cluster.Lease{},
+	}
+	for _, l := range leases {
+		if visible[l.Project] {
+			status.Deploys = append(status.Deploys, l)
+		}
+	}
+	helpers.RespondWithJSON(w, http.StatusOK, status)
+}
+
+// newCluster returns the cluster which this instance joins through "ecl" as configured with -cluster-* flags.
+func newCluster(ecl config.ETCDInterface) (*cluster.Cluster, error) {
+	store, ok := ecl.(cluster.Store)
+	if !ok {
+		return nil, fmt.Errorf("%T cannot elect a leader of the cluster", ecl)
+	}
+	if *clusterToken == "" {
+		return nil, fmt.Errorf("-cluster-advertise requires -cluster-token")
+	}
+	id := *clusterID
+	if id == "" {
+		var err error
+		if id, err = os.Hostname(); err != nil {
+			return nil, err
+		}
+	}
+	return cluster.New(store, cluster.Member{ID: id, URL: *clusterAdvertise}, *clusterToken, *clusterTTL), nil
+}
+
+// lead runs "f" in the background. It runs "f" only while this instance leads "cl" if "cl" is not nil,
+// so that periodic jobs run once in the cluster.
+func lead(ctx context.Context, cl *cluster.Cluster, f func(context.Context)) {
+	if cl == nil {
+		go f(ctx)
+		return
+	}
+	go cl.Lead(ctx, f)
+}
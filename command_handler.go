@@ -0,0 +1,355 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gengo/goship/lib/acl"
+	"github.com/gengo/goship/lib/auth"
+	"github.com/gengo/goship/lib/config"
+	deploypkg "github.com/gengo/goship/lib/deploy"
+	"github.com/gengo/goship/lib/inventory"
+	"github.com/gengo/goship/lib/logging"
+	"github.com/gengo/goship/lib/notification"
+	helpers "github.com/gengo/goship/lib/view-helpers"
+	"github.com/golang/glog"
+	"golang.org/x/net/context"
+)
+
+// remoteRunner runs "cmd" on "host" over SSH as configured in "cfg" and streams its outputs into "stdout" and "stderr".
+type remoteRunner func(ctx context.Context, cfg config.SSH, host, cmd string, stdout, stderr io.Writer) error
+
+// commandList is the remote commands of an environment.
+type commandList struct {
+	Allowed         []string `json:"allowed"`
+	RequireApproval bool     `json:"require_approval"`
+	// Runs are the commands requested in the environment, oldest first.
+	Runs []config.CommandRun `json:"runs"`
+}
+
+// commandResult is a remote command which has been run and its output.
+type commandResult struct {
+	config.CommandRun
+	Output string `json:"output"`
+}
+
+// CommandHandler runs one-off commands on the hosts of environments with remote_commands, e.g. to inspect them during incidents.
+// Anyone who can read the project can see the allowed commands and the history of commands.
+// Deployers of the environment can run the allowed commands, and admins can run any command, with a mandatory "reason".
+// "hosts" optionally selects some of the hosts of the environment, separated by commas.
+// Commands of environments with require_approval wait until another user who can run them, and is listed in the approvers if any, approves them.
+// Every request, decision and result is notified and recorded in the audit log, and outputs are kept with the outputs of deployments.
+//
+// e.g. GET http://127.0.0.1:8000/api/commands?project=admin&environment=production
+// POST http://127.0.0.1:8000/api/commands?project=admin&environment=production&command=uptime&hosts=web1&reason=high+load
+// POST http://127.0.0.1:8000/api/commands?project=admin&environment=production&id=2&decision=approve
+type CommandHandler struct {
+	dh  DeployHandler
+	run remoteRunner
+}
+
+func (h CommandHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	ctx := logging.FromRequest(context.Background(), r)
+	log := logging.FromContext(ctx)
+	u, err := auth.CurrentUser(r)
+	if err != nil {
+		log.Errorf("Failed to get current user: %v", err)
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return
+	}
+	c, err := config.Load(h.dh.ecl)
+	if err != nil {
+		log.Errorf("Failed to load configuration: %v", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	projName, envName := r.FormValue("project"), r.FormValue("environment")
+	proj, err := config.ProjectFromName(acl.ReadableProjects(h.dh.ac, c.Projects, u), projName)
+	if err != nil {
+		http.Error(w, "no such project", http.StatusNotFound)
+		return
+	}
+	env, err := config.EnvironmentFromName([]config.Project{proj}, projName, envName)
+	if err != nil || !c.EnvironmentVisible(*env, u.Name) {
+		http.Error(w, "no such project/environment", http.StatusNotFound)
+		return
+	}
+	if env.RemoteCommands == nil {
+		http.Error(w, "remote commands are not enabled in the environment", http.StatusNotFound)
+		return
+	}
+	ctx = logging.With(ctx, "project", proj.Name, "environment", env.Name, "user", u.Name)
+	switch r.Method {
+	case "GET":
+		h.list(w, proj, *env)
+	case "POST":
+		if r.FormValue("id") != "" {
+			h.decide(ctx, w, r, c, u, proj, *env)
+			return
+		}
+		h.request(ctx, w, r, c, u, proj, *env)
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func (h CommandHandler) list(w http.ResponseWriter, proj config.Project, env config.Environment) {
+	runs, err := config.LoadCommandRuns(h.dh.ecl, proj.Name, env.Name)
+	if err != nil {
+		glog.Errorf("Failed to load remote commands of %s-%s: %v", proj.Name, env.Name, err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	l := commandList{Allowed: env.RemoteCommands.Allowed, RequireApproval: env.RemoteCommands.RequireApproval, Runs: runs}
+	if l.Allowed == nil {
+		l.Allowed = []string{}
+	}
+	if l.Runs == nil {
+		l.Runs = []config.CommandRun{}
+	}
+	helpers.RespondWithJSON(w, http.StatusOK, l)
+}
+
+func (h CommandHandler) request(ctx context.Context, w http.ResponseWriter, r *http.Request, c config.Config, u auth.User, proj config.Project, env config.Environment) {
+	log := logging.FromContext(ctx)
+	rc := *env.RemoteCommands
+	cmd, reason := strings.TrimSpace(r.FormValue("command")), strings.TrimSpace(r.FormValue("reason"))
+	if cmd == "" || reason == "" {
+		http.Error(w, "command and reason must be specified", http.StatusBadRequest)
+		return
+	}
+	role := rc.RequiredRole(cmd)
+	if !acl.Permitted(h.dh.ac, c, proj, env, u, role) {
+		log.Infof("Rejected %q in %s-%s requested by %s: not a %s", cmd, proj.Name, env.Name, u.Name, role)
+		http.Error(w, fmt.Sprintf("%s role is required", role), http.StatusForbidden)
+		return
+	}
+	hosts, err := h.hosts(ctx, env, r.FormValue("hosts"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	run := config.CommandRun{Command: cmd, Hosts: hosts, Reason: reason, User: u.Name, Time: time.Now()}
+	run, err = config.AddCommandRun(h.dh.ecl, proj.Name, env.Name, run, rc.RequireApproval)
+	if err != nil {
+		log.Errorf("Failed to record %q in %s-%s: %v", cmd, proj.Name, env.Name, err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if rc.RequireApproval {
+		log.Infof("%s requested %q on %s of %s-%s: %s", u.Name, cmd, strings.Join(hosts, ", "), proj.Name, env.Name, reason)
+		notification.NotifyAll(ctx, proj, commandEvent(notification.CommandRequested, proj, env, u.Name, run))
+		helpers.RespondWithJSON(w, http.StatusAccepted, run)
+		return
+	}
+	res, err := h.execute(ctx, c, proj, env, run)
+	h.respond(w, res, err)
+}
+
+func (h CommandHandler) decide(ctx context.Context, w http.ResponseWriter, r *http.Request, c config.Config, u auth.User, proj config.Project, env config.Environment) {
+	log := logging.FromContext(ctx)
+	var approve bool
+	switch r.FormValue("decision") {
+	case "approve":
+		approve = true
+	case "reject":
+	default:
+		http.Error(w, fmt.Sprintf("invalid decision %q", r.FormValue("decision")), http.StatusBadRequest)
+		return
+	}
+	id := r.FormValue("id")
+	runs, err := config.LoadCommandRuns(h.dh.ecl, proj.Name, env.Name)
+	if err != nil {
+		log.Errorf("Failed to load remote commands of %s-%s: %v", proj.Name, env.Name, err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	var pending *config.CommandRun
+	for i := range runs {
+		if runs[i].ID == id {
+			pending = &runs[i]
+		}
+	}
+	if pending == nil {
+		http.Error(w, config.ErrNoSuchCommand.Error(), http.StatusNotFound)
+		return
+	}
+	// approvers must be able to run the command themselves
+	if role := env.RemoteCommands.RequiredRole(pending.Command); !acl.Permitted(h.dh.ac, c, proj, env, u, role) {
+		http.Error(w, fmt.Sprintf("%s role is required", role), http.StatusForbidden)
+		return
+	}
+	if !env.CanApprove(u.Name) {
+		http.Error(w, "not allowed to approve commands in the environment", http.StatusForbidden)
+		return
+	}
+	if pending.User == u.Name {
+		http.Error(w, "cannot approve your own command", http.StatusForbidden)
+		return
+	}
+
+	run, err := config.DecideCommandRun(h.dh.ecl, proj.Name, env.Name, id, u.Name, approve)
+	switch err {
+	case nil:
+	case config.ErrNoSuchCommand:
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	case config.ErrCommandDecided:
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	default:
+		log.Errorf("Failed to decide command %s of %s-%s: %v", id, proj.Name, env.Name, err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if !approve {
+		log.Infof("%s rejected %q of %s-%s requested by %s", u.Name, run.Command, proj.Name, env.Name, run.User)
+		notification.NotifyAll(ctx, proj, commandEvent(notification.CommandRejected, proj, env, u.Name, run))
+		helpers.RespondWithJSON(w, http.StatusOK, run)
+		return
+	}
+	log.Infof("%s approved %q of %s-%s requested by %s", u.Name, run.Command, proj.Name, env.Name, run.User)
+	res, err := h.execute(ctx, c, proj, env, run)
+	h.respond(w, res, err)
+}
+
+// hosts returns the hosts of "env" selected by "selected", which is a comma-separated list of hosts or empty to select all of them.
+func (h CommandHandler) hosts(ctx context.Context, env config.Environment, selected string) ([]string, error) {
+	env, err := inventory.Resolve(ctx, env)
+	if err != nil {
+		logging.FromContext(ctx).Errorf("Failed to resolve hosts of %s: %v", env.Name, err)
+		return nil, err
+	}
+	if len(env.Hosts) == 0 {
+		return nil, fmt.Errorf("%s has no hosts", env.Name)
+	}
+	if strings.TrimSpace(selected) == "" {
+		return env.Hosts, nil
+	}
+	var hosts []string
+	for _, s := range strings.Split(selected, ",") {
+		s = strings.TrimSpace(s)
+		if s == "" {
+			continue
+		}
+		var found bool
+		for _, host := range env.Hosts {
+			found = found || host == s
+		}
+		if !found {
+			return nil, fmt.Errorf("%s is not a host of %s", s, env.Name)
+		}
+		hosts = append(hosts, s)
+	}
+	return hosts, nil
+}
+
+// execute runs the approved or pre-approved command "run" on its hosts in parallel, and records its result.
+// The output of each host is prefixed with the host name. Commands are killed after the timeout of the environment.
+func (h CommandHandler) execute(ctx context.Context, c config.Config, proj config.Project, env config.Environment, run config.CommandRun) (commandResult, error) {
+	log := logging.FromContext(ctx)
+	done, err := h.dh.drain.Add()
+	if err != nil {
+		// the command has already been recorded as running, so it must not stay running forever
+		if _, ferr := config.FinishCommandRun(h.dh.ecl, proj.Name, env.Name, run.ID, false, time.Now(), 0); ferr != nil {
+			log.Errorf("Failed to record %q in %s-%s as failed: %v", run.Command, proj.Name, env.Name, ferr)
+		}
+		return commandResult{}, err
+	}
+	defer done()
+	notification.NotifyAll(ctx, proj, commandEvent(notification.CommandStarted, proj, env, run.User, run))
+	log.Infof("Running %q on %s of %s-%s; requested by %s", run.Command, strings.Join(run.Hosts, ", "), proj.Name, env.Name, run.User)
+
+	runCtx, cancel := context.WithTimeout(ctx, env.RemoteCommands.CommandTimeout())
+	defer cancel()
+	target := env
+	target.Hosts, target.Parallelism, target.FailurePolicy = run.Hosts, len(run.Hosts), config.ContinueOnError
+	ssh := c.SSHConfig(proj, env)
+	out := new(lockedBuffer)
+	start := time.Now()
+	fmt.Fprintf(out, "$ %s\n", run.Command)
+	err = deploypkg.ForEachHost(runCtx, target, out, out, func(ctx context.Context, host string, stdout, stderr io.Writer) error {
+		return h.run(ctx, ssh, host, run.Command, stdout, stderr)
+	})
+	if runCtx.Err() == context.DeadlineExceeded {
+		fmt.Fprintf(out, "Command timed out after %s\n", env.RemoteCommands.CommandTimeout())
+	}
+	duration := time.Since(start)
+	output := out.String()
+
+	if outLog, err := createOutputLog(fmt.Sprintf("%s-%s", proj.Name, env.Name), start); err != nil {
+		log.Errorf("Failed to create output log of %s-%s: %v", proj.Name, env.Name, err)
+	} else {
+		s := bufio.NewScanner(strings.NewReader(output))
+		for s.Scan() {
+			outLog.append(s.Text())
+		}
+		h.dh.drain.Go(func() { outLog.close(h.dh.outputStore) })
+	}
+
+	typ := notification.CommandSucceeded
+	if err != nil {
+		typ = notification.CommandFailed
+		log.Errorf("%q on %s of %s-%s failed: %v", run.Command, strings.Join(run.Hosts, ", "), proj.Name, env.Name, err)
+	}
+	finished, ferr := config.FinishCommandRun(h.dh.ecl, proj.Name, env.Name, run.ID, err == nil, start, duration)
+	if ferr != nil {
+		log.Errorf("Failed to record the result of %q in %s-%s: %v", run.Command, proj.Name, env.Name, ferr)
+		finished = run
+	}
+	ev := commandEvent(typ, proj, env, run.User, finished)
+	ev.Owner = run.User
+	notification.NotifyAll(ctx, proj, ev)
+	return commandResult{CommandRun: finished, Output: output}, ferr
+}
+
+func (h CommandHandler) respond(w http.ResponseWriter, res commandResult, err error) {
+	switch err {
+	case nil:
+		helpers.RespondWithJSON(w, http.StatusOK, res)
+	case deploypkg.ErrShuttingDown:
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+	default:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// commandEvent returns an event of "typ" about "run" caused by "user".
+func commandEvent(typ notification.EventType, proj config.Project, env config.Environment, user string, run config.CommandRun) notification.Event {
+	return notification.Event{
+		Type:        typ,
+		Project:     proj.Name,
+		Environment: env.Name,
+		User:        user,
+		Owner:       run.User,
+		Reason:      run.Reason,
+		Time:        time.Now(),
+		Command:     run.Command,
+		Hosts:       run.Hosts,
+	}
+}
+
+// lockedBuffer is a bytes.Buffer which hosts running in parallel can write into.
+type lockedBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *lockedBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *lockedBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
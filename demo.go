@@ -0,0 +1,97 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"path"
+	"time"
+
+	"github.com/gengo/goship/lib/acl"
+	"github.com/gengo/goship/lib/clock"
+	"github.com/gengo/goship/lib/config"
+	"github.com/gengo/goship/lib/demo"
+	"github.com/gengo/goship/lib/revision"
+	"github.com/golang/glog"
+	"golang.org/x/net/context"
+)
+
+// newDemoBackend returns a backend which serves fake projects and simulates deployments without any external services.
+// It stores the seeded deployment logs in a temporary data directory.
+func newDemoBackend() (backend, error) {
+	dir, err := ioutil.TempDir("", "goship-demo")
+	if err != nil {
+		return backend{}, err
+	}
+	*dataPath = dir
+	glog.Infof("Running in demo mode; data directory is %s", dir)
+	if *demoStart != "" || *demoSpeed != 1 {
+		start := time.Now()
+		if *demoStart != "" {
+			if start, err = time.Parse(time.RFC3339, *demoStart); err != nil {
+				return backend{}, fmt.Errorf("invalid -demo-start %q: %v", *demoStart, err)
+			}
+		}
+		if *demoSpeed <= 0 {
+			return backend{}, fmt.Errorf("-demo-speed must be positive: %v", *demoSpeed)
+		}
+		clock.Default = clock.NewSimulated(start, *demoSpeed)
+		glog.Infof("Simulating time from %v at %gx speed", start, *demoSpeed)
+	}
+
+	cfg := demo.Config()
+	// the default user administers the demo so that the project editor can be tried
+	cfg.Admins = append(cfg.Admins, *defaultUser)
+	s := config.NewMemoryStore()
+	if err := config.Store(s, cfg); err != nil {
+		return backend{}, err
+	}
+	if err := seedHistory(cfg, clock.Now()); err != nil {
+		return backend{}, err
+	}
+
+	w := demo.NewWorld()
+	return backend{
+		ac:  acl.Null,
+		ecl: s,
+		newControl: func(config.Project, config.SSH) (revision.Control, error) {
+			return w.Control(), nil
+		},
+		executor: w.Executor(),
+		runRemote: func(ctx context.Context, _ config.SSH, host, cmd string, stdout, stderr io.Writer) error {
+			return w.RunRemote(ctx, host, cmd, stdout, stderr)
+		},
+		demo: true,
+	}, nil
+}
+
+// seedHistory writes fake deployment logs of the projects in "cfg" into the data directory.
+func seedHistory(cfg config.Config, now time.Time) error {
+	for _, p := range cfg.Projects {
+		for _, e := range p.Environments {
+			basename := fmt.Sprintf("%s-%s", p.Name, e.Name)
+			var entries []DeployLogEntry
+			for _, d := range demo.History(p.Name, e.Name, now) {
+				entries = append(entries, DeployLogEntry{
+					Range:         RevRange{From: d.From, To: d.To},
+					ToRevisionMsg: fmt.Sprintf("Demo commit %s", d.To.Short()),
+					User:          d.User,
+					Success:       d.Success,
+					Time:          d.Time,
+				})
+				out, err := createOutputLog(basename, d.Time)
+				if err != nil {
+					return err
+				}
+				for _, l := range d.Output {
+					out.append(l)
+				}
+				out.close(nil)
+			}
+			if err := writeJSON(entries, path.Join(*dataPath, basename+".json")); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
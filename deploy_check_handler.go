@@ -0,0 +1,289 @@
+package main
+
+import (
+	"crypto/subtle"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/gengo/goship/lib/acl"
+	"github.com/gengo/goship/lib/auth"
+	"github.com/gengo/goship/lib/clock"
+	"github.com/gengo/goship/lib/config"
+	"github.com/gengo/goship/lib/revision"
+	"github.com/gengo/goship/lib/scm"
+	helpers "github.com/gengo/goship/lib/view-helpers"
+	"github.com/gengo/goship/plugins/plugin"
+	"github.com/golang/glog"
+	"golang.org/x/net/context"
+)
+
+// Names of the conditions which deployments must satisfy.
+const (
+	checkRole        = "role"
+	checkLock        = "lock"
+	checkReservation = "reservation"
+	checkPin         = "pin"
+	checkProvenance  = "provenance"
+	checkStatus      = "ci_status"
+	checkFreeze      = "freeze"
+	checkPolicy      = "policy"
+	checkBusy        = "in_progress"
+)
+
+// Parameters of deployments with which users bypass failed checks.
+const (
+	overrideIgnoreStatus = "ignore_status"
+	overrideEmergency    = "emergency"
+)
+
+// deployCheck is the result of a condition which a deployment must satisfy.
+type deployCheck struct {
+	Name   string `json:"name"`
+	Passed bool   `json:"passed"`
+	// Message describes why the check failed.
+	Message string `json:"message,omitempty"`
+	// Override is the parameter of deployments with which the check can be bypassed with a reason, if any.
+	Override string `json:"override,omitempty"`
+}
+
+// checkDeploy evaluates the conditions which the deployment of "to" into "env" of "proj" by "u" must satisfy now.
+// "srcTo" is the source revision of "to" if they differ, e.g. for docker projects.
+func (h DeployHandler) checkDeploy(ctx context.Context, c config.Config, proj config.Project, env config.Environment, u auth.User, to, srcTo revision.Revision) ([]deployCheck, error) {
+	checks, err := h.checkAccess(c, proj, env, u, to)
+	if err != nil {
+		return nil, err
+	}
+	return append(checks, h.checkRevision(ctx, c, proj, env, to, srcTo)...), nil
+}
+
+// checkAccess evaluates the conditions of checkDeploy which depend only on Goship itself:
+// the role of "u", and the lock, reservations and pin of "env".
+// None of them can be bypassed, so callers can reject deployments on their failures without asking the SCM.
+func (h DeployHandler) checkAccess(c config.Config, proj config.Project, env config.Environment, u auth.User, to revision.Revision) ([]deployCheck, error) {
+	var checks []deployCheck
+	fail := func(name, override, format string, args ...interface{}) {
+		checks = append(checks, deployCheck{Name: name, Message: fmt.Sprintf(format, args...), Override: override})
+	}
+	pass := func(name string) {
+		checks = append(checks, deployCheck{Name: name, Passed: true})
+	}
+
+	if acl.Permitted(h.ac, c, proj, env, u, config.RoleDeployer) {
+		pass(checkRole)
+	} else {
+		fail(checkRole, "", "deployer role is required")
+	}
+	lock, err := config.LoadLock(h.ecl, proj.Name, env.Name)
+	if err != nil {
+		glog.Errorf("Failed to load lock of %s-%s: %v", proj.Name, env.Name, err)
+		return nil, err
+	}
+	switch {
+	case lock != nil:
+		fail(checkLock, "", "%s-%s is locked by %s: %s", proj.Name, env.Name, lock.User, lock.Reason)
+	case env.IsLocked:
+		fail(checkLock, "", "%s-%s is locked in the configuration", proj.Name, env.Name)
+	default:
+		pass(checkLock)
+	}
+	res, err := config.ActiveReservation(h.ecl, proj.Name, env.Name)
+	if err != nil {
+		glog.Errorf("Failed to load reservations of %s-%s: %v", proj.Name, env.Name, err)
+		return nil, err
+	}
+	if res != nil && res.User != u.Name {
+		fail(checkReservation, "", "%s-%s is reserved by %s for %s", proj.Name, env.Name, res.User, res.Window())
+	} else {
+		pass(checkReservation)
+	}
+	pin, err := config.LoadPin(h.ecl, proj.Name, env.Name)
+	if err != nil {
+		glog.Errorf("Failed to load pin of %s-%s: %v", proj.Name, env.Name, err)
+		return nil, err
+	}
+	if pin != nil && !pin.Allows(string(to)) {
+		fail(checkPin, "", "%s-%s is pinned to %s by %s; unpin it before deploying other revisions", proj.Name, env.Name, pin.Revision, pin.User)
+	} else {
+		pass(checkPin)
+	}
+	return checks, nil
+}
+
+// checkRevision evaluates the conditions of checkDeploy on "to" itself and on the time of the deployment:
+// protected branches and CI statuses in the SCM, and freezes of "env".
+func (h DeployHandler) checkRevision(ctx context.Context, c config.Config, proj config.Project, env config.Environment, to, srcTo revision.Revision) []deployCheck {
+	var checks []deployCheck
+	fail := func(name, override, format string, args ...interface{}) {
+		checks = append(checks, deployCheck{Name: name, Message: fmt.Sprintf(format, args...), Override: override})
+	}
+	pass := func(name string) {
+		checks = append(checks, deployCheck{Name: name, Passed: true})
+	}
+
+	src := scm.SourceRevision(proj, to, srcTo)
+	if p := scm.VerifyProvenance(ctx, h.scms, proj, env, src); !p.Verified {
+		fail(checkProvenance, "", "%s-%s accepts only revisions in its protected branches: %s", proj.Name, env.Name, p.Reason)
+	} else {
+		pass(checkProvenance)
+	}
+	if st := scm.VerifyStatuses(ctx, h.scms, proj, src); st.State != scm.StatusSuccess {
+		fail(checkStatus, overrideIgnoreStatus, "CI of %s is not green: %s; an admin can deploy with ignore_status=true and a reason", to.Short(), st.Reason)
+	} else {
+		pass(checkStatus)
+	}
+	if f := config.ActiveFreeze(c, env.Name, clock.Now()); f != nil {
+		msg := fmt.Sprintf("%s is frozen until %s; deploy with emergency=true and a reason if this is an emergency", env.Name, f.Until(clock.Now()).Format("Mon Jan 2 15:04"))
+		if f.Reason != "" {
+			msg = fmt.Sprintf("%s (%s)", msg, f.Reason)
+		}
+		fail(checkFreeze, overrideEmergency, "%s", msg)
+	} else {
+		pass(checkFreeze)
+	}
+	return checks
+}
+
+// checkRuntime evaluates the conditions which are checked only when the deployment starts:
+// the policies of plugins, and whether another deployment is in progress.
+func (h DeployHandler) checkRuntime(proj config.Project, env config.Environment, u auth.User, to revision.Revision) []deployCheck {
+	var checks []deployCheck
+	pd := plugin.Deployment{Project: proj.Name, Environment: env.Name, User: u.Name, To: string(to)}
+	policy := deployCheck{Name: checkPolicy, Passed: true}
+	for _, ch := range plugin.Checkers {
+		if err := ch.CheckDeploy(pd); err != nil {
+			policy = deployCheck{Name: checkPolicy, Message: err.Error()}
+			break
+		}
+	}
+	checks = append(checks, policy)
+
+	busy := deployCheck{Name: checkBusy, Passed: true}
+	if p, ok := h.progress.Get(proj.Name, env.Name); ok && active(p.State) {
+		if env.QueueDeploys {
+			busy.Message = fmt.Sprintf("%s-%s is being deployed; the deployment will wait for its turn", proj.Name, env.Name)
+		} else {
+			busy = deployCheck{Name: checkBusy, Message: fmt.Sprintf("%s-%s is being deployed by %s", proj.Name, env.Name, p.Owner)}
+		}
+	}
+	return append(checks, busy)
+}
+
+// deployability is the verdict whether a revision can be deployed into an environment now.
+type deployability struct {
+	Project     string            `json:"project"`
+	Environment string            `json:"environment"`
+	Revision    revision.Revision `json:"revision"`
+	User        string            `json:"user"`
+	// Deployable is true if all the checks passed.
+	Deployable bool `json:"deployable"`
+	// RequiresApproval is true if the deployment will wait for an approval.
+	RequiresApproval bool          `json:"requires_approval"`
+	Checks           []deployCheck `json:"checks"`
+}
+
+// DeployabilityHandler tells whether a revision can be deployed into an environment right now,
+// so that CI pipelines can fail fast before requesting the deployment.
+// It evaluates the same checks as deployments: roles, locks, reservations, pins, protected branches, CI statuses, freezes,
+// policies of plugins and deployments in progress.
+// Clients authenticate either with a session, or with a token in ci_tokens of the global configuration as a bearer token,
+// in which case the checks are evaluated for the user of the token.
+// It must not be wrapped by auth.Authenticate so that CI can call it without sessions.
+//
+// e.g. GET http://127.0.0.1:8000/api/deployable?project=admin&environment=staging&revision=abc123
+type DeployabilityHandler struct {
+	ac  acl.AccessControl
+	ecl config.ETCDInterface
+	dh  DeployHandler
+}
+
+func (h DeployabilityHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	c, err := config.Load(h.ecl)
+	if err != nil {
+		glog.Errorf("Failed to load configuration: %v", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	u, err := h.user(c, r)
+	if err != nil {
+		glog.Errorf("Failed to authenticate deployability check from %s: %v", r.RemoteAddr, err)
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return
+	}
+	projName, envName := r.FormValue("project"), r.FormValue("environment")
+	rev, srcRev := revision.Revision(r.FormValue("revision")), revision.Revision(r.FormValue("source_revision"))
+	if projName == "" || envName == "" || rev == "" {
+		http.Error(w, "project, environment and revision must be specified", http.StatusBadRequest)
+		return
+	}
+	proj, err := config.ProjectFromName(acl.ReadableProjects(h.ac, c.Projects, u), projName)
+	if err != nil {
+		http.Error(w, "no such project", http.StatusNotFound)
+		return
+	}
+	env, err := config.EnvironmentFromName([]config.Project{proj}, projName, envName)
+	if err != nil || !c.EnvironmentVisible(*env, u.Name) {
+		http.Error(w, "no such project/environment", http.StatusNotFound)
+		return
+	}
+
+	checks, err := h.dh.checkDeploy(context.Background(), c, proj, *env, u, rev, srcRev)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	checks = append(checks, h.dh.checkRuntime(proj, *env, u, rev)...)
+	v := deployability{
+		Project:          projName,
+		Environment:      envName,
+		Revision:         rev,
+		User:             u.Name,
+		Deployable:       true,
+		RequiresApproval: env.RequireApproval,
+		Checks:           checks,
+	}
+	for _, ch := range checks {
+		if !ch.Passed {
+			v.Deployable = false
+		}
+	}
+	helpers.RespondWithJSON(w, http.StatusOK, v)
+}
+
+// user returns the user of the CI token in "r", or the user of the session if "r" has no bearer token.
+func (h DeployabilityHandler) user(c config.Config, r *http.Request) (auth.User, error) {
+	authz := r.Header.Get("Authorization")
+	if !strings.HasPrefix(authz, "Bearer ") {
+		return auth.CurrentUser(r)
+	}
+	token := strings.TrimPrefix(authz, "Bearer ")
+	for _, t := range c.CITokens {
+		if subtle.ConstantTimeCompare([]byte(token), []byte(t.Token)) == 1 {
+			return loadUser(h.ecl, t.User)
+		}
+	}
+	return auth.User{}, fmt.Errorf("invalid token")
+}
+
+// loadUser returns the Goship user named "name" with the groups stored at the last login or by provisioning.
+// Deactivated users are refused.
+func loadUser(ecl config.ETCDInterface, name string) (auth.User, error) {
+	d, err := config.LoadDeactivation(ecl, name)
+	if err != nil {
+		glog.Errorf("Failed to load deactivation of %s: %v", name, err)
+		return auth.User{}, err
+	}
+	if d != nil && !d.Active() {
+		return auth.User{}, fmt.Errorf("%s is deactivated.", name)
+	}
+	groups, err := config.LoadUserGroups(ecl, name)
+	if err != nil {
+		glog.Errorf("Failed to load groups of %s: %v", name, err)
+		return auth.User{}, err
+	}
+	return auth.User{Name: name, Groups: groups}, nil
+}
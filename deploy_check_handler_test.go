@@ -0,0 +1,114 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/gengo/goship/lib/auth"
+	"github.com/gengo/goship/lib/clock"
+	"github.com/gengo/goship/lib/config"
+	"github.com/gengo/goship/lib/revision"
+)
+
+// readers can read every repository but deploy none of them, so that roles come from the projects.
+type readers struct{}
+
+func (readers) Readable(owner, repo, user string) bool   { return true }
+func (readers) Deployable(owner, repo, user string) bool { return false }
+
+// checkProject returns a project whose "staging" environment has "alice" as an admin, "bob" as a deployer and "carol" as a viewer.
+func checkProject() config.Project {
+	return config.Project{
+		Name: "proj",
+		Environments: []config.Environment{{
+			Name: "staging",
+			Roles: []config.RoleBinding{
+				{Role: config.RoleAdmin, Users: []string{"alice"}},
+				{Role: config.RoleDeployer, Users: []string{"bob"}},
+				{Role: config.RoleViewer, Users: []string{"carol"}},
+			},
+		}},
+	}
+}
+
+// failedChecks returns the names of the failed checks in "checks".
+func failedChecks(checks []deployCheck) []string {
+	var names []string
+	for _, ch := range checks {
+		if !ch.Passed {
+			names = append(names, ch.Name)
+		}
+	}
+	return names
+}
+
+func TestCheckAccess(t *testing.T) {
+	now := time.Date(2015, time.November, 10, 12, 0, 0, 0, time.UTC)
+	defer func(c clock.Clock) { clock.Default = c }(clock.Default)
+	clock.Default = clock.NewFake(now)
+
+	tests := []struct {
+		desc  string
+		user  string
+		setup func(s *config.MemoryStore, env *config.Environment)
+		want  []string
+	}{
+		{desc: "deployer", user: "bob"},
+		{desc: "viewer", user: "carol", want: []string{checkRole}},
+		{
+			desc: "locked",
+			user: "bob",
+			setup: func(s *config.MemoryStore, env *config.Environment) {
+				if err := config.LockEnvironment(s, "proj", "staging", config.Lock{User: "alice", Reason: "release", Time: now}); err != nil {
+					t.Fatalf("config.LockEnvironment failed with %v; want success", err)
+				}
+			},
+			want: []string{checkLock},
+		},
+		{
+			desc: "locked in the configuration",
+			user: "bob",
+			setup: func(s *config.MemoryStore, env *config.Environment) {
+				env.IsLocked = true
+			},
+			want: []string{checkLock},
+		},
+		{
+			desc: "reserved by another user",
+			user: "bob",
+			setup: func(s *config.MemoryStore, env *config.Environment) {
+				if _, err := config.Reserve(s, "proj", "staging", config.Reservation{User: "alice", Start: now.Add(-time.Hour), End: now.Add(time.Hour)}); err != nil {
+					t.Fatalf("config.Reserve failed with %v; want success", err)
+				}
+			},
+			want: []string{checkReservation},
+		},
+		{
+			desc: "pinned to another revision",
+			user: "bob",
+			setup: func(s *config.MemoryStore, env *config.Environment) {
+				if err := config.PinEnvironment(s, "proj", "staging", config.Pin{Revision: "def4567", User: "alice", Time: now}); err != nil {
+					t.Fatalf("config.PinEnvironment failed with %v; want success", err)
+				}
+			},
+			want: []string{checkPin},
+		},
+	}
+	for _, tt := range tests {
+		s := config.NewMemoryStore()
+		proj := checkProject()
+		if tt.setup != nil {
+			tt.setup(s, &proj.Environments[0])
+		}
+		h := DeployHandler{ac: readers{}, ecl: s}
+		checks, err := h.checkAccess(config.Config{}, proj, proj.Environments[0], auth.User{Name: tt.user}, revision.Revision("abc1234"))
+		if err != nil {
+			t.Errorf("checkAccess for %s failed with %v; want success", tt.desc, err)
+			continue
+		}
+		if got := failedChecks(checks); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("checkAccess for %s failed %q; want %q", tt.desc, got, tt.want)
+		}
+	}
+}
@@ -3,6 +3,7 @@ package main
 import (
 	"bufio"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"io/ioutil"
@@ -15,194 +16,1063 @@ import (
 	"sync"
 	"time"
 
-	"github.com/coreos/go-etcd/etcd"
+	"github.com/gengo/goship/lib/acl"
 	"github.com/gengo/goship/lib/auth"
+	"github.com/gengo/goship/lib/clock"
+	"github.com/gengo/goship/lib/cluster"
 	"github.com/gengo/goship/lib/config"
+	deploypkg "github.com/gengo/goship/lib/deploy"
+	"github.com/gengo/goship/lib/ghdeploy"
+	"github.com/gengo/goship/lib/inventory"
+	"github.com/gengo/goship/lib/issue"
+	"github.com/gengo/goship/lib/logging"
+	"github.com/gengo/goship/lib/metrics"
 	"github.com/gengo/goship/lib/notification"
+	"github.com/gengo/goship/lib/outputstore"
 	"github.com/gengo/goship/lib/revision"
+	"github.com/gengo/goship/lib/scm"
+	"github.com/gengo/goship/lib/secrets"
+	"github.com/gengo/goship/lib/ssh"
+	"github.com/gengo/goship/plugins/plugin"
 	"github.com/golang/glog"
 	"golang.org/x/net/context"
 )
 
+var (
+	deploysStarted   = metrics.NewCounterVec("goship_deploys_started_total", "Number of deployments started.", "project", "environment")
+	deploysSucceeded = metrics.NewCounterVec("goship_deploys_succeeded_total", "Number of deployments succeeded.", "project", "environment")
+	deploysFailed    = metrics.NewCounterVec("goship_deploys_failed_total", "Number of deployments failed.", "project", "environment")
+	deployDuration   = metrics.NewHistogramVec("goship_deploy_duration_seconds", "Duration of deployments.", metrics.DeployBuckets, "project", "environment")
+)
+
+const (
+	// maxNotifiedChanges is the maximum number of lines which summarize the changes of a deployment in notifications.
+	maxNotifiedChanges = 10
+	// clusterPollInterval is the interval of checking whether a deployment on another instance of the cluster has finished.
+	clusterPollInterval = 5 * time.Second
+)
+
 type DeployHandler struct {
-	ecl  *etcd.Client
-	ctrl revision.Control
-	hub  *notification.Hub
+	ac       acl.AccessControl
+	ecl      config.ETCDInterface
+	ctrl     revision.Control
+	hub      *notification.Hub
+	executor deploypkg.Executor
+	queue    *deploypkg.Queue
+	progress *deploypkg.Tracker
+	running  *deploypkg.Running
+	outputs  *deploypkg.Outputs
+	// drain waits for deployments in flight when the server shuts down. Deployments are not tracked if nil.
+	drain *deploypkg.Drain
+	// cluster serializes deployments of each environment across the instances of Goship if not nil.
+	cluster *cluster.Cluster
+	// outputStore keeps outputs of finished deployments if not nil. They are kept in the data directory otherwise.
+	outputStore outputstore.Store
+	// scms verify that revisions come from the protected branches of environments.
+	scms map[config.SCMType]scm.Client
+	// issues opens issues of repeated failures if not nil.
+	issues *issue.Tracker
+	// deployments reports deployments to GitHub if not nil.
+	deployments *ghdeploy.Reporter
 }
 
 func (h DeployHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
-	ctx := context.Background()
+	ctx := logging.FromRequest(context.Background(), r)
+	log := logging.FromContext(ctx)
 
 	c, err := config.Load(h.ecl)
 	if err != nil {
-		glog.Errorf("Failed to fetch latest configuration: %v", err)
+		log.Errorf("Failed to fetch latest configuration: %v", err)
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 	u, err := auth.CurrentUser(r)
 	if err != nil {
-		glog.Errorf("Failed to fetch current user: %v", err)
+		log.Errorf("Failed to fetch current user: %v", err)
 		http.Error(w, err.Error(), http.StatusUnauthorized)
 		return
 	}
-
-	var (
-		user              = u.Name
-		projName, envName string
-		deploy            RevRange
-		src               = RevRange{
+	dr := deployRequest{
+		project:     r.FormValue("project"),
+		environment: r.FormValue("environment"),
+		deploy: RevRange{
+			From: revision.Revision(r.FormValue("from_revision")),
+			To:   revision.Revision(r.FormValue("to_revision")),
+		},
+		src: RevRange{
 			From: revision.Revision(r.FormValue("from_source_revision")),
 			To:   revision.Revision(r.FormValue("to_source_revision")),
+		},
+		reason:       r.FormValue("reason"),
+		labels:       r.FormValue("labels"),
+		ignoreStatus: r.FormValue("ignore_status") == "true",
+		emergency:    r.FormValue("emergency") == "true",
+	}
+	h.serve(ctx, w, c, u, dr)
+}
+
+// serve runs "dr" requested by "u", or requests an approval of it, and responds to "w" with its result.
+func (h DeployHandler) serve(ctx context.Context, w http.ResponseWriter, c config.Config, u auth.User, dr deployRequest) {
+	req, status, err := h.prepare(ctx, c, u, dr)
+	if err != nil {
+		http.Error(w, err.Error(), status)
+		return
+	}
+	if req.Environment.RequireApproval {
+		if _, err := h.requestApproval(ctx, req, dr); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
 		}
+		w.WriteHeader(http.StatusAccepted)
+		return
+	}
+
+	_, err = h.deploy(ctx, c, req, dr.src)
+	if _, ok := err.(rejection); err == deploypkg.ErrBusy || ok {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+	if err == deploypkg.ErrShuttingDown {
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
+
+// deployRequest is a deployment requested by a user, e.g. with the Deploy button or a slash command.
+type deployRequest struct {
+	project, environment string
+	deploy, src          RevRange
+	// reason justifies ignoreStatus and emergency.
+	reason string
+	// labels are separated by commas.
+	labels string
+	// ignoreStatus deploys even if CI of the revision is not green. Only admins of the environment can.
+	ignoreStatus bool
+	// emergency deploys even if the environment is frozen.
+	emergency bool
+}
+
+// prepare checks whether "u" can run "dr" now, and returns the deployment to run.
+// The returned deployment needs an approval first if its environment requires.
+// It returns the HTTP status which describes the error on failure.
+func (h DeployHandler) prepare(ctx context.Context, c config.Config, u auth.User, dr deployRequest) (deploypkg.Request, int, error) {
+	log := logging.FromContext(ctx)
+	var (
+		user              = u.Name
+		projName, envName = dr.project, dr.environment
+		deploy            = dr.deploy
 	)
 	for _, spec := range []struct {
 		name  string
-		value *string
+		value string
 	}{
-		{name: "project", value: &projName},
-		{name: "environment", value: &envName},
-		{name: "from_revision", value: (*string)(&deploy.From)},
-		{name: "to_revision", value: (*string)(&deploy.To)},
+		{name: "project", value: projName},
+		{name: "environment", value: envName},
+		{name: "from_revision", value: string(deploy.From)},
+		{name: "to_revision", value: string(deploy.To)},
 	} {
-		*spec.value = r.FormValue(spec.name)
-		if *spec.value == "" {
-			glog.Errorf("%s not specified", spec.name)
-			http.Error(w, fmt.Sprintf("%s not specified", spec.name), http.StatusBadRequest)
-			return
+		if spec.value == "" {
+			log.Errorf("%s not specified", spec.name)
+			return deploypkg.Request{}, http.StatusBadRequest, fmt.Errorf("%s not specified", spec.name)
 		}
 	}
 	proj, err := config.ProjectFromName(c.Projects, projName)
 	if err != nil {
-		http.Error(w, "no such project", http.StatusNotFound)
-		return
+		return deploypkg.Request{}, http.StatusNotFound, errors.New("no such project")
 	}
 	env, err := config.EnvironmentFromName(c.Projects, projName, envName)
 	if err != nil {
-		http.Error(w, "no such project/environment", http.StatusNotFound)
-		return
+		return deploypkg.Request{}, http.StatusNotFound, errors.New("no such project/environment")
+	}
+	reason, status, err := h.authorize(ctx, c, proj, *env, u, dr)
+	if err != nil {
+		return deploypkg.Request{}, status, err
+	}
+	labels, err := deploypkg.ParseLabels(dr.labels)
+	if err != nil {
+		h.broadcast(outputMessage{Project: projName, Environment: envName, StdoutLine: err.Error()})
+		return deploypkg.Request{}, http.StatusBadRequest, err
 	}
+	return deploypkg.Request{
+		Project:     proj,
+		Environment: *env,
+		From:        deploy.From,
+		To:          deploy.To,
+		User:        user,
+		Reason:      reason,
+		Labels:      labels,
+	}, 0, nil
+}
 
-	h.deploy(ctx, w, c, user, proj, *env, deploy, src)
+// authorize evaluates the checks of the deployment "dr" into "env" of "proj" by "u", bypassing the ones which "dr" overrides.
+// It returns the reason of the deployment which describes the overrides, or the HTTP status which describes the error on failure.
+func (h DeployHandler) authorize(ctx context.Context, c config.Config, proj config.Project, env config.Environment, u auth.User, dr deployRequest) (string, int, error) {
+	log := logging.FromContext(ctx)
+	user, deploy := u.Name, dr.deploy
+	// reject rejects the deployment for "msg" and shows it to web clients.
+	reject := func(msg string) (string, int, error) {
+		log.Infof("Rejected deployment of %s requested by %s: %s", deploy.To, user, msg)
+		h.broadcast(outputMessage{Project: proj.Name, Environment: env.Name, StdoutLine: msg})
+		return "", http.StatusConflict, errors.New(msg)
+	}
+	// The checks in Goship come first so that rejected requests do not consume the rate limits of the SCM.
+	checks, err := h.checkAccess(c, proj, env, u, deploy.To)
+	if err != nil {
+		return "", http.StatusInternalServerError, err
+	}
+	for _, ch := range checks {
+		switch {
+		case ch.Passed:
+		case ch.Name == checkRole:
+			log.Infof("Rejected deployment of %s requested by %s: not a deployer of %s-%s", deploy.To, user, proj.Name, env.Name)
+			return "", http.StatusForbidden, errors.New(ch.Message)
+		default:
+			return reject(ch.Message)
+		}
+	}
+	// overrides are the checks which the user explicitly bypassed with a reason.
+	var overrides []string
+	for _, ch := range h.checkRevision(ctx, c, proj, env, deploy.To, dr.src.To) {
+		switch {
+		case ch.Passed:
+		case ch.Override == overrideIgnoreStatus && dr.ignoreStatus && acl.Permitted(h.ac, c, proj, env, u, config.RoleAdmin):
+			if dr.reason == "" {
+				return "", http.StatusBadRequest, errors.New("reason not specified for ignoring CI status")
+			}
+			log.Infof("%s ignored CI status of %s for %s-%s: %s", user, deploy.To, proj.Name, env.Name, ch.Message)
+			overrides = append(overrides, "ignored CI status")
+		case ch.Override == overrideEmergency && dr.emergency:
+			if dr.reason == "" {
+				return "", http.StatusBadRequest, errors.New("reason not specified for the emergency deployment")
+			}
+			overrides = append(overrides, "emergency")
+		default:
+			return reject(ch.Message)
+		}
+	}
+	if len(overrides) == 0 {
+		return "", 0, nil
+	}
+	return strings.Join(overrides, ", ") + ": " + dr.reason, 0, nil
 }
 
-func (h DeployHandler) deploy(ctx context.Context, w http.ResponseWriter, c config.Config, user string, proj config.Project, env config.Environment, deploy, src RevRange) {
-	if c.Notify != "" {
-		err := startNotify(c.Notify, user, proj.Name, env.Name)
+// rejection is returned by DeployHandler.deploy when a plugin rejects the deployment.
+type rejection struct {
+	error
+}
+
+// runHooks sends the finished deployment "d" to the plugins which act after deployments.
+func runHooks(d plugin.Deployment) {
+	for _, hk := range plugin.Hooks {
+		if err := hk.AfterDeploy(d); err != nil {
+			glog.Errorf("Failed to run post-deploy hook for %s-%s: %v", d.Project, d.Environment, err)
+		}
+	}
+}
+
+// acquire serializes deployments to "env" of "proj" requested by "user".
+// It either waits for preceding deployments or rejects the request depending on the configuration of "env".
+// Deployments on the other instances of the cluster precede as well if h.cluster is not nil.
+func (h DeployHandler) acquire(ctx context.Context, proj config.Project, env config.Environment, user string) (release func(), err error) {
+	log := logging.FromContext(ctx)
+	if !env.QueueDeploys {
+		if release, err = h.queue.TryAcquire(proj.Name, env.Name); err != nil || h.cluster == nil {
+			return release, err
+		}
+		lease, err := h.cluster.TryAcquire(proj.Name, env.Name, user)
 		if err != nil {
-			glog.Errorf("Failed to notify start-deployment event of %s (%s): %v", proj.Name, env.Name, err)
+			release()
+			return nil, err
 		}
+		return func() { lease(); release() }, nil
 	}
+	release, err = h.queue.Wait(ctx, proj.Name, env.Name, func(ahead int) {
+		log.Infof("Deployment of %s-%s is waiting for %d deployment(s) ahead", proj.Name, env.Name, ahead)
+		h.report(proj.Name, env.Name, deploypkg.Progress{State: deploypkg.StateQueued, QueuePosition: ahead})
+	})
+	if err != nil || h.cluster == nil {
+		return release, err
+	}
+	lease, err := h.cluster.Acquire(ctx, proj.Name, env.Name, user, clusterPollInterval, func(l cluster.Lease) {
+		log.Infof("Deployment of %s-%s is waiting for the deployment by %s on %s", proj.Name, env.Name, l.User, l.Member)
+		h.report(proj.Name, env.Name, deploypkg.Progress{State: deploypkg.StateQueued, QueuePosition: 1})
+	})
+	if err != nil {
+		release()
+		return nil, err
+	}
+	return func() { lease(); release() }, nil
+}
 
-	deployTime := time.Now()
-	success := true
-	command := deployCommand(env)
-	cmd := exec.Command(command[0], command[1:]...)
-	stdout, err := cmd.StdoutPipe()
+// report records "p" as the progress of the deployment in "env" of "proj" and sends it to web clients.
+func (h DeployHandler) report(proj, env string, p deploypkg.Progress) {
+	h.broadcast(h.progress.Update(proj, env, p))
+}
+
+// deploy runs the deployment described in "req".
+// It returns deploypkg.ErrBusy if another deployment is in progress in the environment and the environment does not queue deployments,
+// and deploypkg.ErrShuttingDown if the server is shutting down.
+// Failures of the deployment itself are recorded in the deploy log rather than returned, and it returns the recorded entry.
+func (h DeployHandler) deploy(ctx context.Context, c config.Config, req deploypkg.Request, src RevRange) (DeployLogEntry, error) {
+	var (
+		user      = req.User
+		proj, env = req.Project, req.Environment
+		deploy    = RevRange{From: req.From, To: req.To}
+	)
+	if logging.CorrelationID(ctx) == "" {
+		ctx = logging.WithCorrelationID(ctx, logging.NewID())
+	}
+	ctx = logging.With(ctx, "project", proj.Name, "environment", env.Name, "user", user)
+	log := logging.FromContext(ctx)
+	done, err := h.drain.Add()
 	if err != nil {
-		glog.Errorf("Could not get stdout of command: %v", err)
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
+		log.Infof("Rejected deployment of %s-%s requested by %s: %v", proj.Name, env.Name, user, err)
+		h.broadcast(outputMessage{Project: proj.Name, Environment: env.Name, StdoutLine: err.Error()})
+		return DeployLogEntry{}, err
+	}
+	defer done()
+	pd := plugin.Deployment{Project: proj.Name, Environment: env.Name, User: user, From: string(deploy.From), To: string(deploy.To), Restart: req.Restart, Labels: req.Labels}
+	for _, ch := range plugin.Checkers {
+		if err := ch.CheckDeploy(pd); err != nil {
+			log.Infof("Rejected deployment of %s-%s requested by %s: %v", proj.Name, env.Name, user, err)
+			h.broadcast(outputMessage{Project: proj.Name, Environment: env.Name, StdoutLine: err.Error()})
+			return DeployLogEntry{}, rejection{err}
+		}
+	}
+	release, err := h.acquire(ctx, proj, env, user)
+	if err == deploypkg.ErrBusy {
+		log.Infof("Rejected deployment of %s-%s requested by %s: %v", proj.Name, env.Name, user, err)
+		// Not recorded in h.progress so that it keeps the progress of the deployment in progress.
+		h.broadcast(deploypkg.Progress{Project: proj.Name, Environment: env.Name, State: deploypkg.StateRejected, Time: time.Now()})
+		return DeployLogEntry{}, err
 	}
-	stderr, err := cmd.StderrPipe()
 	if err != nil {
-		glog.Errorf("Could not get stderr of command: %v", err)
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
+		log.Errorf("Failed to wait for preceding deployments of %s-%s: %v", proj.Name, env.Name, err)
+		return DeployLogEntry{}, err
 	}
-	repo := proj.SourceRepo()
-	glog.Infof("Starting deployment of %s-%s (%s/%s) from %s to %s; requested by %s", proj.Name, env.Name, repo.RepoOwner, repo.RepoName, deploy.From, deploy.To, user)
-	if err = cmd.Start(); err != nil {
-		glog.Errorf("Could not run deployment command: %v", err)
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
+	defer release()
+	if h.drain.Stopping() {
+		log.Infof("Canceled queued deployment of %s-%s requested by %s: %v", proj.Name, env.Name, user, deploypkg.ErrShuttingDown)
+		h.broadcast(outputMessage{Project: proj.Name, Environment: env.Name, StdoutLine: deploypkg.ErrShuttingDown.Error()})
+		return DeployLogEntry{}, deploypkg.ErrShuttingDown
+	}
+
+	// Hosts from inventories are resolved after waiting for preceding deployments so that the latest instances are deployed.
+	if env, err = inventory.Resolve(ctx, env); err != nil {
+		log.Errorf("Failed to resolve hosts of %s-%s: %v", proj.Name, env.Name, err)
+		h.broadcast(outputMessage{Project: proj.Name, Environment: env.Name, StdoutLine: err.Error()})
+		return DeployLogEntry{}, err
+	}
+	req.Environment = env
+	secretEnv, secretValues, err := secrets.Resolve(ctx, env.Secrets)
+	if err != nil {
+		log.Errorf("Failed to fetch secrets of %s-%s: %v", proj.Name, env.Name, err)
+		h.broadcast(outputMessage{Project: proj.Name, Environment: env.Name, StdoutLine: err.Error()})
+		return DeployLogEntry{}, err
+	}
+	req.Env = append(env.EnvVars(), secretEnv...)
+	redact := secrets.NewRedactor(secretValues)
+
+	// runCtx is canceled when the deployment is canceled or timed out.
+	runCtx, run, finish := h.running.Start(ctx, proj.Name, env.Name, user)
+	defer finish()
+	ctx, runCtx = logging.With(ctx, "deploy_id", run.ID), logging.With(runCtx, "deploy_id", run.ID)
+	log = logging.FromContext(ctx)
+	if d := proj.Timeout(); d > 0 {
+		var cancel context.CancelFunc
+		runCtx, cancel = context.WithTimeout(runCtx, d)
+		defer cancel()
+	}
+	report := func(p deploypkg.Progress) {
+		p.DeployID, p.Owner = run.ID, h.owner(run)
+		h.report(proj.Name, env.Name, p)
 	}
+	report(deploypkg.Progress{State: deploypkg.StatePreflight})
+	runCtx = deploypkg.WithReporter(runCtx, report)
 
+	if c.Notify != "" && !req.Restart {
+		err := startNotify(c.Notify, user, proj.Name, env.Name)
+		if err != nil {
+			log.Errorf("Failed to notify start-deployment event of %s (%s): %v", proj.Name, env.Name, err)
+		}
+	}
+	ev := notification.Event{
+		Type:        notification.DeployStarted,
+		Project:     proj.Name,
+		Environment: env.Name,
+		User:        user,
+		Owner:       user,
+		From:        deploy.From,
+		To:          deploy.To,
+		Reason:      req.Reason,
+		Labels:      req.Labels,
+	}
+	if req.Restart {
+		ev.Type = notification.RestartStarted
+	}
+	if src.From != "" && src.To != "" && h.ctrl != nil {
+		ev.DiffURL = h.ctrl.SourceDiffURL(proj, src.From, src.To)
+	}
+	if !req.Restart {
+		ev.Changes = h.changes(ctx, proj, deploy, src)
+		if ev.Skipped, err = skippedEnvironments(proj, env, deploy.To); err != nil {
+			log.Errorf("Failed to check preceding environments of %s-%s: %v", proj.Name, env.Name, err)
+		}
+	}
+	notification.NotifyAll(ctx, proj, ev)
+	runCtx = deploypkg.WithGate(runCtx, h.gate(proj, env, run))
+	ghID := h.startGithubDeployment(ctx, proj, env, req, src)
+
+	deployTime := time.Now()
+	success := true
+	repo := proj.SourceRepo()
+	log.Infof("Starting deployment of %s-%s (%s/%s) from %s to %s; requested by %s", proj.Name, env.Name, repo.RepoOwner, repo.RepoName, deploy.From, deploy.To, user)
+
+	stdout, stdoutW := io.Pipe()
+	stderr, stderrW := io.Pipe()
+	write, finishOutput := h.outputs.Start(run)
+	outLog, err := createOutputLog(fmt.Sprintf("%s-%s", proj.Name, env.Name), deployTime)
+	if err != nil {
+		log.Errorf("Failed to create output log of %s-%s: %v", proj.Name, env.Name, err)
+	}
 	var wg sync.WaitGroup
 	wg.Add(2)
-	go h.sendOutput(&wg, bufio.NewScanner(stdout), proj.Name, env.Name, deployTime)
-	go h.sendOutput(&wg, bufio.NewScanner(stderr), proj.Name, env.Name, deployTime)
+	go h.sendOutput(logging.With(ctx, "stream", "stdout"), &wg, bufio.NewScanner(stdout), run, outLog, write, redact)
+	go h.sendOutput(logging.With(ctx, "stream", "stderr"), &wg, bufio.NewScanner(stderr), run, outLog, write, redact)
+
+	if len(ev.Skipped) > 0 {
+		log.Warningf("Deploying %s to %s-%s without deploying it to %s", deploy.To, proj.Name, env.Name, strings.Join(ev.Skipped, ", "))
+		fmt.Fprintf(stderrW, "Warning: %s has not been deployed to %s\n", deploy.To.Short(), strings.Join(ev.Skipped, ", "))
+	}
+	deploysStarted.Inc(proj.Name, env.Name)
+	remote := sshRemote{cfg: c.SSHConfig(proj, env)}
+	hc := deploypkg.HealthChecker{Remote: remote}
+	var verifyErr error
+	if !req.Restart {
+		verifyErr = deploypkg.ArtifactVerifier{Remote: remote}.Verify(runCtx, req, stdoutW)
+	}
+	if verifyErr == nil {
+		report(deploypkg.Progress{State: deploypkg.StateDeploying, HostCount: len(env.Hosts)})
+		err = deploypkg.Roll(runCtx, h.executor, hc, req, stdoutW, stderrW)
+	} else {
+		err = verifyErr
+		fmt.Fprintln(stderrW, err)
+	}
+	var rolledBack revision.Revision
+	if err == nil && proj.HealthCheck != nil {
+		report(deploypkg.Progress{State: deploypkg.StateVerifying, HostCount: len(env.Hosts)})
+		if err = hc.Check(runCtx, *proj.HealthCheck, env.Hosts, stdoutW); err != nil {
+			fmt.Fprintf(stderrW, "Health check failed: %v\n", err)
+			ev.Reason = "health check failed"
+			if proj.AutoRollback && !req.Restart && runCtx.Err() == nil {
+				report(deploypkg.Progress{State: deploypkg.StateRollingBack, HostCount: len(env.Hosts)})
+				rolledBack = h.rollback(runCtx, req, stdoutW, stderrW)
+			}
+		}
+	}
+	switch runCtx.Err() {
+	case context.DeadlineExceeded:
+		fmt.Fprintf(stderrW, "Deployment timed out after %s\n", proj.DeployTimeout)
+	case context.Canceled:
+		if r, err := h.running.Get(run.ID); err == nil && r.CanceledBy != "" {
+			fmt.Fprintf(stderrW, "Deployment canceled by %s\n", r.CanceledBy)
+		}
+	}
+	stdoutW.Close()
+	stderrW.Close()
 	wg.Wait()
+	finishOutput()
+	if outLog != nil {
+		h.drain.Go(func() { outLog.close(h.outputStore) })
+	}
+	duration := time.Since(deployTime)
+	deployDuration.Observe(duration.Seconds(), proj.Name, env.Name)
 
-	err = cmd.Wait()
 	if err != nil {
 		success = false
-		glog.Errorf("Deployment of %s failed: %v", proj.Name, err)
+		deploysFailed.Inc(proj.Name, env.Name)
+		report(deploypkg.Progress{State: deploypkg.StateFailed, HostCount: len(env.Hosts)})
+		log.Errorf("Deployment of %s failed: %v", proj.Name, err)
 	} else {
-		glog.Infof("Successfully deployed %s", proj.Name)
+		deploysSucceeded.Inc(proj.Name, env.Name)
+		report(deploypkg.Progress{State: deploypkg.StateDone, HostCount: len(env.Hosts)})
+		log.Infof("Successfully deployed %s", proj.Name)
 	}
-	if c.Notify != "" {
+	if c.Notify != "" && !req.Restart {
 		err = endNotify(c.Notify, proj.Name, env.Name, success)
 		if err != nil {
-			glog.Errorf("Failed to notify start-deployment event of %s (%s): %v", proj.Name, env.Name, err)
+			log.Errorf("Failed to notify start-deployment event of %s (%s): %v", proj.Name, env.Name, err)
 		}
 	}
+	owner := h.owner(run)
+	ev.Owner, ev.Time = owner, time.Now()
+	switch {
+	case req.Restart && success:
+		ev.Type = notification.RestartSucceeded
+	case req.Restart:
+		ev.Type = notification.RestartFailed
+	case success:
+		ev.Type = notification.DeploySucceeded
+	default:
+		ev.Type = notification.DeployFailed
+	}
+	notification.NotifyAll(ctx, proj, ev)
+	pd.Success = success
+	go runHooks(pd)
+	if ghID != 0 {
+		go func() {
+			if err := h.deployments.Finish(proj, ghID, success, ev.Reason); err != nil {
+				log.Errorf("Failed to report the result of deployment of %s-%s to github: %v", proj.Name, env.Name, err)
+			}
+		}()
+	}
+	var anomaly string
+	if !req.Restart {
+		anomaly = h.checkDuration(ctx, proj, env, ev, duration)
+	} else {
+		// restarts are not measured so that they do not skew the baseline of deployments
+		duration = 0
+	}
+	if rolledBack != "" {
+		rb := ev
+		rb.Type, rb.From, rb.To, rb.Time = notification.DeployRolledBack, deploy.To, rolledBack, time.Now()
+		notification.NotifyAll(ctx, proj, rb)
+	}
 
-	if (c.Pivotal.Token != "") && success {
+	if c.Pivotal != nil && c.Pivotal.Token != "" && success && !req.Restart {
 		err := config.PostToPivotal(c.Pivotal, env.Name, repo.RepoOwner, repo.RepoName, string(deploy.From), string(deploy.To))
 		if err != nil {
-			glog.Errorf("Failed to post to pivotal: %v", err)
+			log.Errorf("Failed to post to pivotal: %v", err)
 		} else {
-			glog.Infof("Pivotal Token: %s", c.Pivotal.Token)
+			log.Infof("Pivotal Token: %s", c.Pivotal.Token)
 		}
 	}
 
-	err = h.insertEntry(ctx, proj, env, deploy, src, user, success, deployTime)
+	if h.issues != nil && !req.Restart {
+		var output []string
+		if out, err := h.outputs.Get(run.ID); err == nil {
+			output, _, _ = out.Read(0)
+		}
+		d := issue.Deployment{Environment: env.Name, User: owner, Revision: string(deploy.To), Success: success, Time: deployTime, Output: output}
+		go func() {
+			if err := h.issues.Record(proj, d); err != nil {
+				log.Errorf("Failed to track failures of %s-%s: %v", proj.Name, env.Name, err)
+			}
+		}()
+	}
+
+	d, err := h.insertEntry(ctx, proj, env, deploy, src, user, owner, success, rolledBack, deployTime, req.Labels, req.Reason, duration, anomaly, ev.Skipped)
 	if err != nil {
-		glog.Errorf("Failed to insert an entry: %v", err)
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
+		log.Errorf("Failed to insert an entry: %v", err)
+		return d, err
 	}
+	return d, nil
 }
 
-func (h DeployHandler) sendOutput(wg *sync.WaitGroup, scanner *bufio.Scanner, p, e string, deployTime time.Time) {
-	defer wg.Done()
-	for scanner.Scan() {
-		t := scanner.Text()
-		msg := struct {
-			Project     string
-			Environment string
-			StdoutLine  string
-		}{p, e, stripANSICodes(strings.TrimSpace(t))}
-		cmdOutput, err := json.Marshal(msg)
+// checkDuration compares "duration" of the deployment finished with "ev" with the durations of the past deployments to "env".
+// It returns the description of the anomaly if the duration deviates significantly, and notifies it if the project wants.
+func (h DeployHandler) checkDuration(ctx context.Context, proj config.Project, env config.Environment, ev notification.Event, duration time.Duration) string {
+	log := logging.FromContext(ctx)
+	b, ok := history.baseline(fmt.Sprintf("%s-%s", proj.Name, env.Name))
+	if !ok {
+		return ""
+	}
+	anomaly := b.Anomaly(duration)
+	if anomaly == "" {
+		return ""
+	}
+	log.Warningf("Deployment of %s-%s %s", proj.Name, env.Name, anomaly)
+	if proj.NotifyDurationAnomalies {
+		ev.Type, ev.Reason, ev.Changes, ev.Time = notification.DeployDurationAnomaly, anomaly, nil, time.Now()
+		notification.NotifyAll(ctx, proj, ev)
+	}
+	return anomaly
+}
+
+// changes summarizes the changes shipped by "deploy" for notifications, or returns nil if unknown.
+func (h DeployHandler) changes(ctx context.Context, proj config.Project, deploy, src RevRange) []string {
+	log := logging.FromContext(ctx)
+	from, to := scm.SourceRevision(proj, deploy.From, src.From), scm.SourceRevision(proj, deploy.To, src.To)
+	sc, ok := h.scms[proj.SCM]
+	if from == "" || to == "" || from == to || !ok {
+		return nil
+	}
+	cl, err := scm.NewChangelog(ctx, sc, proj.SourceRepo(), from, to)
+	if err != nil {
+		log.Errorf("Failed to compare %s...%s of %s: %v", from, to, proj.Name, err)
+		return nil
+	}
+	return cl.Summary(maxNotifiedChanges)
+}
+
+// startGithubDeployment creates a GitHub deployment of "req" if the project reports deployments to GitHub, and returns its ID.
+// It returns 0 if no deployment is created.
+func (h DeployHandler) startGithubDeployment(ctx context.Context, proj config.Project, env config.Environment, req deploypkg.Request, src RevRange) int {
+	if h.deployments == nil || !proj.GithubDeployments || req.Restart {
+		return 0
+	}
+	ref := scm.SourceRevision(proj, req.To, src.To)
+	if ref == "" {
+		logging.FromContext(ctx).Infof("Not reporting deployment of %s-%s to github: source revision of %s is unknown", proj.Name, env.Name, req.To)
+		return 0
+	}
+	id, err := h.deployments.Start(proj, ghdeploy.Deployment{Environment: env.Name, User: req.User, Ref: ref, Reason: req.Reason})
+	if err != nil {
+		logging.FromContext(ctx).Errorf("Failed to report deployment of %s-%s to github: %v", proj.Name, env.Name, err)
+		return 0
+	}
+	return id
+}
+
+// gate returns a deploypkg.Gate which pauses "run" in "env" of "proj" until someone continues or aborts it.
+// The pause, the decision and who made it are notified.
+func (h DeployHandler) gate(proj config.Project, env config.Environment, run deploypkg.Run) deploypkg.Gate {
+	return func(ctx context.Context, step, message string) error {
+		logging.FromContext(ctx).Infof("Deployment %s of %s-%s is waiting for confirmation before %s", run.ID, proj.Name, env.Name, step)
+		ev := notification.Event{
+			Type:        notification.DeployPaused,
+			Project:     proj.Name,
+			Environment: env.Name,
+			User:        run.User,
+			Owner:       h.owner(run),
+			Reason:      message,
+			Step:        step,
+			Time:        time.Now(),
+		}
+		notification.NotifyAll(ctx, proj, ev)
+		user, err := h.running.Pause(ctx, run.ID, step, message)
+		if user == "" {
+			return err
+		}
+		ev.Type, ev.User, ev.Reason, ev.Time = notification.DeployContinued, user, "", time.Now()
 		if err != nil {
-			glog.Errorf("Failed to marshal output into JSON: %v", err)
+			ev.Type = notification.DeployAborted
+			err = fmt.Errorf("aborted by %s", user)
+		}
+		notification.NotifyAll(ctx, proj, ev)
+		return err
+	}
+}
+
+// requestApproval records a pending approval request of the deployment "req" prepared for "dr" instead of running it.
+// The deployment runs when another user approves it.
+func (h DeployHandler) requestApproval(ctx context.Context, req deploypkg.Request, dr deployRequest) (config.Approval, error) {
+	log := logging.FromContext(ctx)
+	proj, env := req.Project, req.Environment
+	a, err := config.RequestApproval(h.ecl, proj.Name, env.Name, config.Approval{
+		From:         string(req.From),
+		To:           string(req.To),
+		User:         req.User,
+		Time:         time.Now(),
+		FromSource:   string(dr.src.From),
+		ToSource:     string(dr.src.To),
+		Labels:       req.Labels,
+		Reason:       dr.reason,
+		IgnoreStatus: dr.ignoreStatus,
+		Emergency:    dr.emergency,
+	})
+	if err != nil {
+		log.Errorf("Failed to request approval of deployment to %s-%s: %v", proj.Name, env.Name, err)
+		return config.Approval{}, err
+	}
+	log.Infof("Deployment of %s to %s-%s requested by %s is waiting for approval %s", req.To, proj.Name, env.Name, req.User, a.ID)
+	h.broadcast(outputMessage{Project: proj.Name, Environment: env.Name, StdoutLine: fmt.Sprintf("Waiting for approval %s of the deployment", a.ID)})
+	notification.NotifyAll(ctx, proj, notification.Event{
+		Type:        notification.ApprovalRequested,
+		Project:     proj.Name,
+		Environment: env.Name,
+		User:        req.User,
+		From:        req.From,
+		To:          req.To,
+		ApprovalID:  a.ID,
+		Labels:      req.Labels,
+	})
+	return a, nil
+}
+
+// runApproved runs the deployment requested in "a" after it gets approved.
+// The deployment is checked again for the user who requested it, because the environment may have been locked, frozen,
+// reserved or pinned while the request was waiting for the approval.
+func (h DeployHandler) runApproved(proj config.Project, env config.Environment, a config.Approval) {
+	ctx := logging.With(logging.WithCorrelationID(context.Background(), logging.NewID()), "approval_id", a.ID)
+	log := logging.FromContext(ctx)
+	c, err := config.Load(h.ecl)
+	if err != nil {
+		log.Errorf("Failed to fetch latest configuration: %v", err)
+		return
+	}
+	u, err := loadUser(h.ecl, a.User)
+	if err != nil {
+		log.Errorf("Failed to load %s who requested approved deployment of %s-%s: %v", a.User, proj.Name, env.Name, err)
+		return
+	}
+	src := RevRange{From: revision.Revision(a.FromSource), To: revision.Revision(a.ToSource)}
+	dr := deployRequest{
+		project:      proj.Name,
+		environment:  env.Name,
+		deploy:       RevRange{From: revision.Revision(a.From), To: revision.Revision(a.To)},
+		src:          src,
+		reason:       a.Reason,
+		ignoreStatus: a.IgnoreStatus,
+		emergency:    a.Emergency,
+	}
+	reason, _, err := h.authorize(ctx, c, proj, env, u, dr)
+	if err != nil {
+		log.Errorf("Rejected approved deployment of %s-%s: %v", proj.Name, env.Name, err)
+		return
+	}
+	if reason != "" {
+		reason += "; "
+	}
+	req := deploypkg.Request{
+		Project:     proj,
+		Environment: env,
+		From:        dr.deploy.From,
+		To:          dr.deploy.To,
+		User:        a.User,
+		Reason:      reason + "approved by " + a.Approver,
+		Labels:      a.Labels,
+	}
+	go func() {
+		if _, err := h.deploy(ctx, c, req, src); err != nil {
+			log.Errorf("Failed to run approved deployment of %s-%s: %v", proj.Name, env.Name, err)
 		}
-		h.hub.Broadcast(string(cmdOutput))
+	}()
+}
 
-		go appendDeployOutput(fmt.Sprintf("%s-%s", p, e), t, deployTime)
+// rollback deploys the revision of the last successful deployment in the environment of "req" again
+// after "req" failed its health check.
+// It returns the redeployed revision, or an empty revision if the rollback was not possible or failed.
+func (h DeployHandler) rollback(ctx context.Context, req deploypkg.Request, stdout, stderr io.Writer) revision.Revision {
+	log := logging.FromContext(ctx)
+	proj, env := req.Project, req.Environment
+	rev, err := lastDeployed(fmt.Sprintf("%s-%s", proj.Name, env.Name))
+	if err != nil {
+		log.Errorf("Failed to find the previous revision of %s-%s: %v", proj.Name, env.Name, err)
+		fmt.Fprintf(stderr, "Rollback failed: %v\n", err)
+		return ""
 	}
-	if err := scanner.Err(); err != nil {
-		glog.Errorf("Failed to scan deploy output: %v", err)
+	if rev == "" || rev == req.To {
+		fmt.Fprintln(stderr, "Rollback skipped: no previous revision to roll back to")
+		return ""
+	}
+	fmt.Fprintf(stdout, "Rolling back to %s\n", rev)
+	req.From, req.To = req.To, rev
+	if err := h.executor.Execute(ctx, req, stdout, stderr); err != nil {
+		log.Errorf("Failed to roll back %s-%s to %s: %v", proj.Name, env.Name, rev, err)
+		fmt.Fprintf(stderr, "Rollback failed: %v\n", err)
+		return ""
+	}
+	return rev
+}
+
+// sshRemote runs remote commands of health checks, artifact verifications and users over SSH as configured in "cfg".
+// The key given by -k flag is used if "cfg" has no key file.
+type sshRemote struct {
+	cfg config.SSH
+}
+
+func (r sshRemote) Output(ctx context.Context, host, cmd string) ([]byte, error) {
+	s, err := r.ssh()
+	if err != nil {
+		return nil, err
+	}
+	return s.Output(ctx, host, cmd)
+}
+
+// Run runs "cmd" on "host" and streams its outputs into "stdout" and "stderr".
+func (r sshRemote) Run(ctx context.Context, host, cmd string, stdout, stderr io.Writer) error {
+	s, err := r.ssh()
+	if err != nil {
+		return err
+	}
+	return s.Run(ctx, host, cmd, stdout, stderr)
+}
+
+func (r sshRemote) ssh() (ssh.SSH, error) {
+	cfg := r.cfg
+	if cfg.KeyFile == "" {
+		cfg.KeyFile = *keyPath
+	}
+	return ssh.New(cfg)
+}
+
+// owner returns the current owner of "run".
+func (h DeployHandler) owner(run deploypkg.Run) string {
+	if r, err := h.running.Get(run.ID); err == nil {
+		return r.Owner
+	}
+	return run.Owner
+}
+
+// handedOff sends the new owner of "run" to web clients.
+func (h DeployHandler) handedOff(run deploypkg.Run) {
+	p, ok := h.progress.Get(run.Project, run.Environment)
+	if !ok || p.DeployID != run.ID {
 		return
 	}
+	p.Owner, p.Time = run.Owner, time.Time{}
+	h.report(run.Project, run.Environment, p)
 }
 
-func stripANSICodes(t string) string {
-	ansi := regexp.MustCompile(`\x1B\[[0-9;]{1,4}[mK]`)
-	return ansi.ReplaceAllString(t, "")
+// runSchedule runs the scheduled action "s" in "env" of "proj".
+// It is skipped unless the user who added the schedule is still a deployer of "env".
+// Locked environments are skipped, and so are frozen or reserved ones unless the action is a restart.
+// Scheduled deploys are also skipped if the revision is not in the protected branches of "env" or its CI is not green when they fire,
+// and wait for an approval if "env" requires approvals.
+// Reservations by the user who added the schedule do not block it.
+func (h DeployHandler) runSchedule(ctx context.Context, proj config.Project, env config.Environment, s config.Schedule) {
+	ctx = logging.With(logging.WithCorrelationID(ctx, logging.NewID()), "schedule_id", s.ID)
+	log := logging.FromContext(ctx)
+	lock, err := config.LoadLock(h.ecl, proj.Name, env.Name)
+	if err != nil {
+		log.Errorf("Failed to load lock of %s-%s: %v", proj.Name, env.Name, err)
+		return
+	}
+	if env.IsLocked || lock != nil {
+		log.Infof("Skipped scheduled %s of %s-%s because the environment is locked", s.Action, proj.Name, env.Name)
+		return
+	}
+	c, err := config.Load(h.ecl)
+	if err != nil {
+		log.Errorf("Failed to fetch latest configuration: %v", err)
+		return
+	}
+	owner, err := loadUser(h.ecl, s.User)
+	if err != nil {
+		log.Errorf("Skipped scheduled %s of %s-%s because its owner %s cannot be loaded: %v", s.Action, proj.Name, env.Name, s.User, err)
+		return
+	}
+	if !acl.Permitted(h.ac, c, proj, env, owner, config.RoleDeployer) {
+		log.Errorf("Skipped scheduled %s of %s-%s because its owner %s is no longer a deployer of the environment", s.Action, proj.Name, env.Name, s.User)
+		return
+	}
+	rev, err := lastDeployed(fmt.Sprintf("%s-%s", proj.Name, env.Name))
+	if err != nil {
+		log.Errorf("Failed to find the deployed revision of %s-%s: %v", proj.Name, env.Name, err)
+		return
+	}
+	if rev == "" && s.Action == config.ScheduleRedeploy {
+		log.Errorf("Skipped scheduled redeploy of %s-%s because it has never been deployed successfully", proj.Name, env.Name)
+		return
+	}
+	to := rev
+	if s.Action == config.ScheduleDeploy {
+		to = revision.Revision(s.Revision)
+		pin, err := config.LoadPin(h.ecl, proj.Name, env.Name)
+		if err != nil {
+			log.Errorf("Failed to load pin of %s-%s: %v", proj.Name, env.Name, err)
+			return
+		}
+		if pin != nil && !pin.Allows(s.Revision) {
+			log.Errorf("Skipped scheduled deploy of %s to %s-%s because the environment is pinned to %s", s.Revision, proj.Name, env.Name, pin.Revision)
+			return
+		}
+		src := scm.SourceRevision(proj, to, "")
+		if p := scm.VerifyProvenance(ctx, h.scms, proj, env, src); !p.Verified {
+			h.skipSchedule(ctx, proj, env, s, fmt.Sprintf("%s-%s accepts only revisions in its protected branches: %s", proj.Name, env.Name, p.Reason))
+			return
+		}
+		// The revision may have turned red since it was scheduled.
+		if st := scm.VerifyStatuses(ctx, h.scms, proj, src); st.State != scm.StatusSuccess {
+			h.skipSchedule(ctx, proj, env, s, fmt.Sprintf("CI of %s is not green: %s", to.Short(), st.Reason))
+			return
+		}
+	}
+	if f := config.ActiveFreeze(c, env.Name, clock.Now()); f != nil && s.Action != config.ScheduleRestart {
+		log.Errorf("Skipped scheduled %s of %s-%s because the environment is frozen until %v", s.Action, proj.Name, env.Name, f.Until(clock.Now()))
+		return
+	}
+	res, err := config.ActiveReservation(h.ecl, proj.Name, env.Name)
+	if err != nil {
+		log.Errorf("Failed to load reservations of %s-%s: %v", proj.Name, env.Name, err)
+		return
+	}
+	if res != nil && res.User != s.User && s.Action != config.ScheduleRestart {
+		log.Errorf("Skipped scheduled %s of %s-%s because the environment is reserved by %s for %s", s.Action, proj.Name, env.Name, res.User, res.Window())
+		return
+	}
+	req := deploypkg.Request{
+		Project:     proj,
+		Environment: env,
+		From:        rev,
+		To:          to,
+		User:        fmt.Sprintf("schedule %s by %s", s.ID, s.User),
+		Restart:     s.Action == config.ScheduleRestart,
+	}
+	if s.Action == config.ScheduleDeploy && env.RequireApproval {
+		// Requested on behalf of the user who added the schedule, so that the user cannot approve it.
+		req.User = s.User
+		if _, err := h.requestApproval(ctx, req, deployRequest{}); err != nil {
+			log.Errorf("Failed to request approval of scheduled deploy of %s-%s: %v", proj.Name, env.Name, err)
+		}
+		return
+	}
+	if _, err := h.deploy(ctx, c, req, RevRange{}); err != nil {
+		log.Errorf("Failed to run scheduled %s of %s-%s: %v", s.Action, proj.Name, env.Name, err)
+	}
 }
 
-func appendDeployOutput(env string, output string, timestamp time.Time) {
-	logDir := path.Join(*dataPath, env)
-	path := path.Join(logDir, timestamp.String()+".log")
+// skipSchedule skips the scheduled action "s" in "env" of "proj" for "reason", and notifies the user who added it through the notifiers of "proj".
+func (h DeployHandler) skipSchedule(ctx context.Context, proj config.Project, env config.Environment, s config.Schedule, reason string) {
+	logging.FromContext(ctx).Errorf("Skipped scheduled %s of %s-%s: %s", s.Action, proj.Name, env.Name, reason)
+	notification.NotifyAll(ctx, proj, notification.Event{
+		Type:        notification.ScheduleSkipped,
+		Project:     proj.Name,
+		Environment: env.Name,
+		User:        s.User,
+		To:          revision.Revision(s.Revision),
+		Reason:      reason,
+		Action:      string(s.Action),
+		Time:        clock.Now(),
+	})
+}
 
-	if _, err := os.Stat(logDir); err != nil {
-		if os.IsNotExist(err) {
-			err := os.Mkdir(logDir, 0755)
-			if err != nil {
-				fmt.Printf("ERROR: %s", err)
+// lastDeployed returns the revision deployed by the last successful deployment in the log of "env",
+// or an empty revision if there is no such deployment.
+func lastDeployed(env string) (revision.Revision, error) {
+	entries, err := readEntries(env)
+	if os.IsNotExist(err) {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	var (
+		rev revision.Revision
+		t   time.Time
+	)
+	for _, e := range entries {
+		if e.Success && !e.Time.Before(t) {
+			rev, t = e.Range.To, e.Time
+		}
+	}
+	return rev, nil
+}
+
+// skippedEnvironments returns the preceding environments of "env", including its earlier stages in the pipeline of "proj",
+// which "rev" has not been deployed to successfully.
+func skippedEnvironments(proj config.Project, env config.Environment, rev revision.Revision) ([]string, error) {
+	names := append([]string(nil), env.Preceding...)
+	for _, stage := range proj.EarlierStages(env.Name) {
+		preceding := false
+		for _, name := range env.Preceding {
+			preceding = preceding || name == stage
+		}
+		if !preceding {
+			names = append(names, stage)
+		}
+	}
+	return undeployed(proj, names, rev)
+}
+
+// undeployed returns the environments of "proj" in "names" which "rev" has not been deployed to successfully.
+func undeployed(proj config.Project, names []string, rev revision.Revision) ([]string, error) {
+	var result []string
+	for _, name := range names {
+		entries, err := history.search(fmt.Sprintf("%s-%s", proj.Name, name), historyQuery{Result: "success", SHA: string(rev)})
+		if err != nil && !os.IsNotExist(err) {
+			return nil, err
+		}
+		deployed := false
+		for _, e := range entries {
+			if e.Range.To == rev {
+				deployed = true
+				break
 			}
 		}
+		if !deployed {
+			result = append(result, name)
+		}
 	}
+	return result, nil
+}
+
+// outputMessage sends a line of deploy output to web clients.
+type outputMessage struct {
+	Project     string
+	Environment string
+	StdoutLine  string
+	// DeployID and Line identify the line in the output of a deployment, so that clients can skip lines which they have already received.
+	// They are empty if the line is not a part of the output.
+	DeployID string `json:",omitempty"`
+	Line     int    `json:",omitempty"`
+}
 
-	out, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0666)
+// broadcast sends "msg" to web clients as JSON.
+func (h DeployHandler) broadcast(msg interface{}) {
+	buf, err := json.Marshal(msg)
 	if err != nil {
-		fmt.Printf("ERROR: %s", err)
+		glog.Errorf("Failed to marshal %#v into JSON: %v", msg, err)
+		return
+	}
+	h.hub.Broadcast(string(buf))
+}
+
+// relayed sends "msg" relayed from another instance of the cluster to web clients.
+// Progress of deployments on the other instance is recorded in h.progress so that this instance serves it as well.
+func (h DeployHandler) relayed(msg string) {
+	var p deploypkg.Progress
+	if err := json.Unmarshal([]byte(msg), &p); err == nil && p.State != "" && p.State != deploypkg.StateRejected && p.Project != "" && p.Environment != "" {
+		h.progress.Update(p.Project, p.Environment, p)
+	}
+	h.hub.BroadcastLocal(msg)
+}
+
+// replay returns the messages which bring web clients which have just connected up to date with "env" of "proj":
+// the progress of the deployment in progress and its output so far, in the same format as broadcast.
+func (h DeployHandler) replay(proj, env string) []string {
+	p, ok := h.progress.Get(proj, env)
+	if !ok || !active(p.State) {
+		return nil
+	}
+	msgs := []interface{}{p}
+	if out, err := h.outputs.Get(p.DeployID); err == nil {
+		lines, _, _ := out.Read(0)
+		for i, l := range lines {
+			msgs = append(msgs, outputMessage{Project: proj, Environment: env, StdoutLine: stripANSICodes(strings.TrimSpace(l)), DeployID: p.DeployID, Line: i + 1})
+		}
+	}
+	var replay []string
+	for _, m := range msgs {
+		buf, err := json.Marshal(m)
+		if err != nil {
+			glog.Errorf("Failed to marshal %#v into JSON: %v", m, err)
+			continue
+		}
+		replay = append(replay, string(buf))
 	}
+	return replay
+}
 
-	defer out.Close()
+// sendOutput sends each line in "scanner" to web clients, to "write", to the log file of the deployment "run", and to the logs at Debug level.
+// "out" is nil if the log file could not be created. Secrets in the lines are masked by "redact".
+// Lines are written before they are broadcast so that clients which connect in between receive them through replay.
+func (h DeployHandler) sendOutput(ctx context.Context, wg *sync.WaitGroup, scanner *bufio.Scanner, run deploypkg.Run, out *outputLog, write func(string) int, redact *secrets.Redactor) {
+	defer wg.Done()
+	log := logging.FromContext(ctx)
+	for scanner.Scan() {
+		t := redact.Redact(scanner.Text())
+		log.Debugf("%s", t)
+		n := write(t)
+		h.broadcast(outputMessage{Project: run.Project, Environment: run.Environment, StdoutLine: stripANSICodes(strings.TrimSpace(t)), DeployID: run.ID, Line: n})
+		if out != nil {
+			out.append(t)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		log.Errorf("Failed to scan deploy output: %v", err)
+		return
+	}
+}
 
-	io.WriteString(out, output+"\n")
+func stripANSICodes(t string) string {
+	ansi := regexp.MustCompile(`\x1B\[[0-9;]{1,4}[mK]`)
+	return ansi.ReplaceAllString(t, "")
 }
 
 func startNotify(n, user, p, env string) error {
@@ -235,53 +1105,66 @@ func endNotify(n, p, env string, success bool) error {
 	return nil
 }
 
-// deployCommand returns the deployment command for a given
-// environment as a string slice that has been split on spaces.
-func deployCommand(e config.Environment) []string {
-	// TODO(yugui) better handling of shell escape
-	return strings.Split(e.Deploy, " ")
-}
-
-func (h DeployHandler) insertEntry(ctx context.Context, proj config.Project, env config.Environment, deploy, src RevRange, user string, success bool, time time.Time) error {
-	basename := fmt.Sprintf("%s-%s", proj.Name, env.Name)
-	path := path.Join(*dataPath, basename+".json")
-	err := prepareDataFiles(path)
-	if err != nil {
-		return err
-	}
-
-	e, err := readEntries(basename)
-	if err != nil {
-		return err
-	}
-
+func (h DeployHandler) insertEntry(ctx context.Context, proj config.Project, env config.Environment, deploy, src RevRange, user, owner string, success bool, rolledBack revision.Revision, time time.Time, labels []string, reason string, duration time.Duration, anomaly string, skipped []string) (DeployLogEntry, error) {
+	log := logging.FromContext(ctx)
 	repo := proj.SourceRepo()
 	var msg string
-	if src.To != "" {
+	if src.To != "" && h.ctrl != nil {
+		var err error
 		msg, err = h.ctrl.SourceRevMessage(ctx, proj, src.To)
 		if err != nil {
-			glog.Errorf("Failed to get commit %s (%s/%s): %v", src.To, repo.RepoOwner, repo.RepoName, err)
+			log.Errorf("Failed to get commit %s (%s/%s): %v", src.To, repo.RepoOwner, repo.RepoName, err)
 			msg = ""
 		}
 	}
 	var diffURL string
-	if src.From != "" && src.To != "" {
+	if src.From != "" && src.To != "" && h.ctrl != nil {
 		diffURL = h.ctrl.SourceDiffURL(proj, src.From, src.To)
 	}
+	if owner == user {
+		owner = ""
+	}
 	d := DeployLogEntry{
+		Owner:         owner,
 		Range:         deploy,
 		DiffURL:       diffURL,
 		ToRevisionMsg: msg,
 		User:          user,
 		Time:          time,
 		Success:       success,
+		RolledBackTo:  rolledBack,
+		Labels:        labels,
+		Reason:        reason,
+		Duration:      duration,
+		Anomaly:       anomaly,
+		Skipped:       skipped,
 	}
-	e = append(e, d)
-	err = writeJSON(e, path)
+	err := updateEntries(fmt.Sprintf("%s-%s", proj.Name, env.Name), func(e []DeployLogEntry) ([]DeployLogEntry, error) {
+		return append(e, d), nil
+	})
+	return d, err
+}
+
+// deployLogMu serializes changes of deploy log files.
+var deployLogMu sync.Mutex
+
+// updateEntries replaces the entries in the deploy log of "env" with the result of "f".
+func updateEntries(env string, f func([]DeployLogEntry) ([]DeployLogEntry, error)) error {
+	deployLogMu.Lock()
+	defer deployLogMu.Unlock()
+	path := path.Join(*dataPath, env+".json")
+	if err := prepareDataFiles(path); err != nil {
+		return err
+	}
+	e, err := readEntries(env)
 	if err != nil {
 		return err
 	}
-	return nil
+	if e, err = f(e); err != nil {
+		return err
+	}
+	defer history.forget(env)
+	return writeJSON(e, path)
 }
 
 func prepareDataFiles(path string) error {
@@ -0,0 +1,151 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/gengo/goship/lib/auth"
+	"github.com/gengo/goship/lib/clock"
+	"github.com/gengo/goship/lib/config"
+	"github.com/gengo/goship/lib/notification"
+	"golang.org/x/net/context"
+)
+
+func TestAuthorize(t *testing.T) {
+	// Tuesday
+	now := time.Date(2015, time.November, 10, 12, 0, 0, 0, time.Local)
+	defer func(c clock.Clock) { clock.Default = c }(clock.Default)
+	clock.Default = clock.NewFake(now)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	tests := []struct {
+		desc  string
+		user  string
+		dr    deployRequest
+		setup func(s *config.MemoryStore, c *config.Config, proj *config.Project)
+		// wantStatus is 0 if the deployment is authorized.
+		wantStatus int
+		wantReason string
+	}{
+		{desc: "deployer", user: "bob"},
+		{desc: "viewer", user: "carol", wantStatus: http.StatusForbidden},
+		{
+			desc: "locked",
+			user: "bob",
+			setup: func(s *config.MemoryStore, c *config.Config, proj *config.Project) {
+				if err := config.LockEnvironment(s, "proj", "staging", config.Lock{User: "alice", Reason: "release", Time: now}); err != nil {
+					t.Fatalf("config.LockEnvironment failed with %v; want success", err)
+				}
+			},
+			wantStatus: http.StatusConflict,
+		},
+		{
+			desc: "locked by an admin",
+			user: "alice",
+			setup: func(s *config.MemoryStore, c *config.Config, proj *config.Project) {
+				proj.Environments[0].IsLocked = true
+			},
+			wantStatus: http.StatusConflict,
+		},
+		{
+			desc: "reserved by another user",
+			user: "bob",
+			setup: func(s *config.MemoryStore, c *config.Config, proj *config.Project) {
+				if _, err := config.Reserve(s, "proj", "staging", config.Reservation{User: "alice", Start: now.Add(-time.Hour), End: now.Add(time.Hour)}); err != nil {
+					t.Fatalf("config.Reserve failed with %v; want success", err)
+				}
+			},
+			wantStatus: http.StatusConflict,
+		},
+		{
+			desc: "reserved by the user",
+			user: "bob",
+			setup: func(s *config.MemoryStore, c *config.Config, proj *config.Project) {
+				if _, err := config.Reserve(s, "proj", "staging", config.Reservation{User: "bob", Start: now.Add(-time.Hour), End: now.Add(time.Hour)}); err != nil {
+					t.Fatalf("config.Reserve failed with %v; want success", err)
+				}
+			},
+		},
+		{
+			desc:       "red CI",
+			user:       "alice",
+			setup:      requireStatus,
+			wantStatus: http.StatusConflict,
+		},
+		{
+			desc:       "red CI ignored by a deployer",
+			user:       "bob",
+			dr:         deployRequest{ignoreStatus: true, reason: "flaky test"},
+			setup:      requireStatus,
+			wantStatus: http.StatusConflict,
+		},
+		{
+			desc:       "red CI ignored by an admin without reason",
+			user:       "alice",
+			dr:         deployRequest{ignoreStatus: true},
+			setup:      requireStatus,
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			desc:       "red CI ignored by an admin",
+			user:       "alice",
+			dr:         deployRequest{ignoreStatus: true, reason: "flaky test"},
+			setup:      requireStatus,
+			wantReason: "ignored CI status: flaky test",
+		},
+		{
+			desc:       "frozen",
+			user:       "bob",
+			setup:      freeze,
+			wantStatus: http.StatusConflict,
+		},
+		{
+			desc:       "emergency without reason",
+			user:       "bob",
+			dr:         deployRequest{emergency: true},
+			setup:      freeze,
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			desc:       "emergency",
+			user:       "bob",
+			dr:         deployRequest{emergency: true, reason: "outage"},
+			setup:      freeze,
+			wantReason: "emergency: outage",
+		},
+	}
+	for _, tt := range tests {
+		s := config.NewMemoryStore()
+		var c config.Config
+		proj := checkProject()
+		if tt.setup != nil {
+			tt.setup(s, &c, &proj)
+		}
+		h := DeployHandler{ac: readers{}, ecl: s, hub: notification.NewHub(ctx)}
+		dr := tt.dr
+		dr.project, dr.environment, dr.deploy = "proj", "staging", RevRange{To: "abc1234"}
+		reason, status, err := h.authorize(ctx, c, proj, proj.Environments[0], auth.User{Name: tt.user}, dr)
+		if status != tt.wantStatus {
+			t.Errorf("authorize for %s = %d, %v; want %d", tt.desc, status, err, tt.wantStatus)
+			continue
+		}
+		if got := err != nil; got != (tt.wantStatus != 0) {
+			t.Errorf("authorize for %s failed with %v; want error %t", tt.desc, err, tt.wantStatus != 0)
+		}
+		if reason != tt.wantReason {
+			t.Errorf("authorize for %s returned reason %q; want %q", tt.desc, reason, tt.wantReason)
+		}
+	}
+}
+
+// requireStatus makes "proj" require a CI status which no SCM reports.
+func requireStatus(s *config.MemoryStore, c *config.Config, proj *config.Project) {
+	proj.RequiredStatuses = []string{"ci/travis"}
+}
+
+// freeze freezes staging from Monday to Wednesday.
+func freeze(s *config.MemoryStore, c *config.Config, proj *config.Project) {
+	c.Freezes = []config.Freeze{{Start: "Mon 00:00", End: "Wed 00:00", Environments: []string{"staging"}}}
+}
@@ -0,0 +1,177 @@
+package main
+
+import (
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	deploypkg "github.com/gengo/goship/lib/deploy"
+	"github.com/golang/glog"
+)
+
+// historyQuery selects entries of a deploy log. Empty fields match any entries.
+type historyQuery struct {
+	// User matches the user who deployed or the owner of the deployment.
+	User string
+	// Result is either "success" or "failure".
+	Result string
+	// SHA is a prefix of the revision deployed from or to.
+	SHA   string
+	Label string
+	// Since and Until are the range of the time of deployments, inclusive.
+	Since, Until time.Time
+	// Text is searched case-insensitively in the reasons, commit messages and labels of deployments.
+	Text string
+}
+
+// match returns true if "e" satisfies "q". "text" is the lower-cased searchable text of "e".
+func (q historyQuery) match(e DeployLogEntry, text string) bool {
+	switch {
+	case q.User != "" && q.User != e.User && q.User != e.Owner:
+		return false
+	case q.Result == "success" && !e.Success, q.Result == "failure" && e.Success:
+		return false
+	case q.SHA != "" && !strings.HasPrefix(string(e.Range.To), q.SHA) && !strings.HasPrefix(string(e.Range.From), q.SHA):
+		return false
+	case q.Label != "" && !e.hasLabel(q.Label):
+		return false
+	case !q.Since.IsZero() && e.Time.Before(q.Since), !q.Until.IsZero() && e.Time.After(q.Until):
+		return false
+	case q.Text != "" && !strings.Contains(text, strings.ToLower(q.Text)):
+		return false
+	}
+	return true
+}
+
+// searchText returns the lower-cased text of "e" which historyQuery.Text is searched in.
+func searchText(e DeployLogEntry) string {
+	fields := append([]string{e.Reason, e.ToRevisionMsg}, e.Labels...)
+	return strings.ToLower(strings.Join(fields, "\n"))
+}
+
+// deployHistory indexes deploy logs in memory so that queries do not read the files.
+// Logs are read on the first query after they change through updateEntries,
+// or after the modification time of their files changes, e.g. when another instance of the cluster writes them in the shared data directory.
+type deployHistory struct {
+	mu   sync.Mutex
+	logs map[string]*indexedLog
+}
+
+// indexedLog is the deploy log of an environment with the searchable text of each entry.
+type indexedLog struct {
+	// entries are sorted newest first.
+	entries []DeployLogEntry
+	text    []string
+	// modTime is the modification time of the file which the log was read from.
+	modTime time.Time
+}
+
+// history is the index of the deploy logs in the data directory.
+var history = &deployHistory{logs: make(map[string]*indexedLog)}
+
+// search returns the entries in the deploy log of "env" which match "q", newest first.
+func (h *deployHistory) search(env string, q historyQuery) ([]DeployLogEntry, error) {
+	l, err := h.load(env)
+	if err != nil {
+		return nil, err
+	}
+	var matched []DeployLogEntry
+	for i, e := range l.entries {
+		if q.match(e, l.text[i]) {
+			matched = append(matched, e)
+		}
+	}
+	return matched, nil
+}
+
+// load returns the index of the deploy log of "env", reading the log if it is not indexed yet.
+func (h *deployHistory) load(env string) (*indexedLog, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	var modTime time.Time
+	if fi, err := os.Stat(path.Join(*dataPath, env+".json")); err == nil {
+		modTime = fi.ModTime()
+	}
+	if l, ok := h.logs[env]; ok && l.modTime.Equal(modTime) {
+		return l, nil
+	}
+	entries, err := readEntries(env)
+	if err != nil {
+		return nil, err
+	}
+	sort.Sort(ByTime(entries))
+	l := &indexedLog{entries: entries, text: make([]string, len(entries)), modTime: modTime}
+	for i, e := range entries {
+		l.text[i] = searchText(e)
+	}
+	h.logs[env] = l
+	return l, nil
+}
+
+// baseline returns the baseline of the durations of the latest successful deployments to "env",
+// or false if there are too few of them. Rolled back deployments are not counted.
+func (h *deployHistory) baseline(env string) (deploypkg.Baseline, bool) {
+	l, err := h.load(env)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			glog.Errorf("Failed to read deploy log of %s: %v", env, err)
+		}
+		return deploypkg.Baseline{}, false
+	}
+	var durations []time.Duration
+	for _, e := range l.entries {
+		if len(durations) == deploypkg.MaxBaselineSamples {
+			break
+		}
+		if e.Success && e.RolledBackTo == "" && e.Duration > 0 {
+			durations = append(durations, e.Duration)
+		}
+	}
+	return deploypkg.NewBaseline(durations)
+}
+
+// between returns the entries in the deploy log of "env" from "start" until "end", newest first.
+// It returns no entries if the environment has never been deployed.
+func (h *deployHistory) between(env string, start, end time.Time) ([]DeployLogEntry, error) {
+	l, err := h.load(env)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var entries []DeployLogEntry
+	for _, e := range l.entries {
+		if !e.Time.Before(start) && e.Time.Before(end) {
+			entries = append(entries, e)
+		}
+	}
+	return entries, nil
+}
+
+// forget drops the index of the deploy log of "env" after it changes.
+func (h *deployHistory) forget(env string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	delete(h.logs, env)
+}
+
+// pageEntries returns the "page"-th page of "entries" with "perPage" entries per page,
+// and whether there are more entries after the page.
+func pageEntries(entries []DeployLogEntry, page, perPage int) ([]DeployLogEntry, bool) {
+	if page < 1 || perPage < 1 {
+		return nil, false
+	}
+	start := (page - 1) * perPage
+	if start >= len(entries) {
+		return nil, false
+	}
+	end := start + perPage
+	if end >= len(entries) {
+		return entries[start:], false
+	}
+	return entries[start:end], true
+}
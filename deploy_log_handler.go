@@ -8,9 +8,11 @@ import (
 	"math"
 	"net/http"
 	"path"
-	"sort"
+	"strconv"
+	"strings"
 	"time"
 
+	"github.com/gengo/goship/handlers/comment"
 	"github.com/gengo/goship/lib/auth"
 	"github.com/gengo/goship/lib/config"
 	"github.com/gengo/goship/lib/revision"
@@ -18,8 +20,22 @@ import (
 	"github.com/golang/glog"
 )
 
+const (
+	// defaultHistoryPerPage is the number of deployments in a page of the deploy log unless "per_page" is given.
+	defaultHistoryPerPage = 50
+	// maxShownActionRuns is the number of the latest runs of quick actions shown with the deploy log.
+	maxShownActionRuns = 20
+	// maxShownCommandRuns is the number of the latest remote commands shown with the deploy log.
+	maxShownCommandRuns = 20
+)
+
 // DeployLogHandler shows data about the environment including the deploy log.
+// The log is paginated by "page" and "per_page", newest first, and filtered by optional query parameters:
+// "user", "result" (success or failure), "sha", "label", "since" and "until" (YYYY-MM-DD, inclusive) and "q" to search text.
+//
+// e.g. http://127.0.0.1:8000/deployLog/admin-production?user=alice&result=failure&since=2015-11-01&q=migration&page=2
 type DeployLogHandler struct {
+	ecl    config.ETCDInterface
 	assets helpers.Assets
 }
 
@@ -30,10 +46,56 @@ func (h DeployLogHandler) ServeHTTP(w http.ResponseWriter, r *http.Request, full
 		http.Error(w, err.Error(), http.StatusUnauthorized)
 		return
 	}
-	d, err := readEntries(fullEnv)
+	q, err := parseHistoryQuery(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	d, err := history.search(fullEnv, q)
 	if err != nil {
 		glog.Errorf("Failed to read entries: %v", err)
 	}
+	page, perPage := intParam(r, "page", 1), intParam(r, "per_page", defaultHistoryPerPage)
+	d, more := pageEntries(d, page, perPage)
+	pin, err := config.LoadPin(h.ecl, projectName, environment.Name)
+	if err != nil {
+		glog.Errorf("Failed to load pin: %v", err)
+	}
+	lock, err := config.LoadLock(h.ecl, projectName, environment.Name)
+	if err != nil {
+		glog.Errorf("Failed to load lock: %v", err)
+	}
+	runs, err := config.LoadActionRuns(h.ecl, projectName, environment.Name)
+	if err != nil {
+		glog.Errorf("Failed to load action runs: %v", err)
+	}
+	if len(runs) > maxShownActionRuns {
+		runs = runs[len(runs)-maxShownActionRuns:]
+	}
+	// newest first like the deploy log
+	var actionRuns []actionRunView
+	for i := len(runs) - 1; i >= 0; i-- {
+		actionRuns = append(actionRuns, actionRunView{ActionRun: runs[i], FormattedDuration: (runs[i].Duration / time.Millisecond * time.Millisecond).String()})
+	}
+	var commandRuns []commandRunView
+	if environment.RemoteCommands != nil {
+		cmds, err := config.LoadCommandRuns(h.ecl, projectName, environment.Name)
+		if err != nil {
+			glog.Errorf("Failed to load remote commands: %v", err)
+		}
+		if len(cmds) > maxShownCommandRuns {
+			cmds = cmds[len(cmds)-maxShownCommandRuns:]
+		}
+		for i := len(cmds) - 1; i >= 0; i-- {
+			commandRuns = append(commandRuns, commandRunView{CommandRun: cmds[i], FormattedDuration: (cmds[i].Duration / time.Millisecond * time.Millisecond).String()})
+		}
+	}
+	var group []config.LockGroupMember
+	if c, err := config.Load(h.ecl); err != nil {
+		glog.Errorf("Failed to load configuration: %v", err)
+	} else {
+		group = config.LockGroup(c.Projects, projectName, environment.Name)
+	}
 	t, err := template.New("deploy_log.html").ParseFiles("templates/deploy_log.html", "templates/base.html")
 	if err != nil {
 		glog.Errorf("Failed to parse templates: %v", err)
@@ -42,8 +104,17 @@ func (h DeployLogHandler) ServeHTTP(w http.ResponseWriter, r *http.Request, full
 	}
 	for i := range d {
 		d[i].FormattedTime = formatTime(d[i].Time)
+		if d[i].Duration > 0 {
+			d[i].FormattedDuration = (d[i].Duration / time.Second * time.Second).String()
+		}
+	}
+	comments, err := config.LoadComments(h.ecl, projectName, environment.Name)
+	if err != nil {
+		glog.Errorf("Failed to load comments: %v", err)
+	}
+	for i := range d {
+		d[i].Comments = comment.Thread(comments, d[i].Time)
 	}
-	sort.Sort(ByTime(d))
 	js, css := h.assets.Templates()
 
 	params := map[string]interface{}{
@@ -54,10 +125,36 @@ func (h DeployLogHandler) ServeHTTP(w http.ResponseWriter, r *http.Request, full
 		"Env":         fullEnv,
 		"Environment": environment,
 		"ProjectName": projectName,
+		"Pin":         pin,
+		"Lock":        lock,
+		"LockGroup":   group,
+		"ActionRuns":  actionRuns,
+		"CommandRuns": commandRuns,
+		"Notes":       comment.Thread(comments, time.Time{}),
+		"Query":       r.URL.Query(),
+		"Filtered":    q != historyQuery{},
+	}
+	if page > 1 {
+		params["PrevURL"] = pageURL(r, page-1)
+	}
+	if more {
+		params["NextURL"] = pageURL(r, page+1)
 	}
 	helpers.RespondWithTemplate(w, "text/html", t, "base", params)
 }
 
+// actionRunView is a run of a quick action shown with the deploy log.
+type actionRunView struct {
+	config.ActionRun
+	FormattedDuration string
+}
+
+// commandRunView is a remote command shown with the deploy log.
+type commandRunView struct {
+	config.CommandRun
+	FormattedDuration string
+}
+
 func formatTime(t time.Time) string {
 	s := time.Since(t)
 	switch {
@@ -113,9 +210,85 @@ type DeployLogEntry struct {
 	DiffURL       string
 	ToRevisionMsg string
 	User          string
+	// Owner is the user responsible for the deployment if it was handed off from User.
+	Owner         string `json:",omitempty"`
 	Success       bool
 	Time          time.Time
 	FormattedTime string `json:",omitempty"`
+	// RolledBackTo is the revision deployed again after the deployment failed its health check.
+	RolledBackTo revision.Revision `json:",omitempty"`
+	// Labels are tags of the deployment given by users, e.g. "hotfix".
+	Labels []string `json:",omitempty"`
+	// Reason is the justification given by the user who requested the deployment.
+	Reason string `json:",omitempty"`
+	// Duration is how long the deployment took. It is zero for restarts and for deployments recorded before durations were.
+	Duration          time.Duration `json:",omitempty"`
+	FormattedDuration string        `json:",omitempty"`
+	// Anomaly describes how Duration deviates from the usual durations in the environment, if significantly.
+	Anomaly string `json:",omitempty"`
+	// Skipped are the preceding environments which the revision had not been deployed to successfully.
+	Skipped []string `json:",omitempty"`
+	// Comments are the thread of the deployment shown in the deploy log. They are stored separately from the log.
+	Comments []comment.Entry `json:"-"`
+}
+
+// hasLabel returns true if the deployment is labeled "label".
+func (e DeployLogEntry) hasLabel(label string) bool {
+	for _, l := range e.Labels {
+		if l == label {
+			return true
+		}
+	}
+	return false
+}
+
+// parseHistoryQuery returns the historyQuery given in the query parameters of "r".
+func parseHistoryQuery(r *http.Request) (historyQuery, error) {
+	q := historyQuery{
+		User:  r.FormValue("user"),
+		SHA:   r.FormValue("sha"),
+		Label: r.FormValue("label"),
+		Text:  strings.TrimSpace(r.FormValue("q")),
+	}
+	switch result := r.FormValue("result"); result {
+	case "", "success", "failure":
+		q.Result = result
+	default:
+		return historyQuery{}, fmt.Errorf("invalid result %q", result)
+	}
+	const layout = "2006-01-02"
+	if s := r.FormValue("since"); s != "" {
+		t, err := time.Parse(layout, s)
+		if err != nil {
+			return historyQuery{}, fmt.Errorf("invalid since %q", s)
+		}
+		q.Since = t
+	}
+	if s := r.FormValue("until"); s != "" {
+		t, err := time.Parse(layout, s)
+		if err != nil {
+			return historyQuery{}, fmt.Errorf("invalid until %q", s)
+		}
+		// until the end of the day
+		q.Until = t.Add(24*time.Hour - time.Nanosecond)
+	}
+	return q, nil
+}
+
+// pageURL returns the URL of the "page"-th page of the deploy log requested by "r".
+func pageURL(r *http.Request, page int) string {
+	params := r.URL.Query()
+	params.Set("page", strconv.Itoa(page))
+	return r.URL.Path + "?" + params.Encode()
+}
+
+// intParam returns the positive integer in the query parameter "name" of "r", or "def" if not given or invalid.
+func intParam(r *http.Request, name string, def int) int {
+	n, err := strconv.Atoi(r.FormValue(name))
+	if err != nil || n < 1 {
+		return def
+	}
+	return n
 }
 
 type ByTime []DeployLogEntry
@@ -1,19 +1,127 @@
 package main
 
 import (
-	"io/ioutil"
+	"io"
 	"net/http"
-	"path"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/gengo/goship/lib/outputstore"
+	"github.com/golang/glog"
+	"golang.org/x/net/context"
 )
 
-func DeployOutputHandler(w http.ResponseWriter, r *http.Request, env string, formattedTime string) {
-	log := path.Join(*dataPath, env, formattedTime+".log")
+// outputKey returns the key of the output of the deployment at "t" to "env", which is "$project-$environment".
+// The output is kept at the key in the data directory, and in the output store after the deployment if any.
+func outputKey(env string, t time.Time) string {
+	// Round(0) drops the monotonic clock reading so that the key matches the time read back from the deploy log.
+	return env + "/" + t.Round(0).String() + ".log"
+}
+
+// outputLog writes the output of a deployment into the data directory.
+type outputLog struct {
+	key string
+	mu  sync.Mutex
+	f   *os.File
+}
+
+// createOutputLog creates the file of the output of the deployment at "t" to "env".
+func createOutputLog(env string, t time.Time) (*outputLog, error) {
+	key := outputKey(env, t)
+	p := filepath.Join(*dataPath, filepath.FromSlash(key))
+	if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+		return nil, err
+	}
+	f, err := os.OpenFile(p, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return nil, err
+	}
+	return &outputLog{key: key, f: f}, nil
+}
+
+// append writes a line of the output.
+func (l *outputLog) append(line string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if _, err := io.WriteString(l.f, line+"\n"); err != nil {
+		glog.Errorf("Failed to write output into %s: %v", l.f.Name(), err)
+	}
+}
 
-	b, err := ioutil.ReadFile(log)
+// close closes the file, and moves it into "store" if not nil.
+func (l *outputLog) close(store outputstore.Store) {
+	if err := l.f.Close(); err != nil {
+		glog.Errorf("Failed to close %s: %v", l.f.Name(), err)
+		return
+	}
+	if store == nil {
+		return
+	}
+	f, err := os.Open(l.f.Name())
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		glog.Errorf("Failed to open %s: %v", l.f.Name(), err)
+		return
+	}
+	defer f.Close()
+	if err := store.Put(l.key, f); err != nil {
+		// keep the local copy, which the output handler still serves
+		glog.Errorf("Failed to store output %s: %v", l.key, err)
 		return
 	}
+	if err := os.Remove(l.f.Name()); err != nil {
+		glog.Errorf("Failed to remove %s: %v", l.f.Name(), err)
+	}
+}
+
+// newDeployOutputHandler returns a handler which streams the output of a deployment.
+// It reads the data directory first, and then "store" if not nil.
+func newDeployOutputHandler(store outputstore.Store) func(http.ResponseWriter, *http.Request, string, string) {
+	return func(w http.ResponseWriter, r *http.Request, env string, formattedTime string) {
+		key := env + "/" + formattedTime + ".log"
+		var rc io.ReadCloser
+		f, err := os.Open(filepath.Join(*dataPath, filepath.FromSlash(key)))
+		switch {
+		case err == nil:
+			rc = f
+		case os.IsNotExist(err) && store != nil:
+			rc, err = store.Get(key)
+		case os.IsNotExist(err):
+			err = outputstore.ErrNotFound
+		}
+		if err == outputstore.ErrNotFound {
+			http.NotFound(w, r)
+			return
+		}
+		if err != nil {
+			glog.Errorf("Failed to open output %s: %v", key, err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		defer rc.Close()
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		if _, err := io.Copy(w, rc); err != nil {
+			glog.Errorf("Failed to send output %s: %v", key, err)
+		}
+	}
+}
 
-	w.Write(b)
+// compactOutputs removes outputs which "retention" does not keep from "store" every "interval".
+func compactOutputs(ctx context.Context, store outputstore.Store, retention outputstore.Retention, interval time.Duration) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(interval):
+		}
+		n, err := outputstore.Compact(store, retention, ".log", time.Now())
+		if err != nil {
+			glog.Errorf("Failed to compact deploy outputs: %v", err)
+			continue
+		}
+		if n > 0 {
+			glog.Infof("Removed %d deploy output(s)", n)
+		}
+	}
 }
@@ -0,0 +1,297 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gengo/goship/lib/auth"
+	"github.com/gengo/goship/lib/config"
+	"github.com/gengo/goship/lib/diagnostics"
+	helpers "github.com/gengo/goship/lib/view-helpers"
+	"github.com/golang/glog"
+	"golang.org/x/net/context"
+)
+
+// diagnosticsTimeout is how long each check of the diagnostics can take.
+const diagnosticsTimeout = 10 * time.Second
+
+var (
+	// githubRateLimitURL is the endpoint of GitHub which verifies the API token without consuming the rate limit.
+	githubRateLimitURL = "https://api.github.com/rate_limit"
+	diagnosticsClient  = &http.Client{Timeout: diagnosticsTimeout}
+)
+
+// diagnosticChecks returns the self-tests of the dependencies of "b".
+// External services are not checked if "b" simulates them.
+func diagnosticChecks(b backend, ecl config.ETCDInterface) []diagnostics.Check {
+	return []diagnostics.Check{
+		{Name: "etcd", Run: func(ctx context.Context) (string, error) { return checkETCD(ecl) }},
+		{Name: "github", Run: func(ctx context.Context) (string, error) { return checkGithub(b.demo) }},
+		{Name: "ssh-keys", Run: func(ctx context.Context) (string, error) { return checkSSHKeys(ecl, b.demo) }},
+		{Name: "data-dir", Run: func(ctx context.Context) (string, error) { return checkDataDir(*dataPath) }},
+		{Name: "webhook-secrets", Run: func(ctx context.Context) (string, error) { return checkWebhookSecrets(ecl) }},
+		{Name: "notifications", Run: func(ctx context.Context) (string, error) { return checkNotifications(ctx, ecl, b.demo) }},
+	}
+}
+
+func checkETCD(ecl config.ETCDInterface) (string, error) {
+	c, err := config.Load(ecl)
+	if err != nil {
+		return "", fmt.Errorf("cannot load configuration: %v", err)
+	}
+	return fmt.Sprintf("loaded %d project(s)", len(c.Projects)), nil
+}
+
+func checkGithub(demo bool) (string, error) {
+	if demo {
+		return "", diagnostics.Skip("simulated in demo mode")
+	}
+	token := os.Getenv(gitHubAPITokenEnvVar)
+	if token == "" {
+		return "", fmt.Errorf("environment variable %s not defined", gitHubAPITokenEnvVar)
+	}
+	req, err := http.NewRequest("GET", githubRateLimitURL, nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Authorization", "token "+token)
+	resp, err := diagnosticsClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("%s rejected $%s: %s", githubRateLimitURL, gitHubAPITokenEnvVar, resp.Status)
+	}
+	remaining := resp.Header.Get("X-RateLimit-Remaining")
+	if remaining == "0" {
+		return "", diagnostics.Warn("rate limit exhausted until %s", resetTime(resp.Header.Get("X-RateLimit-Reset")))
+	}
+	return fmt.Sprintf("authenticated; %s request(s) remaining", remaining), nil
+}
+
+// resetTime formats the value of X-RateLimit-Reset, which is in seconds since the epoch.
+func resetTime(v string) string {
+	var sec int64
+	if _, err := fmt.Sscan(v, &sec); err != nil {
+		return v
+	}
+	return time.Unix(sec, 0).Format(time.RFC3339)
+}
+
+// checkSSHKeys checks that the private keys of all the environments are readable.
+func checkSSHKeys(ecl config.ETCDInterface, demo bool) (string, error) {
+	if demo {
+		return "", diagnostics.Skip("simulated in demo mode")
+	}
+	c, err := config.Load(ecl)
+	if err != nil {
+		return "", fmt.Errorf("cannot load configuration: %v", err)
+	}
+	keys := map[string]bool{*keyPath: true}
+	for _, p := range c.Projects {
+		for _, e := range p.Environments {
+			if s := c.SSHConfig(p, e); s.KeyFile != "" {
+				keys[s.KeyFile] = true
+			}
+		}
+	}
+	var unreadable []string
+	for k := range keys {
+		if _, err := ioutil.ReadFile(k); err != nil {
+			unreadable = append(unreadable, err.Error())
+		}
+	}
+	sort.Strings(unreadable)
+	switch {
+	case len(unreadable) == 0:
+		return fmt.Sprintf("%d key(s) readable", len(keys)), nil
+	case os.Getenv("SSH_AUTH_SOCK") != "":
+		return "", diagnostics.Warn("only ssh-agent can be used: %s", strings.Join(unreadable, "; "))
+	default:
+		return "", fmt.Errorf("%s", strings.Join(unreadable, "; "))
+	}
+}
+
+// checkDataDir checks that deployment logs can be written into "dir".
+func checkDataDir(dir string) (string, error) {
+	fi, err := os.Stat(dir)
+	if err != nil {
+		return "", err
+	}
+	if !fi.IsDir() {
+		return "", fmt.Errorf("%s is not a directory", dir)
+	}
+	f, err := ioutil.TempFile(dir, ".diagnostics")
+	if err != nil {
+		return "", fmt.Errorf("%s is not writable: %v", dir, err)
+	}
+	f.Close()
+	if err := os.Remove(f.Name()); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%s is writable", dir), nil
+}
+
+// checkWebhookSecrets checks that the secrets which authenticate inbound requests are configured where they are needed.
+func checkWebhookSecrets(ecl config.ETCDInterface) (string, error) {
+	c, err := config.Load(ecl)
+	if err != nil {
+		return "", fmt.Errorf("cannot load configuration: %v", err)
+	}
+	var configured, missing []string
+	if c.Slack != nil {
+		if c.Slack.SigningSecret == "" {
+			missing = append(missing, "slack signing_secret")
+		} else {
+			configured = append(configured, "slack")
+		}
+	}
+	if c.SCIM != nil {
+		configured = append(configured, "scim")
+	}
+	if len(c.CITokens) > 0 {
+		configured = append(configured, fmt.Sprintf("%d ci token(s)", len(c.CITokens)))
+	}
+	if *clusterAdvertise != "" {
+		if *clusterToken == "" {
+			missing = append(missing, "-cluster-token")
+		} else {
+			configured = append(configured, "cluster")
+		}
+	}
+	switch {
+	case len(missing) > 0:
+		return "", fmt.Errorf("not configured: %s", strings.Join(missing, ", "))
+	case len(configured) == 0:
+		return "", diagnostics.Skip("no inbound webhooks configured")
+	}
+	return "configured for " + strings.Join(configured, ", "), nil
+}
+
+// checkNotifications checks that the notifiers of the projects and the mail server accept connections.
+// It does not send any notifications.
+func checkNotifications(ctx context.Context, ecl config.ETCDInterface, demo bool) (string, error) {
+	if demo {
+		return "", diagnostics.Skip("simulated in demo mode")
+	}
+	c, err := config.Load(ecl)
+	if err != nil {
+		return "", fmt.Errorf("cannot load configuration: %v", err)
+	}
+	addrs := make(map[string]bool)
+	var invalid []string
+	for _, p := range c.Projects {
+		for _, n := range p.Notifiers {
+			addr, err := notifierAddr(n.URL)
+			if err != nil {
+				invalid = append(invalid, fmt.Sprintf("%s: %v", p.Name, err))
+				continue
+			}
+			addrs[addr] = true
+		}
+	}
+	if c.SMTP != nil {
+		addrs[c.SMTP.Address] = true
+	}
+	if len(addrs) == 0 && len(invalid) == 0 {
+		return "", diagnostics.Skip("no notification channels configured")
+	}
+	var d net.Dialer
+	if deadline, ok := ctx.Deadline(); ok {
+		d.Deadline = deadline
+	}
+	var unreachable []string
+	for addr := range addrs {
+		conn, err := d.Dial("tcp", addr)
+		if err != nil {
+			unreachable = append(unreachable, err.Error())
+			continue
+		}
+		conn.Close()
+	}
+	sort.Strings(unreachable)
+	if failed := append(invalid, unreachable...); len(failed) > 0 {
+		return "", fmt.Errorf("%d of %d channel(s) failed: %s", len(failed), len(addrs)+len(invalid), strings.Join(failed, "; "))
+	}
+	return fmt.Sprintf("%d channel(s) reachable", len(addrs)), nil
+}
+
+// notifierAddr returns the host and the port which notifications to "u" are sent to.
+func notifierAddr(u string) (string, error) {
+	p, err := url.Parse(u)
+	if err != nil {
+		return "", err
+	}
+	if p.Host == "" {
+		return "", fmt.Errorf("no host in %q", u)
+	}
+	if _, _, err := net.SplitHostPort(p.Host); err == nil {
+		return p.Host, nil
+	}
+	port := "80"
+	if p.Scheme == "https" {
+		port = "443"
+	}
+	return net.JoinHostPort(p.Host, port), nil
+}
+
+// logDiagnostics runs "checks" and logs the report as a single entry.
+func logDiagnostics(ctx context.Context, checks []diagnostics.Check) {
+	r := diagnostics.Run(ctx, checks, diagnosticsTimeout)
+	if r.Healthy {
+		glog.Infof("%s", r)
+		return
+	}
+	glog.Warningf("%s", r)
+}
+
+// DiagnosticsHandler runs the self-tests of the dependencies and reports their results.
+// Only admins can run them. The report is in plain text if "format" is "text".
+//
+// e.g. GET http://127.0.0.1:8000/api/system/diagnostics?format=text
+type DiagnosticsHandler struct {
+	ecl    config.ETCDInterface
+	checks []diagnostics.Check
+}
+
+func (h DiagnosticsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	u, err := auth.CurrentUser(r)
+	if err != nil {
+		glog.Errorf("Failed to get current user: %v", err)
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return
+	}
+	c, err := config.Load(h.ecl)
+	if err != nil {
+		glog.Errorf("Failed to load configuration: %v", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if !c.IsAdmin(u.Name) {
+		glog.Errorf("%s is not allowed to run diagnostics", u.Name)
+		http.Error(w, "only admins can run diagnostics", http.StatusForbidden)
+		return
+	}
+
+	report := diagnostics.Run(context.Background(), h.checks, diagnosticsTimeout)
+	if r.FormValue("format") == "text" {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		if _, err := fmt.Fprint(w, report); err != nil {
+			glog.Errorf("Failed to send response: %v", err)
+		}
+		return
+	}
+	helpers.RespondWithJSON(w, http.StatusOK, report)
+}
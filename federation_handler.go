@@ -0,0 +1,168 @@
+package main
+
+import (
+	"crypto/subtle"
+	"fmt"
+	"html/template"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gengo/goship/lib/acl"
+	"github.com/gengo/goship/lib/auth"
+	"github.com/gengo/goship/lib/config"
+	deploypkg "github.com/gengo/goship/lib/deploy"
+	"github.com/gengo/goship/lib/federation"
+	helpers "github.com/gengo/goship/lib/view-helpers"
+	"github.com/golang/glog"
+)
+
+// peerTimeout is the maximum duration to wait for the status of a peer.
+const peerTimeout = 10 * time.Second
+
+// StatusHandler serves the read-only status of the projects in this instance as JSON.
+// Peers authenticate with the federation token as a bearer token and can read all the projects.
+// Other requests must be authenticated as a user, and only the projects readable by the user are included.
+type StatusHandler struct {
+	ac      acl.AccessControl
+	ecl     config.ETCDInterface
+	tracker *deploypkg.Tracker
+}
+
+func (h StatusHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	c, err := config.Load(h.ecl)
+	if err != nil {
+		glog.Errorf("Failed to load configuration: %v", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	// peers are not admins
+	projs := c.VisibleProjects(c.Projects, "")
+	if !peerAuthorized(c, r) {
+		u, err := auth.CurrentUser(r)
+		if err != nil {
+			glog.Errorf("Failed to get current user: %v", err)
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+			return
+		}
+		projs = c.VisibleProjects(acl.ReadableProjects(h.ac, c.Projects, u), u.Name)
+	}
+	helpers.RespondWithJSON(w, http.StatusOK, localStatus(h.ecl, h.tracker, c, projs))
+}
+
+// peerAuthorized returns true if "r" carries the federation token of "c".
+func peerAuthorized(c config.Config, r *http.Request) bool {
+	if c.Federation == nil || c.Federation.Token == "" {
+		return false
+	}
+	token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	return subtle.ConstantTimeCompare([]byte(token), []byte(c.Federation.Token)) == 1
+}
+
+// localStatus returns the status of "projs" in this instance.
+func localStatus(ecl config.ETCDInterface, tracker *deploypkg.Tracker, c config.Config, projs []config.Project) federation.Status {
+	var s federation.Status
+	if c.Federation != nil {
+		s.Instance = c.Federation.Name
+	}
+	s.Projects = []federation.Project{}
+	sort.Sort(ByName(projs))
+	for _, p := range projs {
+		repo := p.SourceRepo()
+		fp := federation.Project{Name: p.Name, RepoOwner: repo.RepoOwner, RepoName: repo.RepoName, Environments: []federation.Environment{}}
+		for _, e := range p.Environments {
+			fe := federation.Environment{Name: e.Name}
+			lock, err := config.LoadLock(ecl, p.Name, e.Name)
+			if err != nil {
+				glog.Errorf("Failed to load lock of %s-%s: %v", p.Name, e.Name, err)
+			}
+			fe.Lock = lock
+			if pr, ok := tracker.Get(p.Name, e.Name); ok && active(pr.State) {
+				fe.State = pr.State
+			}
+			entries, err := readEntries(fmt.Sprintf("%s-%s", p.Name, e.Name))
+			if err != nil && !os.IsNotExist(err) {
+				glog.Errorf("Failed to read deploy log of %s-%s: %v", p.Name, e.Name, err)
+			}
+			for _, d := range entries {
+				if fe.LastDeploy == nil || d.Time.After(fe.LastDeploy.Time) {
+					fe.LastDeploy = &federation.Deploy{User: d.User, From: d.Range.From, To: d.Range.To, Success: d.Success, Time: d.Time}
+				}
+			}
+			fp.Environments = append(fp.Environments, fe)
+		}
+		s.Projects = append(s.Projects, fp)
+	}
+	return s
+}
+
+// active returns true if a deployment in "s" has not finished yet.
+func active(s deploypkg.State) bool {
+	switch s {
+	case deploypkg.StateDone, deploypkg.StateFailed, deploypkg.StateRejected:
+		return false
+	}
+	return true
+}
+
+// FederationHandler shows the status of this instance and its peers in a single dashboard.
+type FederationHandler struct {
+	ac      acl.AccessControl
+	ecl     config.ETCDInterface
+	tracker *deploypkg.Tracker
+	client  *federation.Client
+	assets  helpers.Assets
+}
+
+func (h FederationHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	u, err := auth.CurrentUser(r)
+	if err != nil {
+		glog.Errorf("Failed to get current user: %v", err)
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return
+	}
+	c, err := config.Load(h.ecl)
+	if err != nil {
+		glog.Errorf("Failed to load configuration: %v", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	local := localStatus(h.ecl, h.tracker, c, c.VisibleProjects(acl.ReadableProjects(h.ac, c.Projects, u), u.Name))
+	instances := []federation.PeerStatus{{Peer: local.Instance, Status: &local}}
+	if c.Federation != nil {
+		for _, ps := range h.client.FetchAll(c.Federation.Peers) {
+			if ps.Status != nil {
+				// Peers serve all their projects, so filter them by the access control of this instance.
+				s := federation.Readable(*ps.Status, h.ac, u.Name)
+				ps.Status = &s
+			} else {
+				glog.Warningf("Failed to fetch status of peer %s: %s", ps.Peer, ps.Error)
+			}
+			ps.URL = strings.TrimSuffix(ps.URL, "/")
+			instances = append(instances, ps)
+		}
+	}
+
+	if r.FormValue("format") == "json" {
+		helpers.RespondWithJSON(w, http.StatusOK, instances)
+		return
+	}
+
+	t, err := template.New("federation.html").ParseFiles("templates/federation.html", "templates/base.html")
+	if err != nil {
+		glog.Errorf("Failed to parse templates: %v", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	js, css := h.assets.Templates()
+	params := map[string]interface{}{
+		"Javascript": js,
+		"Stylesheet": css,
+		"User":       u,
+		"Page":       "federation",
+		"Instances":  instances,
+	}
+	helpers.RespondWithTemplate(w, "text/html", t, "base", params)
+}
@@ -0,0 +1,63 @@
+// Package activity serves the feed of recent events as JSON.
+package activity
+
+import (
+	"net/http"
+
+	"github.com/gengo/goship/lib/acl"
+	"github.com/gengo/goship/lib/auth"
+	"github.com/gengo/goship/lib/config"
+	"github.com/gengo/goship/lib/notification"
+	helpers "github.com/gengo/goship/lib/view-helpers"
+	"github.com/golang/glog"
+)
+
+type handler struct {
+	ac   acl.AccessControl
+	ecl  config.ETCDInterface
+	feed *notification.Feed
+}
+
+// New returns a new http.Handler which serves the events in "feed", newest first.
+// It accepts optional query parameters "project" and "environment" to filter the events.
+//
+// e.g. http://127.0.0.1:8000/api/activity?project=admin&environment=staging
+func New(ac acl.AccessControl, ecl config.ETCDInterface, feed *notification.Feed) http.Handler {
+	return handler{ac: ac, ecl: ecl, feed: feed}
+}
+
+func (h handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	u, err := auth.CurrentUser(r)
+	if err != nil {
+		glog.Errorf("Failed to get current user: %v", err)
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return
+	}
+	c, err := config.Load(h.ecl)
+	if err != nil {
+		glog.Errorf("Failed to load configuration: %v", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	readable := make(map[string]bool)
+	for _, p := range acl.ReadableProjects(h.ac, c.Projects, u) {
+		readable[p.Name] = true
+	}
+
+	proj, env := r.FormValue("project"), r.FormValue("environment")
+	events := []notification.Event{}
+	for _, ev := range h.feed.Events() {
+		if !readable[ev.Project] {
+			continue
+		}
+		if proj != "" && ev.Project != proj {
+			continue
+		}
+		if env != "" && ev.Environment != env {
+			continue
+		}
+		events = append(events, ev)
+	}
+
+	helpers.RespondWithJSON(w, http.StatusOK, events)
+}
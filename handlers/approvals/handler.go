@@ -0,0 +1,172 @@
+// Package approvals serves approvals of deployments in environments which require them.
+package approvals
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/gengo/goship/lib/acl"
+	"github.com/gengo/goship/lib/auth"
+	"github.com/gengo/goship/lib/config"
+	"github.com/gengo/goship/lib/notification"
+	"github.com/gengo/goship/lib/revision"
+	helpers "github.com/gengo/goship/lib/view-helpers"
+	"github.com/golang/glog"
+	"golang.org/x/net/context"
+)
+
+// ApprovedFunc is called with an approval request after it gets approved.
+type ApprovedFunc func(proj config.Project, env config.Environment, a config.Approval)
+
+// decider approves or rejects approval requests.
+type decider struct {
+	ac       acl.AccessControl
+	ecl      config.ETCDInterface
+	approved ApprovedFunc
+}
+
+// decide approves or rejects the approval request "id" in "envName" of "projName" on behalf of "u".
+// It returns an HTTP status code which describes the error on failure.
+func (d decider) decide(u auth.User, projName, envName, id string, approve bool) (config.Approval, int, error) {
+	user := u.Name
+	c, err := config.Load(d.ecl)
+	if err != nil {
+		glog.Errorf("Failed to load configuration: %v", err)
+		return config.Approval{}, http.StatusInternalServerError, err
+	}
+	proj, err := config.ProjectFromName(c.Projects, projName)
+	if err != nil {
+		return config.Approval{}, http.StatusNotFound, fmt.Errorf("no such project")
+	}
+	env, err := config.EnvironmentFromName(c.Projects, projName, envName)
+	if err != nil {
+		return config.Approval{}, http.StatusNotFound, fmt.Errorf("no such project/environment")
+	}
+	if !acl.Permitted(d.ac, c, proj, *env, u, config.RoleDeployer) || !env.CanApprove(user) {
+		glog.Errorf("%s is not allowed to approve deployments of %s-%s", user, projName, envName)
+		return config.Approval{}, http.StatusForbidden, fmt.Errorf("not allowed to approve deployments of the environment")
+	}
+	approvals, err := config.LoadApprovals(d.ecl, projName, envName)
+	if err != nil {
+		glog.Errorf("Failed to load approvals of %s-%s: %v", projName, envName, err)
+		return config.Approval{}, http.StatusInternalServerError, err
+	}
+	for _, a := range approvals {
+		if a.ID == id && a.User == user {
+			return config.Approval{}, http.StatusForbidden, fmt.Errorf("cannot approve your own deployment")
+		}
+	}
+
+	a, err := config.DecideApproval(d.ecl, projName, envName, id, user, approve)
+	switch err {
+	case nil:
+	case config.ErrNoSuchApproval:
+		return config.Approval{}, http.StatusNotFound, err
+	case config.ErrAlreadyDecided:
+		return config.Approval{}, http.StatusConflict, err
+	default:
+		glog.Errorf("Failed to decide approval %s of %s-%s: %v", id, projName, envName, err)
+		return config.Approval{}, http.StatusInternalServerError, err
+	}
+	glog.Infof("Deployment of %s to %s-%s %s by %s", a.To, projName, envName, a.State, user)
+	ev := notification.Event{
+		Type:        notification.DeployRejected,
+		Project:     projName,
+		Environment: envName,
+		User:        user,
+		Owner:       a.User,
+		From:        revision.Revision(a.From),
+		To:          revision.Revision(a.To),
+		ApprovalID:  a.ID,
+	}
+	if approve {
+		ev.Type = notification.DeployApproved
+	}
+	notification.NotifyAll(context.Background(), proj, ev)
+	if approve {
+		d.approved(proj, *env, a)
+	}
+	return a, http.StatusOK, nil
+}
+
+type handler struct {
+	decider
+}
+
+// New returns a new http.Handler which lists and decides approval requests of an environment.
+// Anyone who can read the project can list its approval requests.
+// Only deployers of the environment who are listed in its approvers, if any,
+// can approve or reject deployments requested by others.
+// "approved" is called with the request after it gets approved.
+//
+// e.g. GET http://127.0.0.1:8000/api/approvals?project=admin&environment=production
+// POST http://127.0.0.1:8000/api/approvals?project=admin&environment=production&id=1&decision=approve
+func New(ac acl.AccessControl, ecl config.ETCDInterface, approved ApprovedFunc) http.Handler {
+	return handler{decider{ac: ac, ecl: ecl, approved: approved}}
+}
+
+func (h handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	u, err := auth.CurrentUser(r)
+	if err != nil {
+		glog.Errorf("Failed to get current user: %v", err)
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return
+	}
+	projName, envName := r.FormValue("project"), r.FormValue("environment")
+	if projName == "" || envName == "" {
+		http.Error(w, "project and environment must be specified", http.StatusBadRequest)
+		return
+	}
+
+	switch r.Method {
+	case "GET":
+		h.list(w, u.Name, projName, envName)
+	case "POST":
+		var approve bool
+		switch r.FormValue("decision") {
+		case "approve":
+			approve = true
+		case "reject":
+		default:
+			http.Error(w, fmt.Sprintf("invalid decision %q", r.FormValue("decision")), http.StatusBadRequest)
+			return
+		}
+		a, code, err := h.decide(u, projName, envName, r.FormValue("id"), approve)
+		if err != nil {
+			http.Error(w, err.Error(), code)
+			return
+		}
+		helpers.RespondWithJSON(w, http.StatusOK, a)
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func (h handler) list(w http.ResponseWriter, user, projName, envName string) {
+	c, err := config.Load(h.ecl)
+	if err != nil {
+		glog.Errorf("Failed to load configuration: %v", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	proj, err := config.ProjectFromName(c.Projects, projName)
+	if err != nil {
+		http.Error(w, "no such project", http.StatusNotFound)
+		return
+	}
+	repo := proj.SourceRepo()
+	if !h.ac.Readable(repo.RepoOwner, repo.RepoName, user) {
+		http.Error(w, "no such project", http.StatusNotFound)
+		return
+	}
+	approvals, err := config.LoadApprovals(h.ecl, projName, envName)
+	if err != nil {
+		glog.Errorf("Failed to load approvals of %s-%s: %v", projName, envName, err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if approvals == nil {
+		approvals = []config.Approval{}
+	}
+	helpers.RespondWithJSON(w, http.StatusOK, approvals)
+}
@@ -0,0 +1,128 @@
+package approvals
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/gengo/goship/lib/acl"
+	"github.com/gengo/goship/lib/auth"
+	"github.com/gengo/goship/lib/config"
+	"github.com/gengo/goship/lib/notification"
+	helpers "github.com/gengo/goship/lib/view-helpers"
+	"github.com/golang/glog"
+)
+
+const (
+	// maxSlackPayload is the maximum size of interaction requests from Slack.
+	maxSlackPayload = 1 << 20
+)
+
+// slackInteraction is a payload of interactive message buttons.
+// See also https://api.slack.com/docs/message-buttons
+type slackInteraction struct {
+	CallbackID string `json:"callback_id"`
+	Actions    []struct {
+		Name  string `json:"name"`
+		Value string `json:"value"`
+	} `json:"actions"`
+	User struct {
+		ID   string `json:"id"`
+		Name string `json:"name"`
+	} `json:"user"`
+}
+
+// slackResponse replaces the original message with the buttons.
+type slackResponse struct {
+	Text            string `json:"text"`
+	ReplaceOriginal bool   `json:"replace_original"`
+}
+
+type slackHandler struct {
+	decider
+}
+
+// NewSlack returns a new http.Handler which receives clicks on the Approve/Reject buttons in approval requests posted to Slack.
+// Requests must be signed with the signing secret of the Slack app, and the Slack user who clicked must be mapped to a Goship user
+// who can approve deployments of the environment.
+// It must not be wrapped by auth.Authenticate because Slack calls it directly.
+func NewSlack(ac acl.AccessControl, ecl config.ETCDInterface, approved ApprovedFunc) http.Handler {
+	return slackHandler{decider{ac: ac, ecl: ecl, approved: approved}}
+}
+
+func (h slackHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	c, err := config.Load(h.ecl)
+	if err != nil {
+		glog.Errorf("Failed to load configuration: %v", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if c.Slack == nil || c.Slack.SigningSecret == "" {
+		http.Error(w, "slack is not configured", http.StatusNotFound)
+		return
+	}
+	body, err := ioutil.ReadAll(http.MaxBytesReader(w, r.Body, maxSlackPayload))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := notification.VerifySlackRequest(c.Slack.SigningSecret, r.Header, body, time.Now()); err != nil {
+		glog.Errorf("Rejected a request from Slack: %v", err)
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return
+	}
+	form, err := url.ParseQuery(string(body))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	var in slackInteraction
+	if err := json.Unmarshal([]byte(form.Get("payload")), &in); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if in.CallbackID != notification.SlackApprovalCallback || len(in.Actions) != 1 {
+		http.Error(w, "unknown interaction", http.StatusBadRequest)
+		return
+	}
+	action := in.Actions[0]
+	components := strings.SplitN(action.Value, "/", 3)
+	if len(components) != 3 {
+		http.Error(w, fmt.Sprintf("invalid approval %q", action.Value), http.StatusBadRequest)
+		return
+	}
+
+	user, ok := c.Slack.Users[in.User.ID]
+	if !ok {
+		glog.Errorf("Slack user %s (%s) is not mapped to any Goship user", in.User.ID, in.User.Name)
+		writeSlackResponse(w, slackResponse{Text: fmt.Sprintf("Slack user %s is not mapped to any Goship user.", in.User.Name)})
+		return
+	}
+	groups, err := config.LoadUserGroups(h.ecl, user)
+	if err != nil {
+		glog.Errorf("Failed to load groups of %s: %v", user, err)
+		writeSlackResponse(w, slackResponse{Text: fmt.Sprintf("Failed to %s the deployment: %v", action.Name, err)})
+		return
+	}
+	a, _, err := h.decide(auth.User{Name: user, Groups: groups}, components[0], components[1], components[2], action.Name == notification.SlackApprove)
+	if err != nil {
+		writeSlackResponse(w, slackResponse{Text: fmt.Sprintf("Failed to %s the deployment: %v", action.Name, err)})
+		return
+	}
+	writeSlackResponse(w, slackResponse{
+		Text:            fmt.Sprintf("Deployment of %s to *%s* requested by %s was %s by %s.", components[0], components[1], a.User, a.State, user),
+		ReplaceOriginal: true,
+	})
+}
+
+func writeSlackResponse(w http.ResponseWriter, resp slackResponse) {
+	helpers.RespondWithJSON(w, http.StatusOK, resp)
+}
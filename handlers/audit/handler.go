@@ -0,0 +1,142 @@
+// Package audit serves the audit log of privileged actions.
+package audit
+
+import (
+	"html/template"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/gengo/goship/lib/acl"
+	"github.com/gengo/goship/lib/audit"
+	"github.com/gengo/goship/lib/auth"
+	"github.com/gengo/goship/lib/config"
+	helpers "github.com/gengo/goship/lib/view-helpers"
+	"github.com/golang/glog"
+)
+
+// defaultPerPage is the number of records in a page unless "per_page" is given.
+const defaultPerPage = 50
+
+type handler struct {
+	ac     acl.AccessControl
+	ecl    config.ETCDInterface
+	assets helpers.Assets
+}
+
+// New returns a new http.Handler which serves the audit log as an HTML page, newest first.
+// It accepts optional query parameters "actor", "action", "project" and "environment" to filter the records,
+// and "page" and "per_page" to paginate them.
+// With "format=json", it exports all the matching records as JSON instead.
+// Records of projects which the user cannot read are excluded unless the user is an admin.
+//
+// e.g. http://127.0.0.1:8000/audit?project=admin&environment=production&page=2
+// http://127.0.0.1:8000/audit?actor=alice&format=json
+func New(ac acl.AccessControl, ecl config.ETCDInterface, assets helpers.Assets) http.Handler {
+	return handler{ac: ac, ecl: ecl, assets: assets}
+}
+
+func (h handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	u, err := auth.CurrentUser(r)
+	if err != nil {
+		glog.Errorf("Failed to get current user: %v", err)
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return
+	}
+	c, err := config.Load(h.ecl)
+	if err != nil {
+		glog.Errorf("Failed to load configuration: %v", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	readable := make(map[string]bool)
+	for _, p := range acl.ReadableProjects(h.ac, c.Projects, u) {
+		readable[p.Name] = true
+	}
+	all, err := audit.Load(h.ecl)
+	if err != nil {
+		glog.Errorf("Failed to load audit log: %v", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	f := filter{
+		Filter: audit.Filter{
+			Actor:       r.FormValue("actor"),
+			Action:      r.FormValue("action"),
+			Project:     r.FormValue("project"),
+			Environment: r.FormValue("environment"),
+		},
+	}
+	records := []audit.Record{}
+	for _, rec := range all {
+		if rec.Project != "" && !readable[rec.Project] && !c.IsAdmin(u.Name) {
+			continue
+		}
+		if f.Match(rec) {
+			records = append(records, rec)
+		}
+	}
+
+	if r.FormValue("format") == "json" {
+		w.Header().Set("Content-Disposition", `attachment; filename="goship-audit.json"`)
+		helpers.RespondWithJSON(w, http.StatusOK, records)
+		return
+	}
+
+	page, perPage := intParam(r, "page", 1), intParam(r, "per_page", defaultPerPage)
+	records, more := audit.Page(records, page, perPage)
+
+	t, err := template.New("audit.html").ParseFiles("templates/audit.html", "templates/base.html")
+	if err != nil {
+		glog.Errorf("Failed to parse templates: %v", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	js, css := h.assets.Templates()
+	params := map[string]interface{}{
+		"Javascript": js,
+		"Stylesheet": css,
+		"User":       u,
+		"Page":       "audit",
+		"Filter":     f,
+		"Records":    records,
+		"ExportURL":  f.url(url.Values{"format": {"json"}}),
+	}
+	if page > 1 {
+		params["PrevURL"] = f.url(url.Values{"page": {strconv.Itoa(page - 1)}, "per_page": {strconv.Itoa(perPage)}})
+	}
+	if more {
+		params["NextURL"] = f.url(url.Values{"page": {strconv.Itoa(page + 1)}, "per_page": {strconv.Itoa(perPage)}})
+	}
+	helpers.RespondWithTemplate(w, "text/html", t, "base", params)
+}
+
+// filter is an audit.Filter given in query parameters.
+type filter struct {
+	audit.Filter
+}
+
+// url returns the URL of the audit log page with "f" and "params".
+func (f filter) url(params url.Values) string {
+	for name, value := range map[string]string{
+		"actor":       f.Actor,
+		"action":      f.Action,
+		"project":     f.Project,
+		"environment": f.Environment,
+	} {
+		if value != "" {
+			params.Set(name, value)
+		}
+	}
+	return "/audit?" + params.Encode()
+}
+
+// intParam returns the positive integer in the query parameter "name" of "r", or "def" if not given or invalid.
+func intParam(r *http.Request, name string, def int) int {
+	n, err := strconv.Atoi(r.FormValue(name))
+	if err != nil || n < 1 {
+		return def
+	}
+	return n
+}
@@ -0,0 +1,82 @@
+// Package cancel serves cancellation of running deployments.
+package cancel
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/gengo/goship/lib/acl"
+	"github.com/gengo/goship/lib/auth"
+	"github.com/gengo/goship/lib/config"
+	"github.com/gengo/goship/lib/deploy"
+	"github.com/golang/glog"
+)
+
+type handler struct {
+	ac      acl.AccessControl
+	ecl     config.ETCDInterface
+	running *deploy.Running
+}
+
+// New returns a new http.Handler which cancels a deployment in "running".
+// Only deployers of the environment can cancel its deployments.
+//
+// e.g. POST http://127.0.0.1:8000/deploys/1/cancel
+func New(ac acl.AccessControl, ecl config.ETCDInterface, running *deploy.Running) http.Handler {
+	return handler{ac: ac, ecl: ecl, running: running}
+}
+
+func (h handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	components := strings.Split(r.URL.Path, "/")
+	if len(components) != 4 || components[0] != "" || components[1] != "deploys" || components[3] != "cancel" {
+		http.NotFound(w, r)
+		return
+	}
+	if r.Method != "POST" {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	id := components[2]
+
+	u, err := auth.CurrentUser(r)
+	if err != nil {
+		glog.Errorf("Failed to get current user: %v", err)
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return
+	}
+	run, err := h.running.Get(id)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	c, err := config.Load(h.ecl)
+	if err != nil {
+		glog.Errorf("Failed to load configuration: %v", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	proj, err := config.ProjectFromName(c.Projects, run.Project)
+	if err != nil {
+		glog.Errorf("Failed to find project %s: %v", run.Project, err)
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	env, err := config.EnvironmentFromName(c.Projects, run.Project, run.Environment)
+	if err != nil {
+		glog.Errorf("Failed to find environment %s of %s: %v", run.Environment, run.Project, err)
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	if !acl.Permitted(h.ac, c, proj, *env, u, config.RoleDeployer) {
+		glog.Errorf("%s is not allowed to cancel deployments of %s-%s", u.Name, run.Project, run.Environment)
+		http.Error(w, "not allowed to cancel deployments of the environment", http.StatusForbidden)
+		return
+	}
+
+	if err := h.running.Cancel(id, u.Name); err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	glog.Infof("Deployment %s of %s-%s canceled by %s", id, run.Project, run.Environment, u.Name)
+	w.WriteHeader(http.StatusAccepted)
+}
@@ -0,0 +1,114 @@
+// Package changelog serves what deployments would ship: the commits, pull requests and changed files between two revisions.
+package changelog
+
+import (
+	"html/template"
+	"net/http"
+
+	"github.com/gengo/goship/lib/acl"
+	"github.com/gengo/goship/lib/auth"
+	"github.com/gengo/goship/lib/config"
+	"github.com/gengo/goship/lib/revision"
+	"github.com/gengo/goship/lib/scm"
+	helpers "github.com/gengo/goship/lib/view-helpers"
+	"github.com/golang/glog"
+	"golang.org/x/net/context"
+)
+
+type handler struct {
+	ac      acl.AccessControl
+	ecl     config.ETCDInterface
+	clients map[config.SCMType]scm.Client
+	// assets renders the changelog as an HTML page if not nil.
+	assets *helpers.Assets
+}
+
+// New returns a new http.Handler which responds with scm.Changelog in JSON.
+// "from" and "to" are revisions in the source repository of "project", e.g. the deployed one and the one to deploy.
+// Anyone who can read the project can see its changelogs.
+//
+// e.g. GET http://127.0.0.1:8000/api/changelog?project=admin&from=abc000&to=abc123
+func New(ac acl.AccessControl, ecl config.ETCDInterface, clients map[config.SCMType]scm.Client) http.Handler {
+	return handler{ac: ac, ecl: ecl, clients: clients}
+}
+
+// NewPage returns a new http.Handler which serves the changelog as an HTML page.
+// It accepts the same parameters as New, and optionally "environment" to show.
+//
+// e.g. http://127.0.0.1:8000/changelog?project=admin&environment=production&from=abc000&to=abc123
+func NewPage(ac acl.AccessControl, ecl config.ETCDInterface, clients map[config.SCMType]scm.Client, assets helpers.Assets) http.Handler {
+	return handler{ac: ac, ecl: ecl, clients: clients, assets: &assets}
+}
+
+func (h handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	u, err := auth.CurrentUser(r)
+	if err != nil {
+		glog.Errorf("Failed to get current user: %v", err)
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return
+	}
+	projName := r.FormValue("project")
+	from, to := revision.Revision(r.FormValue("from")), revision.Revision(r.FormValue("to"))
+	if projName == "" || from == "" || to == "" {
+		http.Error(w, "project, from and to must be specified", http.StatusBadRequest)
+		return
+	}
+	c, err := config.Load(h.ecl)
+	if err != nil {
+		glog.Errorf("Failed to load configuration: %v", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	proj, err := config.ProjectFromName(c.Projects, projName)
+	if err != nil {
+		http.Error(w, "no such project", http.StatusNotFound)
+		return
+	}
+	repo := proj.SourceRepo()
+	if !h.ac.Readable(repo.RepoOwner, repo.RepoName, u.Name) {
+		http.Error(w, "no such project", http.StatusNotFound)
+		return
+	}
+	sc, ok := h.clients[proj.SCM]
+	if !ok {
+		http.Error(w, "scm not configured for the project", http.StatusNotFound)
+		return
+	}
+
+	cl, err := scm.NewChangelog(context.Background(), sc, repo, from, to)
+	if err != nil {
+		glog.Errorf("Failed to compare %s...%s of %s: %v", from, to, projName, err)
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	if h.assets != nil {
+		h.render(w, u, proj, r.FormValue("environment"), cl)
+		return
+	}
+	helpers.RespondWithJSON(w, http.StatusOK, cl)
+}
+
+// render responds with the HTML page of "cl".
+func (h handler) render(w http.ResponseWriter, u auth.User, proj config.Project, env string, cl scm.Changelog) {
+	t, err := template.New("changelog.html").ParseFiles("templates/changelog.html", "templates/base.html")
+	if err != nil {
+		glog.Errorf("Failed to parse templates: %v", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	js, css := h.assets.Templates()
+	params := map[string]interface{}{
+		"Javascript":  js,
+		"Stylesheet":  css,
+		"User":        u,
+		"Page":        "changelog",
+		"Project":     proj.Name,
+		"Environment": env,
+		"Changelog":   cl,
+	}
+	helpers.RespondWithTemplate(w, "text/html", t, "base", params)
+}
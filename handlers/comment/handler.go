@@ -1,32 +1,220 @@
+// Package comment serves comments on environments and on deployments in their deploy logs.
 package comment
 
 import (
+	"errors"
+	"fmt"
+	"html/template"
 	"net/http"
+	"strings"
+	"time"
 
-	"github.com/coreos/go-etcd/etcd"
+	"github.com/gengo/goship/lib/acl"
+	"github.com/gengo/goship/lib/audit"
+	"github.com/gengo/goship/lib/auth"
 	"github.com/gengo/goship/lib/config"
+	"github.com/gengo/goship/lib/markdown"
+	helpers "github.com/gengo/goship/lib/view-helpers"
 	"github.com/golang/glog"
 )
 
+// DeployFinder returns true if the deploy log of "env" of "proj" has the deployment at "t".
+type DeployFinder func(proj, env string, t time.Time) (bool, error)
+
 // CommentHandler allows you to update a comment on an environment
 // i.e. http://127.0.0.1:8000/comment?environment=staging&project=admin&comment=DONOTDEPLOYPLEASE!
 type handler struct {
-	ecl *etcd.Client
+	ac    acl.AccessControl
+	ecl   config.ETCDInterface
+	found DeployFinder
 }
 
-func New(ecl *etcd.Client) http.Handler {
-	return handler{ecl: ecl}
+// New returns a new http.Handler which updates comments.
+// Only deployers of the environment can comment on it.
+// The comment replaces the note of the environment on the home page, and is added to the thread of the environment.
+// With "deploy", the time of a deployment in RFC 3339, it is added to the thread of the deployment instead.
+func New(ac acl.AccessControl, ecl config.ETCDInterface, found DeployFinder) http.Handler {
+	return handler{ac: ac, ecl: ecl, found: found}
 }
 
 func (h handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
-	p := r.FormValue("project")
-	env := r.FormValue("environment")
-	comment := r.FormValue("comment")
-	err := config.SetComment(h.ecl, p, env, comment)
+	u, proj, env, ok := h.environment(w, r, config.RoleDeployer)
+	if !ok {
+		return
+	}
+	deploy, err := parseDeploy(r)
 	if err != nil {
-		glog.Errorf("Failed to store comment for project=%s env=%s: %v", p, env, err)
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if _, status, err := h.add(u, proj, env, r.FormValue("comment"), deploy); err != nil {
+		http.Error(w, err.Error(), status)
+		return
+	}
+	if !deploy.IsZero() {
+		http.Redirect(w, r, fmt.Sprintf("/deployLog/%s-%s", proj.Name, env.Name), http.StatusSeeOther)
 		return
 	}
 	http.Redirect(w, r, "/", http.StatusSeeOther)
 }
+
+// Entry is a comment in a thread with its body rendered from Markdown.
+type Entry struct {
+	config.Comment
+	HTML template.HTML `json:"html"`
+}
+
+// Thread returns the comments in "comments" on the deployment at "deploy", or on the environment if "deploy" is zero, in order.
+func Thread(comments []config.Comment, deploy time.Time) []Entry {
+	thread := []Entry{}
+	for _, c := range comments {
+		if c.On(deploy) {
+			thread = append(thread, Entry{Comment: c, HTML: markdown.Render(c.Body)})
+		}
+	}
+	return thread
+}
+
+type threadHandler struct {
+	handler
+}
+
+// threadResponse is the thread of an environment or a deployment.
+type threadResponse struct {
+	Comments []Entry `json:"comments"`
+}
+
+// NewThread returns a new http.Handler which serves the thread of an environment, or of a deployment with "deploy", as JSON.
+// Comments in the thread have their Markdown bodies rendered in "html" too.
+// Users who can see the environment can read the thread, and its deployers can add a comment with POST, which returns the new comment.
+//
+// e.g. GET http://127.0.0.1:8000/api/comments?project=admin&environment=production&deploy=2015-11-10T23:00:00Z
+// POST http://127.0.0.1:8000/api/comments?project=admin&environment=production&deploy=2015-11-10T23:00:00Z&comment=Rolled+back+because+of+**OOM**
+func NewThread(ac acl.AccessControl, ecl config.ETCDInterface, found DeployFinder) http.Handler {
+	return threadHandler{handler{ac: ac, ecl: ecl, found: found}}
+}
+
+func (h threadHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	role := config.RoleViewer
+	switch r.Method {
+	case "GET":
+	case "POST":
+		role = config.RoleDeployer
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	u, proj, env, ok := h.environment(w, r, role)
+	if !ok {
+		return
+	}
+	deploy, err := parseDeploy(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if r.Method == "POST" {
+		c, status, err := h.add(u, proj, env, r.FormValue("comment"), deploy)
+		if err != nil {
+			http.Error(w, err.Error(), status)
+			return
+		}
+		helpers.RespondWithJSON(w, http.StatusCreated, Entry{Comment: c, HTML: markdown.Render(c.Body)})
+		return
+	}
+	comments, err := config.LoadComments(h.ecl, proj.Name, env.Name)
+	if err != nil {
+		glog.Errorf("Failed to load comments of %s-%s: %v", proj.Name, env.Name, err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	helpers.RespondWithJSON(w, http.StatusOK, threadResponse{Comments: Thread(comments, deploy)})
+}
+
+// environment returns the current user and the environment requested by "r" if the user has "role" in the environment.
+// It responds with an error otherwise.
+func (h handler) environment(w http.ResponseWriter, r *http.Request, role config.Role) (auth.User, config.Project, config.Environment, bool) {
+	u, err := auth.CurrentUser(r)
+	if err != nil {
+		glog.Errorf("Failed to get current user: %v", err)
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return auth.User{}, config.Project{}, config.Environment{}, false
+	}
+	c, err := config.Load(h.ecl)
+	if err != nil {
+		glog.Errorf("Failed to load configuration: %v", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return auth.User{}, config.Project{}, config.Environment{}, false
+	}
+	p, envName := r.FormValue("project"), r.FormValue("environment")
+	proj, err := config.ProjectFromName(c.Projects, p)
+	if err != nil {
+		http.Error(w, "no such project", http.StatusNotFound)
+		return auth.User{}, config.Project{}, config.Environment{}, false
+	}
+	e, err := config.EnvironmentFromName(c.Projects, p, envName)
+	if err != nil {
+		http.Error(w, "no such project/environment", http.StatusNotFound)
+		return auth.User{}, config.Project{}, config.Environment{}, false
+	}
+	if !acl.Permitted(h.ac, c, proj, *e, u, role) {
+		http.Error(w, fmt.Sprintf("%s role is required", role), http.StatusForbidden)
+		return auth.User{}, config.Project{}, config.Environment{}, false
+	}
+	return u, proj, *e, true
+}
+
+// parseDeploy returns the time of the deployment given in "r", or zero if not given.
+func parseDeploy(r *http.Request) (time.Time, error) {
+	s := r.FormValue("deploy")
+	if s == "" {
+		return time.Time{}, nil
+	}
+	t, err := time.Parse(time.RFC3339Nano, s)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid deploy %q", s)
+	}
+	return t, nil
+}
+
+var errNoSuchDeploy = errors.New("no such deployment in the deploy log")
+
+// add adds "body" by "u" to the thread of "env", or of its deployment at "deploy" if not zero, and records it in the audit log.
+// It returns the HTTP status which describes the error on failure.
+func (h handler) add(u auth.User, proj config.Project, env config.Environment, body string, deploy time.Time) (config.Comment, int, error) {
+	if !deploy.IsZero() && strings.TrimSpace(body) == "" {
+		return config.Comment{}, http.StatusBadRequest, errors.New("comment not specified")
+	}
+	c := config.Comment{User: u.Name, Body: body}
+	rec := audit.Record{Actor: u.Name, Action: audit.ActionComment, Project: proj.Name, Environment: env.Name, Result: audit.ResultSuccess, Detail: body}
+	err := func() error {
+		if deploy.IsZero() {
+			// the latest comment on the environment is its note on the home page
+			return config.SetComment(h.ecl, proj.Name, env.Name, body)
+		}
+		rec.Detail = fmt.Sprintf("deployment at %s: %s", deploy.Format(time.RFC3339), body)
+		c.Deploy = &deploy
+		found, err := h.found(proj.Name, env.Name, deploy)
+		if err == nil && !found {
+			err = errNoSuchDeploy
+		}
+		return err
+	}()
+	if err == nil {
+		c, err = config.AddComment(h.ecl, proj.Name, env.Name, c)
+	}
+	if err != nil {
+		rec.Result = audit.ResultFailure
+	}
+	if err := audit.Append(h.ecl, rec); err != nil {
+		glog.Errorf("Failed to record comment for project=%s env=%s: %v", proj.Name, env.Name, err)
+	}
+	if err == errNoSuchDeploy {
+		return config.Comment{}, http.StatusNotFound, err
+	}
+	if err != nil {
+		glog.Errorf("Failed to store comment for project=%s env=%s: %v", proj.Name, env.Name, err)
+		return config.Comment{}, http.StatusInternalServerError, err
+	}
+	return c, 0, nil
+}
@@ -1,22 +1,24 @@
 package commits
 
 import (
-	"encoding/json"
 	"errors"
 	"fmt"
 	"net/http"
 	"strings"
 	"sync"
+	"time"
 
-	"github.com/coreos/go-etcd/etcd"
 	docker "github.com/fsouza/go-dockerclient"
 	"github.com/gengo/goship/lib/acl"
 	"github.com/gengo/goship/lib/auth"
 	"github.com/gengo/goship/lib/config"
-	githublib "github.com/gengo/goship/lib/github"
+	"github.com/gengo/goship/lib/inventory"
+	"github.com/gengo/goship/lib/revision"
 	gcrrev "github.com/gengo/goship/lib/revision/gcr"
-	githubrev "github.com/gengo/goship/lib/revision/github"
+	scmrev "github.com/gengo/goship/lib/revision/scm"
+	"github.com/gengo/goship/lib/scm"
 	"github.com/gengo/goship/lib/ssh"
+	helpers "github.com/gengo/goship/lib/view-helpers"
 	"github.com/golang/glog"
 	"golang.org/x/net/context"
 )
@@ -25,17 +27,48 @@ var (
 	projectUnaccessible = errors.New("permission denied")
 )
 
+// ControlFactory returns a revision.Control which is suitable for "proj".
+// "sshCfg" configures connections to the deploy target hosts.
+type ControlFactory func(proj config.Project, sshCfg config.SSH) (revision.Control, error)
+
+// NewControlFactory returns a ControlFactory which accesses to source code management services, docker registries and deploy target hosts.
+// "scms" maps types of source code management services to their clients.
+// "sshKeyPath" is the private key used for hosts whose SSH configuration has no key file.
+func NewControlFactory(scms map[config.SCMType]scm.Client, dcl *docker.Client, sshKeyPath string) ControlFactory {
+	return func(proj config.Project, sshCfg config.SSH) (revision.Control, error) {
+		sc, ok := scms[proj.SCM]
+		if !ok {
+			return nil, fmt.Errorf("scm %q not configured", proj.SCM)
+		}
+		if sshCfg.KeyFile == "" {
+			sshCfg.KeyFile = sshKeyPath
+		}
+		s, err := ssh.New(sshCfg)
+		if err != nil {
+			return nil, err
+		}
+
+		c := scmrev.New(sc, s)
+		switch t := proj.RepoType; t {
+		case config.RepoTypeGithub:
+		case config.RepoTypeDocker:
+			c = gcrrev.New(c, dcl, s)
+		default:
+			return nil, fmt.Errorf("unknown repository type %q", t)
+		}
+		return c, nil
+	}
+}
+
 type handler struct {
 	ac         acl.AccessControl
-	ecl        *etcd.Client
-	gcl        githublib.Client
-	dcl        *docker.Client
-	sshKeyPath string
+	ecl        config.ETCDInterface
+	newControl ControlFactory
 }
 
 // New returns a new http.Handler which serves latest revisions in deploy targets and the revision control system.
-func New(ac acl.AccessControl, ecl *etcd.Client, gcl githublib.Client, dcl *docker.Client, sshKeyPath string) http.Handler {
-	return handler{ac: ac, ecl: ecl, gcl: gcl, dcl: dcl, sshKeyPath: sshKeyPath}
+func New(ac acl.AccessControl, ecl config.ETCDInterface, newControl ControlFactory) http.Handler {
+	return handler{ac: ac, ecl: ecl, newControl: newControl}
 }
 
 func (h handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
@@ -67,26 +100,15 @@ func (h handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	buf, err := json.Marshal(envs)
-	if err != nil {
-		glog.Errorf("Failed to marshal response: %v", err)
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
-	}
-	w.Header().Set("Content-Type", "application/json")
-	if _, err := w.Write(buf); err != nil {
-		glog.Errorf("Failed to send response: %v", err)
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
-	}
+	helpers.RespondWithJSON(w, http.StatusOK, envs)
 }
 
 func (h handler) fetchStatuses(ctx context.Context, projName string, u auth.User) ([]environment, error) {
-	p, deployUser, err := h.loadProject(projName, u)
+	p, cfg, err := h.loadProject(projName, u)
 	if err != nil {
 		return nil, err
 	}
-	envs, err := h.retrieveCommits(ctx, p, deployUser)
+	envs, err := h.retrieveCommits(ctx, p, cfg)
 	if err != nil {
 		glog.Errorf("Failed to retrieve commits: %v", err)
 		return nil, err
@@ -99,11 +121,34 @@ func (h handler) fetchStatuses(ctx context.Context, projName string, u auth.User
 			if c := env.Comment; c != "" {
 				comments = append(comments, c)
 			}
+			if pin := env.pin; pin != nil {
+				c := fmt.Sprintf("pinned to %s by %s", revision.Revision(pin.Revision).Short(), pin.User)
+				if pin.Reason != "" {
+					c += ": " + pin.Reason
+				}
+				comments = append(comments, c)
+			}
+			now := time.Now()
+			if f := config.ActiveFreeze(cfg, env.Name, now); f != nil {
+				c := fmt.Sprintf("frozen until %s", f.Until(now).Format("Mon 15:04"))
+				if f.Reason != "" {
+					c += ": " + f.Reason
+				}
+				comments = append(comments, c)
+			}
+			comments = append(comments, h.scheduledDeploys(p.Name, env.Name)...)
+			comments = append(comments, h.reservations(p.Name, env.Name)...)
+			if l := env.Lock; l != nil {
+				c := fmt.Sprintf("locked by %s", l.User)
+				if !l.Expires.IsZero() {
+					c += " until " + l.Expires.Local().Format("Jan 2 15:04")
+				}
+				return true, append(comments, c+": "+l.Reason)
+			}
 			if env.Locked {
 				return true, append(comments, "repo is locked.")
 			}
-			repo := p.SourceRepo()
-			if !h.ac.Deployable(repo.RepoOwner, repo.RepoName, u.Name) {
+			if !acl.Permitted(h.ac, cfg, p, p.Environments[i], u, config.RoleDeployer) {
 				return true, append(comments, "you do not have permission to deploy")
 			}
 			return false, comments
@@ -115,43 +160,79 @@ func (h handler) fetchStatuses(ctx context.Context, projName string, u auth.User
 	return envs, nil
 }
 
-func (h handler) loadProject(projName string, u auth.User) (p config.Project, deployUser string, err error) {
-	c, err := config.Load(h.ecl)
+func (h handler) loadProject(projName string, u auth.User) (p config.Project, c config.Config, err error) {
+	c, err = config.Load(h.ecl)
 	if err != nil {
 		glog.Errorf("Parsing etc: %v", err)
-		return config.Project{}, "", err
+		return config.Project{}, config.Config{}, err
 	}
 	p, err = config.ProjectFromName(c.Projects, projName)
 	if err != nil {
 		glog.Errorf("Failed to get project from name: %v", err)
-		return config.Project{}, "", err
+		return config.Project{}, config.Config{}, err
 	}
 	repo := p.SourceRepo()
 	if !h.ac.Readable(repo.RepoOwner, repo.RepoName, u.Name) {
-		return config.Project{}, "", projectUnaccessible
+		return config.Project{}, config.Config{}, projectUnaccessible
 	}
-	return p, c.DeployUser, nil
+	return p, c, nil
 
 }
 
-func (h handler) retrieveCommits(ctx context.Context, proj config.Project, deployUser string) ([]environment, error) {
-	s, err := ssh.WithPrivateKeyFile(deployUser, h.sshKeyPath)
+// reservations describes the current and upcoming reservations of "env" in "proj".
+func (h handler) reservations(proj, env string) []string {
+	reservations, err := config.LoadReservations(h.ecl, proj, env)
 	if err != nil {
-		return nil, err
+		glog.Errorf("Failed to load reservations of %s-%s: %v", proj, env, err)
+		return nil
+	}
+	var descs []string
+	for _, r := range reservations {
+		desc := fmt.Sprintf("reserved by %s for %s", r.User, r.Window())
+		if r.Reason != "" {
+			desc += ": " + r.Reason
+		}
+		descs = append(descs, desc)
 	}
+	return descs
+}
+
+// scheduledDeploys describes the upcoming scheduled deploys in "env" of "proj".
+func (h handler) scheduledDeploys(proj, env string) []string {
+	schedules, err := config.LoadSchedules(h.ecl, proj, env)
+	if err != nil {
+		glog.Errorf("Failed to load schedules of %s-%s: %v", proj, env, err)
+		return nil
+	}
+	var descs []string
+	for _, s := range schedules {
+		if s.Action != config.ScheduleDeploy {
+			continue
+		}
+		descs = append(descs, fmt.Sprintf("deploy of %s scheduled at %s by %s", revision.Revision(s.Revision).Short(), s.At.Local().Format("Jan 2 15:04"), s.User))
+	}
+	return descs
+}
 
-	c := githubrev.New(h.gcl, s)
-	switch t := proj.RepoType; t {
-	case config.RepoTypeGithub:
-	case config.RepoTypeDocker:
-		c = gcrrev.New(c, h.dcl, s)
-	default:
-		return nil, fmt.Errorf("unknown repository type %q", t)
+func (h handler) retrieveCommits(ctx context.Context, proj config.Project, cfg config.Config) ([]environment, error) {
+	// each environment may connect to its hosts differently
+	controls := make([]revision.Control, len(proj.Environments))
+	for i, e := range proj.Environments {
+		c, err := h.newControl(proj, cfg.SSHConfig(proj, e))
+		if err != nil {
+			return nil, err
+		}
+		controls[i] = c
 	}
 
 	var wg sync.WaitGroup
 	envs := make([]environment, len(proj.Environments))
 	for i, e := range proj.Environments {
+		c := controls[i]
+		e, err := inventory.Cached(ctx, e)
+		if err != nil {
+			glog.Errorf("Failed to resolve hosts of %s-%s: %v", proj.Name, e.Name, err)
+		}
 		envs[i] = environment{
 			Name:        e.Name,
 			Locked:      e.IsLocked,
@@ -191,6 +272,31 @@ func (h handler) retrieveCommits(ctx context.Context, proj config.Project, deplo
 
 	for i := range envs {
 		env := &envs[i]
+		lock, err := config.LoadLock(h.ecl, proj.Name, env.Name)
+		if err != nil {
+			glog.Errorf("Failed to load lock of %s-%s: %v", proj.Name, env.Name, err)
+		}
+		env.Lock = lock
+
+		pin, err := config.LoadPin(h.ecl, proj.Name, env.Name)
+		if err != nil {
+			glog.Errorf("Failed to load pin of %s-%s: %v", proj.Name, env.Name, err)
+			continue
+		}
+		if pin == nil {
+			continue
+		}
+		// only the pinned revision is deployable
+		env.pin, env.Pinned = pin, true
+		env.Revision = revision.Revision(pin.Revision)
+		env.ShortRevision = env.Revision.Short()
+		if proj.Source == nil {
+			env.SourceCodeRevision = env.Revision
+		}
+	}
+
+	for i := range envs {
+		env, c := &envs[i], controls[i]
 		for j := range env.Deployments {
 			d := &env.Deployments[j]
 			d.SourceCodeDiffURL = c.SourceDiffURL(proj, d.SourceCodeRevision, env.SourceCodeRevision)
@@ -1,6 +1,7 @@
 package commits
 
 import (
+	"github.com/gengo/goship/lib/config"
 	"github.com/gengo/goship/lib/revision"
 )
 
@@ -12,6 +13,11 @@ type environment struct {
 	Comment string `json:"comment"`
 	// Locked is true iff the project is not ready for deployment.
 	Locked bool `json:"isLocked"`
+	// Lock is the lock of the environment if it is locked by a user.
+	Lock *config.Lock `json:"lock,omitempty"`
+	// Pinned is true iff the environment is pinned to the latest deployable revision.
+	Pinned bool `json:"isPinned"`
+	pin    *config.Pin
 	// Deployments are per-host status of deployments
 	Deployments []deployStatus `json:"deployments"`
 }
@@ -0,0 +1,92 @@
+// Package deactivation serves the admin API which deactivates and reactivates users.
+package deactivation
+
+import (
+	"net/http"
+
+	"github.com/gengo/goship/lib/auth"
+	"github.com/gengo/goship/lib/config"
+	"github.com/gengo/goship/lib/users"
+	helpers "github.com/gengo/goship/lib/view-helpers"
+	"github.com/golang/glog"
+)
+
+type handler struct {
+	ecl      config.ETCDInterface
+	activate bool
+}
+
+// NewDeactivate returns a new http.Handler which deactivates a user.
+// Only admins can deactivate users, with POST and a reason.
+// The schedules of the user are reassigned to "reassign_to", or to the admin if not given.
+// It responds with the result of offboarding as JSON.
+//
+// e.g. POST http://127.0.0.1:8000/api/users/deactivate?user=alice&reason=left&reassign_to=bob
+func NewDeactivate(ecl config.ETCDInterface) http.Handler {
+	return handler{ecl: ecl}
+}
+
+// NewReactivate returns a new http.Handler which reactivates a deactivated user.
+// Only admins can reactivate users, with POST. The user needs to log in again.
+//
+// e.g. POST http://127.0.0.1:8000/api/users/reactivate?user=alice
+func NewReactivate(ecl config.ETCDInterface) http.Handler {
+	return handler{ecl: ecl, activate: true}
+}
+
+func (h handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	u, err := auth.CurrentUser(r)
+	if err != nil {
+		glog.Errorf("Failed to get current user: %v", err)
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return
+	}
+	c, err := config.Load(h.ecl)
+	if err != nil {
+		glog.Errorf("Failed to load configuration: %v", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if !c.IsAdmin(u.Name) {
+		glog.Errorf("%s is not allowed to change activation of users", u.Name)
+		http.Error(w, "only admins can deactivate or reactivate users", http.StatusForbidden)
+		return
+	}
+	user := r.FormValue("user")
+	if user == "" {
+		http.Error(w, "user not specified", http.StatusBadRequest)
+		return
+	}
+
+	var resp interface{}
+	if h.activate {
+		resp, err = users.Reactivate(h.ecl, user, u.Name)
+	} else {
+		reason := r.FormValue("reason")
+		if reason == "" {
+			http.Error(w, "reason not specified", http.StatusBadRequest)
+			return
+		}
+		if user == u.Name {
+			http.Error(w, "cannot deactivate yourself", http.StatusBadRequest)
+			return
+		}
+		resp, err = users.Deactivate(h.ecl, c, user, u.Name, reason, r.FormValue("reassign_to"))
+	}
+	switch {
+	case err == users.ErrDeactivated || err == users.ErrActive:
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	case err != nil:
+		glog.Errorf("Failed to change activation of %s: %v", user, err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	glog.Infof("%s changed activation of %s: %+v", u.Name, user, resp)
+
+	helpers.RespondWithJSON(w, http.StatusOK, resp)
+}
@@ -12,7 +12,7 @@ import (
 )
 
 // New return an http handler which renders deploy page.
-// "pushAddr" is an absolute URL to the websocket endpoint of push notification.
+// "pushAddr" is an absolute URL to the websocket endpoint of push notification, either "ws://" or "wss://".
 //
 // pushAddr is defined for backward compatibility
 // TODO(yugui) is it really a right way to solve that?  Is it safe for reverse-proxy or some bind addresses?
@@ -24,7 +24,7 @@ func New(assets helpers.Assets, pushAddr string) (http.Handler, error) {
 	if !addr.IsAbs() {
 		return nil, fmt.Errorf("not an absolute URL: %s", pushAddr)
 	}
-	if addr.Scheme != "ws" {
+	if addr.Scheme != "ws" && addr.Scheme != "wss" {
 		return nil, fmt.Errorf("not a websocket URL: %s", pushAddr)
 	}
 	return deployPage{assets: assets, pushAddr: addr}, nil
@@ -46,9 +46,14 @@ func (h deployPage) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	env := r.FormValue("environment")
 	fromRevision := r.FormValue("from_revision")
 	toRevision := r.FormValue("to_revision")
+	fromSourceRevision := r.FormValue("from_source_revision")
+	toSourceRevision := r.FormValue("to_source_revision")
 	repoOwner := r.FormValue("repo_owner")
 	repoName := r.FormValue("repo_name")
 	timestamp := r.FormValue("timestamp")
+	labels := r.FormValue("labels")
+	// promote deploys the revision running in the previous stage of the pipeline instead of the given revisions
+	promote := r.FormValue("promote") == "true"
 	t, err := template.New("deploy.html").ParseFiles("templates/deploy.html", "templates/base.html")
 	if err != nil {
 		glog.Errorf("Failed to parse templates: %v", err)
@@ -58,17 +63,21 @@ func (h deployPage) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	js, css := h.assets.Templates()
 
 	params := map[string]interface{}{
-		"Javascript":   js,
-		"Stylesheet":   css,
-		"Project":      p,
-		"Env":          env,
-		"User":         user,
-		"PushAddress":  h.pushAddr,
-		"RepoOwner":    repoOwner,
-		"RepoName":     repoName,
-		"ToRevision":   toRevision,
-		"FromRevision": fromRevision,
-		"Timestamp":    timestamp,
+		"Javascript":         js,
+		"Stylesheet":         css,
+		"Project":            p,
+		"Env":                env,
+		"User":               user,
+		"PushAddress":        h.pushAddr,
+		"RepoOwner":          repoOwner,
+		"RepoName":           repoName,
+		"ToRevision":         toRevision,
+		"FromRevision":       fromRevision,
+		"ToSourceRevision":   toSourceRevision,
+		"FromSourceRevision": fromSourceRevision,
+		"Timestamp":          timestamp,
+		"Labels":             labels,
+		"Promote":            promote,
 	}
 	helpers.RespondWithTemplate(w, "text/html", t, "base", params)
 }
@@ -0,0 +1,111 @@
+// Package handoff serves handoff of running deployments to another owner.
+package handoff
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/gengo/goship/lib/acl"
+	"github.com/gengo/goship/lib/auth"
+	"github.com/gengo/goship/lib/config"
+	"github.com/gengo/goship/lib/deploy"
+	"github.com/gengo/goship/lib/notification"
+	"github.com/golang/glog"
+	"golang.org/x/net/context"
+)
+
+type handler struct {
+	ac        acl.AccessControl
+	ecl       config.ETCDInterface
+	running   *deploy.Running
+	handedOff func(deploy.Run)
+}
+
+// New returns a new http.Handler which hands off a deployment in "running" to the user given as "to".
+// Both the current user and the new owner must be deployers of the environment.
+// "handedOff" is called with the deployment after the handoff.
+//
+// e.g. POST http://127.0.0.1:8000/deploys/1/handoff?to=bob&reason=shift+change
+func New(ac acl.AccessControl, ecl config.ETCDInterface, running *deploy.Running, handedOff func(deploy.Run)) http.Handler {
+	return handler{ac: ac, ecl: ecl, running: running, handedOff: handedOff}
+}
+
+func (h handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	components := strings.Split(r.URL.Path, "/")
+	if len(components) != 4 || components[0] != "" || components[1] != "deploys" || components[3] != "handoff" {
+		http.NotFound(w, r)
+		return
+	}
+	if r.Method != "POST" {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	id, to := components[2], r.FormValue("to")
+	if to == "" {
+		http.Error(w, "to not specified", http.StatusBadRequest)
+		return
+	}
+
+	u, err := auth.CurrentUser(r)
+	if err != nil {
+		glog.Errorf("Failed to get current user: %v", err)
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return
+	}
+	run, err := h.running.Get(id)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	c, err := config.Load(h.ecl)
+	if err != nil {
+		glog.Errorf("Failed to load configuration: %v", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	proj, err := config.ProjectFromName(c.Projects, run.Project)
+	if err != nil {
+		glog.Errorf("Failed to find project %s: %v", run.Project, err)
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	env, err := config.EnvironmentFromName(c.Projects, run.Project, run.Environment)
+	if err != nil {
+		glog.Errorf("Failed to find environment %s of %s: %v", run.Environment, run.Project, err)
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	if !acl.Permitted(h.ac, c, proj, *env, u, config.RoleDeployer) {
+		glog.Errorf("%s is not allowed to hand off deployments of %s-%s", u.Name, run.Project, run.Environment)
+		http.Error(w, "not allowed to hand off deployments of the environment", http.StatusForbidden)
+		return
+	}
+	groups, err := config.LoadUserGroups(h.ecl, to)
+	if err != nil {
+		glog.Errorf("Failed to load groups of %s: %v", to, err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if !acl.Permitted(h.ac, c, proj, *env, auth.User{Name: to, Groups: groups}, config.RoleDeployer) {
+		http.Error(w, to+" is not allowed to deploy the environment", http.StatusBadRequest)
+		return
+	}
+
+	prev, err := h.running.Handoff(id, to)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	glog.Infof("Deployment %s of %s-%s handed off from %s to %s by %s", id, run.Project, run.Environment, prev.Owner, to, u.Name)
+	run.Owner = to
+	h.handedOff(run)
+	notification.NotifyAll(context.Background(), proj, notification.Event{
+		Type:        notification.DeployHandedOff,
+		Project:     run.Project,
+		Environment: run.Environment,
+		User:        u.Name,
+		Owner:       to,
+		Reason:      r.FormValue("reason"),
+	})
+	w.WriteHeader(http.StatusAccepted)
+}
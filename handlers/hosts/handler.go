@@ -0,0 +1,157 @@
+// Package hosts serves the admin API which adds hosts to environments in bulk.
+package hosts
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gengo/goship/lib/audit"
+	"github.com/gengo/goship/lib/auth"
+	"github.com/gengo/goship/lib/config"
+	"github.com/gengo/goship/lib/hostimport"
+	helpers "github.com/gengo/goship/lib/view-helpers"
+	"github.com/golang/glog"
+)
+
+// maxImportSize is the maximum size of a CSV file or an inventory in a request.
+const maxImportSize = 1 << 20
+
+type handler struct {
+	ecl          config.EditableStore
+	checkTimeout time.Duration
+}
+
+// response is the result of an import.
+type response struct {
+	Hosts []hostimport.Result `json:"hosts"`
+	// Added is the number of hosts added, or to be added in a dry run.
+	Added  int  `json:"added"`
+	DryRun bool `json:"dry_run"`
+}
+
+// NewImport returns a new http.Handler which adds hosts in a CSV file or an Ansible inventory in the request body to environments.
+// Only admins can import hosts, with POST.
+// "format" is "csv" (default) or "ansible". CSV has the columns "project", "environment", "host" and optionally "group".
+// Hosts in an Ansible inventory are added to "environment" of "project", and to its host group "group" if given.
+// "ansible_group" narrows them down to a group of the inventory.
+//
+// Duplicated hosts, hosts of unknown environments and hosts whose SSH ports do not accept connections within "checkTimeout" are skipped.
+// "check=false" skips the reachability check, and "dry_run=true" only previews the result without changing the configuration.
+//
+// e.g. POST http://127.0.0.1:8000/api/hosts/import?dry_run=true
+// POST http://127.0.0.1:8000/api/hosts/import?format=ansible&project=admin&environment=production&ansible_group=web
+func NewImport(ecl config.EditableStore, checkTimeout time.Duration) http.Handler {
+	return handler{ecl: ecl, checkTimeout: checkTimeout}
+}
+
+func (h handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	u, err := auth.CurrentUser(r)
+	if err != nil {
+		glog.Errorf("Failed to get current user: %v", err)
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return
+	}
+	c, err := config.Load(h.ecl)
+	if err != nil {
+		glog.Errorf("Failed to load configuration: %v", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if !c.IsAdmin(u.Name) {
+		glog.Errorf("%s is not allowed to import hosts", u.Name)
+		http.Error(w, "only admins can import hosts", http.StatusForbidden)
+		return
+	}
+
+	buf, err := ioutil.ReadAll(http.MaxBytesReader(w, r.Body, maxImportSize))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	hosts, err := parse(r, bytes.NewReader(buf))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	results := hostimport.Validate(c, hosts)
+	if r.FormValue("check") != "false" {
+		hostimport.Check(results, h.checkTimeout)
+	}
+	resp := response{Hosts: results, DryRun: r.FormValue("dry_run") == "true"}
+	for _, res := range results {
+		if res.Status == hostimport.StatusAdded {
+			resp.Added++
+		}
+	}
+	if resp.DryRun {
+		helpers.RespondWithJSON(w, http.StatusOK, resp)
+		return
+	}
+
+	for _, p := range hostimport.Apply(c, results) {
+		err := config.StoreProject(h.ecl, p)
+		record(h.ecl, u.Name, p.Name, results, err)
+		if err != nil {
+			glog.Errorf("Failed to store project %s with imported hosts: %v", p.Name, err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+	helpers.RespondWithJSON(w, http.StatusOK, resp)
+}
+
+// parse reads the hosts to import from "body" in the format requested by "r".
+func parse(r *http.Request, body io.Reader) ([]hostimport.Host, error) {
+	switch format := r.FormValue("format"); format {
+	case "", "csv":
+		return hostimport.ParseCSV(body)
+	case "ansible":
+		proj, env := r.FormValue("project"), r.FormValue("environment")
+		if proj == "" || env == "" {
+			return nil, fmt.Errorf("project and environment must be given to import an Ansible inventory")
+		}
+		names, err := hostimport.ParseAnsible(body, r.FormValue("ansible_group"))
+		if err != nil {
+			return nil, fmt.Errorf("invalid inventory: %v", err)
+		}
+		var hosts []hostimport.Host
+		for _, name := range names {
+			hosts = append(hosts, hostimport.Host{Project: proj, Environment: env, Host: name, Group: r.FormValue("group")})
+		}
+		return hosts, nil
+	default:
+		return nil, fmt.Errorf("unknown format %q", format)
+	}
+}
+
+// record adds the import of hosts into "proj" to the audit log.
+func record(ecl config.ETCDInterface, user, proj string, results []hostimport.Result, err error) {
+	added := make(map[string]int)
+	for _, r := range results {
+		if r.Project == proj && r.Status == hostimport.StatusAdded {
+			added[r.Environment]++
+		}
+	}
+	var details []string
+	for env, n := range added {
+		details = append(details, fmt.Sprintf("%d host(s) to %s", n, env))
+	}
+	sort.Strings(details)
+	rec := audit.Record{Actor: user, Action: audit.ActionHostsImported, Project: proj, Result: audit.ResultSuccess, Detail: "added " + strings.Join(details, ", ")}
+	if err != nil {
+		rec.Result, rec.Detail = audit.ResultFailure, err.Error()
+	}
+	if err := audit.Append(ecl, rec); err != nil {
+		glog.Errorf("Failed to record import of hosts into %s in the audit log: %v", proj, err)
+	}
+}
@@ -0,0 +1,164 @@
+// Package keys serves the browser of the raw keys of Goship in etcd for admins.
+package keys
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"sort"
+
+	"github.com/gengo/goship/lib/audit"
+	"github.com/gengo/goship/lib/auth"
+	"github.com/gengo/goship/lib/config"
+	helpers "github.com/gengo/goship/lib/view-helpers"
+	"github.com/golang/glog"
+)
+
+// maxValueSize is the maximum size of a value in a request.
+const maxValueSize = 1 << 20
+
+// Node is a key in etcd with its value, or its children if it is a directory.
+type Node struct {
+	Key      string   `json:"key"`
+	Dir      bool     `json:"dir"`
+	Value    string   `json:"value,omitempty"`
+	Children []string `json:"children,omitempty"`
+}
+
+type handler struct {
+	ecl config.EditableStore
+}
+
+// New returns a new http.Handler which reads and edits the raw keys under /goship in etcd as JSON.
+// Only admins can use it, and keys outside /goship are refused.
+// "key" and "value" must be given in the URL.
+// PUT stores "value", or the request body if "value" is not given, after checking it with config.ValidateKeyChange.
+// DELETE deletes the key, and its children too with "recursive=true".
+// Changes are recorded in the audit log without their values, which may contain secrets.
+//
+// e.g. GET http://127.0.0.1:8000/api/keys?key=/goship/projects/admin
+// PUT http://127.0.0.1:8000/api/keys?key=/goship/comments/admin/staging&value=DONOTDEPLOY
+// DELETE http://127.0.0.1:8000/api/keys?key=/goship/locks/admin&recursive=true
+func New(ecl config.EditableStore) http.Handler {
+	return handler{ecl: ecl}
+}
+
+func (h handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	u, ok := authorize(w, r, h.ecl)
+	if !ok {
+		return
+	}
+	// the body of PUT is the value even if it looks like a form
+	key := config.CleanKey(r.URL.Query().Get("key"))
+	if key == "/" {
+		key = config.KeyPrefix
+	}
+	switch r.Method {
+	case "GET":
+		if err := config.ValidateKeyRead(key); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		n, err := get(h.ecl, key)
+		if config.IsNotFound(err) {
+			http.Error(w, "no such key", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			glog.Errorf("Failed to get %s: %v", key, err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		helpers.RespondWithJSON(w, http.StatusOK, n)
+	case "PUT", "POST":
+		h.put(w, r, u, key)
+	case "DELETE":
+		h.delete(w, r, u, key)
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// authorize returns the current user if the user is an admin.
+// Otherwise it responds with an error and returns false.
+func authorize(w http.ResponseWriter, r *http.Request, ecl config.ETCDInterface) (auth.User, bool) {
+	u, err := auth.CurrentUser(r)
+	if err != nil {
+		glog.Errorf("Failed to get current user: %v", err)
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return auth.User{}, false
+	}
+	c, err := config.Load(ecl)
+	if err != nil {
+		glog.Errorf("Failed to load configuration: %v", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return auth.User{}, false
+	}
+	if !c.IsAdmin(u.Name) {
+		glog.Errorf("%s is not allowed to browse keys", u.Name)
+		http.Error(w, "only admins can browse keys", http.StatusForbidden)
+		return auth.User{}, false
+	}
+	return u, true
+}
+
+// get returns the node of "key" with the names of its children in order.
+func get(ecl config.ETCDInterface, key string) (Node, error) {
+	resp, err := ecl.Get(key, true, false)
+	if err != nil {
+		return Node{}, err
+	}
+	n := Node{Key: key, Dir: resp.Node.Dir, Value: resp.Node.Value}
+	for _, child := range resp.Node.Nodes {
+		n.Children = append(n.Children, child.Key)
+	}
+	sort.Strings(n.Children)
+	return n, nil
+}
+
+func (h handler) put(w http.ResponseWriter, r *http.Request, u auth.User, key string) {
+	value := r.URL.Query().Get("value")
+	if _, ok := r.URL.Query()["value"]; !ok {
+		buf, err := ioutil.ReadAll(http.MaxBytesReader(w, r.Body, maxValueSize))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		value = string(buf)
+	}
+	if err := config.ValidateKeyChange(h.ecl, key, value, false); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	_, err := h.ecl.Set(key, value, 0)
+	audit.AppendChange(h.ecl, audit.ActionKeySet, u.Name, key, err)
+	if err != nil {
+		glog.Errorf("Failed to set %s: %v", key, err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	helpers.RespondWithJSON(w, http.StatusOK, Node{Key: key, Value: value})
+}
+
+func (h handler) delete(w http.ResponseWriter, r *http.Request, u auth.User, key string) {
+	if err := config.ValidateKeyChange(h.ecl, key, "", true); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	_, err := h.ecl.Delete(key, r.URL.Query().Get("recursive") == "true")
+	if config.IsNotFound(err) {
+		http.Error(w, "no such key", http.StatusNotFound)
+		return
+	}
+	if config.IsNotFile(err) {
+		http.Error(w, fmt.Sprintf("%s is a directory; delete it with recursive=true", key), http.StatusBadRequest)
+		return
+	}
+	audit.AppendChange(h.ecl, audit.ActionKeyDeleted, u.Name, key, err)
+	if err != nil {
+		glog.Errorf("Failed to delete %s: %v", key, err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
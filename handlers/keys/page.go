@@ -0,0 +1,72 @@
+package keys
+
+import (
+	"html/template"
+	"net/http"
+	"strings"
+
+	"github.com/gengo/goship/lib/config"
+	helpers "github.com/gengo/goship/lib/view-helpers"
+	"github.com/golang/glog"
+)
+
+type page struct {
+	ecl    config.ETCDInterface
+	assets helpers.Assets
+}
+
+// crumb is a link to an ancestor of the key in the page.
+type crumb struct {
+	Name, Key string
+}
+
+// NewPage returns a new http.Handler which serves the browser of the raw keys under /goship for admins.
+// The page edits keys through the API served by New.
+//
+// e.g. http://127.0.0.1:8000/admin/keys?key=/goship/projects/admin
+func NewPage(ecl config.ETCDInterface, assets helpers.Assets) http.Handler {
+	return page{ecl: ecl, assets: assets}
+}
+
+func (h page) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	u, ok := authorize(w, r, h.ecl)
+	if !ok {
+		return
+	}
+	key := config.CleanKey(r.FormValue("key"))
+	if !strings.HasPrefix(key, config.KeyPrefix) {
+		key = config.KeyPrefix
+	}
+	n, err := get(h.ecl, key)
+	if config.IsNotFound(err) {
+		// a new key to create
+		n, err = Node{Key: key}, nil
+	}
+	if err != nil {
+		glog.Errorf("Failed to get %s: %v", key, err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	var crumbs []crumb
+	parts := strings.Split(strings.TrimPrefix(key, "/"), "/")
+	for i := range parts {
+		crumbs = append(crumbs, crumb{Name: parts[i], Key: "/" + strings.Join(parts[:i+1], "/")})
+	}
+
+	t, err := template.New("keys.html").ParseFiles("templates/keys.html", "templates/base.html")
+	if err != nil {
+		glog.Errorf("Failed to parse templates: %v", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	js, css := h.assets.Templates()
+	params := map[string]interface{}{
+		"Javascript": js,
+		"Stylesheet": css,
+		"User":       u,
+		"Page":       "keys",
+		"Node":       n,
+		"Crumbs":     crumbs,
+	}
+	helpers.RespondWithTemplate(w, "text/html", t, "base", params)
+}
@@ -0,0 +1,152 @@
+// Package kiosk serves the management of kiosk tokens, which let wall displays show the dashboard without user sessions.
+package kiosk
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/gengo/goship/lib/audit"
+	"github.com/gengo/goship/lib/auth"
+	"github.com/gengo/goship/lib/config"
+	helpers "github.com/gengo/goship/lib/view-helpers"
+	"github.com/golang/glog"
+)
+
+// Token is a kiosk token without its hash.
+type Token struct {
+	Name     string    `json:"name"`
+	Projects []string  `json:"projects"`
+	User     string    `json:"user"`
+	Time     time.Time `json:"time"`
+}
+
+// Created is a new kiosk token with its secret.
+type Created struct {
+	Token
+	// Secret is the secret token, which cannot be shown again.
+	Secret string `json:"secret"`
+	// Path is the path which a wall display opens to start showing the dashboard with the token.
+	Path string `json:"path"`
+}
+
+type handler struct {
+	ecl config.EditableStore
+}
+
+// New returns a new http.Handler which manages kiosk tokens as JSON.
+// Only admins can use it.
+// POST creates a token named "name" for the projects in "projects", separated by commas, or for all the projects if empty.
+// The response has the secret token, which is shown only once.
+// DELETE revokes the token named "name", and displays with the token lose access immediately.
+// Creations and revocations are recorded in the audit log.
+//
+// e.g. GET http://127.0.0.1:8000/api/kiosk/tokens
+// POST http://127.0.0.1:8000/api/kiosk/tokens?name=office-wall&projects=admin,storefront
+// DELETE http://127.0.0.1:8000/api/kiosk/tokens?name=office-wall
+func New(ecl config.EditableStore) http.Handler {
+	return handler{ecl: ecl}
+}
+
+func (h handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	u, err := auth.CurrentUser(r)
+	if err != nil {
+		glog.Errorf("Failed to get current user: %v", err)
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return
+	}
+	c, err := config.Load(h.ecl)
+	if err != nil {
+		glog.Errorf("Failed to load configuration: %v", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if !c.IsAdmin(u.Name) {
+		http.Error(w, "only admins can manage kiosk tokens", http.StatusForbidden)
+		return
+	}
+	switch r.Method {
+	case "GET":
+		h.list(w)
+	case "POST":
+		h.create(w, r, c, u)
+	case "DELETE":
+		h.revoke(w, r, u)
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func (h handler) list(w http.ResponseWriter) {
+	tokens, err := config.LoadKioskTokens(h.ecl)
+	if err != nil {
+		glog.Errorf("Failed to load kiosk tokens: %v", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	views := []Token{}
+	for _, t := range tokens {
+		views = append(views, view(t))
+	}
+	helpers.RespondWithJSON(w, http.StatusOK, views)
+}
+
+func (h handler) create(w http.ResponseWriter, r *http.Request, c config.Config, u auth.User) {
+	name := r.FormValue("name")
+	var projects []string
+	for _, p := range strings.Split(r.FormValue("projects"), ",") {
+		if p = strings.TrimSpace(p); p == "" {
+			continue
+		}
+		if _, err := config.ProjectFromName(c.Projects, p); err != nil {
+			http.Error(w, fmt.Sprintf("no such project %q", p), http.StatusBadRequest)
+			return
+		}
+		projects = append(projects, p)
+	}
+	t, secret, err := config.CreateKioskToken(h.ecl, name, projects, u.Name)
+	if err == config.ErrKioskTokenExists {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+	if err != nil {
+		audit.AppendChange(h.ecl, audit.ActionKioskTokenCreated, u.Name, name, err)
+		glog.Errorf("Failed to create kiosk token %s: %v", name, err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	scope := "all projects"
+	if len(projects) > 0 {
+		scope = strings.Join(projects, ", ")
+	}
+	audit.AppendChange(h.ecl, audit.ActionKioskTokenCreated, u.Name, fmt.Sprintf("%s for %s", name, scope), nil)
+	glog.Infof("%s created kiosk token %s for %s", u.Name, name, scope)
+	helpers.RespondWithJSON(w, http.StatusCreated, Created{Token: view(t), Secret: secret, Path: "/kiosk?token=" + url.QueryEscape(secret)})
+}
+
+func (h handler) revoke(w http.ResponseWriter, r *http.Request, u auth.User) {
+	name := r.FormValue("name")
+	err := config.RevokeKioskToken(h.ecl, name)
+	if config.IsNotFound(err) {
+		http.Error(w, "no such kiosk token", http.StatusNotFound)
+		return
+	}
+	audit.AppendChange(h.ecl, audit.ActionKioskTokenRevoked, u.Name, name, err)
+	if err != nil {
+		glog.Errorf("Failed to revoke kiosk token %s: %v", name, err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	glog.Infof("%s revoked kiosk token %s", u.Name, name)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func view(t config.KioskToken) Token {
+	v := Token{Name: t.Name, Projects: t.Projects, User: t.User, Time: t.Time}
+	if v.Projects == nil {
+		v.Projects = []string{}
+	}
+	return v
+}
@@ -1,41 +1,225 @@
 package lock
 
 import (
+	"errors"
+	"fmt"
 	"net/http"
+	"time"
 
-	"github.com/coreos/go-etcd/etcd"
+	"github.com/gengo/goship/lib/acl"
+	"github.com/gengo/goship/lib/auth"
+	"github.com/gengo/goship/lib/clock"
 	"github.com/gengo/goship/lib/config"
+	"github.com/gengo/goship/lib/notification"
+	helpers "github.com/gengo/goship/lib/view-helpers"
 	"github.com/golang/glog"
+	"golang.org/x/net/context"
 )
 
-// http://127.0.0.1:8000/lock?environment=staging&project=admin
-func NewLock(ecl *etcd.Client) http.Handler {
+// NewLock returns a new http.Handler which locks an environment and the other environments in its lock group.
+// Only deployers of the environments can lock them. Environments in the group which are already locked keep their locks.
+// http://127.0.0.1:8000/lock?environment=staging&project=admin&reason=release&ttl=4h
+func NewLock(ac acl.AccessControl, ecl config.ETCDInterface) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		handler(ecl, w, r, true)
+		handler(ac, ecl, w, r, true)
 	})
 }
 
-func NewUnlock(ecl *etcd.Client) http.Handler {
+// NewUnlock returns a new http.Handler which unlocks an environment and the other environments in its lock group.
+// Only deployers of the environments can unlock them, and only admins can remove locks by other users.
+// http://127.0.0.1:8000/unlock?environment=staging&project=admin&reason=released
+func NewUnlock(ac acl.AccessControl, ecl config.ETCDInterface) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		handler(ecl, w, r, false)
+		handler(ac, ecl, w, r, false)
 	})
 }
 
-// handler allows you to lock or unlock an environment
-func handler(ecl *etcd.Client, w http.ResponseWriter, r *http.Request, lock bool) {
+// handler allows you to lock or unlock an environment together with the other environments in its lock group
+func handler(ac acl.AccessControl, ecl config.ETCDInterface, w http.ResponseWriter, r *http.Request, lock bool) {
 	p := r.FormValue("project")
 	env := r.FormValue("environment")
+	reason := r.FormValue("reason")
 
-	lockStr := "false"
-	if lock {
-		lockStr = "true"
+	u, err := auth.CurrentUser(r)
+	if err != nil {
+		glog.Errorf("Failed to get current user: %v", err)
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return
+	}
+	c, err := config.Load(ecl)
+	if err != nil {
+		glog.Errorf("Failed to load configuration: %v", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
 	}
-	err := config.LockEnvironment(ecl, p, env, lockStr)
+	ts, code, err := targets(ac, ecl, c, u, config.LockGroupMember{Project: p, Environment: env}, lock)
 	if err != nil {
-		glog.Errorf("Failed to lock/unlock project=%s env=%s: %v", p, env, err)
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		http.Error(w, err.Error(), code)
 		return
 	}
+	l := config.Lock{User: u.Name, Reason: reason, Time: clock.Now()}
+	if lock {
+		if reason == "" {
+			http.Error(w, "reason not specified", http.StatusBadRequest)
+			return
+		}
+		if s := r.FormValue("ttl"); s != "" {
+			ttl, err := time.ParseDuration(s)
+			if err != nil || ttl <= 0 {
+				http.Error(w, fmt.Sprintf("invalid ttl %q", s), http.StatusBadRequest)
+				return
+			}
+			l.Expires = l.Time.Add(ttl)
+		}
+	}
+	for _, t := range ts {
+		if lock {
+			err = config.LockEnvironment(ecl, t.Project, t.Environment, l)
+		} else {
+			err = config.UnlockEnvironment(ecl, t.Project, t.Environment, u.Name, reason)
+		}
+		if err != nil {
+			glog.Errorf("Failed to lock/unlock project=%s env=%s: %v", t.Project, t.Environment, err)
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		notify(ecl, u.Name, t.Project, t.Environment, reason, lock)
+	}
 
 	http.Redirect(w, r, "/", http.StatusSeeOther)
 }
+
+// targets returns the environments to lock or unlock for "requested", which are "requested" itself and the other environments in its lock group.
+// The other environments are skipped if they are already locked or unlocked, so that they keep their own locks.
+// It returns an HTTP status code and an error if "u" cannot lock or unlock any of them.
+// Only admins can remove locks by other users.
+func targets(ac acl.AccessControl, ecl config.ETCDInterface, c config.Config, u auth.User, requested config.LockGroupMember, lock bool) ([]config.LockGroupMember, int, error) {
+	var ts []config.LockGroupMember
+	for i, t := range append([]config.LockGroupMember{requested}, config.LockGroup(c.Projects, requested.Project, requested.Environment)...) {
+		l, err := config.LoadLock(ecl, t.Project, t.Environment)
+		if err != nil {
+			glog.Errorf("Failed to load lock of %s-%s: %v", t.Project, t.Environment, err)
+			return nil, http.StatusInternalServerError, err
+		}
+		if i > 0 && (l != nil) == lock {
+			continue
+		}
+		want := config.RoleDeployer
+		if !lock && l != nil && l.User != u.Name {
+			want = config.RoleAdmin
+		}
+		if code, err := authorize(ac, c, t.Project, t.Environment, u, want); err != nil {
+			if i > 0 {
+				err = fmt.Errorf("%v in %s-%s of the lock group", err, t.Project, t.Environment)
+			}
+			return nil, code, err
+		}
+		ts = append(ts, t)
+	}
+	return ts, 0, nil
+}
+
+// authorize checks if "u" has "want" role in "env" of "p".
+// It returns an HTTP status code and an error if not.
+func authorize(ac acl.AccessControl, c config.Config, p, env string, u auth.User, want config.Role) (int, error) {
+	proj, err := config.ProjectFromName(c.Projects, p)
+	if err != nil {
+		return http.StatusNotFound, errors.New("no such project")
+	}
+	e, err := config.EnvironmentFromName(c.Projects, p, env)
+	if err != nil {
+		return http.StatusNotFound, errors.New("no such project/environment")
+	}
+	if !acl.Permitted(ac, c, proj, *e, u, want) {
+		return http.StatusForbidden, fmt.Errorf("%s role is required", want)
+	}
+	return 0, nil
+}
+
+// notify sends a lock/unlock event to the notifiers of the project.
+func notify(ecl config.ETCDInterface, user, p, env, reason string, lock bool) {
+	c, err := config.Load(ecl)
+	if err != nil {
+		glog.Errorf("Failed to load configuration: %v", err)
+		return
+	}
+	proj, err := config.ProjectFromName(c.Projects, p)
+	if err != nil {
+		glog.Errorf("Failed to find project %s: %v", p, err)
+		return
+	}
+	ev := notification.Event{
+		Type:        notification.EnvironmentUnlocked,
+		Project:     p,
+		Environment: env,
+		User:        user,
+		Reason:      reason,
+	}
+	if lock {
+		ev.Type = notification.EnvironmentLocked
+	}
+	notification.NotifyAll(context.Background(), proj, ev)
+}
+
+// status is the lock status of an environment.
+type status struct {
+	// Lock is the current lock, or nil if the environment is not locked.
+	Lock *config.Lock `json:"lock"`
+	// Events are the changes of the lock, oldest first.
+	Events []config.LockEvent `json:"events"`
+	// Group are the other environments which are locked and unlocked together with the environment.
+	Group []config.LockGroupMember `json:"group,omitempty"`
+}
+
+// NewStatus returns a new http.Handler which serves the lock and its audit trail of an environment as JSON.
+// Only users who can read the project can see them.
+//
+// e.g. http://127.0.0.1:8000/api/locks?environment=staging&project=admin
+func NewStatus(ac acl.AccessControl, ecl config.ETCDInterface) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		u, err := auth.CurrentUser(r)
+		if err != nil {
+			glog.Errorf("Failed to get current user: %v", err)
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+			return
+		}
+		c, err := config.Load(ecl)
+		if err != nil {
+			glog.Errorf("Failed to load configuration: %v", err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		p, env := r.FormValue("project"), r.FormValue("environment")
+		proj, err := config.ProjectFromName(c.Projects, p)
+		if err != nil {
+			http.Error(w, "no such project", http.StatusNotFound)
+			return
+		}
+		repo := proj.SourceRepo()
+		if !ac.Readable(repo.RepoOwner, repo.RepoName, u.Name) {
+			http.Error(w, "no such project", http.StatusNotFound)
+			return
+		}
+		if _, err := config.EnvironmentFromName(c.Projects, p, env); err != nil {
+			http.Error(w, "no such project/environment", http.StatusNotFound)
+			return
+		}
+
+		var st status
+		if st.Lock, err = config.LoadLock(ecl, p, env); err != nil {
+			glog.Errorf("Failed to load lock of %s-%s: %v", p, env, err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if st.Events, err = config.LoadLockEvents(ecl, p, env); err != nil {
+			glog.Errorf("Failed to load lock events of %s-%s: %v", p, env, err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if st.Events == nil {
+			st.Events = []config.LockEvent{}
+		}
+		st.Group = config.LockGroup(c.Projects, p, env)
+		helpers.RespondWithJSON(w, http.StatusOK, st)
+	})
+}
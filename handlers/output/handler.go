@@ -0,0 +1,125 @@
+// Package output streams the output of deployments.
+package output
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gengo/goship/lib/acl"
+	"github.com/gengo/goship/lib/auth"
+	"github.com/gengo/goship/lib/config"
+	"github.com/gengo/goship/lib/deploy"
+	"github.com/golang/glog"
+)
+
+// OffsetHeader is the response header which tells the number of lines skipped by "offset".
+const OffsetHeader = "X-Goship-Output-Offset"
+
+type handler struct {
+	ac      acl.AccessControl
+	ecl     config.ETCDInterface
+	outputs *deploy.Outputs
+}
+
+// New returns a new http.Handler which serves the output of a deployment in "outputs" as plain text, one line per line of output.
+// Only users who can read the project can read its output.
+//
+// It skips the first "offset" lines if given, so that clients can resume after reconnecting.
+// With "follow=true", it keeps streaming new lines until the deployment finishes.
+// With "host", it serves only the lines of the host without their "[host] " prefixes, and "offset" counts the lines of the host.
+// The response ends cleanly only when all the requested output has been sent.
+//
+// e.g. GET http://127.0.0.1:8000/deploys/1/output?offset=120&follow=true
+// GET http://127.0.0.1:8000/deploys/1/output?host=web1.example.com&follow=true
+func New(ac acl.AccessControl, ecl config.ETCDInterface, outputs *deploy.Outputs) http.Handler {
+	return handler{ac: ac, ecl: ecl, outputs: outputs}
+}
+
+func (h handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	components := strings.Split(r.URL.Path, "/")
+	if len(components) != 4 || components[0] != "" || components[1] != "deploys" || components[3] != "output" {
+		http.NotFound(w, r)
+		return
+	}
+	if r.Method != "GET" {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	id := components[2]
+	offset := 0
+	if s := r.FormValue("offset"); s != "" {
+		var err error
+		if offset, err = strconv.Atoi(s); err != nil || offset < 0 {
+			http.Error(w, fmt.Sprintf("invalid offset %q", s), http.StatusBadRequest)
+			return
+		}
+	}
+	follow := r.FormValue("follow") == "true"
+	host := r.FormValue("host")
+
+	u, err := auth.CurrentUser(r)
+	if err != nil {
+		glog.Errorf("Failed to get current user: %v", err)
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return
+	}
+	out, err := h.outputs.Get(id)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	c, err := config.Load(h.ecl)
+	if err != nil {
+		glog.Errorf("Failed to load configuration: %v", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	proj, err := config.ProjectFromName(c.Projects, out.Project)
+	if err != nil {
+		glog.Errorf("Failed to find project %s: %v", out.Project, err)
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	repo := proj.SourceRepo()
+	if !h.ac.Readable(repo.RepoOwner, repo.RepoName, u.Name) {
+		glog.Errorf("%s is not allowed to read deployments of %s", u.Name, proj.Name)
+		http.Error(w, "not allowed to read deployments of the project", http.StatusForbidden)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Header().Set(OffsetHeader, strconv.Itoa(offset))
+	w.WriteHeader(http.StatusOK)
+	var closed <-chan bool
+	if cn, ok := w.(http.CloseNotifier); ok {
+		closed = cn.CloseNotify()
+	}
+	read := out.Read
+	if host != "" {
+		read = func(offset int) ([]string, bool, <-chan struct{}) { return out.ReadHost(host, offset) }
+	}
+	for {
+		lines, done, wait := read(offset)
+		for _, l := range lines {
+			if _, err := io.WriteString(w, l+"\n"); err != nil {
+				glog.Errorf("Failed to send output of deployment %s: %v", id, err)
+				return
+			}
+		}
+		offset += len(lines)
+		if f, ok := w.(http.Flusher); ok {
+			f.Flush()
+		}
+		if done || !follow {
+			return
+		}
+		select {
+		case <-wait:
+		case <-closed:
+			return
+		}
+	}
+}
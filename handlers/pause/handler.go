@@ -0,0 +1,111 @@
+// Package pause serves confirmations of deployments paused before host groups.
+package pause
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/gengo/goship/lib/acl"
+	"github.com/gengo/goship/lib/auth"
+	"github.com/gengo/goship/lib/config"
+	"github.com/gengo/goship/lib/deploy"
+	"github.com/golang/glog"
+)
+
+type handler struct {
+	ac      acl.AccessControl
+	ecl     config.ETCDInterface
+	running *deploy.Running
+}
+
+// New returns a new http.Handler which continues or aborts a paused deployment in "running".
+// The user who started the deployment and its owner can always decide.
+// Others must be deployers of the environment and listed in its approvers, if any.
+//
+// e.g. POST http://127.0.0.1:8000/deploys/1/continue
+// POST http://127.0.0.1:8000/deploys/1/abort
+func New(ac acl.AccessControl, ecl config.ETCDInterface, running *deploy.Running) http.Handler {
+	return handler{ac: ac, ecl: ecl, running: running}
+}
+
+func (h handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	components := strings.Split(r.URL.Path, "/")
+	if len(components) != 4 || components[0] != "" || components[1] != "deploys" {
+		http.NotFound(w, r)
+		return
+	}
+	var proceed bool
+	switch components[3] {
+	case "continue":
+		proceed = true
+	case "abort":
+	default:
+		http.NotFound(w, r)
+		return
+	}
+	if r.Method != "POST" {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	id := components[2]
+
+	u, err := auth.CurrentUser(r)
+	if err != nil {
+		glog.Errorf("Failed to get current user: %v", err)
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return
+	}
+	run, err := h.running.Get(id)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	if u.Name != run.User && u.Name != run.Owner {
+		if code, err := h.authorize(u, run); err != nil {
+			http.Error(w, err.Error(), code)
+			return
+		}
+	}
+
+	switch _, err := h.running.Resume(id, u.Name, proceed); err {
+	case nil:
+	case deploy.ErrNoSuchDeploy:
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	case deploy.ErrNotPaused:
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	default:
+		glog.Errorf("Failed to resume deployment %s: %v", id, err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	glog.Infof("Deployment %s of %s-%s resumed by %s; continue = %v", id, run.Project, run.Environment, u.Name, proceed)
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// authorize checks if "u" can decide on "run" as an approver.
+// It returns an HTTP status code which describes the error on failure.
+func (h handler) authorize(u auth.User, run deploy.Run) (int, error) {
+	c, err := config.Load(h.ecl)
+	if err != nil {
+		glog.Errorf("Failed to load configuration: %v", err)
+		return http.StatusInternalServerError, err
+	}
+	proj, err := config.ProjectFromName(c.Projects, run.Project)
+	if err != nil {
+		glog.Errorf("Failed to find project %s: %v", run.Project, err)
+		return http.StatusNotFound, err
+	}
+	env, err := config.EnvironmentFromName(c.Projects, run.Project, run.Environment)
+	if err != nil {
+		glog.Errorf("Failed to find environment %s of %s: %v", run.Environment, run.Project, err)
+		return http.StatusNotFound, err
+	}
+	if !acl.Permitted(h.ac, c, proj, *env, u, config.RoleDeployer) || !env.CanApprove(u.Name) {
+		glog.Errorf("%s is not allowed to confirm deployments of %s-%s", u.Name, run.Project, run.Environment)
+		return http.StatusForbidden, errors.New("only the initiator or an approver can confirm the deployment")
+	}
+	return http.StatusOK, nil
+}
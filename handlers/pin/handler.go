@@ -0,0 +1,100 @@
+package pin
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gengo/goship/lib/acl"
+	"github.com/gengo/goship/lib/auth"
+	"github.com/gengo/goship/lib/config"
+	"github.com/gengo/goship/lib/notification"
+	"github.com/gengo/goship/lib/revision"
+	"github.com/golang/glog"
+	"golang.org/x/net/context"
+)
+
+// NewPin returns a new http.Handler which pins an environment to a revision.
+// Only deployers of the environment can pin it.
+// http://127.0.0.1:8000/pin?environment=staging&project=admin&revision=abc1234&reason=certification
+func NewPin(ac acl.AccessControl, ecl config.ETCDInterface) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handler(ac, ecl, w, r, true)
+	})
+}
+
+// NewUnpin returns a new http.Handler which unpins an environment.
+// "reason" is required to unpin. Only deployers of the environment can unpin it.
+// http://127.0.0.1:8000/unpin?environment=staging&project=admin&reason=certified
+func NewUnpin(ac acl.AccessControl, ecl config.ETCDInterface) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handler(ac, ecl, w, r, false)
+	})
+}
+
+// handler allows you to pin or unpin an environment
+func handler(ac acl.AccessControl, ecl config.ETCDInterface, w http.ResponseWriter, r *http.Request, pin bool) {
+	p := r.FormValue("project")
+	env := r.FormValue("environment")
+	reason := r.FormValue("reason")
+	u, err := auth.CurrentUser(r)
+	if err != nil {
+		glog.Errorf("Failed to get current user: %v", err)
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return
+	}
+	c, err := config.Load(ecl)
+	if err != nil {
+		glog.Errorf("Failed to load configuration: %v", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	proj, err := config.ProjectFromName(c.Projects, p)
+	if err != nil {
+		http.Error(w, "no such project", http.StatusNotFound)
+		return
+	}
+	e, err := config.EnvironmentFromName(c.Projects, p, env)
+	if err != nil {
+		http.Error(w, "no such project/environment", http.StatusNotFound)
+		return
+	}
+	if !acl.Permitted(ac, c, proj, *e, u, config.RoleDeployer) {
+		glog.Errorf("%s is not allowed to pin/unpin %s-%s", u.Name, p, env)
+		http.Error(w, "not allowed to pin or unpin the environment", http.StatusForbidden)
+		return
+	}
+
+	ev := notification.Event{
+		Type:        notification.EnvironmentUnpinned,
+		Project:     p,
+		Environment: env,
+		User:        u.Name,
+		Reason:      reason,
+		Time:        time.Now(),
+	}
+	if pin {
+		rev := r.FormValue("revision")
+		err = config.PinEnvironment(ecl, p, env, config.Pin{
+			Revision: rev,
+			User:     u.Name,
+			Reason:   reason,
+			Time:     ev.Time,
+		})
+		ev.Type, ev.To = notification.EnvironmentPinned, revision.Revision(rev)
+	} else {
+		if reason == "" {
+			http.Error(w, "reason not specified", http.StatusBadRequest)
+			return
+		}
+		err = config.UnpinEnvironment(ecl, p, env)
+	}
+	if err != nil {
+		glog.Errorf("Failed to pin/unpin project=%s env=%s: %v", p, env, err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	glog.Infof("%s %s-%s by %s: %s", ev.Type, p, env, u.Name, reason)
+	notification.NotifyAll(context.Background(), proj, ev)
+
+	http.Redirect(w, r, "/", http.StatusSeeOther)
+}
@@ -0,0 +1,67 @@
+// Package preferences serves per-user preferences.
+package preferences
+
+import (
+	"net/http"
+
+	"github.com/gengo/goship/lib/auth"
+	"github.com/gengo/goship/lib/config"
+	helpers "github.com/gengo/goship/lib/view-helpers"
+	"github.com/golang/glog"
+)
+
+type handler struct {
+	ecl config.ETCDInterface
+}
+
+// New returns a new http.Handler which serves preferences of the current user.
+// GET returns the preferences as JSON.
+// POST updates the preferences with form values "home_columns" and redirects to the home page.
+// The preferences are cleared if the form value "reset" is set.
+func New(ecl config.ETCDInterface) http.Handler {
+	return handler{ecl: ecl}
+}
+
+func (h handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	u, err := auth.CurrentUser(r)
+	if err != nil {
+		glog.Errorf("Failed to get current user: %v", err)
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return
+	}
+	switch r.Method {
+	case "GET":
+		h.get(w, r, u)
+	case "POST":
+		h.post(w, r, u)
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func (h handler) get(w http.ResponseWriter, r *http.Request, u auth.User) {
+	prefs, err := config.LoadUserPreferences(h.ecl, u.Name)
+	if err != nil {
+		glog.Errorf("Failed to load preferences of %s: %v", u.Name, err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	helpers.RespondWithJSON(w, http.StatusOK, prefs)
+}
+
+func (h handler) post(w http.ResponseWriter, r *http.Request, u auth.User) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	var prefs config.UserPreferences
+	if r.FormValue("reset") == "" {
+		prefs.HomeColumns = r.Form["home_columns"]
+	}
+	if err := config.StoreUserPreferences(h.ecl, u.Name, prefs); err != nil {
+		glog.Errorf("Failed to store preferences of %s: %v", u.Name, err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	http.Redirect(w, r, "/", http.StatusSeeOther)
+}
@@ -0,0 +1,66 @@
+// Package progress serves progress of deployments as JSON.
+package progress
+
+import (
+	"net/http"
+
+	"github.com/gengo/goship/lib/acl"
+	"github.com/gengo/goship/lib/auth"
+	"github.com/gengo/goship/lib/config"
+	"github.com/gengo/goship/lib/deploy"
+	helpers "github.com/gengo/goship/lib/view-helpers"
+	"github.com/golang/glog"
+)
+
+type handler struct {
+	ac      acl.AccessControl
+	ecl     config.ETCDInterface
+	tracker *deploy.Tracker
+}
+
+// New returns a new http.Handler which serves the latest progress of deployments in "tracker".
+// It accepts optional query parameters "project" and "environment" to filter the progresses.
+//
+// e.g. http://127.0.0.1:8000/api/progress?project=admin&environment=staging
+func New(ac acl.AccessControl, ecl config.ETCDInterface, tracker *deploy.Tracker) http.Handler {
+	return handler{ac: ac, ecl: ecl, tracker: tracker}
+}
+
+func (h handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	u, err := auth.CurrentUser(r)
+	if err != nil {
+		glog.Errorf("Failed to get current user: %v", err)
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return
+	}
+	c, err := config.Load(h.ecl)
+	if err != nil {
+		glog.Errorf("Failed to load configuration: %v", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	// readable contains "project/environment" of the environments visible to the user
+	readable := make(map[string]bool)
+	for _, p := range c.VisibleProjects(acl.ReadableProjects(h.ac, c.Projects, u), u.Name) {
+		for _, e := range p.Environments {
+			readable[p.Name+"/"+e.Name] = true
+		}
+	}
+
+	proj, env := r.FormValue("project"), r.FormValue("environment")
+	progresses := []deploy.Progress{}
+	for _, p := range h.tracker.All() {
+		if !readable[p.Project+"/"+p.Environment] {
+			continue
+		}
+		if proj != "" && p.Project != proj {
+			continue
+		}
+		if env != "" && p.Environment != env {
+			continue
+		}
+		progresses = append(progresses, p)
+	}
+
+	helpers.RespondWithJSON(w, http.StatusOK, progresses)
+}
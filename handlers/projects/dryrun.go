@@ -0,0 +1,114 @@
+package projects
+
+import (
+	"net/http"
+
+	"github.com/gengo/goship/lib/acl"
+	"github.com/gengo/goship/lib/auth"
+	"github.com/gengo/goship/lib/config"
+	"github.com/gengo/goship/lib/deploy"
+	"github.com/gengo/goship/lib/inventory"
+	"github.com/gengo/goship/lib/revision"
+	helpers "github.com/gengo/goship/lib/view-helpers"
+	"github.com/golang/glog"
+	"golang.org/x/net/context"
+)
+
+type dryRun struct {
+	ac  acl.AccessControl
+	ecl config.ETCDInterface
+}
+
+// plan is the response of a dry run.
+type plan struct {
+	Project     string        `json:"project"`
+	Environment string        `json:"environment"`
+	Hosts       []string      `json:"hosts"`
+	Steps       []deploy.Step `json:"steps"`
+}
+
+// NewDryRun returns a new http.Handler which renders the commands and the target hosts of a deployment
+// without running anything.
+// GET plans a deployment of a stored project, and requires the permission to deploy the project.
+// POST plans a deployment of the project in YAML in the request body, e.g. before saving it in the editor,
+// and requires the user to be an admin.
+// "restart=true" plans a restart instead of a deployment.
+//
+// e.g. http://127.0.0.1:8000/api/dryrun?project=admin&environment=staging&from_revision=abc000&to_revision=abc123
+func NewDryRun(ac acl.AccessControl, ecl config.ETCDInterface) http.Handler {
+	return dryRun{ac: ac, ecl: ecl}
+}
+
+func (h dryRun) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var proj config.Project
+	switch r.Method {
+	case "GET":
+		u, err := auth.CurrentUser(r)
+		if err != nil {
+			glog.Errorf("Failed to get current user: %v", err)
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+			return
+		}
+		c, err := config.Load(h.ecl)
+		if err != nil {
+			glog.Errorf("Failed to load configuration: %v", err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if proj, err = config.ProjectFromName(c.Projects, r.FormValue("project")); err != nil {
+			http.Error(w, "no such project", http.StatusNotFound)
+			return
+		}
+		repo := proj.SourceRepo()
+		if !h.ac.Deployable(repo.RepoOwner, repo.RepoName, u.Name) {
+			http.Error(w, "not allowed to deploy the project", http.StatusForbidden)
+			return
+		}
+	case "POST":
+		if _, _, ok := authorize(w, r, h.ecl); !ok {
+			return
+		}
+		p, err := readProject(w, r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if proj, err = config.ValidateProject(p); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	envName := r.FormValue("environment")
+	env, err := config.EnvironmentFromName([]config.Project{proj}, proj.Name, envName)
+	if err != nil {
+		http.Error(w, "no such environment", http.StatusNotFound)
+		return
+	}
+	resolved, err := inventory.Resolve(context.Background(), *env)
+	if err != nil {
+		glog.Errorf("Failed to resolve hosts of %s-%s: %v", proj.Name, envName, err)
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	req := deploy.Request{
+		Project:     proj,
+		Environment: resolved,
+		From:        revision.Revision(r.FormValue("from_revision")),
+		To:          revision.Revision(r.FormValue("to_revision")),
+		Restart:     r.FormValue("restart") == "true",
+	}
+	steps, err := deploy.Plan(req)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	hosts := resolved.Hosts
+	if hosts == nil {
+		hosts = []string{}
+	}
+	helpers.RespondWithJSON(w, http.StatusOK, plan{Project: proj.Name, Environment: env.Name, Hosts: hosts, Steps: steps})
+}
@@ -0,0 +1,165 @@
+// Package projects serves the editor of project configurations.
+package projects
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+
+	"github.com/gengo/goship/lib/audit"
+	"github.com/gengo/goship/lib/auth"
+	"github.com/gengo/goship/lib/config"
+	"github.com/golang/glog"
+	yaml "gopkg.in/yaml.v2"
+)
+
+// maxConfigSize is the maximum size of a project configuration in a request.
+const maxConfigSize = 1 << 20
+
+type handler struct {
+	ecl config.EditableStore
+}
+
+// New returns a new http.Handler which reads and edits project configurations in YAML, in the same format as goshipcfg.
+// Only admins can use it.
+// PUT validates the project in the request body and stores it, replacing the project of the same name.
+// With "dry_run=true", it only validates the project and responds with the defaults filled.
+//
+// e.g. GET http://127.0.0.1:8000/api/projects?project=admin
+// PUT http://127.0.0.1:8000/api/projects?dry_run=true
+// DELETE http://127.0.0.1:8000/api/projects?project=admin
+func New(ecl config.EditableStore) http.Handler {
+	return handler{ecl: ecl}
+}
+
+func (h handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	u, c, ok := authorize(w, r, h.ecl)
+	if !ok {
+		return
+	}
+	switch r.Method {
+	case "GET":
+		h.get(w, r, c)
+	case "PUT", "POST":
+		h.put(w, r, u)
+	case "DELETE":
+		h.delete(w, r, c, u)
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// authorize returns the current user and the configuration if the user is an admin.
+// Otherwise it responds with an error and returns false.
+func authorize(w http.ResponseWriter, r *http.Request, ecl config.ETCDInterface) (auth.User, config.Config, bool) {
+	u, err := auth.CurrentUser(r)
+	if err != nil {
+		glog.Errorf("Failed to get current user: %v", err)
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return auth.User{}, config.Config{}, false
+	}
+	c, err := config.Load(ecl)
+	if err != nil {
+		glog.Errorf("Failed to load configuration: %v", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return auth.User{}, config.Config{}, false
+	}
+	if !c.IsAdmin(u.Name) {
+		glog.Errorf("%s is not allowed to edit projects", u.Name)
+		http.Error(w, "only admins can edit projects", http.StatusForbidden)
+		return auth.User{}, config.Config{}, false
+	}
+	return u, c, true
+}
+
+func (h handler) get(w http.ResponseWriter, r *http.Request, c config.Config) {
+	var obj interface{} = c.Projects
+	if name := r.FormValue("project"); name != "" {
+		p, err := config.ProjectFromName(c.Projects, name)
+		if err != nil {
+			http.Error(w, "no such project", http.StatusNotFound)
+			return
+		}
+		obj = p
+	}
+	writeYAML(w, http.StatusOK, obj)
+}
+
+func (h handler) put(w http.ResponseWriter, r *http.Request, u auth.User) {
+	p, err := readProject(w, r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	valid, err := config.ValidateProject(p)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if r.FormValue("dry_run") == "true" {
+		writeYAML(w, http.StatusOK, valid)
+		return
+	}
+	err = config.StoreProject(h.ecl, p)
+	record(h.ecl, audit.ActionProjectStored, u.Name, p.Name, err)
+	if err != nil {
+		glog.Errorf("Failed to store project %s: %v", p.Name, err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writeYAML(w, http.StatusOK, valid)
+}
+
+func (h handler) delete(w http.ResponseWriter, r *http.Request, c config.Config, u auth.User) {
+	name := r.FormValue("project")
+	if _, err := config.ProjectFromName(c.Projects, name); err != nil {
+		http.Error(w, "no such project", http.StatusNotFound)
+		return
+	}
+	err := config.DeleteProject(h.ecl, name)
+	record(h.ecl, audit.ActionProjectDeleted, u.Name, name, err)
+	if err != nil {
+		glog.Errorf("Failed to delete project %s: %v", name, err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// readProject reads a project configuration in YAML from the body of "r".
+func readProject(w http.ResponseWriter, r *http.Request) (config.Project, error) {
+	buf, err := ioutil.ReadAll(http.MaxBytesReader(w, r.Body, maxConfigSize))
+	if err != nil {
+		return config.Project{}, err
+	}
+	var p config.Project
+	if err := yaml.Unmarshal(buf, &p); err != nil {
+		return config.Project{}, fmt.Errorf("invalid YAML: %v", err)
+	}
+	return p, nil
+}
+
+// record adds a change of the project "name" to the audit log.
+func record(ecl config.ETCDInterface, action, user, name string, err error) {
+	rec := audit.Record{Actor: user, Action: action, Project: name, Result: audit.ResultSuccess}
+	if err != nil {
+		rec.Result, rec.Detail = audit.ResultFailure, err.Error()
+	}
+	if err := audit.Append(ecl, rec); err != nil {
+		glog.Errorf("Failed to record %s of %s in the audit log: %v", action, name, err)
+	}
+}
+
+func writeYAML(w http.ResponseWriter, code int, obj interface{}) {
+	buf, err := yaml.Marshal(obj)
+	if err != nil {
+		glog.Errorf("Failed to marshal response: %v", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/yaml; charset=utf-8")
+	w.WriteHeader(code)
+	if _, err := w.Write(buf); err != nil {
+		glog.Errorf("Failed to send response: %v", err)
+	}
+}
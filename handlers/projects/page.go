@@ -0,0 +1,84 @@
+package projects
+
+import (
+	"html/template"
+	"net/http"
+
+	"github.com/gengo/goship/lib/config"
+	helpers "github.com/gengo/goship/lib/view-helpers"
+	"github.com/golang/glog"
+	yaml "gopkg.in/yaml.v2"
+)
+
+// newProjectTemplate is the initial configuration of a new project in the editor.
+const newProjectTemplate = `name: my-project
+repo_owner: my-org
+repo_name: my-project
+repo_type: github
+host_type: node
+envs:
+- name: staging
+  deploy: /path/to/deploy.sh staging
+  repo_path: /srv/my-project
+  hosts:
+  - staging.example.com
+  branch: master
+`
+
+type page struct {
+	ecl    config.ETCDInterface
+	assets helpers.Assets
+}
+
+// NewPage returns a new http.Handler which serves the editor of project configurations for admins.
+// The page edits projects through the APIs served by New and NewDryRun.
+//
+// e.g. http://127.0.0.1:8000/admin/projects?project=admin
+func NewPage(ecl config.ETCDInterface, assets helpers.Assets) http.Handler {
+	return page{ecl: ecl, assets: assets}
+}
+
+func (h page) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	u, c, ok := authorize(w, r, h.ecl)
+	if !ok {
+		return
+	}
+	name := r.FormValue("project")
+	src := newProjectTemplate
+	if name != "" {
+		p, err := config.ProjectFromName(c.Projects, name)
+		if err != nil {
+			http.Error(w, "no such project", http.StatusNotFound)
+			return
+		}
+		buf, err := yaml.Marshal(p)
+		if err != nil {
+			glog.Errorf("Failed to marshal project %s: %v", name, err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		src = string(buf)
+	}
+	var names []string
+	for _, p := range c.Projects {
+		names = append(names, p.Name)
+	}
+
+	t, err := template.New("projects.html").ParseFiles("templates/projects.html", "templates/base.html")
+	if err != nil {
+		glog.Errorf("Failed to parse templates: %v", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	js, css := h.assets.Templates()
+	params := map[string]interface{}{
+		"Javascript":  js,
+		"Stylesheet":  css,
+		"User":        u,
+		"Page":        "projects",
+		"Projects":    names,
+		"ProjectName": name,
+		"Source":      src,
+	}
+	helpers.RespondWithTemplate(w, "text/html", t, "base", params)
+}
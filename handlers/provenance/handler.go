@@ -0,0 +1,74 @@
+// Package provenance serves checks that revisions come from protected branches.
+package provenance
+
+import (
+	"net/http"
+
+	"github.com/gengo/goship/lib/acl"
+	"github.com/gengo/goship/lib/auth"
+	"github.com/gengo/goship/lib/config"
+	"github.com/gengo/goship/lib/revision"
+	"github.com/gengo/goship/lib/scm"
+	helpers "github.com/gengo/goship/lib/view-helpers"
+	"github.com/golang/glog"
+	"golang.org/x/net/context"
+)
+
+type handler struct {
+	ac      acl.AccessControl
+	ecl     config.ETCDInterface
+	clients map[config.SCMType]scm.Client
+}
+
+// New returns a new http.Handler which checks if a revision to deploy is reachable from the protected branches of an environment.
+// It responds with scm.Provenance in JSON.
+// "revision" is the revision to deploy, and "source_revision" is its source revision if it is built from the source, e.g. into a docker image.
+// Anyone who can read the project can check its revisions.
+//
+// e.g. GET http://127.0.0.1:8000/api/provenance?project=admin&environment=production&revision=abc123
+func New(ac acl.AccessControl, ecl config.ETCDInterface, clients map[config.SCMType]scm.Client) http.Handler {
+	return handler{ac: ac, ecl: ecl, clients: clients}
+}
+
+func (h handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	u, err := auth.CurrentUser(r)
+	if err != nil {
+		glog.Errorf("Failed to get current user: %v", err)
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return
+	}
+	projName, envName := r.FormValue("project"), r.FormValue("environment")
+	if projName == "" || envName == "" {
+		http.Error(w, "project and environment must be specified", http.StatusBadRequest)
+		return
+	}
+	c, err := config.Load(h.ecl)
+	if err != nil {
+		glog.Errorf("Failed to load configuration: %v", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	proj, err := config.ProjectFromName(c.Projects, projName)
+	if err != nil {
+		http.Error(w, "no such project", http.StatusNotFound)
+		return
+	}
+	repo := proj.SourceRepo()
+	if !h.ac.Readable(repo.RepoOwner, repo.RepoName, u.Name) {
+		http.Error(w, "no such project", http.StatusNotFound)
+		return
+	}
+	env, err := config.EnvironmentFromName(c.Projects, projName, envName)
+	if err != nil {
+		http.Error(w, "no such project/environment", http.StatusNotFound)
+		return
+	}
+
+	rev := scm.SourceRevision(proj, revision.Revision(r.FormValue("revision")), revision.Revision(r.FormValue("source_revision")))
+	p := scm.VerifyProvenance(context.Background(), h.clients, proj, *env, rev)
+	helpers.RespondWithJSON(w, http.StatusOK, p)
+}
@@ -0,0 +1,85 @@
+package reservations
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/gengo/goship/lib/acl"
+	"github.com/gengo/goship/lib/auth"
+	"github.com/gengo/goship/lib/config"
+	"github.com/golang/glog"
+)
+
+// icalTime is the format of UTC times in iCalendar.
+const icalTime = "20060102T150405Z"
+
+type calendar struct {
+	ac  acl.AccessControl
+	ecl config.ETCDInterface
+}
+
+// NewCalendar returns a new http.Handler which serves upcoming reservations of environments as an iCalendar feed.
+// It includes all the environments of the projects which the user can read, or only those of "project" if given.
+//
+// e.g. http://127.0.0.1:8000/calendar/reservations.ics?project=admin
+func NewCalendar(ac acl.AccessControl, ecl config.ETCDInterface) http.Handler {
+	return calendar{ac: ac, ecl: ecl}
+}
+
+func (h calendar) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	u, err := auth.CurrentUser(r)
+	if err != nil {
+		glog.Errorf("Failed to get current user: %v", err)
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return
+	}
+	c, err := config.Load(h.ecl)
+	if err != nil {
+		glog.Errorf("Failed to load configuration: %v", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString("BEGIN:VCALENDAR\r\nVERSION:2.0\r\nPRODID:-//goship//reservations//EN\r\n")
+	for _, proj := range c.Projects {
+		if p := r.FormValue("project"); p != "" && p != proj.Name {
+			continue
+		}
+		repo := proj.SourceRepo()
+		if !h.ac.Readable(repo.RepoOwner, repo.RepoName, u.Name) {
+			continue
+		}
+		for _, env := range proj.Environments {
+			reservations, err := config.LoadReservations(h.ecl, proj.Name, env.Name)
+			if err != nil {
+				glog.Errorf("Failed to load reservations of %s-%s: %v", proj.Name, env.Name, err)
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			for _, res := range reservations {
+				summary := fmt.Sprintf("%s %s reserved by %s", proj.Name, env.Name, res.User)
+				if res.Reason != "" {
+					summary = fmt.Sprintf("%s: %s", summary, res.Reason)
+				}
+				fmt.Fprintf(&buf, "BEGIN:VEVENT\r\nUID:%s-%s-%s-%d@goship\r\nDTSTAMP:%s\r\nDTSTART:%s\r\nDTEND:%s\r\nSUMMARY:%s\r\nEND:VEVENT\r\n",
+					proj.Name, env.Name, res.ID, res.Start.Unix(),
+					res.Start.UTC().Format(icalTime), res.Start.UTC().Format(icalTime), res.End.UTC().Format(icalTime),
+					escapeText(summary))
+			}
+		}
+	}
+	buf.WriteString("END:VCALENDAR\r\n")
+
+	w.Header().Set("Content-Type", "text/calendar; charset=utf-8")
+	if _, err := w.Write(buf.Bytes()); err != nil {
+		glog.Errorf("Failed to send response: %v", err)
+	}
+}
+
+// escapeText escapes "s" as a TEXT value of iCalendar.
+func escapeText(s string) string {
+	return strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\n", `\n`).Replace(s)
+}
@@ -0,0 +1,165 @@
+// Package reservations serves reservations of environments for release windows.
+package reservations
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gengo/goship/lib/acl"
+	"github.com/gengo/goship/lib/auth"
+	"github.com/gengo/goship/lib/clock"
+	"github.com/gengo/goship/lib/config"
+	"github.com/gengo/goship/lib/notification"
+	helpers "github.com/gengo/goship/lib/view-helpers"
+	"github.com/golang/glog"
+	"golang.org/x/net/context"
+)
+
+type handler struct {
+	ac  acl.AccessControl
+	ecl config.ETCDInterface
+}
+
+// New returns a new http.Handler which manages reservations of an environment.
+// Anyone who can read the project can list its reservations, but only deployers of the environment can change them.
+// Start and end are in RFC 3339.
+//
+// e.g. GET http://127.0.0.1:8000/api/reservations?project=admin&environment=production
+// POST http://127.0.0.1:8000/api/reservations?project=admin&environment=production&start=2015-11-10T14:00:00Z&end=2015-11-10T15:00:00Z&reason=release
+// DELETE http://127.0.0.1:8000/api/reservations?project=admin&environment=production&id=1
+func New(ac acl.AccessControl, ecl config.ETCDInterface) http.Handler {
+	return handler{ac: ac, ecl: ecl}
+}
+
+func (h handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	u, err := auth.CurrentUser(r)
+	if err != nil {
+		glog.Errorf("Failed to get current user: %v", err)
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return
+	}
+	c, err := config.Load(h.ecl)
+	if err != nil {
+		glog.Errorf("Failed to load configuration: %v", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	projName, envName := r.FormValue("project"), r.FormValue("environment")
+	if projName == "" || envName == "" {
+		http.Error(w, "project and environment must be specified", http.StatusBadRequest)
+		return
+	}
+	proj, err := config.ProjectFromName(c.Projects, projName)
+	if err != nil {
+		http.Error(w, "no such project", http.StatusNotFound)
+		return
+	}
+	env, err := config.EnvironmentFromName(c.Projects, projName, envName)
+	if err != nil {
+		http.Error(w, "no such project/environment", http.StatusNotFound)
+		return
+	}
+	repo := proj.SourceRepo()
+	if !h.ac.Readable(repo.RepoOwner, repo.RepoName, u.Name) {
+		http.Error(w, "no such project", http.StatusNotFound)
+		return
+	}
+
+	switch r.Method {
+	case "GET":
+		h.list(w, projName, envName)
+		return
+	case "POST", "DELETE":
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !acl.Permitted(h.ac, c, proj, *env, u, config.RoleDeployer) {
+		glog.Errorf("%s is not allowed to change reservations of %s-%s", u.Name, proj.Name, envName)
+		http.Error(w, "not allowed to change reservations of the environment", http.StatusForbidden)
+		return
+	}
+	if r.Method == "POST" {
+		h.reserve(w, r, u.Name, proj, envName)
+		return
+	}
+	h.cancel(w, r, u.Name, proj, envName)
+}
+
+func (h handler) list(w http.ResponseWriter, proj, env string) {
+	reservations, err := config.LoadReservations(h.ecl, proj, env)
+	if err != nil {
+		glog.Errorf("Failed to load reservations of %s-%s: %v", proj, env, err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if reservations == nil {
+		reservations = []config.Reservation{}
+	}
+	helpers.RespondWithJSON(w, http.StatusOK, reservations)
+}
+
+func (h handler) reserve(w http.ResponseWriter, r *http.Request, user string, proj config.Project, env string) {
+	res := config.Reservation{User: user, Reason: r.FormValue("reason")}
+	for _, spec := range []struct {
+		name  string
+		value *time.Time
+	}{
+		{name: "start", value: &res.Start},
+		{name: "end", value: &res.End},
+	} {
+		t, err := time.Parse(time.RFC3339, r.FormValue(spec.name))
+		if err != nil {
+			http.Error(w, fmt.Sprintf("invalid %s %q: %v", spec.name, r.FormValue(spec.name), err), http.StatusBadRequest)
+			return
+		}
+		*spec.value = t
+	}
+	if !res.End.After(clock.Now()) {
+		http.Error(w, "reservation must end in the future", http.StatusBadRequest)
+		return
+	}
+	res, err := config.Reserve(h.ecl, proj.Name, env, res)
+	if err == config.ErrReservationConflict {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+	if err != nil {
+		glog.Errorf("Failed to reserve %s-%s: %v", proj.Name, env, err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	notification.NotifyAll(context.Background(), proj, notification.Event{
+		Type:        notification.EnvironmentReserved,
+		Project:     proj.Name,
+		Environment: env,
+		User:        user,
+		Reason:      res.Reason,
+		Window:      res.Window(),
+	})
+	helpers.RespondWithJSON(w, http.StatusCreated, res)
+}
+
+func (h handler) cancel(w http.ResponseWriter, r *http.Request, user string, proj config.Project, env string) {
+	id := r.FormValue("id")
+	res, err := config.CancelReservation(h.ecl, proj.Name, env, id)
+	if err == config.ErrNoSuchReservation {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		glog.Errorf("Failed to cancel reservation %s of %s-%s: %v", id, proj.Name, env, err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	notification.NotifyAll(context.Background(), proj, notification.Event{
+		Type:        notification.ReservationCanceled,
+		Project:     proj.Name,
+		Environment: env,
+		User:        user,
+		Owner:       res.User,
+		Window:      res.Window(),
+	})
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,191 @@
+// Package schedules serves the admin API of recurring and one-off actions in environments.
+package schedules
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gengo/goship/lib/acl"
+	"github.com/gengo/goship/lib/auth"
+	"github.com/gengo/goship/lib/clock"
+	"github.com/gengo/goship/lib/config"
+	"github.com/gengo/goship/lib/notification"
+	"github.com/gengo/goship/lib/revision"
+	"github.com/gengo/goship/lib/schedule"
+	helpers "github.com/gengo/goship/lib/view-helpers"
+	"github.com/golang/glog"
+	"golang.org/x/net/context"
+)
+
+type handler struct {
+	ac  acl.AccessControl
+	ecl config.ETCDInterface
+}
+
+// New returns a new http.Handler which manages schedules of an environment.
+// Anyone who can read the project can list its schedules, but only deployers of the environment can change them.
+//
+// e.g. GET http://127.0.0.1:8000/api/schedules?project=admin&environment=staging
+// POST http://127.0.0.1:8000/api/schedules?project=admin&environment=staging&cron=0+3+*+*+*&action=redeploy
+// POST http://127.0.0.1:8000/api/schedules?project=admin&environment=staging&at=2015-11-10T03:00:00Z&action=deploy&revision=abc123
+// DELETE http://127.0.0.1:8000/api/schedules?project=admin&environment=staging&id=1
+func New(ac acl.AccessControl, ecl config.ETCDInterface) http.Handler {
+	return handler{ac: ac, ecl: ecl}
+}
+
+func (h handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	u, err := auth.CurrentUser(r)
+	if err != nil {
+		glog.Errorf("Failed to get current user: %v", err)
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return
+	}
+	c, err := config.Load(h.ecl)
+	if err != nil {
+		glog.Errorf("Failed to load configuration: %v", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	projName, envName := r.FormValue("project"), r.FormValue("environment")
+	if projName == "" || envName == "" {
+		http.Error(w, "project and environment must be specified", http.StatusBadRequest)
+		return
+	}
+	proj, err := config.ProjectFromName(c.Projects, projName)
+	if err != nil {
+		http.Error(w, "no such project", http.StatusNotFound)
+		return
+	}
+	env, err := config.EnvironmentFromName(c.Projects, projName, envName)
+	if err != nil {
+		http.Error(w, "no such project/environment", http.StatusNotFound)
+		return
+	}
+	repo := proj.SourceRepo()
+	if !h.ac.Readable(repo.RepoOwner, repo.RepoName, u.Name) {
+		http.Error(w, "no such project", http.StatusNotFound)
+		return
+	}
+
+	switch r.Method {
+	case "GET":
+		h.list(w, projName, envName)
+		return
+	case "POST", "DELETE":
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !acl.Permitted(h.ac, c, proj, *env, u, config.RoleDeployer) {
+		glog.Errorf("%s is not allowed to change schedules of %s-%s", u.Name, proj.Name, envName)
+		http.Error(w, "not allowed to change schedules of the environment", http.StatusForbidden)
+		return
+	}
+	if r.Method == "POST" {
+		h.add(w, r, u.Name, proj, envName)
+		return
+	}
+	h.remove(w, r, u.Name, proj, envName)
+}
+
+func (h handler) list(w http.ResponseWriter, proj, env string) {
+	schedules, err := config.LoadSchedules(h.ecl, proj, env)
+	if err != nil {
+		glog.Errorf("Failed to load schedules of %s-%s: %v", proj, env, err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if schedules == nil {
+		schedules = []config.Schedule{}
+	}
+	helpers.RespondWithJSON(w, http.StatusOK, schedules)
+}
+
+func (h handler) add(w http.ResponseWriter, r *http.Request, user string, proj config.Project, env string) {
+	s := config.Schedule{
+		Cron:   r.FormValue("cron"),
+		Action: config.ScheduleAction(r.FormValue("action")),
+		User:   user,
+		Time:   clock.Now(),
+	}
+	if s.Action == config.ScheduleDeploy {
+		at, err := time.Parse(time.RFC3339, r.FormValue("at"))
+		if err != nil {
+			http.Error(w, fmt.Sprintf("invalid time %q: %v", r.FormValue("at"), err), http.StatusBadRequest)
+			return
+		}
+		if !at.After(s.Time) {
+			http.Error(w, "scheduled time must be in the future", http.StatusBadRequest)
+			return
+		}
+		s.Cron, s.At, s.Revision = "", at, r.FormValue("revision")
+		if s.Revision == "" {
+			http.Error(w, "revision not specified", http.StatusBadRequest)
+			return
+		}
+	} else if _, err := schedule.Parse(s.Cron); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if !s.Action.Valid() {
+		http.Error(w, fmt.Sprintf("invalid action %q", s.Action), http.StatusBadRequest)
+		return
+	}
+	s, err := config.AddSchedule(h.ecl, proj.Name, env, s)
+	if err != nil {
+		glog.Errorf("Failed to add a schedule to %s-%s: %v", proj.Name, env, err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	notification.NotifyAll(context.Background(), proj, notification.Event{
+		Type:        notification.ScheduleAdded,
+		Project:     proj.Name,
+		Environment: env,
+		User:        user,
+		Reason:      describe(s),
+	})
+	helpers.RespondWithJSON(w, http.StatusCreated, s)
+}
+
+func (h handler) remove(w http.ResponseWriter, r *http.Request, user string, proj config.Project, env string) {
+	id := r.FormValue("id")
+	schedules, err := config.LoadSchedules(h.ecl, proj.Name, env)
+	if err != nil {
+		glog.Errorf("Failed to load schedules of %s-%s: %v", proj.Name, env, err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	var desc string
+	for _, s := range schedules {
+		if s.ID == id {
+			desc = describe(s)
+		}
+	}
+	err = config.RemoveSchedule(h.ecl, proj.Name, env, id)
+	if err == config.ErrNoSuchSchedule {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		glog.Errorf("Failed to remove schedule %s from %s-%s: %v", id, proj.Name, env, err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	notification.NotifyAll(context.Background(), proj, notification.Event{
+		Type:        notification.ScheduleRemoved,
+		Project:     proj.Name,
+		Environment: env,
+		User:        user,
+		Reason:      desc,
+	})
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// describe returns a human-readable description of "s" for notifications.
+func describe(s config.Schedule) string {
+	if s.OneOff() {
+		return fmt.Sprintf("%s of %s (at %s)", s.Action, revision.Revision(s.Revision).Short(), s.At.Format(time.RFC3339))
+	}
+	return fmt.Sprintf("%s (%s)", s.Action, s.Cron)
+}
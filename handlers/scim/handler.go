@@ -0,0 +1,291 @@
+// Package scim serves the SCIM 2.0 endpoint with which the identity provider provisions users and groups.
+package scim
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gengo/goship/lib/config"
+	"github.com/gengo/goship/lib/scim"
+	helpers "github.com/gengo/goship/lib/view-helpers"
+	"github.com/golang/glog"
+)
+
+const (
+	// Prefix is the path under which the endpoint is served.
+	Prefix = "/scim/v2/"
+	// maxResults is the maximum number of resources in a page of a list.
+	maxResults = 200
+
+	contentType = "application/scim+json"
+)
+
+type handler struct {
+	ecl config.EditableStore
+}
+
+// New returns a new http.Handler which serves Users, Groups and ServiceProviderConfig of SCIM 2.0 under Prefix.
+// The identity provider authenticates with the token of the scim configuration as a bearer token instead of a session.
+// The endpoint is disabled unless scim is configured.
+//
+// e.g. GET http://127.0.0.1:8000/scim/v2/Users?filter=userName%20eq%20%22alice%22
+func New(ecl config.EditableStore) http.Handler {
+	return handler{ecl: ecl}
+}
+
+func (h handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	c, err := config.Load(h.ecl)
+	if err != nil {
+		glog.Errorf("Failed to load configuration: %v", err)
+		respondError(w, err)
+		return
+	}
+	if !authorized(c, r) {
+		glog.Errorf("Unauthorized scim request from %s", r.RemoteAddr)
+		respondError(w, &scim.Error{Status: http.StatusUnauthorized, Detail: "invalid token"})
+		return
+	}
+	p := scim.New(h.ecl, c)
+
+	var id string
+	parts := strings.SplitN(strings.Trim(strings.TrimPrefix(r.URL.Path, Prefix), "/"), "/", 2)
+	if len(parts) == 2 {
+		id = parts[1]
+	}
+	switch parts[0] {
+	case "Users":
+		h.serveUsers(w, r, p, id)
+	case "Groups":
+		h.serveGroups(w, r, p, id)
+	case "ServiceProviderConfig":
+		respond(w, http.StatusOK, serviceProviderConfig)
+	default:
+		respondError(w, &scim.Error{Status: http.StatusNotFound, Detail: "no such resource type"})
+	}
+}
+
+// authorized returns true if "r" carries the token of the scim configuration of "c".
+func authorized(c config.Config, r *http.Request) bool {
+	if c.SCIM == nil || c.SCIM.Token == "" {
+		return false
+	}
+	token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	return subtle.ConstantTimeCompare([]byte(token), []byte(c.SCIM.Token)) == 1
+}
+
+func (h handler) serveUsers(w http.ResponseWriter, r *http.Request, p scim.Provisioner, id string) {
+	var (
+		u   scim.User
+		ops scim.PatchOp
+	)
+	switch {
+	case r.Method == "GET" && id == "":
+		f, err := scim.ParseFilter(r.FormValue("filter"))
+		if err != nil {
+			respondError(w, err)
+			return
+		}
+		users, err := p.Users(f)
+		if err != nil {
+			respondError(w, err)
+			return
+		}
+		start, end, err := page(r, len(users))
+		if err != nil {
+			respondError(w, err)
+			return
+		}
+		respondList(w, start, len(users), users[start:end])
+	case r.Method == "GET":
+		res, err := p.User(id)
+		respondResource(w, http.StatusOK, res, err)
+	case r.Method == "POST" && id == "":
+		if err := decode(r, &u); err != nil {
+			respondError(w, err)
+			return
+		}
+		res, err := p.CreateUser(u)
+		respondResource(w, http.StatusCreated, res, err)
+	case r.Method == "PUT" && id != "":
+		if err := decode(r, &u); err != nil {
+			respondError(w, err)
+			return
+		}
+		res, err := p.ReplaceUser(id, u)
+		respondResource(w, http.StatusOK, res, err)
+	case r.Method == "PATCH" && id != "":
+		if err := decode(r, &ops); err != nil {
+			respondError(w, err)
+			return
+		}
+		res, err := p.PatchUser(id, ops.Operations)
+		respondResource(w, http.StatusOK, res, err)
+	case r.Method == "DELETE" && id != "":
+		if err := p.DeleteUser(id); err != nil {
+			respondError(w, err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		respondError(w, &scim.Error{Status: http.StatusMethodNotAllowed, Detail: "method not allowed"})
+	}
+}
+
+func (h handler) serveGroups(w http.ResponseWriter, r *http.Request, p scim.Provisioner, id string) {
+	var (
+		g   scim.Group
+		ops scim.PatchOp
+	)
+	switch {
+	case r.Method == "GET" && id == "":
+		f, err := scim.ParseFilter(r.FormValue("filter"))
+		if err != nil {
+			respondError(w, err)
+			return
+		}
+		groups, err := p.Groups(f)
+		if err != nil {
+			respondError(w, err)
+			return
+		}
+		start, end, err := page(r, len(groups))
+		if err != nil {
+			respondError(w, err)
+			return
+		}
+		respondList(w, start, len(groups), groups[start:end])
+	case r.Method == "GET":
+		res, err := p.Group(id)
+		respondResource(w, http.StatusOK, res, err)
+	case r.Method == "POST" && id == "":
+		if err := decode(r, &g); err != nil {
+			respondError(w, err)
+			return
+		}
+		res, err := p.CreateGroup(g)
+		respondResource(w, http.StatusCreated, res, err)
+	case r.Method == "PUT" && id != "":
+		if err := decode(r, &g); err != nil {
+			respondError(w, err)
+			return
+		}
+		res, err := p.ReplaceGroup(id, g)
+		respondResource(w, http.StatusOK, res, err)
+	case r.Method == "PATCH" && id != "":
+		if err := decode(r, &ops); err != nil {
+			respondError(w, err)
+			return
+		}
+		res, err := p.PatchGroup(id, ops.Operations)
+		respondResource(w, http.StatusOK, res, err)
+	case r.Method == "DELETE" && id != "":
+		if err := p.DeleteGroup(id); err != nil {
+			respondError(w, err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		respondError(w, &scim.Error{Status: http.StatusMethodNotAllowed, Detail: "method not allowed"})
+	}
+}
+
+// page returns the range of the resources in the page requested with "startIndex" and "count" out of "total".
+// "startIndex" is 1-based as defined in RFC 7644.
+func page(r *http.Request, total int) (int, int, error) {
+	start, count := 1, maxResults
+	if s := r.FormValue("startIndex"); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil {
+			return 0, 0, &scim.Error{Status: http.StatusBadRequest, ScimType: "invalidValue", Detail: "invalid startIndex"}
+		}
+		if n > 1 {
+			start = n
+		}
+	}
+	if s := r.FormValue("count"); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil {
+			return 0, 0, &scim.Error{Status: http.StatusBadRequest, ScimType: "invalidValue", Detail: "invalid count"}
+		}
+		if n < 0 {
+			n = 0
+		}
+		if n < count {
+			count = n
+		}
+	}
+	begin := start - 1
+	if begin > total {
+		begin = total
+	}
+	end := begin + count
+	if end > total {
+		end = total
+	}
+	return begin, end, nil
+}
+
+func decode(r *http.Request, v interface{}) error {
+	if err := json.NewDecoder(r.Body).Decode(v); err != nil {
+		return &scim.Error{Status: http.StatusBadRequest, ScimType: "invalidSyntax", Detail: err.Error()}
+	}
+	return nil
+}
+
+func respondList(w http.ResponseWriter, begin, total int, resources interface{}) {
+	n := 0
+	switch rs := resources.(type) {
+	case []scim.User:
+		n = len(rs)
+	case []scim.Group:
+		n = len(rs)
+	}
+	respond(w, http.StatusOK, scim.ListResponse{
+		Schemas:      []string{scim.ListResponseSchema},
+		TotalResults: total,
+		StartIndex:   begin + 1,
+		ItemsPerPage: n,
+		Resources:    resources,
+	})
+}
+
+func respondResource(w http.ResponseWriter, status int, resource interface{}, err error) {
+	if err != nil {
+		respondError(w, err)
+		return
+	}
+	respond(w, status, resource)
+}
+
+func respondError(w http.ResponseWriter, err error) {
+	e, ok := err.(*scim.Error)
+	if !ok {
+		glog.Errorf("Failed to serve scim request: %v", err)
+		e = &scim.Error{Status: http.StatusInternalServerError, Detail: err.Error()}
+	}
+	respond(w, e.Status, e)
+}
+
+func respond(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", contentType)
+	helpers.RespondWithJSON(w, status, v)
+}
+
+// serviceProviderConfig tells the identity provider which features of SCIM are supported.
+var serviceProviderConfig = map[string]interface{}{
+	"schemas":        []string{"urn:ietf:params:scim:schemas:core:2.0:ServiceProviderConfig"},
+	"patch":          map[string]bool{"supported": true},
+	"bulk":           map[string]interface{}{"supported": false, "maxOperations": 0, "maxPayloadSize": 0},
+	"filter":         map[string]interface{}{"supported": true, "maxResults": maxResults},
+	"changePassword": map[string]bool{"supported": false},
+	"sort":           map[string]bool{"supported": false},
+	"etag":           map[string]bool{"supported": false},
+	"authenticationSchemes": []map[string]string{{
+		"type":        "oauthbearertoken",
+		"name":        "OAuth Bearer Token",
+		"description": "Authentication with the token in the scim configuration of Goship",
+	}},
+}
@@ -0,0 +1,211 @@
+// Package vars serves self-service changes of non-secret environment variables of environments.
+package vars
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gengo/goship/lib/acl"
+	"github.com/gengo/goship/lib/audit"
+	"github.com/gengo/goship/lib/auth"
+	"github.com/gengo/goship/lib/config"
+	helpers "github.com/gengo/goship/lib/view-helpers"
+	"github.com/golang/glog"
+)
+
+// maxChangeSize is the maximum size of a change of variables in a request.
+const maxChangeSize = 64 << 10
+
+type handler struct {
+	ac  acl.AccessControl
+	ecl config.ETCDInterface
+}
+
+// Vars is the variables of an environment and their changes.
+type Vars struct {
+	Vars map[string]string `json:"vars"`
+	// RequireApproval is true if changes wait for approvals.
+	RequireApproval bool               `json:"require_approval"`
+	Changes         []config.VarChange `json:"changes"`
+}
+
+// New returns a new http.Handler which shows and changes the environment variables of an environment.
+// Anyone who can read the project can see the variables and their history.
+// Deployers of the environment can change them in a JSON body with "set" and "unset".
+// Changes of environments with require_approval wait until another deployer listed in the approvers, if any, approves them.
+//
+// e.g. GET http://127.0.0.1:8000/api/vars?project=admin&environment=staging
+// POST http://127.0.0.1:8000/api/vars?project=admin&environment=staging with {"set": {"LOG_LEVEL": "debug"}, "unset": ["FEATURE_X"]}
+// POST http://127.0.0.1:8000/api/vars?project=admin&environment=production&id=2&decision=approve
+func New(ac acl.AccessControl, ecl config.ETCDInterface) http.Handler {
+	return handler{ac: ac, ecl: ecl}
+}
+
+func (h handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	u, c, proj, env, ok := h.environment(w, r)
+	if !ok {
+		return
+	}
+	switch r.Method {
+	case "GET":
+		h.get(w, proj, env)
+	case "POST":
+		if !acl.Permitted(h.ac, c, proj, env, u, config.RoleDeployer) {
+			http.Error(w, "deployer role is required", http.StatusForbidden)
+			return
+		}
+		if r.FormValue("id") != "" {
+			h.decide(w, r, u, proj, env)
+			return
+		}
+		h.change(w, r, u, proj, env)
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// environment returns the current user and the environment in the request if the user can read it.
+// Otherwise it responds with an error and returns false.
+func (h handler) environment(w http.ResponseWriter, r *http.Request) (auth.User, config.Config, config.Project, config.Environment, bool) {
+	u, err := auth.CurrentUser(r)
+	if err != nil {
+		glog.Errorf("Failed to get current user: %v", err)
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return auth.User{}, config.Config{}, config.Project{}, config.Environment{}, false
+	}
+	projName, envName := r.FormValue("project"), r.FormValue("environment")
+	if projName == "" || envName == "" {
+		http.Error(w, "project and environment must be specified", http.StatusBadRequest)
+		return auth.User{}, config.Config{}, config.Project{}, config.Environment{}, false
+	}
+	c, err := config.Load(h.ecl)
+	if err != nil {
+		glog.Errorf("Failed to load configuration: %v", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return auth.User{}, config.Config{}, config.Project{}, config.Environment{}, false
+	}
+	proj, err := config.ProjectFromName(c.Projects, projName)
+	if err != nil {
+		http.Error(w, "no such project", http.StatusNotFound)
+		return auth.User{}, config.Config{}, config.Project{}, config.Environment{}, false
+	}
+	env, err := config.EnvironmentFromName(c.Projects, projName, envName)
+	if err != nil || !c.EnvironmentVisible(*env, u.Name) || !acl.Permitted(h.ac, c, proj, *env, u, config.RoleViewer) {
+		http.Error(w, "no such project/environment", http.StatusNotFound)
+		return auth.User{}, config.Config{}, config.Project{}, config.Environment{}, false
+	}
+	return u, c, proj, *env, true
+}
+
+func (h handler) get(w http.ResponseWriter, proj config.Project, env config.Environment) {
+	changes, err := config.LoadVarChanges(h.ecl, proj.Name, env.Name)
+	if err != nil {
+		glog.Errorf("Failed to load changes of variables of %s-%s: %v", proj.Name, env.Name, err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	v := Vars{Vars: env.Vars, RequireApproval: env.RequireApproval, Changes: changes}
+	if v.Vars == nil {
+		v.Vars = map[string]string{}
+	}
+	if v.Changes == nil {
+		v.Changes = []config.VarChange{}
+	}
+	helpers.RespondWithJSON(w, http.StatusOK, v)
+}
+
+func (h handler) change(w http.ResponseWriter, r *http.Request, u auth.User, proj config.Project, env config.Environment) {
+	var req struct {
+		Set   map[string]string `json:"set"`
+		Unset []string          `json:"unset"`
+	}
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxChangeSize)).Decode(&req); err != nil {
+		http.Error(w, fmt.Sprintf("invalid change: %v", err), http.StatusBadRequest)
+		return
+	}
+	pending := env.RequireApproval
+	ch, err := config.ChangeVars(h.ecl, proj.Name, env.Name, config.VarChange{Set: req.Set, Unset: req.Unset, User: u.Name, Time: time.Now()}, pending)
+	action := audit.ActionVarsChanged
+	if pending {
+		action = audit.ActionVarsChangeRequested
+	}
+	detail := config.VarChange{Set: req.Set, Unset: req.Unset}.Summary()
+	record(h.ecl, action, u.Name, proj.Name, env.Name, detail, err)
+	if err != nil {
+		glog.Errorf("Failed to change variables of %s-%s: %v", proj.Name, env.Name, err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if pending {
+		glog.Infof("%s requested a change of variables of %s-%s: %s", u.Name, proj.Name, env.Name, detail)
+		helpers.RespondWithJSON(w, http.StatusAccepted, ch)
+		return
+	}
+	glog.Infof("%s changed variables of %s-%s to version %d: %s", u.Name, proj.Name, env.Name, ch.Version, detail)
+	helpers.RespondWithJSON(w, http.StatusOK, ch)
+}
+
+func (h handler) decide(w http.ResponseWriter, r *http.Request, u auth.User, proj config.Project, env config.Environment) {
+	var approve bool
+	switch r.FormValue("decision") {
+	case "approve":
+		approve = true
+	case "reject":
+	default:
+		http.Error(w, fmt.Sprintf("invalid decision %q", r.FormValue("decision")), http.StatusBadRequest)
+		return
+	}
+	if !env.CanApprove(u.Name) {
+		http.Error(w, "not allowed to approve changes of the environment", http.StatusForbidden)
+		return
+	}
+	id := r.FormValue("id")
+	changes, err := config.LoadVarChanges(h.ecl, proj.Name, env.Name)
+	if err != nil {
+		glog.Errorf("Failed to load changes of variables of %s-%s: %v", proj.Name, env.Name, err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	for _, ch := range changes {
+		if ch.ID == id && ch.User == u.Name {
+			http.Error(w, "cannot approve your own change", http.StatusForbidden)
+			return
+		}
+	}
+
+	ch, err := config.DecideVarChange(h.ecl, proj.Name, env.Name, id, u.Name, approve)
+	switch err {
+	case nil:
+	case config.ErrNoSuchVarChange:
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	case config.ErrVarChangeDecided:
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	default:
+		glog.Errorf("Failed to decide change %s of variables of %s-%s: %v", id, proj.Name, env.Name, err)
+		record(h.ecl, audit.ActionVarsChanged, u.Name, proj.Name, env.Name, "change "+id, err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	action := audit.ActionVarsChangeRejected
+	if approve {
+		action = audit.ActionVarsChanged
+	}
+	record(h.ecl, action, u.Name, proj.Name, env.Name, fmt.Sprintf("change %s by %s: %s", ch.ID, ch.User, ch.Summary()), nil)
+	glog.Infof("Change %s of variables of %s-%s %s by %s", ch.ID, proj.Name, env.Name, ch.State, u.Name)
+	helpers.RespondWithJSON(w, http.StatusOK, ch)
+}
+
+// record adds a change of the variables of "envName" of "projName" to the audit log.
+func record(ecl config.ETCDInterface, action, user, projName, envName, detail string, err error) {
+	rec := audit.Record{Actor: user, Action: action, Project: projName, Environment: envName, Result: audit.ResultSuccess, Detail: detail}
+	if err != nil {
+		rec.Result, rec.Detail = audit.ResultFailure, detail+": "+err.Error()
+	}
+	if err := audit.Append(ecl, rec); err != nil {
+		glog.Errorf("Failed to record %s of %s-%s in the audit log: %v", action, projName, envName, err)
+	}
+}
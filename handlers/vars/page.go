@@ -0,0 +1,49 @@
+package vars
+
+import (
+	"html/template"
+	"net/http"
+
+	"github.com/gengo/goship/lib/acl"
+	"github.com/gengo/goship/lib/config"
+	helpers "github.com/gengo/goship/lib/view-helpers"
+	"github.com/golang/glog"
+)
+
+type page struct {
+	handler
+	assets helpers.Assets
+}
+
+// NewPage returns a new http.Handler which serves the editor of the environment variables of an environment.
+// The page edits the variables through the API served by New.
+//
+// e.g. http://127.0.0.1:8000/vars?project=admin&environment=staging
+func NewPage(ac acl.AccessControl, ecl config.ETCDInterface, assets helpers.Assets) http.Handler {
+	return page{handler: handler{ac: ac, ecl: ecl}, assets: assets}
+}
+
+func (h page) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	u, c, proj, env, ok := h.environment(w, r)
+	if !ok {
+		return
+	}
+	t, err := template.New("vars.html").ParseFiles("templates/vars.html", "templates/base.html")
+	if err != nil {
+		glog.Errorf("Failed to parse templates: %v", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	js, css := h.assets.Templates()
+	params := map[string]interface{}{
+		"Javascript":      js,
+		"Stylesheet":      css,
+		"User":            u,
+		"Page":            "vars",
+		"Project":         proj.Name,
+		"Environment":     env.Name,
+		"RequireApproval": env.RequireApproval,
+		"Editable":        acl.Permitted(h.ac, c, proj, env, u, config.RoleDeployer),
+	}
+	helpers.RespondWithTemplate(w, "text/html", t, "base", params)
+}
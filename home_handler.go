@@ -6,7 +6,6 @@ import (
 	"os"
 	"sort"
 
-	"github.com/coreos/go-etcd/etcd"
 	"github.com/gengo/goship/lib/acl"
 	"github.com/gengo/goship/lib/auth"
 	"github.com/gengo/goship/lib/config"
@@ -18,7 +17,7 @@ import (
 // HomeHandler is the main home screen
 type HomeHandler struct {
 	ac     acl.AccessControl
-	ecl    *etcd.Client
+	ecl    config.ETCDInterface
 	assets helpers.Assets
 }
 
@@ -41,13 +40,20 @@ func (h HomeHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
-	projs := acl.ReadableProjects(h.ac, c.Projects, u)
+	prefs, err := config.LoadUserPreferences(h.ecl, u.Name)
+	if err != nil {
+		glog.Errorf("Failed to load preferences of %s: %v", u.Name, err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	projs := c.VisibleProjects(acl.ReadableProjects(h.ac, c.Projects, u), u.Name)
 
 	sort.Sort(ByName(c.Projects))
 
-	// columns maps a plugin name to a list of columns
-	columns := make(map[string][]plugin.Column)
+	// columns maps a project name to a map from a plugin name to a list of columns
+	columns := make(map[string]map[string][]plugin.Column)
 	for _, pl := range plugin.Plugins {
+		name := plugin.Name(pl)
 		for _, p := range c.Projects {
 			cols, err := pl.Apply(p)
 			if err != nil {
@@ -55,8 +61,25 @@ func (h HomeHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 				http.Error(w, err.Error(), http.StatusInternalServerError)
 				return
 			}
-			columns[p.Name] = append(columns[p.Name], cols...)
+			if columns[p.Name] == nil {
+				columns[p.Name] = make(map[string][]plugin.Column)
+			}
+			columns[p.Name][name] = append(columns[p.Name][name], cols...)
+		}
+	}
+	// pipelines maps a project name to the states of the stages in its pipeline
+	pipelines := make(map[string][]pipelineStage)
+	for _, p := range projs {
+		if len(p.Pipeline) == 0 {
+			continue
+		}
+		stages, err := pipelineStages(p)
+		if err != nil {
+			// the rest of the home page is still useful
+			glog.Errorf("Failed to get the pipeline of %s: %v", p.Name, err)
+			continue
 		}
+		pipelines[p.Name] = stages
 	}
 	js, css := h.assets.Templates()
 	gt := os.Getenv(gitHubAPITokenEnvVar)
@@ -70,6 +93,9 @@ func (h HomeHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		"Stylesheet":        css,
 		"Projects":          projs,
 		"PluginColumns":     columns,
+		"Pipelines":         pipelines,
+		"Columns":           homeColumns(c, prefs),
+		"AvailableColumns":  availableColumns(),
 		"User":              u,
 		"Page":              "home",
 		"ConfirmDeployFlag": *confirmDeployFlag,
@@ -79,6 +105,59 @@ func (h HomeHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	helpers.RespondWithTemplate(w, "text/html", t, "base", params)
 }
 
+// Names of the standard columns in the home page.
+const (
+	columnEnvironment      = "environment"
+	columnHosts            = "hosts"
+	columnDeployedRevision = "deployed_revision"
+	columnDeploy           = "deploy"
+	columnComment          = "comment"
+)
+
+// availableColumns returns the names of all the columns which the home page can show, in the default order.
+// Columns of plugins are placed between "hosts" and "deployed_revision".
+func availableColumns() []string {
+	cols := []string{columnEnvironment, columnHosts}
+	seen := make(map[string]bool)
+	for _, pl := range plugin.Plugins {
+		name := plugin.Name(pl)
+		if !seen[name] {
+			cols = append(cols, name)
+			seen[name] = true
+		}
+	}
+	return append(cols, columnDeployedRevision, columnDeploy, columnComment)
+}
+
+// homeColumns returns the names of the columns in the home page in order.
+// The preferences of the user take priority over the installation-wide configuration.
+func homeColumns(c config.Config, prefs config.UserPreferences) []string {
+	avail := availableColumns()
+	cols := avail
+	if len(c.HomeColumns) > 0 {
+		cols = c.HomeColumns
+	}
+	if len(prefs.HomeColumns) > 0 {
+		cols = prefs.HomeColumns
+	}
+
+	known := make(map[string]bool)
+	for _, col := range avail {
+		known[col] = true
+	}
+	var result []string
+	for _, col := range cols {
+		if !known[col] {
+			glog.V(1).Infof("Skipping unknown column %q", col)
+			continue
+		}
+		result = append(result, col)
+		// show each column at most once
+		known[col] = false
+	}
+	return result
+}
+
 // ByName is the interface for sorting projects
 type ByName []config.Project
 
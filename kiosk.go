@@ -0,0 +1,134 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gengo/goship/lib/auth"
+	"github.com/gengo/goship/lib/config"
+	"github.com/gengo/goship/lib/notification"
+	"github.com/golang/glog"
+)
+
+const (
+	// kioskCookie keeps the kiosk token of a wall display.
+	kioskCookie = "goship_kiosk"
+	// kioskCookieAge is how long wall displays keep their kiosk tokens. Revoked tokens stop working immediately regardless.
+	kioskCookieAge = 365 * 24 * time.Hour
+)
+
+// errKioskRevoked is returned when a wall display sends a kiosk token which is unknown or revoked.
+var errKioskRevoked = errors.New("kiosk token revoked")
+
+// kioskPath returns true if wall displays with kiosk tokens can read "path".
+// They are the dashboard, the deploy logs and the live status of deployments.
+// Outputs of deployments are excluded, and kioskEvents streams only the progress of deployments in the scopes of the tokens.
+// Displays are redirected to the login page everywhere else.
+func kioskPath(path string) bool {
+	switch path {
+	case "/", "/events", "/api/progress":
+		return true
+	}
+	return strings.HasPrefix(path, "/deployLog/")
+}
+
+// kioskUser returns a function which returns the read-only user of the kiosk token in the cookie of a request, which is kept in "ecl".
+// Only GET requests to kiosk paths are authenticated by kiosk tokens.
+func kioskUser(ecl config.ETCDInterface) func(*http.Request) (auth.User, bool, error) {
+	return func(r *http.Request) (auth.User, bool, error) {
+		cookie, err := r.Cookie(kioskCookie)
+		if err != nil || (r.Method != "GET" && r.Method != "HEAD") || !kioskPath(r.URL.Path) {
+			return auth.User{}, false, nil
+		}
+		t, err := config.FindKioskToken(ecl, cookie.Value)
+		if err != nil {
+			glog.Errorf("Failed to load kiosk tokens: %v", err)
+			return auth.User{}, true, err
+		}
+		if t == nil {
+			return auth.User{}, true, errKioskRevoked
+		}
+		return auth.User{Name: t.UserName()}, true, nil
+	}
+}
+
+// kioskEvents returns a function which streams the messages of "hub" like Hub.ServeEvents.
+// Wall displays with kiosk tokens in "ecl" receive only the progress of deployments in the projects which their tokens cover.
+func kioskEvents(ecl config.ETCDInterface, hub *notification.Hub) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		u, err := auth.CurrentUser(r)
+		if err != nil {
+			glog.Errorf("Failed to get current user: %v", err)
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+			return
+		}
+		name, ok := config.KioskTokenName(u.Name)
+		if !ok {
+			hub.ServeEvents(w, r)
+			return
+		}
+		t, err := config.LoadKioskToken(ecl, name)
+		if err != nil {
+			glog.Errorf("Failed to load kiosk token %s: %v", name, err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if t == nil {
+			http.Error(w, errKioskRevoked.Error(), http.StatusForbidden)
+			return
+		}
+		hub.ServeFilteredEvents(w, r, func(msg string) bool { return kioskMessage(*t, msg) })
+	}
+}
+
+// kioskMessage returns true if wall displays with "t" can receive "msg" broadcast to web clients:
+// it must be about a project which "t" covers, and must not be a line of deploy outputs.
+func kioskMessage(t config.KioskToken, msg string) bool {
+	var m struct {
+		Project    string
+		StdoutLine *string
+	}
+	if err := json.Unmarshal([]byte(msg), &m); err != nil {
+		return false
+	}
+	return m.Project != "" && m.StdoutLine == nil && t.Covers(m.Project)
+}
+
+// KioskHandler starts showing the dashboard on a wall display with the kiosk token in "token".
+// It keeps the token in a cookie instead of starting a user session, and redirects to the dashboard.
+//
+// e.g. http://127.0.0.1:8000/kiosk?token=0123456789abcdef
+type KioskHandler struct {
+	ecl config.ETCDInterface
+}
+
+func (h KioskHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	secret := r.FormValue("token")
+	t, err := config.FindKioskToken(h.ecl, secret)
+	if err != nil {
+		glog.Errorf("Failed to load kiosk tokens: %v", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if t == nil {
+		http.Error(w, errKioskRevoked.Error(), http.StatusForbidden)
+		return
+	}
+	glog.Infof("Started a wall display with kiosk token %s from %s", t.Name, r.RemoteAddr)
+	http.SetCookie(w, &http.Cookie{
+		Name:     kioskCookie,
+		Value:    secret,
+		Path:     "/",
+		MaxAge:   int(kioskCookieAge / time.Second),
+		Secure:   tlsEnabled(),
+		HttpOnly: true,
+	})
+	http.Redirect(w, r, "/", http.StatusSeeOther)
+}
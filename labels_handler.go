@@ -0,0 +1,126 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/gengo/goship/lib/acl"
+	"github.com/gengo/goship/lib/audit"
+	"github.com/gengo/goship/lib/auth"
+	"github.com/gengo/goship/lib/config"
+	deploypkg "github.com/gengo/goship/lib/deploy"
+	"github.com/golang/glog"
+)
+
+var errNoSuchEntry = errors.New("no such deployment in the deploy log")
+
+// LabelsHandler replaces the labels of a past deployment in the deploy log.
+// The deployment is identified by its time in RFC 3339.
+// Only deployers of the environment can change the labels.
+//
+// e.g. POST http://127.0.0.1:8000/labels?project=admin&environment=staging&time=2015-11-10T23:00:00Z&labels=hotfix,schema-change
+type LabelsHandler struct {
+	ac  acl.AccessControl
+	ecl config.ETCDInterface
+}
+
+func (h LabelsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	u, err := auth.CurrentUser(r)
+	if err != nil {
+		glog.Errorf("Failed to get current user: %v", err)
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return
+	}
+	projName, envName := r.FormValue("project"), r.FormValue("environment")
+	t, err := time.Parse(time.RFC3339Nano, r.FormValue("time"))
+	if err != nil {
+		http.Error(w, fmt.Sprintf("invalid time %q", r.FormValue("time")), http.StatusBadRequest)
+		return
+	}
+	labels, err := deploypkg.ParseLabels(r.FormValue("labels"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	c, err := config.Load(h.ecl)
+	if err != nil {
+		glog.Errorf("Failed to load configuration: %v", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	proj, err := config.ProjectFromName(c.Projects, projName)
+	if err != nil {
+		http.Error(w, "no such project", http.StatusNotFound)
+		return
+	}
+	e, err := config.EnvironmentFromName(c.Projects, projName, envName)
+	if err != nil {
+		http.Error(w, "no such project/environment", http.StatusNotFound)
+		return
+	}
+	if !acl.Permitted(h.ac, c, proj, *e, u, config.RoleDeployer) {
+		glog.Errorf("%s is not allowed to label deployments of %s-%s", u.Name, projName, envName)
+		http.Error(w, "not allowed to label deployments of the environment", http.StatusForbidden)
+		return
+	}
+
+	env := fmt.Sprintf("%s-%s", projName, envName)
+	err = updateEntries(env, func(entries []DeployLogEntry) ([]DeployLogEntry, error) {
+		for i := range entries {
+			if entries[i].Time.Equal(t) {
+				entries[i].Labels = labels
+				return entries, nil
+			}
+		}
+		return nil, errNoSuchEntry
+	})
+	rec := audit.Record{
+		Actor:       u.Name,
+		Action:      audit.ActionLabelsChanged,
+		Project:     projName,
+		Environment: envName,
+		Result:      audit.ResultSuccess,
+		Detail:      fmt.Sprintf("deployment at %s: %s", t.Format(time.RFC3339), strings.Join(labels, ", ")),
+	}
+	if err != nil {
+		rec.Result = audit.ResultFailure
+	}
+	if err := audit.Append(h.ecl, rec); err != nil {
+		glog.Errorf("Failed to record labels of %s in the audit log: %v", env, err)
+	}
+	if err == errNoSuchEntry {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		glog.Errorf("Failed to update labels of %s: %v", env, err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	http.Redirect(w, r, "/deployLog/"+env, http.StatusSeeOther)
+}
+
+// hasDeployment returns true if the deploy log of "envName" of "projName" has the deployment at "t".
+func hasDeployment(projName, envName string, t time.Time) (bool, error) {
+	entries, err := readEntries(fmt.Sprintf("%s-%s", projName, envName))
+	if os.IsNotExist(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	for _, e := range entries {
+		if e.Time.Equal(t) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
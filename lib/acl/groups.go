@@ -0,0 +1,73 @@
+package acl
+
+import (
+	"github.com/gengo/goship/lib/config"
+	"github.com/golang/glog"
+)
+
+type groupAccessControl struct {
+	ecl config.ETCDInterface
+}
+
+// NewByGroups returns an AccessControl which allows users by the groups given by the identity provider, e.g. OpenID Connect or LDAP.
+// The groups of a user are stored in "ecl" when the user logs in.
+// Projects without Access are readable and deployable by all the users.
+func NewByGroups(ecl config.ETCDInterface) AccessControl {
+	return groupAccessControl{ecl: ecl}
+}
+
+// access returns the Access of the project of "$owner/$repo" and the groups of "user".
+// It returns false if the project or the groups are not found.
+func (a groupAccessControl) access(owner, repo, user string) (*config.Access, []string, bool) {
+	c, err := config.Load(a.ecl)
+	if err != nil {
+		glog.Errorf("Failed to load configuration: %v", err)
+		return nil, nil, false
+	}
+	for _, p := range c.Projects {
+		r := p.SourceRepo()
+		if r.RepoOwner != owner || r.RepoName != repo {
+			continue
+		}
+		if p.Access == nil {
+			return nil, nil, true
+		}
+		groups, err := config.LoadUserGroups(a.ecl, user)
+		if err != nil {
+			glog.Errorf("Failed to load groups of %s: %v", user, err)
+			return nil, nil, false
+		}
+		return p.Access, groups, true
+	}
+	glog.Errorf("No project found for %s/%s", owner, repo)
+	return nil, nil, false
+}
+
+// Readable determines if "user" belongs to any of the readers or the deployers of the repository "$owner/$repo".
+func (a groupAccessControl) Readable(owner, repo, user string) bool {
+	access, groups, ok := a.access(owner, repo, user)
+	if !ok {
+		return false
+	}
+	return access == nil || intersects(access.Readers, groups) || intersects(access.Deployers, groups)
+}
+
+// Deployable determines if "user" belongs to any of the deployers of the repository "$owner/$repo".
+func (a groupAccessControl) Deployable(owner, repo, user string) bool {
+	access, groups, ok := a.access(owner, repo, user)
+	if !ok {
+		return false
+	}
+	return access == nil || intersects(access.Deployers, groups)
+}
+
+func intersects(a, b []string) bool {
+	for _, x := range a {
+		for _, y := range b {
+			if x == y {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,59 @@
+package acl_test
+
+import (
+	"testing"
+
+	"github.com/gengo/goship/lib/acl"
+	"github.com/gengo/goship/lib/config"
+)
+
+func TestByGroups(t *testing.T) {
+	s := config.NewMemoryStore()
+	cfg := config.Config{
+		Projects: []config.Project{
+			{
+				Name:         "restricted",
+				Repo:         config.Repo{RepoOwner: "owner", RepoName: "restricted"},
+				Access:       &config.Access{Readers: []string{"developers"}, Deployers: []string{"ops"}},
+				Environments: []config.Environment{{Name: "production"}},
+			},
+			{
+				Name:         "open",
+				Repo:         config.Repo{RepoOwner: "owner", RepoName: "open"},
+				Environments: []config.Environment{{Name: "production"}},
+			},
+		},
+	}
+	if err := config.Store(s, cfg); err != nil {
+		t.Fatalf("config.Store(s, %#v) failed with %v; want success", cfg, err)
+	}
+	for user, groups := range map[string][]string{
+		"dev":      {"developers"},
+		"operator": {"ops", "developers"},
+		"intern":   {"interns"},
+	} {
+		if err := config.StoreUserGroups(s, user, groups); err != nil {
+			t.Fatalf("config.StoreUserGroups(s, %q, %q) failed with %v; want success", user, groups, err)
+		}
+	}
+	ac := acl.NewByGroups(s)
+
+	for _, spec := range []struct {
+		repo, user           string
+		readable, deployable bool
+	}{
+		{repo: "restricted", user: "dev", readable: true},
+		{repo: "restricted", user: "operator", readable: true, deployable: true},
+		{repo: "restricted", user: "intern"},
+		{repo: "restricted", user: "unknown"},
+		{repo: "open", user: "intern", readable: true, deployable: true},
+		{repo: "no-such-repo", user: "operator"},
+	} {
+		if got := ac.Readable("owner", spec.repo, spec.user); got != spec.readable {
+			t.Errorf("ac.Readable(%q, %q, %q) = %t; want %t", "owner", spec.repo, spec.user, got, spec.readable)
+		}
+		if got := ac.Deployable("owner", spec.repo, spec.user); got != spec.deployable {
+			t.Errorf("ac.Deployable(%q, %q, %q) = %t; want %t", "owner", spec.repo, spec.user, got, spec.deployable)
+		}
+	}
+}
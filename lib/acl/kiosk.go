@@ -0,0 +1,52 @@
+package acl
+
+import (
+	"github.com/gengo/goship/lib/config"
+	"github.com/golang/glog"
+)
+
+type kioskAccessControl struct {
+	AccessControl
+	ecl config.ETCDInterface
+}
+
+// WithKiosks returns an AccessControl which allows the users of kiosk tokens in "ecl" to read the projects in the scopes of the tokens,
+// and the other users as "a" does.
+// Kiosks can never deploy, and they can read a repository if any project of the repository is in the scope.
+func WithKiosks(a AccessControl, ecl config.ETCDInterface) AccessControl {
+	return kioskAccessControl{AccessControl: a, ecl: ecl}
+}
+
+func (a kioskAccessControl) Readable(owner, repo, user string) bool {
+	name, ok := config.KioskTokenName(user)
+	if !ok {
+		return a.AccessControl.Readable(owner, repo, user)
+	}
+	t, err := config.LoadKioskToken(a.ecl, name)
+	if err != nil {
+		glog.Errorf("Failed to load kiosk token %s: %v", name, err)
+		return false
+	}
+	if t == nil {
+		return false
+	}
+	c, err := config.Load(a.ecl)
+	if err != nil {
+		glog.Errorf("Failed to load configuration: %v", err)
+		return false
+	}
+	for _, p := range c.Projects {
+		r := p.SourceRepo()
+		if r.RepoOwner == owner && r.RepoName == repo && t.Covers(p.Name) {
+			return true
+		}
+	}
+	return false
+}
+
+func (a kioskAccessControl) Deployable(owner, repo, user string) bool {
+	if _, ok := config.KioskTokenName(user); ok {
+		return false
+	}
+	return a.AccessControl.Deployable(owner, repo, user)
+}
@@ -0,0 +1,60 @@
+package acl_test
+
+import (
+	"testing"
+
+	"github.com/gengo/goship/lib/acl"
+	"github.com/gengo/goship/lib/config"
+)
+
+func TestWithKiosks(t *testing.T) {
+	s := config.NewMemoryStore()
+	cfg := config.Config{
+		Projects: []config.Project{
+			{
+				Name:         "shown",
+				Repo:         config.Repo{RepoOwner: "owner", RepoName: "shown"},
+				Environments: []config.Environment{{Name: "production"}},
+			},
+			{
+				Name:         "hidden",
+				Repo:         config.Repo{RepoOwner: "owner", RepoName: "hidden"},
+				Environments: []config.Environment{{Name: "production"}},
+			},
+		},
+	}
+	if err := config.Store(s, cfg); err != nil {
+		t.Fatalf("config.Store(s, %#v) failed with %v; want success", cfg, err)
+	}
+	scoped, _, err := config.CreateKioskToken(s, "office-wall", []string{"shown"}, "admin")
+	if err != nil {
+		t.Fatalf("config.CreateKioskToken failed with %v; want success", err)
+	}
+	all, _, err := config.CreateKioskToken(s, "lobby", nil, "admin")
+	if err != nil {
+		t.Fatalf("config.CreateKioskToken failed with %v; want success", err)
+	}
+
+	ac := acl.WithKiosks(acl.Null, s)
+	for _, spec := range []struct {
+		user, repo string
+		readable   bool
+	}{
+		{user: scoped.UserName(), repo: "shown", readable: true},
+		{user: scoped.UserName(), repo: "hidden", readable: false},
+		{user: scoped.UserName(), repo: "unknown", readable: false},
+		{user: all.UserName(), repo: "hidden", readable: true},
+		{user: "kiosk:revoked", repo: "shown", readable: false},
+		{user: "alice", repo: "hidden", readable: true},
+	} {
+		if got := ac.Readable("owner", spec.repo, spec.user); got != spec.readable {
+			t.Errorf("ac.Readable(%q, %q, %q) = %t; want %t", "owner", spec.repo, spec.user, got, spec.readable)
+		}
+	}
+	if ac.Deployable("owner", "shown", scoped.UserName()) {
+		t.Errorf("ac.Deployable(%q, %q, %q) = true; want false", "owner", "shown", scoped.UserName())
+	}
+	if !ac.Deployable("owner", "shown", "alice") {
+		t.Errorf("ac.Deployable(%q, %q, %q) = false; want true", "owner", "shown", "alice")
+	}
+}
@@ -0,0 +1,33 @@
+package acl
+
+import (
+	"github.com/gengo/goship/lib/auth"
+	"github.com/gengo/goship/lib/config"
+	"github.com/golang/glog"
+)
+
+// Permitted determines if "u" has "want" or a higher role in "env" of "proj".
+//
+// Users must be able to read the repository of "proj", and admins in "c" have every role.
+// Without role bindings in "proj" nor "env", deployers of the repository are admins and others are viewers.
+func Permitted(a AccessControl, c config.Config, proj config.Project, env config.Environment, u auth.User, want config.Role) bool {
+	repo := proj.SourceRepo()
+	if !a.Readable(repo.RepoOwner, repo.RepoName, u.Name) {
+		return false
+	}
+	if c.IsAdmin(u.Name) {
+		return true
+	}
+	role, ok := proj.RoleOf(env, u.Name, u.Groups)
+	if !ok {
+		role = config.RoleViewer
+		if a.Deployable(repo.RepoOwner, repo.RepoName, u.Name) {
+			role = config.RoleAdmin
+		}
+	}
+	if !role.Includes(want) {
+		glog.V(1).Infof("%s is %q in %s of %s; %q is required", u.Name, role, env.Name, proj.Name, want)
+		return false
+	}
+	return true
+}
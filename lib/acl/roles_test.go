@@ -0,0 +1,67 @@
+package acl_test
+
+import (
+	"testing"
+
+	"github.com/gengo/goship/lib/acl"
+	"github.com/gengo/goship/lib/auth"
+	"github.com/gengo/goship/lib/config"
+)
+
+// deployers is an AccessControl which allows everyone to read and only "deployer" to deploy.
+type deployers struct{}
+
+func (deployers) Readable(owner, repo, user string) bool   { return true }
+func (deployers) Deployable(owner, repo, user string) bool { return user == "deployer" }
+
+func TestPermitted(t *testing.T) {
+	c := config.Config{Admins: []string{"root"}}
+	staging := config.Environment{Name: "staging"}
+	production := config.Environment{
+		Name: "production",
+		Roles: []config.RoleBinding{
+			{Role: config.RoleViewer, Users: []string{"*"}},
+			{Role: config.RoleDeployer, Groups: []string{"ops"}},
+			{Role: config.RoleAdmin, Users: []string{"lead"}},
+		},
+	}
+	proj := config.Project{
+		Name:         "proj",
+		Roles:        []config.RoleBinding{{Role: config.RoleDeployer, Users: []string{"*"}}},
+		Environments: []config.Environment{staging, production},
+	}
+	legacy := config.Project{Name: "legacy", Environments: []config.Environment{staging}}
+
+	for _, spec := range []struct {
+		proj config.Project
+		env  config.Environment
+		user auth.User
+		want config.Role
+		ok   bool
+	}{
+		{proj: proj, env: staging, user: auth.User{Name: "dev"}, want: config.RoleDeployer, ok: true},
+		{proj: proj, env: staging, user: auth.User{Name: "dev"}, want: config.RoleAdmin, ok: false},
+		{proj: proj, env: production, user: auth.User{Name: "dev"}, want: config.RoleViewer, ok: true},
+		{proj: proj, env: production, user: auth.User{Name: "dev"}, want: config.RoleDeployer, ok: false},
+		{proj: proj, env: production, user: auth.User{Name: "operator", Groups: []string{"ops"}}, want: config.RoleDeployer, ok: true},
+		{proj: proj, env: production, user: auth.User{Name: "operator", Groups: []string{"ops"}}, want: config.RoleAdmin, ok: false},
+		{proj: proj, env: production, user: auth.User{Name: "lead"}, want: config.RoleAdmin, ok: true},
+		{proj: proj, env: production, user: auth.User{Name: "root"}, want: config.RoleAdmin, ok: true},
+		{proj: legacy, env: staging, user: auth.User{Name: "deployer"}, want: config.RoleAdmin, ok: true},
+		{proj: legacy, env: staging, user: auth.User{Name: "dev"}, want: config.RoleViewer, ok: true},
+		{proj: legacy, env: staging, user: auth.User{Name: "dev"}, want: config.RoleDeployer, ok: false},
+	} {
+		if got := acl.Permitted(deployers{}, c, spec.proj, spec.env, spec.user, spec.want); got != spec.ok {
+			t.Errorf("acl.Permitted(ac, c, %q, %q, %#v, %q) = %t; want %t", spec.proj.Name, spec.env.Name, spec.user, spec.want, got, spec.ok)
+		}
+	}
+	if acl.Permitted(nobody{}, c, proj, staging, auth.User{Name: "root"}, config.RoleViewer) {
+		t.Errorf("acl.Permitted allowed a user who cannot read the repository; want denial")
+	}
+}
+
+// nobody is an AccessControl which allows no one.
+type nobody struct{}
+
+func (nobody) Readable(owner, repo, user string) bool   { return false }
+func (nobody) Deployable(owner, repo, user string) bool { return false }
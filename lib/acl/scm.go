@@ -0,0 +1,54 @@
+package acl
+
+import (
+	"github.com/gengo/goship/lib/config"
+	"github.com/golang/glog"
+)
+
+type scmAccessControl struct {
+	acls map[config.SCMType]AccessControl
+	ecl  config.ETCDInterface
+}
+
+// NewBySCM returns an AccessControl which delegates permission checks on a repository to the AccessControl of the source code management service hosting the repository.
+// "acls" maps types of source code management services to their AccessControls.
+// The service of a repository is determined by the project configuration in "ecl".
+func NewBySCM(acls map[config.SCMType]AccessControl, ecl config.ETCDInterface) AccessControl {
+	return scmAccessControl{acls: acls, ecl: ecl}
+}
+
+// lookup returns the AccessControl of the service which hosts "$owner/$repo".
+// It returns nil if the service is unknown or not configured.
+func (a scmAccessControl) lookup(owner, repo string) AccessControl {
+	c, err := config.Load(a.ecl)
+	if err != nil {
+		glog.Errorf("Failed to load configuration: %v", err)
+		return nil
+	}
+	for _, p := range c.Projects {
+		r := p.SourceRepo()
+		if r.RepoOwner != owner || r.RepoName != repo {
+			continue
+		}
+		ac, ok := a.acls[p.SCM]
+		if !ok {
+			glog.Errorf("No access control configured for %s, which hosts %s/%s", p.SCM, owner, repo)
+			return nil
+		}
+		return ac
+	}
+	glog.Errorf("No project found for %s/%s", owner, repo)
+	return nil
+}
+
+// Readable determines if "user" has read permission on the repository "$owner/$repo" in the service hosting it.
+func (a scmAccessControl) Readable(owner, repo, user string) bool {
+	ac := a.lookup(owner, repo)
+	return ac != nil && ac.Readable(owner, repo, user)
+}
+
+// Deployable determines if "user" has write permission on the repository "$owner/$repo" in the service hosting it.
+func (a scmAccessControl) Deployable(owner, repo, user string) bool {
+	ac := a.lookup(owner, repo)
+	return ac != nil && ac.Deployable(owner, repo, user)
+}
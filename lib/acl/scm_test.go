@@ -0,0 +1,73 @@
+package acl_test
+
+import (
+	"testing"
+
+	"github.com/gengo/goship/lib/acl"
+	"github.com/gengo/goship/lib/config"
+)
+
+// userAccessControl allows only "user" to read and deploy.
+type userAccessControl string
+
+func (u userAccessControl) Readable(owner, repo, user string) bool   { return user == string(u) }
+func (u userAccessControl) Deployable(owner, repo, user string) bool { return user == string(u) }
+
+func TestBySCM(t *testing.T) {
+	s := config.NewMemoryStore()
+	cfg := config.Config{
+		Projects: []config.Project{
+			{
+				Name: "on-github",
+				Repo: config.Repo{RepoOwner: "owner", RepoName: "github-repo"},
+				SCM:  config.SCMGithub,
+				Environments: []config.Environment{
+					{Name: "production"},
+				},
+			},
+			{
+				Name: "on-gitlab",
+				Repo: config.Repo{RepoOwner: "owner", RepoName: "gitlab-repo"},
+				SCM:  config.SCMGitLab,
+				Environments: []config.Environment{
+					{Name: "production"},
+				},
+			},
+			{
+				Name: "on-bitbucket",
+				Repo: config.Repo{RepoOwner: "owner", RepoName: "bitbucket-repo"},
+				SCM:  config.SCMBitbucket,
+				Environments: []config.Environment{
+					{Name: "production"},
+				},
+			},
+		},
+	}
+	if err := config.Store(s, cfg); err != nil {
+		t.Fatalf("config.Store(s, %#v) failed with %v; want success", cfg, err)
+	}
+	ac := acl.NewBySCM(map[config.SCMType]acl.AccessControl{
+		config.SCMGithub: userAccessControl("github-user"),
+		config.SCMGitLab: userAccessControl("gitlab-user"),
+	}, s)
+
+	for _, spec := range []struct {
+		repo, user string
+		want       bool
+	}{
+		{repo: "github-repo", user: "github-user", want: true},
+		{repo: "github-repo", user: "gitlab-user", want: false},
+		{repo: "gitlab-repo", user: "gitlab-user", want: true},
+		{repo: "gitlab-repo", user: "github-user", want: false},
+		// access control for bitbucket is not configured
+		{repo: "bitbucket-repo", user: "github-user", want: false},
+		{repo: "unknown-repo", user: "github-user", want: false},
+	} {
+		if got, want := ac.Readable("owner", spec.repo, spec.user), spec.want; got != want {
+			t.Errorf("ac.Readable(%q, %q, %q) = %v; want %v", "owner", spec.repo, spec.user, got, want)
+		}
+		if got, want := ac.Deployable("owner", spec.repo, spec.user), spec.want; got != want {
+			t.Errorf("ac.Deployable(%q, %q, %q) = %v; want %v", "owner", spec.repo, spec.user, got, want)
+		}
+	}
+}
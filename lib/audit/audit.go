@@ -0,0 +1,201 @@
+// Package audit records privileged actions in an append-only log in etcd.
+package audit
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/coreos/go-etcd/etcd"
+	"github.com/gengo/goship/lib/config"
+	"github.com/gengo/goship/lib/notification"
+	"github.com/gengo/goship/lib/revision"
+	"github.com/golang/glog"
+)
+
+// auditDir is the etcd directory of the records. Each record is stored in its own key and never modified.
+const auditDir = "/goship/audit"
+
+// Result is the outcome of an action.
+type Result string
+
+const (
+	// ResultSuccess means the action succeeded.
+	ResultSuccess = Result("success")
+	// ResultFailure means the action was attempted but failed.
+	ResultFailure = Result("failure")
+)
+
+// Actions which are not notified as events.
+const (
+	// ActionComment is recorded when the comment of an environment is changed.
+	ActionComment = "comment"
+	// ActionConfigStored is recorded when the configuration is replaced with goshipcfg.
+	ActionConfigStored = "config_stored"
+	// ActionProjectStored is recorded when a project is created or changed in the editor.
+	ActionProjectStored = "project_stored"
+	// ActionProjectDeleted is recorded when a project is deleted in the editor.
+	ActionProjectDeleted = "project_deleted"
+	// ActionConfigReloaded is recorded when an admin reloads the cached configuration.
+	ActionConfigReloaded = "config_reloaded"
+	// ActionLabelsChanged is recorded when labels of a past deployment are changed.
+	ActionLabelsChanged = "labels_changed"
+	// ActionUserDeactivated is recorded when an admin deactivates a user.
+	ActionUserDeactivated = "user_deactivated"
+	// ActionUserReactivated is recorded when an admin reactivates a user.
+	ActionUserReactivated = "user_reactivated"
+	// ActionUserProvisioned is recorded when the identity provider creates, changes or deletes a user with SCIM.
+	ActionUserProvisioned = "user_provisioned"
+	// ActionGroupProvisioned is recorded when the identity provider creates, changes or deletes a group with SCIM.
+	ActionGroupProvisioned = "group_provisioned"
+	// ActionVarsChanged is recorded when environment variables of an environment are changed, directly or by an approval.
+	ActionVarsChanged = "vars_changed"
+	// ActionVarsChangeRequested is recorded when a change of environment variables waits for an approval.
+	ActionVarsChangeRequested = "vars_change_requested"
+	// ActionVarsChangeRejected is recorded when a change of environment variables is rejected.
+	ActionVarsChangeRejected = "vars_change_rejected"
+	// ActionHostsImported is recorded when an admin adds hosts to environments of a project in bulk.
+	ActionHostsImported = "hosts_imported"
+	// ActionKeySet is recorded when an admin sets a raw key in etcd with the key browser.
+	ActionKeySet = "key_set"
+	// ActionKeyDeleted is recorded when an admin deletes a raw key in etcd with the key browser.
+	ActionKeyDeleted = "key_deleted"
+	// ActionKioskTokenCreated is recorded when an admin creates a kiosk token for a wall display.
+	ActionKioskTokenCreated = "kiosk_token_created"
+	// ActionKioskTokenRevoked is recorded when an admin revokes a kiosk token.
+	ActionKioskTokenRevoked = "kiosk_token_revoked"
+)
+
+// Record is a privileged action in the audit log.
+type Record struct {
+	Time time.Time `json:"time"`
+	// Actor is the name of the user who performed the action.
+	Actor string `json:"actor"`
+	// Action is the type of the notified event, or one of the actions defined in this package.
+	Action string `json:"action"`
+	// Project and Environment are empty for actions on the whole installation.
+	Project     string `json:"project,omitempty"`
+	Environment string `json:"environment,omitempty"`
+	// Revision is the revision deployed, pinned or rolled back to, if any.
+	Revision revision.Revision `json:"revision,omitempty"`
+	Result   Result            `json:"result"`
+	// Detail is an optional human-readable description, e.g. a reason given by the actor.
+	Detail string `json:"detail,omitempty"`
+}
+
+// FromEvent returns a record of "ev".
+func FromEvent(ev notification.Event) Record {
+	r := Record{
+		Time:        ev.Time,
+		Actor:       ev.User,
+		Action:      string(ev.Type),
+		Project:     ev.Project,
+		Environment: ev.Environment,
+		Revision:    ev.To,
+		Result:      ResultSuccess,
+		Detail:      ev.Message(),
+	}
+	switch ev.Type {
+	case notification.DeployFailed, notification.RestartFailed, notification.ActionFailed, notification.CommandFailed:
+		r.Result = ResultFailure
+	}
+	return r
+}
+
+var (
+	// mu serializes Append in this process so that records get distinct keys.
+	mu sync.Mutex
+	// last is the key of the latest record appended in this process.
+	last int64
+)
+
+// Append adds "r" to the audit log in "client".
+func Append(client config.ETCDInterface, r Record) error {
+	if r.Time.IsZero() {
+		r.Time = time.Now()
+	}
+	buf, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	id := r.Time.UnixNano()
+	if id <= last {
+		id = last + 1
+	}
+	last = id
+	_, err = client.Set(fmt.Sprintf("%s/%020d", auditDir, id), string(buf), 0)
+	return err
+}
+
+// AppendChange adds a record of "action" by "user" on "detail" to the audit log in "client".
+// The record is a failure with "err" in its detail if "err" is not nil.
+// Failures to append are logged instead of returned so that they do not fail the change itself.
+func AppendChange(client config.ETCDInterface, action, user, detail string, err error) {
+	r := Record{Actor: user, Action: action, Result: ResultSuccess, Detail: detail}
+	if err != nil {
+		r.Result, r.Detail = ResultFailure, fmt.Sprintf("%s: %v", detail, err)
+	}
+	if err := Append(client, r); err != nil {
+		glog.Errorf("Failed to record %s of %s in the audit log: %v", action, detail, err)
+	}
+}
+
+// Load returns all the records in the audit log in "client", newest first.
+func Load(client config.ETCDInterface) ([]Record, error) {
+	nodes, err := loadNodes(client)
+	if err != nil {
+		return nil, err
+	}
+	records := make([]Record, 0, len(nodes))
+	for i := len(nodes) - 1; i >= 0; i-- {
+		n := nodes[i]
+		var r Record
+		if err := json.Unmarshal([]byte(n.Value), &r); err != nil {
+			return nil, fmt.Errorf("invalid audit record %s: %v", n.Key, err)
+		}
+		records = append(records, r)
+	}
+	return records, nil
+}
+
+// Filter selects records by their fields. Empty fields match any record.
+type Filter struct {
+	Actor       string
+	Action      string
+	Project     string
+	Environment string
+}
+
+// Match returns true if "r" satisfies "f".
+func (f Filter) Match(r Record) bool {
+	return (f.Actor == "" || f.Actor == r.Actor) &&
+		(f.Action == "" || f.Action == r.Action) &&
+		(f.Project == "" || f.Project == r.Project) &&
+		(f.Environment == "" || f.Environment == r.Environment)
+}
+
+// Page returns the "page"-th slice of at most "perPage" records in "records", where "page" is 1-origin.
+// It also returns true if there are more records after the page.
+func Page(records []Record, page, perPage int) ([]Record, bool) {
+	if page < 1 || perPage < 1 {
+		return nil, false
+	}
+	start := (page - 1) * perPage
+	if start >= len(records) {
+		return nil, false
+	}
+	end := start + perPage
+	if end >= len(records) {
+		return records[start:], false
+	}
+	return records[start:end], true
+}
+
+type byKey []*etcd.Node
+
+func (ns byKey) Len() int           { return len(ns) }
+func (ns byKey) Swap(i, j int)      { ns[i], ns[j] = ns[j], ns[i] }
+func (ns byKey) Less(i, j int) bool { return ns[i].Key < ns[j].Key }
@@ -0,0 +1,114 @@
+package audit
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/gengo/goship/lib/config"
+	"github.com/gengo/goship/lib/notification"
+)
+
+func TestAppendAndLoad(t *testing.T) {
+	s := config.NewMemoryStore()
+	now := time.Now().UTC().Truncate(time.Second)
+	records := []Record{
+		{Time: now, Actor: "alice", Action: string(notification.DeployStarted), Project: "proj", Environment: "production", Revision: "abc123", Result: ResultSuccess},
+		// same time as the previous record
+		{Time: now, Actor: "alice", Action: string(notification.DeployFailed), Project: "proj", Environment: "production", Revision: "abc123", Result: ResultFailure},
+		{Time: now.Add(time.Minute), Actor: "bob", Action: ActionConfigStored, Result: ResultSuccess},
+	}
+	for _, r := range records {
+		if err := Append(s, r); err != nil {
+			t.Fatalf("Append(s, %#v) failed with %v; want success", r, err)
+		}
+	}
+
+	got, err := Load(s)
+	if err != nil {
+		t.Fatalf("Load(s) failed with %v; want success", err)
+	}
+	want := []Record{records[2], records[1], records[0]}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Load(s) = %#v; want %#v", got, want)
+	}
+}
+
+func TestLoadEmpty(t *testing.T) {
+	got, err := Load(config.NewMemoryStore())
+	if err != nil || len(got) != 0 {
+		t.Errorf("Load(s) = %#v, %v; want no records", got, err)
+	}
+}
+
+func TestAppendChange(t *testing.T) {
+	s := config.NewMemoryStore()
+	AppendChange(s, ActionKeySet, "alice", "/goship/config", nil)
+	AppendChange(s, ActionKeyDeleted, "bob", "/goship/locks/admin", errors.New("etcd is down"))
+
+	got, err := Load(s)
+	if err != nil {
+		t.Fatalf("Load(s) failed with %v; want success", err)
+	}
+	want := []Record{
+		{Actor: "bob", Action: ActionKeyDeleted, Result: ResultFailure, Detail: "/goship/locks/admin: etcd is down"},
+		{Actor: "alice", Action: ActionKeySet, Result: ResultSuccess, Detail: "/goship/config"},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("Load(s) = %#v; want %d records", got, len(want))
+	}
+	for i := range got {
+		got[i].Time = time.Time{}
+		if !reflect.DeepEqual(got[i], want[i]) {
+			t.Errorf("Load(s)[%d] = %#v; want %#v", i, got[i], want[i])
+		}
+	}
+}
+
+func TestFromEvent(t *testing.T) {
+	now := time.Now()
+	ev := notification.Event{
+		Type:        notification.DeployFailed,
+		Project:     "proj",
+		Environment: "production",
+		User:        "alice",
+		From:        "abc000",
+		To:          "abc123",
+		Time:        now,
+	}
+	got := FromEvent(ev)
+	want := Record{
+		Time:        now,
+		Actor:       "alice",
+		Action:      "deploy_failed",
+		Project:     "proj",
+		Environment: "production",
+		Revision:    "abc123",
+		Result:      ResultFailure,
+		Detail:      ev.Message(),
+	}
+	if got != want {
+		t.Errorf("FromEvent(%#v) = %#v; want %#v", ev, got, want)
+	}
+}
+
+func TestPage(t *testing.T) {
+	records := []Record{{Actor: "a"}, {Actor: "b"}, {Actor: "c"}}
+	for _, spec := range []struct {
+		page, perPage int
+		want          []Record
+		more          bool
+	}{
+		{page: 1, perPage: 2, want: records[:2], more: true},
+		{page: 2, perPage: 2, want: records[2:]},
+		{page: 1, perPage: 3, want: records},
+		{page: 3, perPage: 2},
+		{page: 0, perPage: 2},
+	} {
+		got, more := Page(records, spec.page, spec.perPage)
+		if !reflect.DeepEqual(got, spec.want) || more != spec.more {
+			t.Errorf("Page(records, %d, %d) = %#v, %v; want %#v, %v", spec.page, spec.perPage, got, more, spec.want, spec.more)
+		}
+	}
+}
@@ -0,0 +1,279 @@
+package audit
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/coreos/go-etcd/etcd"
+	"github.com/gengo/goship/lib/config"
+)
+
+const (
+	// exportCursorKey is the etcd key of the key of the last record delivered to the exporter.
+	exportCursorKey = "/goship/audit-export/cursor"
+	// exportBatchSize is the maximum number of records sent to a Sink at once.
+	exportBatchSize = 100
+)
+
+// Format is a serialization of records for SIEM.
+type Format string
+
+const (
+	// FormatJSON serializes each record as a JSON object.
+	FormatJSON = Format("json")
+	// FormatCEF serializes each record in ArcSight Common Event Format.
+	FormatCEF = Format("cef")
+)
+
+// Encode returns "r" serialized in "f" without trailing newline.
+func (f Format) Encode(r Record) (string, error) {
+	switch f {
+	case FormatJSON:
+		buf, err := json.Marshal(r)
+		return string(buf), err
+	case FormatCEF:
+		return cef(r), nil
+	}
+	return "", fmt.Errorf("unsupported audit export format %q", f)
+}
+
+// cef returns "r" in Common Event Format.
+func cef(r Record) string {
+	severity := 3
+	if r.Result == ResultFailure {
+		severity = 7
+	}
+	header := strings.NewReplacer(`\`, `\\`, `|`, `\|`)
+	ext := strings.NewReplacer(`\`, `\\`, `=`, `\=`, "\r", `\r`, "\n", `\n`)
+	fields := []string{
+		"rt=" + fmt.Sprint(r.Time.UnixNano()/int64(time.Millisecond)),
+		"suser=" + ext.Replace(r.Actor),
+		"outcome=" + ext.Replace(string(r.Result)),
+	}
+	for _, f := range []struct {
+		n            int
+		label, value string
+	}{
+		{1, "project", r.Project},
+		{2, "environment", r.Environment},
+		{3, "revision", string(r.Revision)},
+	} {
+		if f.value != "" {
+			fields = append(fields, fmt.Sprintf("cs%dLabel=%s cs%d=%s", f.n, f.label, f.n, ext.Replace(f.value)))
+		}
+	}
+	if r.Detail != "" {
+		fields = append(fields, "msg="+ext.Replace(r.Detail))
+	}
+	action := header.Replace(r.Action)
+	return fmt.Sprintf("CEF:0|Gengo|Goship|1.0|%s|%s|%d|%s", action, action, severity, strings.Join(fields, " "))
+}
+
+// Sink delivers records to a SIEM, e.g. Splunk or Elastic.
+type Sink interface {
+	// Send delivers "records" in order. It returns an error unless all of them are delivered.
+	Send(records []Record) error
+}
+
+// NewSink returns a Sink which sends records in "format" to "rawurl".
+//
+// "rawurl" is either "syslog+tcp://host:port" or "syslog+udp://host:port" to send RFC 5424 syslog messages,
+// or an "http://" or "https://" URL to post newline-delimited records.
+// The value of the environment variable AUDIT_EXPORT_AUTHORIZATION is sent as the Authorization header of HTTP requests if set.
+func NewSink(rawurl string, format Format) (Sink, error) {
+	if _, err := format.Encode(Record{}); err != nil {
+		return nil, err
+	}
+	u, err := url.Parse(rawurl)
+	if err != nil {
+		return nil, err
+	}
+	switch u.Scheme {
+	case "syslog+tcp", "syslog+udp":
+		host, err := os.Hostname()
+		if err != nil {
+			host = "-"
+		}
+		return syslogSink{network: strings.TrimPrefix(u.Scheme, "syslog+"), addr: u.Host, hostname: host, format: format}, nil
+	case "http", "https":
+		return httpSink{url: rawurl, authorization: os.Getenv("AUDIT_EXPORT_AUTHORIZATION"), format: format}, nil
+	}
+	return nil, fmt.Errorf("unsupported audit export URL %q", rawurl)
+}
+
+type syslogSink struct {
+	network, addr string
+	hostname      string
+	format        Format
+}
+
+// syslogPriority is the priority of the messages: facility "log audit" (13) and severity "informational" (6).
+const syslogPriority = 13*8 + 6
+
+func (s syslogSink) Send(records []Record) error {
+	conn, err := net.DialTimeout(s.network, s.addr, 10*time.Second)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	for _, r := range records {
+		msg, err := s.format.Encode(r)
+		if err != nil {
+			return err
+		}
+		line := fmt.Sprintf("<%d>1 %s %s goship - - - %s\n", syslogPriority, r.Time.UTC().Format(time.RFC3339Nano), s.hostname, msg)
+		conn.SetWriteDeadline(time.Now().Add(10 * time.Second))
+		if _, err := conn.Write([]byte(line)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type httpSink struct {
+	url           string
+	authorization string
+	format        Format
+}
+
+func (s httpSink) Send(records []Record) error {
+	var buf bytes.Buffer
+	for _, r := range records {
+		msg, err := s.format.Encode(r)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintln(&buf, msg)
+	}
+	req, err := http.NewRequest("POST", s.url, &buf)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "text/plain")
+	if s.format == FormatJSON {
+		req.Header.Set("Content-Type", "application/x-ndjson")
+	}
+	if s.authorization != "" {
+		req.Header.Set("Authorization", s.authorization)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("%s responded with %s", s.url, resp.Status)
+	}
+	return nil
+}
+
+// Export sends the records which have not been delivered yet to "sink", oldest first, and returns the number of delivered records.
+//
+// The position of the last delivered record is stored in "client" after each batch,
+// so records are delivered at least once even if Goship or the sink goes down in the middle.
+func Export(client config.ETCDInterface, sink Sink) (int, error) {
+	cursor, err := loadCursor(client)
+	if err != nil {
+		return 0, err
+	}
+	nodes, err := loadNodes(client)
+	if err != nil {
+		return 0, err
+	}
+	i := sort.Search(len(nodes), func(i int) bool { return nodes[i].Key > cursor })
+	nodes = nodes[i:]
+
+	var n int
+	for len(nodes) > 0 {
+		batch := nodes
+		if len(batch) > exportBatchSize {
+			batch = batch[:exportBatchSize]
+		}
+		records := make([]Record, 0, len(batch))
+		for _, node := range batch {
+			var r Record
+			if err := json.Unmarshal([]byte(node.Value), &r); err != nil {
+				return n, fmt.Errorf("invalid audit record %s: %v", node.Key, err)
+			}
+			records = append(records, r)
+		}
+		if err := sink.Send(records); err != nil {
+			return n, err
+		}
+		if _, err := client.Set(exportCursorKey, batch[len(batch)-1].Key, 0); err != nil {
+			return n, err
+		}
+		n += len(batch)
+		nodes = nodes[len(batch):]
+	}
+	return n, nil
+}
+
+// Prune removes the records older than "retention" at "now" from "client" and returns the number of removed records.
+// If "exported" is true, it keeps the records which have not been delivered by Export yet.
+func Prune(client config.ETCDInterface, retention time.Duration, now time.Time, exported bool) (int, error) {
+	d, ok := client.(config.Deleter)
+	if !ok {
+		return 0, fmt.Errorf("cannot remove records from %T", client)
+	}
+	nodes, err := loadNodes(client)
+	if err != nil {
+		return 0, err
+	}
+	limit := fmt.Sprintf("%s/%020d", auditDir, now.Add(-retention).UnixNano())
+	if exported {
+		cursor, err := loadCursor(client)
+		if err != nil {
+			return 0, err
+		}
+		if cursor < limit {
+			limit = cursor
+		}
+	}
+	var n int
+	for _, node := range nodes {
+		// keys are ordered by the time of records, and the cursor itself has been delivered
+		if node.Key > limit {
+			break
+		}
+		if _, err := d.Delete(node.Key, false); err != nil {
+			return n, err
+		}
+		n++
+	}
+	return n, nil
+}
+
+// loadCursor returns the key of the last exported record, or an empty string if nothing has been exported.
+func loadCursor(client config.ETCDInterface) (string, error) {
+	resp, err := client.Get(exportCursorKey, false, false)
+	if config.IsNotFound(err) {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	return resp.Node.Value, nil
+}
+
+// loadNodes returns the nodes of all the records in "client", oldest first.
+func loadNodes(client config.ETCDInterface) ([]*etcd.Node, error) {
+	resp, err := client.Get(auditDir, true, true)
+	if config.IsNotFound(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	nodes := resp.Node.Nodes
+	sort.Sort(byKey(nodes))
+	return nodes, nil
+}
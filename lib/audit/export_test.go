@@ -0,0 +1,130 @@
+package audit
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/gengo/goship/lib/config"
+)
+
+// fakeSink records delivered records, and fails after "limit" records if "limit" is positive.
+type fakeSink struct {
+	records []Record
+	limit   int
+}
+
+func (s *fakeSink) Send(records []Record) error {
+	if s.limit > 0 && len(s.records)+len(records) > s.limit {
+		return errors.New("sink is down")
+	}
+	s.records = append(s.records, records...)
+	return nil
+}
+
+func TestExport(t *testing.T) {
+	s := config.NewMemoryStore()
+	start := time.Date(2015, time.November, 10, 23, 0, 0, 0, time.UTC)
+	var records []Record
+	for i := 0; i < exportBatchSize+1; i++ {
+		r := Record{Time: start.Add(time.Duration(i) * time.Second), Actor: "alice", Action: ActionComment, Result: ResultSuccess}
+		if err := Append(s, r); err != nil {
+			t.Fatalf("Append(s, %#v) failed with %v; want success", r, err)
+		}
+		records = append(records, r)
+	}
+
+	down := &fakeSink{limit: exportBatchSize}
+	if n, err := Export(s, down); err == nil || n != exportBatchSize {
+		t.Errorf("Export(s, down) = %d, %v; want %d records delivered and failure", n, err, exportBatchSize)
+	}
+
+	sink := new(fakeSink)
+	n, err := Export(s, sink)
+	if err != nil {
+		t.Fatalf("Export(s, sink) failed with %v; want success", err)
+	}
+	if want := records[exportBatchSize:]; n != len(want) || !reflect.DeepEqual(sink.records, want) {
+		t.Errorf("Export(s, sink) delivered %d records %#v; want %#v", n, sink.records, want)
+	}
+
+	if n, err := Export(s, sink); err != nil || n != 0 {
+		t.Errorf("Export(s, sink) = %d, %v after all records delivered; want 0, nil", n, err)
+	}
+}
+
+func TestPrune(t *testing.T) {
+	// records appended by other tests must not push the keys of older records forward
+	last = 0
+	s := config.NewMemoryStore()
+	now := time.Date(2015, time.November, 10, 23, 0, 0, 0, time.UTC)
+	old := []Record{
+		{Time: now.Add(-72 * time.Hour), Actor: "alice", Action: ActionComment, Result: ResultSuccess},
+		{Time: now.Add(-48 * time.Hour), Actor: "bob", Action: ActionComment, Result: ResultSuccess},
+	}
+	recent := Record{Time: now.Add(-time.Hour), Actor: "carol", Action: ActionComment, Result: ResultSuccess}
+	for _, r := range old {
+		if err := Append(s, r); err != nil {
+			t.Fatalf("Append(s, %#v) failed with %v; want success", r, err)
+		}
+	}
+	// only the oldest record has been exported
+	if _, err := s.Set(exportCursorKey, cursorOf(t, s, 0), 0); err != nil {
+		t.Fatalf("s.Set failed with %v", err)
+	}
+	if err := Append(s, recent); err != nil {
+		t.Fatalf("Append(s, %#v) failed with %v; want success", recent, err)
+	}
+
+	if n, err := Prune(s, 24*time.Hour, now, true); err != nil || n != 1 {
+		t.Errorf("Prune(s, 24h, now, true) = %d, %v; want 1 record removed", n, err)
+	}
+	if n, err := Prune(s, 24*time.Hour, now, false); err != nil || n != 1 {
+		t.Errorf("Prune(s, 24h, now, false) = %d, %v; want 1 record removed", n, err)
+	}
+	got, err := Load(s)
+	if err != nil {
+		t.Fatalf("Load(s) failed with %v; want success", err)
+	}
+	if want := []Record{recent}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Load(s) = %#v after pruning; want %#v", got, want)
+	}
+}
+
+// cursorOf returns the key of the "i"-th oldest record in "s".
+func cursorOf(t *testing.T, s config.ETCDInterface, i int) string {
+	nodes, err := loadNodes(s)
+	if err != nil {
+		t.Fatalf("loadNodes(s) failed with %v", err)
+	}
+	return nodes[i].Key
+}
+
+func TestEncode(t *testing.T) {
+	r := Record{
+		Time:        time.Date(2015, time.November, 10, 23, 0, 0, 0, time.UTC),
+		Actor:       "alice",
+		Action:      "deploy_failed",
+		Project:     "proj",
+		Environment: "production",
+		Revision:    "abc123",
+		Result:      ResultFailure,
+		Detail:      "a=b|c\nd",
+	}
+	got, err := FormatCEF.Encode(r)
+	if err != nil {
+		t.Fatalf("FormatCEF.Encode(%#v) failed with %v; want success", r, err)
+	}
+	want := `CEF:0|Gengo|Goship|1.0|deploy_failed|deploy_failed|7|rt=1447196400000 suser=alice outcome=failure cs1Label=project cs1=proj cs2Label=environment cs2=production cs3Label=revision cs3=abc123 msg=a\=b|c\nd`
+	if got != want {
+		t.Errorf("FormatCEF.Encode(%#v) = %q; want %q", r, got, want)
+	}
+
+	if _, err := Format("xml").Encode(r); err == nil {
+		t.Errorf("Format(\"xml\").Encode succeeded; want failure")
+	}
+	if _, err := NewSink("ftp://example.com", FormatJSON); err == nil {
+		t.Errorf("NewSink with ftp URL succeeded; want failure")
+	}
+}
@@ -1,10 +1,12 @@
 package auth
 
 import (
+	"crypto/x509"
 	"errors"
 	"fmt"
 	"net/http"
 	"os"
+	"time"
 
 	"github.com/golang/glog"
 	"github.com/gorilla/sessions"
@@ -16,23 +18,56 @@ const (
 	sessionName = "goship"
 )
 
+// ErrRevoked is returned when the session of the current user has been revoked.
+var ErrRevoked = errors.New("session revoked")
+
 var (
 	// enabled is true iff client authentication is enabled.
 	enabled bool
 	// defaultUser is the value which CurrentUser returns if client authentication is disabled.
 	defaultUser User
 
+	// provider is the provider which authenticates users if enabled.
+	provider Provider
+
 	githubCallbackBase string
 
 	store *sessions.CookieStore
+
+	// OnLogin, if not nil, receives every user who logs in, e.g. to remember the groups of the user.
+	OnLogin func(User)
+	// Revoked, if not nil, returns true if the session of the user which started at the time is revoked, e.g. because the user is deactivated.
+	Revoked func(name string, login time.Time) bool
+	// Groups, if not nil, returns the groups of the user and true to override the groups given at login, e.g. because they are provisioned.
+	Groups func(name string) ([]string, bool)
+	// ClientCertUser, if not nil, returns the user authenticated by the verified client certificate "cert" of the request and true, e.g. a service account of a machine client.
+	// It returns false if the request is authenticated by the session instead.
+	ClientCertUser func(r *http.Request, cert *x509.Certificate) (User, bool, error)
+	// KioskUser, if not nil, returns the user authenticated by the kiosk token of the request and true, e.g. a wall display showing the dashboard.
+	// It returns false if the request has no kiosk token, or is authenticated by the session instead.
+	KioskUser func(r *http.Request) (User, bool, error)
 )
 
-// Initialize collects server-side credential from environment variables and prepare for authentication with Github OAuth.
+// Initialize prepares for authentication with "p".
+// "p" is nil for Github OAuth, which collects server-side credential from environment variables.
 //
+// GITHUB_CALLBACK_URL is the base URL of Goship, to which users are redirected after logging in.
 // Client authentication is disabled and CurrentUser always returns "anonymous" if any of the environment variables are missing.
-func Initialize(anynomous User, cookieSecret []byte) {
+func Initialize(p Provider, anynomous User, cookieSecret []byte) {
 	store = sessions.NewCookieStore(cookieSecret)
 	githubCallbackBase = os.Getenv("GITHUB_CALLBACK_URL")
+	defaultUser = anynomous
+	if p != nil {
+		if githubCallbackBase == "" {
+			glog.Warningf("Missing GITHUB_CALLBACK_URL: Running without authentication!")
+			enabled = false
+			return
+		}
+		provider, enabled = p, true
+		glog.Infof("Enabled authentication by %s", p.Name())
+		return
+	}
+
 	cred := struct {
 		githubRandomHashKey string
 		githubOmniauthID    string
@@ -42,8 +77,6 @@ func Initialize(anynomous User, cookieSecret []byte) {
 		os.Getenv("GITHUB_OMNI_AUTH_ID"),
 		os.Getenv("GITHUB_OMNI_AUTH_KEY"),
 	}
-	defaultUser = anynomous
-
 	if cred.githubRandomHashKey == "" || cred.githubOmniauthID == "" || cred.githubOmniauthKey == "" || githubCallbackBase == "" {
 		glog.Warningf(
 			"Missing one or more Gomniauth Environment Variables: Running with with limited functionality! \n GITHUB_RANDOM_HASH_KEY [%s] \n GITHUB_OMNI_AUTH_ID [%s] \n GITHUB_OMNI_AUTH_KEY [%s] \n GITHUB_CALLBACK_URL [%s]",
@@ -62,7 +95,14 @@ func Initialize(anynomous User, cookieSecret []byte) {
 		githubOauth.New(cred.githubOmniauthID, cred.githubOmniauthKey, url),
 	)
 	glog.Infof("Enabled authentication by github OAuth2")
-	enabled = true
+	provider, enabled = githubProvider{}, true
+}
+
+// SecureCookies makes the session cookie sent only over HTTPS and hidden from scripts.
+// It must be called after Initialize.
+func SecureCookies() {
+	store.Options.Secure = true
+	store.Options.HttpOnly = true
 }
 
 func Enabled() bool {
@@ -75,11 +115,23 @@ type User struct {
 	Name string
 	// Avatar is the URL to the avatar of the user
 	Avatar string
+	// Groups are the groups of the user given by the identity provider, e.g. by OpenID Connect or LDAP.
+	Groups []string
 }
 
-// CurrentUser returns the current login user of the request.
+// CurrentUser returns the current login user of the request, or the user of its client certificate or its kiosk token.
 // It returns the default user if client authentication is disabled in the current context.
 func CurrentUser(r *http.Request) (User, error) {
+	if ClientCertUser != nil && r.TLS != nil && len(r.TLS.VerifiedChains) > 0 {
+		if u, ok, err := ClientCertUser(r, r.TLS.VerifiedChains[0][0]); ok || err != nil {
+			return u, err
+		}
+	}
+	if KioskUser != nil {
+		if u, ok, err := KioskUser(r); ok || err != nil {
+			return u, err
+		}
+	}
 	if !enabled {
 		return defaultUser, nil
 	}
@@ -96,5 +148,18 @@ func CurrentUser(r *http.Request) (User, error) {
 	if !ok {
 		return User{}, errors.New("no avatar")
 	}
-	return User{Name: name, Avatar: avatar}, nil
+	if Revoked != nil {
+		// sessions started before login times were recorded are treated as started at the zero time.
+		login, _ := session.Values["loginTime"].(int64)
+		if Revoked(name, time.Unix(login, 0)) {
+			return User{}, ErrRevoked
+		}
+	}
+	groups, _ := session.Values["groups"].([]string)
+	if Groups != nil {
+		if gs, ok := Groups(name); ok {
+			groups = gs
+		}
+	}
+	return User{Name: name, Avatar: avatar, Groups: groups}, nil
 }
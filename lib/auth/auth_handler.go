@@ -3,26 +3,38 @@ package auth
 import (
 	"fmt"
 	"net/http"
-	"os"
+	"time"
 
 	"github.com/golang/glog"
 	"github.com/gorilla/sessions"
-	"github.com/stretchr/gomniauth"
-	"github.com/stretchr/objx"
 )
 
-const (
-	providerName = "github"
-)
+// providerName returns the name of the current provider.
+func providerName() string {
+	if provider == nil {
+		return githubProviderName
+	}
+	return provider.Name()
+}
+
+// LoginPath returns the path of LoginHandler.
+func LoginPath() string {
+	return fmt.Sprintf("/auth/%s/login", providerName())
+}
+
+// CallbackPath returns the path of CallbackHandler.
+func CallbackPath() string {
+	return fmt.Sprintf("/auth/%s/callback", providerName())
+}
 
-// Authenticate decorates "h" with github OAuth authentication.
+// Authenticate decorates "h" with authentication by the current provider.
 func Authenticate(h http.Handler) http.Handler {
-	callback := fmt.Sprintf("%s/auth/github/login", githubCallbackBase)
+	login := githubCallbackBase + LoginPath()
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		_, err := CurrentUser(r)
 		if err != nil {
 			glog.Warningf("Failed to fetch the current user: %v", err)
-			http.Redirect(w, r, callback, http.StatusSeeOther)
+			http.Redirect(w, r, login, http.StatusSeeOther)
 			return
 		}
 		h.ServeHTTP(w, r)
@@ -33,61 +45,36 @@ func AuthenticateFunc(h http.HandlerFunc) http.Handler {
 	return Authenticate(h)
 }
 
-// LoginHandler begins github OAuth2 authentication
+// LoginHandler begins authentication with the current provider.
 func LoginHandler(w http.ResponseWriter, r *http.Request) {
 	if !enabled {
 		return
 	}
-
-	provider, err := gomniauth.Provider(providerName)
-	if err != nil {
-		glog.Errorf("failed to get authentication provider %s: %v", providerName, err)
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
-	}
-
-	state := gomniauth.NewState("after", "success")
-
-	authURL, err := provider.GetBeginAuthURL(state, nil)
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
-	}
-
-	http.Redirect(w, r, authURL, http.StatusFound)
+	provider.Login(w, r, githubCallbackBase+CallbackPath())
 }
 
-// CallbackHandler receives callback from github OAuth provider
+// CallbackHandler completes authentication with the current provider and starts a session of the user.
 func CallbackHandler(w http.ResponseWriter, r *http.Request) {
 	if !enabled {
 		http.Error(w, "authenticatin disabled", http.StatusBadRequest)
 		return
 	}
 
-	provider, err := gomniauth.Provider(providerName)
-	if err != nil {
-		glog.Errorf("failed to get authentication provider %s: %v", providerName, err)
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
-	}
-
-	omap, err := objx.FromURLQuery(r.URL.RawQuery)
-	if err != nil {
-		glog.Errorf("Failed to parse querystring: %v", err)
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+	user, err := provider.Callback(w, r, githubCallbackBase+CallbackPath())
+	if err == ErrInvalidCredentials {
+		http.Error(w, err.Error(), http.StatusUnauthorized)
 		return
 	}
-
-	creds, err := provider.CompleteAuth(omap)
 	if err != nil {
+		glog.Errorf("Failed to authenticate with %s: %v", provider.Name(), err)
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
-	user, userErr := provider.GetUser(creds)
-	if userErr != nil {
-		glog.Errorf("Failed to get user from Github %s", user)
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+	now := time.Now()
+	if Revoked != nil && Revoked(user.Name, now) {
+		glog.Infof("Rejected login of deactivated user %s", user.Name)
+		http.Error(w, "user deactivated", http.StatusForbidden)
 		return
 	}
 
@@ -104,9 +91,14 @@ func CallbackHandler(w http.ResponseWriter, r *http.Request) {
 		HttpOnly: true,
 	}
 
-	session.Values["userName"] = user.Nickname()
-	session.Values["avatarURL"] = user.AvatarURL()
+	session.Values["userName"] = user.Name
+	session.Values["avatarURL"] = user.Avatar
+	session.Values["groups"] = user.Groups
+	session.Values["loginTime"] = now.Unix()
 	session.Save(r, w)
+	if OnLogin != nil {
+		OnLogin(user)
+	}
 
-	http.Redirect(w, r, os.Getenv("GITHUB_CALLBACK_URL"), http.StatusFound)
+	http.Redirect(w, r, githubCallbackBase, http.StatusFound)
 }
@@ -1,6 +1,10 @@
 package auth
 
 import (
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"errors"
 	"net/http"
 	"net/http/httptest"
 	"testing"
@@ -10,7 +14,7 @@ import (
 
 func TestCurrentUser(t *testing.T) {
 	anonymous := User{Name: "T-600", Avatar: "http://avatar.example/600"}
-	Initialize(anonymous, []byte("12345"))
+	Initialize(nil, anonymous, []byte("12345"))
 
 	enabled = true
 
@@ -45,3 +49,98 @@ func TestCurrentUser(t *testing.T) {
 		t.Errorf("user.Avatar = %q; want %q", got, want)
 	}
 }
+
+func TestCurrentUserWithClientCert(t *testing.T) {
+	Initialize(nil, User{Name: "T-600"}, []byte("12345"))
+	defer func() { ClientCertUser = nil }()
+	ClientCertUser = func(r *http.Request, cert *x509.Certificate) (User, bool, error) {
+		switch cert.Subject.CommonName {
+		case "ci.example":
+			return User{Name: "ci-bot"}, true, nil
+		case "ignored.example":
+			return User{}, false, nil
+		}
+		return User{}, true, errors.New("unknown certificate")
+	}
+
+	for _, spec := range []struct {
+		commonName string
+		want       string
+		err        bool
+	}{
+		{commonName: "ci.example", want: "ci-bot"},
+		{commonName: "ignored.example", want: "T-600"},
+		{commonName: "unknown.example", err: true},
+		{want: "T-600"},
+	} {
+		req, err := http.NewRequest("GET", "https://host.example/api/deployable", nil)
+		if err != nil {
+			t.Fatalf("http.NewRequest(%q, %q, nil) failed with %v; want success", "GET", "https://host.example/api/deployable", err)
+		}
+		req.TLS = new(tls.ConnectionState)
+		if spec.commonName != "" {
+			cert := &x509.Certificate{Subject: pkix.Name{CommonName: spec.commonName}}
+			req.TLS.VerifiedChains = [][]*x509.Certificate{{cert}}
+		}
+		user, err := CurrentUser(req)
+		if spec.err {
+			if err == nil {
+				t.Errorf("CurrentUser(req) = %#v with a certificate of %q; want failure", user, spec.commonName)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("CurrentUser(req) failed with %v with a certificate of %q; want success", err, spec.commonName)
+			continue
+		}
+		if user.Name != spec.want {
+			t.Errorf("user.Name = %q with a certificate of %q; want %q", user.Name, spec.commonName, spec.want)
+		}
+	}
+}
+
+func TestCurrentUserWithKioskToken(t *testing.T) {
+	Initialize(nil, User{Name: "T-600"}, []byte("12345"))
+	defer func() { KioskUser = nil }()
+	KioskUser = func(r *http.Request) (User, bool, error) {
+		switch r.Header.Get("X-Kiosk") {
+		case "":
+			return User{}, false, nil
+		case "wall":
+			return User{Name: "kiosk:wall"}, true, nil
+		}
+		return User{}, true, errors.New("unknown kiosk token")
+	}
+
+	for _, spec := range []struct {
+		token string
+		want  string
+		err   bool
+	}{
+		{token: "wall", want: "kiosk:wall"},
+		{token: "revoked", err: true},
+		{want: "T-600"},
+	} {
+		req, err := http.NewRequest("GET", "http://host.example/", nil)
+		if err != nil {
+			t.Fatalf("http.NewRequest(%q, %q, nil) failed with %v; want success", "GET", "http://host.example/", err)
+		}
+		if spec.token != "" {
+			req.Header.Set("X-Kiosk", spec.token)
+		}
+		user, err := CurrentUser(req)
+		if spec.err {
+			if err == nil {
+				t.Errorf("CurrentUser(req) = %#v with a kiosk token %q; want failure", user, spec.token)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("CurrentUser(req) failed with %v with a kiosk token %q; want success", err, spec.token)
+			continue
+		}
+		if user.Name != spec.want {
+			t.Errorf("user.Name = %q with a kiosk token %q; want %q", user.Name, spec.token, spec.want)
+		}
+	}
+}
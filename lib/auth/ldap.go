@@ -0,0 +1,172 @@
+package auth
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"html/template"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/golang/glog"
+	"gopkg.in/ldap.v2"
+)
+
+// LDAPConfig configures authentication with user names and passwords in an LDAP directory.
+type LDAPConfig struct {
+	// URL is the address of the directory server, e.g. "ldaps://ldap.example.com" or "ldap://ldap.example.com:389".
+	URL string
+	// StartTLS upgrades "ldap://" connections to TLS.
+	StartTLS bool
+	// BindDN and BindPassword are the credential to search users. Users are searched anonymously if empty.
+	BindDN       string
+	BindPassword string
+	// BaseDN is the base of the search of users, e.g. "ou=people,dc=example,dc=com".
+	BaseDN string
+	// UserFilter finds the entry of a user, with "%s" replaced with the user name. "(uid=%s)" if empty.
+	UserFilter string
+	// GroupAttribute is the attribute of user entries which lists the DNs of their groups. "memberOf" if empty.
+	GroupAttribute string
+}
+
+type ldapProvider struct {
+	cfg  LDAPConfig
+	addr string
+	tls  bool
+}
+
+// NewLDAP returns a Provider which authenticates users by binding to the LDAP directory configured in "cfg" with their passwords.
+// Groups of the users are the values of the first RDNs of the DNs in GroupAttribute, e.g. "deployers" of "cn=deployers,ou=groups,dc=example,dc=com".
+func NewLDAP(cfg LDAPConfig) (Provider, error) {
+	u, err := url.Parse(cfg.URL)
+	if err != nil {
+		return nil, err
+	}
+	p := ldapProvider{cfg: cfg, addr: u.Host}
+	switch u.Scheme {
+	case "ldaps":
+		p.tls = true
+		if _, _, err := net.SplitHostPort(p.addr); err != nil {
+			p.addr = net.JoinHostPort(p.addr, "636")
+		}
+	case "ldap":
+		if _, _, err := net.SplitHostPort(p.addr); err != nil {
+			p.addr = net.JoinHostPort(p.addr, "389")
+		}
+	default:
+		return nil, fmt.Errorf("unsupported LDAP URL %q", cfg.URL)
+	}
+	if cfg.BaseDN == "" {
+		return nil, errors.New("base DN is required for LDAP")
+	}
+	if p.cfg.UserFilter == "" {
+		p.cfg.UserFilter = "(uid=%s)"
+	}
+	if p.cfg.GroupAttribute == "" {
+		p.cfg.GroupAttribute = "memberOf"
+	}
+	return p, nil
+}
+
+func (p ldapProvider) Name() string {
+	return "ldap"
+}
+
+var ldapLoginTemplate = template.Must(template.New("login").Parse(`<!DOCTYPE html>
+<html>
+<head><title>Goship - Log in</title></head>
+<body>
+<form method="POST" action="{{.}}">
+<p><label>User name <input type="text" name="username" autofocus required></label></p>
+<p><label>Password <input type="password" name="password" required></label></p>
+<p><input type="submit" value="Log in"></p>
+</form>
+</body>
+</html>
+`))
+
+// Login shows a form which posts the user name and the password to "callbackURL".
+func (p ldapProvider) Login(w http.ResponseWriter, r *http.Request, callbackURL string) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if err := ldapLoginTemplate.Execute(w, callbackURL); err != nil {
+		glog.Errorf("Failed to render login form: %v", err)
+	}
+}
+
+func (p ldapProvider) Callback(w http.ResponseWriter, r *http.Request, callbackURL string) (User, error) {
+	if r.Method != "POST" {
+		return User{}, ErrInvalidCredentials
+	}
+	name, password := r.PostFormValue("username"), r.PostFormValue("password")
+	// an empty password would make an unauthenticated bind, which most servers accept
+	if name == "" || password == "" {
+		return User{}, ErrInvalidCredentials
+	}
+
+	conn, err := p.dial()
+	if err != nil {
+		return User{}, err
+	}
+	defer conn.Close()
+	if p.cfg.BindDN != "" {
+		if err := conn.Bind(p.cfg.BindDN, p.cfg.BindPassword); err != nil {
+			return User{}, fmt.Errorf("cannot bind as %s: %v", p.cfg.BindDN, err)
+		}
+	}
+	req := ldap.NewSearchRequest(
+		p.cfg.BaseDN, ldap.ScopeWholeSubtree, ldap.NeverDerefAliases, 2, 0, false,
+		strings.Replace(p.cfg.UserFilter, "%s", ldap.EscapeFilter(name), -1),
+		[]string{"dn", p.cfg.GroupAttribute}, nil,
+	)
+	res, err := conn.Search(req)
+	if err != nil {
+		return User{}, err
+	}
+	if len(res.Entries) != 1 {
+		glog.Warningf("Found %d entries of %s in LDAP", len(res.Entries), name)
+		return User{}, ErrInvalidCredentials
+	}
+	entry := res.Entries[0]
+	if err := conn.Bind(entry.DN, password); err != nil {
+		if ldap.IsErrorWithCode(err, ldap.LDAPResultInvalidCredentials) {
+			return User{}, ErrInvalidCredentials
+		}
+		return User{}, err
+	}
+
+	var groups []string
+	for _, dn := range entry.GetAttributeValues(p.cfg.GroupAttribute) {
+		groups = append(groups, groupName(dn))
+	}
+	return User{Name: name, Groups: groups}, nil
+}
+
+// dial connects to the directory server.
+func (p ldapProvider) dial() (*ldap.Conn, error) {
+	host, _, _ := net.SplitHostPort(p.addr)
+	if p.tls {
+		return ldap.DialTLS("tcp", p.addr, &tls.Config{ServerName: host})
+	}
+	conn, err := ldap.Dial("tcp", p.addr)
+	if err != nil {
+		return nil, err
+	}
+	if p.cfg.StartTLS {
+		if err := conn.StartTLS(&tls.Config{ServerName: host}); err != nil {
+			conn.Close()
+			return nil, err
+		}
+	}
+	return conn, nil
+}
+
+// groupName returns the value of the first RDN of "dn", or "dn" itself if it is not a DN.
+func groupName(dn string) string {
+	rdn := strings.SplitN(dn, ",", 2)[0]
+	if i := strings.Index(rdn, "="); i >= 0 {
+		return strings.TrimSpace(rdn[i+1:])
+	}
+	return dn
+}
@@ -0,0 +1,210 @@
+package auth
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/golang/glog"
+	"golang.org/x/net/context"
+	"golang.org/x/oauth2"
+)
+
+// OIDCConfig configures authentication with an OpenID Connect provider, e.g. Okta or Azure AD.
+type OIDCConfig struct {
+	// Issuer is the issuer URL of the provider, e.g. "https://example.okta.com".
+	// The endpoints are discovered from "$Issuer/.well-known/openid-configuration".
+	Issuer       string
+	ClientID     string
+	ClientSecret string
+	// UserClaim is the claim in ID tokens used as user names. "preferred_username" if empty.
+	UserClaim string
+	// GroupsClaim is the claim in ID tokens which lists the groups of the user. "groups" if empty.
+	GroupsClaim string
+}
+
+type oidcProvider struct {
+	cfg    OIDCConfig
+	oauth2 oauth2.Config
+}
+
+// discovery is the subset of the OpenID Provider metadata used by Goship.
+type discovery struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+}
+
+// NewOIDC returns a Provider which authenticates users with the OpenID Connect provider configured in "cfg".
+// It discovers the endpoints of the provider.
+func NewOIDC(cfg OIDCConfig) (Provider, error) {
+	if cfg.Issuer == "" || cfg.ClientID == "" || cfg.ClientSecret == "" {
+		return nil, errors.New("issuer, client ID and client secret are required for OpenID Connect")
+	}
+	if cfg.UserClaim == "" {
+		cfg.UserClaim = "preferred_username"
+	}
+	if cfg.GroupsClaim == "" {
+		cfg.GroupsClaim = "groups"
+	}
+	cfg.Issuer = strings.TrimSuffix(cfg.Issuer, "/")
+
+	resp, err := http.Get(cfg.Issuer + "/.well-known/openid-configuration")
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("discovery of %s failed with %s", cfg.Issuer, resp.Status)
+	}
+	var d discovery
+	if err := json.NewDecoder(resp.Body).Decode(&d); err != nil {
+		return nil, err
+	}
+	if d.Issuer != cfg.Issuer {
+		return nil, fmt.Errorf("issuer %q in the discovery document does not match %q", d.Issuer, cfg.Issuer)
+	}
+	return oidcProvider{
+		cfg: cfg,
+		oauth2: oauth2.Config{
+			ClientID:     cfg.ClientID,
+			ClientSecret: cfg.ClientSecret,
+			Endpoint:     oauth2.Endpoint{AuthURL: d.AuthorizationEndpoint, TokenURL: d.TokenEndpoint},
+			Scopes:       []string{"openid", "profile", "email"},
+		},
+	}, nil
+}
+
+func (p oidcProvider) Name() string {
+	return "oidc"
+}
+
+// oauth2Config returns the OAuth2 configuration which redirects to "callbackURL".
+func (p oidcProvider) oauth2Config(callbackURL string) *oauth2.Config {
+	c := p.oauth2
+	c.RedirectURL = callbackURL
+	return &c
+}
+
+// Login redirects to the provider with a random state, which is also used as the nonce of the ID token.
+func (p oidcProvider) Login(w http.ResponseWriter, r *http.Request, callbackURL string) {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		glog.Errorf("Failed to generate state: %v", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	state := hex.EncodeToString(buf)
+	session, err := store.Get(r, sessionName)
+	if err != nil {
+		glog.Errorf("Failed to fetch current session: %v", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	session.Values["oidcState"] = state
+	session.Save(r, w)
+
+	u := p.oauth2Config(callbackURL).AuthCodeURL(state)
+	sep := "?"
+	if strings.Contains(u, "?") {
+		sep = "&"
+	}
+	http.Redirect(w, r, u+sep+"nonce="+state, http.StatusFound)
+}
+
+func (p oidcProvider) Callback(w http.ResponseWriter, r *http.Request, callbackURL string) (User, error) {
+	if msg := r.FormValue("error"); msg != "" {
+		return User{}, fmt.Errorf("%s: %s", msg, r.FormValue("error_description"))
+	}
+	session, err := store.Get(r, sessionName)
+	if err != nil {
+		return User{}, err
+	}
+	state, _ := session.Values["oidcState"].(string)
+	if state == "" || r.FormValue("state") != state {
+		return User{}, ErrInvalidCredentials
+	}
+	delete(session.Values, "oidcState")
+
+	tok, err := p.oauth2Config(callbackURL).Exchange(context.Background(), r.FormValue("code"))
+	if err != nil {
+		return User{}, err
+	}
+	idToken, ok := tok.Extra("id_token").(string)
+	if !ok {
+		return User{}, errors.New("no id_token in the token response")
+	}
+	return p.userFromIDToken(idToken, state, time.Now())
+}
+
+// userFromIDToken validates the claims of "idToken" and returns the user in it.
+//
+// The signature is not verified because the token comes directly from the token endpoint over TLS,
+// which OpenID Connect Core 1.0 section 3.1.3.7 allows.
+func (p oidcProvider) userFromIDToken(idToken, nonce string, now time.Time) (User, error) {
+	parts := strings.Split(idToken, ".")
+	if len(parts) != 3 {
+		return User{}, errors.New("malformed id_token")
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return User{}, fmt.Errorf("malformed id_token: %v", err)
+	}
+	var claims map[string]interface{}
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		return User{}, fmt.Errorf("malformed id_token: %v", err)
+	}
+
+	if iss, _ := claims["iss"].(string); iss != p.cfg.Issuer {
+		return User{}, fmt.Errorf("id_token issued by %q; want %q", iss, p.cfg.Issuer)
+	}
+	if !containsString(stringsClaim(claims["aud"]), p.cfg.ClientID) {
+		return User{}, fmt.Errorf("id_token is not issued for %s", p.cfg.ClientID)
+	}
+	exp, _ := claims["exp"].(float64)
+	if now.After(time.Unix(int64(exp), 0)) {
+		return User{}, errors.New("id_token expired")
+	}
+	if n, _ := claims["nonce"].(string); n != nonce {
+		return User{}, ErrInvalidCredentials
+	}
+
+	name, _ := claims[p.cfg.UserClaim].(string)
+	if name == "" {
+		return User{}, fmt.Errorf("no %s claim in id_token", p.cfg.UserClaim)
+	}
+	avatar, _ := claims["picture"].(string)
+	return User{Name: name, Avatar: avatar, Groups: stringsClaim(claims[p.cfg.GroupsClaim])}, nil
+}
+
+// stringsClaim returns the value of a claim which is either a string or an array of strings.
+func stringsClaim(v interface{}) []string {
+	switch v := v.(type) {
+	case string:
+		return []string{v}
+	case []interface{}:
+		var s []string
+		for _, item := range v {
+			if str, ok := item.(string); ok {
+				s = append(s, str)
+			}
+		}
+		return s
+	}
+	return nil
+}
+
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,78 @@
+package auth
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/golang/glog"
+	"github.com/stretchr/gomniauth"
+	"github.com/stretchr/objx"
+)
+
+// ErrInvalidCredentials is returned by Provider.Callback when the user fails to prove the identity, e.g. with a wrong password.
+var ErrInvalidCredentials = errors.New("invalid credentials")
+
+// Provider authenticates users with an identity provider.
+type Provider interface {
+	// Name identifies the provider in URLs, e.g. "github" in /auth/github/login.
+	Name() string
+	// Login begins authentication, e.g. by redirecting to the identity provider or by showing a login form.
+	// The identity provider or the form sends the user to "callbackURL" to complete authentication.
+	Login(w http.ResponseWriter, r *http.Request, callbackURL string)
+	// Callback completes authentication of the request to "callbackURL" and returns the authenticated user.
+	Callback(w http.ResponseWriter, r *http.Request, callbackURL string) (User, error)
+}
+
+const githubProviderName = "github"
+
+// githubProvider authenticates users with Github OAuth2 through gomniauth.
+type githubProvider struct{}
+
+func (githubProvider) Name() string {
+	return githubProviderName
+}
+
+func (githubProvider) Login(w http.ResponseWriter, r *http.Request, callbackURL string) {
+	provider, err := gomniauth.Provider(githubProviderName)
+	if err != nil {
+		glog.Errorf("failed to get authentication provider %s: %v", githubProviderName, err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	state := gomniauth.NewState("after", "success")
+
+	authURL, err := provider.GetBeginAuthURL(state, nil)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	http.Redirect(w, r, authURL, http.StatusFound)
+}
+
+func (githubProvider) Callback(w http.ResponseWriter, r *http.Request, callbackURL string) (User, error) {
+	provider, err := gomniauth.Provider(githubProviderName)
+	if err != nil {
+		glog.Errorf("failed to get authentication provider %s: %v", githubProviderName, err)
+		return User{}, err
+	}
+
+	omap, err := objx.FromURLQuery(r.URL.RawQuery)
+	if err != nil {
+		glog.Errorf("Failed to parse querystring: %v", err)
+		return User{}, err
+	}
+
+	creds, err := provider.CompleteAuth(omap)
+	if err != nil {
+		return User{}, err
+	}
+
+	user, err := provider.GetUser(creds)
+	if err != nil {
+		glog.Errorf("Failed to get user from Github: %v", err)
+		return User{}, err
+	}
+	return User{Name: user.Nickname(), Avatar: user.AvatarURL()}, nil
+}
@@ -0,0 +1,103 @@
+package auth
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func idToken(t *testing.T, claims map[string]interface{}) string {
+	buf, err := json.Marshal(claims)
+	if err != nil {
+		t.Fatalf("json.Marshal(%#v) failed with %v", claims, err)
+	}
+	return "eyJhbGciOiJSUzI1NiJ9." + base64.RawURLEncoding.EncodeToString(buf) + ".signature"
+}
+
+func TestUserFromIDToken(t *testing.T) {
+	p := oidcProvider{cfg: OIDCConfig{Issuer: "https://idp.example.com", ClientID: "goship", UserClaim: "preferred_username", GroupsClaim: "groups"}}
+	now := time.Date(2015, time.November, 10, 23, 0, 0, 0, time.UTC)
+	valid := func() map[string]interface{} {
+		return map[string]interface{}{
+			"iss":                "https://idp.example.com",
+			"aud":                []string{"goship", "other"},
+			"exp":                now.Add(time.Minute).Unix(),
+			"nonce":              "nonce",
+			"preferred_username": "alice",
+			"picture":            "https://idp.example.com/alice.png",
+			"groups":             []string{"ops", "developers"},
+		}
+	}
+
+	got, err := p.userFromIDToken(idToken(t, valid()), "nonce", now)
+	if err != nil {
+		t.Fatalf("p.userFromIDToken failed with %v; want success", err)
+	}
+	want := User{Name: "alice", Avatar: "https://idp.example.com/alice.png", Groups: []string{"ops", "developers"}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("p.userFromIDToken = %#v; want %#v", got, want)
+	}
+
+	for _, spec := range []struct {
+		name  string
+		claim string
+		value interface{}
+	}{
+		{name: "other issuer", claim: "iss", value: "https://evil.example.com"},
+		{name: "other audience", claim: "aud", value: "other"},
+		{name: "expired", claim: "exp", value: now.Add(-time.Minute).Unix()},
+		{name: "wrong nonce", claim: "nonce", value: "replayed"},
+		{name: "no user name", claim: "preferred_username", value: ""},
+	} {
+		claims := valid()
+		claims[spec.claim] = spec.value
+		if _, err := p.userFromIDToken(idToken(t, claims), "nonce", now); err == nil {
+			t.Errorf("p.userFromIDToken succeeded with %s; want failure", spec.name)
+		}
+	}
+	if _, err := p.userFromIDToken("not-a-jwt", "nonce", now); err == nil {
+		t.Errorf("p.userFromIDToken succeeded with a malformed token; want failure")
+	}
+}
+
+func TestNewLDAP(t *testing.T) {
+	for _, spec := range []struct {
+		url  string
+		addr string
+		tls  bool
+	}{
+		{url: "ldaps://ldap.example.com", addr: "ldap.example.com:636", tls: true},
+		{url: "ldap://ldap.example.com", addr: "ldap.example.com:389"},
+		{url: "ldap://ldap.example.com:10389", addr: "ldap.example.com:10389"},
+	} {
+		p, err := NewLDAP(LDAPConfig{URL: spec.url, BaseDN: "dc=example,dc=com"})
+		if err != nil {
+			t.Errorf("NewLDAP(%q) failed with %v; want success", spec.url, err)
+			continue
+		}
+		lp := p.(ldapProvider)
+		if lp.addr != spec.addr || lp.tls != spec.tls {
+			t.Errorf("NewLDAP(%q) connects to %q (tls=%t); want %q (tls=%t)", spec.url, lp.addr, lp.tls, spec.addr, spec.tls)
+		}
+		if got, want := lp.cfg.UserFilter, "(uid=%s)"; got != want {
+			t.Errorf("lp.cfg.UserFilter = %q; want %q by default", got, want)
+		}
+	}
+	if _, err := NewLDAP(LDAPConfig{URL: "http://ldap.example.com", BaseDN: "dc=example,dc=com"}); err == nil {
+		t.Errorf("NewLDAP with http URL succeeded; want failure")
+	}
+}
+
+func TestGroupName(t *testing.T) {
+	for dn, want := range map[string]string{
+		"cn=deployers,ou=groups,dc=example,dc=com": "deployers",
+		"CN=Ops Team,OU=Groups,DC=example,DC=com":  "Ops Team",
+		"deployers": "deployers",
+	} {
+		if got := groupName(dn); got != want {
+			t.Errorf("groupName(%q) = %q; want %q", dn, got, want)
+		}
+	}
+}
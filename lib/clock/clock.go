@@ -0,0 +1,127 @@
+// Package clock provides the clock which scheduling of goship runs off,
+// so that deploy windows, freezes, scheduled deploys and lock expiry can be tested with fake time
+// and the demo mode can simulate time.
+package clock
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// Clock tells the current time and waits for durations.
+type Clock interface {
+	// Now returns the current time.
+	Now() time.Time
+	// After returns a channel which receives the current time once "d" has elapsed.
+	After(d time.Duration) <-chan time.Time
+}
+
+// Real is the clock of the system.
+var Real Clock = realClock{}
+
+// Default is the clock which scheduling uses unless another clock is given.
+// It is Real except in tests and the demo mode.
+var Default = Real
+
+// Now returns the current time of Default.
+func Now() time.Time {
+	return Default.Now()
+}
+
+type realClock struct{}
+
+func (realClock) Now() time.Time                         { return time.Now() }
+func (realClock) After(d time.Duration) <-chan time.Time { return time.After(d) }
+
+// Fake is a clock which stays still until it is advanced.
+type Fake struct {
+	mu      sync.Mutex
+	now     time.Time
+	waiters waiters
+}
+
+type waiter struct {
+	at time.Time
+	c  chan time.Time
+}
+
+type waiters []waiter
+
+func (w waiters) Len() int           { return len(w) }
+func (w waiters) Less(i, j int) bool { return w[i].at.Before(w[j].at) }
+func (w waiters) Swap(i, j int)      { w[i], w[j] = w[j], w[i] }
+
+// NewFake returns a new Fake which starts at "t".
+func NewFake(t time.Time) *Fake {
+	return &Fake{now: t}
+}
+
+// Now returns the time of the clock.
+func (f *Fake) Now() time.Time {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.now
+}
+
+// After returns a channel which receives the time of the clock once it is advanced by "d".
+func (f *Fake) After(d time.Duration) <-chan time.Time {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	c := make(chan time.Time, 1)
+	if d <= 0 {
+		c <- f.now
+		return c
+	}
+	f.waiters = append(f.waiters, waiter{at: f.now.Add(d), c: c})
+	sort.Stable(f.waiters)
+	return c
+}
+
+// Advance moves the clock forward by "d" and fires the channels of After which are due.
+func (f *Fake) Advance(d time.Duration) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.now = f.now.Add(d)
+	for len(f.waiters) > 0 && !f.waiters[0].at.After(f.now) {
+		f.waiters[0].c <- f.now
+		f.waiters = f.waiters[1:]
+	}
+}
+
+// Waiters returns the number of channels of After which have not fired yet.
+// Tests use it to wait until the code under test sleeps on the clock.
+func (f *Fake) Waiters() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return len(f.waiters)
+}
+
+// Simulated is a clock which starts at a given time and runs faster than the real time.
+type Simulated struct {
+	start time.Time
+	base  time.Time
+	speed float64
+}
+
+// NewSimulated returns a new Simulated which starts at "start" and runs "speed" times as fast as the real time.
+// "speed" must be positive.
+func NewSimulated(start time.Time, speed float64) *Simulated {
+	return &Simulated{start: start, base: time.Now(), speed: speed}
+}
+
+// Now returns the simulated time.
+func (s *Simulated) Now() time.Time {
+	elapsed := float64(time.Since(s.base)) * s.speed
+	return s.start.Add(time.Duration(elapsed))
+}
+
+// After returns a channel which receives the simulated time once "d" has elapsed in the simulated time.
+func (s *Simulated) After(d time.Duration) <-chan time.Time {
+	c := make(chan time.Time, 1)
+	go func() {
+		<-time.After(time.Duration(float64(d) / s.speed))
+		c <- s.Now()
+	}()
+	return c
+}
@@ -0,0 +1,58 @@
+package clock
+
+import (
+	"testing"
+	"time"
+)
+
+func TestFake(t *testing.T) {
+	start := time.Date(2015, time.November, 13, 12, 0, 0, 0, time.UTC)
+	f := NewFake(start)
+	c1 := f.After(time.Minute)
+	c2 := f.After(time.Hour)
+	if got := f.Waiters(); got != 2 {
+		t.Errorf("f.Waiters() = %d; want 2", got)
+	}
+
+	f.Advance(30 * time.Second)
+	select {
+	case got := <-c1:
+		t.Errorf("f.After(time.Minute) fired at %v; want after a minute", got)
+	default:
+	}
+	f.Advance(30 * time.Second)
+	select {
+	case got := <-c1:
+		if want := start.Add(time.Minute); !got.Equal(want) {
+			t.Errorf("f.After(time.Minute) received %v; want %v", got, want)
+		}
+	default:
+		t.Errorf("f.After(time.Minute) did not fire after a minute")
+	}
+	if got := f.Waiters(); got != 1 {
+		t.Errorf("f.Waiters() = %d; want 1", got)
+	}
+
+	f.Advance(2 * time.Hour)
+	select {
+	case <-c2:
+	default:
+		t.Errorf("f.After(time.Hour) did not fire after an hour")
+	}
+	if got, want := f.Now(), start.Add(2*time.Hour+time.Minute); !got.Equal(want) {
+		t.Errorf("f.Now() = %v; want %v", got, want)
+	}
+}
+
+func TestSimulated(t *testing.T) {
+	start := time.Date(2015, time.November, 13, 12, 0, 0, 0, time.UTC)
+	s := NewSimulated(start, 3600)
+	select {
+	case <-s.After(time.Hour):
+	case <-time.After(time.Second * 5):
+		t.Fatalf("s.After(time.Hour) did not fire in a second of the real time at 3600x speed")
+	}
+	if got := s.Now(); got.Before(start.Add(time.Hour)) {
+		t.Errorf("s.Now() = %v; want after %v", got, start.Add(time.Hour))
+	}
+}
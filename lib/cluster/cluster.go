@@ -0,0 +1,264 @@
+// Package cluster runs multiple instances of Goship against a shared etcd.
+// Instances register themselves as members, elect a leader which runs periodic jobs,
+// serialize deployments of each environment with leases, and relay push notifications to each other.
+package cluster
+
+import (
+	"encoding/json"
+	"path"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/coreos/go-etcd/etcd"
+	"github.com/gengo/goship/lib/config"
+	"github.com/golang/glog"
+	"golang.org/x/net/context"
+)
+
+const (
+	membersKey = "/goship/cluster/members"
+	leaderKey  = "/goship/cluster/leader"
+	deploysKey = "/goship/cluster/deploys"
+)
+
+// Store is an etcd client which can create and compare keys atomically.
+type Store interface {
+	config.ETCDInterface
+	Create(key, value string, ttl uint64) (*etcd.Response, error)
+	CompareAndSwap(key, value string, ttl uint64, prevValue string, prevIndex uint64) (*etcd.Response, error)
+	CompareAndDelete(key, prevValue string, prevIndex uint64) (*etcd.Response, error)
+}
+
+// Member is an instance of Goship in a cluster.
+type Member struct {
+	ID string `json:"id"`
+	// URL is where the other members reach the member, e.g. "http://10.0.0.1:8000".
+	URL     string    `json:"url"`
+	Started time.Time `json:"started"`
+}
+
+// Cluster is the view of a cluster from one of its members.
+type Cluster struct {
+	store Store
+	self  Member
+	token string
+	ttl   time.Duration
+
+	mu      sync.Mutex
+	members []Member
+	leader  string
+	// changed is closed and replaced when the leader changes.
+	changed chan struct{}
+
+	relay chan string
+}
+
+// New returns a cluster which "self" joins through "store".
+// Members authenticate relayed notifications with "token".
+// Membership, leadership and deploy leases expire in "ttl" unless their holder renews them, e.g. after the holder crashed.
+func New(store Store, self Member, token string, ttl time.Duration) *Cluster {
+	if self.Started.IsZero() {
+		self.Started = time.Now()
+	}
+	return &Cluster{
+		store:   store,
+		self:    self,
+		token:   token,
+		ttl:     ttl,
+		changed: make(chan struct{}),
+		relay:   make(chan string, relayBuffer),
+	}
+}
+
+// Self returns the member which this cluster represents.
+func (c *Cluster) Self() Member {
+	return c.self
+}
+
+// Members returns the live members sorted by ID, as of the last heartbeat.
+func (c *Cluster) Members() []Member {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]Member(nil), c.members...)
+}
+
+// Member returns the live member identified by "id".
+func (c *Cluster) Member(id string) (Member, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, m := range c.members {
+		if m.ID == id {
+			return m, true
+		}
+	}
+	return Member{}, false
+}
+
+// Leader returns the ID of the current leader, or empty if no member leads.
+func (c *Cluster) Leader() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.leader
+}
+
+// IsLeader returns true if this member is the leader.
+func (c *Cluster) IsLeader() bool {
+	return c.Leader() == c.self.ID
+}
+
+// Run keeps this member registered and campaigns for the leadership until "ctx" is done.
+// It also sends notifications passed to Relay to the other members. It leaves the cluster when it returns.
+func (c *Cluster) Run(ctx context.Context) {
+	go c.runRelay(ctx)
+	for {
+		c.heartbeat()
+		select {
+		case <-ctx.Done():
+			c.Leave()
+			return
+		case <-time.After(c.ttl / 3):
+		}
+	}
+}
+
+// Lead runs "f" while this member is the leader.
+// The context passed to "f" is canceled when this member loses the leadership or "ctx" is done.
+// "f" runs again when this member becomes the leader again.
+func (c *Cluster) Lead(ctx context.Context, f func(ctx context.Context)) {
+	for {
+		c.mu.Lock()
+		leading, changed := c.leader == c.self.ID, c.changed
+		c.mu.Unlock()
+		if !leading {
+			select {
+			case <-ctx.Done():
+				return
+			case <-changed:
+			}
+			continue
+		}
+
+		lctx, cancel := context.WithCancel(ctx)
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			f(lctx)
+		}()
+		select {
+		case <-ctx.Done():
+		case <-changed:
+		}
+		cancel()
+		<-done
+		if ctx.Err() != nil {
+			return
+		}
+	}
+}
+
+// heartbeat renews the membership of this member and its leadership, and refreshes the members and the leader.
+func (c *Cluster) heartbeat() {
+	buf, err := json.Marshal(c.self)
+	if err != nil {
+		glog.Errorf("Failed to marshal %#v into JSON: %v", c.self, err)
+		return
+	}
+	if _, err := c.store.Set(path.Join(membersKey, c.self.ID), string(buf), c.ttlSeconds()); err != nil {
+		glog.Errorf("Failed to register %s as a member of the cluster: %v", c.self.ID, err)
+	}
+	leader := c.campaign()
+	members, err := c.loadMembers()
+	if err != nil {
+		glog.Errorf("Failed to load members of the cluster: %v", err)
+		members = c.Members()
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.members = members
+	if leader != c.leader {
+		glog.Infof("Leader of the cluster changed from %q to %q", c.leader, leader)
+		c.leader = leader
+		close(c.changed)
+		c.changed = make(chan struct{})
+	}
+}
+
+// campaign renews the leadership if this member leads, or tries to take it if no member leads.
+// It returns the ID of the leader, or empty if unknown.
+func (c *Cluster) campaign() string {
+	id := c.self.ID
+	if _, err := c.store.CompareAndSwap(leaderKey, id, c.ttlSeconds(), id, 0); err == nil {
+		return id
+	}
+	if _, err := c.store.Create(leaderKey, id, c.ttlSeconds()); err == nil {
+		glog.Infof("%s became the leader of the cluster", id)
+		return id
+	} else if !config.IsExist(err) {
+		glog.Errorf("Failed to campaign for the leader of the cluster: %v", err)
+		return ""
+	}
+	resp, err := c.store.Get(leaderKey, false, false)
+	if err != nil {
+		if !config.IsNotFound(err) {
+			glog.Errorf("Failed to get the leader of the cluster: %v", err)
+		}
+		return ""
+	}
+	return resp.Node.Value
+}
+
+// Leave gives up the leadership and the membership of this member so that the others take over without waiting for them to expire.
+func (c *Cluster) Leave() {
+	if _, err := c.store.CompareAndDelete(leaderKey, c.self.ID, 0); err != nil && !config.IsCompareFailed(err) && !config.IsNotFound(err) {
+		glog.Errorf("Failed to resign the leader of the cluster: %v", err)
+	}
+	if d, ok := c.store.(config.Deleter); ok {
+		if _, err := d.Delete(path.Join(membersKey, c.self.ID), false); err != nil && !config.IsNotFound(err) {
+			glog.Errorf("Failed to leave the cluster: %v", err)
+		}
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.leader == c.self.ID {
+		c.leader = ""
+		close(c.changed)
+		c.changed = make(chan struct{})
+	}
+}
+
+func (c *Cluster) loadMembers() ([]Member, error) {
+	resp, err := c.store.Get(membersKey, false, false)
+	if config.IsNotFound(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var members []Member
+	for _, n := range resp.Node.Nodes {
+		var m Member
+		if err := json.Unmarshal([]byte(n.Value), &m); err != nil {
+			glog.Errorf("Failed to unmarshal member at %s: %v", n.Key, err)
+			continue
+		}
+		members = append(members, m)
+	}
+	sort.Sort(byID(members))
+	return members, nil
+}
+
+// ttlSeconds returns the TTL of keys in etcd, which is at least a second.
+func (c *Cluster) ttlSeconds() uint64 {
+	if s := uint64(c.ttl / time.Second); s > 0 {
+		return s
+	}
+	return 1
+}
+
+type byID []Member
+
+func (ms byID) Len() int           { return len(ms) }
+func (ms byID) Swap(i, j int)      { ms[i], ms[j] = ms[j], ms[i] }
+func (ms byID) Less(i, j int) bool { return ms[i].ID < ms[j].ID }
@@ -0,0 +1,204 @@
+package cluster
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/gengo/goship/lib/config"
+	"github.com/gengo/goship/lib/deploy"
+	"golang.org/x/net/context"
+)
+
+func TestLeaderElection(t *testing.T) {
+	s := config.NewMemoryStore()
+	a := New(s, Member{ID: "a", URL: "http://a"}, "token", time.Minute)
+	b := New(s, Member{ID: "b", URL: "http://b"}, "token", time.Minute)
+	a.heartbeat()
+	b.heartbeat()
+	a.heartbeat()
+	if !a.IsLeader() || b.IsLeader() {
+		t.Errorf("a.IsLeader() = %t, b.IsLeader() = %t; want true, false", a.IsLeader(), b.IsLeader())
+	}
+	if got, want := b.Leader(), "a"; got != want {
+		t.Errorf("b.Leader() = %q; want %q", got, want)
+	}
+	var ids []string
+	for _, m := range a.Members() {
+		ids = append(ids, m.ID)
+	}
+	if want := []string{"a", "b"}; !reflect.DeepEqual(ids, want) {
+		t.Errorf("members = %q; want %q", ids, want)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	led := make(chan context.Context, 1)
+	go b.Lead(ctx, func(ctx context.Context) {
+		led <- ctx
+		<-ctx.Done()
+	})
+	select {
+	case <-led:
+		t.Fatalf("b led before it became the leader")
+	case <-time.After(10 * time.Millisecond):
+	}
+
+	a.Leave()
+	b.heartbeat()
+	if !b.IsLeader() {
+		t.Fatalf("b.IsLeader() = false after a left; want true")
+	}
+	var lctx context.Context
+	select {
+	case lctx = <-led:
+	case <-time.After(time.Second):
+		t.Fatalf("b did not lead after it became the leader")
+	}
+	if _, err := s.Set(leaderKey, "c", 0); err != nil {
+		t.Fatalf("s.Set(%q, %q, 0) failed with %v; want success", leaderKey, "c", err)
+	}
+	b.heartbeat()
+	select {
+	case <-lctx.Done():
+	case <-time.After(time.Second):
+		t.Errorf("context of the leader is not canceled after b lost the leadership")
+	}
+}
+
+func TestDeployLease(t *testing.T) {
+	s := config.NewMemoryStore()
+	a := New(s, Member{ID: "a"}, "token", time.Minute)
+	b := New(s, Member{ID: "b"}, "token", time.Minute)
+
+	release, err := a.TryAcquire("proj", "staging", "alice")
+	if err != nil {
+		t.Fatalf("a.TryAcquire(%q, %q, %q) failed with %v; want success", "proj", "staging", "alice", err)
+	}
+	if _, err := b.TryAcquire("proj", "staging", "bob"); err != deploy.ErrBusy {
+		t.Errorf("b.TryAcquire(%q, %q, %q) returned %v; want %v", "proj", "staging", "bob", err, deploy.ErrBusy)
+	}
+	rel, err := b.TryAcquire("proj", "production", "bob")
+	if err != nil {
+		t.Fatalf("b.TryAcquire(%q, %q, %q) failed with %v; want success", "proj", "production", "bob", err)
+	}
+	defer rel()
+
+	leases, err := b.Deploys()
+	if err != nil {
+		t.Fatalf("b.Deploys() failed with %v; want success", err)
+	}
+	var got []string
+	for _, l := range leases {
+		got = append(got, l.Member+":"+l.Environment+":"+l.User)
+	}
+	if want := []string{"b:production:bob", "a:staging:alice"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("b.Deploys() = %q; want %q", got, want)
+	}
+
+	var waited []Lease
+	acquired := make(chan error, 1)
+	go func() {
+		release, err := b.Acquire(context.Background(), "proj", "staging", "bob", time.Millisecond, func(l Lease) { waited = append(waited, l) })
+		if err == nil {
+			release()
+		}
+		acquired <- err
+	}()
+	time.Sleep(10 * time.Millisecond)
+	release()
+	select {
+	case err := <-acquired:
+		if err != nil {
+			t.Errorf("b.Acquire(ctx, %q, %q, %q, ...) failed with %v; want success", "proj", "staging", "bob", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("b.Acquire did not return after a released the lease")
+	}
+	if len(waited) == 0 || waited[0].Member != "a" {
+		t.Errorf("waited = %#v; want the lease of a", waited)
+	}
+}
+
+func TestRelay(t *testing.T) {
+	s := config.NewMemoryStore()
+	received := make(chan string, 10)
+	var b *Cluster
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b.RelayHandler(func(msg string) { received <- msg }).ServeHTTP(w, r)
+	}))
+	defer srv.Close()
+	a := New(s, Member{ID: "a", URL: "http://127.0.0.1:1"}, "token", time.Minute)
+	b = New(s, Member{ID: "b", URL: srv.URL}, "token", time.Minute)
+	a.heartbeat()
+	b.heartbeat()
+	a.heartbeat()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go a.runRelay(ctx)
+	a.Relay("msg 1")
+	a.Relay("msg 2")
+	for _, want := range []string{"msg 1", "msg 2"} {
+		select {
+		case got := <-received:
+			if got != want {
+				t.Errorf("received %q; want %q", got, want)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("%q not relayed", want)
+		}
+	}
+
+	resp, err := http.Post(srv.URL+RelayPath, "application/json", nil)
+	if err != nil {
+		t.Fatalf("http.Post(%q, ...) failed with %v; want success", srv.URL+RelayPath, err)
+	}
+	resp.Body.Close()
+	if got, want := resp.StatusCode, http.StatusUnauthorized; got != want {
+		t.Errorf("status of a request without the token = %d; want %d", got, want)
+	}
+}
+
+func TestForward(t *testing.T) {
+	s := config.NewMemoryStore()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("b " + r.URL.Path))
+	}))
+	defer srv.Close()
+	a := New(s, Member{ID: "a.example.com"}, "token", time.Minute)
+	b := New(s, Member{ID: "b.example.com", URL: srv.URL}, "token", time.Minute)
+	b.heartbeat()
+	a.heartbeat()
+
+	h := a.Forward(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("a " + r.URL.Path))
+	}))
+	for _, spec := range []struct {
+		path, want string
+	}{
+		{path: "/deploys/b.example.com.3/cancel", want: "b /deploys/b.example.com.3/cancel"},
+		{path: "/deploys/a.example.com.3/cancel", want: "a /deploys/a.example.com.3/cancel"},
+		{path: "/deploys/c.example.com.3/cancel", want: "a /deploys/c.example.com.3/cancel"},
+		{path: "/deploys/3/cancel", want: "a /deploys/3/cancel"},
+	} {
+		local := httptest.NewServer(h)
+		resp, err := http.Post(local.URL+spec.path, "text/plain", nil)
+		if err != nil {
+			local.Close()
+			t.Fatalf("http.Post(%q, ...) failed with %v; want success", spec.path, err)
+		}
+		buf, err := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		local.Close()
+		if err != nil {
+			t.Fatalf("ioutil.ReadAll(resp.Body) failed with %v; want success", err)
+		}
+		if got := string(buf); got != spec.want {
+			t.Errorf("response to %s = %q; want %q", spec.path, got, spec.want)
+		}
+	}
+}
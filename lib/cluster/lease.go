@@ -0,0 +1,134 @@
+package cluster
+
+import (
+	"encoding/json"
+	"path"
+	"sort"
+	"time"
+
+	"github.com/gengo/goship/lib/config"
+	"github.com/gengo/goship/lib/deploy"
+	"github.com/golang/glog"
+	"golang.org/x/net/context"
+)
+
+// Lease is the ownership of the deployment in an environment by a member.
+type Lease struct {
+	Member      string    `json:"member"`
+	Project     string    `json:"project"`
+	Environment string    `json:"environment"`
+	User        string    `json:"user"`
+	Started     time.Time `json:"started"`
+}
+
+func leaseKey(proj, env string) string {
+	return path.Join(deploysKey, proj, env)
+}
+
+// TryAcquire takes the lease of "env" of "proj" for a deployment requested by "user".
+// It returns deploy.ErrBusy if another deployment in the environment holds the lease on any member.
+// The lease is renewed until "release" is called, and expires if this member stops renewing it.
+func (c *Cluster) TryAcquire(proj, env, user string) (release func(), err error) {
+	buf, err := json.Marshal(Lease{Member: c.self.ID, Project: proj, Environment: env, User: user, Started: time.Now()})
+	if err != nil {
+		return nil, err
+	}
+	key, value := leaseKey(proj, env), string(buf)
+	if _, err := c.store.Create(key, value, c.ttlSeconds()); config.IsExist(err) {
+		return nil, deploy.ErrBusy
+	} else if err != nil {
+		return nil, err
+	}
+
+	stop, stopped := make(chan struct{}), make(chan struct{})
+	go func() {
+		defer close(stopped)
+		for {
+			select {
+			case <-stop:
+				return
+			case <-time.After(c.ttl / 3):
+			}
+			if _, err := c.store.CompareAndSwap(key, value, c.ttlSeconds(), value, 0); err != nil {
+				glog.Errorf("Failed to renew the deploy lease of %s-%s: %v", proj, env, err)
+			}
+		}
+	}()
+	return func() {
+		close(stop)
+		<-stopped
+		if _, err := c.store.CompareAndDelete(key, value, 0); err != nil {
+			glog.Errorf("Failed to release the deploy lease of %s-%s: %v", proj, env, err)
+		}
+	}, nil
+}
+
+// Acquire waits until it takes the lease of "env" of "proj", checking every "interval".
+// It calls "wait" with the lease held by another member before each wait.
+func (c *Cluster) Acquire(ctx context.Context, proj, env, user string, interval time.Duration, wait func(Lease)) (release func(), err error) {
+	for {
+		release, err := c.TryAcquire(proj, env, user)
+		if err != deploy.ErrBusy {
+			return release, err
+		}
+		if l, ok, err := c.lease(proj, env); err == nil && ok && wait != nil {
+			wait(l)
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(interval):
+		}
+	}
+}
+
+// lease returns the lease of "env" of "proj". It returns false if nobody holds it.
+func (c *Cluster) lease(proj, env string) (Lease, bool, error) {
+	resp, err := c.store.Get(leaseKey(proj, env), false, false)
+	if config.IsNotFound(err) {
+		return Lease{}, false, nil
+	}
+	if err != nil {
+		return Lease{}, false, err
+	}
+	var l Lease
+	if err := json.Unmarshal([]byte(resp.Node.Value), &l); err != nil {
+		return Lease{}, false, err
+	}
+	return l, true, nil
+}
+
+// Deploys returns the deploy leases held by the members, sorted by project and environment.
+func (c *Cluster) Deploys() ([]Lease, error) {
+	resp, err := c.store.Get(deploysKey, false, true)
+	if config.IsNotFound(err) {
+		return []Lease{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	leases := []Lease{}
+	for _, p := range resp.Node.Nodes {
+		for _, e := range p.Nodes {
+			var l Lease
+			if err := json.Unmarshal([]byte(e.Value), &l); err != nil {
+				glog.Errorf("Failed to unmarshal deploy lease at %s: %v", e.Key, err)
+				continue
+			}
+			leases = append(leases, l)
+		}
+	}
+	sort.Sort(byEnvironment(leases))
+	return leases, nil
+}
+
+type byEnvironment []Lease
+
+func (ls byEnvironment) Len() int      { return len(ls) }
+func (ls byEnvironment) Swap(i, j int) { ls[i], ls[j] = ls[j], ls[i] }
+func (ls byEnvironment) Less(i, j int) bool {
+	if ls[i].Project != ls[j].Project {
+		return ls[i].Project < ls[j].Project
+	}
+	return ls[i].Environment < ls[j].Environment
+}
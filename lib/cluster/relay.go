@@ -0,0 +1,159 @@
+package cluster
+
+import (
+	"bytes"
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang/glog"
+	"golang.org/x/net/context"
+)
+
+const (
+	// RelayPath is the path at which members receive notifications relayed from the others.
+	RelayPath = "/cluster/relay"
+	// relayBuffer is the number of notifications waiting to be relayed. Notifications are dropped when it is full.
+	relayBuffer = 1024
+	// relayBatch is the maximum number of notifications sent to a member at once.
+	relayBatch = 100
+	// relayTimeout is the maximum duration of sending notifications to a member.
+	relayTimeout = 5 * time.Second
+)
+
+var relayClient = &http.Client{Timeout: relayTimeout}
+
+// Relay sends "msg" to the other members in the background. It never blocks, and drops "msg" if too many notifications are waiting.
+func (c *Cluster) Relay(msg string) {
+	select {
+	case c.relay <- msg:
+	default:
+		glog.Warningf("Dropped a notification relayed to the cluster: %d notification(s) waiting", relayBuffer)
+	}
+}
+
+// runRelay sends notifications passed to Relay to the other members in batches, in order, until "ctx" is done.
+func (c *Cluster) runRelay(ctx context.Context) {
+	for {
+		var batch []string
+		select {
+		case <-ctx.Done():
+			return
+		case msg := <-c.relay:
+			batch = append(batch, msg)
+		}
+	collect:
+		for len(batch) < relayBatch {
+			select {
+			case msg := <-c.relay:
+				batch = append(batch, msg)
+			default:
+				break collect
+			}
+		}
+		buf, err := json.Marshal(batch)
+		if err != nil {
+			glog.Errorf("Failed to marshal notifications into JSON: %v", err)
+			continue
+		}
+		var wg sync.WaitGroup
+		for _, m := range c.Members() {
+			if m.ID == c.self.ID {
+				continue
+			}
+			wg.Add(1)
+			go func(m Member) {
+				defer wg.Done()
+				if err := c.send(m, buf); err != nil {
+					glog.Errorf("Failed to relay %d notification(s) to %s: %v", len(batch), m.ID, err)
+				}
+			}(m)
+		}
+		wg.Wait()
+	}
+}
+
+// send posts the JSON-encoded notifications "buf" to "m".
+func (c *Cluster) send(m Member, buf []byte) error {
+	req, err := http.NewRequest("POST", strings.TrimSuffix(m.URL, "/")+RelayPath, bytes.NewReader(buf))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+c.token)
+	resp, err := relayClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusNoContent {
+		return fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	return nil
+}
+
+// RelayHandler returns an http.Handler at RelayPath which passes notifications relayed from the other members to "receive" in order.
+// Requests must have the token of the cluster as a bearer token.
+func (c *Cluster) RelayHandler(receive func(msg string)) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "POST" {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if c.token == "" || subtle.ConstantTimeCompare([]byte(token), []byte(c.token)) != 1 {
+			http.Error(w, "invalid token", http.StatusUnauthorized)
+			return
+		}
+		var msgs []string
+		if err := json.NewDecoder(r.Body).Decode(&msgs); err != nil {
+			http.Error(w, fmt.Sprintf("invalid notifications: %v", err), http.StatusBadRequest)
+			return
+		}
+		for _, msg := range msgs {
+			receive(msg)
+		}
+		w.WriteHeader(http.StatusNoContent)
+	})
+}
+
+// Owner returns the member which runs the deployment identified by "id", which starts with the ID of the member followed by ".".
+// It returns false if the member is unknown.
+func (c *Cluster) Owner(id string) (Member, bool) {
+	i := strings.LastIndex(id, ".")
+	if i < 0 {
+		return Member{}, false
+	}
+	return c.Member(id[:i])
+}
+
+// Forward returns an http.Handler which forwards requests to /deploys/{id}/{action} to the member running the deployment,
+// so that users can control deployments regardless of the member which the load balancer chose.
+// It passes requests for deployments of this member or unknown members to "h".
+func (c *Cluster) Forward(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		components := strings.Split(r.URL.Path, "/")
+		if len(components) != 4 {
+			h.ServeHTTP(w, r)
+			return
+		}
+		m, ok := c.Owner(components[2])
+		if !ok || m.ID == c.self.ID {
+			h.ServeHTTP(w, r)
+			return
+		}
+		u, err := url.Parse(m.URL)
+		if err != nil {
+			glog.Errorf("Failed to parse URL of member %s: %v", m.ID, err)
+			http.Error(w, err.Error(), http.StatusBadGateway)
+			return
+		}
+		httputil.NewSingleHostReverseProxy(u).ServeHTTP(w, r)
+	})
+}
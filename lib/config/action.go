@@ -0,0 +1,122 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// maxActionRuns is the maximum number of runs of quick actions kept in the history of an environment.
+const maxActionRuns = 100
+
+// Action is a quick action of an environment, e.g. restarting a service or clearing a cache,
+// which runs a command without deploying a revision.
+type Action struct {
+	// Name identifies the action in the environment, e.g. "clear-cache".
+	Name string `json:"name" yaml:"name"`
+	// Command is the command which the action runs.
+	Command string `json:"command" yaml:"command"`
+	// Role is the role required to run the action. RoleDeployer if empty.
+	Role Role `json:"role,omitempty" yaml:"role,omitempty"`
+	// Confirm makes users confirm the action before running it if not empty.
+	// It describes the consequences of the action, e.g. "All the sessions will be dropped".
+	Confirm string `json:"confirm,omitempty" yaml:"confirm,omitempty"`
+	// PerHost makes Command run once for each host of the environment with GOSHIP_HOST environment variable set to the host.
+	// Otherwise Command runs only once for the whole environment.
+	PerHost bool `json:"per_host,omitempty" yaml:"per_host,omitempty"`
+}
+
+// RequiredRole returns the role required to run the action.
+func (a Action) RequiredRole() Role {
+	if a.Role == "" {
+		return RoleDeployer
+	}
+	return a.Role
+}
+
+// Args returns the command of the action split on spaces.
+func (a Action) Args() []string {
+	return strings.Split(a.Command, " ")
+}
+
+// Action returns the quick action of the environment named "name", or false if there is no such action.
+func (e Environment) Action(name string) (Action, bool) {
+	for _, a := range e.Actions {
+		if a.Name == name {
+			return a, true
+		}
+	}
+	return Action{}, false
+}
+
+func validateActions(actions []Action) error {
+	seen := make(map[string]bool)
+	for _, a := range actions {
+		if err := validName(a.Name); err != nil {
+			return fmt.Errorf("invalid action name: %v", err)
+		}
+		if seen[a.Name] {
+			return fmt.Errorf("duplicate action %q", a.Name)
+		}
+		seen[a.Name] = true
+		if strings.TrimSpace(a.Command) == "" {
+			return fmt.Errorf("command of action %q not specified", a.Name)
+		}
+		if a.Role != "" && !a.Role.Valid() {
+			return fmt.Errorf("invalid role %q of action %q", a.Role, a.Name)
+		}
+	}
+	return nil
+}
+
+// ActionRun is an entry of the history of quick actions in an environment.
+type ActionRun struct {
+	// Action is the name of the action.
+	Action string `json:"action"`
+	// User is the name of the user who ran the action.
+	User    string    `json:"user"`
+	Success bool      `json:"success"`
+	Time    time.Time `json:"time"`
+	// Duration is how long the action took.
+	Duration time.Duration `json:"duration"`
+}
+
+func actionRunKey(projectName, projectEnv string) string {
+	return fmt.Sprintf("/goship/action_runs/%s/%s", projectName, projectEnv)
+}
+
+// LoadActionRuns returns the history of quick actions in the environment, oldest first.
+func LoadActionRuns(client ETCDInterface, projectName, projectEnv string) ([]ActionRun, error) {
+	resp, err := client.Get(actionRunKey(projectName, projectEnv), false, false)
+	if IsNotFound(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var runs []ActionRun
+	if err := json.Unmarshal([]byte(resp.Node.Value), &runs); err != nil {
+		return nil, err
+	}
+	return runs, nil
+}
+
+// AddActionRun appends "run" to the history of quick actions in the environment.
+// The oldest runs are dropped when the history gets too long.
+func AddActionRun(client ETCDInterface, projectName, projectEnv string, run ActionRun) error {
+	runs, err := LoadActionRuns(client, projectName, projectEnv)
+	if err != nil {
+		return err
+	}
+	runs = append(runs, run)
+	if len(runs) > maxActionRuns {
+		runs = runs[len(runs)-maxActionRuns:]
+	}
+	buf, err := json.Marshal(runs)
+	if err != nil {
+		return err
+	}
+	_, err = client.Set(actionRunKey(projectName, projectEnv), string(buf), 0)
+	return err
+}
@@ -0,0 +1,94 @@
+package config_test
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/gengo/goship/lib/config"
+)
+
+func TestActionRuns(t *testing.T) {
+	s := config.NewMemoryStore()
+	runs, err := config.LoadActionRuns(s, "test_project", "test_environment")
+	if err != nil {
+		t.Fatalf("config.LoadActionRuns(s, %q, %q) failed with %v; want success", "test_project", "test_environment", err)
+	}
+	if len(runs) != 0 {
+		t.Errorf("config.LoadActionRuns(s, %q, %q) = %#v; want no runs", "test_project", "test_environment", runs)
+	}
+
+	start := time.Date(2015, time.November, 10, 23, 0, 0, 0, time.UTC)
+	var want []config.ActionRun
+	for i := 0; i < 105; i++ {
+		run := config.ActionRun{Action: "clear-cache", User: "alice", Success: i%2 == 0, Time: start.Add(time.Duration(i) * time.Minute), Duration: time.Second}
+		if err := config.AddActionRun(s, "test_project", "test_environment", run); err != nil {
+			t.Fatalf("config.AddActionRun(s, %q, %q, %#v) failed with %v; want success", "test_project", "test_environment", run, err)
+		}
+		want = append(want, run)
+	}
+	runs, err = config.LoadActionRuns(s, "test_project", "test_environment")
+	if err != nil {
+		t.Fatalf("config.LoadActionRuns(s, %q, %q) failed with %v; want success", "test_project", "test_environment", err)
+	}
+	if want = want[5:]; !reflect.DeepEqual(runs, want) {
+		t.Errorf("config.LoadActionRuns(s, %q, %q) = %#v; want the last 100 runs %#v", "test_project", "test_environment", runs, want)
+	}
+}
+
+func TestEnvironmentAction(t *testing.T) {
+	env := config.Environment{
+		Name: "production",
+		Actions: []config.Action{
+			{Name: "clear-cache", Command: "bin/clear-cache"},
+			{Name: "restart", Command: "sudo service app restart", Role: config.RoleAdmin},
+		},
+	}
+	a, ok := env.Action("restart")
+	if !ok {
+		t.Fatalf("env.Action(%q) = _, false; want true", "restart")
+	}
+	if got, want := a.RequiredRole(), config.RoleAdmin; got != want {
+		t.Errorf("a.RequiredRole() = %q; want %q", got, want)
+	}
+	if got, want := a.Args(), []string{"sudo", "service", "app", "restart"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("a.Args() = %q; want %q", got, want)
+	}
+	if a, _ = env.Action("clear-cache"); a.RequiredRole() != config.RoleDeployer {
+		t.Errorf("a.RequiredRole() = %q; want %q", a.RequiredRole(), config.RoleDeployer)
+	}
+	if _, ok := env.Action("migrate"); ok {
+		t.Errorf("env.Action(%q) = _, true; want false", "migrate")
+	}
+}
+
+func TestLoadActions(t *testing.T) {
+	clearCache := config.Action{Name: "clear-cache", Command: "bin/clear-cache", Confirm: "Caches will be cold for a while"}
+	for _, spec := range []struct {
+		actions []config.Action
+		valid   bool
+	}{
+		{actions: []config.Action{clearCache}, valid: true},
+		{
+			actions: []config.Action{
+				clearCache,
+				{Name: "restart", Command: "sudo service app restart", Role: config.RoleAdmin, PerHost: true},
+			},
+			valid: true,
+		},
+		{actions: []config.Action{clearCache, clearCache}},
+		{actions: []config.Action{{Name: "", Command: "bin/clear-cache"}}},
+		{actions: []config.Action{{Name: "clear/cache", Command: "bin/clear-cache"}}},
+		{actions: []config.Action{{Name: "clear-cache"}}},
+		{actions: []config.Action{{Name: "clear-cache", Command: "bin/clear-cache", Role: "operator"}}},
+	} {
+		env, ok := loadEnvironment(t, config.Environment{Name: "production", Deploy: "deploy-command", Actions: spec.actions})
+		if ok != spec.valid {
+			t.Errorf("loaded the project with actions %#v = %t; want %t", spec.actions, ok, spec.valid)
+			continue
+		}
+		if spec.valid && !reflect.DeepEqual(env.Actions, spec.actions) {
+			t.Errorf("env.Actions = %#v; want %#v", env.Actions, spec.actions)
+		}
+	}
+}
@@ -0,0 +1,148 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// ApprovalState is a state of an approval request.
+type ApprovalState string
+
+const (
+	// ApprovalPending means the deployment is waiting for a decision.
+	ApprovalPending = ApprovalState("pending")
+	// ApprovalApproved means the deployment has been approved.
+	ApprovalApproved = ApprovalState("approved")
+	// ApprovalRejected means the deployment has been rejected.
+	ApprovalRejected = ApprovalState("rejected")
+)
+
+var (
+	// ErrNoSuchApproval is returned when an approval request to decide is not found.
+	ErrNoSuchApproval = errors.New("no such approval request")
+	// ErrAlreadyDecided is returned when an approval request has already been approved or rejected.
+	ErrAlreadyDecided = errors.New("approval request already decided")
+)
+
+// Approval is a request to approve a deployment in an environment which requires approvals.
+type Approval struct {
+	// ID identifies the approval request in the environment.
+	ID string `json:"id"`
+	// From and To are the revisions of the deployment.
+	From string `json:"from"`
+	To   string `json:"to"`
+	// User is the name of the user who requested the deployment.
+	User  string        `json:"user"`
+	State ApprovalState `json:"state"`
+	// Approver is the name of the user who approved or rejected the deployment.
+	Approver string    `json:"approver,omitempty"`
+	Time     time.Time `json:"time"`
+	// DecidedAt is when the request was approved or rejected.
+	DecidedAt time.Time `json:"decided_at,omitempty"`
+	// FromSource and ToSource are the source code revisions of the deployment if they differ from From and To.
+	FromSource string `json:"from_source,omitempty"`
+	ToSource   string `json:"to_source,omitempty"`
+	// Labels are the labels of the deployment.
+	Labels []string `json:"labels,omitempty"`
+	// IgnoreStatus and Emergency are the checks which the user bypassed, and Reason justifies them.
+	// They are evaluated again when the deployment gets approved.
+	Reason       string `json:"reason,omitempty"`
+	IgnoreStatus bool   `json:"ignore_status,omitempty"`
+	Emergency    bool   `json:"emergency,omitempty"`
+}
+
+func approvalKey(projectName, projectEnv string) string {
+	return fmt.Sprintf("/goship/approvals/%s/%s", projectName, projectEnv)
+}
+
+// LoadApprovals returns the approval requests of the environment in the order of requests.
+func LoadApprovals(client ETCDInterface, projectName, projectEnv string) ([]Approval, error) {
+	resp, err := client.Get(approvalKey(projectName, projectEnv), false, false)
+	if IsNotFound(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return decodeApprovals(resp.Node.Value)
+}
+
+func decodeApprovals(value string) ([]Approval, error) {
+	if value == "" {
+		return nil, nil
+	}
+	var approvals []Approval
+	if err := json.Unmarshal([]byte(value), &approvals); err != nil {
+		return nil, err
+	}
+	return approvals, nil
+}
+
+// RequestApproval adds a pending approval request "a" to the environment.
+// It returns "a" with a new ID assigned.
+func RequestApproval(client ETCDInterface, projectName, projectEnv string, a Approval) (Approval, error) {
+	if projectName == "" || projectEnv == "" {
+		return Approval{}, fmt.Errorf("Missing parameters")
+	}
+	err := updateApprovals(client, projectName, projectEnv, func(approvals []Approval) ([]Approval, error) {
+		var last int
+		for _, other := range approvals {
+			if id, err := strconv.Atoi(other.ID); err == nil && id > last {
+				last = id
+			}
+		}
+		a.ID, a.State = strconv.Itoa(last+1), ApprovalPending
+		return append(approvals, a), nil
+	})
+	if err != nil {
+		return Approval{}, err
+	}
+	return a, nil
+}
+
+// DecideApproval approves or rejects the pending approval request identified by "id" on behalf of "approver".
+// It returns the decided request.
+func DecideApproval(client ETCDInterface, projectName, projectEnv, id, approver string, approve bool) (Approval, error) {
+	var decided Approval
+	err := updateApprovals(client, projectName, projectEnv, func(approvals []Approval) ([]Approval, error) {
+		for i := range approvals {
+			a := &approvals[i]
+			if a.ID != id {
+				continue
+			}
+			if a.State != ApprovalPending {
+				return nil, ErrAlreadyDecided
+			}
+			a.State, a.Approver, a.DecidedAt = ApprovalRejected, approver, time.Now()
+			if approve {
+				a.State = ApprovalApproved
+			}
+			decided = *a
+			return approvals, nil
+		}
+		return nil, ErrNoSuchApproval
+	})
+	if err != nil {
+		return Approval{}, err
+	}
+	return decided, nil
+}
+
+// updateApprovals replaces the approval requests of the environment with what "change" returns for the current ones.
+// Approvals are decided at most once even if instances of Goship decide them concurrently.
+func updateApprovals(client ETCDInterface, projectName, projectEnv string, change func([]Approval) ([]Approval, error)) error {
+	return update(client, approvalKey(projectName, projectEnv), func(value string) (string, error) {
+		approvals, err := decodeApprovals(value)
+		if err != nil {
+			return "", err
+		}
+		if approvals, err = change(approvals); err != nil {
+			return "", err
+		}
+		buf, err := json.Marshal(approvals)
+		return string(buf), err
+	})
+}
@@ -0,0 +1,40 @@
+package config_test
+
+import (
+	"testing"
+
+	"github.com/gengo/goship/lib/config"
+)
+
+func TestApprovals(t *testing.T) {
+	s := config.NewMemoryStore()
+	a, err := config.RequestApproval(s, "proj", "env", config.Approval{From: "abc", To: "def", User: "alice"})
+	if err != nil {
+		t.Fatalf("config.RequestApproval(s, %q, %q, a) failed with %v; want success", "proj", "env", err)
+	}
+	if a.ID != "1" || a.State != config.ApprovalPending {
+		t.Errorf("config.RequestApproval(s, %q, %q, a) = %#v; want pending request 1", "proj", "env", a)
+	}
+
+	if _, err := config.DecideApproval(s, "proj", "env", "2", "bob", true); err != config.ErrNoSuchApproval {
+		t.Errorf("config.DecideApproval(s, %q, %q, %q, %q, true) failed with %v; want %v", "proj", "env", "2", "bob", err, config.ErrNoSuchApproval)
+	}
+	got, err := config.DecideApproval(s, "proj", "env", a.ID, "bob", true)
+	if err != nil {
+		t.Fatalf("config.DecideApproval(s, %q, %q, %q, %q, true) failed with %v; want success", "proj", "env", a.ID, "bob", err)
+	}
+	if got.State != config.ApprovalApproved || got.Approver != "bob" {
+		t.Errorf("config.DecideApproval(s, %q, %q, %q, %q, true) = %#v; want approved by bob", "proj", "env", a.ID, "bob", got)
+	}
+	if _, err := config.DecideApproval(s, "proj", "env", a.ID, "carol", false); err != config.ErrAlreadyDecided {
+		t.Errorf("config.DecideApproval(s, %q, %q, %q, %q, false) failed with %v; want %v", "proj", "env", a.ID, "carol", err, config.ErrAlreadyDecided)
+	}
+
+	approvals, err := config.LoadApprovals(s, "proj", "env")
+	if err != nil {
+		t.Fatalf("config.LoadApprovals(s, %q, %q) failed with %v; want success", "proj", "env", err)
+	}
+	if len(approvals) != 1 || approvals[0].State != config.ApprovalApproved {
+		t.Errorf("config.LoadApprovals(s, %q, %q) = %#v; want the approved request", "proj", "env", approvals)
+	}
+}
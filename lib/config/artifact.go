@@ -0,0 +1,111 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"text/template"
+)
+
+// ArtifactType is a kind of prebuilt artifacts which deployments ship.
+type ArtifactType string
+
+const (
+	// ArtifactTarball is an archive downloaded over HTTP and verified with its SHA-256 checksum.
+	ArtifactTarball = ArtifactType("tarball")
+	// ArtifactImage is a container image verified with its cosign signature.
+	ArtifactImage = ArtifactType("image")
+)
+
+// Artifact describes the artifact deployed into an environment and how its integrity is verified before each deployment.
+// Its templates take the same parameters as Environment.K8sImage.
+type Artifact struct {
+	Type ArtifactType `json:"type" yaml:"type"`
+	// URL is a template of the URL of the tarball, or of the reference of the image.
+	// K8sImage of the environment is used for images if empty.
+	URL string `json:"url,omitempty" yaml:"url,omitempty"`
+	// ChecksumURL is a template of the URL of the SHA-256 checksum of the tarball in the format of sha256sum.
+	// URL followed by ".sha256" is used if empty.
+	ChecksumURL string `json:"checksum_url,omitempty" yaml:"checksum_url,omitempty"`
+	// HostPath is a template of the path where the tarball is staged on each host before deployments, e.g. by CI.
+	// The checksum of the file on each host is also verified over SSH if not empty.
+	HostPath string `json:"host_path,omitempty" yaml:"host_path,omitempty"`
+	// CosignKey is the path of the public key on the Goship server which verifies signatures of images with cosign.
+	CosignKey string `json:"cosign_key,omitempty" yaml:"cosign_key,omitempty"`
+	// Required refuses deployments of artifacts which cannot be verified, e.g. without checksums or signatures.
+	// Otherwise such artifacts are deployed with a warning. Artifacts whose checksums mismatch are always refused.
+	Required bool `json:"required,omitempty" yaml:"required,omitempty"`
+}
+
+func (a Artifact) validate() error {
+	switch a.Type {
+	case ArtifactTarball:
+		if a.URL == "" {
+			return errors.New("url not specified")
+		}
+		if a.CosignKey != "" {
+			return errors.New("cosign_key cannot be used with tarballs")
+		}
+	case ArtifactImage:
+		if a.CosignKey == "" {
+			return errors.New("cosign_key not specified")
+		}
+		if a.ChecksumURL != "" || a.HostPath != "" {
+			return errors.New("checksum_url and host_path cannot be used with images")
+		}
+	default:
+		return fmt.Errorf("unknown type %q", a.Type)
+	}
+	for _, t := range []string{a.URL, a.ChecksumURL, a.HostPath} {
+		if _, err := template.New("artifact").Parse(t); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ArtifactURL returns the URL of the tarball or the reference of the image of "rev" of "proj" to deploy into the environment.
+func (e Environment) ArtifactURL(proj, rev string) (string, error) {
+	a := e.Artifact
+	if a == nil {
+		return "", errors.New("no artifact configured")
+	}
+	if a.Type == ArtifactImage && a.URL == "" {
+		return e.Image(proj, rev)
+	}
+	return e.expand(a.URL, proj, rev)
+}
+
+// ArtifactChecksumURL returns the URL of the checksum of the tarball of "rev" of "proj".
+func (e Environment) ArtifactChecksumURL(proj, rev string) (string, error) {
+	if e.Artifact == nil || e.Artifact.ChecksumURL == "" {
+		u, err := e.ArtifactURL(proj, rev)
+		if err != nil {
+			return "", err
+		}
+		return u + ".sha256", nil
+	}
+	return e.expand(e.Artifact.ChecksumURL, proj, rev)
+}
+
+// ArtifactHostPath returns the path of the tarball of "rev" of "proj" on the hosts, or an empty string if it is not staged on the hosts.
+func (e Environment) ArtifactHostPath(proj, rev string) (string, error) {
+	if e.Artifact == nil || e.Artifact.HostPath == "" {
+		return "", nil
+	}
+	return e.expand(e.Artifact.HostPath, proj, rev)
+}
+
+// expand executes the template "text" for "rev" of "proj".
+func (e Environment) expand(text, proj, rev string) (string, error) {
+	tmpl, err := template.New(e.Name).Parse(text)
+	if err != nil {
+		return "", err
+	}
+	var buf bytes.Buffer
+	params := K8sImageParams{Project: proj, Environment: e.Name, Revision: rev}
+	if err := tmpl.Execute(&buf, params); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
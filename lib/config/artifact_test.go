@@ -0,0 +1,39 @@
+package config_test
+
+import (
+	"testing"
+
+	"github.com/gengo/goship/lib/config"
+)
+
+func TestLoadArtifact(t *testing.T) {
+	for _, spec := range []struct {
+		env   config.Environment
+		valid bool
+	}{
+		{
+			env:   config.Environment{Name: "production", Deploy: "deploy-command", Artifact: &config.Artifact{Type: config.ArtifactTarball, URL: "https://artifacts.example.com/{{.Revision}}.tar.gz", Required: true}},
+			valid: true,
+		},
+		{
+			env:   config.Environment{Name: "production", Deploy: "deploy-command", Artifact: &config.Artifact{Type: config.ArtifactImage, URL: "gcr.io/example/app:{{.Revision}}", CosignKey: "/etc/goship/cosign.pub"}},
+			valid: true,
+		},
+		{
+			env: config.Environment{
+				Name: "production", K8sNamespace: "web", K8sDeployment: "app", K8sImage: "gcr.io/example/app:{{.Revision}}",
+				Artifact: &config.Artifact{Type: config.ArtifactImage, CosignKey: "/etc/goship/cosign.pub"},
+			},
+			valid: true,
+		},
+		{env: config.Environment{Name: "production", Deploy: "deploy-command", Artifact: &config.Artifact{Type: config.ArtifactTarball}}},
+		{env: config.Environment{Name: "production", Deploy: "deploy-command", Artifact: &config.Artifact{Type: config.ArtifactTarball, URL: "https://artifacts.example.com/{{.Revision}.tar.gz"}}},
+		{env: config.Environment{Name: "production", Deploy: "deploy-command", Artifact: &config.Artifact{Type: config.ArtifactImage, CosignKey: "/etc/goship/cosign.pub"}}},
+		{env: config.Environment{Name: "production", Deploy: "deploy-command", Artifact: &config.Artifact{Type: config.ArtifactImage, URL: "gcr.io/example/app:{{.Revision}}"}}},
+		{env: config.Environment{Name: "production", Deploy: "deploy-command", Artifact: &config.Artifact{Type: "jar", URL: "https://artifacts.example.com/app.jar"}}},
+	} {
+		if _, ok := loadEnvironment(t, spec.env); ok != spec.valid {
+			t.Errorf("loaded the project with artifact %#v = %t; want %t", spec.env.Artifact, ok, spec.valid)
+		}
+	}
+}
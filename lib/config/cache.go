@@ -0,0 +1,227 @@
+package config
+
+import (
+	"errors"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/coreos/go-etcd/etcd"
+	"github.com/gengo/goship/lib/metrics"
+	"github.com/golang/glog"
+	"golang.org/x/net/context"
+)
+
+var (
+	configLoads = metrics.NewCounterVec("goship_config_loads_total", "Number of configurations loaded from etcd into the cache.")
+
+	errDeleteUnsupported = errors.New("the backend cannot delete keys")
+)
+
+const (
+	// watchPrefix is the key which Cache.Watch watches for changes.
+	watchPrefix = "/goship"
+	// watchRetryInterval is the interval of reconnecting to the backend after a watch fails.
+	watchRetryInterval = 5 * time.Second
+)
+
+// Watcher is implemented by etcd clients which can watch changes of keys.
+type Watcher interface {
+	Watch(prefix string, waitIndex uint64, recursive bool, receiver chan *etcd.Response, stop chan bool) (*etcd.Response, error)
+}
+
+// Cache is an ETCDInterface which keeps the configuration loaded from another ETCDInterface.
+// Load returns a snapshot of the cached configuration instead of reading etcd when it is given a Cache.
+//
+// The cached configuration is discarded when a configuration key is set or deleted through the Cache,
+// when Watch observes a change of a configuration key or when Reload is called.
+// Other keys, e.g. locks and schedules, are always read from the backend.
+type Cache struct {
+	client ETCDInterface
+
+	mu       sync.Mutex
+	cfg      *Config
+	loadedAt time.Time
+}
+
+// NewCache returns a new Cache of the configuration in "client".
+func NewCache(client ETCDInterface) *Cache {
+	return &Cache{client: client}
+}
+
+// Get reads "key" from the backend.
+func (c *Cache) Get(key string, sort, recursive bool) (*etcd.Response, error) {
+	return c.client.Get(key, sort, recursive)
+}
+
+// Set writes "value" into "key" in the backend.
+func (c *Cache) Set(key, value string, ttl uint64) (*etcd.Response, error) {
+	resp, err := c.client.Set(key, value, ttl)
+	if isConfigKey(key) {
+		c.Invalidate()
+	}
+	return resp, err
+}
+
+// Delete deletes "key" from the backend.
+// It fails if the backend does not implement Deleter.
+func (c *Cache) Delete(key string, recursive bool) (*etcd.Response, error) {
+	d, ok := c.client.(Deleter)
+	if !ok {
+		return nil, errDeleteUnsupported
+	}
+	resp, err := d.Delete(key, recursive)
+	if isConfigKey(key) {
+		c.Invalidate()
+	}
+	return resp, err
+}
+
+// Create creates "key" in the backend.
+// It fails if the backend does not implement Swapper.
+func (c *Cache) Create(key, value string, ttl uint64) (*etcd.Response, error) {
+	s, ok := c.client.(Swapper)
+	if !ok {
+		return nil, errSwapUnsupported
+	}
+	resp, err := s.Create(key, value, ttl)
+	if isConfigKey(key) {
+		c.Invalidate()
+	}
+	return resp, err
+}
+
+// CompareAndSwap swaps the value of "key" in the backend.
+// It fails if the backend does not implement Swapper.
+func (c *Cache) CompareAndSwap(key, value string, ttl uint64, prevValue string, prevIndex uint64) (*etcd.Response, error) {
+	s, ok := c.client.(Swapper)
+	if !ok {
+		return nil, errSwapUnsupported
+	}
+	resp, err := s.CompareAndSwap(key, value, ttl, prevValue, prevIndex)
+	if isConfigKey(key) {
+		c.Invalidate()
+	}
+	return resp, err
+}
+
+// Config returns a snapshot of the cached configuration.
+// It loads the configuration from the backend if it is not cached.
+// Callers can modify the returned slices without affecting the cache, but not the values pointed by the configuration.
+func (c *Cache) Config() (Config, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.cfg == nil {
+		if err := c.load(); err != nil {
+			return Config{}, err
+		}
+	}
+	return c.cfg.clone(), nil
+}
+
+// Reload loads the configuration from the backend regardless of the cache, and returns a snapshot of it.
+// The cache is kept unchanged if it fails.
+func (c *Cache) Reload() (Config, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err := c.load(); err != nil {
+		return Config{}, err
+	}
+	return c.cfg.clone(), nil
+}
+
+// LoadedAt returns when the cached configuration was loaded.
+// It returns zero if nothing is cached.
+func (c *Cache) LoadedAt() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.cfg == nil {
+		return time.Time{}
+	}
+	return c.loadedAt
+}
+
+// Invalidate discards the cached configuration.
+func (c *Cache) Invalidate() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.cfg = nil
+}
+
+func (c *Cache) load() error {
+	cfg, err := load(c.client)
+	if err != nil {
+		return err
+	}
+	configLoads.Inc()
+	c.cfg, c.loadedAt = &cfg, time.Now()
+	return nil
+}
+
+// Watch invalidates the cache whenever "w" reports changes of configuration keys until "ctx" is done.
+// "w" must watch the same store as the backend of the cache.
+// It reconnects after watchRetryInterval if the watch fails.
+func (c *Cache) Watch(ctx context.Context, w Watcher) {
+	for {
+		receiver, stop := make(chan *etcd.Response), make(chan bool)
+		done := make(chan error, 1)
+		go func() {
+			_, err := w.Watch(watchPrefix, 0, true, receiver, stop)
+			done <- err
+		}()
+	watch:
+		for {
+			select {
+			case <-ctx.Done():
+				close(stop)
+				return
+			case resp, ok := <-receiver:
+				if !ok {
+					receiver = nil
+					continue
+				}
+				if resp.Node != nil && isConfigKey(resp.Node.Key) {
+					glog.V(1).Infof("Configuration changed at %s", resp.Node.Key)
+					c.Invalidate()
+				}
+			case err := <-done:
+				glog.Errorf("Failed to watch %s: %v", watchPrefix, err)
+				break watch
+			}
+		}
+		// changes might have been missed while disconnected
+		c.Invalidate()
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(watchRetryInterval):
+		}
+	}
+}
+
+// isConfigKey returns true if "key" is a part of the configuration which Load reads.
+func isConfigKey(key string) bool {
+	key = cleanKey(key)
+	return key == "/goship/config" || key == "/goship" || key == "/goship/projects" || strings.HasPrefix(key, "/goship/projects/")
+}
+
+// clone returns a copy of "c" which shares no slices of projects and environments with "c".
+func (c Config) clone() Config {
+	if c.Projects != nil {
+		projs := make([]Project, len(c.Projects))
+		for i, p := range c.Projects {
+			if p.Environments != nil {
+				p.Environments = append([]Environment(nil), p.Environments...)
+			}
+			projs[i] = p
+		}
+		c.Projects = projs
+	}
+	if c.Freezes != nil {
+		c.Freezes = append([]Freeze(nil), c.Freezes...)
+	}
+	if c.Admins != nil {
+		c.Admins = append([]string(nil), c.Admins...)
+	}
+	return c
+}
@@ -0,0 +1,184 @@
+package config_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/coreos/go-etcd/etcd"
+	"github.com/gengo/goship/lib/config"
+	"golang.org/x/net/context"
+)
+
+// countingStore counts reads of the global configuration.
+type countingStore struct {
+	*config.MemoryStore
+	reads int
+}
+
+func (s *countingStore) Get(key string, sort, recursive bool) (*etcd.Response, error) {
+	if key == "/goship/config" {
+		s.reads++
+	}
+	return s.MemoryStore.Get(key, sort, recursive)
+}
+
+func newCountingStore(t *testing.T) *countingStore {
+	s := &countingStore{MemoryStore: config.NewMemoryStore()}
+	cfg := config.Config{
+		DeployUser: "deployer",
+		Projects: []config.Project{
+			{
+				Name:         "proj",
+				Environments: []config.Environment{{Name: "staging", Deploy: "deploy-command"}},
+			},
+		},
+	}
+	if err := config.Store(s, cfg); err != nil {
+		t.Fatalf("config.Store(s, %#v) failed with %v; want success", cfg, err)
+	}
+	return s
+}
+
+func TestCacheLoadsOnce(t *testing.T) {
+	s := newCountingStore(t)
+	c := config.NewCache(s)
+	for i := 0; i < 3; i++ {
+		cfg, err := config.Load(c)
+		if err != nil {
+			t.Fatalf("config.Load(c) failed with %v; want success", err)
+		}
+		if got, want := len(cfg.Projects), 1; got != want {
+			t.Errorf("len(cfg.Projects) = %d; want %d", got, want)
+		}
+	}
+	if got, want := s.reads, 1; got != want {
+		t.Errorf("s.reads = %d; want %d", got, want)
+	}
+
+	if err := config.LockEnvironment(c, "proj", "staging", config.Lock{User: "alice", Reason: "testing"}); err != nil {
+		t.Fatalf("config.LockEnvironment failed with %v; want success", err)
+	}
+	if _, err := config.Load(c); err != nil {
+		t.Fatalf("config.Load(c) failed with %v; want success", err)
+	}
+	if got, want := s.reads, 1; got != want {
+		t.Errorf("s.reads = %d; want %d after locking", got, want)
+	}
+
+	p := config.Project{Name: "other", Environments: []config.Environment{{Name: "live", Deploy: "deploy-command"}}}
+	if err := config.StoreProject(c, p); err != nil {
+		t.Fatalf("config.StoreProject(c, %#v) failed with %v; want success", p, err)
+	}
+	cfg, err := config.Load(c)
+	if err != nil {
+		t.Fatalf("config.Load(c) failed with %v; want success", err)
+	}
+	if got, want := len(cfg.Projects), 2; got != want {
+		t.Errorf("len(cfg.Projects) = %d; want %d after storing a project", got, want)
+	}
+	if got, want := s.reads, 2; got != want {
+		t.Errorf("s.reads = %d; want %d", got, want)
+	}
+}
+
+func TestCacheSnapshot(t *testing.T) {
+	c := config.NewCache(newCountingStore(t))
+	cfg, err := c.Config()
+	if err != nil {
+		t.Fatalf("c.Config() failed with %v; want success", err)
+	}
+	cfg.Projects[0].Name = "modified"
+	cfg.Projects[0].Environments[0].Name = "modified"
+	cfg.Projects = append(cfg.Projects, config.Project{Name: "appended"})
+
+	cfg, err = c.Config()
+	if err != nil {
+		t.Fatalf("c.Config() failed with %v; want success", err)
+	}
+	if got, want := len(cfg.Projects), 1; got != want {
+		t.Fatalf("len(cfg.Projects) = %d; want %d", got, want)
+	}
+	if got, want := cfg.Projects[0].Name, "proj"; got != want {
+		t.Errorf("cfg.Projects[0].Name = %q; want %q", got, want)
+	}
+	if got, want := cfg.Projects[0].Environments[0].Name, "staging"; got != want {
+		t.Errorf("cfg.Projects[0].Environments[0].Name = %q; want %q", got, want)
+	}
+}
+
+func TestCacheReload(t *testing.T) {
+	s := newCountingStore(t)
+	c := config.NewCache(s)
+	if _, err := c.Config(); err != nil {
+		t.Fatalf("c.Config() failed with %v; want success", err)
+	}
+	// bypasses the cache
+	p := config.Project{Name: "other", Environments: []config.Environment{{Name: "live", Deploy: "deploy-command"}}}
+	if err := config.StoreProject(s, p); err != nil {
+		t.Fatalf("config.StoreProject(s, %#v) failed with %v; want success", p, err)
+	}
+	cfg, err := c.Config()
+	if err != nil {
+		t.Fatalf("c.Config() failed with %v; want success", err)
+	}
+	if got, want := len(cfg.Projects), 1; got != want {
+		t.Errorf("len(cfg.Projects) = %d; want %d before reloading", got, want)
+	}
+	cfg, err = c.Reload()
+	if err != nil {
+		t.Fatalf("c.Reload() failed with %v; want success", err)
+	}
+	if got, want := len(cfg.Projects), 2; got != want {
+		t.Errorf("len(cfg.Projects) = %d; want %d after reloading", got, want)
+	}
+}
+
+// fakeWatcher reports changes of "keys", closes "sent" and then blocks until stopped.
+type fakeWatcher struct {
+	keys []string
+	sent chan struct{}
+}
+
+func (w fakeWatcher) Watch(prefix string, waitIndex uint64, recursive bool, receiver chan *etcd.Response, stop chan bool) (*etcd.Response, error) {
+	for _, k := range w.keys {
+		select {
+		case receiver <- &etcd.Response{Node: &etcd.Node{Key: k}}:
+		case <-stop:
+			return nil, errors.New("stopped")
+		}
+	}
+	close(w.sent)
+	<-stop
+	return nil, errors.New("stopped")
+}
+
+func TestCacheWatch(t *testing.T) {
+	for _, spec := range []struct {
+		key         string
+		invalidated bool
+	}{
+		{key: "/goship/config", invalidated: true},
+		{key: "/goship/projects/proj/environments/staging", invalidated: true},
+		{key: "/goship/locks/proj/staging"},
+		{key: "/goship/audit/00000000000000000001"},
+	} {
+		c := config.NewCache(newCountingStore(t))
+		if _, err := c.Config(); err != nil {
+			t.Fatalf("c.Config() failed with %v; want success", err)
+		}
+		ctx, cancel := context.WithCancel(context.Background())
+		// receiving the second key means that the first one has been processed
+		w := fakeWatcher{keys: []string{spec.key, "/goship/locks/sync"}, sent: make(chan struct{})}
+		go c.Watch(ctx, w)
+		select {
+		case <-w.sent:
+		case <-time.After(time.Second):
+			t.Fatalf("timed out watching %s", spec.key)
+		}
+		if got, want := c.LoadedAt().IsZero(), spec.invalidated; got != want {
+			t.Errorf("c.LoadedAt().IsZero() = %t; want %t after a change of %s", got, want, spec.key)
+		}
+		cancel()
+	}
+}
@@ -0,0 +1,30 @@
+package config
+
+import "errors"
+
+// CIToken authenticates a CI system which asks Goship whether revisions can be deployed.
+type CIToken struct {
+	// Name describes the CI system, e.g. "jenkins".
+	Name string `json:"name" yaml:"name"`
+	// Token is sent by the CI system as a bearer token.
+	Token string `json:"token" yaml:"token"`
+	// User is the Goship user on whose behalf the checks are evaluated, e.g. a bot account with the deployer role.
+	User string `json:"user" yaml:"user"`
+}
+
+func validateCITokens(tokens []CIToken) error {
+	seen := make(map[string]bool)
+	for _, t := range tokens {
+		if t.Name == "" {
+			return errors.New("name not specified")
+		}
+		if t.Token == "" || t.User == "" {
+			return errors.New("token and user must be specified in " + t.Name)
+		}
+		if seen[t.Token] {
+			return errors.New("duplicate token in " + t.Name)
+		}
+		seen[t.Token] = true
+	}
+	return nil
+}
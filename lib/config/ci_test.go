@@ -0,0 +1,28 @@
+package config_test
+
+import (
+	"testing"
+
+	"github.com/gengo/goship/lib/config"
+)
+
+func TestLoadCITokens(t *testing.T) {
+	for _, spec := range []struct {
+		tokens []config.CIToken
+		valid  bool
+	}{
+		{tokens: []config.CIToken{{Name: "jenkins", Token: "secret", User: "deploy-bot"}}, valid: true},
+		{tokens: []config.CIToken{{Name: "jenkins", Token: "secret"}}},
+		{tokens: []config.CIToken{{Token: "secret", User: "deploy-bot"}}},
+		{tokens: []config.CIToken{
+			{Name: "jenkins", Token: "secret", User: "deploy-bot"},
+			{Name: "travis", Token: "secret", User: "deploy-bot"},
+		}},
+	} {
+		cfg := exampleConfig()
+		cfg.CITokens = spec.tokens
+		if _, err := storeAndLoad(t, cfg); (err == nil) != spec.valid {
+			t.Errorf("config.Load(s) failed with %v; want success = %v; tokens = %#v", err, spec.valid, spec.tokens)
+		}
+	}
+}
@@ -0,0 +1,38 @@
+package config
+
+import "errors"
+
+// ClientCert maps client certificates of machine clients to Goship users.
+type ClientCert struct {
+	// CommonName is the common name in the subject of the certificate, e.g. "jenkins.example.com".
+	CommonName string `json:"common_name" yaml:"common_name"`
+	// User is the Goship user on whose behalf the client makes requests, e.g. a service account with the deployer role.
+	User string `json:"user" yaml:"user"`
+}
+
+// ClientCertUser returns the name of the user of the client certificate with "commonName", and false if no user is mapped to it.
+func (c Config) ClientCertUser(commonName string) (string, bool) {
+	for _, cc := range c.ClientCerts {
+		if cc.CommonName == commonName {
+			return cc.User, true
+		}
+	}
+	return "", false
+}
+
+func validateClientCerts(certs []ClientCert) error {
+	seen := make(map[string]bool)
+	for _, cc := range certs {
+		if cc.CommonName == "" {
+			return errors.New("common_name not specified")
+		}
+		if cc.User == "" {
+			return errors.New("user not specified for " + cc.CommonName)
+		}
+		if seen[cc.CommonName] {
+			return errors.New("duplicate common_name " + cc.CommonName)
+		}
+		seen[cc.CommonName] = true
+	}
+	return nil
+}
@@ -0,0 +1,38 @@
+package config_test
+
+import (
+	"testing"
+
+	"github.com/gengo/goship/lib/config"
+)
+
+func TestLoadClientCerts(t *testing.T) {
+	for _, spec := range []struct {
+		certs []config.ClientCert
+		valid bool
+	}{
+		{certs: []config.ClientCert{{CommonName: "jenkins.example.com", User: "deploy-bot"}}, valid: true},
+		{certs: []config.ClientCert{{CommonName: "jenkins.example.com"}}},
+		{certs: []config.ClientCert{{User: "deploy-bot"}}},
+		{certs: []config.ClientCert{
+			{CommonName: "jenkins.example.com", User: "deploy-bot"},
+			{CommonName: "jenkins.example.com", User: "other-bot"},
+		}},
+	} {
+		cfg := exampleConfig()
+		cfg.ClientCerts = spec.certs
+		c, err := storeAndLoad(t, cfg)
+		if got := err == nil; got != spec.valid {
+			t.Errorf("config.Load(s) failed with %v; want success = %v; certs = %#v", err, spec.valid, spec.certs)
+		}
+		if !spec.valid {
+			continue
+		}
+		if got, ok := c.ClientCertUser("jenkins.example.com"); !ok || got != "deploy-bot" {
+			t.Errorf("c.ClientCertUser(%q) = %q, %t; want %q, true", "jenkins.example.com", got, ok, "deploy-bot")
+		}
+		if got, ok := c.ClientCertUser("unknown.example.com"); ok {
+			t.Errorf("c.ClientCertUser(%q) = %q, %t; want false", "unknown.example.com", got, ok)
+		}
+	}
+}
@@ -0,0 +1,223 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// maxCommandRuns is the maximum number of remote commands kept in the history of an environment.
+	maxCommandRuns = 100
+	// defaultCommandTimeout is the maximum duration of remote commands unless RemoteCommands.Timeout is given.
+	defaultCommandTimeout = time.Minute
+)
+
+// RemoteCommands lets users run one-off commands on the hosts of an environment over SSH, e.g. to inspect them during incidents.
+type RemoteCommands struct {
+	// Allowed are the pre-approved commands which deployers of the environment can run, e.g. "uptime".
+	// Admins of the environment can also run any other command.
+	Allowed []string `json:"allowed,omitempty" yaml:"allowed,omitempty"`
+	// RequireApproval makes commands wait until another user who can run them approves them, e.g. in production.
+	// Approvers of the environment must approve them if listed.
+	RequireApproval bool `json:"require_approval,omitempty" yaml:"require_approval,omitempty"`
+	// Timeout is the maximum duration of a command, e.g. "5m". One minute if empty.
+	Timeout string `json:"timeout,omitempty" yaml:"timeout,omitempty"`
+}
+
+// RequiredRole returns the role required to run "command": deployers can run the allowed commands and admins can run any command.
+func (r RemoteCommands) RequiredRole(command string) Role {
+	if contains(r.Allowed, command) {
+		return RoleDeployer
+	}
+	return RoleAdmin
+}
+
+// CommandTimeout returns the maximum duration of a command.
+func (r RemoteCommands) CommandTimeout() time.Duration {
+	d, err := time.ParseDuration(r.Timeout)
+	if err != nil || d <= 0 {
+		return defaultCommandTimeout
+	}
+	return d
+}
+
+func (r RemoteCommands) validate() error {
+	for _, c := range r.Allowed {
+		if strings.TrimSpace(c) == "" {
+			return errors.New("empty allowed command")
+		}
+	}
+	if r.Timeout != "" {
+		if d, err := time.ParseDuration(r.Timeout); err != nil || d <= 0 {
+			return fmt.Errorf("invalid timeout %q", r.Timeout)
+		}
+	}
+	return nil
+}
+
+// CommandState is a state of a remote command.
+type CommandState string
+
+const (
+	// CommandPending means the command is waiting for an approval.
+	CommandPending = CommandState("pending")
+	// CommandRejected means the command has been rejected.
+	CommandRejected = CommandState("rejected")
+	// CommandRunning means the command is running.
+	CommandRunning = CommandState("running")
+	// CommandSucceeded means the command has succeeded on all the hosts.
+	CommandSucceeded = CommandState("succeeded")
+	// CommandFailed means the command has failed on any of the hosts.
+	CommandFailed = CommandState("failed")
+)
+
+var (
+	// ErrNoSuchCommand is returned when a remote command to decide is not found.
+	ErrNoSuchCommand = errors.New("no such command")
+	// ErrCommandDecided is returned when a remote command has already been approved or rejected.
+	ErrCommandDecided = errors.New("command already decided")
+)
+
+// CommandRun is a remote command requested in an environment.
+type CommandRun struct {
+	// ID identifies the command in the environment.
+	ID      string `json:"id"`
+	Command string `json:"command"`
+	// Hosts are the hosts which the command runs on.
+	Hosts []string `json:"hosts"`
+	// Reason is the justification given by the user who requested the command.
+	Reason string `json:"reason"`
+	// User is the name of the user who requested the command.
+	User  string       `json:"user"`
+	State CommandState `json:"state"`
+	Time  time.Time    `json:"time"`
+	// Approver is the name of the user who approved or rejected the command, if it required an approval.
+	Approver  string    `json:"approver,omitempty"`
+	DecidedAt time.Time `json:"decided_at,omitempty"`
+	// StartedAt is when the command started. Its output is kept at the time in the same way as outputs of deployments.
+	StartedAt time.Time     `json:"started_at,omitempty"`
+	Duration  time.Duration `json:"duration,omitempty"`
+}
+
+func commandKey(projectName, projectEnv string) string {
+	return fmt.Sprintf("/goship/commands/%s/%s", projectName, projectEnv)
+}
+
+// LoadCommandRuns returns the remote commands of the environment in the order of requests.
+func LoadCommandRuns(client ETCDInterface, projectName, projectEnv string) ([]CommandRun, error) {
+	resp, err := client.Get(commandKey(projectName, projectEnv), false, false)
+	if IsNotFound(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return decodeCommandRuns(resp.Node.Value)
+}
+
+func decodeCommandRuns(value string) ([]CommandRun, error) {
+	if value == "" {
+		return nil, nil
+	}
+	var runs []CommandRun
+	if err := json.Unmarshal([]byte(value), &runs); err != nil {
+		return nil, err
+	}
+	return runs, nil
+}
+
+// AddCommandRun adds "run" to the environment, pending an approval if "pending" is true or running otherwise.
+// It returns "run" with a new ID assigned. The oldest commands are dropped when the history gets too long.
+func AddCommandRun(client ETCDInterface, projectName, projectEnv string, run CommandRun, pending bool) (CommandRun, error) {
+	if projectName == "" || projectEnv == "" {
+		return CommandRun{}, fmt.Errorf("Missing parameters")
+	}
+	err := updateCommandRuns(client, projectName, projectEnv, func(runs []CommandRun) ([]CommandRun, error) {
+		var last int
+		for _, prev := range runs {
+			if id, err := strconv.Atoi(prev.ID); err == nil && id > last {
+				last = id
+			}
+		}
+		run.ID, run.State = strconv.Itoa(last+1), CommandRunning
+		if pending {
+			run.State = CommandPending
+		}
+		runs = append(runs, run)
+		if len(runs) > maxCommandRuns {
+			runs = runs[len(runs)-maxCommandRuns:]
+		}
+		return runs, nil
+	})
+	if err != nil {
+		return CommandRun{}, err
+	}
+	return run, nil
+}
+
+// DecideCommandRun approves or rejects the pending command identified by "id" on behalf of "approver".
+// Approved commands get running. It returns the decided command.
+// Commands are swapped, so that a command is not decided twice by concurrent clicks on any instance of Goship.
+func DecideCommandRun(client ETCDInterface, projectName, projectEnv, id, approver string, approve bool) (CommandRun, error) {
+	return updateCommandRun(client, projectName, projectEnv, id, func(run *CommandRun) error {
+		if run.State != CommandPending {
+			return ErrCommandDecided
+		}
+		run.State, run.Approver, run.DecidedAt = CommandRejected, approver, time.Now()
+		if approve {
+			run.State = CommandRunning
+		}
+		return nil
+	})
+}
+
+// FinishCommandRun records the result of the running command identified by "id" which started at "start".
+func FinishCommandRun(client ETCDInterface, projectName, projectEnv, id string, success bool, start time.Time, duration time.Duration) (CommandRun, error) {
+	return updateCommandRun(client, projectName, projectEnv, id, func(run *CommandRun) error {
+		run.State, run.StartedAt, run.Duration = CommandFailed, start, duration
+		if success {
+			run.State = CommandSucceeded
+		}
+		return nil
+	})
+}
+
+func updateCommandRun(client ETCDInterface, projectName, projectEnv, id string, f func(*CommandRun) error) (CommandRun, error) {
+	var updated CommandRun
+	err := updateCommandRuns(client, projectName, projectEnv, func(runs []CommandRun) ([]CommandRun, error) {
+		for i := range runs {
+			run := &runs[i]
+			if run.ID != id {
+				continue
+			}
+			if err := f(run); err != nil {
+				return nil, err
+			}
+			updated = *run
+			return runs, nil
+		}
+		return nil, ErrNoSuchCommand
+	})
+	if err != nil {
+		return CommandRun{}, err
+	}
+	return updated, nil
+}
+
+func updateCommandRuns(client ETCDInterface, projectName, projectEnv string, change func([]CommandRun) ([]CommandRun, error)) error {
+	return update(client, commandKey(projectName, projectEnv), func(value string) (string, error) {
+		runs, err := decodeCommandRuns(value)
+		if err != nil {
+			return "", err
+		}
+		if runs, err = change(runs); err != nil {
+			return "", err
+		}
+		buf, err := json.Marshal(runs)
+		return string(buf), err
+	})
+}
@@ -0,0 +1,122 @@
+package config_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/gengo/goship/lib/config"
+)
+
+func TestRemoteCommands(t *testing.T) {
+	rc := config.RemoteCommands{Allowed: []string{"uptime", "tail -n 100 /var/log/app.log"}}
+	for _, spec := range []struct {
+		command string
+		want    config.Role
+	}{
+		{command: "uptime", want: config.RoleDeployer},
+		{command: "tail -n 100 /var/log/app.log", want: config.RoleDeployer},
+		{command: "uptime; rm -rf /", want: config.RoleAdmin},
+		{command: "sudo reboot", want: config.RoleAdmin},
+	} {
+		if got := rc.RequiredRole(spec.command); got != spec.want {
+			t.Errorf("rc.RequiredRole(%q) = %q; want %q", spec.command, got, spec.want)
+		}
+	}
+	if got, want := rc.CommandTimeout(), time.Minute; got != want {
+		t.Errorf("rc.CommandTimeout() = %v; want %v", got, want)
+	}
+	rc.Timeout = "5m"
+	if got, want := rc.CommandTimeout(), 5*time.Minute; got != want {
+		t.Errorf("rc.CommandTimeout() = %v; want %v", got, want)
+	}
+}
+
+func TestLoadRemoteCommands(t *testing.T) {
+	for _, spec := range []struct {
+		rc    config.RemoteCommands
+		valid bool
+	}{
+		{rc: config.RemoteCommands{}, valid: true},
+		{rc: config.RemoteCommands{Allowed: []string{"uptime"}, RequireApproval: true, Timeout: "30s"}, valid: true},
+		{rc: config.RemoteCommands{Allowed: []string{" "}}},
+		{rc: config.RemoteCommands{Timeout: "forever"}},
+		{rc: config.RemoteCommands{Timeout: "-1m"}},
+	} {
+		rc := spec.rc
+		_, got := loadEnvironment(t, config.Environment{Name: "production", Deploy: "deploy-command", RemoteCommands: &rc})
+		if got != spec.valid {
+			t.Errorf("loaded the project with remote_commands %#v = %t; want %t", spec.rc, got, spec.valid)
+		}
+	}
+}
+
+func TestCommandRuns(t *testing.T) {
+	s := config.NewMemoryStore()
+	now := time.Date(2015, time.November, 10, 23, 0, 0, 0, time.UTC)
+	run, err := config.AddCommandRun(s, "proj", "production", config.CommandRun{Command: "uptime", Hosts: []string{"web1"}, Reason: "incident", User: "alice", Time: now}, true)
+	if err != nil {
+		t.Fatalf("config.AddCommandRun failed with %v; want success", err)
+	}
+	if run.ID != "1" || run.State != config.CommandPending {
+		t.Errorf("run = %#v; want a pending command with ID 1", run)
+	}
+	direct, err := config.AddCommandRun(s, "proj", "production", config.CommandRun{Command: "df -h", Hosts: []string{"web1"}, Reason: "incident", User: "bob", Time: now}, false)
+	if err != nil {
+		t.Fatalf("config.AddCommandRun failed with %v; want success", err)
+	}
+	if direct.ID != "2" || direct.State != config.CommandRunning {
+		t.Errorf("direct = %#v; want a running command with ID 2", direct)
+	}
+
+	if _, err := config.DecideCommandRun(s, "proj", "production", "3", "bob", true); err != config.ErrNoSuchCommand {
+		t.Errorf("config.DecideCommandRun(..., %q, ...) failed with %v; want %v", "3", err, config.ErrNoSuchCommand)
+	}
+	decided, err := config.DecideCommandRun(s, "proj", "production", "1", "bob", true)
+	if err != nil {
+		t.Fatalf("config.DecideCommandRun failed with %v; want success", err)
+	}
+	if decided.State != config.CommandRunning || decided.Approver != "bob" {
+		t.Errorf("decided = %#v; want a running command approved by bob", decided)
+	}
+	if _, err := config.DecideCommandRun(s, "proj", "production", "1", "carol", false); err != config.ErrCommandDecided {
+		t.Errorf("config.DecideCommandRun decided the command twice with %v; want %v", err, config.ErrCommandDecided)
+	}
+
+	finished, err := config.FinishCommandRun(s, "proj", "production", "1", false, now, time.Second)
+	if err != nil {
+		t.Fatalf("config.FinishCommandRun failed with %v; want success", err)
+	}
+	if finished.State != config.CommandFailed || !finished.StartedAt.Equal(now) || finished.Duration != time.Second {
+		t.Errorf("finished = %#v; want a failed command started at %v which took 1s", finished, now)
+	}
+	runs, err := config.LoadCommandRuns(s, "proj", "production")
+	if err != nil {
+		t.Fatalf("config.LoadCommandRuns failed with %v; want success", err)
+	}
+	if len(runs) != 2 || runs[0].State != config.CommandFailed || runs[1].State != config.CommandRunning {
+		t.Errorf("config.LoadCommandRuns = %#v; want the failed command and the running command", runs)
+	}
+}
+
+func TestDecideCommandRunConcurrently(t *testing.T) {
+	m := config.NewMemoryStore()
+	run, err := config.AddCommandRun(m, "proj", "production", config.CommandRun{Command: "uptime", Hosts: []string{"web1"}, Reason: "incident", User: "alice"}, true)
+	if err != nil {
+		t.Fatalf("config.AddCommandRun failed with %v; want success", err)
+	}
+	s := &racingStore{MemoryStore: m, race: func() {
+		if _, err := config.DecideCommandRun(m, "proj", "production", run.ID, "bob", true); err != nil {
+			t.Fatalf("config.DecideCommandRun failed with %v; want success", err)
+		}
+	}}
+	if _, err := config.DecideCommandRun(s, "proj", "production", run.ID, "carol", false); err != config.ErrCommandDecided {
+		t.Errorf("config.DecideCommandRun decided the command approved by another instance with %v; want %v", err, config.ErrCommandDecided)
+	}
+	runs, err := config.LoadCommandRuns(m, "proj", "production")
+	if err != nil {
+		t.Fatalf("config.LoadCommandRuns failed with %v; want success", err)
+	}
+	if len(runs) != 1 || runs[0].State != config.CommandRunning || runs[0].Approver != "bob" {
+		t.Errorf("config.LoadCommandRuns = %#v; want the command approved by bob", runs)
+	}
+}
@@ -0,0 +1,87 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/gengo/goship/lib/clock"
+)
+
+// Comment is a comment in the thread of an environment, or of a deployment in its deploy log.
+// Threads keep all the comments, so that postmortems can reference them.
+type Comment struct {
+	// ID identifies the comment in the environment.
+	ID string `json:"id"`
+	// User is the name of the user who wrote the comment.
+	User string `json:"user"`
+	// Body is written in Markdown.
+	Body string    `json:"body"`
+	Time time.Time `json:"time"`
+	// Deploy is the time of the deployment which the comment is on, or nil if the comment is on the environment.
+	Deploy *time.Time `json:"deploy,omitempty"`
+}
+
+// On returns true if the comment is on the deployment at "deploy", or on the environment if "deploy" is zero.
+func (c Comment) On(deploy time.Time) bool {
+	if c.Deploy == nil {
+		return deploy.IsZero()
+	}
+	return c.Deploy.Equal(deploy)
+}
+
+func commentsKey(projectName, projectEnv string) string {
+	return fmt.Sprintf("/goship/comments/%s/%s", projectName, projectEnv)
+}
+
+// LoadComments returns all the comments on the environment and on its deployments, oldest first.
+func LoadComments(client ETCDInterface, projectName, projectEnv string) ([]Comment, error) {
+	resp, err := client.Get(commentsKey(projectName, projectEnv), false, false)
+	if IsNotFound(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return decodeComments(resp.Node.Value)
+}
+
+func decodeComments(value string) ([]Comment, error) {
+	if value == "" {
+		return nil, nil
+	}
+	var comments []Comment
+	if err := json.Unmarshal([]byte(value), &comments); err != nil {
+		return nil, err
+	}
+	return comments, nil
+}
+
+// AddComment adds "c" to the comments of the environment.
+// It returns "c" with a new ID and the current time assigned.
+func AddComment(client ETCDInterface, projectName, projectEnv string, c Comment) (Comment, error) {
+	if projectName == "" || projectEnv == "" {
+		return Comment{}, fmt.Errorf("Missing parameters")
+	}
+	// comments are swapped so that concurrent comments from the instances of Goship are not lost
+	err := update(client, commentsKey(projectName, projectEnv), func(value string) (string, error) {
+		comments, err := decodeComments(value)
+		if err != nil {
+			return "", err
+		}
+		var last int
+		for _, other := range comments {
+			if id, err := strconv.Atoi(other.ID); err == nil && id > last {
+				last = id
+			}
+		}
+		c.ID, c.Time = strconv.Itoa(last+1), clock.Now()
+		buf, err := json.Marshal(append(comments, c))
+		return string(buf), err
+	})
+	if err != nil {
+		return Comment{}, err
+	}
+	return c, nil
+}
@@ -0,0 +1,93 @@
+package config_test
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/coreos/go-etcd/etcd"
+	"github.com/gengo/goship/lib/config"
+)
+
+func TestComments(t *testing.T) {
+	s := config.NewMemoryStore()
+	deploy := time.Date(2015, 11, 10, 3, 0, 0, 0, time.UTC)
+	for _, c := range []config.Comment{
+		{User: "alice", Body: "Do not deploy until the **migration** finishes"},
+		{User: "bob", Body: "Rolled back because of `OOM`", Deploy: &deploy},
+		{User: "alice", Body: ""},
+	} {
+		if _, err := config.AddComment(s, "proj", "env", c); err != nil {
+			t.Fatalf("config.AddComment(s, %q, %q, %#v) failed with %v; want success", "proj", "env", c, err)
+		}
+	}
+
+	comments, err := config.LoadComments(s, "proj", "env")
+	if err != nil {
+		t.Fatalf("config.LoadComments(s, %q, %q) failed with %v; want success", "proj", "env", err)
+	}
+	if len(comments) != 3 {
+		t.Fatalf("config.LoadComments(s, %q, %q) = %#v; want all the 3 comments", "proj", "env", comments)
+	}
+	for i, c := range comments {
+		if want := []string{"1", "2", "3"}[i]; c.ID != want || c.Time.IsZero() {
+			t.Errorf("comments[%d] = %#v; want ID %q and the time assigned", i, c, want)
+		}
+	}
+	if c := comments[1]; !c.On(deploy.In(time.Local)) || c.On(time.Time{}) || c.User != "bob" {
+		t.Errorf("comments[1] = %#v; want the comment by bob on the deployment at %v", c, deploy)
+	}
+	if c := comments[0]; !c.On(time.Time{}) || c.On(deploy) {
+		t.Errorf("comments[0] = %#v; want the comment on the environment", c)
+	}
+
+	if comments, err := config.LoadComments(s, "proj", "other"); err != nil || len(comments) != 0 {
+		t.Errorf("config.LoadComments(s, %q, %q) = %#v, %v; want no comments", "proj", "other", comments, err)
+	}
+}
+
+// racingStore runs "race" once after the first read, as if another instance of Goship wrote in between.
+type racingStore struct {
+	*config.MemoryStore
+	race func()
+}
+
+func (s *racingStore) Get(key string, sort, recursive bool) (*etcd.Response, error) {
+	resp, err := s.MemoryStore.Get(key, sort, recursive)
+	if race := s.race; race != nil {
+		s.race = nil
+		race()
+	}
+	return resp, err
+}
+
+func TestAddCommentConcurrently(t *testing.T) {
+	m := config.NewMemoryStore()
+	if _, err := config.AddComment(m, "proj", "env", config.Comment{User: "alice", Body: "first"}); err != nil {
+		t.Fatalf("config.AddComment failed with %v; want success", err)
+	}
+	s := &racingStore{MemoryStore: m, race: func() {
+		if _, err := config.AddComment(m, "proj", "env", config.Comment{User: "bob", Body: "racing"}); err != nil {
+			t.Fatalf("config.AddComment failed with %v; want success", err)
+		}
+	}}
+	c, err := config.AddComment(s, "proj", "env", config.Comment{User: "carol", Body: "third"})
+	if err != nil {
+		t.Fatalf("config.AddComment failed with %v; want success", err)
+	}
+	if c.ID != "3" {
+		t.Errorf("config.AddComment assigned ID %q; want %q after the racing comment", c.ID, "3")
+	}
+
+	comments, err := config.LoadComments(m, "proj", "env")
+	if err != nil {
+		t.Fatalf("config.LoadComments failed with %v; want success", err)
+	}
+	var bodies []string
+	for _, c := range comments {
+		bodies = append(bodies, c.Body)
+	}
+	if want := []string{"first", "racing", "third"}; !reflect.DeepEqual(bodies, want) {
+		t.Errorf("comments = %q; want %q", bodies, want)
+	}
+}
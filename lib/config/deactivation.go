@@ -0,0 +1,61 @@
+package config
+
+import (
+	"encoding/json"
+	"path"
+	"time"
+)
+
+// Deactivation records that a user has been deactivated, e.g. on leaving the organization.
+// Past records such as deploy logs keep the name of the user as is.
+type Deactivation struct {
+	User string `json:"user"`
+	// By is the name of the admin who deactivated the user.
+	By     string    `json:"by"`
+	Reason string    `json:"reason,omitempty"`
+	Time   time.Time `json:"time"`
+	// ReactivatedAt and ReactivatedBy are when and by whom the user was reactivated, if ever.
+	ReactivatedAt time.Time `json:"reactivated_at,omitempty"`
+	ReactivatedBy string    `json:"reactivated_by,omitempty"`
+}
+
+// Active returns true if the user has been reactivated since the deactivation.
+func (d Deactivation) Active() bool {
+	return !d.ReactivatedAt.IsZero()
+}
+
+// Revokes returns true if the session of the user which started at "login" is revoked by the deactivation.
+// Sessions started before the deactivation stay revoked even after the user is reactivated.
+func (d Deactivation) Revokes(login time.Time) bool {
+	return !d.Active() || login.Before(d.Time)
+}
+
+func deactivationKey(user string) string {
+	return path.Join("/goship/users", user, "deactivation")
+}
+
+// LoadDeactivation returns the last deactivation of "user", or nil if the user has never been deactivated.
+func LoadDeactivation(client ETCDInterface, user string) (*Deactivation, error) {
+	resp, err := client.Get(deactivationKey(user), false, false)
+	if IsNotFound(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var d Deactivation
+	if err := json.Unmarshal([]byte(resp.Node.Value), &d); err != nil {
+		return nil, err
+	}
+	return &d, nil
+}
+
+// StoreDeactivation stores "d" as the last deactivation of d.User.
+func StoreDeactivation(client ETCDInterface, d Deactivation) error {
+	buf, err := json.Marshal(d)
+	if err != nil {
+		return err
+	}
+	_, err = client.Set(deactivationKey(d.User), string(buf), 0)
+	return err
+}
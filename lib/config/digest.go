@@ -0,0 +1,114 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"path"
+	"time"
+)
+
+// digestDir is the etcd directory of pending digests. Each project has its own key under it.
+const digestDir = "/goship/digests"
+
+// PendingDigest is a digest of a project which has not been sent to its notifier yet.
+// Pending digests are kept in etcd so that they survive restarts and are sent once by the leader of a cluster.
+type PendingDigest struct {
+	Project  string   `json:"project"`
+	Notifier Notifier `json:"notifier"`
+	// Due is when the digest is sent.
+	Due time.Time `json:"due"`
+	// Events are the events which the digest summarizes, encoded by the notification package.
+	Events []json.RawMessage `json:"events"`
+}
+
+// sends returns true if the digest is sent to "n".
+func (d PendingDigest) sends(n Notifier) bool {
+	return d.Notifier.Type == n.Type && d.Notifier.URL == n.URL && d.Notifier.Channel == n.Channel && d.Notifier.Digest == n.Digest
+}
+
+func digestKey(projectName string) string {
+	return path.Join(digestDir, projectName)
+}
+
+func decodeDigests(value string) ([]PendingDigest, error) {
+	if value == "" {
+		return nil, nil
+	}
+	var digests []PendingDigest
+	if err := json.Unmarshal([]byte(value), &digests); err != nil {
+		return nil, err
+	}
+	return digests, nil
+}
+
+// AddDigestEvent adds "ev" of the project to its pending digest sent to "n".
+// A new digest is due at "due" if the project has no pending digest for "n".
+func AddDigestEvent(client ETCDInterface, projectName string, n Notifier, due time.Time, ev json.RawMessage) error {
+	if projectName == "" {
+		return fmt.Errorf("Missing parameters")
+	}
+	return update(client, digestKey(projectName), func(value string) (string, error) {
+		digests, err := decodeDigests(value)
+		if err != nil {
+			return "", err
+		}
+		var d *PendingDigest
+		for i := range digests {
+			if digests[i].sends(n) {
+				d = &digests[i]
+			}
+		}
+		if d == nil {
+			digests = append(digests, PendingDigest{Project: projectName, Notifier: n, Due: due})
+			d = &digests[len(digests)-1]
+		}
+		d.Events = append(d.Events, ev)
+		buf, err := json.Marshal(digests)
+		return string(buf), err
+	})
+}
+
+// TakeDueDigests removes the pending digests due at "now" from "client" and returns them.
+// Each digest is taken only once even if instances of Goship take them concurrently.
+// It returns the digests taken so far along with an error if it fails.
+func TakeDueDigests(client ETCDInterface, now time.Time) ([]PendingDigest, error) {
+	nodes, err := getDir(client, digestDir)
+	if err != nil {
+		return nil, err
+	}
+	var taken []PendingDigest
+	for _, node := range nodes {
+		if node.Dir {
+			continue
+		}
+		var due []PendingDigest
+		err := update(client, node.Key, func(value string) (string, error) {
+			digests, err := decodeDigests(value)
+			if err != nil {
+				return "", err
+			}
+			var rest []PendingDigest
+			due = nil
+			for _, d := range digests {
+				if now.Before(d.Due) {
+					rest = append(rest, d)
+				} else {
+					due = append(due, d)
+				}
+			}
+			if len(due) == 0 {
+				return value, nil
+			}
+			if len(rest) == 0 {
+				return "", nil
+			}
+			buf, err := json.Marshal(rest)
+			return string(buf), err
+		})
+		if err != nil {
+			return taken, err
+		}
+		taken = append(taken, due...)
+	}
+	return taken, nil
+}
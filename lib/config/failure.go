@@ -0,0 +1,74 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// FailedDeploy is a failed deployment in a FailureStreak.
+type FailedDeploy struct {
+	User     string    `json:"user"`
+	Revision string    `json:"revision"`
+	Time     time.Time `json:"time"`
+	// Output is the last lines of the output of the deployment.
+	Output []string `json:"output,omitempty"`
+}
+
+// FailureStreak is the consecutive failed deployments of an environment.
+type FailureStreak struct {
+	// Count is the number of the consecutive failures.
+	Count int `json:"count"`
+	// Failures are the latest failures, oldest first.
+	// It may have fewer entries than Count.
+	Failures []FailedDeploy `json:"failures"`
+	// Issue is the number of the issue opened for the failures, or zero if not opened.
+	Issue int `json:"issue,omitempty"`
+	// IssueURL is the URL of the issue.
+	IssueURL string `json:"issue_url,omitempty"`
+}
+
+func failureKey(projectName, projectEnv string) string {
+	return fmt.Sprintf("/goship/failures/%s/%s", projectName, projectEnv)
+}
+
+// LoadFailureStreak returns the consecutive failures of the environment, or nil if its last deployment succeeded.
+func LoadFailureStreak(client ETCDInterface, projectName, projectEnv string) (*FailureStreak, error) {
+	resp, err := client.Get(failureKey(projectName, projectEnv), false, false)
+	if IsNotFound(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if resp.Node.Value == "" {
+		return nil, nil
+	}
+	var s FailureStreak
+	if err := json.Unmarshal([]byte(resp.Node.Value), &s); err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+// SaveFailureStreak stores the consecutive failures of the environment.
+func SaveFailureStreak(client ETCDInterface, projectName, projectEnv string, s FailureStreak) error {
+	if projectName == "" || projectEnv == "" {
+		return fmt.Errorf("Missing parameters")
+	}
+	buf, err := json.Marshal(s)
+	if err != nil {
+		return err
+	}
+	_, err = client.Set(failureKey(projectName, projectEnv), string(buf), 0)
+	return err
+}
+
+// ClearFailureStreak removes the consecutive failures of the environment.
+func ClearFailureStreak(client ETCDInterface, projectName, projectEnv string) error {
+	if projectName == "" || projectEnv == "" {
+		return fmt.Errorf("Missing parameters")
+	}
+	_, err := client.Set(failureKey(projectName, projectEnv), "", 0)
+	return err
+}
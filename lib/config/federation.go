@@ -0,0 +1,48 @@
+package config
+
+import (
+	"fmt"
+	"net/url"
+)
+
+// FederationConfig configures the aggregation of status from other Goship instances.
+type FederationConfig struct {
+	// Name is the name of this instance in the federation view.
+	Name string `json:"name,omitempty" yaml:"name,omitempty"`
+	// Token is the bearer token which peers present to read the status of this instance.
+	// Peers cannot read the status if empty.
+	Token string `json:"token,omitempty" yaml:"token,omitempty"`
+	// Peers are the other instances whose status is shown in the federation view.
+	Peers []Peer `json:"peers,omitempty" yaml:"peers,omitempty"`
+}
+
+// Peer is another Goship instance in the federation.
+type Peer struct {
+	// Name is the name of the peer in the federation view, e.g. its datacenter.
+	Name string `json:"name" yaml:"name"`
+	// URL is the base URL of the peer, e.g. "https://goship.us-east.example.com".
+	URL string `json:"url" yaml:"url"`
+	// Token is the bearer token configured as the federation token of the peer.
+	Token string `json:"token,omitempty" yaml:"token,omitempty"`
+}
+
+func (f FederationConfig) validate() error {
+	seen := make(map[string]bool)
+	for _, p := range f.Peers {
+		if p.Name == "" {
+			return fmt.Errorf("peer name not specified")
+		}
+		if seen[p.Name] {
+			return fmt.Errorf("duplicate peer %q", p.Name)
+		}
+		seen[p.Name] = true
+		u, err := url.Parse(p.URL)
+		if err != nil {
+			return fmt.Errorf("invalid url of peer %q: %v", p.Name, err)
+		}
+		if u.Scheme != "http" && u.Scheme != "https" {
+			return fmt.Errorf("url of peer %q must be http or https: %q", p.Name, p.URL)
+		}
+	}
+	return nil
+}
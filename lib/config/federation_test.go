@@ -0,0 +1,37 @@
+package config_test
+
+import (
+	"testing"
+
+	"github.com/gengo/goship/lib/config"
+)
+
+func TestLoadFederation(t *testing.T) {
+	for _, spec := range []struct {
+		peers []config.Peer
+		valid bool
+	}{
+		{
+			peers: []config.Peer{{Name: "us-east", URL: "https://goship.us-east.example.com", Token: "secret"}},
+			valid: true,
+		},
+		{
+			peers: []config.Peer{{URL: "https://goship.us-east.example.com"}},
+		},
+		{
+			peers: []config.Peer{{Name: "us-east", URL: "goship.us-east.example.com"}},
+		},
+		{
+			peers: []config.Peer{
+				{Name: "us-east", URL: "https://goship.us-east.example.com"},
+				{Name: "us-east", URL: "https://goship.us-west.example.com"},
+			},
+		},
+	} {
+		cfg := exampleConfig()
+		cfg.Federation = &config.FederationConfig{Peers: spec.peers}
+		if _, err := storeAndLoad(t, cfg); (err == nil) != spec.valid {
+			t.Errorf("config.Load(s) failed with %v; want success = %v; peers = %#v", err, spec.valid, spec.peers)
+		}
+	}
+}
@@ -0,0 +1,114 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+const weekDuration = 7 * 24 * time.Hour
+
+// Freeze is a weekly window during which deployments are refused unless they are emergencies.
+type Freeze struct {
+	// Start and End are a weekday and a time of day in the local time of the Goship server, e.g. "Fri 18:00" and "Mon 08:00".
+	Start string `json:"start" yaml:"start"`
+	End   string `json:"end" yaml:"end"`
+	// Environments are the names of the frozen environments.
+	// All the environments are frozen if empty.
+	Environments []string `json:"environments,omitempty" yaml:"environments,omitempty"`
+	// Reason is an optional description of the freeze shown to users.
+	Reason string `json:"reason,omitempty" yaml:"reason,omitempty"`
+}
+
+// Covers returns true if the environment named "env" is frozen at "t".
+func (f Freeze) Covers(env string, t time.Time) bool {
+	if len(f.Environments) > 0 && !contains(f.Environments, env) {
+		return false
+	}
+	start, err := parseWeekTime(f.Start)
+	if err != nil {
+		return false
+	}
+	end, err := parseWeekTime(f.End)
+	if err != nil {
+		return false
+	}
+	now := sinceWeekStart(t)
+	if start <= end {
+		return start <= now && now < end
+	}
+	return start <= now || now < end
+}
+
+// Until returns the end of the freeze after "t".
+func (f Freeze) Until(t time.Time) time.Time {
+	end, err := parseWeekTime(f.End)
+	if err != nil {
+		return t
+	}
+	d := end - sinceWeekStart(t)
+	if d <= 0 {
+		d += weekDuration
+	}
+	return t.Add(d).Truncate(time.Minute)
+}
+
+func (f Freeze) validate() error {
+	if _, err := parseWeekTime(f.Start); err != nil {
+		return err
+	}
+	if _, err := parseWeekTime(f.End); err != nil {
+		return err
+	}
+	if f.Start == f.End {
+		return fmt.Errorf("freeze window %s - %s is empty", f.Start, f.End)
+	}
+	return nil
+}
+
+// ActiveFreeze returns the freeze window in "c" which covers the environment named "env" at "t",
+// or nil if the environment is not frozen.
+func ActiveFreeze(c Config, env string, t time.Time) *Freeze {
+	for i, f := range c.Freezes {
+		if f.Covers(env, t) {
+			return &c.Freezes[i]
+		}
+	}
+	return nil
+}
+
+// parseWeekTime parses "s" like "Fri 18:00" into the duration since the beginning of the week.
+func parseWeekTime(s string) (time.Duration, error) {
+	fields := strings.Fields(s)
+	if len(fields) != 2 {
+		return 0, fmt.Errorf("invalid weekday and time %q", s)
+	}
+	day := -1
+	for d := time.Sunday; d <= time.Saturday; d++ {
+		if strings.EqualFold(fields[0], d.String()[:3]) || strings.EqualFold(fields[0], d.String()) {
+			day = int(d)
+		}
+	}
+	if day < 0 {
+		return 0, fmt.Errorf("invalid weekday in %q", s)
+	}
+	tod, err := time.Parse("15:04", fields[1])
+	if err != nil {
+		return 0, fmt.Errorf("invalid time of day in %q", s)
+	}
+	return time.Duration(day)*24*time.Hour + time.Duration(tod.Hour())*time.Hour + time.Duration(tod.Minute())*time.Minute, nil
+}
+
+// sinceWeekStart returns the duration of "t" since the beginning of its week.
+func sinceWeekStart(t time.Time) time.Duration {
+	return time.Duration(t.Weekday())*24*time.Hour + time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute + time.Duration(t.Second())*time.Second
+}
+
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
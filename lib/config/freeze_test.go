@@ -0,0 +1,44 @@
+package config_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/gengo/goship/lib/config"
+)
+
+func TestFreezeCovers(t *testing.T) {
+	f := config.Freeze{Start: "Fri 18:00", End: "Mon 08:00", Environments: []string{"production"}}
+	for _, spec := range []struct {
+		env  string
+		t    time.Time
+		want bool
+	}{
+		// 2015-11-13 is a Friday.
+		{env: "production", t: time.Date(2015, time.November, 13, 17, 59, 0, 0, time.Local), want: false},
+		{env: "production", t: time.Date(2015, time.November, 13, 18, 0, 0, 0, time.Local), want: true},
+		{env: "production", t: time.Date(2015, time.November, 15, 12, 0, 0, 0, time.Local), want: true},
+		{env: "production", t: time.Date(2015, time.November, 16, 7, 59, 0, 0, time.Local), want: true},
+		{env: "production", t: time.Date(2015, time.November, 16, 8, 0, 0, 0, time.Local), want: false},
+		{env: "staging", t: time.Date(2015, time.November, 15, 12, 0, 0, 0, time.Local), want: false},
+	} {
+		if got := f.Covers(spec.env, spec.t); got != spec.want {
+			t.Errorf("f.Covers(%q, %v) = %v; want %v", spec.env, spec.t, got, spec.want)
+		}
+	}
+
+	now := time.Date(2015, time.November, 14, 9, 30, 0, 0, time.Local)
+	if got, want := f.Until(now), time.Date(2015, time.November, 16, 8, 0, 0, 0, time.Local); !got.Equal(want) {
+		t.Errorf("f.Until(%v) = %v; want %v", now, got, want)
+	}
+}
+
+func TestActiveFreeze(t *testing.T) {
+	c := config.Config{Freezes: []config.Freeze{{Start: "Wed 09:00", End: "Wed 10:00", Reason: "release meeting"}}}
+	if f := config.ActiveFreeze(c, "staging", time.Date(2015, time.November, 11, 9, 30, 0, 0, time.Local)); f == nil || f.Reason != "release meeting" {
+		t.Errorf("config.ActiveFreeze(c, %q, Wed 09:30) = %#v; want the release meeting freeze", "staging", f)
+	}
+	if f := config.ActiveFreeze(c, "staging", time.Date(2015, time.November, 11, 10, 30, 0, 0, time.Local)); f != nil {
+		t.Errorf("config.ActiveFreeze(c, %q, Wed 10:30) = %#v; want nil", "staging", f)
+	}
+}
@@ -0,0 +1,98 @@
+package config
+
+import (
+	"path"
+
+	"github.com/coreos/go-etcd/etcd"
+)
+
+// Deleter is implemented by etcd clients which can delete keys.
+type Deleter interface {
+	Delete(key string, recursive bool) (*etcd.Response, error)
+}
+
+// Orphan is a key in etcd which belongs to a project or an environment which no longer exists.
+type Orphan struct {
+	Key string
+	// Dir is true if Key is a directory.
+	Dir    bool
+	Reason string
+}
+
+// FindOrphans returns keys of locks, comments and their threads, pins, schedules, approvals, reservations and failure streaks which belong to deleted projects or environments.
+// A project exists if it has its config, and an environment exists if it has its config in the project.
+func FindOrphans(client ETCDInterface) ([]Orphan, error) {
+	var orphans []Orphan
+	exists := make(map[string]bool)
+
+	projs, err := getDir(client, "/goship/projects")
+	if err != nil {
+		return nil, err
+	}
+	for _, p := range projs {
+		var (
+			hasConfig bool
+			envs      []*etcd.Node
+		)
+		for _, child := range p.Nodes {
+			switch path.Base(child.Key) {
+			case "config":
+				hasConfig = !child.Dir
+			case "environments":
+				envs = child.Nodes
+			}
+		}
+		if !hasConfig {
+			orphans = append(orphans, Orphan{Key: p.Key, Dir: p.Dir, Reason: "project has no config"})
+			continue
+		}
+		for _, e := range envs {
+			if e.Dir {
+				// Leftovers like locks and comments of an environment whose config is deleted.
+				orphans = append(orphans, Orphan{Key: e.Key, Dir: true, Reason: "environment has no config"})
+				continue
+			}
+			exists[path.Join(path.Base(p.Key), path.Base(e.Key))] = true
+		}
+	}
+
+	for _, base := range []string{"/goship/locks", "/goship/pins", "/goship/schedules", "/goship/approvals", "/goship/reservations", "/goship/failures", "/goship/comments"} {
+		projs, err := getDir(client, base)
+		if err != nil {
+			return nil, err
+		}
+		for _, p := range projs {
+			if !p.Dir {
+				continue
+			}
+			for _, e := range p.Nodes {
+				if !exists[path.Join(path.Base(p.Key), path.Base(e.Key))] {
+					orphans = append(orphans, Orphan{Key: e.Key, Dir: e.Dir, Reason: "no such project or environment"})
+				}
+			}
+		}
+	}
+	return orphans, nil
+}
+
+// RemoveOrphans deletes "orphans" from etcd.
+func RemoveOrphans(client Deleter, orphans []Orphan) error {
+	for _, o := range orphans {
+		if _, err := client.Delete(o.Key, o.Dir); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// getDir returns the children of the directory "key" recursively, or nil if "key" does not exist.
+func getDir(client ETCDInterface, key string) ([]*etcd.Node, error) {
+	resp, err := client.Get(key, true, true)
+	if IsNotFound(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return resp.Node.Nodes, nil
+}
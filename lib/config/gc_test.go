@@ -0,0 +1,56 @@
+package config_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/gengo/goship/lib/config"
+)
+
+func TestFindOrphans(t *testing.T) {
+	s := config.NewMemoryStore()
+	for k, v := range map[string]string{
+		"/goship/projects/proj/config":                          "{}",
+		"/goship/projects/proj/environments/staging":            "{}",
+		"/goship/projects/proj/environments/qa/comment":         "DO NOT DEPLOY",
+		"/goship/projects/deleted/environments/staging/locked":  "true",
+		"/goship/pins/proj/staging":                             `{"revision":"abc"}`,
+		"/goship/pins/proj/production":                          `{"revision":"abc"}`,
+		"/goship/schedules/deleted/staging":                     "[]",
+		"/goship/projects/proj/environments/staging/locked":     "true",
+		"/goship/projects/deleted/environments/staging/comment": "",
+		"/goship/comments/proj/staging":                         "[]",
+		"/goship/comments/proj/qa":                              "[]",
+	} {
+		if _, err := s.Set(k, v, 0); err != nil {
+			t.Fatalf("s.Set(%q, %q, 0) failed with %v; want success", k, v, err)
+		}
+	}
+
+	got, err := config.FindOrphans(s)
+	if err != nil {
+		t.Fatalf("config.FindOrphans(s) failed with %v; want success", err)
+	}
+	want := []config.Orphan{
+		{Key: "/goship/projects/deleted", Dir: true, Reason: "project has no config"},
+		{Key: "/goship/projects/proj/environments/qa", Dir: true, Reason: "environment has no config"},
+		{Key: "/goship/pins/proj/production", Reason: "no such project or environment"},
+		{Key: "/goship/schedules/deleted/staging", Reason: "no such project or environment"},
+		{Key: "/goship/comments/proj/qa", Reason: "no such project or environment"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("config.FindOrphans(s) = %#v; want %#v", got, want)
+	}
+
+	if err := config.RemoveOrphans(s, got); err != nil {
+		t.Fatalf("config.RemoveOrphans(s, %#v) failed with %v; want success", got, err)
+	}
+	if got, err := config.FindOrphans(s); err != nil || len(got) != 0 {
+		t.Errorf("config.FindOrphans(s) = %#v, %v after removal; want no orphans", got, err)
+	}
+	for _, key := range []string{"/goship/projects/proj/config", "/goship/projects/proj/environments/staging", "/goship/pins/proj/staging"} {
+		if _, err := s.Get(key, false, false); err != nil {
+			t.Errorf("s.Get(%q, false, false) failed with %v after removal; want success", key, err)
+		}
+	}
+}
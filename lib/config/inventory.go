@@ -0,0 +1,74 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+)
+
+// InventoryProvider is a source of the hosts of an environment.
+type InventoryProvider string
+
+const (
+	// InventoryEC2 resolves the hosts from tags of AWS EC2 instances.
+	InventoryEC2 = InventoryProvider("ec2")
+	// InventoryGCE resolves the hosts from labels of GCP Compute Engine instances.
+	InventoryGCE = InventoryProvider("gce")
+	// InventoryConsul resolves the hosts from the healthy instances of a Consul service.
+	InventoryConsul = InventoryProvider("consul")
+)
+
+// Inventory resolves the hosts of an environment at deploy time instead of listing them statically,
+// so that deployments to autoscaled fleets always reach the current set of instances.
+type Inventory struct {
+	Provider InventoryProvider `json:"provider" yaml:"provider"`
+
+	// Region is the AWS region of the EC2 instances, e.g. "us-east-1".
+	Region string `json:"region,omitempty" yaml:"region,omitempty"`
+	// Tags select the running EC2 instances which have all of the tags.
+	Tags map[string]string `json:"tags,omitempty" yaml:"tags,omitempty"`
+
+	// Project is the GCP project of the Compute Engine instances.
+	Project string `json:"project,omitempty" yaml:"project,omitempty"`
+	// Zone optionally narrows down the Compute Engine instances to a zone. All zones are searched if empty.
+	Zone string `json:"zone,omitempty" yaml:"zone,omitempty"`
+	// Labels select the running Compute Engine instances which have all of the labels.
+	Labels map[string]string `json:"labels,omitempty" yaml:"labels,omitempty"`
+
+	// PublicAddress resolves public IP addresses of EC2 and Compute Engine instances instead of private ones.
+	PublicAddress bool `json:"public_address,omitempty" yaml:"public_address,omitempty"`
+
+	// Address is the URL of the Consul agent. "http://127.0.0.1:8500" is used if empty.
+	Address string `json:"address,omitempty" yaml:"address,omitempty"`
+	// Service is the name of the Consul service. Only the instances passing their health checks are resolved.
+	Service string `json:"service,omitempty" yaml:"service,omitempty"`
+	// Tag optionally narrows down the instances of Service to the ones with the tag.
+	Tag string `json:"tag,omitempty" yaml:"tag,omitempty"`
+	// Datacenter is the Consul datacenter of Service. The datacenter of the agent is used if empty.
+	Datacenter string `json:"datacenter,omitempty" yaml:"datacenter,omitempty"`
+}
+
+func (inv Inventory) validate() error {
+	switch inv.Provider {
+	case InventoryEC2:
+		if inv.Region == "" {
+			return errors.New("region not specified")
+		}
+		if len(inv.Tags) == 0 {
+			return errors.New("tags not specified")
+		}
+	case InventoryGCE:
+		if inv.Project == "" {
+			return errors.New("project not specified")
+		}
+		if len(inv.Labels) == 0 {
+			return errors.New("labels not specified")
+		}
+	case InventoryConsul:
+		if inv.Service == "" {
+			return errors.New("service not specified")
+		}
+	default:
+		return fmt.Errorf("unknown provider %q", inv.Provider)
+	}
+	return nil
+}
@@ -0,0 +1,26 @@
+package config_test
+
+import (
+	"testing"
+
+	"github.com/gengo/goship/lib/config"
+)
+
+func TestLoadInventory(t *testing.T) {
+	ec2 := &config.Inventory{Provider: config.InventoryEC2, Region: "us-east-1", Tags: map[string]string{"role": "web"}}
+	for _, spec := range []struct {
+		env   config.Environment
+		valid bool
+	}{
+		{env: config.Environment{Name: "production", Deploy: "deploy-command", Inventory: ec2}, valid: true},
+		{env: config.Environment{Name: "production", Deploy: "deploy-command", Inventory: &config.Inventory{Provider: config.InventoryConsul, Service: "web"}}, valid: true},
+		{env: config.Environment{Name: "production", Deploy: "deploy-command", Hosts: []string{"web1"}, Inventory: ec2}},
+		{env: config.Environment{Name: "production", Deploy: "deploy-command", Inventory: &config.Inventory{Provider: config.InventoryEC2, Region: "us-east-1"}}},
+		{env: config.Environment{Name: "production", Deploy: "deploy-command", Inventory: &config.Inventory{Provider: config.InventoryGCE, Labels: map[string]string{"role": "web"}}}},
+		{env: config.Environment{Name: "production", Deploy: "deploy-command", Inventory: &config.Inventory{Provider: "azure"}}},
+	} {
+		if _, ok := loadEnvironment(t, spec.env); ok != spec.valid {
+			t.Errorf("loaded the project with inventory %#v = %t; want %t", spec.env.Inventory, ok, spec.valid)
+		}
+	}
+}
@@ -0,0 +1,154 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/coreos/go-etcd/etcd"
+)
+
+// KeyPrefix is the directory of all the keys of Goship in etcd.
+const KeyPrefix = "/goship"
+
+// readOnlyKeys are the directories of keys which ValidateKeyChange rejects changes of, e.g. the audit log which must not be tampered with.
+var readOnlyKeys = []string{"/goship/audit"}
+
+// CleanKey returns the canonical form of "key", e.g. "/goship/config" for "goship//config/".
+func CleanKey(key string) string {
+	return cleanKey(key)
+}
+
+// ValidateKeyRead checks that "key" can be read through the key browser, i.e. that it is KeyPrefix or under it.
+func ValidateKeyRead(key string) error {
+	key = cleanKey(key)
+	if key != KeyPrefix && !strings.HasPrefix(key, KeyPrefix+"/") {
+		return fmt.Errorf("%s is not under %s", key, KeyPrefix)
+	}
+	return nil
+}
+
+// ValidateKeyChange checks that "value" can be stored at "key" in etcd, or that "key" can be deleted with its children if "deleted" is true.
+// Keys must be under KeyPrefix and not in the audit log.
+// The configuration must still load after changes of the global configuration or projects, and JSON values must remain JSON.
+func ValidateKeyChange(client ETCDInterface, key, value string, deleted bool) error {
+	key = cleanKey(key)
+	if !strings.HasPrefix(key, KeyPrefix+"/") {
+		return fmt.Errorf("%s is not under %s", key, KeyPrefix)
+	}
+	for _, dir := range readOnlyKeys {
+		if key == dir || strings.HasPrefix(key, dir+"/") {
+			return fmt.Errorf("%s is read-only", key)
+		}
+	}
+	if !deleted {
+		resp, err := client.Get(key, false, false)
+		switch {
+		case IsNotFound(err):
+		case err != nil:
+			return err
+		case resp.Node.Dir:
+			return fmt.Errorf("%s is a directory", key)
+		case isJSON(resp.Node.Value) && !isJSON(value):
+			return fmt.Errorf("%s must be JSON", key)
+		}
+	}
+
+	switch {
+	case key == "/goship/config" || key == "/goship/projects":
+		s, err := changedCopy(client, []string{"/goship/config", "/goship/projects"}, key, value, deleted)
+		if err != nil {
+			return err
+		}
+		if _, err := load(s); err != nil {
+			return fmt.Errorf("the configuration would be invalid: %v", err)
+		}
+	case strings.HasPrefix(key, "/goship/projects/"):
+		// load skips invalid projects instead of failing, so check the project itself.
+		// Projects which are already invalid can be fixed in several changes.
+		name := strings.SplitN(strings.TrimPrefix(key, "/goship/projects/"), "/", 2)[0]
+		dir := path.Join("/goship/projects", name)
+		invalid, err := projectError(client, dir)
+		if err != nil {
+			return err
+		}
+		if invalid != nil {
+			return nil
+		}
+		s, err := changedCopy(client, []string{dir}, key, value, deleted)
+		if err != nil {
+			return err
+		}
+		if invalid, err := projectError(s, dir); err != nil {
+			return err
+		} else if invalid != nil {
+			return fmt.Errorf("project %s would be invalid: %v", name, invalid)
+		}
+	}
+	return nil
+}
+
+// changedCopy returns a copy of the keys under "dirs" in "client" with the change of "key" applied.
+func changedCopy(client ETCDInterface, dirs []string, key, value string, deleted bool) (*MemoryStore, error) {
+	s := NewMemoryStore()
+	for _, dir := range dirs {
+		if err := copyKeys(s, client, dir); err != nil {
+			return nil, err
+		}
+	}
+	if deleted {
+		if _, err := s.Delete(key, true); err != nil && !IsNotFound(err) {
+			return nil, err
+		}
+	} else if _, err := s.Set(key, value, 0); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// projectError returns why the project in "dir" cannot be loaded as "invalid", or nil if it does not exist or is valid.
+// It returns "err" instead if "dir" cannot be read.
+func projectError(client ETCDInterface, dir string) (invalid, err error) {
+	resp, err := client.Get(dir, true, true)
+	if IsNotFound(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	_, invalid = loadProject(resp.Node)
+	return invalid, nil
+}
+
+// isJSON returns true if "s" is a JSON object or array.
+func isJSON(s string) bool {
+	s = strings.TrimSpace(s)
+	if !strings.HasPrefix(s, "{") && !strings.HasPrefix(s, "[") {
+		return false
+	}
+	var v interface{}
+	return json.Unmarshal([]byte(s), &v) == nil
+}
+
+// copyKeys copies "key" and the keys under it from "src" into "dst".
+func copyKeys(dst *MemoryStore, src ETCDInterface, key string) error {
+	resp, err := src.Get(key, true, true)
+	if IsNotFound(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	nodes := []*etcd.Node{resp.Node}
+	for len(nodes) > 0 {
+		n := nodes[0]
+		nodes = append(nodes[1:], n.Nodes...)
+		if !n.Dir {
+			if _, err := dst.Set(n.Key, n.Value, 0); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
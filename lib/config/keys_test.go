@@ -0,0 +1,115 @@
+package config_test
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/coreos/go-etcd/etcd"
+	"github.com/gengo/goship/lib/config"
+)
+
+func TestValidateKeyChange(t *testing.T) {
+	s := config.NewMemoryStore()
+	for k, v := range map[string]string{
+		"/goship/config":                             `{"admins":["alice"]}`,
+		"/goship/projects/proj/config":               `{"repo_owner":"owner","repo_name":"proj"}`,
+		"/goship/projects/proj/environments/staging": `{"deploy":"deploy-command"}`,
+		"/goship/comments/proj/staging":              `[]`,
+		"/goship/projects/broken/config":             `{"repo_type":"svn"}`,
+		"/goship/audit/1":                            `{}`,
+	} {
+		if _, err := s.Set(k, v, 0); err != nil {
+			t.Fatalf("s.Set(%q, %q, 0) failed with %v; want success", k, v, err)
+		}
+	}
+
+	for _, spec := range []struct {
+		key, value string
+		deleted    bool
+		valid      bool
+	}{
+		{key: "/goship/config", value: `{"admins":["alice","bob"]}`, valid: true},
+		{key: "/goship/config", value: `{"freezes":[{"start":"x"}]}`},
+		{key: "/goship/config", value: "admins"},
+		{key: "/goship/config", deleted: true},
+		{key: "/goship/projects/proj/config", value: `{"repo_type":"svn"}`},
+		{key: "/goship/projects/proj/config", value: `{"repo_owner":"other"}`, valid: true},
+		{key: "/goship/projects/proj/environments/qa", value: "{}", valid: true},
+		{key: "/goship/projects/proj/environments/qa", value: `{"parallelism":-1}`},
+		{key: "/goship/projects/proj/environments", value: "{}"},
+		{key: "/goship/projects/proj", deleted: true, valid: true},
+		{key: "/goship/projects", deleted: true},
+		{key: "/goship/projects/broken/config", value: `{"repo_owner":"owner"}`, valid: true},
+		{key: "/goship/projects/new/config", value: `{"host_type":"unknown"}`},
+		{key: "/goship/comments/proj/staging", value: `[{"id":1}]`, valid: true},
+		{key: "/goship/comments/proj/staging", value: "["},
+		{key: "/goship/locks/proj/staging", value: "anything", valid: true},
+		{key: "/goship//locks/proj/staging/", value: "anything", valid: true},
+		{key: "/goship/audit/1", value: "{}"},
+		{key: "/goship/audit", deleted: true},
+		{key: "/goship", deleted: true},
+		{key: "/other", value: "x"},
+	} {
+		err := config.ValidateKeyChange(s, spec.key, spec.value, spec.deleted)
+		if spec.valid && err != nil {
+			t.Errorf("config.ValidateKeyChange(s, %q, %q, %t) failed with %v; want success", spec.key, spec.value, spec.deleted, err)
+		}
+		if !spec.valid && err == nil {
+			t.Errorf("config.ValidateKeyChange(s, %q, %q, %t) succeeded; want failure", spec.key, spec.value, spec.deleted)
+		}
+	}
+
+	// validation does not change the store
+	resp, err := s.Get("/goship/config", false, false)
+	if err != nil {
+		t.Fatalf("s.Get(%q, false, false) failed with %v; want success", "/goship/config", err)
+	}
+	if got, want := resp.Node.Value, `{"admins":["alice"]}`; got != want {
+		t.Errorf("value of /goship/config = %q; want %q", got, want)
+	}
+}
+
+// failingStore fails to read the keys under "prefix" recursively.
+type failingStore struct {
+	*config.MemoryStore
+	prefix string
+}
+
+func (s failingStore) Get(key string, sort, recursive bool) (*etcd.Response, error) {
+	if recursive && strings.HasPrefix(key, s.prefix) {
+		return nil, errors.New("etcd unavailable")
+	}
+	return s.MemoryStore.Get(key, sort, recursive)
+}
+
+func TestValidateKeyChangeReadError(t *testing.T) {
+	s := failingStore{MemoryStore: config.NewMemoryStore(), prefix: "/goship/projects/proj"}
+	key, value := "/goship/projects/proj/environments/qa", `{"parallelism":-1}`
+	if err := config.ValidateKeyChange(s, key, value, false); err == nil {
+		t.Errorf("config.ValidateKeyChange(s, %q, %q, false) succeeded; want failure", key, value)
+	}
+}
+
+func TestValidateKeyRead(t *testing.T) {
+	for _, spec := range []struct {
+		key   string
+		valid bool
+	}{
+		{key: "/goship", valid: true},
+		{key: "/goship/", valid: true},
+		{key: "/goship/projects/proj", valid: true},
+		{key: "/goship/../other", valid: false},
+		{key: "/goshipper", valid: false},
+		{key: "/other", valid: false},
+		{key: "/", valid: false},
+	} {
+		err := config.ValidateKeyRead(spec.key)
+		if spec.valid && err != nil {
+			t.Errorf("config.ValidateKeyRead(%q) failed with %v; want success", spec.key, err)
+		}
+		if !spec.valid && err == nil {
+			t.Errorf("config.ValidateKeyRead(%q) succeeded; want failure", spec.key)
+		}
+	}
+}
@@ -0,0 +1,149 @@
+package config
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path"
+	"strings"
+	"time"
+)
+
+const (
+	kioskTokensDir = "/goship/kiosk_tokens"
+	// kioskUserPrefix prefixes the names of the users which kiosk tokens authenticate so that they never collide with real users.
+	kioskUserPrefix = "kiosk:"
+)
+
+// ErrKioskTokenExists is returned when a kiosk token of the same name already exists.
+var ErrKioskTokenExists = errors.New("kiosk token already exists")
+
+// KioskToken is a read-only token which lets a wall display show the dashboard without a user session.
+// Only the hash of the secret token is kept.
+type KioskToken struct {
+	// Name identifies the token, e.g. "office-wall".
+	Name string `json:"name"`
+	// Projects are the names of the projects which the display can show. It can show all the projects if empty.
+	Projects []string `json:"projects,omitempty"`
+	// Hash is the hex-encoded SHA-256 hash of the secret token.
+	Hash string `json:"hash"`
+	// User is the name of the admin who created the token.
+	User string    `json:"user"`
+	Time time.Time `json:"time"`
+}
+
+// UserName returns the name of the user which the token authenticates.
+func (t KioskToken) UserName() string {
+	return kioskUserPrefix + t.Name
+}
+
+// Covers returns true if the token can show the project named "project".
+func (t KioskToken) Covers(project string) bool {
+	return len(t.Projects) == 0 || contains(t.Projects, project)
+}
+
+// KioskTokenName returns the name of the kiosk token which authenticates the user named "user", or false if the user is not a kiosk.
+func KioskTokenName(user string) (string, bool) {
+	if !strings.HasPrefix(user, kioskUserPrefix) {
+		return "", false
+	}
+	return strings.TrimPrefix(user, kioskUserPrefix), true
+}
+
+func hashKioskToken(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
+
+// LoadKioskTokens returns all the kiosk tokens, sorted by their names.
+func LoadKioskTokens(client ETCDInterface) ([]KioskToken, error) {
+	nodes, err := getDir(client, kioskTokensDir)
+	if err != nil {
+		return nil, err
+	}
+	tokens := make([]KioskToken, 0, len(nodes))
+	for _, n := range nodes {
+		var t KioskToken
+		if err := json.Unmarshal([]byte(n.Value), &t); err != nil {
+			return nil, fmt.Errorf("invalid kiosk token %s: %v", n.Key, err)
+		}
+		tokens = append(tokens, t)
+	}
+	return tokens, nil
+}
+
+// LoadKioskToken returns the kiosk token named "name", or nil if there is no such token.
+func LoadKioskToken(client ETCDInterface, name string) (*KioskToken, error) {
+	resp, err := client.Get(path.Join(kioskTokensDir, name), false, false)
+	if IsNotFound(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var t KioskToken
+	if err := json.Unmarshal([]byte(resp.Node.Value), &t); err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+// FindKioskToken returns the kiosk token whose secret is "secret", or nil if the secret is unknown or revoked.
+func FindKioskToken(client ETCDInterface, secret string) (*KioskToken, error) {
+	if secret == "" {
+		return nil, nil
+	}
+	tokens, err := LoadKioskTokens(client)
+	if err != nil {
+		return nil, err
+	}
+	hash := []byte(hashKioskToken(secret))
+	for _, t := range tokens {
+		if subtle.ConstantTimeCompare(hash, []byte(t.Hash)) == 1 {
+			return &t, nil
+		}
+	}
+	return nil, nil
+}
+
+// CreateKioskToken creates a kiosk token named "name" for "projects" on behalf of "user".
+// It returns the token and its secret, which is not kept anywhere and cannot be shown again.
+func CreateKioskToken(client ETCDInterface, name string, projects []string, user string) (KioskToken, string, error) {
+	if err := validName(name); err != nil {
+		return KioskToken{}, "", fmt.Errorf("invalid kiosk token name: %v", err)
+	}
+	existing, err := LoadKioskToken(client, name)
+	if err != nil {
+		return KioskToken{}, "", err
+	}
+	if existing != nil {
+		return KioskToken{}, "", ErrKioskTokenExists
+	}
+	buf := make([]byte, 24)
+	if _, err := rand.Read(buf); err != nil {
+		return KioskToken{}, "", err
+	}
+	secret := hex.EncodeToString(buf)
+	t := KioskToken{Name: name, Projects: projects, Hash: hashKioskToken(secret), User: user, Time: time.Now()}
+	v, err := json.Marshal(t)
+	if err != nil {
+		return KioskToken{}, "", err
+	}
+	if _, err := client.Set(path.Join(kioskTokensDir, name), string(v), 0); err != nil {
+		return KioskToken{}, "", err
+	}
+	return t, secret, nil
+}
+
+// RevokeKioskToken deletes the kiosk token named "name". Displays with the token lose access immediately.
+func RevokeKioskToken(client EditableStore, name string) error {
+	if err := validName(name); err != nil {
+		return fmt.Errorf("invalid kiosk token name: %v", err)
+	}
+	_, err := client.Delete(path.Join(kioskTokensDir, name), false)
+	return err
+}
@@ -0,0 +1,61 @@
+package config_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/gengo/goship/lib/config"
+)
+
+func TestKioskTokens(t *testing.T) {
+	s := config.NewMemoryStore()
+	tok, secret, err := config.CreateKioskToken(s, "office-wall", []string{"admin"}, "alice")
+	if err != nil {
+		t.Fatalf("config.CreateKioskToken(s, %q, ...) failed with %v; want success", "office-wall", err)
+	}
+	if secret == "" || tok.Hash == "" || tok.Hash == secret {
+		t.Errorf("config.CreateKioskToken(s, %q, ...) = %#v, %q; want a secret kept only as its hash", "office-wall", tok, secret)
+	}
+	if _, _, err := config.CreateKioskToken(s, "office-wall", nil, "bob"); err != config.ErrKioskTokenExists {
+		t.Errorf("config.CreateKioskToken(s, %q, ...) failed with %v; want %v", "office-wall", err, config.ErrKioskTokenExists)
+	}
+	if _, _, err := config.CreateKioskToken(s, "a/b", nil, "bob"); err == nil {
+		t.Errorf("config.CreateKioskToken(s, %q, ...) succeeded; want failure", "a/b")
+	}
+
+	found, err := config.FindKioskToken(s, secret)
+	if err != nil {
+		t.Fatalf("config.FindKioskToken(s, secret) failed with %v; want success", err)
+	}
+	if found == nil || found.Name != tok.Name || found.Hash != tok.Hash || !reflect.DeepEqual(found.Projects, tok.Projects) {
+		t.Errorf("config.FindKioskToken(s, secret) = %#v; want %#v", found, tok)
+	}
+	for _, wrong := range []string{"", "wrong", tok.Hash} {
+		if found, err := config.FindKioskToken(s, wrong); err != nil || found != nil {
+			t.Errorf("config.FindKioskToken(s, %q) = %#v, %v; want nil, nil", wrong, found, err)
+		}
+	}
+	if name, ok := config.KioskTokenName(tok.UserName()); !ok || name != "office-wall" {
+		t.Errorf("config.KioskTokenName(%q) = %q, %t; want %q, true", tok.UserName(), name, ok, "office-wall")
+	}
+	if _, ok := config.KioskTokenName("alice"); ok {
+		t.Errorf("config.KioskTokenName(%q) = _, true; want false", "alice")
+	}
+	if !tok.Covers("admin") || tok.Covers("other") {
+		t.Errorf("tok.Covers covers %q only = false; want true", "admin")
+	}
+
+	if err := config.RevokeKioskToken(s, "office-wall"); err != nil {
+		t.Fatalf("config.RevokeKioskToken(s, %q) failed with %v; want success", "office-wall", err)
+	}
+	if found, err := config.FindKioskToken(s, secret); err != nil || found != nil {
+		t.Errorf("config.FindKioskToken(s, secret) = %#v, %v after revocation; want nil, nil", found, err)
+	}
+	tokens, err := config.LoadKioskTokens(s)
+	if err != nil {
+		t.Fatalf("config.LoadKioskTokens(s) failed with %v; want success", err)
+	}
+	if len(tokens) != 0 {
+		t.Errorf("config.LoadKioskTokens(s) = %#v; want no tokens", tokens)
+	}
+}
@@ -2,17 +2,32 @@ package config
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"path"
+	"sort"
+	"time"
 
 	"github.com/coreos/go-etcd/etcd"
+	"github.com/gengo/goship/lib/metrics"
 	"github.com/golang/glog"
 )
 
-// Load loads a deployment configuration from etcd
+var etcdReadFailures = metrics.NewCounterVec("goship_etcd_read_failures_total", "Number of failures on reading configurations from etcd.")
+
+// Load loads a deployment configuration from etcd.
+// It returns a snapshot of the cached configuration if "client" is a Cache.
 func Load(client ETCDInterface) (Config, error) {
+	if c, ok := client.(*Cache); ok {
+		return c.Config()
+	}
+	return load(client)
+}
+
+func load(client ETCDInterface) (Config, error) {
 	resp, err := client.Get("/goship/config", false, false)
 	if err != nil {
+		etcdReadFailures.Inc()
 		return Config{}, err
 	}
 	var cfg Config
@@ -20,6 +35,35 @@ func Load(client ETCDInterface) (Config, error) {
 		glog.Errorf("Failed to unmarshal %s: %v", resp.Node.Value, err)
 		return Config{}, err
 	}
+	for _, f := range cfg.Freezes {
+		if err := f.validate(); err != nil {
+			return Config{}, fmt.Errorf("invalid freeze window: %v", err)
+		}
+	}
+	if cfg.Federation != nil {
+		if err := cfg.Federation.validate(); err != nil {
+			return Config{}, fmt.Errorf("invalid federation: %v", err)
+		}
+	}
+	if cfg.SCIM != nil {
+		if err := cfg.SCIM.validate(); err != nil {
+			return Config{}, fmt.Errorf("invalid scim: %v", err)
+		}
+	}
+	if cfg.SMTP != nil {
+		if err := cfg.SMTP.validate(); err != nil {
+			return Config{}, fmt.Errorf("invalid smtp: %v", err)
+		}
+	}
+	if err := validateWeeklyReports(cfg.WeeklyReports, cfg.SMTP); err != nil {
+		return Config{}, fmt.Errorf("invalid weekly_reports: %v", err)
+	}
+	if err := validateCITokens(cfg.CITokens); err != nil {
+		return Config{}, fmt.Errorf("invalid ci_tokens: %v", err)
+	}
+	if err := validateClientCerts(cfg.ClientCerts); err != nil {
+		return Config{}, fmt.Errorf("invalid client_certs: %v", err)
+	}
 	if err := loadProjects(client, &cfg, "/goship"); err != nil {
 		return Config{}, err
 	}
@@ -30,6 +74,7 @@ func Load(client ETCDInterface) (Config, error) {
 func loadProjects(client ETCDInterface, cfg *Config, basePath string) error {
 	projs, err := client.Get(path.Join(basePath, "projects"), false, true)
 	if err != nil {
+		etcdReadFailures.Inc()
 		return err
 	}
 	if !projs.Node.Dir {
@@ -73,9 +118,53 @@ func loadProject(node *etcd.Node) (Project, error) {
 	if !proj.RepoType.Valid() {
 		return Project{}, fmt.Errorf("invalid repo_type %q", proj.RepoType)
 	}
+	if proj.SCM == "" {
+		proj.SCM = SCMGithub
+	}
+	if !proj.SCM.Valid() {
+		return Project{}, fmt.Errorf("invalid scm %q", proj.SCM)
+	}
 	if proj.RepoType == RepoTypeDocker && proj.Source == nil {
 		return Project{}, fmt.Errorf("source repo not configured in %s", name)
 	}
+	for _, n := range proj.Notifiers {
+		if !n.Type.Valid() {
+			return Project{}, fmt.Errorf("invalid notifier type %q", n.Type)
+		}
+		if n.URL == "" {
+			return Project{}, fmt.Errorf("notifier url not configured in %s", name)
+		}
+		if !n.Digest.Valid() {
+			return Project{}, fmt.Errorf("invalid notifier digest %q in %s", n.Digest, name)
+		}
+	}
+	if proj.DeployTimeout != "" {
+		if _, err := time.ParseDuration(proj.DeployTimeout); err != nil {
+			return Project{}, fmt.Errorf("invalid deploy_timeout %q in %s: %v", proj.DeployTimeout, name, err)
+		}
+	}
+	if proj.HealthCheck != nil {
+		if err := proj.HealthCheck.validate(); err != nil {
+			return Project{}, fmt.Errorf("invalid health_check in %s: %v", name, err)
+		}
+	}
+	if proj.AutoRollback && proj.HealthCheck == nil {
+		return Project{}, fmt.Errorf("auto_rollback requires health_check in %s", name)
+	}
+	if err := validateRoles(proj.Roles); err != nil {
+		return Project{}, fmt.Errorf("%v in %s", err, name)
+	}
+	if f := proj.FailureIssue; f != nil {
+		if f.Threshold < 0 {
+			return Project{}, fmt.Errorf("invalid threshold %d of failure_issue in %s", f.Threshold, name)
+		}
+		if proj.SCM != SCMGithub {
+			return Project{}, fmt.Errorf("failure_issue requires the source repository in github in %s", name)
+		}
+	}
+	if proj.GithubDeployments && proj.SCM != SCMGithub {
+		return Project{}, fmt.Errorf("github_deployments requires the source repository in github in %s", name)
+	}
 	if proj.K8sSelector == "" {
 		proj.K8sSelector = name
 	}
@@ -84,9 +173,41 @@ func loadProject(node *etcd.Node) (Project, error) {
 	if err := loadEnvironments(envs, &proj); err != nil {
 		return Project{}, err
 	}
+	sort.Sort(byEnvironmentOrder{envs: proj.Environments, order: environmentRanks(proj.EnvironmentOrder)})
 	return proj, nil
 }
 
+// environmentRanks maps names of environments to their positions in "order".
+func environmentRanks(order []string) map[string]int {
+	ranks := make(map[string]int)
+	for i, name := range order {
+		if _, ok := ranks[name]; !ok {
+			ranks[name] = i
+		}
+	}
+	return ranks
+}
+
+// byEnvironmentOrder sorts environments by their positions in EnvironmentOrder of the project, and then by their names.
+type byEnvironmentOrder struct {
+	envs  []Environment
+	order map[string]int
+}
+
+func (s byEnvironmentOrder) Len() int      { return len(s.envs) }
+func (s byEnvironmentOrder) Swap(i, j int) { s.envs[i], s.envs[j] = s.envs[j], s.envs[i] }
+func (s byEnvironmentOrder) Less(i, j int) bool {
+	ri, iok := s.order[s.envs[i].Name]
+	rj, jok := s.order[s.envs[j].Name]
+	switch {
+	case iok && jok:
+		return ri < rj
+	case iok != jok:
+		return iok
+	}
+	return s.envs[i].Name < s.envs[j].Name
+}
+
 func loadEnvironments(node *etcd.Node, proj *Project) error {
 	if !node.Dir {
 		return fmt.Errorf("node %s must be a directory", node.Key)
@@ -101,6 +222,35 @@ func loadEnvironments(node *etcd.Node, proj *Project) error {
 	return nil
 }
 
+// loadHostGroups validates the host groups of "env" and adds their hosts to the hosts of "env".
+func loadHostGroups(env *Environment) error {
+	if len(env.HostGroups) == 0 {
+		return nil
+	}
+	if env.Rollout != nil {
+		return errors.New("rollout cannot be used with host_groups")
+	}
+	if env.IsK8sDeployment() {
+		return errors.New("host_groups cannot be used with k8s_deployment")
+	}
+	names := make(map[string]bool)
+	for _, g := range env.HostGroups {
+		if g.Name == "" {
+			return errors.New("host group without name")
+		}
+		if names[g.Name] {
+			return fmt.Errorf("duplicate host group %q", g.Name)
+		}
+		names[g.Name] = true
+		for _, h := range g.Hosts {
+			if !contains(env.Hosts, h) {
+				env.Hosts = append(env.Hosts, h)
+			}
+		}
+	}
+	return nil
+}
+
 func loadEnvironment(node *etcd.Node) (Environment, error) {
 	var env Environment
 	if err := json.Unmarshal([]byte(node.Value), &env); err != nil {
@@ -114,5 +264,69 @@ func loadEnvironment(node *etcd.Node) (Environment, error) {
 	if env.K8sNamespace == "" {
 		env.K8sNamespace = "default"
 	}
+	if env.FailurePolicy != "" && !env.FailurePolicy.Valid() {
+		return Environment{}, fmt.Errorf("invalid failure_policy %q in %s", env.FailurePolicy, node.Key)
+	}
+	if env.Parallelism < 0 {
+		return Environment{}, fmt.Errorf("negative parallelism %d in %s", env.Parallelism, node.Key)
+	}
+	if err := validateRoles(env.Roles); err != nil {
+		return Environment{}, fmt.Errorf("%v in %s", err, node.Key)
+	}
+	if err := loadHostGroups(&env); err != nil {
+		return Environment{}, fmt.Errorf("%v in %s", err, node.Key)
+	}
+	if err := validateSecrets(env.Secrets); err != nil {
+		return Environment{}, fmt.Errorf("%v in %s", err, node.Key)
+	}
+	if err := validateVars(env.Vars, env.Secrets); err != nil {
+		return Environment{}, fmt.Errorf("%v in %s", err, node.Key)
+	}
+	if err := validateActions(env.Actions); err != nil {
+		return Environment{}, fmt.Errorf("%v in %s", err, node.Key)
+	}
+	if rc := env.RemoteCommands; rc != nil {
+		if err := rc.validate(); err != nil {
+			return Environment{}, fmt.Errorf("invalid remote_commands in %s: %v", node.Key, err)
+		}
+	}
+	if inv := env.Inventory; inv != nil {
+		if len(env.Hosts) > 0 {
+			return Environment{}, fmt.Errorf("inventory cannot be used with hosts or host_groups in %s", node.Key)
+		}
+		if env.IsK8sDeployment() {
+			return Environment{}, fmt.Errorf("inventory cannot be used with k8s_deployment in %s", node.Key)
+		}
+		if err := inv.validate(); err != nil {
+			return Environment{}, fmt.Errorf("invalid inventory in %s: %v", node.Key, err)
+		}
+	}
+	if r := env.Rollout; r != nil {
+		if !env.PerHost {
+			return Environment{}, fmt.Errorf("rollout requires per_host in %s", node.Key)
+		}
+		if r.Canary <= 0 {
+			return Environment{}, fmt.Errorf("rollout needs at least one canary host in %s", node.Key)
+		}
+		if err := r.HealthCheck.validate(); err != nil {
+			return Environment{}, fmt.Errorf("invalid rollout in %s: %v", node.Key, err)
+		}
+	}
+	if a := env.Artifact; a != nil {
+		if err := a.validate(); err != nil {
+			return Environment{}, fmt.Errorf("invalid artifact in %s: %v", node.Key, err)
+		}
+		if a.Type == ArtifactImage && a.URL == "" && env.K8sImage == "" {
+			return Environment{}, fmt.Errorf("url of artifact not specified in %s", node.Key)
+		}
+	}
+	if env.IsK8sDeployment() {
+		if env.K8sImage == "" {
+			return Environment{}, fmt.Errorf("k8s_image not configured in %s", node.Key)
+		}
+		if _, err := env.Image("", ""); err != nil {
+			return Environment{}, fmt.Errorf("invalid k8s_image %q in %s: %v", env.K8sImage, node.Key, err)
+		}
+	}
 	return env, nil
 }
@@ -81,6 +81,7 @@ func TestLoad(t *testing.T) {
 				Name:     "example-project",
 				RepoType: config.RepoTypeGithub,
 				HostType: config.HostTypeNode,
+				SCM:      config.SCMGithub,
 				Repo: config.Repo{
 					RepoName:  "example",
 					RepoOwner: "gengo",
@@ -138,3 +139,45 @@ func (cl mockEtcdClient) Get(key string, sort bool, recursive bool) (*etcd.Respo
 		RaftTerm:  1,
 	}, nil
 }
+
+// exampleConfig returns a configuration with "example-project", whose "production" environment only has a deploy command.
+func exampleConfig() config.Config {
+	return config.Config{
+		Projects: []config.Project{{
+			Name:         "example-project",
+			Environments: []config.Environment{{Name: "production", Deploy: "deploy-command"}},
+		}},
+	}
+}
+
+// storeAndLoad stores "cfg" into a new MemoryStore and loads it back.
+func storeAndLoad(t *testing.T, cfg config.Config) (config.Config, error) {
+	s := config.NewMemoryStore()
+	if err := config.Store(s, cfg); err != nil {
+		t.Fatalf("config.Store(s, cfg) failed with %v; want success", err)
+	}
+	return config.Load(s)
+}
+
+// loadProject stores "proj" and loads it back.
+// It returns false if config.Load skipped the project as invalid.
+func loadProject(t *testing.T, proj config.Project) (config.Project, bool) {
+	cfg, err := storeAndLoad(t, config.Config{Projects: []config.Project{proj}})
+	if err != nil {
+		t.Fatalf("config.Load(s) failed with %v; want success", err)
+	}
+	if len(cfg.Projects) == 0 {
+		return config.Project{}, false
+	}
+	return cfg.Projects[0], true
+}
+
+// loadEnvironment stores "env" in "example-project" and loads it back.
+// It returns false if config.Load skipped the project as invalid.
+func loadEnvironment(t *testing.T, env config.Environment) (config.Environment, bool) {
+	proj, ok := loadProject(t, config.Project{Name: "example-project", Environments: []config.Environment{env}})
+	if !ok {
+		return config.Environment{}, false
+	}
+	return proj.Environments[0], true
+}
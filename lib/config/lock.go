@@ -1,7 +1,11 @@
 package config
 
 import (
+	"encoding/json"
 	"fmt"
+	"time"
+
+	"github.com/gengo/goship/lib/clock"
 )
 
 // SetComment will set the  comment field on an environment
@@ -15,13 +19,129 @@ func SetComment(client ETCDInterface, projectName, projectEnv, comment string) (
 	return err
 }
 
-// LockEnvironment Locks or unlock an environment for deploy
-func LockEnvironment(client ETCDInterface, projectName, projectEnv, lock string) (err error) {
-	projectString := fmt.Sprintf("/goship/projects/%s/environments/%s/locked", projectName, projectEnv)
+// maxLockEvents is the maximum number of lock changes kept in the audit trail of an environment.
+const maxLockEvents = 100
+
+// Lock blocks deployments into an environment.
+type Lock struct {
+	// User is the name of the user who locked the environment.
+	User string `json:"user"`
+	// Reason describes why the environment is locked.
+	Reason string    `json:"reason"`
+	Time   time.Time `json:"time"`
+	// Expires is when the environment gets unlocked automatically.
+	// The lock does not expire if zero.
+	Expires time.Time `json:"expires,omitempty"`
+}
+
+// Expired returns true if the lock has expired at "t".
+func (l Lock) Expired(t time.Time) bool {
+	return !l.Expires.IsZero() && !t.Before(l.Expires)
+}
+
+// LockEvent is an entry of the audit trail of locks in an environment.
+type LockEvent struct {
+	// Locked is true if the environment got locked, or false if it got unlocked.
+	Locked bool `json:"locked"`
+	Lock
+}
+
+// LockGroupMember is an environment in a lock group.
+type LockGroupMember struct {
+	Project     string `json:"project"`
+	Environment string `json:"environment"`
+}
+
+// LockGroup returns the other environments in the lock group of "envName" of "projName" in "projs", in the order of "projs".
+// It returns nil if the environment is not in a lock group.
+func LockGroup(projs []Project, projName, envName string) []LockGroupMember {
+	e, err := EnvironmentFromName(projs, projName, envName)
+	if err != nil || e.LockGroup == "" {
+		return nil
+	}
+	var members []LockGroupMember
+	for _, p := range projs {
+		for _, other := range p.Environments {
+			if other.LockGroup == e.LockGroup && (p.Name != projName || other.Name != envName) {
+				members = append(members, LockGroupMember{Project: p.Name, Environment: other.Name})
+			}
+		}
+	}
+	return members
+}
+
+func lockKey(projectName, projectEnv string) string {
+	return fmt.Sprintf("/goship/locks/%s/%s", projectName, projectEnv)
+}
+
+// LoadLockEvents returns the audit trail of locks in the environment, oldest first.
+func LoadLockEvents(client ETCDInterface, projectName, projectEnv string) ([]LockEvent, error) {
+	resp, err := client.Get(lockKey(projectName, projectEnv), false, false)
+	if IsNotFound(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return decodeLockEvents(resp.Node.Value)
+}
+
+func decodeLockEvents(value string) ([]LockEvent, error) {
+	if value == "" {
+		return nil, nil
+	}
+	var events []LockEvent
+	if err := json.Unmarshal([]byte(value), &events); err != nil {
+		return nil, err
+	}
+	return events, nil
+}
+
+// LoadLock returns the lock of the environment, or nil if the environment is not locked or the lock has expired.
+func LoadLock(client ETCDInterface, projectName, projectEnv string) (*Lock, error) {
+	events, err := LoadLockEvents(client, projectName, projectEnv)
+	if err != nil {
+		return nil, err
+	}
+	if len(events) == 0 {
+		return nil, nil
+	}
+	last := events[len(events)-1]
+	if !last.Locked || last.Expired(clock.Now()) {
+		return nil, nil
+	}
+	return &last.Lock, nil
+}
+
+// LockEnvironment locks the environment with "lock", which must have a user and a reason.
+func LockEnvironment(client ETCDInterface, projectName, projectEnv string, lock Lock) error {
+	if lock.User == "" || lock.Reason == "" {
+		return fmt.Errorf("user and reason must be given to lock an environment")
+	}
+	return addLockEvent(client, projectName, projectEnv, LockEvent{Locked: true, Lock: lock})
+}
+
+// UnlockEnvironment unlocks the environment on behalf of "user".
+func UnlockEnvironment(client ETCDInterface, projectName, projectEnv, user, reason string) error {
+	return addLockEvent(client, projectName, projectEnv, LockEvent{Lock: Lock{User: user, Reason: reason, Time: clock.Now()}})
+}
+
+func addLockEvent(client ETCDInterface, projectName, projectEnv string, ev LockEvent) error {
 	// guard against empty values ( simple validation)
 	if projectName == "" || projectEnv == "" {
 		return fmt.Errorf("Missing parameters")
 	}
-	_, err = client.Set(projectString, lock, 0)
-	return err
+	// events are swapped so that concurrent changes from the instances of Goship are not lost
+	return update(client, lockKey(projectName, projectEnv), func(value string) (string, error) {
+		events, err := decodeLockEvents(value)
+		if err != nil {
+			return "", err
+		}
+		events = append(events, ev)
+		if len(events) > maxLockEvents {
+			events = events[len(events)-maxLockEvents:]
+		}
+		buf, err := json.Marshal(events)
+		return string(buf), err
+	})
 }
@@ -1,8 +1,12 @@
 package config_test
 
 import (
+	"fmt"
+	"reflect"
 	"testing"
+	"time"
 
+	"github.com/gengo/goship/lib/clock"
 	"github.com/gengo/goship/lib/config"
 )
 
@@ -18,23 +22,128 @@ func TestSetComment(t *testing.T) {
 }
 
 func TestLockingEnvironment(t *testing.T) {
-	const key = "/goship/projects/test_project/environments/test_environment/locked"
-	ecl := mockEtcdClient{
-		setExpectation: map[string]string{key: "true"},
+	s := config.NewMemoryStore()
+	lock := config.Lock{User: "alice", Reason: "release", Time: time.Date(2015, time.November, 10, 23, 0, 0, 0, time.UTC)}
+	if err := config.LockEnvironment(s, "test_project", "test_environment", lock); err != nil {
+		t.Fatalf("Can't lock %s", err)
 	}
-	err := config.LockEnvironment(ecl, "test_project", "test_environment", "true")
+	got, err := config.LoadLock(s, "test_project", "test_environment")
 	if err != nil {
-		t.Fatalf("Can't lock %s", err)
+		t.Fatalf("config.LoadLock(s, %q, %q) failed with %v; want success", "test_project", "test_environment", err)
+	}
+	if got == nil || !reflect.DeepEqual(*got, lock) {
+		t.Errorf("config.LoadLock(s, %q, %q) = %#v; want %#v", "test_project", "test_environment", got, lock)
+	}
+
+	if err := config.LockEnvironment(s, "test_project", "test_environment", config.Lock{User: "alice"}); err == nil {
+		t.Errorf("config.LockEnvironment succeeded without a reason; want failure")
 	}
 }
 
 func TestUnlockingEnvironment(t *testing.T) {
-	const key = "/goship/projects/test_project/environments/test_environment/locked"
-	ecl := mockEtcdClient{
-		setExpectation: map[string]string{key: "false"},
+	s := config.NewMemoryStore()
+	if err := config.LockEnvironment(s, "test_project", "test_environment", config.Lock{User: "alice", Reason: "release", Time: time.Now()}); err != nil {
+		t.Fatalf("Can't lock %s", err)
 	}
-	err := config.LockEnvironment(ecl, "test_project", "test_environment", "false")
+	if err := config.UnlockEnvironment(s, "test_project", "test_environment", "bob", "released"); err != nil {
+		t.Fatalf("Can't unlock %s", err)
+	}
+	if got, err := config.LoadLock(s, "test_project", "test_environment"); err != nil || got != nil {
+		t.Errorf("config.LoadLock(s, %q, %q) = %#v, %v; want nil, nil", "test_project", "test_environment", got, err)
+	}
+
+	events, err := config.LoadLockEvents(s, "test_project", "test_environment")
 	if err != nil {
+		t.Fatalf("config.LoadLockEvents(s, %q, %q) failed with %v; want success", "test_project", "test_environment", err)
+	}
+	var got []string
+	for _, ev := range events {
+		got = append(got, fmt.Sprintf("%v:%s", ev.Locked, ev.User))
+	}
+	if want := []string{"true:alice", "false:bob"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("lock events = %q; want %q", got, want)
+	}
+}
+
+func TestLockingEnvironmentConcurrently(t *testing.T) {
+	m := config.NewMemoryStore()
+	s := &racingStore{MemoryStore: m, race: func() {
+		if err := config.LockEnvironment(m, "test_project", "test_environment", config.Lock{User: "alice", Reason: "release", Time: time.Now()}); err != nil {
+			t.Fatalf("Can't lock %s", err)
+		}
+	}}
+	if err := config.UnlockEnvironment(s, "test_project", "test_environment", "bob", "released"); err != nil {
 		t.Fatalf("Can't unlock %s", err)
 	}
+
+	events, err := config.LoadLockEvents(m, "test_project", "test_environment")
+	if err != nil {
+		t.Fatalf("config.LoadLockEvents(s, %q, %q) failed with %v; want success", "test_project", "test_environment", err)
+	}
+	var got []string
+	for _, ev := range events {
+		got = append(got, fmt.Sprintf("%v:%s", ev.Locked, ev.User))
+	}
+	if want := []string{"true:alice", "false:bob"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("lock events = %q; want %q", got, want)
+	}
+}
+
+func TestLockExpiry(t *testing.T) {
+	clk := clock.NewFake(time.Date(2015, time.November, 13, 12, 0, 0, 0, time.UTC))
+	defer func(c clock.Clock) { clock.Default = c }(clock.Default)
+	clock.Default = clk
+
+	s := config.NewMemoryStore()
+	lock := config.Lock{User: "alice", Reason: "release", Time: clk.Now(), Expires: clk.Now().Add(time.Hour)}
+	if err := config.LockEnvironment(s, "test_project", "test_environment", lock); err != nil {
+		t.Fatalf("Can't lock %s", err)
+	}
+	if got, err := config.LoadLock(s, "test_project", "test_environment"); err != nil || got == nil {
+		t.Errorf("config.LoadLock(s, %q, %q) = %#v, %v; want the lock before expiry", "test_project", "test_environment", got, err)
+	}
+	clk.Advance(time.Hour)
+	if got, err := config.LoadLock(s, "test_project", "test_environment"); err != nil || got != nil {
+		t.Errorf("config.LoadLock(s, %q, %q) = %#v, %v; want nil, nil after expiry", "test_project", "test_environment", got, err)
+	}
+}
+
+func TestLockGroup(t *testing.T) {
+	projs := []config.Project{
+		{
+			Name: "api",
+			Environments: []config.Environment{
+				{Name: "staging"},
+				{Name: "production", LockGroup: "backend"},
+			},
+		},
+		{
+			Name: "worker",
+			Environments: []config.Environment{
+				{Name: "production", LockGroup: "backend"},
+				{Name: "canary", LockGroup: "backend"},
+			},
+		},
+	}
+	for _, spec := range []struct {
+		proj, env string
+		want      []config.LockGroupMember
+	}{
+		{
+			proj: "api",
+			env:  "production",
+			want: []config.LockGroupMember{{Project: "worker", Environment: "production"}, {Project: "worker", Environment: "canary"}},
+		},
+		{
+			proj: "worker",
+			env:  "production",
+			want: []config.LockGroupMember{{Project: "api", Environment: "production"}, {Project: "worker", Environment: "canary"}},
+		},
+		{proj: "api", env: "staging"},
+		{proj: "api", env: "unknown"},
+	} {
+		if got := config.LockGroup(projs, spec.proj, spec.env); !reflect.DeepEqual(got, spec.want) {
+			t.Errorf("config.LockGroup(projs, %q, %q) = %#v; want %#v", spec.proj, spec.env, got, spec.want)
+		}
+	}
 }
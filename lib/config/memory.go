@@ -0,0 +1,184 @@
+package config
+
+import (
+	"path"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/coreos/go-etcd/etcd"
+)
+
+const (
+	// etcdErrorCodeKeyNotFound is the error code which etcd returns for missing keys.
+	etcdErrorCodeKeyNotFound = 100
+	// etcdErrorCodeTestFailed is the error code which etcd returns when the previous value of a compare-and-swap does not match.
+	etcdErrorCodeTestFailed = 101
+	// etcdErrorCodeNotFile is the error code which etcd returns for non-recursive deletion of directories.
+	etcdErrorCodeNotFile = 102
+	// etcdErrorCodeNodeExist is the error code which etcd returns when a key to create already exists.
+	etcdErrorCodeNodeExist = 105
+)
+
+// MemoryStore is an in-memory implementation of ETCDInterface.
+// It is useful for demonstrations and tests which cannot access to etcd.
+type MemoryStore struct {
+	mu     sync.Mutex
+	values map[string]string
+	index  uint64
+}
+
+// NewMemoryStore returns a new empty MemoryStore.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{values: make(map[string]string)}
+}
+
+// Get returns the node at "key".
+// A key is regarded as a directory if there are any values under the key.
+func (s *MemoryStore) Get(key string, sort, recursive bool) (*etcd.Response, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	node := s.node(cleanKey(key), recursive)
+	if node == nil {
+		return nil, &etcd.EtcdError{ErrorCode: etcdErrorCodeKeyNotFound, Message: "Key not found", Cause: key, Index: s.index}
+	}
+	return &etcd.Response{Action: "get", Node: node, EtcdIndex: s.index}, nil
+}
+
+// Set stores "value" at "key". "ttl" is ignored.
+func (s *MemoryStore) Set(key, value string, ttl uint64) (*etcd.Response, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key = cleanKey(key)
+	s.index++
+	resp := &etcd.Response{
+		Action:    "set",
+		Node:      &etcd.Node{Key: key, Value: value, ModifiedIndex: s.index},
+		EtcdIndex: s.index,
+	}
+	if prev, ok := s.values[key]; ok {
+		resp.PrevNode = &etcd.Node{Key: key, Value: prev}
+	}
+	s.values[key] = value
+	return resp, nil
+}
+
+// Delete deletes "key".
+// It deletes all the values under "key" if "recursive" is true.
+func (s *MemoryStore) Delete(key string, recursive bool) (*etcd.Response, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key = cleanKey(key)
+	node := s.node(key, false)
+	if node == nil {
+		return nil, &etcd.EtcdError{ErrorCode: etcdErrorCodeKeyNotFound, Message: "Key not found", Cause: key, Index: s.index}
+	}
+	if node.Dir && !recursive {
+		return nil, &etcd.EtcdError{ErrorCode: etcdErrorCodeNotFile, Message: "Not a file", Cause: key, Index: s.index}
+	}
+	s.index++
+	delete(s.values, key)
+	for k := range s.values {
+		if strings.HasPrefix(k, key+"/") {
+			delete(s.values, k)
+		}
+	}
+	return &etcd.Response{Action: "delete", Node: &etcd.Node{Key: key, Dir: node.Dir, ModifiedIndex: s.index}, EtcdIndex: s.index}, nil
+}
+
+// Create stores "value" at "key" only if "key" does not exist. "ttl" is ignored.
+func (s *MemoryStore) Create(key, value string, ttl uint64) (*etcd.Response, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key = cleanKey(key)
+	if s.node(key, false) != nil {
+		return nil, &etcd.EtcdError{ErrorCode: etcdErrorCodeNodeExist, Message: "Key already exists", Cause: key, Index: s.index}
+	}
+	s.index++
+	s.values[key] = value
+	return &etcd.Response{Action: "create", Node: &etcd.Node{Key: key, Value: value, ModifiedIndex: s.index}, EtcdIndex: s.index}, nil
+}
+
+// CompareAndSwap stores "value" at "key" only if its current value is "prevValue". "ttl" and "prevIndex" are ignored.
+func (s *MemoryStore) CompareAndSwap(key, value string, ttl uint64, prevValue string, prevIndex uint64) (*etcd.Response, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key = cleanKey(key)
+	prev, ok := s.values[key]
+	if !ok {
+		return nil, &etcd.EtcdError{ErrorCode: etcdErrorCodeKeyNotFound, Message: "Key not found", Cause: key, Index: s.index}
+	}
+	if prev != prevValue {
+		return nil, &etcd.EtcdError{ErrorCode: etcdErrorCodeTestFailed, Message: "Compare failed", Cause: key, Index: s.index}
+	}
+	s.index++
+	s.values[key] = value
+	return &etcd.Response{Action: "compareAndSwap", Node: &etcd.Node{Key: key, Value: value, ModifiedIndex: s.index}, PrevNode: &etcd.Node{Key: key, Value: prev}, EtcdIndex: s.index}, nil
+}
+
+// CompareAndDelete deletes "key" only if its current value is "prevValue". "prevIndex" is ignored.
+func (s *MemoryStore) CompareAndDelete(key, prevValue string, prevIndex uint64) (*etcd.Response, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key = cleanKey(key)
+	prev, ok := s.values[key]
+	if !ok {
+		return nil, &etcd.EtcdError{ErrorCode: etcdErrorCodeKeyNotFound, Message: "Key not found", Cause: key, Index: s.index}
+	}
+	if prev != prevValue {
+		return nil, &etcd.EtcdError{ErrorCode: etcdErrorCodeTestFailed, Message: "Compare failed", Cause: key, Index: s.index}
+	}
+	s.index++
+	delete(s.values, key)
+	return &etcd.Response{Action: "compareAndDelete", Node: &etcd.Node{Key: key, ModifiedIndex: s.index}, PrevNode: &etcd.Node{Key: key, Value: prev}, EtcdIndex: s.index}, nil
+}
+
+func (s *MemoryStore) node(key string, recursive bool) *etcd.Node {
+	if v, ok := s.values[key]; ok {
+		return &etcd.Node{Key: key, Value: v}
+	}
+	prefix := key + "/"
+	if key == "/" {
+		prefix = key
+	}
+	children := make(map[string]bool)
+	for k := range s.values {
+		if !strings.HasPrefix(k, prefix) {
+			continue
+		}
+		name := strings.SplitN(strings.TrimPrefix(k, prefix), "/", 2)[0]
+		children[path.Join(key, name)] = true
+	}
+	if len(children) == 0 {
+		return nil
+	}
+	var keys []string
+	for k := range children {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	dir := &etcd.Node{Key: key, Dir: true}
+	for _, k := range keys {
+		if v, ok := s.values[k]; ok {
+			dir.Nodes = append(dir.Nodes, &etcd.Node{Key: k, Value: v})
+			continue
+		}
+		if recursive {
+			dir.Nodes = append(dir.Nodes, s.node(k, true))
+			continue
+		}
+		dir.Nodes = append(dir.Nodes, &etcd.Node{Key: k, Dir: true})
+	}
+	return dir
+}
+
+func cleanKey(key string) string {
+	return path.Clean("/" + key)
+}
@@ -0,0 +1,112 @@
+package config_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/coreos/go-etcd/etcd"
+	"github.com/gengo/goship/lib/config"
+)
+
+func TestMemoryStoreRoundTrip(t *testing.T) {
+	cfg := config.Config{
+		DeployUser: "test_user",
+		Projects: []config.Project{
+			{
+				Name:     "example-project",
+				RepoType: config.RepoTypeGithub,
+				HostType: config.HostTypeNode,
+				SCM:      config.SCMGithub,
+				Repo: config.Repo{
+					RepoName:  "example",
+					RepoOwner: "gengo",
+				},
+				K8sSelector: "example-project",
+				Environments: []config.Environment{
+					{
+						Name:         "production",
+						Deploy:       "deploy-command",
+						Branch:       "master",
+						Hosts:        []string{"host1"},
+						K8sNamespace: "default",
+					},
+					{
+						Name:         "staging",
+						Deploy:       "deploy-command",
+						Branch:       "develop",
+						Hosts:        []string{"host2"},
+						K8sNamespace: "default",
+					},
+				},
+			},
+		},
+	}
+	s := config.NewMemoryStore()
+	if err := config.Store(s, cfg); err != nil {
+		t.Fatalf("config.Store(s, %#v) failed with %v; want success", cfg, err)
+	}
+	got, err := config.Load(s)
+	if err != nil {
+		t.Fatalf("config.Load(s) failed with %v; want success", err)
+	}
+	if !reflect.DeepEqual(got, cfg) {
+		t.Errorf("config.Load(s) = %#v; want %#v", got, cfg)
+	}
+}
+
+func TestMemoryStoreGet(t *testing.T) {
+	s := config.NewMemoryStore()
+	for k, v := range map[string]string{
+		"/a/b/c": "1",
+		"/a/d":   "2",
+	} {
+		if _, err := s.Set(k, v, 0); err != nil {
+			t.Fatalf("s.Set(%q, %q, 0) failed with %v; want success", k, v, err)
+		}
+	}
+
+	resp, err := s.Get("/a", false, false)
+	if err != nil {
+		t.Fatalf("s.Get(%q, false, false) failed with %v; want success", "/a", err)
+	}
+	want := &etcd.Node{
+		Key: "/a",
+		Dir: true,
+		Nodes: etcd.Nodes{
+			{Key: "/a/b", Dir: true},
+			{Key: "/a/d", Value: "2"},
+		},
+	}
+	if got := resp.Node; !reflect.DeepEqual(got, want) {
+		t.Errorf("s.Get(%q, false, false).Node = %#v; want %#v", "/a", got, want)
+	}
+
+	if _, err := s.Get("/no/such/key", false, false); err == nil {
+		t.Errorf("s.Get(%q, false, false) succeeded; want failure", "/no/such/key")
+	}
+}
+
+func TestMemoryStoreCompare(t *testing.T) {
+	s := config.NewMemoryStore()
+	if _, err := s.Create("/a", "1", 0); err != nil {
+		t.Fatalf("s.Create(%q, %q, 0) failed with %v; want success", "/a", "1", err)
+	}
+	if _, err := s.Create("/a", "2", 0); !config.IsExist(err) {
+		t.Errorf("s.Create(%q, %q, 0) returned %v; want an error of existing key", "/a", "2", err)
+	}
+	if _, err := s.CompareAndSwap("/a", "2", 0, "0", 0); !config.IsCompareFailed(err) {
+		t.Errorf("s.CompareAndSwap(%q, %q, 0, %q, 0) returned %v; want an error of failed comparison", "/a", "2", "0", err)
+	}
+	if _, err := s.CompareAndSwap("/a", "2", 0, "1", 0); err != nil {
+		t.Errorf("s.CompareAndSwap(%q, %q, 0, %q, 0) failed with %v; want success", "/a", "2", "1", err)
+	}
+	if _, err := s.CompareAndDelete("/a", "1", 0); !config.IsCompareFailed(err) {
+		t.Errorf("s.CompareAndDelete(%q, %q, 0) returned %v; want an error of failed comparison", "/a", "1", err)
+	}
+	if _, err := s.CompareAndDelete("/a", "2", 0); err != nil {
+		t.Errorf("s.CompareAndDelete(%q, %q, 0) failed with %v; want success", "/a", "2", err)
+	}
+	if _, err := s.Get("/a", false, false); !config.IsNotFound(err) {
+		t.Errorf("s.Get(%q, false, false) returned %v after s.CompareAndDelete; want an error of missing key", "/a", err)
+	}
+}
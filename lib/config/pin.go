@@ -0,0 +1,83 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// Pin pins an environment to a specific revision.
+// Deployments of other revisions into the environment are rejected until the environment is unpinned.
+type Pin struct {
+	// Revision is the only revision which can be deployed.
+	Revision string `json:"revision"`
+	// User is the name of the user who pinned the environment.
+	User string `json:"user"`
+	// Reason describes why the environment is pinned.
+	Reason string    `json:"reason,omitempty"`
+	Time   time.Time `json:"time"`
+}
+
+// Allows returns true if "rev" can be deployed under the pin.
+// "p.Revision" can be an abbreviation of "rev".
+func (p Pin) Allows(rev string) bool {
+	return strings.HasPrefix(rev, p.Revision)
+}
+
+// pinRevisionPattern matches revisions which environments can be pinned to.
+// Shorter abbreviations would allow deployments of too many revisions in Allows.
+var pinRevisionPattern = regexp.MustCompile(`^[0-9a-f]{7,}$`)
+
+func pinKey(projectName, projectEnv string) string {
+	return fmt.Sprintf("/goship/pins/%s/%s", projectName, projectEnv)
+}
+
+// LoadPin returns the pin of the environment, or nil if the environment is not pinned.
+func LoadPin(client ETCDInterface, projectName, projectEnv string) (*Pin, error) {
+	resp, err := client.Get(pinKey(projectName, projectEnv), false, false)
+	if IsNotFound(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if resp.Node.Value == "" {
+		return nil, nil
+	}
+	var pin Pin
+	if err := json.Unmarshal([]byte(resp.Node.Value), &pin); err != nil {
+		return nil, err
+	}
+	return &pin, nil
+}
+
+// PinEnvironment pins the environment to "pin.Revision".
+// "pin.Revision" must be a lowercase hex revision or an abbreviation of at least 7 characters.
+func PinEnvironment(client ETCDInterface, projectName, projectEnv string, pin Pin) error {
+	if projectName == "" || projectEnv == "" {
+		return fmt.Errorf("Missing parameters")
+	}
+	if pin.Revision == "" {
+		return fmt.Errorf("revision not specified")
+	}
+	if !pinRevisionPattern.MatchString(pin.Revision) {
+		return fmt.Errorf("invalid revision %q: must be at least 7 hex characters", pin.Revision)
+	}
+	buf, err := json.Marshal(pin)
+	if err != nil {
+		return err
+	}
+	_, err = client.Set(pinKey(projectName, projectEnv), string(buf), 0)
+	return err
+}
+
+// UnpinEnvironment removes the pin of the environment.
+func UnpinEnvironment(client ETCDInterface, projectName, projectEnv string) error {
+	if projectName == "" || projectEnv == "" {
+		return fmt.Errorf("Missing parameters")
+	}
+	_, err := client.Set(pinKey(projectName, projectEnv), "", 0)
+	return err
+}
@@ -0,0 +1,59 @@
+package config_test
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/gengo/goship/lib/config"
+)
+
+func TestPinEnvironment(t *testing.T) {
+	s := config.NewMemoryStore()
+	if pin, err := config.LoadPin(s, "proj", "env"); err != nil || pin != nil {
+		t.Errorf("config.LoadPin(s, %q, %q) = %#v, %v; want nil, nil", "proj", "env", pin, err)
+	}
+
+	pin := config.Pin{
+		Revision: "abc1234",
+		User:     "alice",
+		Reason:   "under certification",
+		Time:     time.Date(2015, time.November, 10, 23, 0, 0, 0, time.UTC),
+	}
+	if err := config.PinEnvironment(s, "proj", "env", pin); err != nil {
+		t.Fatalf("config.PinEnvironment(s, %q, %q, %#v) failed with %v; want success", "proj", "env", pin, err)
+	}
+	got, err := config.LoadPin(s, "proj", "env")
+	if err != nil {
+		t.Fatalf("config.LoadPin(s, %q, %q) failed with %v; want success", "proj", "env", err)
+	}
+	if got == nil || !reflect.DeepEqual(*got, pin) {
+		t.Errorf("config.LoadPin(s, %q, %q) = %#v; want %#v", "proj", "env", got, pin)
+	}
+
+	if err := config.UnpinEnvironment(s, "proj", "env"); err != nil {
+		t.Fatalf("config.UnpinEnvironment(s, %q, %q) failed with %v; want success", "proj", "env", err)
+	}
+	if pin, err := config.LoadPin(s, "proj", "env"); err != nil || pin != nil {
+		t.Errorf("config.LoadPin(s, %q, %q) = %#v, %v after unpin; want nil, nil", "proj", "env", pin, err)
+	}
+}
+
+func TestPinEnvironmentWithoutRevision(t *testing.T) {
+	s := config.NewMemoryStore()
+	if err := config.PinEnvironment(s, "proj", "env", config.Pin{User: "alice"}); err == nil {
+		t.Errorf("config.PinEnvironment(s, %q, %q, pin) succeeded without revision; want failure", "proj", "env")
+	}
+}
+
+func TestPinEnvironmentWithInvalidRevision(t *testing.T) {
+	s := config.NewMemoryStore()
+	for _, rev := range []string{"a", "abc123", "ABC1234", "master", "abc1234 "} {
+		if err := config.PinEnvironment(s, "proj", "env", config.Pin{Revision: rev, User: "alice"}); err == nil {
+			t.Errorf("config.PinEnvironment(s, %q, %q, pin) succeeded with revision %q; want failure", "proj", "env", rev)
+		}
+	}
+	if pin, err := config.LoadPin(s, "proj", "env"); err != nil || pin != nil {
+		t.Errorf("config.LoadPin(s, %q, %q) = %#v, %v after invalid pins; want nil, nil", "proj", "env", pin, err)
+	}
+}
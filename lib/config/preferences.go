@@ -0,0 +1,107 @@
+package config
+
+import (
+	"encoding/json"
+	"path"
+
+	"github.com/coreos/go-etcd/etcd"
+	"github.com/golang/glog"
+)
+
+// UserPreferences is a set of per-user settings which override the installation-wide configuration.
+type UserPreferences struct {
+	// HomeColumns is the list of columns in the home page, which overrides Config.HomeColumns.
+	HomeColumns []string `json:"home_columns,omitempty"`
+}
+
+func preferencesKey(user string) string {
+	return path.Join("/goship/users", user, "preferences")
+}
+
+// LoadUserPreferences loads preferences of "user" from etcd.
+// It returns empty preferences if the user has not stored any.
+func LoadUserPreferences(client ETCDInterface, user string) (UserPreferences, error) {
+	resp, err := client.Get(preferencesKey(user), false, false)
+	if IsNotFound(err) {
+		return UserPreferences{}, nil
+	}
+	if err != nil {
+		return UserPreferences{}, err
+	}
+	var prefs UserPreferences
+	if err := json.Unmarshal([]byte(resp.Node.Value), &prefs); err != nil {
+		glog.Errorf("Failed to unmarshal %s: %v", resp.Node.Value, err)
+		return UserPreferences{}, err
+	}
+	return prefs, nil
+}
+
+// StoreUserPreferences stores preferences of "user" into etcd.
+func StoreUserPreferences(client ETCDInterface, user string, prefs UserPreferences) error {
+	buf, err := json.Marshal(prefs)
+	if err != nil {
+		return err
+	}
+	_, err = client.Set(preferencesKey(user), string(buf), 0)
+	return err
+}
+
+func groupsKey(user string) string {
+	return path.Join("/goship/users", user, "groups")
+}
+
+// LoadUserGroups returns the groups of "user" given by the identity provider when the user logged in last time.
+func LoadUserGroups(client ETCDInterface, user string) ([]string, error) {
+	resp, err := client.Get(groupsKey(user), false, false)
+	if IsNotFound(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var groups []string
+	if err := json.Unmarshal([]byte(resp.Node.Value), &groups); err != nil {
+		return nil, err
+	}
+	return groups, nil
+}
+
+// StoreUserGroups stores the groups of "user".
+func StoreUserGroups(client ETCDInterface, user string, groups []string) error {
+	buf, err := json.Marshal(groups)
+	if err != nil {
+		return err
+	}
+	_, err = client.Set(groupsKey(user), string(buf), 0)
+	return err
+}
+
+// IsNotFound returns true if "err" means that the requested key does not exist in etcd.
+func IsNotFound(err error) bool {
+	return hasErrorCode(err, etcdErrorCodeKeyNotFound)
+}
+
+// IsExist returns true if "err" means that the key to create already exists.
+func IsExist(err error) bool {
+	return hasErrorCode(err, etcdErrorCodeNodeExist)
+}
+
+// IsCompareFailed returns true if "err" means that the previous value of a compare-and-swap or a compare-and-delete did not match.
+func IsCompareFailed(err error) bool {
+	return hasErrorCode(err, etcdErrorCodeTestFailed)
+}
+
+// IsNotFile returns true if "err" means that the key to delete is a directory, which needs a recursive deletion.
+func IsNotFile(err error) bool {
+	return hasErrorCode(err, etcdErrorCodeNotFile)
+}
+
+func hasErrorCode(err error, code int) bool {
+	switch e := err.(type) {
+	case etcd.EtcdError:
+		return e.ErrorCode == code
+	case *etcd.EtcdError:
+		return e.ErrorCode == code
+	}
+	return false
+}
@@ -0,0 +1,31 @@
+package config_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/gengo/goship/lib/config"
+)
+
+func TestUserPreferencesRoundTrip(t *testing.T) {
+	s := config.NewMemoryStore()
+	got, err := config.LoadUserPreferences(s, "alice")
+	if err != nil {
+		t.Fatalf("config.LoadUserPreferences(s, %q) failed with %v; want success", "alice", err)
+	}
+	if want := (config.UserPreferences{}); !reflect.DeepEqual(got, want) {
+		t.Errorf("config.LoadUserPreferences(s, %q) = %#v; want %#v", "alice", got, want)
+	}
+
+	prefs := config.UserPreferences{HomeColumns: []string{"environment", "deploy"}}
+	if err := config.StoreUserPreferences(s, "alice", prefs); err != nil {
+		t.Fatalf("config.StoreUserPreferences(s, %q, %#v) failed with %v; want success", "alice", prefs, err)
+	}
+	got, err = config.LoadUserPreferences(s, "alice")
+	if err != nil {
+		t.Fatalf("config.LoadUserPreferences(s, %q) failed with %v; want success", "alice", err)
+	}
+	if !reflect.DeepEqual(got, prefs) {
+		t.Errorf("config.LoadUserPreferences(s, %q) = %#v; want %#v", "alice", got, prefs)
+	}
+}
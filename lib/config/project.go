@@ -0,0 +1,130 @@
+package config
+
+import (
+	"fmt"
+	"path"
+	"strings"
+)
+
+// EditableStore is an etcd client which can also delete keys.
+type EditableStore interface {
+	ETCDInterface
+	Deleter
+}
+
+// ValidateProject checks "p" in the same way as Load does.
+// It returns "p" with the defaults filled.
+func ValidateProject(p Project) (Project, error) {
+	if err := validName(p.Name); err != nil {
+		return Project{}, fmt.Errorf("invalid project name: %v", err)
+	}
+	if len(p.Environments) == 0 {
+		return Project{}, fmt.Errorf("no environments in %s", p.Name)
+	}
+	seen := make(map[string]bool)
+	for _, e := range p.Environments {
+		if err := validName(e.Name); err != nil {
+			return Project{}, fmt.Errorf("invalid environment name in %s: %v", p.Name, err)
+		}
+		if strings.Contains(e.Name, "-") {
+			return Project{}, fmt.Errorf("environment name %q in %s must not contain '-'", e.Name, p.Name)
+		}
+		if seen[e.Name] {
+			return Project{}, fmt.Errorf("duplicate environment %q in %s", e.Name, p.Name)
+		}
+		seen[e.Name] = true
+	}
+	for _, e := range p.Environments {
+		for _, name := range e.Preceding {
+			if !seen[name] || name == e.Name {
+				return Project{}, fmt.Errorf("invalid environment %q in preceding of %s-%s", name, p.Name, e.Name)
+			}
+		}
+	}
+	ordered := make(map[string]bool)
+	for _, name := range p.EnvironmentOrder {
+		if !seen[name] {
+			return Project{}, fmt.Errorf("unknown environment %q in environment_order of %s", name, p.Name)
+		}
+		if ordered[name] {
+			return Project{}, fmt.Errorf("duplicate environment %q in environment_order of %s", name, p.Name)
+		}
+		ordered[name] = true
+	}
+	if len(p.Pipeline) == 1 {
+		return Project{}, fmt.Errorf("pipeline of %s must have two or more stages", p.Name)
+	}
+	staged := make(map[string]bool)
+	for _, name := range p.Pipeline {
+		if !seen[name] {
+			return Project{}, fmt.Errorf("unknown environment %q in pipeline of %s", name, p.Name)
+		}
+		if staged[name] {
+			return Project{}, fmt.Errorf("duplicate environment %q in pipeline of %s", name, p.Name)
+		}
+		staged[name] = true
+	}
+
+	s := NewMemoryStore()
+	if err := storeProject(s, p, "/"); err != nil {
+		return Project{}, err
+	}
+	resp, err := s.Get(path.Join("/projects", p.Name), false, true)
+	if err != nil {
+		return Project{}, err
+	}
+	return loadProject(resp.Node)
+}
+
+// validName returns an error if "name" cannot be a key in etcd.
+func validName(name string) error {
+	if name == "" {
+		return fmt.Errorf("empty name")
+	}
+	if strings.Contains(name, "/") || name == "." || name == ".." {
+		return fmt.Errorf("name %q must not contain '/'", name)
+	}
+	return nil
+}
+
+// StoreProject validates "p" and stores it into etcd, replacing the project of the same name if any.
+// Environments of the existing project which are not in "p" are removed.
+func StoreProject(client EditableStore, p Project) error {
+	if _, err := ValidateProject(p); err != nil {
+		return err
+	}
+	dir := path.Join("/goship/projects", p.Name, "environments")
+	resp, err := client.Get(dir, false, false)
+	if err != nil && !IsNotFound(err) {
+		return err
+	}
+	if err := storeProject(client, p, "/goship"); err != nil {
+		return err
+	}
+	if resp == nil {
+		return nil
+	}
+	keep := make(map[string]bool)
+	for _, e := range p.Environments {
+		keep[e.Name] = true
+	}
+	for _, n := range resp.Node.Nodes {
+		if keep[path.Base(n.Key)] {
+			continue
+		}
+		if _, err := client.Delete(n.Key, true); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// DeleteProject removes the configuration of the project "name" from etcd.
+// Locks, pins and other states of the project remain as orphaned keys.
+func DeleteProject(client Deleter, name string) error {
+	if err := validName(name); err != nil {
+		return err
+	}
+	_, err := client.Delete(path.Join("/goship/projects", name), true)
+	return err
+}
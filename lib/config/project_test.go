@@ -0,0 +1,168 @@
+package config_test
+
+import (
+	"testing"
+
+	"github.com/gengo/goship/lib/config"
+)
+
+func TestValidateProject(t *testing.T) {
+	for _, spec := range []struct {
+		proj  config.Project
+		valid bool
+	}{
+		{
+			proj: config.Project{
+				Name:         "proj",
+				Environments: []config.Environment{{Name: "staging", Deploy: "deploy-command"}},
+			},
+			valid: true,
+		},
+		{
+			proj: config.Project{Name: "proj"},
+		},
+		{
+			proj: config.Project{
+				Name:         "proj/x",
+				Environments: []config.Environment{{Name: "staging"}},
+			},
+		},
+		{
+			proj: config.Project{
+				Name:         "proj",
+				Environments: []config.Environment{{Name: "pre-production"}},
+			},
+		},
+		{
+			proj: config.Project{
+				Name:         "proj",
+				Environments: []config.Environment{{Name: "staging"}, {Name: "staging"}},
+			},
+		},
+		{
+			proj: config.Project{
+				Name:         "proj",
+				RepoType:     "svn",
+				Environments: []config.Environment{{Name: "staging"}},
+			},
+		},
+		{
+			proj: config.Project{
+				Name:         "proj",
+				Environments: []config.Environment{{Name: "staging", Parallelism: -1}},
+			},
+		},
+		{
+			proj: config.Project{
+				Name:             "proj",
+				Environments:     []config.Environment{{Name: "staging"}, {Name: "production"}},
+				EnvironmentOrder: []string{"staging", "production"},
+			},
+			valid: true,
+		},
+		{
+			proj: config.Project{
+				Name:             "proj",
+				Environments:     []config.Environment{{Name: "staging"}},
+				EnvironmentOrder: []string{"dev", "staging"},
+			},
+		},
+		{
+			proj: config.Project{
+				Name:             "proj",
+				Environments:     []config.Environment{{Name: "staging"}},
+				EnvironmentOrder: []string{"staging", "staging"},
+			},
+		},
+		{
+			proj: config.Project{
+				Name:         "proj",
+				Environments: []config.Environment{{Name: "staging"}, {Name: "production", Preceding: []string{"staging"}}},
+			},
+			valid: true,
+		},
+		{
+			proj: config.Project{
+				Name:         "proj",
+				Environments: []config.Environment{{Name: "production", Preceding: []string{"staging"}}},
+			},
+		},
+		{
+			proj: config.Project{
+				Name:         "proj",
+				Environments: []config.Environment{{Name: "dev"}, {Name: "staging"}, {Name: "production"}},
+				Pipeline:     []string{"dev", "staging", "production"},
+			},
+			valid: true,
+		},
+		{
+			proj: config.Project{
+				Name:         "proj",
+				Environments: []config.Environment{{Name: "staging"}, {Name: "production"}},
+				Pipeline:     []string{"dev", "staging", "production"},
+			},
+		},
+		{
+			proj: config.Project{
+				Name:         "proj",
+				Environments: []config.Environment{{Name: "staging"}, {Name: "production"}},
+				Pipeline:     []string{"staging", "production", "staging"},
+			},
+		},
+		{
+			proj: config.Project{
+				Name:         "proj",
+				Environments: []config.Environment{{Name: "staging"}},
+				Pipeline:     []string{"staging"},
+			},
+		},
+	} {
+		got, err := config.ValidateProject(spec.proj)
+		if (err == nil) != spec.valid {
+			t.Errorf("config.ValidateProject(%#v) failed with %v; want success = %v", spec.proj, err, spec.valid)
+			continue
+		}
+		if err == nil && (got.RepoType != config.RepoTypeGithub || got.Environments[0].Branch != "master") {
+			t.Errorf("config.ValidateProject(%#v) = %#v; want defaults filled", spec.proj, got)
+		}
+	}
+}
+
+func TestStoreProject(t *testing.T) {
+	s := config.NewMemoryStore()
+	proj := config.Project{
+		Name: "proj",
+		Environments: []config.Environment{
+			{Name: "production", Deploy: "deploy-command"},
+			{Name: "staging", Deploy: "deploy-command"},
+		},
+	}
+	if err := config.StoreProject(s, proj); err != nil {
+		t.Fatalf("config.StoreProject(s, %#v) failed with %v; want success", proj, err)
+	}
+	proj.Environments = proj.Environments[1:]
+	if err := config.StoreProject(s, proj); err != nil {
+		t.Fatalf("config.StoreProject(s, %#v) failed with %v; want success", proj, err)
+	}
+	if err := config.StoreProject(s, config.Project{Name: "invalid"}); err == nil {
+		t.Errorf("config.StoreProject(s, invalid) succeeded; want failure")
+	}
+	if err := config.Store(s, config.Config{}); err != nil {
+		t.Fatalf("config.Store(s, cfg) failed with %v; want success", err)
+	}
+
+	cfg, err := config.Load(s)
+	if err != nil {
+		t.Fatalf("config.Load(s) failed with %v; want success", err)
+	}
+	if len(cfg.Projects) != 1 || len(cfg.Projects[0].Environments) != 1 || cfg.Projects[0].Environments[0].Name != "staging" {
+		t.Errorf("config.Load(s).Projects = %#v; want proj with only staging", cfg.Projects)
+	}
+
+	if err := config.DeleteProject(s, "proj"); err != nil {
+		t.Fatalf("config.DeleteProject(s, %q) failed with %v; want success", "proj", err)
+	}
+	if _, err := s.Get("/goship/projects/proj", false, true); !config.IsNotFound(err) {
+		t.Errorf("s.Get(%q) failed with %v; want not found", "/goship/projects/proj", err)
+	}
+}
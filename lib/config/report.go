@@ -0,0 +1,93 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// SMTPConfig configures the mail server through which Goship sends emails.
+type SMTPConfig struct {
+	// Address is the host and the port of the server, e.g. "smtp.example.com:587".
+	Address string `json:"address" yaml:"address"`
+	// From is the sender address of the emails.
+	From string `json:"from" yaml:"from"`
+	// Username and Password optionally authenticate Goship with PLAIN authentication.
+	Username string `json:"username,omitempty" yaml:"username,omitempty"`
+	Password string `json:"password,omitempty" yaml:"password,omitempty"`
+}
+
+func (s SMTPConfig) validate() error {
+	if s.Address == "" {
+		return errors.New("address not specified")
+	}
+	if s.From == "" {
+		return errors.New("from not specified")
+	}
+	return nil
+}
+
+// WeeklyReport emails a summary of the deployments of the last week to a team every Monday.
+type WeeklyReport struct {
+	// Team names the report. It is also the name of the report in the API.
+	Team string `json:"team" yaml:"team"`
+	// Projects are the names of the projects summarized in the report.
+	Projects []string `json:"projects" yaml:"projects"`
+	// To are the addresses of the recipients.
+	To []string `json:"to" yaml:"to"`
+}
+
+func validateWeeklyReports(reports []WeeklyReport, smtp *SMTPConfig) error {
+	if len(reports) > 0 && smtp == nil {
+		return errors.New("smtp not configured")
+	}
+	teams := make(map[string]bool)
+	for _, r := range reports {
+		if r.Team == "" {
+			return errors.New("team not specified")
+		}
+		if teams[r.Team] {
+			return fmt.Errorf("duplicate team %q", r.Team)
+		}
+		teams[r.Team] = true
+		if len(r.Projects) == 0 {
+			return fmt.Errorf("no projects in the report of %s", r.Team)
+		}
+		if len(r.To) == 0 {
+			return fmt.Errorf("no recipients of the report of %s", r.Team)
+		}
+	}
+	return nil
+}
+
+// WeeklyReportFromTeam returns the weekly report of "team".
+func (c Config) WeeklyReportFromTeam(team string) (WeeklyReport, error) {
+	for _, r := range c.WeeklyReports {
+		if r.Team == team {
+			return r, nil
+		}
+	}
+	return WeeklyReport{}, fmt.Errorf("no weekly report of %s", team)
+}
+
+func reportSentKey(team string) string {
+	return fmt.Sprintf("/goship/reports/%s/sent", team)
+}
+
+// LoadReportSent returns the end of the last week whose report has been sent to "team", or the zero time if none has.
+func LoadReportSent(client ETCDInterface, team string) (time.Time, error) {
+	resp, err := client.Get(reportSentKey(team), false, false)
+	if IsNotFound(err) {
+		return time.Time{}, nil
+	}
+	if err != nil {
+		return time.Time{}, err
+	}
+	return time.Parse(time.RFC3339, resp.Node.Value)
+}
+
+// StoreReportSent records that the report of the week ending at "end" has been sent to "team".
+func StoreReportSent(client ETCDInterface, team string, end time.Time) error {
+	_, err := client.Set(reportSentKey(team), end.Format(time.RFC3339), 0)
+	return err
+}
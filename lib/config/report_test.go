@@ -0,0 +1,30 @@
+package config_test
+
+import (
+	"testing"
+
+	"github.com/gengo/goship/lib/config"
+)
+
+func TestLoadWeeklyReports(t *testing.T) {
+	smtp := &config.SMTPConfig{Address: "smtp.example.com:587", From: "goship@example.com"}
+	report := config.WeeklyReport{Team: "platform", Projects: []string{"example-project"}, To: []string{"platform@example.com"}}
+	for _, spec := range []struct {
+		smtp    *config.SMTPConfig
+		reports []config.WeeklyReport
+		valid   bool
+	}{
+		{smtp: smtp, reports: []config.WeeklyReport{report}, valid: true},
+		{smtp: smtp, valid: true},
+		{reports: []config.WeeklyReport{report}},
+		{smtp: &config.SMTPConfig{Address: "smtp.example.com:587"}},
+		{smtp: smtp, reports: []config.WeeklyReport{report, report}},
+		{smtp: smtp, reports: []config.WeeklyReport{{Team: "platform", Projects: []string{"example-project"}}}},
+	} {
+		cfg := exampleConfig()
+		cfg.SMTP, cfg.WeeklyReports = spec.smtp, spec.reports
+		if _, err := storeAndLoad(t, cfg); (err == nil) != spec.valid {
+			t.Errorf("config.Load(s) failed with %v; want success = %v; smtp = %#v, reports = %#v", err, spec.valid, spec.smtp, spec.reports)
+		}
+	}
+}
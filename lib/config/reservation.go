@@ -0,0 +1,162 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/gengo/goship/lib/clock"
+)
+
+var (
+	// ErrNoSuchReservation is returned when a reservation to cancel is not found.
+	ErrNoSuchReservation = errors.New("no such reservation")
+	// ErrReservationConflict is returned when a new reservation overlaps an existing one.
+	ErrReservationConflict = errors.New("overlaps an existing reservation")
+)
+
+// Reservation reserves an environment for a user during a time window.
+// Deployments by other users are rejected during the window.
+type Reservation struct {
+	// ID identifies the reservation in the environment.
+	ID string `json:"id"`
+	// User is the name of the user who reserved the environment.
+	User string `json:"user"`
+	// Reason describes what the window is for, e.g. a release.
+	Reason string    `json:"reason,omitempty"`
+	Start  time.Time `json:"start"`
+	End    time.Time `json:"end"`
+}
+
+// Covers returns true if "t" is in the window of the reservation.
+func (r Reservation) Covers(t time.Time) bool {
+	return !t.Before(r.Start) && t.Before(r.End)
+}
+
+// Window returns a human-readable time window of the reservation in the local time of the server.
+func (r Reservation) Window() string {
+	start, end := r.Start.Local(), r.End.Local()
+	if start.YearDay() == end.YearDay() && start.Year() == end.Year() {
+		return fmt.Sprintf("%s-%s", start.Format("Jan 2 15:04"), end.Format("15:04"))
+	}
+	return fmt.Sprintf("%s - %s", start.Format("Jan 2 15:04"), end.Format("Jan 2 15:04"))
+}
+
+func reservationKey(projectName, projectEnv string) string {
+	return fmt.Sprintf("/goship/reservations/%s/%s", projectName, projectEnv)
+}
+
+// LoadReservations returns the reservations of the environment which have not ended, in the order of their start.
+func LoadReservations(client ETCDInterface, projectName, projectEnv string) ([]Reservation, error) {
+	resp, err := client.Get(reservationKey(projectName, projectEnv), false, false)
+	if IsNotFound(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return decodeReservations(resp.Node.Value)
+}
+
+// decodeReservations returns the reservations in "value" which have not ended.
+func decodeReservations(value string) ([]Reservation, error) {
+	if value == "" {
+		return nil, nil
+	}
+	var all, reservations []Reservation
+	if err := json.Unmarshal([]byte(value), &all); err != nil {
+		return nil, err
+	}
+	now := clock.Now()
+	for _, r := range all {
+		if r.End.After(now) {
+			reservations = append(reservations, r)
+		}
+	}
+	return reservations, nil
+}
+
+// ActiveReservation returns the reservation of the environment which covers the current time,
+// or nil if the environment is not reserved now.
+func ActiveReservation(client ETCDInterface, projectName, projectEnv string) (*Reservation, error) {
+	reservations, err := LoadReservations(client, projectName, projectEnv)
+	if err != nil {
+		return nil, err
+	}
+	now := clock.Now()
+	for _, r := range reservations {
+		if r.Covers(now) {
+			return &r, nil
+		}
+	}
+	return nil, nil
+}
+
+// Reserve adds "r" to the reservations of the environment.
+// It returns "r" with a new ID assigned, or ErrReservationConflict if "r" overlaps another reservation.
+func Reserve(client ETCDInterface, projectName, projectEnv string, r Reservation) (Reservation, error) {
+	if projectName == "" || projectEnv == "" {
+		return Reservation{}, fmt.Errorf("Missing parameters")
+	}
+	if !r.End.After(r.Start) {
+		return Reservation{}, fmt.Errorf("reservation must end after its start")
+	}
+	err := updateReservations(client, projectName, projectEnv, func(reservations []Reservation) ([]Reservation, error) {
+		var last int
+		for _, other := range reservations {
+			if r.Start.Before(other.End) && other.Start.Before(r.End) {
+				return nil, ErrReservationConflict
+			}
+			if id, err := strconv.Atoi(other.ID); err == nil && id > last {
+				last = id
+			}
+		}
+		r.ID = strconv.Itoa(last + 1)
+		reservations = append(reservations, r)
+		for i := len(reservations) - 1; i > 0 && reservations[i].Start.Before(reservations[i-1].Start); i-- {
+			reservations[i], reservations[i-1] = reservations[i-1], reservations[i]
+		}
+		return reservations, nil
+	})
+	if err != nil {
+		return Reservation{}, err
+	}
+	return r, nil
+}
+
+// CancelReservation removes the reservation identified by "id" from the environment.
+// It returns the removed reservation.
+func CancelReservation(client ETCDInterface, projectName, projectEnv, id string) (Reservation, error) {
+	var canceled Reservation
+	err := updateReservations(client, projectName, projectEnv, func(reservations []Reservation) ([]Reservation, error) {
+		for i, r := range reservations {
+			if r.ID == id {
+				canceled = r
+				return append(reservations[:i], reservations[i+1:]...), nil
+			}
+		}
+		return nil, ErrNoSuchReservation
+	})
+	if err != nil {
+		return Reservation{}, err
+	}
+	return canceled, nil
+}
+
+// updateReservations replaces the reservations of the environment with what "change" returns for the ones which have not ended.
+// Concurrent reservations from the instances of Goship do not overlap.
+func updateReservations(client ETCDInterface, projectName, projectEnv string, change func([]Reservation) ([]Reservation, error)) error {
+	return update(client, reservationKey(projectName, projectEnv), func(value string) (string, error) {
+		reservations, err := decodeReservations(value)
+		if err != nil {
+			return "", err
+		}
+		if reservations, err = change(reservations); err != nil {
+			return "", err
+		}
+		buf, err := json.Marshal(reservations)
+		return string(buf), err
+	})
+}
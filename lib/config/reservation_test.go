@@ -0,0 +1,79 @@
+package config_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/gengo/goship/lib/clock"
+	"github.com/gengo/goship/lib/config"
+)
+
+func TestReservations(t *testing.T) {
+	s := config.NewMemoryStore()
+	now := time.Now().Truncate(time.Second)
+	first, err := config.Reserve(s, "proj", "env", config.Reservation{User: "alice", Start: now.Add(2 * time.Hour), End: now.Add(3 * time.Hour)})
+	if err != nil {
+		t.Fatalf("config.Reserve(s, %q, %q, r) failed with %v; want success", "proj", "env", err)
+	}
+	if _, err := config.Reserve(s, "proj", "env", config.Reservation{User: "bob", Start: now.Add(150 * time.Minute), End: now.Add(4 * time.Hour)}); err != config.ErrReservationConflict {
+		t.Errorf("config.Reserve(s, %q, %q, overlapping) failed with %v; want %v", "proj", "env", err, config.ErrReservationConflict)
+	}
+	second, err := config.Reserve(s, "proj", "env", config.Reservation{User: "bob", Start: now.Add(-time.Minute), End: now.Add(time.Hour)})
+	if err != nil {
+		t.Fatalf("config.Reserve(s, %q, %q, r) failed with %v; want success", "proj", "env", err)
+	}
+
+	reservations, err := config.LoadReservations(s, "proj", "env")
+	if err != nil {
+		t.Fatalf("config.LoadReservations(s, %q, %q) failed with %v; want success", "proj", "env", err)
+	}
+	if len(reservations) != 2 || reservations[0].ID != second.ID || reservations[1].ID != first.ID {
+		t.Errorf("config.LoadReservations(s, %q, %q) = %#v; want reservations %s and %s in order", "proj", "env", reservations, second.ID, first.ID)
+	}
+
+	active, err := config.ActiveReservation(s, "proj", "env")
+	if err != nil || active == nil || active.User != "bob" {
+		t.Errorf("config.ActiveReservation(s, %q, %q) = %#v, %v; want the reservation by bob", "proj", "env", active, err)
+	}
+
+	if _, err := config.CancelReservation(s, "proj", "env", second.ID); err != nil {
+		t.Errorf("config.CancelReservation(s, %q, %q, %q) failed with %v; want success", "proj", "env", second.ID, err)
+	}
+	if active, err := config.ActiveReservation(s, "proj", "env"); err != nil || active != nil {
+		t.Errorf("config.ActiveReservation(s, %q, %q) = %#v, %v; want nil, nil", "proj", "env", active, err)
+	}
+	if _, err := config.CancelReservation(s, "proj", "env", second.ID); err != config.ErrNoSuchReservation {
+		t.Errorf("config.CancelReservation(s, %q, %q, %q) failed with %v; want %v", "proj", "env", second.ID, err, config.ErrNoSuchReservation)
+	}
+}
+
+func TestReservationsOverTime(t *testing.T) {
+	now := time.Date(2015, time.November, 13, 12, 0, 0, 0, time.UTC)
+	clk := clock.NewFake(now)
+	defer func(c clock.Clock) { clock.Default = c }(clock.Default)
+	clock.Default = clk
+
+	s := config.NewMemoryStore()
+	if _, err := config.Reserve(s, "proj", "env", config.Reservation{User: "alice", Start: now.Add(time.Hour), End: now.Add(2 * time.Hour)}); err != nil {
+		t.Fatalf("config.Reserve(s, %q, %q, r) failed with %v; want success", "proj", "env", err)
+	}
+	for _, spec := range []struct {
+		advance time.Duration
+		active  bool
+		loaded  int
+	}{
+		{advance: 0, active: false, loaded: 1},
+		{advance: time.Hour, active: true, loaded: 1},
+		{advance: time.Hour, active: false, loaded: 0},
+	} {
+		clk.Advance(spec.advance)
+		active, err := config.ActiveReservation(s, "proj", "env")
+		if err != nil || (active != nil) != spec.active {
+			t.Errorf("config.ActiveReservation(s, %q, %q) at %v = %#v, %v; want active = %t", "proj", "env", clk.Now(), active, err, spec.active)
+		}
+		reservations, err := config.LoadReservations(s, "proj", "env")
+		if err != nil || len(reservations) != spec.loaded {
+			t.Errorf("config.LoadReservations(s, %q, %q) at %v = %#v, %v; want %d reservation(s)", "proj", "env", clk.Now(), reservations, err, spec.loaded)
+		}
+	}
+}
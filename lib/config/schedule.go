@@ -0,0 +1,149 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// ScheduleAction is a kind of actions which can be run periodically in an environment.
+type ScheduleAction string
+
+const (
+	// ScheduleRedeploy deploys the revision which is currently deployed again.
+	ScheduleRedeploy = ScheduleAction("redeploy")
+	// ScheduleRestart restarts the environment without deploying.
+	ScheduleRestart = ScheduleAction("restart")
+	// ScheduleDeploy deploys a specific revision once.
+	ScheduleDeploy = ScheduleAction("deploy")
+)
+
+func (a ScheduleAction) Valid() bool {
+	switch a {
+	case ScheduleRedeploy, ScheduleRestart, ScheduleDeploy:
+		return true
+	}
+	return false
+}
+
+// ErrNoSuchSchedule is returned when a schedule to remove is not found.
+var ErrNoSuchSchedule = errors.New("no such schedule")
+
+// Schedule is a recurring or one-off action in an environment.
+type Schedule struct {
+	// ID identifies the schedule in the environment.
+	ID string `json:"id"`
+	// Cron is a cron expression which describes when the action runs, e.g. "0 3 * * *".
+	// It is empty for one-off actions.
+	Cron   string         `json:"cron"`
+	Action ScheduleAction `json:"action"`
+	// User is the name of the user who added the schedule.
+	User string    `json:"user"`
+	Time time.Time `json:"time"`
+	// At is when the one-off action runs.
+	At time.Time `json:"at,omitempty"`
+	// Revision is the revision to deploy for ScheduleDeploy.
+	Revision string `json:"revision,omitempty"`
+}
+
+// OneOff returns true if the action runs only once at s.At.
+func (s Schedule) OneOff() bool {
+	return !s.At.IsZero()
+}
+
+func scheduleKey(projectName, projectEnv string) string {
+	return fmt.Sprintf("/goship/schedules/%s/%s", projectName, projectEnv)
+}
+
+// LoadSchedules returns the schedules of the environment.
+func LoadSchedules(client ETCDInterface, projectName, projectEnv string) ([]Schedule, error) {
+	resp, err := client.Get(scheduleKey(projectName, projectEnv), false, false)
+	if IsNotFound(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if resp.Node.Value == "" {
+		return nil, nil
+	}
+	var schedules []Schedule
+	if err := json.Unmarshal([]byte(resp.Node.Value), &schedules); err != nil {
+		return nil, err
+	}
+	return schedules, nil
+}
+
+// AddSchedule adds "s" to the schedules of the environment.
+// It returns "s" with a new ID assigned.
+func AddSchedule(client ETCDInterface, projectName, projectEnv string, s Schedule) (Schedule, error) {
+	if projectName == "" || projectEnv == "" {
+		return Schedule{}, fmt.Errorf("Missing parameters")
+	}
+	if !s.Action.Valid() {
+		return Schedule{}, fmt.Errorf("invalid schedule action %q", s.Action)
+	}
+	if s.Action == ScheduleDeploy && (!s.OneOff() || s.Revision == "") {
+		return Schedule{}, fmt.Errorf("scheduled deploys need a time and a revision")
+	}
+	schedules, err := LoadSchedules(client, projectName, projectEnv)
+	if err != nil {
+		return Schedule{}, err
+	}
+	var last int
+	for _, s := range schedules {
+		if id, err := strconv.Atoi(s.ID); err == nil && id > last {
+			last = id
+		}
+	}
+	s.ID = strconv.Itoa(last + 1)
+	if err := storeSchedules(client, projectName, projectEnv, append(schedules, s)); err != nil {
+		return Schedule{}, err
+	}
+	return s, nil
+}
+
+// RemoveSchedule removes the schedule identified by "id" from the environment.
+func RemoveSchedule(client ETCDInterface, projectName, projectEnv, id string) error {
+	schedules, err := LoadSchedules(client, projectName, projectEnv)
+	if err != nil {
+		return err
+	}
+	for i, s := range schedules {
+		if s.ID == id {
+			return storeSchedules(client, projectName, projectEnv, append(schedules[:i], schedules[i+1:]...))
+		}
+	}
+	return ErrNoSuchSchedule
+}
+
+// ReassignSchedules makes "to" the user of the schedules of the environment which "from" added.
+// It returns the number of reassigned schedules.
+func ReassignSchedules(client ETCDInterface, projectName, projectEnv, from, to string) (int, error) {
+	schedules, err := LoadSchedules(client, projectName, projectEnv)
+	if err != nil {
+		return 0, err
+	}
+	var n int
+	for i := range schedules {
+		if schedules[i].User == from {
+			schedules[i].User = to
+			n++
+		}
+	}
+	if n == 0 {
+		return 0, nil
+	}
+	return n, storeSchedules(client, projectName, projectEnv, schedules)
+}
+
+func storeSchedules(client ETCDInterface, projectName, projectEnv string, schedules []Schedule) error {
+	buf, err := json.Marshal(schedules)
+	if err != nil {
+		return err
+	}
+	_, err = client.Set(scheduleKey(projectName, projectEnv), string(buf), 0)
+	return err
+}
@@ -0,0 +1,59 @@
+package config_test
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/gengo/goship/lib/config"
+)
+
+func TestSchedules(t *testing.T) {
+	s := config.NewMemoryStore()
+	if got, err := config.LoadSchedules(s, "proj", "env"); err != nil || got != nil {
+		t.Errorf("config.LoadSchedules(s, %q, %q) = %#v, %v; want nil, nil", "proj", "env", got, err)
+	}
+
+	now := time.Date(2015, time.November, 10, 23, 0, 0, 0, time.UTC)
+	var added []config.Schedule
+	for _, sc := range []config.Schedule{
+		{Cron: "0 3 * * *", Action: config.ScheduleRedeploy, User: "alice", Time: now},
+		{Cron: "30 4 * * 1-5", Action: config.ScheduleRestart, User: "bob", Time: now},
+	} {
+		got, err := config.AddSchedule(s, "proj", "env", sc)
+		if err != nil {
+			t.Fatalf("config.AddSchedule(s, %q, %q, %#v) failed with %v; want success", "proj", "env", sc, err)
+		}
+		added = append(added, got)
+	}
+	if got, want := []string{added[0].ID, added[1].ID}, []string{"1", "2"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("IDs of added schedules = %q; want %q", got, want)
+	}
+
+	got, err := config.LoadSchedules(s, "proj", "env")
+	if err != nil {
+		t.Fatalf("config.LoadSchedules(s, %q, %q) failed with %v; want success", "proj", "env", err)
+	}
+	if !reflect.DeepEqual(got, added) {
+		t.Errorf("config.LoadSchedules(s, %q, %q) = %#v; want %#v", "proj", "env", got, added)
+	}
+
+	if err := config.RemoveSchedule(s, "proj", "env", "1"); err != nil {
+		t.Fatalf("config.RemoveSchedule(s, %q, %q, %q) failed with %v; want success", "proj", "env", "1", err)
+	}
+	if err := config.RemoveSchedule(s, "proj", "env", "1"); err != config.ErrNoSuchSchedule {
+		t.Errorf("config.RemoveSchedule(s, %q, %q, %q) = %v; want %v", "proj", "env", "1", err, config.ErrNoSuchSchedule)
+	}
+	got, err = config.LoadSchedules(s, "proj", "env")
+	if err != nil {
+		t.Fatalf("config.LoadSchedules(s, %q, %q) failed with %v; want success", "proj", "env", err)
+	}
+	if want := added[1:]; !reflect.DeepEqual(got, want) {
+		t.Errorf("config.LoadSchedules(s, %q, %q) = %#v; want %#v", "proj", "env", got, want)
+	}
+
+	sc := config.Schedule{Cron: "0 3 * * *", Action: "reboot"}
+	if _, err := config.AddSchedule(s, "proj", "env", sc); err == nil {
+		t.Errorf("config.AddSchedule(s, %q, %q, %#v) succeeded; want failure", "proj", "env", sc)
+	}
+}
@@ -0,0 +1,152 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"path"
+	"time"
+)
+
+// SCIMConfig configures provisioning of users and groups by the identity provider with SCIM 2.0.
+type SCIMConfig struct {
+	// Token authenticates the identity provider as a bearer token.
+	Token string `json:"token" yaml:"token"`
+	// ReassignTo is the user who the schedules of deprovisioned users are reassigned to.
+	ReassignTo string `json:"reassign_to" yaml:"reassign_to"`
+}
+
+func (s SCIMConfig) validate() error {
+	if s.Token == "" {
+		return fmt.Errorf("token not specified")
+	}
+	if s.ReassignTo == "" {
+		return fmt.Errorf("reassign_to not specified")
+	}
+	return nil
+}
+
+// SCIMUser is a user provisioned by the identity provider.
+// Whether the user is active is recorded as Deactivation.
+type SCIMUser struct {
+	// UserName is the name of the user in Goship, which is also the ID of the user in SCIM.
+	UserName    string    `json:"user_name"`
+	ExternalID  string    `json:"external_id,omitempty"`
+	DisplayName string    `json:"display_name,omitempty"`
+	Created     time.Time `json:"created"`
+	Modified    time.Time `json:"modified"`
+}
+
+// SCIMGroup is a group provisioned by the identity provider.
+// DisplayName is the name of the group in access control.
+type SCIMGroup struct {
+	ID          string `json:"id"`
+	ExternalID  string `json:"external_id,omitempty"`
+	DisplayName string `json:"display_name"`
+	// Members are the names of the users in the group.
+	Members  []string  `json:"members,omitempty"`
+	Created  time.Time `json:"created"`
+	Modified time.Time `json:"modified"`
+}
+
+const (
+	scimUsersDir  = "/goship/scim/users"
+	scimGroupsDir = "/goship/scim/groups"
+)
+
+// LoadSCIMUsers returns all the users provisioned by the identity provider, sorted by their names.
+func LoadSCIMUsers(client ETCDInterface) ([]SCIMUser, error) {
+	nodes, err := getDir(client, scimUsersDir)
+	if err != nil {
+		return nil, err
+	}
+	users := make([]SCIMUser, 0, len(nodes))
+	for _, n := range nodes {
+		var u SCIMUser
+		if err := json.Unmarshal([]byte(n.Value), &u); err != nil {
+			return nil, fmt.Errorf("invalid scim user %s: %v", n.Key, err)
+		}
+		users = append(users, u)
+	}
+	return users, nil
+}
+
+// LoadSCIMUser returns the provisioned user named "name", or nil if not provisioned.
+func LoadSCIMUser(client ETCDInterface, name string) (*SCIMUser, error) {
+	resp, err := client.Get(path.Join(scimUsersDir, name), false, false)
+	if IsNotFound(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var u SCIMUser
+	if err := json.Unmarshal([]byte(resp.Node.Value), &u); err != nil {
+		return nil, err
+	}
+	return &u, nil
+}
+
+// StoreSCIMUser stores "u" as a provisioned user.
+func StoreSCIMUser(client ETCDInterface, u SCIMUser) error {
+	buf, err := json.Marshal(u)
+	if err != nil {
+		return err
+	}
+	_, err = client.Set(path.Join(scimUsersDir, u.UserName), string(buf), 0)
+	return err
+}
+
+// DeleteSCIMUser deletes the provisioned user named "name".
+func DeleteSCIMUser(client Deleter, name string) error {
+	_, err := client.Delete(path.Join(scimUsersDir, name), false)
+	return err
+}
+
+// LoadSCIMGroups returns all the groups provisioned by the identity provider, sorted by their IDs.
+func LoadSCIMGroups(client ETCDInterface) ([]SCIMGroup, error) {
+	nodes, err := getDir(client, scimGroupsDir)
+	if err != nil {
+		return nil, err
+	}
+	groups := make([]SCIMGroup, 0, len(nodes))
+	for _, n := range nodes {
+		var g SCIMGroup
+		if err := json.Unmarshal([]byte(n.Value), &g); err != nil {
+			return nil, fmt.Errorf("invalid scim group %s: %v", n.Key, err)
+		}
+		groups = append(groups, g)
+	}
+	return groups, nil
+}
+
+// LoadSCIMGroup returns the provisioned group whose ID is "id", or nil if not provisioned.
+func LoadSCIMGroup(client ETCDInterface, id string) (*SCIMGroup, error) {
+	resp, err := client.Get(path.Join(scimGroupsDir, id), false, false)
+	if IsNotFound(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var g SCIMGroup
+	if err := json.Unmarshal([]byte(resp.Node.Value), &g); err != nil {
+		return nil, err
+	}
+	return &g, nil
+}
+
+// StoreSCIMGroup stores "g" as a provisioned group.
+func StoreSCIMGroup(client ETCDInterface, g SCIMGroup) error {
+	buf, err := json.Marshal(g)
+	if err != nil {
+		return err
+	}
+	_, err = client.Set(path.Join(scimGroupsDir, g.ID), string(buf), 0)
+	return err
+}
+
+// DeleteSCIMGroup deletes the provisioned group whose ID is "id".
+func DeleteSCIMGroup(client Deleter, id string) error {
+	_, err := client.Delete(path.Join(scimGroupsDir, id), false)
+	return err
+}
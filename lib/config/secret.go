@@ -0,0 +1,66 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+)
+
+// SecretProvider is a store of secrets.
+type SecretProvider string
+
+const (
+	// SecretVault reads secrets from the KV secrets engine of HashiCorp Vault.
+	SecretVault = SecretProvider("vault")
+	// SecretAWS reads secrets from AWS Secrets Manager.
+	SecretAWS = SecretProvider("aws_secrets_manager")
+	// SecretEnvFile reads secrets from a file of KEY=VALUE lines on the Goship host.
+	SecretEnvFile = SecretProvider("env_file")
+)
+
+// envNamePattern matches valid names of environment variables.
+var envNamePattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// Secret is a value fetched from a secret store at deploy time and given to the deploy command as an environment variable,
+// so that it is not written in the command in etcd.
+type Secret struct {
+	// Env is the name of the environment variable, e.g. "API_TOKEN".
+	Env      string         `json:"env" yaml:"env"`
+	Provider SecretProvider `json:"provider" yaml:"provider"`
+	// Path locates the secret: the path of the secret in Vault, e.g. "secret/data/my-project",
+	// the name or the ARN of the secret in AWS Secrets Manager, or the path of the env file.
+	Path string `json:"path" yaml:"path"`
+	// Key is the field in the secret: of the data in Vault, of the JSON secret string in AWS Secrets Manager, or the variable in the env file.
+	// The whole secret string is used in AWS Secrets Manager if empty, and Env is used in Vault and env files if empty.
+	Key string `json:"key,omitempty" yaml:"key,omitempty"`
+	// Region is the AWS region of the secret in AWS Secrets Manager.
+	Region string `json:"region,omitempty" yaml:"region,omitempty"`
+}
+
+func validateSecrets(secrets []Secret) error {
+	seen := make(map[string]bool)
+	for _, s := range secrets {
+		if !envNamePattern.MatchString(s.Env) {
+			return fmt.Errorf("invalid env %q of a secret", s.Env)
+		}
+		if seen[s.Env] {
+			return fmt.Errorf("duplicate secret %s", s.Env)
+		}
+		seen[s.Env] = true
+		if s.Path == "" {
+			return fmt.Errorf("path of %s not specified", s.Env)
+		}
+		switch s.Provider {
+		case SecretVault, SecretEnvFile:
+		case SecretAWS:
+			if s.Region == "" {
+				return fmt.Errorf("region of %s not specified", s.Env)
+			}
+		case "":
+			return errors.New("provider of " + s.Env + " not specified")
+		default:
+			return fmt.Errorf("unknown provider %q of %s", s.Provider, s.Env)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,40 @@
+package config_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/gengo/goship/lib/config"
+)
+
+func TestLoadSecrets(t *testing.T) {
+	vault := config.Secret{Env: "API_TOKEN", Provider: config.SecretVault, Path: "secret/data/example-project", Key: "api_token"}
+	for _, spec := range []struct {
+		secrets []config.Secret
+		valid   bool
+	}{
+		{secrets: []config.Secret{vault}, valid: true},
+		{
+			secrets: []config.Secret{
+				vault,
+				{Env: "DB_PASSWORD", Provider: config.SecretAWS, Path: "example-project/db", Key: "password", Region: "us-east-1"},
+				{Env: "SENTRY_DSN", Provider: config.SecretEnvFile, Path: "/etc/goship/example-project.env"},
+			},
+			valid: true,
+		},
+		{secrets: []config.Secret{vault, vault}},
+		{secrets: []config.Secret{{Env: "API-TOKEN", Provider: config.SecretVault, Path: "secret/data/example-project"}}},
+		{secrets: []config.Secret{{Env: "API_TOKEN", Provider: config.SecretVault}}},
+		{secrets: []config.Secret{{Env: "DB_PASSWORD", Provider: config.SecretAWS, Path: "example-project/db"}}},
+		{secrets: []config.Secret{{Env: "API_TOKEN", Provider: "keychain", Path: "example-project"}}},
+	} {
+		env, ok := loadEnvironment(t, config.Environment{Name: "production", Deploy: "deploy-command", Secrets: spec.secrets})
+		if ok != spec.valid {
+			t.Errorf("loaded the project with secrets %#v = %t; want %t", spec.secrets, ok, spec.valid)
+			continue
+		}
+		if spec.valid && !reflect.DeepEqual(env.Secrets, spec.secrets) {
+			t.Errorf("env.Secrets = %#v; want %#v", env.Secrets, spec.secrets)
+		}
+	}
+}
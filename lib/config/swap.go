@@ -0,0 +1,66 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/coreos/go-etcd/etcd"
+)
+
+// maxUpdateAttempts is how many times update reads and swaps a key which other clients keep changing.
+const maxUpdateAttempts = 10
+
+var errSwapUnsupported = errors.New("the backend cannot compare and swap keys")
+
+// Swapper is implemented by etcd clients which can change keys atomically.
+type Swapper interface {
+	Create(key, value string, ttl uint64) (*etcd.Response, error)
+	CompareAndSwap(key, value string, ttl uint64, prevValue string, prevIndex uint64) (*etcd.Response, error)
+}
+
+// update replaces the value at "key" in "client" with what "change" returns for the current value, which is empty if the key does not exist.
+// The value is swapped only if nobody has changed it since it was read, so that concurrent updates from the instances of Goship are not lost.
+// "change" is called again with the new value on conflicts, so it must not have side effects.
+// Errors from "change" are returned as they are without changing the key, and unchanged values are not written.
+func update(client ETCDInterface, key string, change func(value string) (string, error)) error {
+	s, ok := client.(Swapper)
+	if !ok {
+		return errSwapUnsupported
+	}
+	for i := 0; i < maxUpdateAttempts; i++ {
+		var (
+			prev   string
+			index  uint64
+			exists bool
+		)
+		resp, err := client.Get(key, false, false)
+		switch {
+		case IsNotFound(err):
+		case err != nil:
+			return err
+		default:
+			prev, index, exists = resp.Node.Value, resp.Node.ModifiedIndex, true
+		}
+		value, err := change(prev)
+		if err != nil {
+			return err
+		}
+		if value == prev {
+			return nil
+		}
+		if exists {
+			_, err = s.CompareAndSwap(key, value, 0, prev, index)
+		} else {
+			_, err = s.Create(key, value, 0)
+		}
+		if !isConflict(err) {
+			return err
+		}
+	}
+	return fmt.Errorf("gave up updating %s which kept changing", key)
+}
+
+// isConflict returns true if "err" means that the key was changed by another client during update.
+func isConflict(err error) bool {
+	return IsCompareFailed(err) || IsExist(err) || IsNotFound(err)
+}
@@ -1,10 +1,12 @@
 package config
 
 import (
+	"bytes"
 	"fmt"
 	"os"
 	"regexp"
 	"strconv"
+	"text/template"
 	"time"
 
 	"github.com/coreos/go-etcd/etcd"
@@ -24,6 +26,67 @@ type Config struct {
 	DeployUser string                `json:"deploy_user" yaml:"deploy_user"`
 	Notify     string                `json:"notify" yaml:"notify"`
 	Pivotal    *PivotalConfiguration `json:"pivotal,omitempty" yaml:"pivotal,omitempty"`
+	// HomeColumns is the list of columns in the home page in order.
+	// Each item is either a name of a standard column or a name of a plugin.
+	// All the standard columns and plugins are shown if empty.
+	HomeColumns []string `json:"home_columns,omitempty" yaml:"home_columns,omitempty"`
+	// Freezes are weekly windows during which only emergency deployments are allowed.
+	Freezes []Freeze `json:"freezes,omitempty" yaml:"freezes,omitempty"`
+	// Slack configures interactive messages from Slack.
+	Slack *SlackConfig `json:"slack,omitempty" yaml:"slack,omitempty"`
+	// Federation configures the aggregated view of other Goship instances.
+	Federation *FederationConfig `json:"federation,omitempty" yaml:"federation,omitempty"`
+	// SCIM configures provisioning of users and groups by the identity provider.
+	SCIM *SCIMConfig `json:"scim,omitempty" yaml:"scim,omitempty"`
+	// SMTP configures the mail server through which emails are sent.
+	SMTP *SMTPConfig `json:"smtp,omitempty" yaml:"smtp,omitempty"`
+	// WeeklyReports email summaries of the deployments of the last week to teams.
+	WeeklyReports []WeeklyReport `json:"weekly_reports,omitempty" yaml:"weekly_reports,omitempty"`
+	// CITokens authenticate CI systems which check whether revisions can be deployed.
+	CITokens []CIToken `json:"ci_tokens,omitempty" yaml:"ci_tokens,omitempty"`
+	// ClientCerts map client certificates, which are verified with -tls-client-ca, to the users of machine clients of the APIs.
+	ClientCerts []ClientCert `json:"client_certs,omitempty" yaml:"client_certs,omitempty"`
+	// Admins are the names of the users who can edit projects in Goship.
+	Admins []string `json:"admins,omitempty" yaml:"admins,omitempty"`
+}
+
+// IsAdmin returns true if "user" is listed in the admins.
+func (c Config) IsAdmin(user string) bool {
+	return contains(c.Admins, user)
+}
+
+// EnvironmentVisible returns true if "user" can see "env".
+// Hidden environments are visible only to admins.
+func (c Config) EnvironmentVisible(env Environment, user string) bool {
+	return !env.Hidden || c.IsAdmin(user)
+}
+
+// VisibleProjects returns "projs" without the environments which "user" cannot see.
+// Projects whose environments are all hidden from "user" are also removed.
+func (c Config) VisibleProjects(projs []Project, user string) []Project {
+	var visible []Project
+	for _, p := range projs {
+		var envs []Environment
+		for _, e := range p.Environments {
+			if c.EnvironmentVisible(e, user) {
+				envs = append(envs, e)
+			}
+		}
+		if len(envs) == 0 && len(p.Environments) > 0 {
+			continue
+		}
+		p.Environments = envs
+		visible = append(visible, p)
+	}
+	return visible
+}
+
+// SlackConfig configures the Slack app which sends interactions to Goship.
+type SlackConfig struct {
+	// SigningSecret verifies that requests come from Slack.
+	SigningSecret string `json:"signing_secret" yaml:"signing_secret"`
+	// Users maps Slack user IDs to the names of Goship users.
+	Users map[string]string `json:"users,omitempty" yaml:"users,omitempty"`
 }
 
 // Project stores information about a GitHub project, such as its GitHub URL and repo name, and a list of extra columns (PluginColumns)
@@ -36,9 +99,149 @@ type Project struct {
 	TravisToken  string         `json:"travis_token" yaml:"travis_token"`
 	K8sResource  string         `json:"k8s_resource" yaml:"k8s_resource"`
 	K8sSelector  string         `json:"k8s_selector" yaml:"k8s_selector"`
+	// SCM is the source code management service which hosts the source repository of the project.
+	SCM SCMType `json:"scm,omitempty" yaml:"scm,omitempty"`
 	// Source is an additional revision control system.
 	// It is effective only if RepoType does not serve source codes.
 	Source *Repo `json:"source,omitempty" yaml:"source,omitempty"`
+	// Notifiers are destinations of outbound notifications on deployment events of the project.
+	Notifiers []Notifier `json:"notifiers,omitempty" yaml:"notifiers,omitempty"`
+	// DeployTimeout is the maximum duration of deployments of the project, e.g. "30m".
+	// Deployments are not limited if empty.
+	DeployTimeout string `json:"deploy_timeout,omitempty" yaml:"deploy_timeout,omitempty"`
+	// HealthCheck optionally checks the hosts of the environment after each deployment.
+	// The deployment fails if any host is unhealthy.
+	HealthCheck *HealthCheck `json:"health_check,omitempty" yaml:"health_check,omitempty"`
+	// AutoRollback deploys the previous revision again when HealthCheck fails.
+	AutoRollback bool `json:"auto_rollback,omitempty" yaml:"auto_rollback,omitempty"`
+	// NotifyDurationAnomalies notifies deployments which take significantly longer or shorter than usual in the environment.
+	// They are flagged in the deploy log regardless.
+	NotifyDurationAnomalies bool `json:"notify_duration_anomalies,omitempty" yaml:"notify_duration_anomalies,omitempty"`
+	// EnvironmentOrder is the display order of environments by name, e.g. ["dev", "staging", "production"].
+	// Environments not listed follow the listed ones in the order of their names.
+	EnvironmentOrder []string `json:"environment_order,omitempty" yaml:"environment_order,omitempty"`
+	// Pipeline is the order of stages by environment name which revisions are promoted through, e.g. ["dev", "staging", "production"].
+	// Promoting a stage deploys the revision running in the previous stage, only if it has passed through all the earlier stages.
+	Pipeline []string `json:"pipeline,omitempty" yaml:"pipeline,omitempty"`
+	// SSH optionally configures connections to the hosts of the project.
+	SSH *SSH `json:"ssh,omitempty" yaml:"ssh,omitempty"`
+	// FailureIssue optionally opens a GitHub issue in the source repository when an environment fails deployments repeatedly.
+	FailureIssue *FailureIssue `json:"failure_issue,omitempty" yaml:"failure_issue,omitempty"`
+	// GithubDeployments reports deployments of the project and their results to the GitHub Deployments API of the source repository,
+	// so that environments and their statuses show up in pull requests and the repository.
+	GithubDeployments bool `json:"github_deployments,omitempty" yaml:"github_deployments,omitempty"`
+	// RequiredStatuses are the contexts of CI statuses, e.g. "ci/travis", which must succeed for the source revision before deployments.
+	RequiredStatuses []string `json:"required_statuses,omitempty" yaml:"required_statuses,omitempty"`
+	// Access optionally limits the users who can read and deploy the project by their groups.
+	// It is effective only if users are authenticated with OpenID Connect or LDAP.
+	Access *Access `json:"access,omitempty" yaml:"access,omitempty"`
+	// Roles optionally assign roles in the environments of the project to users and groups.
+	Roles []RoleBinding `json:"roles,omitempty" yaml:"roles,omitempty"`
+}
+
+// A Role is a set of permissions in an environment.
+// Each role includes the permissions of the lower roles.
+type Role string
+
+const (
+	// RoleViewer can see the environment.
+	RoleViewer = Role("viewer")
+	// RoleDeployer can deploy to, lock, unlock and comment on the environment.
+	RoleDeployer = Role("deployer")
+	// RoleAdmin can also remove locks by other users.
+	RoleAdmin = Role("admin")
+)
+
+var roleRanks = map[Role]int{RoleViewer: 1, RoleDeployer: 2, RoleAdmin: 3}
+
+// Valid returns true if "r" is one of the known roles.
+func (r Role) Valid() bool {
+	return roleRanks[r] > 0
+}
+
+// Includes returns true if "r" has all the permissions of "other".
+func (r Role) Includes(other Role) bool {
+	return roleRanks[r] >= roleRanks[other]
+}
+
+// RoleBinding assigns a role to users and groups.
+type RoleBinding struct {
+	Role Role `json:"role" yaml:"role"`
+	// Users are the names of the users. "*" means all the users.
+	Users []string `json:"users,omitempty" yaml:"users,omitempty"`
+	// Groups are the groups of users given by the identity provider, e.g. OpenID Connect or LDAP.
+	Groups []string `json:"groups,omitempty" yaml:"groups,omitempty"`
+}
+
+// matches returns true if the binding applies to "user" in "groups".
+func (b RoleBinding) matches(user string, groups []string) bool {
+	if contains(b.Users, "*") || contains(b.Users, user) {
+		return true
+	}
+	for _, g := range groups {
+		if contains(b.Groups, g) {
+			return true
+		}
+	}
+	return false
+}
+
+// RoleOf returns the highest role of "user" in "groups" in "env" of the project.
+// The role bindings of the environment are used if any, and those of the project otherwise.
+// It returns false if neither of them has role bindings, and an empty role if no binding applies to the user.
+func (p Project) RoleOf(env Environment, user string, groups []string) (Role, bool) {
+	bindings := env.Roles
+	if len(bindings) == 0 {
+		bindings = p.Roles
+	}
+	if len(bindings) == 0 {
+		return "", false
+	}
+	var role Role
+	for _, b := range bindings {
+		if b.matches(user, groups) && !role.Includes(b.Role) {
+			role = b.Role
+		}
+	}
+	return role, true
+}
+
+// validateRoles returns an error if any of "bindings" has an unknown role.
+func validateRoles(bindings []RoleBinding) error {
+	for _, b := range bindings {
+		if !b.Role.Valid() {
+			return fmt.Errorf("invalid role %q", b.Role)
+		}
+	}
+	return nil
+}
+
+// Access lists the groups of users who can access a project.
+type Access struct {
+	// Readers are the groups which can read the project.
+	Readers []string `json:"readers,omitempty" yaml:"readers,omitempty"`
+	// Deployers are the groups which can read and deploy the project.
+	Deployers []string `json:"deployers,omitempty" yaml:"deployers,omitempty"`
+}
+
+// Timeout returns the maximum duration of deployments of the project, or zero if not limited.
+func (p Project) Timeout() time.Duration {
+	d, err := time.ParseDuration(p.DeployTimeout)
+	if err != nil {
+		return 0
+	}
+	return d
+}
+
+// EarlierStages returns the stages before "env" in the pipeline of "p" in order.
+// It returns nil if "env" is not a stage or is the first stage.
+func (p Project) EarlierStages(env string) []string {
+	for i, name := range p.Pipeline {
+		if name == env && i > 0 {
+			return p.Pipeline[:i:i]
+		}
+	}
+	return nil
 }
 
 func (p Project) SourceRepo() Repo {
@@ -91,6 +294,387 @@ type Environment struct {
 	Comment      string   `json:"comment" yaml:"comment"`
 	IsLocked     bool     `json:"is_locked,omitempty" yaml:"is_locked,omitempty"`
 	K8sNamespace string   `json:"k8s_namespace" yaml:"k8s_namespace"`
+	// QueueDeploys makes a deployment requested while another one is in progress wait for its turn.
+	// Otherwise the request is rejected.
+	QueueDeploys bool `json:"queue_deploys,omitempty" yaml:"queue_deploys,omitempty"`
+	// K8sDeployment is the name of a Kubernetes deployment in K8sNamespace which is the target of deployments.
+	// Deploy is not used if it is not empty.
+	K8sDeployment string `json:"k8s_deployment,omitempty" yaml:"k8s_deployment,omitempty"`
+	// K8sContainer is the name of the container in K8sDeployment whose image is updated.
+	// All the containers are updated if empty.
+	K8sContainer string `json:"k8s_container,omitempty" yaml:"k8s_container,omitempty"`
+	// K8sImage is a template of the image to deploy into K8sDeployment, e.g. "gcr.io/my-project/app:{{.Revision}}".
+	K8sImage string `json:"k8s_image,omitempty" yaml:"k8s_image,omitempty"`
+	// Restart is a command which restarts the environment without deploying.
+	// Kubernetes deployments are restarted with kubectl if empty.
+	Restart string `json:"restart,omitempty" yaml:"restart,omitempty"`
+	// PerHost makes Deploy run once for each host with GOSHIP_HOST environment variable set to the host.
+	// Otherwise Deploy runs only once for the whole environment.
+	PerHost bool `json:"per_host,omitempty" yaml:"per_host,omitempty"`
+	// Parallelism is the maximum number of hosts deployed concurrently.
+	// Hosts are deployed one by one if zero.
+	Parallelism int `json:"parallelism,omitempty" yaml:"parallelism,omitempty"`
+	// FailurePolicy decides how a failure on a host affects the other hosts.
+	// Empty means FailFast.
+	FailurePolicy FailurePolicy `json:"failure_policy,omitempty" yaml:"failure_policy,omitempty"`
+	// Rollout optionally deploys to canary hosts before the others.
+	Rollout *Rollout `json:"rollout,omitempty" yaml:"rollout,omitempty"`
+	// RequireApproval makes deployments wait until another user approves them.
+	RequireApproval bool `json:"require_approval,omitempty" yaml:"require_approval,omitempty"`
+	// Approvers are the names of the users who can approve deployments.
+	// Anyone who can deploy the project can approve if empty.
+	Approvers []string `json:"approvers,omitempty" yaml:"approvers,omitempty"`
+	// Hidden hides the environment from users other than admins.
+	Hidden bool `json:"hidden,omitempty" yaml:"hidden,omitempty"`
+	// SSH optionally configures connections to the hosts of the environment.
+	// Its empty fields are inherited from the project.
+	SSH *SSH `json:"ssh,omitempty" yaml:"ssh,omitempty"`
+	// Roles optionally assign roles in the environment to users and groups instead of Project.Roles.
+	Roles []RoleBinding `json:"roles,omitempty" yaml:"roles,omitempty"`
+	// HostGroups optionally split the hosts into named groups, e.g. "workers" and "web", which are deployed one after another in order.
+	// Their hosts are also part of Hosts.
+	HostGroups []HostGroup `json:"host_groups,omitempty" yaml:"host_groups,omitempty"`
+	// ProtectedBranches optionally restrict deployments to source revisions which are reachable from any of the branches.
+	ProtectedBranches []string `json:"protected_branches,omitempty" yaml:"protected_branches,omitempty"`
+	// Preceding are the environments which revisions are expected to be deployed to successfully before this one, e.g. staging for production.
+	// Deployments of revisions which skipped any of them are recorded and notified with a warning, but not blocked.
+	Preceding []string `json:"preceding,omitempty" yaml:"preceding,omitempty"`
+	// LockGroup optionally names a group of environments, in any projects, which are locked and unlocked together, e.g. "backend-production".
+	LockGroup string `json:"lock_group,omitempty" yaml:"lock_group,omitempty"`
+	// Inventory optionally resolves Hosts at deploy time from a cloud provider or a service catalog.
+	Inventory *Inventory `json:"inventory,omitempty" yaml:"inventory,omitempty"`
+	// Secrets are fetched at deploy time and given to the deploy command as environment variables.
+	// Their values are redacted from the outputs of deployments.
+	Secrets []Secret `json:"secrets,omitempty" yaml:"secrets,omitempty"`
+	// Vars are non-secret environment variables of the deploy command, e.g. "LOG_LEVEL".
+	// Deployers can change them on the variables page, subject to approvals if RequireApproval is set.
+	Vars map[string]string `json:"vars,omitempty" yaml:"vars,omitempty"`
+	// Artifact optionally verifies the checksum or the signature of the artifact before each deployment.
+	Artifact *Artifact `json:"artifact,omitempty" yaml:"artifact,omitempty"`
+	// Actions are quick actions which users can run in the environment without deploying, e.g. clearing a cache.
+	Actions []Action `json:"actions,omitempty" yaml:"actions,omitempty"`
+	// RemoteCommands optionally lets users run one-off commands on Hosts over SSH.
+	RemoteCommands *RemoteCommands `json:"remote_commands,omitempty" yaml:"remote_commands,omitempty"`
+}
+
+// HostGroup is a named group of hosts in an environment with its own deploy command.
+type HostGroup struct {
+	Name  string   `json:"name" yaml:"name"`
+	Hosts []string `json:"hosts" yaml:"hosts"`
+	// Deploy is the deploy command of the group. Deploy of the environment is used if empty.
+	Deploy string `json:"deploy,omitempty" yaml:"deploy,omitempty"`
+	// Pause makes deployments wait for a manual confirmation before deploying the group if not empty.
+	// It describes what to check before continuing, e.g. "Check that the migrations succeeded".
+	Pause string `json:"pause,omitempty" yaml:"pause,omitempty"`
+}
+
+// Group returns the environment narrowed down to the hosts and the deploy command of "g".
+func (e Environment) Group(g HostGroup) Environment {
+	e.Hosts = g.Hosts
+	if g.Deploy != "" {
+		e.Deploy = g.Deploy
+	}
+	e.HostGroups = nil
+	return e
+}
+
+// CanApprove returns true if "user" is listed in the approvers of the environment.
+// It does not check the access control of the project.
+func (e Environment) CanApprove(user string) bool {
+	return len(e.Approvers) == 0 || contains(e.Approvers, user)
+}
+
+const defaultFailureThreshold = 3
+
+// FailureIssue configures issues opened when an environment fails deployments repeatedly.
+// The issue is closed on the next successful deployment of the environment.
+type FailureIssue struct {
+	// Threshold is the number of consecutive failures which opens an issue. 3 if zero.
+	Threshold int `json:"threshold,omitempty" yaml:"threshold,omitempty"`
+	// Labels are added to the issues.
+	Labels []string `json:"labels,omitempty" yaml:"labels,omitempty"`
+}
+
+// FailureThreshold returns the number of consecutive failures which opens an issue.
+func (f FailureIssue) FailureThreshold() int {
+	if f.Threshold <= 0 {
+		return defaultFailureThreshold
+	}
+	return f.Threshold
+}
+
+// SSH configures how Goship connects to hosts over SSH, e.g. to find deployed revisions and to run remote health checks.
+// Keys in the ssh-agent at SSH_AUTH_SOCK are also offered if the agent is running.
+type SSH struct {
+	// User is the login user in the hosts. DeployUser is used if empty.
+	User string `json:"user,omitempty" yaml:"user,omitempty"`
+	// KeyFile is the path to the private key in the Goship server. The key given by -k flag is used if empty.
+	KeyFile string `json:"key_file,omitempty" yaml:"key_file,omitempty"`
+	// PassphraseEnv is the name of the environment variable of the Goship server which holds the passphrase of KeyFile.
+	PassphraseEnv string `json:"passphrase_env,omitempty" yaml:"passphrase_env,omitempty"`
+	// JumpHost is a bastion host through which the hosts are reached, e.g. "bastion@jump.example.com:2222".
+	// The user defaults to User, and the same keys are used to log in to the jump host.
+	JumpHost string `json:"jump_host,omitempty" yaml:"jump_host,omitempty"`
+}
+
+// inherit returns "s" with its empty fields filled with those of "base".
+func (s SSH) inherit(base SSH) SSH {
+	if s.User == "" {
+		s.User = base.User
+	}
+	if s.KeyFile == "" {
+		s.KeyFile = base.KeyFile
+		if s.PassphraseEnv == "" {
+			s.PassphraseEnv = base.PassphraseEnv
+		}
+	}
+	if s.JumpHost == "" {
+		s.JumpHost = base.JumpHost
+	}
+	return s
+}
+
+// SSHConfig returns the SSH configuration of "env" of "proj".
+// Fields which neither the environment nor the project configures are empty except User, which defaults to DeployUser.
+func (c Config) SSHConfig(proj Project, env Environment) SSH {
+	s := SSH{User: c.DeployUser}
+	for _, o := range []*SSH{proj.SSH, env.SSH} {
+		if o != nil {
+			s = o.inherit(s)
+		}
+	}
+	return s
+}
+
+// Rollout is a strategy which deploys to a canary subset of hosts first,
+// and continues to the rest only if the canary hosts pass the health check.
+type Rollout struct {
+	// Canary is the number of hosts deployed first.
+	Canary      int         `json:"canary" yaml:"canary"`
+	HealthCheck HealthCheck `json:"health_check" yaml:"health_check"`
+}
+
+const (
+	defaultHealthCheckInterval = 10 * time.Second
+	defaultHealthCheckTimeout  = time.Minute
+)
+
+// HealthCheck describes how to tell whether a host is healthy.
+// Exactly one of URL, Command and RemoteCommand must be set.
+type HealthCheck struct {
+	// URL is a template of a URL which responds 2xx when the host is healthy, e.g. "http://{{.Host}}:8080/healthz".
+	URL string `json:"url,omitempty" yaml:"url,omitempty"`
+	// ExpectedStatus is the HTTP status which URL responds when the host is healthy.
+	// Any 2xx status is accepted if zero.
+	ExpectedStatus int `json:"expected_status,omitempty" yaml:"expected_status,omitempty"`
+	// Command is a command which exits with zero when the host is healthy.
+	// It runs on the Goship server with GOSHIP_HOST environment variable set to the host.
+	Command string `json:"command,omitempty" yaml:"command,omitempty"`
+	// RemoteCommand is a command which exits with zero when the host is healthy.
+	// It runs on the host over SSH.
+	RemoteCommand string `json:"remote_command,omitempty" yaml:"remote_command,omitempty"`
+	// Interval is the interval between retries, e.g. "10s".
+	Interval string `json:"interval,omitempty" yaml:"interval,omitempty"`
+	// Timeout is how long to retry until giving up, e.g. "1m".
+	Timeout string `json:"timeout,omitempty" yaml:"timeout,omitempty"`
+}
+
+// HealthCheckParams is the set of parameters available in HealthCheck.URL.
+type HealthCheckParams struct {
+	Host string
+}
+
+// ProbeURL returns the URL to probe the health of "host".
+func (c HealthCheck) ProbeURL(host string) (string, error) {
+	tmpl, err := template.New("url").Parse(c.URL)
+	if err != nil {
+		return "", err
+	}
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, HealthCheckParams{Host: host}); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+// RetryInterval returns the interval between retries of the check.
+func (c HealthCheck) RetryInterval() time.Duration {
+	return durationOr(c.Interval, defaultHealthCheckInterval)
+}
+
+// RetryTimeout returns how long to retry the check.
+func (c HealthCheck) RetryTimeout() time.Duration {
+	return durationOr(c.Timeout, defaultHealthCheckTimeout)
+}
+
+func durationOr(s string, def time.Duration) time.Duration {
+	d, err := time.ParseDuration(s)
+	if err != nil || d <= 0 {
+		return def
+	}
+	return d
+}
+
+// validate returns an error if the check is not well-formed.
+func (c HealthCheck) validate() error {
+	var n int
+	for _, v := range []string{c.URL, c.Command, c.RemoteCommand} {
+		if v != "" {
+			n++
+		}
+	}
+	if n != 1 {
+		return fmt.Errorf("exactly one of url, command or remote_command must be configured in health_check")
+	}
+	if _, err := c.ProbeURL(""); err != nil {
+		return fmt.Errorf("invalid url %q: %v", c.URL, err)
+	}
+	for _, d := range []string{c.Interval, c.Timeout} {
+		if d == "" {
+			continue
+		}
+		if _, err := time.ParseDuration(d); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// A FailurePolicy describes how a failure on a host affects deployments on the other hosts.
+type FailurePolicy string
+
+const (
+	// FailFast stops deployments on the other hosts on the first failure.
+	FailFast = FailurePolicy("fail_fast")
+	// ContinueOnError keeps deploying to the other hosts even after failures.
+	ContinueOnError = FailurePolicy("continue")
+)
+
+func (p FailurePolicy) Valid() bool {
+	switch p {
+	case FailFast, ContinueOnError:
+		return true
+	}
+	return false
+}
+
+// K8sImageParams is the set of parameters available in Environment.K8sImage.
+type K8sImageParams struct {
+	Project     string
+	Environment string
+	Revision    string
+}
+
+// IsK8sDeployment returns true if the target of deployments of the environment is a Kubernetes deployment.
+func (e Environment) IsK8sDeployment() bool {
+	return e.K8sDeployment != ""
+}
+
+// Image returns the image of "rev" to deploy into K8sDeployment.
+func (e Environment) Image(proj, rev string) (string, error) {
+	tmpl, err := template.New(e.Name).Parse(e.K8sImage)
+	if err != nil {
+		return "", err
+	}
+	var buf bytes.Buffer
+	params := K8sImageParams{Project: proj, Environment: e.Name, Revision: rev}
+	if err := tmpl.Execute(&buf, params); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+// An SCMType describes a source code management service.
+type SCMType string
+
+const (
+	// SCMGithub means the source repository is hosted in GitHub.
+	SCMGithub = SCMType("github")
+	// SCMGitLab means the source repository is hosted in GitLab.
+	SCMGitLab = SCMType("gitlab")
+	// SCMBitbucket means the source repository is hosted in Bitbucket Cloud.
+	SCMBitbucket = SCMType("bitbucket")
+)
+
+func (t SCMType) Valid() bool {
+	switch t {
+	case SCMGithub, SCMGitLab, SCMBitbucket:
+		return true
+	}
+	return false
+}
+
+// A NotifierType describes a kind of outbound notification destination.
+type NotifierType string
+
+const (
+	// NotifierTypeSlack posts messages to a Slack incoming webhook.
+	NotifierTypeSlack = NotifierType("slack")
+	// NotifierTypeWebhook posts events as JSON to an arbitrary HTTP endpoint.
+	NotifierTypeWebhook = NotifierType("webhook")
+)
+
+func (t NotifierType) Valid() bool {
+	switch t {
+	case NotifierTypeSlack, NotifierTypeWebhook:
+		return true
+	}
+	return false
+}
+
+// Notifier is a destination of outbound notifications.
+type Notifier struct {
+	Type NotifierType `json:"type" yaml:"type"`
+	// URL is the endpoint which notifications are posted to.
+	URL string `json:"url" yaml:"url"`
+	// Channel optionally overrides the default channel of a Slack incoming webhook.
+	Channel string `json:"channel,omitempty" yaml:"channel,omitempty"`
+	// Labels limits the notifications to events of deployments which have any of the labels.
+	// Events are not limited if empty.
+	Labels []string `json:"labels,omitempty" yaml:"labels,omitempty"`
+	// Digest optionally summarizes the results of deployments periodically instead of notifying each of them.
+	Digest DigestInterval `json:"digest,omitempty" yaml:"digest,omitempty"`
+}
+
+// A DigestInterval is a period of digest notifications.
+type DigestInterval string
+
+const (
+	// DigestHourly summarizes deployments at the top of every hour.
+	DigestHourly = DigestInterval("hourly")
+	// DigestDaily summarizes deployments at midnight every day.
+	DigestDaily = DigestInterval("daily")
+)
+
+// Valid returns true if "d" is a known interval or empty.
+func (d DigestInterval) Valid() bool {
+	switch d {
+	case "", DigestHourly, DigestDaily:
+		return true
+	}
+	return false
+}
+
+// Next returns the end of the period of "d" which contains "t" in the location of "t".
+func (d DigestInterval) Next(t time.Time) time.Time {
+	if d == DigestDaily {
+		y, m, day := t.Date()
+		return time.Date(y, m, day+1, 0, 0, 0, 0, t.Location())
+	}
+	return time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, t.Location())
+}
+
+// Accepts returns true if events of a deployment with "labels" are sent to the notifier.
+func (n Notifier) Accepts(labels []string) bool {
+	if len(n.Labels) == 0 {
+		return true
+	}
+	for _, l := range labels {
+		if contains(n.Labels, l) {
+			return true
+		}
+	}
+	return false
 }
 
 // Repo identifies a revision repository
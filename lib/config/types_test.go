@@ -3,6 +3,7 @@ package config_test
 import (
 	"reflect"
 	"testing"
+	"time"
 
 	"github.com/gengo/goship/lib/config"
 )
@@ -41,3 +42,222 @@ func TestGetEnvironmentFromName(t *testing.T) {
 		t.Errorf("config.EnvironmentFromName error case did not error")
 	}
 }
+
+func TestEarlierStages(t *testing.T) {
+	p := config.Project{Pipeline: []string{"dev", "staging", "production"}}
+	for env, want := range map[string][]string{
+		"production": {"dev", "staging"},
+		"staging":    {"dev"},
+		"dev":        nil,
+		"qa":         nil,
+	} {
+		if got := p.EarlierStages(env); !reflect.DeepEqual(got, want) {
+			t.Errorf("p.EarlierStages(%q) = %q; want %q", env, got, want)
+		}
+	}
+}
+
+func TestEnvironmentImage(t *testing.T) {
+	env := config.Environment{Name: "staging", K8sImage: "gcr.io/example/{{.Project}}-{{.Environment}}:{{.Revision}}"}
+	got, err := env.Image("app", "abc123")
+	if err != nil {
+		t.Fatalf("env.Image(%q, %q) failed with %v; want success", "app", "abc123", err)
+	}
+	if want := "gcr.io/example/app-staging:abc123"; got != want {
+		t.Errorf("env.Image(%q, %q) = %q; want %q", "app", "abc123", got, want)
+	}
+
+	env.K8sImage = "gcr.io/example/app:{{.Revision"
+	if _, err := env.Image("app", "abc123"); err == nil {
+		t.Errorf("env.Image(%q, %q) succeeded with K8sImage %q; want failure", "app", "abc123", env.K8sImage)
+	}
+}
+
+func TestVisibleProjects(t *testing.T) {
+	c := config.Config{Admins: []string{"admin"}}
+	projs := []config.Project{
+		{
+			Name:         "app",
+			Environments: []config.Environment{{Name: "internal", Hidden: true}, {Name: "production"}},
+		},
+		{
+			Name:         "tools",
+			Environments: []config.Environment{{Name: "internal", Hidden: true}},
+		},
+	}
+	for _, spec := range []struct {
+		user string
+		want map[string][]string
+	}{
+		{
+			user: "admin",
+			want: map[string][]string{"app": {"internal", "production"}, "tools": {"internal"}},
+		},
+		{
+			user: "alice",
+			want: map[string][]string{"app": {"production"}},
+		},
+	} {
+		got := make(map[string][]string)
+		for _, p := range c.VisibleProjects(projs, spec.user) {
+			for _, e := range p.Environments {
+				got[p.Name] = append(got[p.Name], e.Name)
+			}
+		}
+		if !reflect.DeepEqual(got, spec.want) {
+			t.Errorf("c.VisibleProjects(projs, %q) = %q; want %q", spec.user, got, spec.want)
+		}
+	}
+	if got, want := len(projs[0].Environments), 2; got != want {
+		t.Errorf("len(projs[0].Environments) = %d; want %d after c.VisibleProjects", got, want)
+	}
+}
+
+func TestNotifierAccepts(t *testing.T) {
+	for _, spec := range []struct {
+		notifier config.Notifier
+		labels   []string
+		want     bool
+	}{
+		{notifier: config.Notifier{}, want: true},
+		{notifier: config.Notifier{}, labels: []string{"hotfix"}, want: true},
+		{notifier: config.Notifier{Labels: []string{"hotfix", "schema-change"}}, labels: []string{"rollback", "hotfix"}, want: true},
+		{notifier: config.Notifier{Labels: []string{"hotfix"}}, labels: []string{"rollback"}},
+		{notifier: config.Notifier{Labels: []string{"hotfix"}}},
+	} {
+		if got := spec.notifier.Accepts(spec.labels); got != spec.want {
+			t.Errorf("%#v.Accepts(%q) = %t; want %t", spec.notifier, spec.labels, got, spec.want)
+		}
+	}
+}
+
+func TestSSHConfig(t *testing.T) {
+	c := config.Config{DeployUser: "deploy"}
+	proj := config.Project{SSH: &config.SSH{KeyFile: "/keys/proj", PassphraseEnv: "PROJ_PASSPHRASE", JumpHost: "jump.example.com"}}
+	for _, spec := range []struct {
+		env  config.Environment
+		want config.SSH
+	}{
+		{
+			env:  config.Environment{Name: "staging"},
+			want: config.SSH{User: "deploy", KeyFile: "/keys/proj", PassphraseEnv: "PROJ_PASSPHRASE", JumpHost: "jump.example.com"},
+		},
+		{
+			env:  config.Environment{Name: "production", SSH: &config.SSH{User: "ops", KeyFile: "/keys/production"}},
+			want: config.SSH{User: "ops", KeyFile: "/keys/production", JumpHost: "jump.example.com"},
+		},
+		{
+			env:  config.Environment{Name: "qa", SSH: &config.SSH{JumpHost: "ops@qa-jump.example.com:2222"}},
+			want: config.SSH{User: "deploy", KeyFile: "/keys/proj", PassphraseEnv: "PROJ_PASSPHRASE", JumpHost: "ops@qa-jump.example.com:2222"},
+		},
+	} {
+		if got := c.SSHConfig(proj, spec.env); got != spec.want {
+			t.Errorf("c.SSHConfig(proj, %q) = %#v; want %#v", spec.env.Name, got, spec.want)
+		}
+	}
+	if got, want := c.SSHConfig(config.Project{}, config.Environment{}), (config.SSH{User: "deploy"}); got != want {
+		t.Errorf("c.SSHConfig without ssh configuration = %#v; want %#v", got, want)
+	}
+}
+
+func TestDigestIntervalNext(t *testing.T) {
+	now := time.Date(2015, time.November, 10, 13, 10, 0, 0, time.UTC)
+	for _, tt := range []struct {
+		d    config.DigestInterval
+		want time.Time
+	}{
+		{d: config.DigestHourly, want: time.Date(2015, time.November, 10, 14, 0, 0, 0, time.UTC)},
+		{d: config.DigestDaily, want: time.Date(2015, time.November, 11, 0, 0, 0, 0, time.UTC)},
+	} {
+		if got := tt.d.Next(now); !got.Equal(tt.want) {
+			t.Errorf("%q.Next(%s) = %s; want %s", tt.d, now, got, tt.want)
+		}
+	}
+}
+
+func TestLoadProjectHealthCheck(t *testing.T) {
+	for _, spec := range []struct {
+		proj    config.Project
+		skipped bool
+	}{
+		{
+			proj: config.Project{
+				HealthCheck:  &config.HealthCheck{URL: "http://{{.Host}}/healthz", ExpectedStatus: 204},
+				AutoRollback: true,
+			},
+		},
+		{
+			proj:    config.Project{AutoRollback: true},
+			skipped: true,
+		},
+		{
+			proj:    config.Project{HealthCheck: &config.HealthCheck{URL: "http://{{.Host}}/healthz", RemoteCommand: "true"}},
+			skipped: true,
+		},
+	} {
+		spec.proj.Name = "example-project"
+		spec.proj.Environments = []config.Environment{{Name: "production", Deploy: "deploy-command"}}
+		if _, ok := loadProject(t, spec.proj); ok == spec.skipped {
+			t.Errorf("project skipped by config.Load(s) = %v; want %v; project = %#v", !ok, spec.skipped, spec.proj)
+		}
+	}
+}
+
+func TestLoadEnvironmentOrder(t *testing.T) {
+	proj, ok := loadProject(t, config.Project{
+		Name: "example-project",
+		Environments: []config.Environment{
+			{Name: "dev", Deploy: "deploy-command"},
+			{Name: "production", Deploy: "deploy-command"},
+			{Name: "qa", Deploy: "deploy-command"},
+			{Name: "staging", Deploy: "deploy-command"},
+			{Name: "ci", Deploy: "deploy-command"},
+		},
+		EnvironmentOrder: []string{"dev", "staging", "production"},
+	})
+	if !ok {
+		t.Fatalf("config.Load(s) skipped the project; want it loaded")
+	}
+	var got []string
+	for _, e := range proj.Environments {
+		got = append(got, e.Name)
+	}
+	if want := []string{"dev", "staging", "production", "ci", "qa"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("environments = %q; want %q", got, want)
+	}
+}
+
+func TestLoadHostGroups(t *testing.T) {
+	for _, spec := range []struct {
+		env   config.Environment
+		valid bool
+		hosts []string
+	}{
+		{
+			env: config.Environment{Name: "production", Deploy: "deploy-command", Hosts: []string{"web1"}, HostGroups: []config.HostGroup{
+				{Name: "workers", Hosts: []string{"worker1"}},
+				{Name: "web", Hosts: []string{"web1", "web2"}},
+			}},
+			valid: true,
+			hosts: []string{"web1", "worker1", "web2"},
+		},
+		{
+			env: config.Environment{Name: "production", Deploy: "deploy-command", HostGroups: []config.HostGroup{
+				{Name: "web", Hosts: []string{"web1"}},
+				{Name: "web", Hosts: []string{"web2"}},
+			}},
+		},
+		{
+			env: config.Environment{Name: "production", Deploy: "deploy-command", HostGroups: []config.HostGroup{{Hosts: []string{"web1"}}}},
+		},
+	} {
+		env, ok := loadEnvironment(t, spec.env)
+		if ok != spec.valid {
+			t.Errorf("loaded the project with host groups %#v = %t; want %t", spec.env.HostGroups, ok, spec.valid)
+			continue
+		}
+		if spec.valid && !reflect.DeepEqual(env.Hosts, spec.hosts) {
+			t.Errorf("hosts = %q; want %q", env.Hosts, spec.hosts)
+		}
+	}
+}
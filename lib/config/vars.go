@@ -0,0 +1,320 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// VarChangeState is a state of a change of environment variables.
+type VarChangeState string
+
+const (
+	// VarChangePending means the change is waiting for an approval.
+	VarChangePending = VarChangeState("pending")
+	// VarChangeApplied means the change has been applied to the environment.
+	VarChangeApplied = VarChangeState("applied")
+	// VarChangeRejected means the change has been rejected.
+	VarChangeRejected = VarChangeState("rejected")
+)
+
+var (
+	// ErrNoSuchVarChange is returned when a change of environment variables to decide is not found.
+	ErrNoSuchVarChange = errors.New("no such change of environment variables")
+	// ErrVarChangeDecided is returned when a change of environment variables has already been applied or rejected.
+	ErrVarChangeDecided = errors.New("change of environment variables already decided")
+)
+
+// VarChange is a change of Environment.Vars.
+// Applied changes are numbered by Version and keep the variables after the change, so that they serve as the history of the variables.
+type VarChange struct {
+	// ID identifies the change in the environment.
+	ID string `json:"id"`
+	// Set are the variables to add or to update.
+	Set map[string]string `json:"set,omitempty"`
+	// Unset are the names of the variables to remove.
+	Unset []string `json:"unset,omitempty"`
+	// User is the name of the user who requested the change.
+	User  string         `json:"user"`
+	State VarChangeState `json:"state"`
+	Time  time.Time      `json:"time"`
+	// Approver is the name of the user who approved or rejected the change, if it required an approval.
+	Approver  string    `json:"approver,omitempty"`
+	DecidedAt time.Time `json:"decided_at,omitempty"`
+	// Version is the version of the variables which the change made, starting at 1. It is zero unless applied.
+	Version int `json:"version,omitempty"`
+	// Vars are the variables after the change was applied.
+	Vars map[string]string `json:"vars,omitempty"`
+}
+
+// Summary describes the change in a line, e.g. "set LOG_LEVEL=debug; unset FEATURE_X".
+func (c VarChange) Summary() string {
+	var names []string
+	for k := range c.Set {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	var parts []string
+	for _, k := range names {
+		parts = append(parts, "set "+k+"="+c.Set[k])
+	}
+	for _, k := range c.Unset {
+		parts = append(parts, "unset "+k)
+	}
+	return strings.Join(parts, "; ")
+}
+
+// EnvVars returns the variables of the environment in the form of KEY=VALUE ordered by their names.
+func (e Environment) EnvVars() []string {
+	var names []string
+	for k := range e.Vars {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	var vars []string
+	for _, k := range names {
+		vars = append(vars, k+"="+e.Vars[k])
+	}
+	return vars
+}
+
+// validateVars returns an error if any of "vars" has an invalid name or conflicts with the secrets or the variables of Goship.
+func validateVars(vars map[string]string, secrets []Secret) error {
+	for k := range vars {
+		if !envNamePattern.MatchString(k) {
+			return fmt.Errorf("invalid name %q of a variable", k)
+		}
+		if strings.HasPrefix(k, "GOSHIP_") {
+			return fmt.Errorf("variable %s must not start with GOSHIP_", k)
+		}
+		for _, s := range secrets {
+			if s.Env == k {
+				return fmt.Errorf("variable %s conflicts with a secret", k)
+			}
+		}
+	}
+	return nil
+}
+
+func varChangeKey(projectName, projectEnv string) string {
+	return fmt.Sprintf("/goship/var-changes/%s/%s", projectName, projectEnv)
+}
+
+func envKey(projectName, projectEnv string) string {
+	return path.Join("/goship/projects", projectName, "environments", projectEnv)
+}
+
+// LoadVarChanges returns the changes of the environment variables of the environment in the order of requests.
+func LoadVarChanges(client ETCDInterface, projectName, projectEnv string) ([]VarChange, error) {
+	resp, err := client.Get(varChangeKey(projectName, projectEnv), false, false)
+	if IsNotFound(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return decodeVarChanges(resp.Node.Value)
+}
+
+func decodeVarChanges(value string) ([]VarChange, error) {
+	if value == "" {
+		return nil, nil
+	}
+	var changes []VarChange
+	if err := json.Unmarshal([]byte(value), &changes); err != nil {
+		return nil, err
+	}
+	return changes, nil
+}
+
+// ChangeVars applies "c" to the variables of the environment, or records it as a pending change if "pending" is true.
+// It returns "c" with a new ID assigned, and the version and the variables after the change if applied.
+func ChangeVars(client ETCDInterface, projectName, projectEnv string, c VarChange, pending bool) (VarChange, error) {
+	if projectName == "" || projectEnv == "" {
+		return VarChange{}, fmt.Errorf("Missing parameters")
+	}
+	if len(c.Set) == 0 && len(c.Unset) == 0 {
+		return VarChange{}, errors.New("no variables to change")
+	}
+	if err := validateVars(c.Set, nil); err != nil {
+		return VarChange{}, err
+	}
+	if !pending {
+		if _, err := changedVars(client, projectName, projectEnv, c); err != nil {
+			return VarChange{}, err
+		}
+	}
+	err := updateVarChanges(client, projectName, projectEnv, func(changes []VarChange) ([]VarChange, error) {
+		var last int
+		for _, prev := range changes {
+			if id, err := strconv.Atoi(prev.ID); err == nil && id > last {
+				last = id
+			}
+		}
+		c.ID, c.State, c.Version, c.Vars = strconv.Itoa(last+1), VarChangePending, 0, nil
+		if !pending {
+			c.State, c.Version = VarChangeApplied, nextVarVersion(changes)
+		}
+		return append(changes, c), nil
+	})
+	if err != nil {
+		return VarChange{}, err
+	}
+	if pending {
+		return c, nil
+	}
+	return applyVars(client, projectName, projectEnv, c, func(changes []VarChange, i int) []VarChange {
+		return append(changes[:i], changes[i+1:]...)
+	})
+}
+
+// DecideVarChange applies or rejects the pending change identified by "id" on behalf of "approver".
+// It returns the decided change.
+func DecideVarChange(client ETCDInterface, projectName, projectEnv, id, approver string, approve bool) (VarChange, error) {
+	var decided VarChange
+	err := updateVarChanges(client, projectName, projectEnv, func(changes []VarChange) ([]VarChange, error) {
+		for i := range changes {
+			c := &changes[i]
+			if c.ID != id {
+				continue
+			}
+			if c.State != VarChangePending {
+				return nil, ErrVarChangeDecided
+			}
+			if approve {
+				if _, err := changedVars(client, projectName, projectEnv, *c); err != nil {
+					return nil, err
+				}
+				c.State, c.Version = VarChangeApplied, nextVarVersion(changes)
+			} else {
+				c.State = VarChangeRejected
+			}
+			c.Approver, c.DecidedAt = approver, time.Now()
+			decided = *c
+			return changes, nil
+		}
+		return nil, ErrNoSuchVarChange
+	})
+	if err != nil {
+		return VarChange{}, err
+	}
+	if !approve {
+		return decided, nil
+	}
+	return applyVars(client, projectName, projectEnv, decided, func(changes []VarChange, i int) []VarChange {
+		changes[i].State, changes[i].Version, changes[i].Approver, changes[i].DecidedAt = VarChangePending, 0, "", time.Time{}
+		return changes
+	})
+}
+
+// nextVarVersion returns the version of the variables after the last applied change in "changes".
+func nextVarVersion(changes []VarChange) int {
+	var version int
+	for _, prev := range changes {
+		if prev.Version > version {
+			version = prev.Version
+		}
+	}
+	return version + 1
+}
+
+// changedVars returns the current variables of the environment with "c" applied.
+// It fails if the variables would be invalid.
+func changedVars(client ETCDInterface, projectName, projectEnv string, c VarChange) (map[string]string, error) {
+	resp, err := client.Get(envKey(projectName, projectEnv), false, false)
+	if err != nil {
+		return nil, err
+	}
+	var env Environment
+	if err := json.Unmarshal([]byte(resp.Node.Value), &env); err != nil {
+		return nil, err
+	}
+	return applyVarChange(env, c)
+}
+
+// applyVarChange returns the variables of "env" with "c" applied.
+func applyVarChange(env Environment, c VarChange) (map[string]string, error) {
+	vars := make(map[string]string)
+	for k, v := range env.Vars {
+		vars[k] = v
+	}
+	for k, v := range c.Set {
+		vars[k] = v
+	}
+	for _, k := range c.Unset {
+		delete(vars, k)
+	}
+	if err := validateVars(vars, env.Secrets); err != nil {
+		return nil, err
+	}
+	return vars, nil
+}
+
+// applyVars stores the variables of the environment changed by "c", which has been recorded as applied,
+// and records the variables after the change in "c".
+// Changes are recorded before they are applied so that each of them is applied once even if instances of Goship decide it concurrently.
+// If the variables cannot be stored, the record of "c" at "i" of the changes is reverted by "withdraw".
+func applyVars(client ETCDInterface, projectName, projectEnv string, c VarChange, withdraw func(changes []VarChange, i int) []VarChange) (VarChange, error) {
+	var vars map[string]string
+	// The stored configuration is changed instead of the loaded one, whose defaults and hosts from host groups are filled.
+	err := update(client, envKey(projectName, projectEnv), func(value string) (string, error) {
+		var env Environment
+		if err := json.Unmarshal([]byte(value), &env); err != nil {
+			return "", err
+		}
+		env.Name = projectEnv
+		changed, err := applyVarChange(env, c)
+		if err != nil {
+			return "", err
+		}
+		vars, env.Vars = changed, changed
+		if len(changed) == 0 {
+			env.Vars = nil
+		}
+		buf, err := json.Marshal(env)
+		return string(buf), err
+	})
+	record := func(changes []VarChange, i int) []VarChange {
+		changes[i].Vars = vars
+		return changes
+	}
+	if err != nil {
+		record = withdraw
+	}
+	rerr := updateVarChanges(client, projectName, projectEnv, func(changes []VarChange) ([]VarChange, error) {
+		for i := range changes {
+			if changes[i].ID == c.ID {
+				return record(changes, i), nil
+			}
+		}
+		return nil, ErrNoSuchVarChange
+	})
+	if err != nil {
+		return VarChange{}, err
+	}
+	if rerr != nil {
+		return VarChange{}, rerr
+	}
+	c.Vars = vars
+	return c, nil
+}
+
+// updateVarChanges replaces the changes of the variables of the environment with what "change" returns for the current ones.
+func updateVarChanges(client ETCDInterface, projectName, projectEnv string, change func([]VarChange) ([]VarChange, error)) error {
+	return update(client, varChangeKey(projectName, projectEnv), func(value string) (string, error) {
+		changes, err := decodeVarChanges(value)
+		if err != nil {
+			return "", err
+		}
+		if changes, err = change(changes); err != nil {
+			return "", err
+		}
+		buf, err := json.Marshal(changes)
+		return string(buf), err
+	})
+}
@@ -0,0 +1,83 @@
+package config_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/gengo/goship/lib/config"
+)
+
+func TestChangeVars(t *testing.T) {
+	s := config.NewMemoryStore()
+	env := config.Environment{
+		Name:    "production",
+		Deploy:  "deploy-command",
+		Vars:    map[string]string{"LOG_LEVEL": "info", "FEATURE_X": "on"},
+		Secrets: []config.Secret{{Env: "API_TOKEN", Provider: config.SecretVault, Path: "secret/data/example-project"}},
+	}
+	proj := config.Project{Name: "example-project", Environments: []config.Environment{env}}
+	if err := config.Store(s, config.Config{Projects: []config.Project{proj}}); err != nil {
+		t.Fatalf("config.Store(s, cfg) failed with %v; want success", err)
+	}
+
+	c, err := config.ChangeVars(s, "example-project", "production", config.VarChange{Set: map[string]string{"LOG_LEVEL": "debug"}, Unset: []string{"FEATURE_X"}, User: "alice"}, false)
+	if err != nil {
+		t.Fatalf("config.ChangeVars(s, %q, %q, c, false) failed with %v; want success", "example-project", "production", err)
+	}
+	if want := map[string]string{"LOG_LEVEL": "debug"}; c.State != config.VarChangeApplied || c.Version != 1 || !reflect.DeepEqual(c.Vars, want) {
+		t.Errorf("config.ChangeVars(s, %q, %q, c, false) = %#v; want version 1 with %v applied", "example-project", "production", c, want)
+	}
+
+	p, err := config.ChangeVars(s, "example-project", "production", config.VarChange{Set: map[string]string{"WORKERS": "4"}, User: "alice"}, true)
+	if err != nil {
+		t.Fatalf("config.ChangeVars(s, %q, %q, c, true) failed with %v; want success", "example-project", "production", err)
+	}
+	if p.ID != "2" || p.State != config.VarChangePending || p.Version != 0 {
+		t.Errorf("config.ChangeVars(s, %q, %q, c, true) = %#v; want pending change 2", "example-project", "production", p)
+	}
+	cfg, err := config.Load(s)
+	if err != nil {
+		t.Fatalf("config.Load(s) failed with %v; want success", err)
+	}
+	if got, want := cfg.Projects[0].Environments[0].EnvVars(), []string{"LOG_LEVEL=debug"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("EnvVars() = %q before the approval; want %q", got, want)
+	}
+
+	got, err := config.DecideVarChange(s, "example-project", "production", p.ID, "bob", true)
+	if err != nil {
+		t.Fatalf("config.DecideVarChange(s, %q, %q, %q, %q, true) failed with %v; want success", "example-project", "production", p.ID, "bob", err)
+	}
+	if got.State != config.VarChangeApplied || got.Approver != "bob" || got.Version != 2 {
+		t.Errorf("config.DecideVarChange(s, %q, %q, %q, %q, true) = %#v; want version 2 approved by bob", "example-project", "production", p.ID, "bob", got)
+	}
+	if _, err := config.DecideVarChange(s, "example-project", "production", p.ID, "carol", false); err != config.ErrVarChangeDecided {
+		t.Errorf("config.DecideVarChange(s, %q, %q, %q, %q, false) failed with %v; want %v", "example-project", "production", p.ID, "carol", err, config.ErrVarChangeDecided)
+	}
+	if _, err := config.DecideVarChange(s, "example-project", "production", "3", "bob", true); err != config.ErrNoSuchVarChange {
+		t.Errorf("config.DecideVarChange(s, %q, %q, %q, %q, true) failed with %v; want %v", "example-project", "production", "3", "bob", err, config.ErrNoSuchVarChange)
+	}
+	cfg, err = config.Load(s)
+	if err != nil {
+		t.Fatalf("config.Load(s) failed with %v; want success", err)
+	}
+	if got, want := cfg.Projects[0].Environments[0].EnvVars(), []string{"LOG_LEVEL=debug", "WORKERS=4"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("EnvVars() = %q after the approval; want %q", got, want)
+	}
+
+	for _, set := range []map[string]string{
+		{"API_TOKEN": "plain"},
+		{"GOSHIP_HOST": "web1"},
+		{"LOG-LEVEL": "debug"},
+	} {
+		if c, err := config.ChangeVars(s, "example-project", "production", config.VarChange{Set: set, User: "alice"}, false); err == nil {
+			t.Errorf("config.ChangeVars(s, %q, %q, c, false) with %v = %#v; want failure", "example-project", "production", set, c)
+		}
+	}
+	changes, err := config.LoadVarChanges(s, "example-project", "production")
+	if err != nil {
+		t.Fatalf("config.LoadVarChanges(s, %q, %q) failed with %v; want success", "example-project", "production", err)
+	}
+	if len(changes) != 2 {
+		t.Errorf("config.LoadVarChanges(s, %q, %q) = %#v; want the 2 changes", "example-project", "production", changes)
+	}
+}
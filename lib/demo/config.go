@@ -0,0 +1,79 @@
+package demo
+
+import (
+	"github.com/gengo/goship/lib/config"
+)
+
+const (
+	// failFlag in a deploy command makes the simulated deployment fail.
+	failFlag = "--fail"
+)
+
+// Config returns a configuration of the fake projects.
+func Config() config.Config {
+	env := func(name, branch string, hosts ...string) config.Environment {
+		return config.Environment{
+			Name:     name,
+			Deploy:   "demo-deploy -e=" + name,
+			RepoPath: "/srv/app/.git",
+			Branch:   branch,
+			Hosts:    hosts,
+		}
+	}
+	proj := func(name string, envs ...config.Environment) config.Project {
+		return config.Project{
+			Name:         name,
+			Repo:         config.Repo{RepoOwner: "goship-demo", RepoName: name},
+			Environments: envs,
+		}
+	}
+
+	flaky := env("qa", "develop", "worker-qa1")
+	flaky.Deploy += " " + failFlag
+	flaky.Comment = "deployment to this environment always fails in the demo"
+
+	locked := env("production", "master", "worker1", "worker2")
+	locked.IsLocked = true
+	// the API and its workers in production are locked together
+	locked.LockGroup = "backend-production"
+
+	parallel := env("production", "master", "web1", "web2", "web3")
+	parallel.Parallelism = 2
+	// deployments of revisions which have not been deployed to staging are warned
+	parallel.Preceding = []string{"staging"}
+	parallel.Actions = []config.Action{
+		{Name: "clear-cache", Command: "demo-clear-cache", Confirm: "Pages will be slow until the cache warms up again."},
+		{Name: "restart", Command: "demo-restart", PerHost: true, Role: config.RoleAdmin},
+	}
+	parallel.RemoteCommands = &config.RemoteCommands{Allowed: []string{"uptime", "df -h"}}
+
+	canary := env("production", "master", "api1", "api2")
+	canary.PerHost = true
+	canary.LockGroup = "backend-production"
+	canary.Rollout = &config.Rollout{
+		Canary:      1,
+		HealthCheck: config.HealthCheck{Command: "true"},
+	}
+	// remote commands in the payments API need a second pair of eyes
+	canary.RemoteCommands = &config.RemoteCommands{Allowed: []string{"uptime"}, RequireApproval: true}
+
+	// revisions are promoted from dev through staging to production
+	payments := proj("payments-api",
+		canary,
+		env("staging", "develop", "staging-api1"),
+		env("dev", "develop", "dev-api1"),
+	)
+	payments.Pipeline = []string{"dev", "staging", "production"}
+
+	return config.Config{
+		DeployUser: "deploy",
+		Projects: []config.Project{
+			proj("storefront",
+				parallel,
+				env("staging", "develop", "staging-web1"),
+			),
+			payments,
+			proj("batch-worker", locked, flaky),
+		},
+	}
+}
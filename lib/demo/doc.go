@@ -0,0 +1,6 @@
+// Package demo provides a self-contained set of fake projects, revisions and deployments.
+//
+// It allows to run goship without etcd, github or deploy target hosts
+// so that people can evaluate goship or develop UI features.
+// Everything in this package is deterministic.
+package demo
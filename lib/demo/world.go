@@ -0,0 +1,235 @@
+package demo
+
+import (
+	"crypto/sha1"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"math/rand"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gengo/goship/lib/config"
+	"github.com/gengo/goship/lib/deploy"
+	"github.com/gengo/goship/lib/revision"
+	"golang.org/x/net/context"
+)
+
+// World is a fake universe of revision control systems and deploy target hosts.
+type World struct {
+	// Step is the interval between lines of the output of simulated deployments.
+	Step time.Duration
+
+	mu       sync.Mutex
+	deployed map[string]revision.Revision
+}
+
+// NewWorld returns a new World in its initial state.
+// Some of the environments in Config are up to date and the others are not in the initial state.
+func NewWorld() *World {
+	w := &World{
+		Step:     300 * time.Millisecond,
+		deployed: make(map[string]revision.Revision),
+	}
+	for _, p := range Config().Projects {
+		for i, e := range p.Environments {
+			rev := latest(p.Name, e.Name)
+			if i%2 == 0 {
+				rev = fakeRevision(p.Name, e.Name, "previous")
+			}
+			w.deployed[key(p.Name, e.Name)] = rev
+		}
+	}
+	return w
+}
+
+func key(proj, env string) string {
+	return fmt.Sprintf("%s/%s", proj, env)
+}
+
+// fakeRevision returns a deterministic hash-like revision of the given components.
+func fakeRevision(components ...string) revision.Revision {
+	return revision.Revision(fmt.Sprintf("%x", sha1.Sum([]byte(strings.Join(components, "/")))))
+}
+
+func latest(proj, env string) revision.Revision {
+	return fakeRevision(proj, env, "latest")
+}
+
+// Control returns a revision.Control which reads revisions from "w".
+func (w *World) Control() revision.Control {
+	return control{w: w}
+}
+
+// Executor returns a deploy.Executor which simulates deployments in "w".
+func (w *World) Executor() deploy.Executor {
+	return executor{w: w}
+}
+
+type control struct {
+	w *World
+}
+
+func (c control) Latest(ctx context.Context, proj config.Project, env config.Environment) (rev, srcRev revision.Revision, err error) {
+	rev = latest(proj.Name, env.Name)
+	return rev, rev, nil
+}
+
+func (c control) LatestDeployed(ctx context.Context, hostname string, proj config.Project, env config.Environment) (rev, srcRev revision.Revision, err error) {
+	c.w.mu.Lock()
+	defer c.w.mu.Unlock()
+	rev = c.w.deployed[key(proj.Name, env.Name)]
+	return rev, rev, nil
+}
+
+func (c control) RevisionURL(p config.Project, rev revision.Revision) string {
+	return ""
+}
+
+func (c control) SourceDiffURL(p config.Project, from, to revision.Revision) string {
+	return ""
+}
+
+func (c control) SourceRevMessage(ctx context.Context, p config.Project, rev revision.Revision) (string, error) {
+	return fmt.Sprintf("Demo commit %s", rev.Short()), nil
+}
+
+type executor struct {
+	w *World
+}
+
+func (e executor) Execute(ctx context.Context, req deploy.Request, stdout, stderr io.Writer) error {
+	if a := req.Action; a != nil {
+		return e.runAction(ctx, req, *a, stdout, stderr)
+	}
+	to := req.To
+	if to == "" {
+		to = latest(req.Project.Name, req.Environment.Name)
+	}
+	fail := strings.Contains(req.Environment.Deploy, failFlag)
+
+	fmt.Fprintf(stdout, "Deploying %s to %s: %s...%s\n", req.Project.Name, req.Environment.Name, req.From.Short(), to.Short())
+	hosts := req.Environment.Hosts
+	err := deploy.ForEachHost(ctx, req.Environment, stdout, stderr, func(ctx context.Context, h string, stdout, stderr io.Writer) error {
+		for _, l := range []string{
+			fmt.Sprintf("fetching %s", to.Short()),
+			fmt.Sprintf("checking out %s", to.Short()),
+			"restarting services",
+		} {
+			if err := e.output(ctx, stdout, l); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	deploy.Report(ctx, deploy.Progress{State: deploy.StateVerifying, HostCount: len(hosts)})
+	if err := e.output(ctx, stdout, "verifying"); err != nil {
+		return err
+	}
+	if fail {
+		fmt.Fprintln(stderr, "simulated failure")
+		return fmt.Errorf("simulated failure of %s in %s", req.Project.Name, req.Environment.Name)
+	}
+	fmt.Fprintln(stdout, "done")
+
+	e.w.mu.Lock()
+	defer e.w.mu.Unlock()
+	e.w.deployed[key(req.Project.Name, req.Environment.Name)] = to
+	return nil
+}
+
+// runAction simulates the quick action "a" on each host, or once if the action is not per host.
+func (e executor) runAction(ctx context.Context, req deploy.Request, a config.Action, stdout, stderr io.Writer) error {
+	run := func(ctx context.Context, h string, stdout, stderr io.Writer) error {
+		return e.output(ctx, stdout, fmt.Sprintf("%s: done", h))
+	}
+	var err error
+	if a.PerHost {
+		err = deploy.ForEachHost(ctx, req.Environment, stdout, stderr, run)
+	} else {
+		err = run(ctx, req.Environment.Name, stdout, stderr)
+	}
+	if err != nil {
+		return err
+	}
+	if strings.Contains(a.Command, failFlag) {
+		fmt.Fprintln(stderr, "simulated failure")
+		return fmt.Errorf("simulated failure of %s in %s", a.Name, req.Environment.Name)
+	}
+	return nil
+}
+
+// RunRemote simulates running "cmd" on "host" as if over SSH.
+// It fails if "cmd" contains the failure flag.
+func (w *World) RunRemote(ctx context.Context, host, cmd string, stdout, stderr io.Writer) error {
+	e := executor{w: w}
+	if err := e.output(ctx, stdout, fmt.Sprintf("%s: simulated output of %q", host, cmd)); err != nil {
+		return err
+	}
+	if strings.Contains(cmd, failFlag) {
+		fmt.Fprintln(stderr, "simulated failure")
+		return fmt.Errorf("simulated failure of %q on %s", cmd, host)
+	}
+	return nil
+}
+
+// output writes a line "l" into "w" after an interval.
+func (e executor) output(ctx context.Context, w io.Writer, l string) error {
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-time.After(e.w.Step):
+	}
+	_, err := fmt.Fprintln(w, l)
+	return err
+}
+
+// Deployment is a fake record of a past deployment.
+type Deployment struct {
+	From, To revision.Revision
+	User     string
+	Success  bool
+	Time     time.Time
+	Output   []string
+}
+
+var users = []string{"alice", "bob", "carol", "dave"}
+
+// History returns fake records of past deployments of "env" in "proj" before "now".
+// The records are ordered from the oldest to the newest.
+func History(proj, env string, now time.Time) []Deployment {
+	h := fnv.New64a()
+	io.WriteString(h, key(proj, env))
+	rnd := rand.New(rand.NewSource(int64(h.Sum64())))
+
+	const n = 5
+	var history []Deployment
+	from := fakeRevision(proj, env, "initial")
+	t := now.Add(-time.Duration(n+1) * 24 * time.Hour)
+	for i := 0; i < n; i++ {
+		t = t.Add(time.Duration(12+rnd.Intn(24)) * time.Hour)
+		to := fakeRevision(proj, env, fmt.Sprint(i))
+		d := Deployment{
+			From:    from,
+			To:      to,
+			User:    users[rnd.Intn(len(users))],
+			Success: rnd.Intn(5) != 0,
+			Time:    t,
+			Output: []string{
+				fmt.Sprintf("Deploying %s to %s: %s...%s", proj, env, from.Short(), to.Short()),
+			},
+		}
+		if d.Success {
+			d.Output = append(d.Output, "done")
+			from = to
+		} else {
+			d.Output = append(d.Output, "simulated failure")
+		}
+		history = append(history, d)
+	}
+	return history
+}
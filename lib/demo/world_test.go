@@ -0,0 +1,74 @@
+package demo
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/gengo/goship/lib/deploy"
+	"golang.org/x/net/context"
+)
+
+func TestExecutor(t *testing.T) {
+	w := NewWorld()
+	w.Step = 0
+	ctx := context.Background()
+	proj := Config().Projects[0]
+	env := proj.Environments[0]
+
+	before, _, err := w.Control().LatestDeployed(ctx, env.Hosts[0], proj, env)
+	if err != nil {
+		t.Fatalf("LatestDeployed(ctx, %q, %q, %q) failed with %v; want success", env.Hosts[0], proj.Name, env.Name, err)
+	}
+	latest, _, err := w.Control().Latest(ctx, proj, env)
+	if err != nil {
+		t.Fatalf("Latest(ctx, %q, %q) failed with %v; want success", proj.Name, env.Name, err)
+	}
+	if before == latest {
+		t.Fatalf("%s/%s is up to date in the initial state; want outdated", proj.Name, env.Name)
+	}
+
+	var stdout, stderr bytes.Buffer
+	req := deploy.Request{Project: proj, Environment: env, From: before, To: latest}
+	if err := w.Executor().Execute(ctx, req, &stdout, &stderr); err != nil {
+		t.Fatalf("Execute(ctx, %#v) failed with %v; want success", req, err)
+	}
+	if stdout.Len() == 0 {
+		t.Errorf("stdout is empty; want some outputs")
+	}
+
+	after, _, err := w.Control().LatestDeployed(ctx, env.Hosts[0], proj, env)
+	if err != nil {
+		t.Fatalf("LatestDeployed(ctx, %q, %q, %q) failed with %v; want success", env.Hosts[0], proj.Name, env.Name, err)
+	}
+	if got, want := after, latest; got != want {
+		t.Errorf("LatestDeployed(ctx, %q, %q, %q) = %q; want %q", env.Hosts[0], proj.Name, env.Name, got, want)
+	}
+}
+
+func TestExecutorFailure(t *testing.T) {
+	w := NewWorld()
+	w.Step = 0
+	proj := Config().Projects[2]
+	env := proj.Environments[1]
+	var stdout, stderr bytes.Buffer
+	req := deploy.Request{Project: proj, Environment: env}
+	if err := w.Executor().Execute(context.Background(), req, &stdout, &stderr); err == nil {
+		t.Errorf("Execute(ctx, %#v) succeeded; want failure", req)
+	}
+}
+
+func TestHistoryIsDeterministic(t *testing.T) {
+	now := time.Date(2015, time.November, 10, 23, 0, 0, 0, time.UTC)
+	a := History("storefront", "production", now)
+	b := History("storefront", "production", now)
+	if !reflect.DeepEqual(a, b) {
+		t.Errorf("History returned different results for the same arguments: %#v and %#v", a, b)
+	}
+	for i := 1; i < len(a); i++ {
+		if !a[i-1].Time.Before(a[i].Time) {
+			t.Errorf("History(...)[%d].Time = %v; want after %v", i, a[i].Time, a[i-1].Time)
+		}
+	}
+}
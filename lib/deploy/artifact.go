@@ -0,0 +1,182 @@
+package deploy
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/gengo/goship/lib/config"
+	"golang.org/x/net/context"
+)
+
+// artifactClient downloads artifacts and their checksums.
+var artifactClient = &http.Client{Timeout: 10 * time.Minute}
+
+// maxChecksumSize is the maximum size of checksum files.
+const maxChecksumSize = 64 << 10
+
+var sha256Pattern = regexp.MustCompile(`^[0-9a-f]{64}$`)
+
+// mismatchError means that an artifact is not the one which its checksum describes.
+type mismatchError struct {
+	artifact, got, want string
+}
+
+func (e mismatchError) Error() string {
+	return fmt.Sprintf("sha256 of %s is %s; want %s", e.artifact, e.got, e.want)
+}
+
+// ArtifactVerifier verifies artifacts before they are deployed.
+type ArtifactVerifier struct {
+	// Remote runs the commands which verify artifacts staged on hosts.
+	// Such artifacts cannot be verified if nil.
+	Remote Remote
+}
+
+// Verify verifies the artifact of the environment of "req" if configured. Results are written into "w".
+// It returns an error if the checksum mismatches, or if the artifact cannot be verified and the environment requires verified artifacts.
+// Otherwise unverifiable artifacts are only warned about.
+func (v ArtifactVerifier) Verify(ctx context.Context, req Request, w io.Writer) error {
+	a := req.Environment.Artifact
+	if a == nil {
+		return nil
+	}
+	var err error
+	switch a.Type {
+	case config.ArtifactImage:
+		err = v.verifyImage(ctx, req, w)
+	default:
+		err = v.verifyTarball(ctx, req, w)
+	}
+	if err == nil {
+		return nil
+	}
+	if _, ok := err.(mismatchError); ok || a.Required {
+		return fmt.Errorf("refused to deploy an unverified artifact: %v", err)
+	}
+	fmt.Fprintf(w, "WARNING: deploying an unverified artifact: %v\n", err)
+	return nil
+}
+
+// verifyImage verifies the signature of the image with cosign on the local host.
+func (v ArtifactVerifier) verifyImage(ctx context.Context, req Request, w io.Writer) error {
+	env := req.Environment
+	image, err := env.ArtifactURL(req.Project.Name, string(req.To))
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(w, "Verifying the signature of %s\n", image)
+	if err := run(ctx, Request{}, []string{"cosign", "verify", "--key", env.Artifact.CosignKey, image}, w, w); err != nil {
+		return fmt.Errorf("signature of %s not verified: %v", image, err)
+	}
+	fmt.Fprintf(w, "%s: signature OK\n", image)
+	return nil
+}
+
+// verifyTarball downloads the tarball and compares its SHA-256 with the published checksum,
+// and then with the checksums of the copies staged on the hosts if any.
+func (v ArtifactVerifier) verifyTarball(ctx context.Context, req Request, w io.Writer) error {
+	env, proj, rev := req.Environment, req.Project.Name, string(req.To)
+	u, err := env.ArtifactURL(proj, rev)
+	if err != nil {
+		return err
+	}
+	checksumURL, err := env.ArtifactChecksumURL(proj, rev)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(w, "Verifying %s with %s\n", u, checksumURL)
+	want, err := fetchChecksum(checksumURL)
+	if err != nil {
+		return fmt.Errorf("checksum of %s not available: %v", u, err)
+	}
+	got, err := download(u)
+	if err != nil {
+		return err
+	}
+	if got != want {
+		return mismatchError{artifact: u, got: got, want: want}
+	}
+	fmt.Fprintf(w, "%s: sha256 %s OK\n", u, got)
+
+	path, err := env.ArtifactHostPath(proj, rev)
+	if err != nil || path == "" {
+		return err
+	}
+	if v.Remote == nil {
+		return fmt.Errorf("remote commands are not available")
+	}
+	for _, host := range env.Hosts {
+		out, err := v.Remote.Output(ctx, host, "sha256sum "+shellQuote(path))
+		if err != nil {
+			return err
+		}
+		got, err := parseChecksum(string(out))
+		if err != nil {
+			return fmt.Errorf("invalid output of sha256sum on %s: %v", host, err)
+		}
+		if got != want {
+			return mismatchError{artifact: fmt.Sprintf("%s on %s", path, host), got: got, want: want}
+		}
+		fmt.Fprintf(w, "[%s] %s: sha256 OK\n", host, path)
+	}
+	return nil
+}
+
+// fetchChecksum returns the SHA-256 checksum at "u" in the format of sha256sum.
+func fetchChecksum(u string) (string, error) {
+	resp, err := artifactClient.Get(u)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("HTTP status %d from %s", resp.StatusCode, u)
+	}
+	buf, err := ioutil.ReadAll(io.LimitReader(resp.Body, maxChecksumSize))
+	if err != nil {
+		return "", err
+	}
+	return parseChecksum(string(buf))
+}
+
+// download returns the SHA-256 checksum of the content at "u" without keeping the content.
+func download(u string) (string, error) {
+	resp, err := artifactClient.Get(u)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("HTTP status %d from %s", resp.StatusCode, u)
+	}
+	h := sha256.New()
+	if _, err := io.Copy(h, resp.Body); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// parseChecksum returns the checksum at the beginning of "s", which is either a bare checksum or a line of sha256sum.
+func parseChecksum(s string) (string, error) {
+	fields := strings.Fields(s)
+	if len(fields) == 0 {
+		return "", fmt.Errorf("empty checksum")
+	}
+	sum := strings.ToLower(fields[0])
+	if !sha256Pattern.MatchString(sum) {
+		return "", fmt.Errorf("invalid sha256 checksum %q", fields[0])
+	}
+	return sum, nil
+}
+
+// shellQuote quotes "s" as a single word of POSIX shells.
+func shellQuote(s string) string {
+	return "'" + strings.Replace(s, "'", `'\''`, -1) + "'"
+}
@@ -0,0 +1,99 @@
+package deploy
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gengo/goship/lib/config"
+	"golang.org/x/net/context"
+)
+
+// checksumRemote returns the checksums of the staged artifacts on each host like sha256sum.
+type checksumRemote map[string]string
+
+func (r checksumRemote) Output(ctx context.Context, host, cmd string) ([]byte, error) {
+	return []byte(fmt.Sprintf("%s  /opt/app/app.tar.gz\n", r[host])), nil
+}
+
+func TestArtifactVerifierTarball(t *testing.T) {
+	content := []byte("tarball of abc123")
+	sum := sha256.Sum256(content)
+	good := hex.EncodeToString(sum[:])
+	bad := hex.EncodeToString(make([]byte, sha256.Size))
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/abc123.tar.gz", func(w http.ResponseWriter, r *http.Request) {
+		w.Write(content)
+	})
+	mux.HandleFunc("/abc123.tar.gz.sha256", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, "%s  abc123.tar.gz\n", good)
+	})
+	mux.HandleFunc("/abc123.tar.gz.bad", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, bad)
+	})
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	for _, spec := range []struct {
+		artifact config.Artifact
+		remote   Remote
+		ok       bool
+	}{
+		{
+			artifact: config.Artifact{Type: config.ArtifactTarball, URL: srv.URL + "/{{.Revision}}.tar.gz"},
+			ok:       true,
+		},
+		{
+			artifact: config.Artifact{Type: config.ArtifactTarball, URL: srv.URL + "/{{.Revision}}.tar.gz", ChecksumURL: srv.URL + "/{{.Revision}}.tar.gz.bad"},
+		},
+		{
+			artifact: config.Artifact{Type: config.ArtifactTarball, URL: srv.URL + "/{{.Revision}}.tar.gz", ChecksumURL: srv.URL + "/missing"},
+			ok:       true,
+		},
+		{
+			artifact: config.Artifact{Type: config.ArtifactTarball, URL: srv.URL + "/{{.Revision}}.tar.gz", ChecksumURL: srv.URL + "/missing", Required: true},
+		},
+		{
+			artifact: config.Artifact{Type: config.ArtifactTarball, URL: srv.URL + "/{{.Revision}}.tar.gz", HostPath: "/opt/app/app.tar.gz"},
+			remote:   checksumRemote{"web1": good, "web2": good},
+			ok:       true,
+		},
+		{
+			artifact: config.Artifact{Type: config.ArtifactTarball, URL: srv.URL + "/{{.Revision}}.tar.gz", HostPath: "/opt/app/app.tar.gz"},
+			remote:   checksumRemote{"web1": good, "web2": bad},
+		},
+		{
+			artifact: config.Artifact{Type: config.ArtifactTarball, URL: srv.URL + "/{{.Revision}}.tar.gz", HostPath: "/opt/app/app.tar.gz", Required: true},
+		},
+	} {
+		a := spec.artifact
+		req := Request{
+			Project:     config.Project{Name: "example-project"},
+			Environment: config.Environment{Name: "production", Hosts: []string{"web1", "web2"}, Artifact: &a},
+			To:          "abc123",
+		}
+		err := ArtifactVerifier{Remote: spec.remote}.Verify(context.Background(), req, ioutil.Discard)
+		if got := err == nil; got != spec.ok {
+			t.Errorf("Verify(ctx, req, w) with %#v succeeded = %t; want %t; err = %v", a, got, spec.ok, err)
+		}
+	}
+}
+
+func TestParseChecksum(t *testing.T) {
+	sum := "9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08"
+	for _, s := range []string{sum, sum + "\n", sum + "  app.tar.gz\n", "9F86D081884C7D659A2FEAA0C55AD015A3BF4F1B2B0B822CD15D6C15B0F00A08"} {
+		if got, err := parseChecksum(s); err != nil || got != sum {
+			t.Errorf("parseChecksum(%q) = %q, %v; want %q, nil", s, got, err, sum)
+		}
+	}
+	for _, s := range []string{"", "abc123  app.tar.gz", "<html>Not Found</html>"} {
+		if got, err := parseChecksum(s); err == nil {
+			t.Errorf("parseChecksum(%q) = %q; want failure", s, got)
+		}
+	}
+}
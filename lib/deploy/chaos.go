@@ -0,0 +1,120 @@
+package deploy
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"sync"
+	"time"
+
+	"golang.org/x/net/context"
+	yaml "gopkg.in/yaml.v2"
+)
+
+// ChaosSteps are the steps which the chaos executor simulates on each host, in order.
+var ChaosSteps = []string{"preflight", "fetch", "install", "restart", "verify"}
+
+// Fault describes a delay or a failure injected into deployments simulated by the chaos executor.
+// Empty Project, Environment, Host or Step match any.
+type Fault struct {
+	Project     string `yaml:"project,omitempty"`
+	Environment string `yaml:"environment,omitempty"`
+	Host        string `yaml:"host,omitempty"`
+	Step        string `yaml:"step,omitempty"`
+	// Delay is the time to wait before the step finishes.
+	Delay time.Duration `yaml:"delay,omitempty"`
+	// Fail makes the step fail.
+	Fail bool `yaml:"fail,omitempty"`
+	// Times limits the number of times the fault is injected. Zero means no limit.
+	// It is useful to exercise retries.
+	Times int `yaml:"times,omitempty"`
+}
+
+func (f Fault) match(req Request, host, step string) bool {
+	for _, c := range []struct{ pattern, value string }{
+		{f.Project, req.Project.Name},
+		{f.Environment, req.Environment.Name},
+		{f.Host, host},
+		{f.Step, step},
+	} {
+		if c.pattern != "" && c.pattern != c.value {
+			return false
+		}
+	}
+	return true
+}
+
+// LoadFaults reads a list of faults from a YAML file at "path".
+func LoadFaults(path string) ([]Fault, error) {
+	buf, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var faults []Fault
+	if err := yaml.Unmarshal(buf, &faults); err != nil {
+		return nil, err
+	}
+	return faults, nil
+}
+
+// NewChaosExecutor returns an Executor which simulates deployments without touching any hosts.
+// It runs ChaosSteps on each host of the environment with ForEachHost and injects "faults" into the steps.
+// It is intended to exercise failure handling of goship itself in staging installations.
+func NewChaosExecutor(faults []Fault) Executor {
+	return &chaosExecutor{
+		faults: faults,
+		counts: make([]int, len(faults)),
+	}
+}
+
+type chaosExecutor struct {
+	mu     sync.Mutex
+	faults []Fault
+	// counts are the numbers of times each fault has been injected.
+	counts []int
+}
+
+func (e *chaosExecutor) Execute(ctx context.Context, req Request, stdout, stderr io.Writer) error {
+	fmt.Fprintf(stdout, "Simulating deployment of %s to %s: %s...%s\n", req.Project.Name, req.Environment.Name, req.From.Short(), req.To.Short())
+	err := ForEachHost(ctx, req.Environment, stdout, stderr, func(ctx context.Context, h string, stdout, stderr io.Writer) error {
+		for _, s := range ChaosSteps {
+			fmt.Fprintln(stdout, s)
+			delay, fail := e.inject(req, h, s)
+			if delay > 0 {
+				select {
+				case <-ctx.Done():
+					return ctx.Err()
+				case <-time.After(delay):
+				}
+			}
+			if fail {
+				fmt.Fprintf(stderr, "injected failure in %s\n", s)
+				return fmt.Errorf("injected failure in %s on %s", s, h)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	fmt.Fprintln(stdout, "done")
+	return nil
+}
+
+// inject returns the total delay of the faults which match the step and whether any of them fails the step.
+func (e *chaosExecutor) inject(req Request, host, step string) (delay time.Duration, fail bool) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	for i, f := range e.faults {
+		if !f.match(req, host, step) {
+			continue
+		}
+		if f.Times > 0 && e.counts[i] >= f.Times {
+			continue
+		}
+		e.counts[i]++
+		delay += f.Delay
+		fail = fail || f.Fail
+	}
+	return delay, fail
+}
@@ -0,0 +1,79 @@
+package deploy
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gengo/goship/lib/config"
+	"golang.org/x/net/context"
+)
+
+func TestChaosExecutor(t *testing.T) {
+	req := Request{
+		Project: config.Project{Name: "proj"},
+		Environment: config.Environment{
+			Name:  "staging",
+			Hosts: []string{"host1", "host2"},
+		},
+		From: "from",
+		To:   "to",
+	}
+	for _, spec := range []struct {
+		faults   []Fault
+		fails    []bool
+		lastLine string
+	}{
+		{
+			fails:    []bool{false, false},
+			lastLine: "done",
+		},
+		{
+			faults: []Fault{
+				{Host: "host2", Step: "restart", Fail: true},
+			},
+			fails:    []bool{true, true},
+			lastLine: "[host2] restart",
+		},
+		{
+			faults: []Fault{
+				{Environment: "production", Fail: true},
+				{Project: "proj", Step: "verify", Delay: time.Millisecond},
+			},
+			fails:    []bool{false, false},
+			lastLine: "done",
+		},
+		{
+			faults: []Fault{
+				{Step: "fetch", Fail: true, Times: 1},
+			},
+			fails:    []bool{true, false},
+			lastLine: "done",
+		},
+	} {
+		e := NewChaosExecutor(spec.faults)
+		var stdout, stderr bytes.Buffer
+		for i, want := range spec.fails {
+			stdout.Reset()
+			err := e.Execute(context.Background(), req, &stdout, &stderr)
+			if got := err != nil; got != want {
+				t.Errorf("e.Execute(ctx, %#v, stdout, stderr) #%d failed = %v; want %v; faults = %#v; err = %v", req, i, got, want, spec.faults, err)
+			}
+		}
+		lines := strings.Split(strings.TrimSpace(stdout.String()), "\n")
+		if got, want := lines[len(lines)-1], spec.lastLine; got != want {
+			t.Errorf("last line of stdout = %q; want %q; faults = %#v", got, want, spec.faults)
+		}
+	}
+}
+
+func TestChaosExecutorCancel(t *testing.T) {
+	e := NewChaosExecutor([]Fault{{Delay: time.Hour}})
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	var stdout, stderr bytes.Buffer
+	if err := e.Execute(ctx, Request{}, &stdout, &stderr); err == nil {
+		t.Errorf("e.Execute(ctx, req, stdout, stderr) succeeded with a canceled context; want failure")
+	}
+}
@@ -0,0 +1,124 @@
+package deploy
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+// ErrShuttingDown is returned for deployments requested after the server started shutting down.
+var ErrShuttingDown = errors.New("goship is shutting down")
+
+// Drain tracks deployments in flight so that the server can wait for them to finish before it exits.
+// A nil Drain tracks nothing.
+type Drain struct {
+	mu       sync.Mutex
+	closed   bool
+	inflight int
+	// idle is closed when no deployments are in flight after Wait is called.
+	idle chan struct{}
+}
+
+// NewDrain returns a new Drain which is accepting deployments.
+func NewDrain() *Drain {
+	return &Drain{idle: make(chan struct{})}
+}
+
+// Add registers a deployment in flight. The caller must call "done" when it finishes.
+// It returns ErrShuttingDown once Wait has been called.
+func (d *Drain) Add() (done func(), err error) {
+	if d == nil {
+		return func() {}, nil
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.closed {
+		return nil, ErrShuttingDown
+	}
+	return d.add(), nil
+}
+
+// Go runs "f" in a new goroutine and waits for it like a deployment in flight even after Wait is called,
+// e.g. to flush the output of a deployment which has just finished.
+func (d *Drain) Go(f func()) {
+	if d == nil {
+		go f()
+		return
+	}
+	d.mu.Lock()
+	done := d.add()
+	d.mu.Unlock()
+	go func() {
+		defer done()
+		f()
+	}()
+}
+
+// add increments the number of deployments in flight. d.mu must be held.
+func (d *Drain) add() func() {
+	d.inflight++
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			d.mu.Lock()
+			defer d.mu.Unlock()
+			d.inflight--
+			d.notifyIdle()
+		})
+	}
+}
+
+// notifyIdle closes d.idle if shutting down and no deployments are in flight. d.mu must be held.
+func (d *Drain) notifyIdle() {
+	if !d.closed || d.inflight > 0 {
+		return
+	}
+	select {
+	case <-d.idle:
+	default:
+		close(d.idle)
+	}
+}
+
+// Wait stops accepting new deployments and waits until the ones in flight finish or "timeout" elapses.
+// It returns false if some deployments are still in flight.
+func (d *Drain) Wait(timeout time.Duration) bool {
+	if d == nil {
+		return true
+	}
+	d.mu.Lock()
+	d.closed = true
+	d.notifyIdle()
+	idle := d.inflight == 0
+	d.mu.Unlock()
+	if idle {
+		return true
+	}
+	select {
+	case <-d.idle:
+		return true
+	case <-time.After(timeout):
+		return false
+	}
+}
+
+// Stopping returns true once Wait has been called.
+// Deployments which have been waiting for preceding ones should give up instead of starting then.
+func (d *Drain) Stopping() bool {
+	if d == nil {
+		return false
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.closed
+}
+
+// InFlight returns the number of deployments in flight.
+func (d *Drain) InFlight() int {
+	if d == nil {
+		return 0
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.inflight
+}
@@ -0,0 +1,53 @@
+package deploy
+
+import (
+	"testing"
+	"time"
+)
+
+func TestDrain(t *testing.T) {
+	d := NewDrain()
+	done, err := d.Add()
+	if err != nil {
+		t.Fatalf("d.Add() failed with %v; want success", err)
+	}
+	flushed := make(chan struct{})
+	d.Go(func() { <-flushed })
+
+	if d.Stopping() {
+		t.Errorf("d.Stopping() = true before Wait; want false")
+	}
+	if d.Wait(10 * time.Millisecond) {
+		t.Errorf("d.Wait(10ms) = true with deployments in flight; want false")
+	}
+	if !d.Stopping() {
+		t.Errorf("d.Stopping() = false after Wait; want true")
+	}
+	if _, err := d.Add(); err != ErrShuttingDown {
+		t.Errorf("d.Add() after Wait failed with %v; want %v", err, ErrShuttingDown)
+	}
+	done()
+	done()
+	if got, want := d.InFlight(), 1; got != want {
+		t.Errorf("d.InFlight() = %d; want %d", got, want)
+	}
+	close(flushed)
+	if !d.Wait(time.Second) {
+		t.Errorf("d.Wait(1s) = false after all deployments finished; want true")
+	}
+}
+
+func TestDrainIdle(t *testing.T) {
+	if !NewDrain().Wait(0) {
+		t.Errorf("Wait(0) = false without deployments; want true")
+	}
+	var d *Drain
+	done, err := d.Add()
+	if err != nil {
+		t.Fatalf("(*Drain)(nil).Add() failed with %v; want success", err)
+	}
+	done()
+	if !d.Wait(0) {
+		t.Errorf("(*Drain)(nil).Wait(0) = false; want true")
+	}
+}
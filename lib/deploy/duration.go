@@ -0,0 +1,83 @@
+package deploy
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+const (
+	// MinBaselineSamples is the number of past durations needed to tell whether a duration is anomalous.
+	MinBaselineSamples = 5
+	// MaxBaselineSamples is the number of the latest durations which a baseline is computed from.
+	MaxBaselineSamples = 20
+	// deviationFactor is how many spreads away from the median an anomalous duration is at least.
+	deviationFactor = 3
+	// madScale scales the median absolute deviation to estimate the standard deviation of normally distributed durations.
+	madScale = 1.4826
+)
+
+// Baseline is the typical duration of deployments to an environment.
+type Baseline struct {
+	Median time.Duration
+	// Spread is the scaled median absolute deviation, which estimates the standard deviation without being skewed by outliers.
+	Spread  time.Duration
+	Samples int
+}
+
+// NewBaseline returns the baseline of "durations", or false if there are fewer than MinBaselineSamples of them.
+func NewBaseline(durations []time.Duration) (Baseline, bool) {
+	if len(durations) < MinBaselineSamples {
+		return Baseline{}, false
+	}
+	median := medianOf(durations)
+	deviations := make([]time.Duration, len(durations))
+	for i, d := range durations {
+		deviations[i] = abs(d - median)
+	}
+	spread := time.Duration(float64(medianOf(deviations)) * madScale)
+	return Baseline{Median: median, Spread: spread, Samples: len(durations)}, true
+}
+
+// Anomaly describes how "d" deviates from "b", or returns "" if it does not deviate significantly.
+// "d" deviates if it is more than 3 spreads away from the median, and also differs from the median by half of it
+// so that fluctuations of stable or short deployments are not flagged.
+func (b Baseline) Anomaly(d time.Duration) string {
+	diff := abs(d - b.Median)
+	if diff <= deviationFactor*b.Spread || diff <= b.Median/2 {
+		return ""
+	}
+	usual := seconds(b.Median)
+	if d > b.Median {
+		return fmt.Sprintf("took %s, %.1f times as long as the usual %s", seconds(d), float64(d)/float64(b.Median), usual)
+	}
+	return fmt.Sprintf("took only %s while it usually takes %s", seconds(d), usual)
+}
+
+func medianOf(durations []time.Duration) time.Duration {
+	sorted := append([]time.Duration(nil), durations...)
+	sort.Sort(byDuration(sorted))
+	n := len(sorted)
+	if n%2 == 1 {
+		return sorted[n/2]
+	}
+	return (sorted[n/2-1] + sorted[n/2]) / 2
+}
+
+// seconds truncates "d" to seconds for display.
+func seconds(d time.Duration) time.Duration {
+	return d / time.Second * time.Second
+}
+
+func abs(d time.Duration) time.Duration {
+	if d < 0 {
+		return -d
+	}
+	return d
+}
+
+type byDuration []time.Duration
+
+func (ds byDuration) Len() int           { return len(ds) }
+func (ds byDuration) Swap(i, j int)      { ds[i], ds[j] = ds[j], ds[i] }
+func (ds byDuration) Less(i, j int) bool { return ds[i] < ds[j] }
@@ -0,0 +1,49 @@
+package deploy
+
+import (
+	"testing"
+	"time"
+)
+
+func TestBaseline(t *testing.T) {
+	minutes := func(ms ...float64) []time.Duration {
+		var ds []time.Duration
+		for _, m := range ms {
+			ds = append(ds, time.Duration(m*float64(time.Minute)))
+		}
+		return ds
+	}
+	if _, ok := NewBaseline(minutes(5, 6, 7, 8)); ok {
+		t.Errorf("NewBaseline(4 durations) = _, true; want false")
+	}
+	b, ok := NewBaseline(minutes(5, 6, 6, 7, 6, 5, 60))
+	if !ok {
+		t.Fatalf("NewBaseline(...) = _, false; want true")
+	}
+	if got, want := b.Median, 6*time.Minute; got != want {
+		t.Errorf("b.Median = %v; want %v", got, want)
+	}
+
+	for _, spec := range []struct {
+		d       time.Duration
+		anomaly bool
+	}{
+		{d: 6 * time.Minute},
+		{d: 8 * time.Minute},
+		{d: 25 * time.Minute, anomaly: true},
+		{d: 30 * time.Second, anomaly: true},
+	} {
+		if got := b.Anomaly(spec.d); (got != "") != spec.anomaly {
+			t.Errorf("b.Anomaly(%v) = %q; want anomalous: %t", spec.d, got, spec.anomaly)
+		}
+	}
+
+	// stable durations do not make small differences anomalous
+	b, _ = NewBaseline(minutes(6, 6, 6, 6, 6))
+	if got := b.Anomaly(7 * time.Minute); got != "" {
+		t.Errorf("b.Anomaly(7m) = %q; want no anomaly", got)
+	}
+	if got := b.Anomaly(20 * time.Minute); got != "took 20m0s, 3.3 times as long as the usual 6m0s" {
+		t.Errorf("b.Anomaly(20m) = %q; want the description of the anomaly", got)
+	}
+}
@@ -0,0 +1,157 @@
+// Package deploy provides abstractions of how goship runs deployments.
+package deploy
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/gengo/goship/lib/config"
+	"github.com/gengo/goship/lib/logging"
+	"github.com/gengo/goship/lib/revision"
+	"golang.org/x/net/context"
+)
+
+// Request describes a deployment to be executed.
+type Request struct {
+	Project     config.Project
+	Environment config.Environment
+	// From is the revision deployed before the deployment.
+	From revision.Revision
+	// To is the revision to be deployed.
+	To revision.Revision
+	// User is the name of the user who requested the deployment.
+	User string
+	// Restart makes the executor restart the environment instead of deploying To.
+	Restart bool
+	// Action makes the executor run the quick action instead of deploying To if not nil.
+	Action *config.Action
+	// Reason is an optional justification of the deployment, e.g. of an emergency deployment during a freeze.
+	Reason string
+	// Labels are tags of the deployment given by the user, e.g. "hotfix".
+	Labels []string
+	// Env are additional environment variables of the deploy commands in the form of KEY=VALUE, e.g. secrets.
+	Env []string
+}
+
+// Deploys returns true if the request deploys To rather than restarting the environment or running a quick action.
+func (r Request) Deploys() bool {
+	return !r.Restart && r.Action == nil
+}
+
+// Executor runs deployments.
+type Executor interface {
+	// Execute runs the deployment described in "req".
+	// It writes outputs of the deployment into "stdout" and "stderr", and returns an error if the deployment fails.
+	Execute(ctx context.Context, req Request, stdout, stderr io.Writer) error
+}
+
+// Command is an Executor which runs the deploy command of the environment on the local host.
+// It runs kubectl instead if the target of the environment is a Kubernetes deployment.
+var Command = Executor(commandExecutor{})
+
+type commandExecutor struct{}
+
+func (commandExecutor) Execute(ctx context.Context, req Request, stdout, stderr io.Writer) error {
+	if a := req.Action; a != nil {
+		if a.PerHost {
+			return ForEachHost(ctx, req.Environment, stdout, stderr, func(ctx context.Context, host string, stdout, stderr io.Writer) error {
+				return run(ctx, req, a.Args(), stdout, stderr, "GOSHIP_HOST="+host)
+			})
+		}
+		return run(ctx, req, a.Args(), stdout, stderr)
+	}
+	if req.Restart {
+		command, err := RestartArgs(req.Environment)
+		if err != nil {
+			return err
+		}
+		return run(ctx, req, command, stdout, stderr)
+	}
+	if req.Environment.PerHost {
+		return ForEachHost(ctx, req.Environment, stdout, stderr, func(ctx context.Context, host string, stdout, stderr io.Writer) error {
+			return run(ctx, req, Args(req.Environment), stdout, stderr, "GOSHIP_HOST="+host)
+		})
+	}
+	if !req.Environment.IsK8sDeployment() {
+		return run(ctx, req, Args(req.Environment), stdout, stderr)
+	}
+	commands, err := K8sArgs(req)
+	if err != nil {
+		return err
+	}
+	for _, command := range commands {
+		fmt.Fprintf(stdout, "$ %s\n", strings.Join(command, " "))
+		if err := run(ctx, req, command, stdout, stderr); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// run runs "command" on the local host with the environment variables of "req" and additional ones "env".
+func run(ctx context.Context, req Request, command []string, stdout, stderr io.Writer, env ...string) error {
+	cmd := exec.Command(command[0], command[1:]...)
+	if len(req.Env) > 0 || len(env) > 0 {
+		cmd.Env = append(append(os.Environ(), req.Env...), env...)
+	}
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+	prepareKill(cmd)
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+
+	done := make(chan error, 1)
+	go func() { done <- cmd.Wait() }()
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		if err := kill(cmd); err != nil {
+			logging.FromContext(ctx).Errorf("Failed to kill deploy command of %s-%s: %v", req.Project.Name, req.Environment.Name, err)
+		}
+		<-done
+		return ctx.Err()
+	}
+}
+
+// Args returns the deployment command for a given
+// environment as a string slice that has been split on spaces.
+func Args(e config.Environment) []string {
+	// TODO(yugui) better handling of shell escape
+	return strings.Split(e.Deploy, " ")
+}
+
+// RestartArgs returns the command which restarts the environment.
+func RestartArgs(e config.Environment) ([]string, error) {
+	switch {
+	case e.Restart != "":
+		return strings.Split(e.Restart, " "), nil
+	case e.IsK8sDeployment():
+		return []string{"kubectl", "rollout", "restart", "deployment/" + e.K8sDeployment, "--namespace=" + e.K8sNamespace}, nil
+	}
+	return nil, fmt.Errorf("restart command not configured in %s", e.Name)
+}
+
+// K8sArgs returns the commands which update the image of the Kubernetes deployment of the environment
+// and wait for its rollout.
+func K8sArgs(req Request) ([][]string, error) {
+	env := req.Environment
+	image, err := env.Image(req.Project.Name, string(req.To))
+	if err != nil {
+		return nil, err
+	}
+	container := env.K8sContainer
+	if container == "" {
+		container = "*"
+	}
+	deployment := "deployment/" + env.K8sDeployment
+	namespace := "--namespace=" + env.K8sNamespace
+	return [][]string{
+		{"kubectl", "set", "image", deployment, container + "=" + image, namespace},
+		{"kubectl", "rollout", "status", deployment, namespace},
+	}, nil
+}
@@ -0,0 +1,107 @@
+package deploy
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/gengo/goship/lib/config"
+	"golang.org/x/net/context"
+)
+
+func TestCommandCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	req := Request{Environment: config.Environment{Deploy: "sleep 60"}}
+	done := make(chan error, 1)
+	go func() {
+		var stdout, stderr bytes.Buffer
+		done <- Command.Execute(ctx, req, &stdout, &stderr)
+	}()
+	cancel()
+	select {
+	case err := <-done:
+		if err == nil {
+			t.Errorf("Command.Execute(ctx, %#v, stdout, stderr) succeeded after cancel; want failure", req)
+		}
+	case <-time.After(10 * time.Second):
+		t.Errorf("Command.Execute(ctx, %#v, stdout, stderr) did not return after cancel", req)
+	}
+}
+
+func TestCommandEnv(t *testing.T) {
+	req := Request{
+		Environment: config.Environment{Deploy: "printenv API_TOKEN GOSHIP_HOST", PerHost: true, Hosts: []string{"web1"}},
+		Env:         []string{"API_TOKEN=s3cr3t"},
+	}
+	var stdout, stderr bytes.Buffer
+	if err := Command.Execute(context.Background(), req, &stdout, &stderr); err != nil {
+		t.Fatalf("Command.Execute(ctx, %#v, stdout, stderr) failed with %v; want success", req, err)
+	}
+	if got, want := stdout.String(), "[web1] s3cr3t\n[web1] web1\n"; got != want {
+		t.Errorf("stdout = %q; want %q", got, want)
+	}
+}
+
+func TestK8sArgs(t *testing.T) {
+	req := Request{
+		Project: config.Project{Name: "app"},
+		Environment: config.Environment{
+			Name:          "staging",
+			K8sNamespace:  "web",
+			K8sDeployment: "app-server",
+			K8sImage:      "gcr.io/example/{{.Project}}:{{.Revision}}",
+		},
+		To: "abc123",
+	}
+	got, err := K8sArgs(req)
+	if err != nil {
+		t.Fatalf("K8sArgs(%#v) failed with %v; want success", req, err)
+	}
+	want := [][]string{
+		{"kubectl", "set", "image", "deployment/app-server", "*=gcr.io/example/app:abc123", "--namespace=web"},
+		{"kubectl", "rollout", "status", "deployment/app-server", "--namespace=web"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("K8sArgs(%#v) = %q; want %q", req, got, want)
+	}
+
+	req.Environment.K8sContainer = "server"
+	got, err = K8sArgs(req)
+	if err != nil {
+		t.Fatalf("K8sArgs(%#v) failed with %v; want success", req, err)
+	}
+	if got, want := got[0][4], "server=gcr.io/example/app:abc123"; got != want {
+		t.Errorf("K8sArgs(%#v)[0][4] = %q; want %q", req, got, want)
+	}
+}
+
+func TestRestartArgs(t *testing.T) {
+	for _, spec := range []struct {
+		env  config.Environment
+		want []string
+	}{
+		{
+			env:  config.Environment{Name: "staging", Restart: "sudo service app restart"},
+			want: []string{"sudo", "service", "app", "restart"},
+		},
+		{
+			env:  config.Environment{Name: "staging", K8sNamespace: "web", K8sDeployment: "app-server"},
+			want: []string{"kubectl", "rollout", "restart", "deployment/app-server", "--namespace=web"},
+		},
+	} {
+		got, err := RestartArgs(spec.env)
+		if err != nil {
+			t.Errorf("RestartArgs(%#v) failed with %v; want success", spec.env, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, spec.want) {
+			t.Errorf("RestartArgs(%#v) = %q; want %q", spec.env, got, spec.want)
+		}
+	}
+
+	env := config.Environment{Name: "staging", Deploy: "deploy-command"}
+	if _, err := RestartArgs(env); err == nil {
+		t.Errorf("RestartArgs(%#v) succeeded; want failure", env)
+	}
+}
@@ -0,0 +1,31 @@
+package deploy
+
+import (
+	"errors"
+
+	"golang.org/x/net/context"
+)
+
+// ErrAborted is returned when a paused deployment is aborted.
+var ErrAborted = errors.New("deployment aborted")
+
+// Gate waits for a manual confirmation of "message" before "step" of a deployment.
+// It returns nil to continue the deployment, or an error to stop it.
+type Gate func(ctx context.Context, step, message string) error
+
+type gateKey struct{}
+
+// WithGate returns a copy of "ctx" which carries "g".
+func WithGate(ctx context.Context, g Gate) context.Context {
+	return context.WithValue(ctx, gateKey{}, g)
+}
+
+// confirm waits for the Gate in "ctx".
+// Deployments without Gate fail because no one can confirm them.
+func confirm(ctx context.Context, step, message string) error {
+	g, ok := ctx.Value(gateKey{}).(Gate)
+	if !ok {
+		return errors.New("no one can confirm the deployment")
+	}
+	return g(ctx, step, message)
+}
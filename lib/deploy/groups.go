@@ -0,0 +1,65 @@
+package deploy
+
+import (
+	"fmt"
+	"io"
+
+	"golang.org/x/net/context"
+)
+
+// deployGroups runs the deployment described in "req" with "e" for each host group of the environment in order.
+// It stops at the first group which fails, and waits for the Gate in "ctx" before groups which pause.
+func deployGroups(ctx context.Context, e Executor, req Request, stdout, stderr io.Writer) error {
+	groups := req.Environment.HostGroups
+	hostCount := len(req.Environment.Hosts)
+	var offset int
+	for i, g := range groups {
+		// Host states are reported for all the hosts in all the groups.
+		gctx := ctx
+		if report, ok := ctx.Value(reporterKey{}).(Reporter); ok {
+			var before, after []HostStatus
+			for _, done := range groups[:i] {
+				before = append(before, hostStatuses(done.Hosts, HostSucceeded)...)
+			}
+			for _, pending := range groups[i+1:] {
+				after = append(after, hostStatuses(pending.Hosts, HostPending)...)
+			}
+			offset := offset
+			gctx = WithReporter(ctx, func(p Progress) {
+				if p.Hosts != nil {
+					p.Hosts = append(append(append([]HostStatus(nil), before...), p.Hosts...), after...)
+				}
+				if p.HostIndex > 0 {
+					p.HostIndex += offset
+				}
+				p.HostCount = hostCount
+				report(p)
+			})
+		}
+		if g.Pause != "" {
+			var hosts []HostStatus
+			for j, other := range groups {
+				state := HostPending
+				if j < i {
+					state = HostSucceeded
+				}
+				hosts = append(hosts, hostStatuses(other.Hosts, state)...)
+			}
+			Report(ctx, Progress{State: StatePaused, HostCount: hostCount, Hosts: hosts, Pause: g.Pause})
+			fmt.Fprintf(stdout, "Waiting for confirmation before host group %s: %s\n", g.Name, g.Pause)
+			if err := confirm(ctx, g.Name, g.Pause); err != nil {
+				fmt.Fprintf(stderr, "Deployment stopped before host group %s: %v\n", g.Name, err)
+				return err
+			}
+		}
+		fmt.Fprintf(stdout, "Deploying host group %s (%d/%d)\n", g.Name, i+1, len(groups))
+		greq := req
+		greq.Environment = req.Environment.Group(g)
+		if err := e.Execute(gctx, greq, stdout, stderr); err != nil {
+			fmt.Fprintf(stderr, "Host group %s failed; skipping the remaining groups\n", g.Name)
+			return err
+		}
+		offset += len(g.Hosts)
+	}
+	return nil
+}
@@ -0,0 +1,125 @@
+package deploy
+
+import (
+	"bytes"
+	"io"
+	"reflect"
+	"testing"
+
+	"github.com/gengo/goship/lib/config"
+	"golang.org/x/net/context"
+)
+
+func TestRollHostGroups(t *testing.T) {
+	env := config.Environment{
+		Hosts: []string{"w1", "w2", "web1"},
+		HostGroups: []config.HostGroup{
+			{Name: "workers", Hosts: []string{"w1", "w2"}},
+			{Name: "web", Hosts: []string{"web1"}},
+		},
+	}
+	for _, spec := range []struct {
+		fail    bool
+		restart bool
+		want    [][]string
+	}{
+		{want: [][]string{{"w1", "w2"}, {"web1"}}},
+		{fail: true, want: [][]string{{"w1", "w2"}}},
+		{restart: true, want: [][]string{{"w1", "w2", "web1"}}},
+	} {
+		e := &recordingExecutor{fail: spec.fail}
+		var stdout, stderr bytes.Buffer
+		err := Roll(context.Background(), e, HealthChecker{}, Request{Environment: env, Restart: spec.restart}, &stdout, &stderr)
+		if got := err != nil; got != spec.fail {
+			t.Errorf("Roll(ctx, e, req, stdout, stderr) failed = %v; want %v; err = %v", got, spec.fail, err)
+		}
+		if !reflect.DeepEqual(e.hosts, spec.want) {
+			t.Errorf("hosts deployed by Roll(ctx, e, req, stdout, stderr) = %q; want %q; fail = %v, restart = %v", e.hosts, spec.want, spec.fail, spec.restart)
+		}
+	}
+}
+
+func TestDeployGroupsProgress(t *testing.T) {
+	env := config.Environment{
+		Deploy:  "true",
+		PerHost: true,
+		Hosts:   []string{"w1", "web1"},
+		HostGroups: []config.HostGroup{
+			{Name: "workers", Hosts: []string{"w1"}},
+			{Name: "web", Hosts: []string{"web1"}},
+		},
+	}
+	var last Progress
+	ctx := WithReporter(context.Background(), func(p Progress) {
+		if p.Hosts != nil {
+			last = p
+		}
+	})
+	e := hostExecutor{}
+	var stdout, stderr bytes.Buffer
+	if err := Roll(ctx, e, HealthChecker{}, Request{Environment: env}, &stdout, &stderr); err != nil {
+		t.Fatalf("Roll(ctx, e, req, stdout, stderr) failed with %v; want success", err)
+	}
+	want := []HostStatus{{Host: "w1", State: HostSucceeded}, {Host: "web1", State: HostSucceeded}}
+	if !reflect.DeepEqual(last.Hosts, want) || last.HostCount != 2 || last.HostIndex != 2 {
+		t.Errorf("last progress = %#v; want hosts %#v at 2/2", last, want)
+	}
+}
+
+// hostExecutor succeeds on each host without running commands.
+type hostExecutor struct{}
+
+func (hostExecutor) Execute(ctx context.Context, req Request, stdout, stderr io.Writer) error {
+	return ForEachHost(ctx, req.Environment, stdout, stderr, func(ctx context.Context, host string, stdout, stderr io.Writer) error {
+		return nil
+	})
+}
+
+func TestDeployGroupsPause(t *testing.T) {
+	env := config.Environment{
+		Hosts: []string{"db1", "web1"},
+		HostGroups: []config.HostGroup{
+			{Name: "migrations", Hosts: []string{"db1"}},
+			{Name: "web", Hosts: []string{"web1"}, Pause: "Check the migrations"},
+		},
+	}
+	for _, spec := range []struct {
+		gate Gate
+		want [][]string
+	}{
+		{
+			gate: func(ctx context.Context, step, message string) error { return nil },
+			want: [][]string{{"db1"}, {"web1"}},
+		},
+		{
+			gate: func(ctx context.Context, step, message string) error { return ErrAborted },
+			want: [][]string{{"db1"}},
+		},
+		{
+			want: [][]string{{"db1"}},
+		},
+	} {
+		var paused []Progress
+		ctx := WithReporter(context.Background(), func(p Progress) {
+			if p.State == StatePaused {
+				paused = append(paused, p)
+			}
+		})
+		if spec.gate != nil {
+			ctx = WithGate(ctx, spec.gate)
+		}
+		e := &recordingExecutor{}
+		var stdout, stderr bytes.Buffer
+		err := Roll(ctx, e, HealthChecker{}, Request{Environment: env}, &stdout, &stderr)
+		if got, want := err != nil, len(spec.want) < 2; got != want {
+			t.Errorf("Roll(ctx, e, req, stdout, stderr) failed = %v; want %v; err = %v", got, want, err)
+		}
+		if !reflect.DeepEqual(e.hosts, spec.want) {
+			t.Errorf("hosts deployed by Roll(ctx, e, req, stdout, stderr) = %q; want %q", e.hosts, spec.want)
+		}
+		want := []HostStatus{{Host: "db1", State: HostSucceeded}, {Host: "web1", State: HostPending}}
+		if len(paused) != 1 || paused[0].Pause != "Check the migrations" || !reflect.DeepEqual(paused[0].Hosts, want) {
+			t.Errorf("paused progress = %#v; want one with hosts %#v", paused, want)
+		}
+	}
+}
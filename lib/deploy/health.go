@@ -0,0 +1,103 @@
+package deploy
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gengo/goship/lib/config"
+	"golang.org/x/net/context"
+)
+
+// healthClient is the HTTP client for health checks.
+var healthClient = &http.Client{Timeout: 10 * time.Second}
+
+// Remote runs commands on remote hosts.
+type Remote interface {
+	// Output runs "cmd" on "host" and returns its standard output.
+	// It returns an error if the command exits with non-zero status.
+	Output(ctx context.Context, host, cmd string) ([]byte, error)
+}
+
+// HealthChecker runs health checks of hosts.
+type HealthChecker struct {
+	// Remote runs remote commands of health checks.
+	// Health checks with remote commands fail if nil.
+	Remote Remote
+}
+
+// Check retries "c" on each of "hosts" until they all become healthy.
+// It returns an error if any host is still unhealthy after c.RetryTimeout().
+// The check runs once without a host if "hosts" is empty.
+// Results of attempts are written into "w".
+func (hc HealthChecker) Check(ctx context.Context, c config.HealthCheck, hosts []string, w io.Writer) error {
+	if len(hosts) == 0 {
+		hosts = []string{""}
+	}
+	deadline := time.Now().Add(c.RetryTimeout())
+	pending := hosts
+	for {
+		var unhealthy []string
+		for _, h := range pending {
+			label := "health check"
+			if h != "" {
+				label = fmt.Sprintf("[%s]", h)
+			}
+			if err := hc.probe(ctx, c, h); err != nil {
+				fmt.Fprintf(w, "%s unhealthy: %v\n", label, err)
+				unhealthy = append(unhealthy, h)
+				continue
+			}
+			fmt.Fprintf(w, "%s healthy\n", label)
+		}
+		if len(unhealthy) == 0 {
+			return nil
+		}
+		if time.Now().Add(c.RetryInterval()).After(deadline) {
+			return fmt.Errorf("unhealthy after %s: %s", c.RetryTimeout(), strings.Join(unhealthy, ", "))
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(c.RetryInterval()):
+		}
+		pending = unhealthy
+	}
+}
+
+// probe checks the health of "host" once.
+func (hc HealthChecker) probe(ctx context.Context, c config.HealthCheck, host string) error {
+	switch {
+	case c.Command != "":
+		return run(ctx, Request{}, strings.Split(c.Command, " "), ioutil.Discard, ioutil.Discard, "GOSHIP_HOST="+host)
+	case c.RemoteCommand != "":
+		if hc.Remote == nil {
+			return fmt.Errorf("remote commands are not available")
+		}
+		_, err := hc.Remote.Output(ctx, host, c.RemoteCommand)
+		return err
+	}
+	u, err := c.ProbeURL(host)
+	if err != nil {
+		return err
+	}
+	resp, err := healthClient.Get(u)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	code := resp.StatusCode
+	if c.ExpectedStatus != 0 {
+		if code != c.ExpectedStatus {
+			return fmt.Errorf("HTTP status %d from %s; want %d", code, u, c.ExpectedStatus)
+		}
+		return nil
+	}
+	if code < http.StatusOK || http.StatusMultipleChoices <= code {
+		return fmt.Errorf("HTTP status %d from %s", code, u)
+	}
+	return nil
+}
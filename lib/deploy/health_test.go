@@ -0,0 +1,60 @@
+package deploy
+
+import (
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gengo/goship/lib/config"
+	"golang.org/x/net/context"
+)
+
+// fakeRemote succeeds only on the healthy hosts.
+type fakeRemote struct {
+	healthy map[string]bool
+}
+
+func (r fakeRemote) Output(ctx context.Context, host, cmd string) ([]byte, error) {
+	if !r.healthy[host] {
+		return nil, errors.New("exit status 1")
+	}
+	return nil, nil
+}
+
+func TestHealthCheckerExpectedStatus(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	for _, spec := range []struct {
+		status  int
+		healthy bool
+	}{
+		{status: 0, healthy: true},
+		{status: http.StatusNoContent, healthy: true},
+		{status: http.StatusOK, healthy: false},
+	} {
+		c := config.HealthCheck{URL: srv.URL, ExpectedStatus: spec.status, Interval: "1ms", Timeout: "1ms"}
+		err := HealthChecker{}.Check(context.Background(), c, nil, ioutil.Discard)
+		if got := err == nil; got != spec.healthy {
+			t.Errorf("Check(ctx, %#v, nil, w) succeeded = %v; want %v; err = %v", c, got, spec.healthy, err)
+		}
+	}
+}
+
+func TestHealthCheckerRemoteCommand(t *testing.T) {
+	c := config.HealthCheck{RemoteCommand: "service app status", Interval: "1ms", Timeout: "1ms"}
+	hc := HealthChecker{Remote: fakeRemote{healthy: map[string]bool{"host1": true}}}
+	if err := hc.Check(context.Background(), c, []string{"host1"}, ioutil.Discard); err != nil {
+		t.Errorf("Check(ctx, %#v, [host1], w) failed with %v; want success", c, err)
+	}
+	if err := hc.Check(context.Background(), c, []string{"host1", "host2"}, ioutil.Discard); err == nil {
+		t.Errorf("Check(ctx, %#v, [host1 host2], w) succeeded; want failure", c)
+	}
+	if err := (HealthChecker{}).Check(context.Background(), c, []string{"host1"}, ioutil.Discard); err == nil {
+		t.Errorf("Check(ctx, %#v, [host1], w) without Remote succeeded; want failure", c)
+	}
+}
@@ -0,0 +1,185 @@
+package deploy
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+
+	"github.com/gengo/goship/lib/config"
+	"golang.org/x/net/context"
+)
+
+// HostState is a phase of a deployment on a single host.
+type HostState string
+
+const (
+	// HostPending means the host is waiting for its turn.
+	HostPending = HostState("pending")
+	// HostRunning means the deployment is running on the host.
+	HostRunning = HostState("running")
+	// HostSucceeded means the deployment on the host has succeeded.
+	HostSucceeded = HostState("succeeded")
+	// HostFailed means the deployment on the host has failed.
+	HostFailed = HostState("failed")
+	// HostSkipped means the host was not deployed because another host failed.
+	HostSkipped = HostState("skipped")
+)
+
+// HostStatus is the state of a deployment on a host.
+type HostStatus struct {
+	Host  string
+	State HostState
+}
+
+// HostFunc deploys to "host".
+// It writes outputs of the deployment into "stdout" and "stderr", and returns an error if the deployment fails.
+type HostFunc func(ctx context.Context, host string, stdout, stderr io.Writer) error
+
+// ForEachHost runs "fn" for each host of "env".
+// It runs up to env.Parallelism hosts concurrently and follows env.FailurePolicy on failures.
+// Lines written by "fn" are prefixed with the host name and reported per host through Report.
+func ForEachHost(ctx context.Context, env config.Environment, stdout, stderr io.Writer, fn HostFunc) error {
+	hosts := env.Hosts
+	if len(hosts) == 0 {
+		hosts = []string{"localhost"}
+	}
+	parallelism := env.Parallelism
+	if parallelism <= 0 {
+		parallelism = 1
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var (
+		mu       sync.Mutex
+		statuses = make([]HostStatus, len(hosts))
+		failed   []string
+		firstErr error
+	)
+	for i, h := range hosts {
+		statuses[i] = HostStatus{Host: h, State: HostPending}
+	}
+	// update sets the state of the i-th host and reports the progress.
+	update := func(i int, s HostState) {
+		mu.Lock()
+		statuses[i].State = s
+		snapshot := append([]HostStatus(nil), statuses...)
+		mu.Unlock()
+		Report(ctx, Progress{State: StateDeploying, Host: hosts[i], HostIndex: i + 1, HostCount: len(hosts), Hosts: snapshot})
+	}
+	Report(ctx, Progress{State: StateDeploying, HostCount: len(hosts), Hosts: append([]HostStatus(nil), statuses...)})
+
+	var (
+		outMu sync.Mutex
+		wg    sync.WaitGroup
+		sem   = make(chan struct{}, parallelism)
+	)
+	for i, h := range hosts {
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+		}
+		if ctx.Err() != nil {
+			update(i, HostSkipped)
+			continue
+		}
+		wg.Add(1)
+		go func(i int, h string) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			update(i, HostRunning)
+
+			prefix := hostPrefix(h)
+			hout := &prefixWriter{mu: &outMu, w: stdout, prefix: prefix}
+			herr := &prefixWriter{mu: &outMu, w: stderr, prefix: prefix}
+			err := fn(ctx, h, hout, herr)
+			hout.Flush()
+			herr.Flush()
+			if err == nil {
+				update(i, HostSucceeded)
+				return
+			}
+			update(i, HostFailed)
+			mu.Lock()
+			failed = append(failed, h)
+			if firstErr == nil {
+				firstErr = err
+			}
+			mu.Unlock()
+			if env.FailurePolicy != config.ContinueOnError {
+				cancel()
+			}
+		}(i, h)
+	}
+	wg.Wait()
+
+	switch {
+	case firstErr == nil:
+		return ctx.Err()
+	case len(hosts) == 1:
+		return firstErr
+	case env.FailurePolicy == config.ContinueOnError:
+		return fmt.Errorf("deployment failed on %d of %d hosts (%s): %v", len(failed), len(hosts), strings.Join(failed, ", "), firstErr)
+	}
+	return fmt.Errorf("deployment failed on %s: %v", failed[0], firstErr)
+}
+
+// hostPrefix returns the prefix of the lines written for "host" by ForEachHost.
+func hostPrefix(host string) string {
+	return fmt.Sprintf("[%s] ", host)
+}
+
+// SplitHost returns the host of "line" written by ForEachHost and the rest of the line.
+// It returns an empty host and "line" as is if the line is not written for a host.
+func SplitHost(line string) (host, text string) {
+	if !strings.HasPrefix(line, "[") {
+		return "", line
+	}
+	i := strings.Index(line, "] ")
+	if i < 0 || strings.ContainsAny(line[1:i], " []") {
+		return "", line
+	}
+	return line[1:i], line[i+2:]
+}
+
+// prefixWriter writes each line with "prefix" into "w".
+// Lines are written atomically under "mu" so that lines from concurrent hosts do not mix.
+type prefixWriter struct {
+	mu     *sync.Mutex
+	w      io.Writer
+	prefix string
+	buf    []byte
+}
+
+func (p *prefixWriter) Write(b []byte) (int, error) {
+	p.buf = append(p.buf, b...)
+	for {
+		i := bytes.IndexByte(p.buf, '\n')
+		if i < 0 {
+			return len(b), nil
+		}
+		if err := p.writeLine(p.buf[:i+1]); err != nil {
+			return 0, err
+		}
+		p.buf = p.buf[i+1:]
+	}
+}
+
+// Flush writes the last incomplete line if any.
+func (p *prefixWriter) Flush() error {
+	if len(p.buf) == 0 {
+		return nil
+	}
+	err := p.writeLine(append(p.buf, '\n'))
+	p.buf = nil
+	return err
+}
+
+func (p *prefixWriter) writeLine(l []byte) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	_, err := io.WriteString(p.w, p.prefix+string(l))
+	return err
+}
@@ -0,0 +1,152 @@
+package deploy
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gengo/goship/lib/config"
+	"golang.org/x/net/context"
+)
+
+func TestForEachHost(t *testing.T) {
+	hosts := []string{"host1", "host2", "host3", "host4"}
+	for _, spec := range []struct {
+		parallelism int
+		policy      config.FailurePolicy
+		// fail is the host which fails, if any.
+		fail    string
+		wantErr bool
+		want    map[string]HostState
+	}{
+		{
+			want: map[string]HostState{"host1": HostSucceeded, "host2": HostSucceeded, "host3": HostSucceeded, "host4": HostSucceeded},
+		},
+		{
+			parallelism: 3,
+			want:        map[string]HostState{"host1": HostSucceeded, "host2": HostSucceeded, "host3": HostSucceeded, "host4": HostSucceeded},
+		},
+		{
+			fail:    "host2",
+			wantErr: true,
+			want:    map[string]HostState{"host1": HostSucceeded, "host2": HostFailed, "host3": HostSkipped, "host4": HostSkipped},
+		},
+		{
+			policy:  config.ContinueOnError,
+			fail:    "host2",
+			wantErr: true,
+			want:    map[string]HostState{"host1": HostSucceeded, "host2": HostFailed, "host3": HostSucceeded, "host4": HostSucceeded},
+		},
+	} {
+		env := config.Environment{Hosts: hosts, Parallelism: spec.parallelism, FailurePolicy: spec.policy}
+		var (
+			mu   sync.Mutex
+			last []HostStatus
+		)
+		ctx := WithReporter(context.Background(), func(p Progress) {
+			mu.Lock()
+			defer mu.Unlock()
+			last = p.Hosts
+		})
+		var stdout, stderr bytes.Buffer
+		err := ForEachHost(ctx, env, &stdout, &stderr, func(ctx context.Context, host string, stdout, stderr io.Writer) error {
+			fmt.Fprintln(stdout, "deploying")
+			if host == spec.fail {
+				return errors.New("failed")
+			}
+			return nil
+		})
+		if got := err != nil; got != spec.wantErr {
+			t.Errorf("ForEachHost(ctx, %#v, stdout, stderr, fn) failed = %v; want %v; err = %v", env, got, spec.wantErr, err)
+		}
+		got := make(map[string]HostState)
+		for _, s := range last {
+			got[s.Host] = s.State
+		}
+		if !reflect.DeepEqual(got, spec.want) {
+			t.Errorf("host states after ForEachHost(ctx, %#v, stdout, stderr, fn) = %v; want %v", env, got, spec.want)
+		}
+
+		lines := strings.Split(strings.TrimSpace(stdout.String()), "\n")
+		sort.Strings(lines)
+		var want []string
+		for h, s := range spec.want {
+			if s != HostSkipped {
+				want = append(want, fmt.Sprintf("[%s] deploying", h))
+			}
+		}
+		sort.Strings(want)
+		if !reflect.DeepEqual(lines, want) {
+			t.Errorf("stdout of ForEachHost(ctx, %#v, stdout, stderr, fn) = %q; want %q", env, lines, want)
+		}
+	}
+}
+
+func TestForEachHostParallelism(t *testing.T) {
+	env := config.Environment{Hosts: []string{"host1", "host2", "host3", "host4", "host5"}, Parallelism: 2}
+	var (
+		mu          sync.Mutex
+		running, mx int
+	)
+	release := make(chan struct{})
+	done := make(chan error, 1)
+	go func() {
+		var stdout, stderr bytes.Buffer
+		done <- ForEachHost(context.Background(), env, &stdout, &stderr, func(ctx context.Context, host string, stdout, stderr io.Writer) error {
+			mu.Lock()
+			running++
+			if running > mx {
+				mx = running
+			}
+			mu.Unlock()
+			<-release
+			mu.Lock()
+			running--
+			mu.Unlock()
+			return nil
+		})
+	}()
+	// Waits for the hosts to fill the parallelism before letting them finish.
+	for deadline := time.Now().Add(10 * time.Second); time.Now().Before(deadline); time.Sleep(time.Millisecond) {
+		mu.Lock()
+		n := running
+		mu.Unlock()
+		if n == env.Parallelism {
+			break
+		}
+	}
+	for range env.Hosts {
+		release <- struct{}{}
+	}
+	if err := <-done; err != nil {
+		t.Fatalf("ForEachHost(ctx, %#v, stdout, stderr, fn) failed with %v; want success", env, err)
+	}
+	if mx != env.Parallelism {
+		t.Errorf("max number of concurrent hosts = %d; want %d", mx, env.Parallelism)
+	}
+}
+
+func TestSplitHost(t *testing.T) {
+	for _, spec := range []struct {
+		line       string
+		host, text string
+	}{
+		{line: "[web1.example.com] pulling", host: "web1.example.com", text: "pulling"},
+		{line: "[web1]  indented", host: "web1", text: " indented"},
+		{line: "Deploying web1", text: "Deploying web1"},
+		{line: "[a b] c", text: "[a b] c"},
+		{line: "[web1]", text: "[web1]"},
+	} {
+		host, text := SplitHost(spec.line)
+		if host != spec.host || text != spec.text {
+			t.Errorf("SplitHost(%q) = %q, %q; want %q, %q", spec.line, host, text, spec.host, spec.text)
+		}
+	}
+}
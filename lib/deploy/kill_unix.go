@@ -0,0 +1,19 @@
+//go:build !windows
+// +build !windows
+
+package deploy
+
+import (
+	"os/exec"
+	"syscall"
+)
+
+// prepareKill makes "cmd" run in its own process group so that kill can terminate its descendants, e.g. ssh, too.
+func prepareKill(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+}
+
+// kill terminates the process group of "cmd".
+func kill(cmd *exec.Cmd) error {
+	return syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+}
@@ -0,0 +1,12 @@
+package deploy
+
+import (
+	"os/exec"
+)
+
+func prepareKill(cmd *exec.Cmd) {}
+
+// kill terminates "cmd". Descendants of the process are not terminated.
+func kill(cmd *exec.Cmd) error {
+	return cmd.Process.Kill()
+}
@@ -0,0 +1,35 @@
+package deploy
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// maxLabels is the maximum number of labels of a deployment.
+const maxLabels = 10
+
+var labelPattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]{0,31}$`)
+
+// ParseLabels parses a comma-separated list of labels of a deployment, e.g. "hotfix, schema-change".
+// Labels are lower-cased and deduplicated in the order of appearance.
+// Each label must consist of up to 32 letters, digits, '-' and '_'.
+func ParseLabels(s string) ([]string, error) {
+	var labels []string
+	seen := make(map[string]bool)
+	for _, l := range strings.Split(s, ",") {
+		l = strings.ToLower(strings.TrimSpace(l))
+		if l == "" || seen[l] {
+			continue
+		}
+		if !labelPattern.MatchString(l) {
+			return nil, fmt.Errorf("invalid label %q", l)
+		}
+		seen[l] = true
+		labels = append(labels, l)
+	}
+	if len(labels) > maxLabels {
+		return nil, fmt.Errorf("too many labels: %d > %d", len(labels), maxLabels)
+	}
+	return labels, nil
+}
@@ -0,0 +1,38 @@
+package deploy
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseLabels(t *testing.T) {
+	for _, spec := range []struct {
+		s    string
+		want []string
+	}{
+		{s: "", want: nil},
+		{s: "hotfix", want: []string{"hotfix"}},
+		{s: " Hotfix, schema-change,,hotfix ", want: []string{"hotfix", "schema-change"}},
+	} {
+		got, err := ParseLabels(spec.s)
+		if err != nil {
+			t.Errorf("ParseLabels(%q) failed with %v; want success", spec.s, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, spec.want) {
+			t.Errorf("ParseLabels(%q) = %q; want %q", spec.s, got, spec.want)
+		}
+	}
+	for _, s := range []string{
+		"hot fix",
+		"-hotfix",
+		"<script>",
+		strings.Repeat("a", 33),
+		"a,b,c,d,e,f,g,h,i,j,k",
+	} {
+		if got, err := ParseLabels(s); err == nil {
+			t.Errorf("ParseLabels(%q) = %q; want failure", s, got)
+		}
+	}
+}
@@ -0,0 +1,121 @@
+package deploy
+
+import (
+	"sync"
+)
+
+// maxFinishedOutputs is the number of finished deployments whose output is kept in Outputs.
+const maxFinishedOutputs = 20
+
+// Output is the output of a deployment, line by line.
+// It also keeps the host of each line written by ForEachHost so that clients can follow the output of a single host.
+type Output struct {
+	Project     string
+	Environment string
+
+	mu    sync.Mutex
+	lines []string
+	// hosts are the hosts of the lines, or empty strings for lines not written for a host.
+	hosts []string
+	done  bool
+	// changed is closed when a line is added or the deployment finishes.
+	changed chan struct{}
+}
+
+// Read returns the lines after the first "offset" lines.
+// It also returns whether the deployment has finished, and a channel which is closed when more lines are added or the deployment finishes.
+func (o *Output) Read(offset int) (lines []string, done bool, wait <-chan struct{}) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if offset < len(o.lines) {
+		lines = append([]string(nil), o.lines[offset:]...)
+	}
+	return lines, o.done, o.changed
+}
+
+// ReadHost is the same as Read, but returns only the lines written for "host" without their prefixes.
+// "offset" counts the lines of the host.
+func (o *Output) ReadHost(host string, offset int) (lines []string, done bool, wait <-chan struct{}) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	n := 0
+	for i, h := range o.hosts {
+		if h != host {
+			continue
+		}
+		if n >= offset {
+			_, text := SplitHost(o.lines[i])
+			lines = append(lines, text)
+		}
+		n++
+	}
+	return lines, o.done, o.changed
+}
+
+// append adds "line" and returns its line number, starting from 1. It returns 0 if the deployment has finished.
+func (o *Output) append(line string) int {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if o.done {
+		return 0
+	}
+	host, _ := SplitHost(line)
+	o.lines = append(o.lines, line)
+	o.hosts = append(o.hosts, host)
+	close(o.changed)
+	o.changed = make(chan struct{})
+	return len(o.lines)
+}
+
+func (o *Output) finish() {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if o.done {
+		return
+	}
+	o.done = true
+	close(o.changed)
+}
+
+// Outputs keeps the output of running deployments and of the latest maxFinishedOutputs finished ones so that clients can follow them.
+type Outputs struct {
+	mu       sync.Mutex
+	outputs  map[string]*Output
+	finished []string
+}
+
+// NewOutputs returns a new empty Outputs.
+func NewOutputs() *Outputs {
+	return &Outputs{outputs: make(map[string]*Output)}
+}
+
+// Start registers the output of "run".
+// The caller must call "finish" after writing all the lines with "write", which returns the line number of each line.
+func (o *Outputs) Start(run Run) (write func(line string) int, finish func()) {
+	out := &Output{Project: run.Project, Environment: run.Environment, changed: make(chan struct{})}
+	o.mu.Lock()
+	o.outputs[run.ID] = out
+	o.mu.Unlock()
+	return out.append, func() {
+		out.finish()
+		o.mu.Lock()
+		defer o.mu.Unlock()
+		o.finished = append(o.finished, run.ID)
+		if len(o.finished) > maxFinishedOutputs {
+			delete(o.outputs, o.finished[0])
+			o.finished = o.finished[1:]
+		}
+	}
+}
+
+// Get returns the output of the deployment identified by "id".
+// It returns ErrNoSuchDeploy if the output is not kept.
+func (o *Outputs) Get(id string) (*Output, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	out, ok := o.outputs[id]
+	if !ok {
+		return nil, ErrNoSuchDeploy
+	}
+	return out, nil
+}
@@ -0,0 +1,101 @@
+package deploy
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+func TestOutputsRead(t *testing.T) {
+	o := NewOutputs()
+	write, finish := o.Start(Run{ID: "1", Project: "proj", Environment: "env"})
+	out, err := o.Get("1")
+	if err != nil {
+		t.Fatalf("o.Get(%q) failed with %v; want success", "1", err)
+	}
+	if got, want := out.Project, "proj"; got != want {
+		t.Errorf("out.Project = %q; want %q", got, want)
+	}
+
+	lines, done, wait := out.Read(0)
+	if len(lines) != 0 || done {
+		t.Errorf("out.Read(0) = %q, %t; want no lines, false", lines, done)
+	}
+	write("line 1")
+	select {
+	case <-wait:
+	default:
+		t.Errorf("wait is not closed after writing a line")
+	}
+	if got, want := write("line 2"), 2; got != want {
+		t.Errorf("write(%q) = %d; want %d", "line 2", got, want)
+	}
+
+	lines, done, wait = out.Read(1)
+	if got, want := lines, []string{"line 2"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("out.Read(1) = %q; want %q", got, want)
+	}
+	if done {
+		t.Errorf("out.Read(1) returned done before finish")
+	}
+	if lines, _, _ := out.Read(5); len(lines) != 0 {
+		t.Errorf("out.Read(5) = %q; want no lines", lines)
+	}
+
+	finish()
+	select {
+	case <-wait:
+	default:
+		t.Errorf("wait is not closed after finish")
+	}
+	write("ignored")
+	lines, done, _ = out.Read(0)
+	if got, want := lines, []string{"line 1", "line 2"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("out.Read(0) = %q; want %q after finish", got, want)
+	}
+	if !done {
+		t.Errorf("out.Read(0) returned not done after finish")
+	}
+}
+
+func TestOutputReadHost(t *testing.T) {
+	o := NewOutputs()
+	write, finish := o.Start(Run{ID: "1"})
+	for _, l := range []string{"Deploying", "[web1] pulling", "[web2] pulling", "[web1] restarting", "[INFO] done"} {
+		write(l)
+	}
+	finish()
+	out, err := o.Get("1")
+	if err != nil {
+		t.Fatalf("o.Get(%q) failed with %v; want success", "1", err)
+	}
+	for _, spec := range []struct {
+		host   string
+		offset int
+		want   []string
+	}{
+		{host: "web1", want: []string{"pulling", "restarting"}},
+		{host: "web1", offset: 1, want: []string{"restarting"}},
+		{host: "web2", want: []string{"pulling"}},
+		{host: "web3"},
+	} {
+		lines, done, _ := out.ReadHost(spec.host, spec.offset)
+		if !reflect.DeepEqual(lines, spec.want) || !done {
+			t.Errorf("out.ReadHost(%q, %d) = %q, %t; want %q, true", spec.host, spec.offset, lines, done, spec.want)
+		}
+	}
+}
+
+func TestOutputsKeepsLatestFinished(t *testing.T) {
+	o := NewOutputs()
+	for i := 0; i < maxFinishedOutputs+1; i++ {
+		_, finish := o.Start(Run{ID: fmt.Sprint(i)})
+		finish()
+	}
+	if _, err := o.Get("0"); err != ErrNoSuchDeploy {
+		t.Errorf("o.Get(%q) returned %v; want %v", "0", err, ErrNoSuchDeploy)
+	}
+	if _, err := o.Get("1"); err != nil {
+		t.Errorf("o.Get(%q) failed with %v; want success", "1", err)
+	}
+}
@@ -0,0 +1,101 @@
+package deploy
+
+import "github.com/gengo/goship/lib/config"
+
+// Step is a command which a deployment runs.
+type Step struct {
+	// Phase is "canary" or "rest" in a rollout with canary hosts, or empty otherwise.
+	Phase string `json:"phase,omitempty"`
+	// Group is the name of the host group which the command deploys to, if the environment has host groups.
+	Group string `json:"group,omitempty"`
+	// Pause is what to confirm manually before the step. It is set only on the first step of a host group which pauses.
+	Pause string `json:"pause,omitempty"`
+	// Host is the host which the command deploys to, given in GOSHIP_HOST.
+	// It is empty if the command runs once for the whole environment.
+	Host    string   `json:"host,omitempty"`
+	Command []string `json:"command"`
+}
+
+// Plan returns the commands which Roll with Command would run for "req", in order, without running them.
+// Commands for different hosts may run concurrently depending on the parallelism of the environment.
+func Plan(req Request) ([]Step, error) {
+	if len(req.Environment.HostGroups) > 0 && req.Deploys() {
+		var steps []Step
+		for _, g := range req.Environment.HostGroups {
+			greq := req
+			greq.Environment = req.Environment.Group(g)
+			gsteps, err := plan(greq, "")
+			if err != nil {
+				return nil, err
+			}
+			for i, s := range gsteps {
+				s.Group = g.Name
+				if i == 0 {
+					s.Pause = g.Pause
+				}
+				steps = append(steps, s)
+			}
+		}
+		return steps, nil
+	}
+	r, hosts := req.Environment.Rollout, req.Environment.Hosts
+	if !req.Deploys() || r == nil || r.Canary >= len(hosts) {
+		return plan(req, "")
+	}
+	creq, rreq := req, req
+	creq.Environment.Hosts, rreq.Environment.Hosts = hosts[:r.Canary], hosts[r.Canary:]
+	canary, err := plan(creq, "canary")
+	if err != nil {
+		return nil, err
+	}
+	rest, err := plan(rreq, "rest")
+	if err != nil {
+		return nil, err
+	}
+	return append(canary, rest...), nil
+}
+
+// plan returns the commands which Command.Execute would run for "req".
+func plan(req Request, phase string) ([]Step, error) {
+	if a := req.Action; a != nil {
+		if !a.PerHost {
+			return []Step{{Phase: phase, Command: a.Args()}}, nil
+		}
+		return perHost(req.Environment, phase, a.Args()), nil
+	}
+	if req.Restart {
+		command, err := RestartArgs(req.Environment)
+		if err != nil {
+			return nil, err
+		}
+		return []Step{{Phase: phase, Command: command}}, nil
+	}
+	if req.Environment.PerHost {
+		return perHost(req.Environment, phase, Args(req.Environment)), nil
+	}
+	if !req.Environment.IsK8sDeployment() {
+		return []Step{{Phase: phase, Command: Args(req.Environment)}}, nil
+	}
+	commands, err := K8sArgs(req)
+	if err != nil {
+		return nil, err
+	}
+	var steps []Step
+	for _, command := range commands {
+		steps = append(steps, Step{Phase: phase, Command: command})
+	}
+	return steps, nil
+}
+
+// perHost returns the steps which run "command" once for each host of "env".
+func perHost(env config.Environment, phase string, command []string) []Step {
+	hosts := env.Hosts
+	if len(hosts) == 0 {
+		hosts = []string{"localhost"}
+	}
+	var steps []Step
+	for _, h := range hosts {
+		steps = append(steps, Step{Phase: phase, Host: h, Command: command})
+	}
+	return steps
+}
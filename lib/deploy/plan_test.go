@@ -0,0 +1,107 @@
+package deploy
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/gengo/goship/lib/config"
+)
+
+func TestPlan(t *testing.T) {
+	for _, spec := range []struct {
+		req  Request
+		want []Step
+	}{
+		{
+			req: Request{Environment: config.Environment{Deploy: "deploy.sh staging"}},
+			want: []Step{
+				{Command: []string{"deploy.sh", "staging"}},
+			},
+		},
+		{
+			req: Request{Environment: config.Environment{Deploy: "deploy.sh", PerHost: true, Hosts: []string{"a", "b"}}},
+			want: []Step{
+				{Host: "a", Command: []string{"deploy.sh"}},
+				{Host: "b", Command: []string{"deploy.sh"}},
+			},
+		},
+		{
+			req: Request{Environment: config.Environment{
+				Deploy:  "deploy.sh",
+				PerHost: true,
+				Hosts:   []string{"a", "b", "c"},
+				Rollout: &config.Rollout{Canary: 1},
+			}},
+			want: []Step{
+				{Phase: "canary", Host: "a", Command: []string{"deploy.sh"}},
+				{Phase: "rest", Host: "b", Command: []string{"deploy.sh"}},
+				{Phase: "rest", Host: "c", Command: []string{"deploy.sh"}},
+			},
+		},
+		{
+			req: Request{Environment: config.Environment{
+				Deploy:  "deploy.sh",
+				PerHost: true,
+				Hosts:   []string{"w1", "a", "b"},
+				HostGroups: []config.HostGroup{
+					{Name: "workers", Hosts: []string{"w1"}, Deploy: "workers.sh"},
+					{Name: "web", Hosts: []string{"a", "b"}, Pause: "check workers"},
+				},
+			}},
+			want: []Step{
+				{Group: "workers", Host: "w1", Command: []string{"workers.sh"}},
+				{Group: "web", Pause: "check workers", Host: "a", Command: []string{"deploy.sh"}},
+				{Group: "web", Host: "b", Command: []string{"deploy.sh"}},
+			},
+		},
+		{
+			req: Request{Environment: config.Environment{Restart: "restart.sh now", PerHost: true, Hosts: []string{"a"}}, Restart: true},
+			want: []Step{
+				{Command: []string{"restart.sh", "now"}},
+			},
+		},
+		{
+			req: Request{
+				Environment: config.Environment{Deploy: "deploy.sh", PerHost: true, Hosts: []string{"a", "b"}, Rollout: &config.Rollout{Canary: 1}},
+				Action:      &config.Action{Name: "clear-cache", Command: "clear-cache.sh --all"},
+			},
+			want: []Step{
+				{Command: []string{"clear-cache.sh", "--all"}},
+			},
+		},
+		{
+			req: Request{
+				Environment: config.Environment{Deploy: "deploy.sh", Hosts: []string{"a", "b"}},
+				Action:      &config.Action{Name: "restart", Command: "restart.sh", PerHost: true},
+			},
+			want: []Step{
+				{Host: "a", Command: []string{"restart.sh"}},
+				{Host: "b", Command: []string{"restart.sh"}},
+			},
+		},
+		{
+			req: Request{
+				Project: config.Project{Name: "proj"},
+				Environment: config.Environment{
+					K8sNamespace:  "default",
+					K8sDeployment: "web",
+					K8sImage:      "gcr.io/example/web:{{.Revision}}",
+				},
+				To: "abc123",
+			},
+			want: []Step{
+				{Command: []string{"kubectl", "set", "image", "deployment/web", "*=gcr.io/example/web:abc123", "--namespace=default"}},
+				{Command: []string{"kubectl", "rollout", "status", "deployment/web", "--namespace=default"}},
+			},
+		},
+	} {
+		got, err := Plan(spec.req)
+		if err != nil {
+			t.Errorf("Plan(%#v) failed with %v; want success", spec.req, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, spec.want) {
+			t.Errorf("Plan(%#v) = %#v; want %#v", spec.req, got, spec.want)
+		}
+	}
+}
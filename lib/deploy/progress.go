@@ -0,0 +1,139 @@
+package deploy
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"golang.org/x/net/context"
+)
+
+// State is a discrete phase of a deployment.
+type State string
+
+const (
+	// StateQueued means the deployment is waiting for preceding deployments in the environment.
+	StateQueued = State("queued")
+	// StateRejected means the deployment is rejected because another deployment is in progress.
+	StateRejected = State("rejected")
+	// StatePreflight means the deployment is being prepared.
+	StatePreflight = State("preflight")
+	// StateCanary means the deployment is running on the canary hosts or checking their health.
+	StateCanary = State("canary")
+	// StateDeploying means the deployment is running on the hosts of the environment.
+	StateDeploying = State("deploying")
+	// StatePaused means the deployment is waiting for a manual confirmation before the next host group.
+	StatePaused = State("paused")
+	// StateVerifying means the deployed revision is being verified.
+	StateVerifying = State("verifying")
+	// StateRollingBack means the deployment has failed its health check and the previous revision is being deployed again.
+	StateRollingBack = State("rolling_back")
+	// StateDone means the deployment has succeeded.
+	StateDone = State("done")
+	// StateFailed means the deployment has failed.
+	StateFailed = State("failed")
+)
+
+// Progress is a snapshot of the progress of a deployment.
+type Progress struct {
+	Project     string
+	Environment string
+	State       State
+	// DeployID identifies the running deployment in Running, if started.
+	DeployID string `json:",omitempty"`
+	// Owner is the name of the user responsible for the deployment, if started.
+	Owner string `json:",omitempty"`
+	// QueuePosition is the number of deployments ahead in StateQueued.
+	QueuePosition int `json:",omitempty"`
+	// Host is the host being deployed in StateDeploying, if known.
+	Host string `json:",omitempty"`
+	// HostIndex is the 1-origin index of Host in StateDeploying, or zero if unknown.
+	HostIndex int `json:",omitempty"`
+	// HostCount is the number of hosts in the environment.
+	HostCount int `json:",omitempty"`
+	// Hosts are the states of the deployment on each host, if known.
+	Hosts []HostStatus `json:",omitempty"`
+	// Pause describes what to confirm in StatePaused.
+	Pause string `json:",omitempty"`
+	// Time is when the deployment entered the state.
+	Time time.Time
+}
+
+// Reporter receives progress of a deployment.
+type Reporter func(Progress)
+
+type reporterKey struct{}
+
+// WithReporter returns a copy of "ctx" which carries "r".
+func WithReporter(ctx context.Context, r Reporter) context.Context {
+	return context.WithValue(ctx, reporterKey{}, r)
+}
+
+// Report sends "p" to the Reporter in "ctx" if any.
+// Executors call this function to report their progress in more detail.
+func Report(ctx context.Context, p Progress) {
+	if r, ok := ctx.Value(reporterKey{}).(Reporter); ok {
+		r(p)
+	}
+}
+
+// Tracker keeps the latest progress of deployments per project and environment.
+type Tracker struct {
+	mu      sync.Mutex
+	current map[string]Progress
+}
+
+// NewTracker returns a new empty Tracker.
+func NewTracker() *Tracker {
+	return &Tracker{current: make(map[string]Progress)}
+}
+
+// Update records "p" as the latest progress in "env" of "proj".
+// It returns "p" with its Project, Environment and Time filled.
+// Hosts are carried over from the previous progress of the same deployment if "p" does not have them.
+func (t *Tracker) Update(proj, env string, p Progress) Progress {
+	p.Project, p.Environment = proj, env
+	if p.Time.IsZero() {
+		p.Time = time.Now()
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	key := queueKey(proj, env)
+	if prev, ok := t.current[key]; ok && p.Hosts == nil && p.DeployID != "" && prev.DeployID == p.DeployID {
+		p.Hosts = prev.Hosts
+	}
+	t.current[key] = p
+	return p
+}
+
+// Get returns the latest progress in "env" of "proj".
+// It returns false if no deployment has been tracked in the environment.
+func (t *Tracker) Get(proj, env string) (Progress, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	p, ok := t.current[queueKey(proj, env)]
+	return p, ok
+}
+
+// All returns the latest progresses in all environments, sorted by project and environment.
+func (t *Tracker) All() []Progress {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	var ps []Progress
+	for _, p := range t.current {
+		ps = append(ps, p)
+	}
+	sort.Sort(byProjectAndEnvironment(ps))
+	return ps
+}
+
+type byProjectAndEnvironment []Progress
+
+func (ps byProjectAndEnvironment) Len() int      { return len(ps) }
+func (ps byProjectAndEnvironment) Swap(i, j int) { ps[i], ps[j] = ps[j], ps[i] }
+func (ps byProjectAndEnvironment) Less(i, j int) bool {
+	if ps[i].Project != ps[j].Project {
+		return ps[i].Project < ps[j].Project
+	}
+	return ps[i].Environment < ps[j].Environment
+}
@@ -0,0 +1,48 @@
+package deploy
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"golang.org/x/net/context"
+)
+
+func TestTracker(t *testing.T) {
+	tr := NewTracker()
+	if _, ok := tr.Get("proj", "env"); ok {
+		t.Errorf("tr.Get(%q, %q) succeeded on an empty tracker; want failure", "proj", "env")
+	}
+
+	now := time.Now()
+	tr.Update("proj", "env", Progress{State: StateQueued, QueuePosition: 1, Time: now})
+	tr.Update("a-proj", "env", Progress{State: StateDone, Time: now})
+	want := Progress{Project: "proj", Environment: "env", State: StateDeploying, Host: "host1", HostIndex: 1, HostCount: 2, Time: now}
+	if got := tr.Update("proj", "env", Progress{State: StateDeploying, Host: "host1", HostIndex: 1, HostCount: 2, Time: now}); !reflect.DeepEqual(got, want) {
+		t.Errorf("tr.Update(...) = %#v; want %#v", got, want)
+	}
+	if got, ok := tr.Get("proj", "env"); !ok || !reflect.DeepEqual(got, want) {
+		t.Errorf("tr.Get(%q, %q) = %#v, %v; want %#v, true", "proj", "env", got, ok, want)
+	}
+
+	all := tr.All()
+	if got, want := len(all), 2; got != want {
+		t.Fatalf("len(tr.All()) = %d; want %d", got, want)
+	}
+	if got, want := all[0].Project, "a-proj"; got != want {
+		t.Errorf("tr.All()[0].Project = %q; want %q", got, want)
+	}
+}
+
+func TestReport(t *testing.T) {
+	// must not panic without reporters
+	Report(context.Background(), Progress{State: StatePreflight})
+
+	var got []State
+	ctx := WithReporter(context.Background(), func(p Progress) { got = append(got, p.State) })
+	Report(ctx, Progress{State: StatePreflight})
+	Report(ctx, Progress{State: StateDeploying})
+	if want := []State{StatePreflight, StateDeploying}; !reflect.DeepEqual(got, want) {
+		t.Errorf("reported states = %v; want %v", got, want)
+	}
+}
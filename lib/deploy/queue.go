@@ -0,0 +1,126 @@
+package deploy
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+
+	"golang.org/x/net/context"
+)
+
+// ErrBusy is returned when another deployment is in progress in the same environment.
+var ErrBusy = errors.New("another deployment is in progress in the environment")
+
+// Queue serializes deployments per project and environment.
+type Queue struct {
+	mu sync.Mutex
+	// lines maps a project and environment to tickets of deployments, the head of which is running.
+	lines map[string][]*ticket
+	// changed maps a project and environment to a channel which is closed when the line changes.
+	changed map[string]chan struct{}
+}
+
+// ticket identifies a deployment in a line.
+// It must not be zero-sized so that pointers to distinct tickets are distinguishable.
+type ticket struct {
+	_ byte
+}
+
+// NewQueue returns a new empty Queue.
+func NewQueue() *Queue {
+	return &Queue{
+		lines:   make(map[string][]*ticket),
+		changed: make(map[string]chan struct{}),
+	}
+}
+
+func queueKey(proj, env string) string {
+	return fmt.Sprintf("%s/%s", proj, env)
+}
+
+// TryAcquire acquires the right to deploy "env" of "proj" without waiting.
+// It returns ErrBusy if another deployment is running or waiting.
+// The caller must call the returned function when the deployment finishes.
+func (q *Queue) TryAcquire(proj, env string) (release func(), err error) {
+	k := queueKey(proj, env)
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if len(q.lines[k]) > 0 {
+		return nil, ErrBusy
+	}
+	t := q.enqueue(k)
+	return func() { q.remove(k, t) }, nil
+}
+
+// Wait waits for preceding deployments in "env" of "proj" to finish and then acquires the right to deploy.
+// It calls "progress" with the number of deployments ahead of the caller whenever the number changes.
+// The caller must call the returned function when the deployment finishes.
+func (q *Queue) Wait(ctx context.Context, proj, env string, progress func(ahead int)) (release func(), err error) {
+	k := queueKey(proj, env)
+	q.mu.Lock()
+	t := q.enqueue(k)
+	q.mu.Unlock()
+
+	last := -1
+	for {
+		q.mu.Lock()
+		pos, changed := q.position(k, t), q.changed[k]
+		q.mu.Unlock()
+		if pos == 0 {
+			return func() { q.remove(k, t) }, nil
+		}
+		if pos != last && progress != nil {
+			progress(pos)
+			last = pos
+		}
+		select {
+		case <-changed:
+		case <-ctx.Done():
+			q.remove(k, t)
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// Len returns the number of running and waiting deployments in "env" of "proj".
+func (q *Queue) Len(proj, env string) int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return len(q.lines[queueKey(proj, env)])
+}
+
+func (q *Queue) enqueue(k string) *ticket {
+	t := new(ticket)
+	q.lines[k] = append(q.lines[k], t)
+	if _, ok := q.changed[k]; !ok {
+		q.changed[k] = make(chan struct{})
+	}
+	return t
+}
+
+func (q *Queue) position(k string, t *ticket) int {
+	for i, u := range q.lines[k] {
+		if u == t {
+			return i
+		}
+	}
+	return -1
+}
+
+func (q *Queue) remove(k string, t *ticket) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	i := q.position(k, t)
+	if i < 0 {
+		return
+	}
+	line := q.lines[k]
+	q.lines[k] = append(line[:i:i], line[i+1:]...)
+	close(q.changed[k])
+	if len(q.lines[k]) == 0 {
+		delete(q.lines, k)
+		delete(q.changed, k)
+		return
+	}
+	q.changed[k] = make(chan struct{})
+}
@@ -0,0 +1,91 @@
+package deploy
+
+import (
+	"testing"
+	"time"
+
+	"golang.org/x/net/context"
+)
+
+func TestQueueTryAcquire(t *testing.T) {
+	q := NewQueue()
+	release, err := q.TryAcquire("proj", "env")
+	if err != nil {
+		t.Fatalf("q.TryAcquire(%q, %q) failed with %v; want success", "proj", "env", err)
+	}
+	if _, err := q.TryAcquire("proj", "env"); err != ErrBusy {
+		t.Errorf("q.TryAcquire(%q, %q) returned %v; want %v", "proj", "env", err, ErrBusy)
+	}
+	if r, err := q.TryAcquire("proj", "other-env"); err != nil {
+		t.Errorf("q.TryAcquire(%q, %q) failed with %v; want success", "proj", "other-env", err)
+	} else {
+		r()
+	}
+	release()
+	if r, err := q.TryAcquire("proj", "env"); err != nil {
+		t.Errorf("q.TryAcquire(%q, %q) failed with %v after release; want success", "proj", "env", err)
+	} else {
+		r()
+	}
+}
+
+func TestQueueWait(t *testing.T) {
+	q := NewQueue()
+	ctx := context.Background()
+	release1, err := q.Wait(ctx, "proj", "env", nil)
+	if err != nil {
+		t.Fatalf("q.Wait(ctx, %q, %q, nil) failed with %v; want success", "proj", "env", err)
+	}
+
+	positions := make(chan int, 10)
+	acquired := make(chan func())
+	go func() {
+		release, err := q.Wait(ctx, "proj", "env", func(ahead int) { positions <- ahead })
+		if err != nil {
+			t.Errorf("q.Wait(ctx, %q, %q, progress) failed with %v; want success", "proj", "env", err)
+			return
+		}
+		acquired <- release
+	}()
+
+	select {
+	case got := <-positions:
+		if want := 1; got != want {
+			t.Errorf("ahead = %d; want %d", got, want)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("progress not reported")
+	}
+	if got, want := q.Len("proj", "env"), 2; got != want {
+		t.Errorf("q.Len(%q, %q) = %d; want %d", "proj", "env", got, want)
+	}
+
+	release1()
+	select {
+	case release := <-acquired:
+		release()
+	case <-time.After(time.Second):
+		t.Fatalf("second deployment did not start after the first one finished")
+	}
+	if got, want := q.Len("proj", "env"), 0; got != want {
+		t.Errorf("q.Len(%q, %q) = %d; want %d", "proj", "env", got, want)
+	}
+}
+
+func TestQueueWaitCancel(t *testing.T) {
+	q := NewQueue()
+	release, err := q.TryAcquire("proj", "env")
+	if err != nil {
+		t.Fatalf("q.TryAcquire(%q, %q) failed with %v; want success", "proj", "env", err)
+	}
+	defer release()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := q.Wait(ctx, "proj", "env", nil); err == nil {
+		t.Errorf("q.Wait(ctx, %q, %q, nil) succeeded with a canceled context; want failure", "proj", "env")
+	}
+	if got, want := q.Len("proj", "env"), 1; got != want {
+		t.Errorf("q.Len(%q, %q) = %d; want %d", "proj", "env", got, want)
+	}
+}
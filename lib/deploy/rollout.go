@@ -0,0 +1,75 @@
+package deploy
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"golang.org/x/net/context"
+)
+
+// Roll runs the deployment described in "req" with "e" following the rollout strategy of the environment.
+// With a strategy, it deploys to the canary hosts first and continues to the rest only if they pass the health check by "hc".
+// With host groups, it deploys to each group in order.
+// Otherwise it simply runs the deployment on all the hosts.
+func Roll(ctx context.Context, e Executor, hc HealthChecker, req Request, stdout, stderr io.Writer) error {
+	if len(req.Environment.HostGroups) > 0 && req.Deploys() {
+		return deployGroups(ctx, e, req, stdout, stderr)
+	}
+	r, hosts := req.Environment.Rollout, req.Environment.Hosts
+	if r == nil || r.Canary >= len(hosts) || req.Action != nil {
+		return e.Execute(ctx, req, stdout, stderr)
+	}
+	canary, rest := hosts[:r.Canary], hosts[r.Canary:]
+
+	// Progresses of the canary deployment are reported as StateCanary.
+	// Host states are reported for all the hosts in both phases.
+	cctx, rctx := ctx, ctx
+	if report, ok := ctx.Value(reporterKey{}).(Reporter); ok {
+		cctx = WithReporter(ctx, func(p Progress) {
+			p.State = StateCanary
+			if p.Hosts != nil {
+				p.Hosts = append(p.Hosts, hostStatuses(rest, HostPending)...)
+			}
+			report(p)
+		})
+		rctx = WithReporter(ctx, func(p Progress) {
+			if p.Hosts != nil {
+				p.Hosts = append(hostStatuses(canary, HostSucceeded), p.Hosts...)
+			}
+			if p.HostIndex > 0 {
+				p.HostIndex += len(canary)
+			}
+			p.HostCount = len(hosts)
+			report(p)
+		})
+	}
+	Report(cctx, Progress{State: StateCanary, HostCount: len(canary)})
+	fmt.Fprintf(stdout, "Deploying to canary host(s): %s\n", strings.Join(canary, ", "))
+	creq := req
+	creq.Environment.Hosts = canary
+	if err := e.Execute(cctx, creq, stdout, stderr); err != nil {
+		fmt.Fprintln(stderr, "Canary deployment failed; aborting rollout")
+		return err
+	}
+
+	Report(cctx, Progress{State: StateCanary, HostCount: len(canary)})
+	fmt.Fprintln(stdout, "Checking health of canary host(s)")
+	if err := hc.Check(ctx, r.HealthCheck, canary, stdout); err != nil {
+		fmt.Fprintf(stderr, "Canary failed the health check; aborting rollout: %v\n", err)
+		return err
+	}
+
+	fmt.Fprintf(stdout, "Canary is healthy; deploying to the remaining %d host(s)\n", len(rest))
+	rreq := req
+	rreq.Environment.Hosts = rest
+	return e.Execute(rctx, rreq, stdout, stderr)
+}
+
+func hostStatuses(hosts []string, s HostState) []HostStatus {
+	statuses := make([]HostStatus, 0, len(hosts))
+	for _, h := range hosts {
+		statuses = append(statuses, HostStatus{Host: h, State: s})
+	}
+	return statuses
+}
@@ -0,0 +1,92 @@
+package deploy
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/gengo/goship/lib/config"
+	"golang.org/x/net/context"
+)
+
+// recordingExecutor records the hosts of each request.
+type recordingExecutor struct {
+	mu    sync.Mutex
+	hosts [][]string
+	fail  bool
+}
+
+func (e *recordingExecutor) Execute(ctx context.Context, req Request, stdout, stderr io.Writer) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.hosts = append(e.hosts, req.Environment.Hosts)
+	if e.fail {
+		return errors.New("failed")
+	}
+	return nil
+}
+
+func TestRoll(t *testing.T) {
+	var healthy bool
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !healthy || !strings.HasPrefix(r.URL.Path, "/host1/") {
+			http.Error(w, "unhealthy", http.StatusServiceUnavailable)
+		}
+	}))
+	defer srv.Close()
+
+	hosts := []string{"host1", "host2", "host3"}
+	env := config.Environment{
+		Hosts: hosts,
+		Rollout: &config.Rollout{
+			Canary: 1,
+			HealthCheck: config.HealthCheck{
+				URL:      srv.URL + "/{{.Host}}/healthz",
+				Interval: "1ms",
+				Timeout:  "10ms",
+			},
+		},
+	}
+	for _, spec := range []struct {
+		healthy bool
+		fail    bool
+		wantErr bool
+		want    [][]string
+	}{
+		{healthy: true, want: [][]string{{"host1"}, {"host2", "host3"}}},
+		{healthy: false, wantErr: true, want: [][]string{{"host1"}}},
+		{healthy: true, fail: true, wantErr: true, want: [][]string{{"host1"}}},
+	} {
+		healthy = spec.healthy
+		e := &recordingExecutor{fail: spec.fail}
+		var states []State
+		ctx := WithReporter(context.Background(), func(p Progress) { states = append(states, p.State) })
+		var stdout, stderr bytes.Buffer
+		err := Roll(ctx, e, HealthChecker{}, Request{Environment: env}, &stdout, &stderr)
+		if got := err != nil; got != spec.wantErr {
+			t.Errorf("Roll(ctx, e, req, stdout, stderr) failed = %v; want %v; healthy = %v; err = %v", got, spec.wantErr, spec.healthy, err)
+		}
+		if !reflect.DeepEqual(e.hosts, spec.want) {
+			t.Errorf("hosts deployed by Roll(ctx, e, req, stdout, stderr) = %q; want %q; healthy = %v", e.hosts, spec.want, spec.healthy)
+		}
+		if len(states) == 0 || states[0] != StateCanary {
+			t.Errorf("states reported by Roll(ctx, e, req, stdout, stderr) = %q; want to start with %q", states, StateCanary)
+		}
+	}
+
+	env.Rollout = nil
+	e := &recordingExecutor{}
+	var stdout, stderr bytes.Buffer
+	if err := Roll(context.Background(), e, HealthChecker{}, Request{Environment: env}, &stdout, &stderr); err != nil {
+		t.Errorf("Roll(ctx, e, req, stdout, stderr) failed with %v; want success", err)
+	}
+	if want := [][]string{hosts}; !reflect.DeepEqual(e.hosts, want) {
+		t.Errorf("hosts deployed by Roll(ctx, e, req, stdout, stderr) = %q; want %q", e.hosts, want)
+	}
+}
@@ -0,0 +1,209 @@
+package deploy
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"golang.org/x/net/context"
+)
+
+var (
+	// ErrNoSuchDeploy is returned when the specified deployment is not running.
+	ErrNoSuchDeploy = errors.New("no such deployment in progress")
+	// ErrNotPaused is returned when the specified deployment is not waiting for a confirmation.
+	ErrNotPaused = errors.New("deployment is not paused")
+)
+
+// Run describes a running deployment.
+type Run struct {
+	ID          string
+	Project     string
+	Environment string
+	User        string
+	// Owner is the name of the user responsible for the deployment.
+	// It is User unless the deployment has been handed off to another user.
+	Owner   string
+	Started time.Time
+	// CanceledBy is the name of the user who canceled the deployment, or empty if not canceled.
+	CanceledBy string `json:",omitempty"`
+	// Paused describes the confirmation which the deployment is waiting for, or nil if not paused.
+	Paused *Pause `json:",omitempty"`
+
+	cancel context.CancelFunc
+	resume chan resumption
+}
+
+// Pause is a manual confirmation which a deployment waits for.
+type Pause struct {
+	// Step is the name of the host group deployed after the confirmation.
+	Step    string
+	Message string
+	Since   time.Time
+}
+
+// resumption is a decision on a paused deployment.
+type resumption struct {
+	user    string
+	proceed bool
+}
+
+// Running keeps track of running deployments so that they can be canceled.
+type Running struct {
+	mu     sync.Mutex
+	prefix string
+	seq    uint64
+	runs   map[string]*Run
+}
+
+// NewRunning returns a new empty Running.
+func NewRunning() *Running {
+	return &Running{runs: make(map[string]*Run)}
+}
+
+// NewRunningWithPrefix returns a new empty Running whose IDs of deployments are "prefix" and a sequence number joined with ".",
+// so that deployments of different servers have different IDs.
+func NewRunningWithPrefix(prefix string) *Running {
+	return &Running{prefix: prefix, runs: make(map[string]*Run)}
+}
+
+// Start registers a new deployment of "env" of "proj" requested by "user".
+// It returns the registered deployment and a context which is canceled when the deployment is canceled.
+// The caller must call "finish" when the deployment finishes.
+func (r *Running) Start(ctx context.Context, proj, env, user string) (ctx2 context.Context, run Run, finish func()) {
+	ctx, cancel := context.WithCancel(ctx)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.seq++
+	id := fmt.Sprint(r.seq)
+	if r.prefix != "" {
+		id = r.prefix + "." + id
+	}
+	rp := &Run{
+		ID:          id,
+		Project:     proj,
+		Environment: env,
+		User:        user,
+		Owner:       user,
+		Started:     time.Now(),
+		cancel:      cancel,
+	}
+	r.runs[rp.ID] = rp
+	return ctx, *rp, func() {
+		cancel()
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		delete(r.runs, rp.ID)
+	}
+}
+
+// Get returns the running deployment identified by "id".
+func (r *Running) Get(id string) (Run, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	rp, ok := r.runs[id]
+	if !ok {
+		return Run{}, ErrNoSuchDeploy
+	}
+	return *rp, nil
+}
+
+// Cancel cancels the running deployment identified by "id" on behalf of "user".
+func (r *Running) Cancel(id, user string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	rp, ok := r.runs[id]
+	if !ok {
+		return ErrNoSuchDeploy
+	}
+	if rp.CanceledBy == "" {
+		rp.CanceledBy = user
+	}
+	rp.cancel()
+	return nil
+}
+
+// Pause marks the running deployment identified by "id" as waiting for a confirmation of "message" before "step",
+// and blocks until someone resumes it or "ctx" is done.
+// It returns the name of the user who continued the deployment, or ErrAborted with the name of the user who aborted it.
+func (r *Running) Pause(ctx context.Context, id, step, message string) (user string, err error) {
+	ch := make(chan resumption, 1)
+	r.mu.Lock()
+	rp, ok := r.runs[id]
+	if !ok {
+		r.mu.Unlock()
+		return "", ErrNoSuchDeploy
+	}
+	rp.Paused = &Pause{Step: step, Message: message, Since: time.Now()}
+	rp.resume = ch
+	r.mu.Unlock()
+
+	defer func() {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		rp.Paused, rp.resume = nil, nil
+	}()
+	select {
+	case res := <-ch:
+		if !res.proceed {
+			return res.user, ErrAborted
+		}
+		return res.user, nil
+	case <-ctx.Done():
+		return "", ctx.Err()
+	}
+}
+
+// Resume continues the paused deployment identified by "id" on behalf of "user" if "proceed" is true, or aborts it otherwise.
+// It returns the deployment as it was paused.
+func (r *Running) Resume(id, user string, proceed bool) (Run, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	rp, ok := r.runs[id]
+	if !ok {
+		return Run{}, ErrNoSuchDeploy
+	}
+	if rp.resume == nil {
+		return Run{}, ErrNotPaused
+	}
+	run := *rp
+	rp.resume <- resumption{user: user, proceed: proceed}
+	// only the first decision counts
+	rp.resume = nil
+	return run, nil
+}
+
+// Handoff makes "to" the owner of the running deployment identified by "id".
+// It returns the deployment with the previous owner.
+func (r *Running) Handoff(id, to string) (prev Run, err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	rp, ok := r.runs[id]
+	if !ok {
+		return Run{}, ErrNoSuchDeploy
+	}
+	prev = *rp
+	rp.Owner = to
+	return prev, nil
+}
+
+// All returns all the running deployments in the order of their start.
+func (r *Running) All() []Run {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var runs []Run
+	for _, rp := range r.runs {
+		runs = append(runs, *rp)
+	}
+	sort.Sort(byStart(runs))
+	return runs
+}
+
+type byStart []Run
+
+func (rs byStart) Len() int           { return len(rs) }
+func (rs byStart) Swap(i, j int)      { rs[i], rs[j] = rs[j], rs[i] }
+func (rs byStart) Less(i, j int) bool { return rs[i].Started.Before(rs[j].Started) }
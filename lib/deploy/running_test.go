@@ -0,0 +1,138 @@
+package deploy
+
+import (
+	"testing"
+
+	"golang.org/x/net/context"
+)
+
+func TestRunningCancel(t *testing.T) {
+	r := NewRunning()
+	ctx, run, finish := r.Start(context.Background(), "proj", "env", "alice")
+	if got, want := len(r.All()), 1; got != want {
+		t.Errorf("len(r.All()) = %d; want %d", got, want)
+	}
+
+	if err := r.Cancel(run.ID, "bob"); err != nil {
+		t.Errorf("r.Cancel(%q, %q) failed with %v; want success", run.ID, "bob", err)
+	}
+	select {
+	case <-ctx.Done():
+	default:
+		t.Errorf("ctx is not canceled after r.Cancel(%q, %q)", run.ID, "bob")
+	}
+	got, err := r.Get(run.ID)
+	if err != nil {
+		t.Fatalf("r.Get(%q) failed with %v; want success", run.ID, err)
+	}
+	if got, want := got.CanceledBy, "bob"; got != want {
+		t.Errorf("r.Get(%q).CanceledBy = %q; want %q", run.ID, got, want)
+	}
+
+	finish()
+	if _, err := r.Get(run.ID); err != ErrNoSuchDeploy {
+		t.Errorf("r.Get(%q) returned %v after finish; want %v", run.ID, err, ErrNoSuchDeploy)
+	}
+	if err := r.Cancel(run.ID, "bob"); err != ErrNoSuchDeploy {
+		t.Errorf("r.Cancel(%q, %q) returned %v after finish; want %v", run.ID, "bob", err, ErrNoSuchDeploy)
+	}
+}
+
+func TestRunningIDsAreUnique(t *testing.T) {
+	r := NewRunning()
+	_, a, finishA := r.Start(context.Background(), "proj", "env", "alice")
+	defer finishA()
+	_, b, finishB := r.Start(context.Background(), "proj", "env", "alice")
+	defer finishB()
+	if a.ID == b.ID {
+		t.Errorf("r.Start returned the same ID %q twice; want unique IDs", a.ID)
+	}
+}
+
+func TestRunningHandoff(t *testing.T) {
+	r := NewRunning()
+	_, run, finish := r.Start(context.Background(), "proj", "env", "alice")
+	if got, want := run.Owner, "alice"; got != want {
+		t.Errorf("run.Owner = %q; want %q", got, want)
+	}
+
+	prev, err := r.Handoff(run.ID, "bob")
+	if err != nil {
+		t.Fatalf("r.Handoff(%q, %q) failed with %v; want success", run.ID, "bob", err)
+	}
+	if got, want := prev.Owner, "alice"; got != want {
+		t.Errorf("r.Handoff(%q, %q).Owner = %q; want %q", run.ID, "bob", got, want)
+	}
+	got, err := r.Get(run.ID)
+	if err != nil {
+		t.Fatalf("r.Get(%q) failed with %v; want success", run.ID, err)
+	}
+	if got, want := got.Owner, "bob"; got != want {
+		t.Errorf("r.Get(%q).Owner = %q; want %q", run.ID, got, want)
+	}
+	if got, want := got.User, "alice"; got != want {
+		t.Errorf("r.Get(%q).User = %q; want %q", run.ID, got, want)
+	}
+
+	finish()
+	if _, err := r.Handoff(run.ID, "carol"); err != ErrNoSuchDeploy {
+		t.Errorf("r.Handoff(%q, %q) returned %v after finish; want %v", run.ID, "carol", err, ErrNoSuchDeploy)
+	}
+}
+
+func TestRunningPause(t *testing.T) {
+	r := NewRunning()
+	ctx, run, finish := r.Start(context.Background(), "proj", "env", "alice")
+	defer finish()
+	if _, err := r.Resume(run.ID, "bob", true); err != ErrNotPaused {
+		t.Errorf("r.Resume(%q, %q, true) returned %v before pause; want %v", run.ID, "bob", err, ErrNotPaused)
+	}
+
+	for _, proceed := range []bool{true, false} {
+		type result struct {
+			user string
+			err  error
+		}
+		done := make(chan result)
+		go func() {
+			user, err := r.Pause(ctx, run.ID, "web", "check migrations")
+			done <- result{user, err}
+		}()
+		var paused Run
+		for paused.Paused == nil {
+			var err error
+			if paused, err = r.Get(run.ID); err != nil {
+				t.Fatalf("r.Get(%q) failed with %v; want success", run.ID, err)
+			}
+		}
+		if got, want := paused.Paused.Step, "web"; got != want {
+			t.Errorf("r.Get(%q).Paused.Step = %q; want %q", run.ID, got, want)
+		}
+		if _, err := r.Resume(run.ID, "bob", proceed); err != nil {
+			t.Fatalf("r.Resume(%q, %q, %v) failed with %v; want success", run.ID, "bob", proceed, err)
+		}
+		res := <-done
+		var want error
+		if !proceed {
+			want = ErrAborted
+		}
+		if res.user != "bob" || res.err != want {
+			t.Errorf("r.Pause(ctx, %q, ...) = %q, %v; want %q, %v", run.ID, res.user, res.err, "bob", want)
+		}
+		if got, err := r.Get(run.ID); err != nil || got.Paused != nil {
+			t.Errorf("r.Get(%q) = %#v, %v after resume; want not paused", run.ID, got, err)
+		}
+	}
+}
+
+func TestRunningWithPrefix(t *testing.T) {
+	r := NewRunningWithPrefix("goship-1.example.com")
+	_, run, finish := r.Start(context.Background(), "proj", "env", "alice")
+	defer finish()
+	if got, want := run.ID, "goship-1.example.com.1"; got != want {
+		t.Errorf("run.ID = %q; want %q", got, want)
+	}
+	if _, err := r.Get(run.ID); err != nil {
+		t.Errorf("r.Get(%q) failed with %v; want success", run.ID, err)
+	}
+}
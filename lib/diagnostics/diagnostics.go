@@ -0,0 +1,150 @@
+// Package diagnostics runs self-tests of the dependencies of Goship and reports their results together,
+// so that misconfigurations surface as one readable report instead of scattered log lines.
+package diagnostics
+
+import (
+	"bytes"
+	"fmt"
+	"sync"
+	"time"
+
+	"golang.org/x/net/context"
+)
+
+// Status is the result of a check.
+type Status string
+
+const (
+	// StatusOK means the dependency works.
+	StatusOK = Status("ok")
+	// StatusWarning means the dependency works but is likely misconfigured, or works only partially.
+	StatusWarning = Status("warning")
+	// StatusFailure means the dependency does not work.
+	StatusFailure = Status("failure")
+	// StatusSkipped means the dependency is not used in this configuration.
+	StatusSkipped = Status("skipped")
+)
+
+// Check is a self-test of a dependency.
+type Check struct {
+	// Name identifies the dependency, e.g. "etcd".
+	Name string
+	// Run checks the dependency and returns a human-readable description of the result.
+	// The status is StatusOK if err is nil, and otherwise the status given by Warn or Skip, or StatusFailure.
+	Run func(ctx context.Context) (string, error)
+}
+
+// statusError is an error which reports a status other than StatusFailure.
+type statusError struct {
+	status Status
+	msg    string
+}
+
+func (e statusError) Error() string {
+	return e.msg
+}
+
+// Warn returns an error which makes a check report StatusWarning.
+func Warn(format string, args ...interface{}) error {
+	return statusError{status: StatusWarning, msg: fmt.Sprintf(format, args...)}
+}
+
+// Skip returns an error which makes a check report StatusSkipped.
+func Skip(format string, args ...interface{}) error {
+	return statusError{status: StatusSkipped, msg: fmt.Sprintf(format, args...)}
+}
+
+// Result is the result of a check.
+type Result struct {
+	Name    string `json:"name"`
+	Status  Status `json:"status"`
+	Message string `json:"message"`
+	// Duration is how long the check took.
+	Duration time.Duration `json:"duration"`
+}
+
+// Report is the results of checks.
+type Report struct {
+	Time time.Time `json:"time"`
+	// Healthy is true if no check failed.
+	Healthy bool `json:"healthy"`
+	// Results are the results of the checks in the given order.
+	Results []Result `json:"results"`
+}
+
+// Run runs "checks" concurrently, each of which is canceled after "timeout", and returns their results.
+func Run(ctx context.Context, checks []Check, timeout time.Duration) Report {
+	r := Report{Time: time.Now(), Healthy: true, Results: make([]Result, len(checks))}
+	var wg sync.WaitGroup
+	for i, c := range checks {
+		wg.Add(1)
+		go func(i int, c Check) {
+			defer wg.Done()
+			r.Results[i] = run(ctx, c, timeout)
+		}(i, c)
+	}
+	wg.Wait()
+	for _, res := range r.Results {
+		if res.Status == StatusFailure {
+			r.Healthy = false
+		}
+	}
+	return r
+}
+
+func run(ctx context.Context, c Check, timeout time.Duration) Result {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	type outcome struct {
+		msg string
+		err error
+	}
+	// buffered so that checks which ignore the context do not leak after the timeout
+	done := make(chan outcome, 1)
+	start := time.Now()
+	go func() {
+		msg, err := c.Run(ctx)
+		done <- outcome{msg: msg, err: err}
+	}()
+	res := Result{Name: c.Name}
+	select {
+	case o := <-done:
+		res.Status, res.Message = StatusOK, o.msg
+		if se, ok := o.err.(statusError); ok {
+			res.Status, res.Message = se.status, se.msg
+		} else if o.err != nil {
+			res.Status, res.Message = StatusFailure, o.err.Error()
+		}
+	case <-ctx.Done():
+		res.Status, res.Message = StatusFailure, fmt.Sprintf("timed out after %s", timeout)
+	}
+	res.Duration = time.Since(start)
+	return res
+}
+
+// Counts returns the number of the results of each status.
+func (r Report) Counts() map[Status]int {
+	counts := make(map[Status]int)
+	for _, res := range r.Results {
+		counts[res.Status]++
+	}
+	return counts
+}
+
+// String formats the report as a readable text with a line per check.
+func (r Report) String() string {
+	counts := r.Counts()
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "Diagnostics: %d ok, %d warning(s), %d failure(s), %d skipped\n", counts[StatusOK], counts[StatusWarning], counts[StatusFailure], counts[StatusSkipped])
+	width := 0
+	for _, res := range r.Results {
+		if len(res.Name) > width {
+			width = len(res.Name)
+		}
+	}
+	for _, res := range r.Results {
+		fmt.Fprintf(&buf, "  %-9s %-*s  %s\n", "["+string(res.Status)+"]", width, res.Name, res.Message)
+	}
+	return buf.String()
+}
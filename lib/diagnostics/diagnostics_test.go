@@ -0,0 +1,57 @@
+package diagnostics
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/net/context"
+)
+
+func TestRun(t *testing.T) {
+	checks := []Check{
+		{Name: "etcd", Run: func(ctx context.Context) (string, error) { return "loaded 3 projects", nil }},
+		{Name: "github", Run: func(ctx context.Context) (string, error) { return "", errors.New("401 Unauthorized") }},
+		{Name: "data-dir", Run: func(ctx context.Context) (string, error) { return "", Warn("world-writable") }},
+		{Name: "slack", Run: func(ctx context.Context) (string, error) { return "", Skip("not configured") }},
+		{Name: "smtp", Run: func(ctx context.Context) (string, error) {
+			<-ctx.Done()
+			return "", ctx.Err()
+		}},
+	}
+	r := Run(context.Background(), checks, 10*time.Millisecond)
+	if r.Healthy {
+		t.Errorf("r.Healthy = true; want false")
+	}
+	want := []Result{
+		{Name: "etcd", Status: StatusOK, Message: "loaded 3 projects"},
+		{Name: "github", Status: StatusFailure, Message: "401 Unauthorized"},
+		{Name: "data-dir", Status: StatusWarning, Message: "world-writable"},
+		{Name: "slack", Status: StatusSkipped, Message: "not configured"},
+		{Name: "smtp", Status: StatusFailure, Message: "timed out after 10ms"},
+	}
+	if len(r.Results) != len(want) {
+		t.Fatalf("r.Results = %#v; want %d results", r.Results, len(want))
+	}
+	for i, res := range r.Results {
+		if res.Name != want[i].Name || res.Status != want[i].Status || res.Message != want[i].Message {
+			t.Errorf("r.Results[%d] = %#v; want %#v", i, res, want[i])
+		}
+	}
+
+	s := r.String()
+	for _, line := range []string{
+		"Diagnostics: 1 ok, 1 warning(s), 2 failure(s), 1 skipped",
+		"[failure] github    401 Unauthorized",
+		"[skipped] slack     not configured",
+	} {
+		if !strings.Contains(s, line) {
+			t.Errorf("r.String() = %q; want a line %q", s, line)
+		}
+	}
+
+	if r := Run(context.Background(), checks[:1], time.Second); !r.Healthy {
+		t.Errorf("Run(ctx, %q, time.Second).Healthy = false; want true", "etcd")
+	}
+}
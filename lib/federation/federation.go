@@ -0,0 +1,132 @@
+// Package federation aggregates read-only status of projects from multiple Goship instances.
+package federation
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gengo/goship/lib/acl"
+	"github.com/gengo/goship/lib/config"
+	"github.com/gengo/goship/lib/deploy"
+	"github.com/gengo/goship/lib/revision"
+)
+
+// StatusPath is the path of the API which serves the Status of an instance.
+const StatusPath = "/api/status"
+
+// Status is the status of the projects in a Goship instance.
+type Status struct {
+	// Instance is the name of the instance, if configured.
+	Instance string    `json:"instance,omitempty"`
+	Projects []Project `json:"projects"`
+}
+
+// Project is the status of a project.
+type Project struct {
+	Name string `json:"name"`
+	// RepoOwner and RepoName identify the source repository, which decides who can read the project.
+	RepoOwner    string        `json:"repo_owner"`
+	RepoName     string        `json:"repo_name"`
+	Environments []Environment `json:"environments"`
+}
+
+// Environment is the status of an environment of a project.
+type Environment struct {
+	Name string `json:"name"`
+	// Lock is the current lock of the environment, or nil if not locked.
+	Lock *config.Lock `json:"lock,omitempty"`
+	// State is the state of the deployment in progress, or empty if none is in progress.
+	State deploy.State `json:"state,omitempty"`
+	// LastDeploy is the latest deployment to the environment, or nil if never deployed.
+	LastDeploy *Deploy `json:"last_deploy,omitempty"`
+}
+
+// Deploy is a finished deployment.
+type Deploy struct {
+	User    string            `json:"user"`
+	From    revision.Revision `json:"from"`
+	To      revision.Revision `json:"to"`
+	Success bool              `json:"success"`
+	Time    time.Time         `json:"time"`
+}
+
+// Readable returns a copy of "s" which contains only the projects "user" can read according to "ac".
+func Readable(s Status, ac acl.AccessControl, user string) Status {
+	projs := []Project{}
+	for _, p := range s.Projects {
+		if ac.Readable(p.RepoOwner, p.RepoName, user) {
+			projs = append(projs, p)
+		}
+	}
+	s.Projects = projs
+	return s
+}
+
+// PeerStatus is the status of a peer, or why it is not available.
+type PeerStatus struct {
+	Peer string `json:"peer"`
+	URL  string `json:"url"`
+	// Status is the status of the peer, or nil if Error is not empty.
+	Status *Status `json:"status,omitempty"`
+	Error  string  `json:"error,omitempty"`
+}
+
+// Client reads the status of peers.
+type Client struct {
+	hc *http.Client
+}
+
+// NewClient returns a new Client which gives up requests to peers after "timeout".
+func NewClient(timeout time.Duration) *Client {
+	return &Client{hc: &http.Client{Timeout: timeout}}
+}
+
+// Fetch returns the status of "p".
+func (c *Client) Fetch(p config.Peer) (Status, error) {
+	req, err := http.NewRequest("GET", strings.TrimSuffix(p.URL, "/")+StatusPath, nil)
+	if err != nil {
+		return Status{}, err
+	}
+	if p.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+p.Token)
+	}
+	res, err := c.hc.Do(req)
+	if err != nil {
+		return Status{}, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return Status{}, fmt.Errorf("GET %s returned %s", req.URL, res.Status)
+	}
+	var s Status
+	if err := json.NewDecoder(res.Body).Decode(&s); err != nil {
+		return Status{}, err
+	}
+	return s, nil
+}
+
+// FetchAll returns the statuses of "peers" in the same order.
+// Peers are queried concurrently, and failures are reported in PeerStatus.Error.
+func (c *Client) FetchAll(peers []config.Peer) []PeerStatus {
+	result := make([]PeerStatus, len(peers))
+	var wg sync.WaitGroup
+	for i, p := range peers {
+		wg.Add(1)
+		go func(i int, p config.Peer) {
+			defer wg.Done()
+			result[i] = PeerStatus{Peer: p.Name, URL: p.URL}
+			s, err := c.Fetch(p)
+			if err != nil {
+				result[i].Error = err.Error()
+				return
+			}
+			result[i].Status = &s
+		}(i, p)
+	}
+	wg.Wait()
+	return result
+}
@@ -0,0 +1,77 @@
+package federation
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/gengo/goship/lib/config"
+)
+
+type ownerACL struct{}
+
+func (ownerACL) Readable(owner, repo, user string) bool   { return owner == user }
+func (ownerACL) Deployable(owner, repo, user string) bool { return false }
+
+func TestFetchAll(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != StatusPath {
+			t.Errorf("r.URL.Path = %q; want %q", r.URL.Path, StatusPath)
+		}
+		if got, want := r.Header.Get("Authorization"), "Bearer secret"; got != want {
+			http.Error(w, "invalid token", http.StatusUnauthorized)
+			return
+		}
+		fmt.Fprint(w, `{"instance": "us-east", "projects": [{"name": "admin", "repo_owner": "alice", "repo_name": "admin", "environments": [{"name": "production", "state": "deploying"}]}]}`)
+	}))
+	defer s.Close()
+
+	c := NewClient(time.Second)
+	got := c.FetchAll([]config.Peer{
+		{Name: "us-east", URL: s.URL + "/", Token: "secret"},
+		{Name: "us-west", URL: s.URL, Token: "wrong"},
+	})
+	want := PeerStatus{
+		Peer: "us-east",
+		URL:  s.URL + "/",
+		Status: &Status{
+			Instance: "us-east",
+			Projects: []Project{{
+				Name:         "admin",
+				RepoOwner:    "alice",
+				RepoName:     "admin",
+				Environments: []Environment{{Name: "production", State: "deploying"}},
+			}},
+		},
+	}
+	if len(got) != 2 {
+		t.Fatalf("c.FetchAll(peers) = %#v; want 2 statuses", got)
+	}
+	if !reflect.DeepEqual(got[0], want) {
+		t.Errorf("c.FetchAll(peers)[0] = %#v; want %#v", got[0], want)
+	}
+	if got[1].Peer != "us-west" || got[1].Status != nil || got[1].Error == "" {
+		t.Errorf("c.FetchAll(peers)[1] = %#v; want an error of us-west", got[1])
+	}
+}
+
+func TestReadable(t *testing.T) {
+	s := Status{
+		Instance: "us-east",
+		Projects: []Project{
+			{Name: "admin", RepoOwner: "alice", RepoName: "admin"},
+			{Name: "api", RepoOwner: "bob", RepoName: "api"},
+		},
+	}
+	got := Readable(s, ownerACL{}, "bob")
+	want := Status{
+		Instance: "us-east",
+		Projects: []Project{{Name: "api", RepoOwner: "bob", RepoName: "api"}},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Readable(s, ac, %q) = %#v; want %#v", "bob", got, want)
+	}
+}
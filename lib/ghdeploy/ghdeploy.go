@@ -0,0 +1,87 @@
+// Package ghdeploy reports deployments and their results to the GitHub Deployments API.
+package ghdeploy
+
+import (
+	"fmt"
+
+	"github.com/gengo/goship/lib/config"
+	githublib "github.com/gengo/goship/lib/github"
+	"github.com/gengo/goship/lib/revision"
+	"github.com/google/go-github/github"
+)
+
+// States of deployments in GitHub.
+const (
+	StatePending = "pending"
+	StateSuccess = "success"
+	StateFailure = "failure"
+	StateError   = "error"
+)
+
+// Deployment is a deployment into an environment.
+type Deployment struct {
+	Environment string
+	User        string
+	// Ref is the revision in the source repository being deployed.
+	Ref revision.Revision
+	// Reason is the reason of the deployment given by the user, if any.
+	Reason string
+}
+
+// Reporter creates GitHub deployments in the source repositories of projects and updates their statuses.
+type Reporter struct {
+	gcl githublib.Client
+}
+
+// New returns a new Reporter which calls the GitHub API with "gcl".
+func New(gcl githublib.Client) Reporter {
+	return Reporter{gcl: gcl}
+}
+
+// Start creates a deployment of "d" in the source repository of "proj" in the pending state, and returns its ID.
+func (r Reporter) Start(proj config.Project, d Deployment) (int, error) {
+	repo := proj.SourceRepo()
+	desc := fmt.Sprintf("Deployed by %s with Goship", d.User)
+	if d.Reason != "" {
+		desc = fmt.Sprintf("%s: %s", desc, d.Reason)
+	}
+	req := &github.DeploymentRequest{
+		Ref:         github.String(string(d.Ref)),
+		Environment: github.String(d.Environment),
+		Description: github.String(desc),
+		// Goship has already decided to deploy the revision, so GitHub must neither merge the default branch nor check commit statuses.
+		AutoMerge:        github.Bool(false),
+		RequiredContexts: &[]string{},
+	}
+	dep, _, err := r.gcl.CreateDeployment(repo.RepoOwner, repo.RepoName, req)
+	if err != nil {
+		return 0, err
+	}
+	if dep.ID == nil {
+		return 0, fmt.Errorf("no id of the deployment of %s in %s/%s", d.Ref, repo.RepoOwner, repo.RepoName)
+	}
+	if err := r.SetStatus(proj, *dep.ID, StatePending, ""); err != nil {
+		return 0, err
+	}
+	return *dep.ID, nil
+}
+
+// SetStatus sets the status of the deployment "id" in the source repository of "proj" to "state" with an optional "description".
+func (r Reporter) SetStatus(proj config.Project, id int, state, description string) error {
+	repo := proj.SourceRepo()
+	req := &github.DeploymentStatusRequest{State: github.String(state)}
+	if description != "" {
+		req.Description = github.String(description)
+	}
+	_, _, err := r.gcl.CreateDeploymentStatus(repo.RepoOwner, repo.RepoName, id, req)
+	return err
+}
+
+// Finish sets the status of the deployment "id" in the source repository of "proj" to the result of the deployment.
+func (r Reporter) Finish(proj config.Project, id int, success bool, description string) error {
+	state := StateSuccess
+	if !success {
+		state = StateFailure
+	}
+	return r.SetStatus(proj, id, state, description)
+}
@@ -0,0 +1,68 @@
+package ghdeploy
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/gengo/goship/lib/config"
+	githublib "github.com/gengo/goship/lib/github"
+	"github.com/google/go-github/github"
+)
+
+// fakeDeployments records the deployment API calls.
+type fakeDeployments struct {
+	githublib.Client
+	repos       []string
+	deployments []*github.DeploymentRequest
+	statuses    []string
+}
+
+func (f *fakeDeployments) CreateDeployment(owner, repo string, request *github.DeploymentRequest) (*github.Deployment, *github.Response, error) {
+	f.repos = append(f.repos, owner+"/"+repo)
+	f.deployments = append(f.deployments, request)
+	return &github.Deployment{ID: github.Int(42)}, nil, nil
+}
+
+func (f *fakeDeployments) CreateDeploymentStatus(owner, repo string, deployment int, request *github.DeploymentStatusRequest) (*github.DeploymentStatus, *github.Response, error) {
+	f.statuses = append(f.statuses, *request.State)
+	return &github.DeploymentStatus{State: request.State}, nil, nil
+}
+
+func TestReporter(t *testing.T) {
+	gcl := new(fakeDeployments)
+	r := New(gcl)
+	proj := config.Project{
+		Name:   "proj",
+		Repo:   config.Repo{RepoOwner: "owner", RepoName: "image"},
+		Source: &config.Repo{RepoOwner: "owner", RepoName: "repo"},
+	}
+	d := Deployment{Environment: "production", User: "alice", Ref: "abc123", Reason: "hotfix"}
+
+	id, err := r.Start(proj, d)
+	if err != nil {
+		t.Fatalf("r.Start(proj, %#v) failed with %v; want success", d, err)
+	}
+	if got, want := id, 42; got != want {
+		t.Errorf("r.Start(proj, %#v) = %d; want %d", d, got, want)
+	}
+	if got, want := gcl.repos, []string{"owner/repo"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("deployments created in %q; want %q", got, want)
+	}
+	req := gcl.deployments[0]
+	if got, want := *req.Ref, "abc123"; got != want {
+		t.Errorf("ref = %q; want %q", got, want)
+	}
+	if got, want := *req.Environment, "production"; got != want {
+		t.Errorf("environment = %q; want %q", got, want)
+	}
+	if got, want := *req.Description, "Deployed by alice with Goship: hotfix"; got != want {
+		t.Errorf("description = %q; want %q", got, want)
+	}
+
+	if err := r.Finish(proj, id, false, ""); err != nil {
+		t.Fatalf("r.Finish(proj, %d, false, %q) failed with %v; want success", id, "", err)
+	}
+	if got, want := gcl.statuses, []string{StatePending, StateFailure}; !reflect.DeepEqual(got, want) {
+		t.Errorf("statuses = %q; want %q", got, want)
+	}
+}
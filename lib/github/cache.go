@@ -0,0 +1,276 @@
+package github
+
+import (
+	"bytes"
+	"container/list"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gengo/goship/lib/metrics"
+	"github.com/golang/glog"
+	"golang.org/x/net/context"
+)
+
+// CacheHeader is the header of responses which tells how Cache served them: "hit", "revalidated" or "miss".
+const CacheHeader = "X-Goship-Cache"
+
+// Results of Cache in CacheHeader.
+const (
+	// cacheHit means the response was fresh in the cache and served without calling github.
+	cacheHit = "hit"
+	// cacheRevalidated means github replied "304 Not Modified" to a conditional request, which does not count against the rate limit.
+	cacheRevalidated = "revalidated"
+	// cacheMiss means the response came from github.
+	cacheMiss = "miss"
+)
+
+var cacheRequests = metrics.NewCounterVec("goship_github_cache_requests_total", "Number of github API requests by the result of the cache.", "result")
+
+// rateLimitHeaders are the headers of the rate limit, which are copied from "304 Not Modified" into cached responses.
+var rateLimitHeaders = []string{"X-RateLimit-Limit", "X-RateLimit-Remaining", "X-RateLimit-Reset"}
+
+// CacheTTLs are how long responses of github APIs are served from Cache without asking github.
+type CacheTTLs struct {
+	// Default is the TTL of the responses which no other field covers.
+	Default time.Duration
+	// Statuses is the TTL of commit statuses, which CI updates while it runs.
+	Statuses time.Duration
+}
+
+// ttl returns the TTL of the response to "req".
+func (t CacheTTLs) ttl(req *http.Request) time.Duration {
+	if p := req.URL.Path; strings.HasSuffix(p, "/status") || strings.HasSuffix(p, "/statuses") {
+		return t.Statuses
+	}
+	return t.Default
+}
+
+// Cache is an http.RoundTripper which caches successful GET responses of github APIs.
+// Fresh responses are served without requests, and stale ones are revalidated with their ETags or modification times,
+// so that repeated page loads do not exhaust the rate limit.
+type Cache struct {
+	// Base sends requests to github. http.DefaultTransport is used if nil.
+	Base http.RoundTripper
+
+	ttls CacheTTLs
+	max  int
+
+	mu      sync.Mutex
+	entries map[string]*list.Element
+	// lru has the entries, most recently used first.
+	lru *list.List
+}
+
+type cacheEntry struct {
+	key string
+	// reqHeader is the header of the request which revalidations send again.
+	reqHeader http.Header
+
+	status int
+	header http.Header
+	body   []byte
+
+	expires time.Time
+	// used is when the entry was requested last, except for background refreshes.
+	used time.Time
+}
+
+// NewCache returns a new empty Cache which keeps up to "maxEntries" responses for "ttls".
+func NewCache(ttls CacheTTLs, maxEntries int) *Cache {
+	return &Cache{ttls: ttls, max: maxEntries, entries: make(map[string]*list.Element), lru: list.New()}
+}
+
+// RoundTrip serves "req" from the cache if fresh, and sends it to github otherwise.
+func (c *Cache) RoundTrip(req *http.Request) (*http.Response, error) {
+	return c.roundTrip(req, false)
+}
+
+// roundTrip serves "req". Fresh entries are revalidated and not marked as used if "refresh" is true.
+func (c *Cache) roundTrip(req *http.Request, refresh bool) (*http.Response, error) {
+	if req.Method != "GET" || req.Header.Get("Range") != "" {
+		return c.base().RoundTrip(req)
+	}
+	key, now := req.URL.String(), time.Now()
+
+	c.mu.Lock()
+	var e *cacheEntry
+	if elem, ok := c.entries[key]; ok {
+		e = elem.Value.(*cacheEntry)
+		if !refresh {
+			c.lru.MoveToFront(elem)
+			e.used = now
+			if now.Before(e.expires) {
+				resp := e.response(req, cacheHit)
+				c.mu.Unlock()
+				cacheRequests.Inc(cacheHit)
+				return resp, nil
+			}
+		}
+	}
+	out := cloneRequest(req)
+	if e != nil {
+		if etag := e.header.Get("ETag"); etag != "" {
+			out.Header.Set("If-None-Match", etag)
+		}
+		if lm := e.header.Get("Last-Modified"); lm != "" {
+			out.Header.Set("If-Modified-Since", lm)
+		}
+	}
+	c.mu.Unlock()
+
+	resp, err := c.base().RoundTrip(out)
+	if err != nil {
+		return nil, err
+	}
+	ttl := c.ttls.ttl(req)
+	if e != nil && resp.StatusCode == http.StatusNotModified {
+		io.Copy(ioutil.Discard, resp.Body)
+		resp.Body.Close()
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		for _, h := range rateLimitHeaders {
+			if v := resp.Header.Get(h); v != "" {
+				e.header.Set(h, v)
+			}
+		}
+		e.expires = time.Now().Add(ttl)
+		cacheRequests.Inc(cacheRevalidated)
+		return e.response(req, cacheRevalidated), nil
+	}
+	cacheRequests.Inc(cacheMiss)
+	if resp.StatusCode != http.StatusOK || (ttl <= 0 && resp.Header.Get("ETag") == "" && resp.Header.Get("Last-Modified") == "") {
+		return resp, nil
+	}
+
+	body, err := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	e = &cacheEntry{
+		key:       key,
+		reqHeader: cloneHeader(req.Header),
+		status:    resp.StatusCode,
+		header:    cloneHeader(resp.Header),
+		body:      body,
+		expires:   time.Now().Add(ttl),
+		used:      now,
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if elem, ok := c.entries[key]; ok {
+		if refresh {
+			e.used = elem.Value.(*cacheEntry).used
+		}
+		elem.Value = e
+	} else {
+		c.entries[key] = c.lru.PushFront(e)
+	}
+	for c.max > 0 && c.lru.Len() > c.max {
+		oldest := c.lru.Back()
+		c.lru.Remove(oldest)
+		delete(c.entries, oldest.Value.(*cacheEntry).key)
+	}
+	return e.response(req, cacheMiss), nil
+}
+
+// Refresh revalidates the entries which have been used within "within" and expire within "interval", every "interval" until "ctx" is done.
+// It keeps responses used by pages loaded periodically, e.g. the latest commits on the home page, fresh in the background.
+// Revalidations which github answers with "304 Not Modified" do not count against the rate limit.
+func (c *Cache) Refresh(ctx context.Context, interval, within time.Duration) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(interval):
+		}
+		now := time.Now()
+		var stale []*cacheEntry
+		c.mu.Lock()
+		for elem := c.lru.Front(); elem != nil; elem = elem.Next() {
+			e := elem.Value.(*cacheEntry)
+			if now.Sub(e.used) > within {
+				// the rest are used even earlier
+				break
+			}
+			if e.expires.Before(now.Add(interval)) {
+				stale = append(stale, e)
+			}
+		}
+		c.mu.Unlock()
+
+		for _, e := range stale {
+			if ctx.Err() != nil {
+				return
+			}
+			if err := c.refresh(e); err != nil {
+				glog.Warningf("Failed to refresh cached github response of %s: %v", e.key, err)
+			}
+		}
+		if len(stale) > 0 {
+			glog.V(1).Infof("Refreshed %d cached github response(s)", len(stale))
+		}
+	}
+}
+
+// refresh revalidates "e" with github.
+func (c *Cache) refresh(e *cacheEntry) error {
+	req, err := http.NewRequest("GET", e.key, nil)
+	if err != nil {
+		return err
+	}
+	req.Header = cloneHeader(e.reqHeader)
+	resp, err := c.roundTrip(req, true)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	return nil
+}
+
+func (c *Cache) base() http.RoundTripper {
+	if c.Base != nil {
+		return c.Base
+	}
+	return http.DefaultTransport
+}
+
+// response returns the cached response to "req", which tells "result" in CacheHeader.
+func (e *cacheEntry) response(req *http.Request, result string) *http.Response {
+	header := cloneHeader(e.header)
+	header.Set(CacheHeader, result)
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", e.status, http.StatusText(e.status)),
+		StatusCode:    e.status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          ioutil.NopCloser(bytes.NewReader(e.body)),
+		ContentLength: int64(len(e.body)),
+		Request:       req,
+	}
+}
+
+func cloneRequest(req *http.Request) *http.Request {
+	r := new(http.Request)
+	*r = *req
+	r.Header = cloneHeader(req.Header)
+	return r
+}
+
+func cloneHeader(h http.Header) http.Header {
+	c := make(http.Header, len(h))
+	for k, v := range h {
+		c[k] = append([]string(nil), v...)
+	}
+	return c
+}
@@ -0,0 +1,112 @@
+package github
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"golang.org/x/net/context"
+)
+
+// etagServer serves the request path with an ETag, and replies "304 Not Modified" to requests with the ETag.
+type etagServer struct {
+	mu          sync.Mutex
+	requests    int
+	conditional int
+}
+
+func (s *etagServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.requests++
+	etag := `"` + r.URL.Path + `"`
+	w.Header().Set("X-RateLimit-Limit", "5000")
+	w.Header().Set("X-RateLimit-Remaining", "4000")
+	if r.Header.Get("If-None-Match") == etag {
+		s.conditional++
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	w.Header().Set("ETag", etag)
+	w.Write([]byte(r.URL.Path))
+}
+
+func (s *etagServer) counts() (requests, conditional int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.requests, s.conditional
+}
+
+func get(t *testing.T, c *http.Client, url string) (body, result string) {
+	resp, err := c.Get(url)
+	if err != nil {
+		t.Fatalf("c.Get(%q) failed with %v; want success", url, err)
+	}
+	defer resp.Body.Close()
+	buf, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("ioutil.ReadAll(resp.Body) failed with %v; want success", err)
+	}
+	return string(buf), resp.Header.Get(CacheHeader)
+}
+
+func TestCache(t *testing.T) {
+	s := new(etagServer)
+	srv := httptest.NewServer(s)
+	defer srv.Close()
+	cache := NewCache(CacheTTLs{Default: time.Hour, Statuses: 0}, 2)
+	c := &http.Client{Transport: cache}
+
+	for _, spec := range []struct {
+		path                  string
+		result                string
+		requests, conditional int
+	}{
+		{path: "/repos/o/r/commits", result: cacheMiss, requests: 1},
+		{path: "/repos/o/r/commits", result: cacheHit, requests: 1},
+		// statuses expire immediately
+		{path: "/repos/o/r/commits/abc/status", result: cacheMiss, requests: 2},
+		{path: "/repos/o/r/commits/abc/status", result: cacheRevalidated, requests: 3, conditional: 1},
+		{path: "/repos/o/r/commits", result: cacheHit, requests: 3, conditional: 1},
+		// evicts the status, which is the least recently used
+		{path: "/repos/o/r/compare/a...b", result: cacheMiss, requests: 4, conditional: 1},
+		{path: "/repos/o/r/commits/abc/status", result: cacheMiss, requests: 5, conditional: 1},
+	} {
+		body, result := get(t, c, srv.URL+spec.path)
+		if body != spec.path {
+			t.Errorf("body of %s = %q; want %q", spec.path, body, spec.path)
+		}
+		if result != spec.result {
+			t.Errorf("result of %s = %q; want %q", spec.path, result, spec.result)
+		}
+		if requests, conditional := s.counts(); requests != spec.requests || conditional != spec.conditional {
+			t.Errorf("requests after %s = %d (%d conditional); want %d (%d conditional)", spec.path, requests, conditional, spec.requests, spec.conditional)
+		}
+	}
+}
+
+func TestCacheRefresh(t *testing.T) {
+	s := new(etagServer)
+	srv := httptest.NewServer(s)
+	defer srv.Close()
+	cache := NewCache(CacheTTLs{Default: time.Millisecond}, 10)
+	c := &http.Client{Transport: cache}
+	get(t, c, srv.URL+"/repos/o/r/commits")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go cache.Refresh(ctx, 5*time.Millisecond, time.Hour)
+	deadline := time.Now().Add(time.Second)
+	for {
+		if _, conditional := s.counts(); conditional > 0 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("cached response was not revalidated in the background")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
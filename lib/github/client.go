@@ -1,6 +1,8 @@
 package github
 
 import (
+	"net/http"
+
 	"github.com/google/go-github/github"
 	"golang.org/x/oauth2"
 )
@@ -11,24 +13,38 @@ type Client interface {
 	ListTeams(string, string, *github.ListOptions) ([]github.Team, *github.Response, error)
 	ListCommits(owner, repo string, opts *github.CommitsListOptions) ([]github.RepositoryCommit, *github.Response, error)
 	GetCommit(owner, repo, sha1 string) (*github.RepositoryCommit, *github.Response, error)
+	CompareCommits(owner, repo, base, head string) (*github.CommitsComparison, *github.Response, error)
+	GetCombinedStatus(owner, repo, ref string, opt *github.ListOptions) (*github.CombinedStatus, *github.Response, error)
 	IsTeamMember(int, string) (bool, *github.Response, error)
 	IsCollaborator(string, string, string) (bool, *github.Response, error)
+	CreateIssue(owner, repo string, issue *github.IssueRequest) (*github.Issue, *github.Response, error)
+	EditIssue(owner, repo string, number int, issue *github.IssueRequest) (*github.Issue, *github.Response, error)
+	CreateIssueComment(owner, repo string, number int, comment *github.IssueComment) (*github.IssueComment, *github.Response, error)
+	CreateDeployment(owner, repo string, request *github.DeploymentRequest) (*github.Deployment, *github.Response, error)
+	CreateDeploymentStatus(owner, repo string, deployment int, request *github.DeploymentStatusRequest) (*github.DeploymentStatus, *github.Response, error)
 }
 
 type prodClient struct {
-	org  *github.OrganizationsService
-	repo *github.RepositoriesService
+	org    *github.OrganizationsService
+	repo   *github.RepositoriesService
+	issues *github.IssuesService
 }
 
 // NewClient returns a new client of Github APIs.
 // "token" must be a valid Github API access token with several scopes.
+// Responses are cached in "cache" unless it is nil.
 // TODO(yugui) Add a comprehensive list of the scopes.
-func NewClient(token string) Client {
+func NewClient(token string, cache *Cache) Client {
 	ts := oauth2.StaticTokenSource(&oauth2.Token{AccessToken: token})
-	c := github.NewClient(oauth2.NewClient(oauth2.NoContext, ts))
+	hc := oauth2.NewClient(oauth2.NoContext, ts)
+	if cache != nil {
+		hc = &http.Client{Transport: &oauth2.Transport{Source: ts, Base: cache}}
+	}
+	c := github.NewClient(hc)
 	return prodClient{
-		org:  c.Organizations,
-		repo: c.Repositories,
+		org:    c.Organizations,
+		repo:   c.Repositories,
+		issues: c.Issues,
 	}
 }
 
@@ -45,6 +61,14 @@ func (c prodClient) GetCommit(owner, repo, sha1 string) (*github.RepositoryCommi
 	return c.repo.GetCommit(owner, repo, sha1)
 }
 
+func (c prodClient) CompareCommits(owner, repo, base, head string) (*github.CommitsComparison, *github.Response, error) {
+	return c.repo.CompareCommits(owner, repo, base, head)
+}
+
+func (c prodClient) GetCombinedStatus(owner, repo, ref string, opt *github.ListOptions) (*github.CombinedStatus, *github.Response, error) {
+	return c.repo.GetCombinedStatus(owner, repo, ref, opt)
+}
+
 func (c prodClient) IsTeamMember(team int, user string) (bool, *github.Response, error) {
 	return c.org.IsTeamMember(team, user)
 }
@@ -52,3 +76,23 @@ func (c prodClient) IsTeamMember(team int, user string) (bool, *github.Response,
 func (c prodClient) IsCollaborator(owner, repo, user string) (bool, *github.Response, error) {
 	return c.repo.IsCollaborator(owner, repo, user)
 }
+
+func (c prodClient) CreateIssue(owner, repo string, issue *github.IssueRequest) (*github.Issue, *github.Response, error) {
+	return c.issues.Create(owner, repo, issue)
+}
+
+func (c prodClient) EditIssue(owner, repo string, number int, issue *github.IssueRequest) (*github.Issue, *github.Response, error) {
+	return c.issues.Edit(owner, repo, number, issue)
+}
+
+func (c prodClient) CreateIssueComment(owner, repo string, number int, comment *github.IssueComment) (*github.IssueComment, *github.Response, error) {
+	return c.issues.CreateComment(owner, repo, number, comment)
+}
+
+func (c prodClient) CreateDeployment(owner, repo string, request *github.DeploymentRequest) (*github.Deployment, *github.Response, error) {
+	return c.repo.CreateDeployment(owner, repo, request)
+}
+
+func (c prodClient) CreateDeploymentStatus(owner, repo string, deployment int, request *github.DeploymentStatusRequest) (*github.DeploymentStatus, *github.Response, error) {
+	return c.repo.CreateDeploymentStatus(owner, repo, deployment, request)
+}
@@ -34,6 +34,14 @@ func (s stub) GetCommit(owner, repo, sha1 string) (*github.RepositoryCommit, *gi
 	return nil, nil, fmt.Errorf("not implemented")
 }
 
+func (s stub) CompareCommits(owner, repo, base, head string) (*github.CommitsComparison, *github.Response, error) {
+	return nil, nil, fmt.Errorf("not implemented")
+}
+
+func (s stub) GetCombinedStatus(owner, repo, ref string, opt *github.ListOptions) (*github.CombinedStatus, *github.Response, error) {
+	return nil, nil, fmt.Errorf("not implemented")
+}
+
 func (s stub) IsTeamMember(team int, user string) (bool, *github.Response, error) {
 	if user == "read_only_user" && team == 1 {
 		return true, nil, nil
@@ -51,6 +59,26 @@ func (s stub) IsCollaborator(owner, repo, user string) (bool, *github.Response,
 	return true, nil, nil
 }
 
+func (s stub) CreateIssue(owner, repo string, issue *github.IssueRequest) (*github.Issue, *github.Response, error) {
+	return nil, nil, fmt.Errorf("not implemented")
+}
+
+func (s stub) EditIssue(owner, repo string, number int, issue *github.IssueRequest) (*github.Issue, *github.Response, error) {
+	return nil, nil, fmt.Errorf("not implemented")
+}
+
+func (s stub) CreateIssueComment(owner, repo string, number int, comment *github.IssueComment) (*github.IssueComment, *github.Response, error) {
+	return nil, nil, fmt.Errorf("not implemented")
+}
+
+func (s stub) CreateDeployment(owner, repo string, request *github.DeploymentRequest) (*github.Deployment, *github.Response, error) {
+	return nil, nil, fmt.Errorf("not implemented")
+}
+
+func (s stub) CreateDeploymentStatus(owner, repo string, deployment int, request *github.DeploymentStatusRequest) (*github.DeploymentStatus, *github.Response, error) {
+	return nil, nil, fmt.Errorf("not implemented")
+}
+
 func NewStub() githublib.Client {
 	return stub{}
 }
@@ -0,0 +1,143 @@
+package github
+
+import (
+	"time"
+
+	"github.com/gengo/goship/lib/metrics"
+	"github.com/google/go-github/github"
+)
+
+// Features of Goship which call github APIs.
+const (
+	// FeatureCommits is the commits view, which reads revisions and diffs of repositories.
+	FeatureCommits = "commits"
+	// FeatureACL is the access control, which checks collaborators and team members of repositories.
+	FeatureACL = "acl"
+	// FeatureIssues is the issues of environments which fail deployments repeatedly.
+	FeatureIssues = "issues"
+	// FeatureDeployments is the deployments and their statuses reported to repositories.
+	FeatureDeployments = "deployments"
+)
+
+var (
+	apiCalls   = metrics.NewCounterVec("goship_github_api_calls_total", "Number of github API calls.", "feature", "method")
+	apiLatency = metrics.NewHistogramVec("goship_github_api_duration_seconds", "Latency of github API calls.", metrics.DefBuckets, "feature", "method")
+	apiErrors  = metrics.NewCounterVec("goship_github_api_errors_total", "Number of failed github API calls.", "feature", "method")
+
+	rateLimitRemaining = metrics.NewGaugeVec("goship_github_rate_limit_remaining", "Number of github API calls remaining in the current rate limit window.")
+	rateLimitReset     = metrics.NewGaugeVec("goship_github_rate_limit_reset_timestamp_seconds", "Unix time when the current github rate limit window resets.")
+)
+
+// Instrument decorates "c" with metrics of the number, latency and errors of API calls made by "feature".
+// The calls are also counted in DefaultUsage.
+func Instrument(c Client, feature string) Client {
+	return instrumentedClient{c: c, feature: feature}
+}
+
+type instrumentedClient struct {
+	c       Client
+	feature string
+}
+
+// observe records the latency and the result of an API call which started at "start".
+// Calls served from Cache without asking github are not counted in DefaultUsage.
+func (c instrumentedClient) observe(method string, start time.Time, resp *github.Response, err error) {
+	apiCalls.Inc(c.feature, method)
+	apiLatency.Observe(time.Since(start).Seconds(), c.feature, method)
+	if err != nil {
+		apiErrors.Inc(c.feature, method)
+	}
+	if resp != nil && resp.Response != nil && resp.Header.Get(CacheHeader) == cacheHit {
+		return
+	}
+	DefaultUsage.Add(c.feature, start)
+	if resp != nil && resp.Limit > 0 {
+		rateLimitRemaining.Set(float64(resp.Remaining))
+		rateLimitReset.Set(float64(resp.Reset.Unix()))
+		DefaultUsage.SetRate(resp.Rate)
+	}
+}
+
+func (c instrumentedClient) ListTeams(owner string, repo string, opt *github.ListOptions) ([]github.Team, *github.Response, error) {
+	start := time.Now()
+	teams, resp, err := c.c.ListTeams(owner, repo, opt)
+	c.observe("ListTeams", start, resp, err)
+	return teams, resp, err
+}
+
+func (c instrumentedClient) ListCommits(owner, repo string, opt *github.CommitsListOptions) ([]github.RepositoryCommit, *github.Response, error) {
+	start := time.Now()
+	commits, resp, err := c.c.ListCommits(owner, repo, opt)
+	c.observe("ListCommits", start, resp, err)
+	return commits, resp, err
+}
+
+func (c instrumentedClient) GetCommit(owner, repo, sha1 string) (*github.RepositoryCommit, *github.Response, error) {
+	start := time.Now()
+	commit, resp, err := c.c.GetCommit(owner, repo, sha1)
+	c.observe("GetCommit", start, resp, err)
+	return commit, resp, err
+}
+
+func (c instrumentedClient) CompareCommits(owner, repo, base, head string) (*github.CommitsComparison, *github.Response, error) {
+	start := time.Now()
+	comp, resp, err := c.c.CompareCommits(owner, repo, base, head)
+	c.observe("CompareCommits", start, resp, err)
+	return comp, resp, err
+}
+
+func (c instrumentedClient) GetCombinedStatus(owner, repo, ref string, opt *github.ListOptions) (*github.CombinedStatus, *github.Response, error) {
+	start := time.Now()
+	st, resp, err := c.c.GetCombinedStatus(owner, repo, ref, opt)
+	c.observe("GetCombinedStatus", start, resp, err)
+	return st, resp, err
+}
+
+func (c instrumentedClient) IsTeamMember(team int, user string) (bool, *github.Response, error) {
+	start := time.Now()
+	ok, resp, err := c.c.IsTeamMember(team, user)
+	c.observe("IsTeamMember", start, resp, err)
+	return ok, resp, err
+}
+
+func (c instrumentedClient) IsCollaborator(owner, repo, user string) (bool, *github.Response, error) {
+	start := time.Now()
+	ok, resp, err := c.c.IsCollaborator(owner, repo, user)
+	c.observe("IsCollaborator", start, resp, err)
+	return ok, resp, err
+}
+
+func (c instrumentedClient) CreateIssue(owner, repo string, issue *github.IssueRequest) (*github.Issue, *github.Response, error) {
+	start := time.Now()
+	i, resp, err := c.c.CreateIssue(owner, repo, issue)
+	c.observe("CreateIssue", start, resp, err)
+	return i, resp, err
+}
+
+func (c instrumentedClient) EditIssue(owner, repo string, number int, issue *github.IssueRequest) (*github.Issue, *github.Response, error) {
+	start := time.Now()
+	i, resp, err := c.c.EditIssue(owner, repo, number, issue)
+	c.observe("EditIssue", start, resp, err)
+	return i, resp, err
+}
+
+func (c instrumentedClient) CreateIssueComment(owner, repo string, number int, comment *github.IssueComment) (*github.IssueComment, *github.Response, error) {
+	start := time.Now()
+	cmt, resp, err := c.c.CreateIssueComment(owner, repo, number, comment)
+	c.observe("CreateIssueComment", start, resp, err)
+	return cmt, resp, err
+}
+
+func (c instrumentedClient) CreateDeployment(owner, repo string, request *github.DeploymentRequest) (*github.Deployment, *github.Response, error) {
+	start := time.Now()
+	d, resp, err := c.c.CreateDeployment(owner, repo, request)
+	c.observe("CreateDeployment", start, resp, err)
+	return d, resp, err
+}
+
+func (c instrumentedClient) CreateDeploymentStatus(owner, repo string, deployment int, request *github.DeploymentStatusRequest) (*github.DeploymentStatus, *github.Response, error) {
+	start := time.Now()
+	st, resp, err := c.c.CreateDeploymentStatus(owner, repo, deployment, request)
+	c.observe("CreateDeploymentStatus", start, resp, err)
+	return st, resp, err
+}
@@ -0,0 +1,110 @@
+package github
+
+import (
+	"net/http"
+	"sync"
+	"time"
+
+	helpers "github.com/gengo/goship/lib/view-helpers"
+	"github.com/golang/glog"
+	"github.com/google/go-github/github"
+)
+
+const (
+	// usageHours is the number of hours which DefaultUsage keeps.
+	usageHours = 24
+	// LowRateLimit is the ratio of remaining calls to the rate limit below which Usage logs warnings.
+	LowRateLimit = 0.1
+)
+
+// DefaultUsage counts the API calls of the clients returned by Instrument.
+var DefaultUsage = NewUsage(usageHours)
+
+// Usage counts github API calls per feature in hourly buckets.
+type Usage struct {
+	mu    sync.Mutex
+	hours int
+	// calls maps the start of an hour to the number of calls per feature in the hour.
+	calls map[time.Time]map[string]int
+	rate  *github.Rate
+	// warned is the reset time of the rate limit window in which a low rate limit has been logged.
+	warned time.Time
+}
+
+// NewUsage returns a new Usage which keeps the counts of the last "hours" hours.
+func NewUsage(hours int) *Usage {
+	return &Usage{hours: hours, calls: make(map[time.Time]map[string]int)}
+}
+
+// Add counts a call by "feature" at "t".
+func (u *Usage) Add(feature string, t time.Time) {
+	hour := t.UTC().Truncate(time.Hour)
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	if u.calls[hour] == nil {
+		u.calls[hour] = make(map[string]int)
+	}
+	u.calls[hour][feature]++
+	for h := range u.calls {
+		if !h.After(hour.Add(-time.Duration(u.hours) * time.Hour)) {
+			delete(u.calls, h)
+		}
+	}
+}
+
+// SetRate records "r" as the latest known rate limit.
+// It logs a warning once per rate limit window when the remaining calls drop below LowRateLimit of the limit.
+func (u *Usage) SetRate(r github.Rate) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	u.rate = &r
+	if r.Limit > 0 && float64(r.Remaining) < LowRateLimit*float64(r.Limit) && !r.Reset.Time.Equal(u.warned) {
+		u.warned = r.Reset.Time
+		glog.Warningf("Github API rate limit is running low: %d of %d calls remaining until %s", r.Remaining, r.Limit, r.Reset.Time.Format(time.RFC3339))
+	}
+}
+
+// HourlyUsage is the number of API calls per feature in an hour.
+type HourlyUsage struct {
+	// Hour is the start of the hour in UTC.
+	Hour  time.Time      `json:"hour"`
+	Calls map[string]int `json:"calls"`
+	Total int            `json:"total"`
+}
+
+// Hourly returns the usage in each of the last hours until "now", newest first.
+// Hours without calls are included with zero counts.
+func (u *Usage) Hourly(now time.Time) []HourlyUsage {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	current := now.UTC().Truncate(time.Hour)
+	result := make([]HourlyUsage, 0, u.hours)
+	for i := 0; i < u.hours; i++ {
+		hour := current.Add(-time.Duration(i) * time.Hour)
+		hu := HourlyUsage{Hour: hour, Calls: make(map[string]int)}
+		for feature, n := range u.calls[hour] {
+			hu.Calls[feature] = n
+			hu.Total += n
+		}
+		result = append(result, hu)
+	}
+	return result
+}
+
+// usageReport is the JSON representation of a Usage.
+type usageReport struct {
+	// RateLimit is the latest rate limit returned by github, or nil if unknown.
+	RateLimit *github.Rate  `json:"rate_limit"`
+	Hourly    []HourlyUsage `json:"hourly"`
+}
+
+// Handler returns an http.Handler which serves the usage in "u" as JSON.
+func (u *Usage) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		report := usageReport{Hourly: u.Hourly(time.Now())}
+		u.mu.Lock()
+		report.RateLimit = u.rate
+		u.mu.Unlock()
+		helpers.RespondWithJSON(w, http.StatusOK, report)
+	})
+}
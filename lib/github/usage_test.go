@@ -0,0 +1,27 @@
+package github
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestUsageHourly(t *testing.T) {
+	u := NewUsage(3)
+	now := time.Date(2015, 11, 10, 14, 30, 0, 0, time.UTC)
+	u.Add(FeatureCommits, now.Add(-3*time.Hour))
+	u.Add(FeatureCommits, now.Add(-time.Hour))
+	u.Add(FeatureACL, now.Add(-time.Hour))
+	u.Add(FeatureACL, now.Add(-time.Hour))
+	u.Add(FeatureCommits, now)
+
+	got := u.Hourly(now)
+	want := []HourlyUsage{
+		{Hour: time.Date(2015, 11, 10, 14, 0, 0, 0, time.UTC), Calls: map[string]int{FeatureCommits: 1}, Total: 1},
+		{Hour: time.Date(2015, 11, 10, 13, 0, 0, 0, time.UTC), Calls: map[string]int{FeatureCommits: 1, FeatureACL: 2}, Total: 3},
+		{Hour: time.Date(2015, 11, 10, 12, 0, 0, 0, time.UTC), Calls: map[string]int{}, Total: 0},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("u.Hourly(%v) = %#v; want %#v", now, got, want)
+	}
+}
@@ -0,0 +1,356 @@
+// Package hostimport adds hosts to environments in bulk from CSV files or Ansible inventories.
+package hostimport
+
+import (
+	"bufio"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"net"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gengo/goship/lib/config"
+)
+
+// sshPort is the port which reachability checks connect to if a host does not have a port.
+const sshPort = "22"
+
+// maxConcurrentChecks is the maximum number of hosts whose reachability is checked concurrently.
+const maxConcurrentChecks = 16
+
+// Host is a host to add to an environment.
+type Host struct {
+	Project     string `json:"project"`
+	Environment string `json:"environment"`
+	Host        string `json:"host"`
+	// Group is the name of the host group of the environment which the host is added to, if any.
+	Group string `json:"group,omitempty"`
+}
+
+// Status is the outcome of a host in an import.
+type Status string
+
+const (
+	// StatusAdded means the host is added to the environment.
+	StatusAdded = Status("added")
+	// StatusDuplicate means the host is skipped because the environment or the import already has it.
+	StatusDuplicate = Status("duplicate")
+	// StatusInvalid means the host is skipped because it or its environment is invalid.
+	StatusInvalid = Status("invalid")
+	// StatusUnreachable means the host is skipped because its SSH port did not accept a connection.
+	StatusUnreachable = Status("unreachable")
+)
+
+// Result is the outcome of a host in an import.
+type Result struct {
+	Host
+	Status Status `json:"status"`
+	// Error describes why the host is skipped.
+	Error string `json:"error,omitempty"`
+}
+
+// ParseCSV reads hosts from CSV in "r".
+// The first line is a header which names the columns "project", "environment", "host" and optionally "group" in any order.
+// Empty lines and lines starting with '#' are ignored.
+func ParseCSV(r io.Reader) ([]Host, error) {
+	cr := csv.NewReader(r)
+	cr.Comment = '#'
+	cr.FieldsPerRecord = -1
+	cr.TrimLeadingSpace = true
+	header, err := cr.Read()
+	if err == io.EOF {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	cols := map[string]int{"group": -1}
+	for i, name := range header {
+		cols[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	for _, name := range []string{"project", "environment", "host"} {
+		if _, ok := cols[name]; !ok {
+			return nil, fmt.Errorf("missing column %q in the header", name)
+		}
+	}
+	field := func(rec []string, name string) string {
+		if i := cols[name]; i >= 0 && i < len(rec) {
+			return strings.TrimSpace(rec[i])
+		}
+		return ""
+	}
+
+	var hosts []Host
+	for {
+		rec, err := cr.Read()
+		if err == io.EOF {
+			return hosts, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		hosts = append(hosts, Host{
+			Project:     field(rec, "project"),
+			Environment: field(rec, "environment"),
+			Host:        field(rec, "host"),
+			Group:       field(rec, "group"),
+		})
+	}
+}
+
+// ParseAnsible reads the hosts of "group" from an Ansible inventory in the INI format in "r".
+// All the hosts in the inventory are returned if "group" is empty.
+// Children of groups are expanded, and the "ansible_host" and "ansible_port" variables of hosts override their names.
+func ParseAnsible(r io.Reader, group string) ([]string, error) {
+	var (
+		// members are the hosts of the groups in the order of appearance.
+		members  = make(map[string][]string)
+		children = make(map[string][]string)
+		order    []string
+		section  = "ungrouped"
+		kind     string
+	)
+	s := bufio.NewScanner(r)
+	for n := 1; s.Scan(); n++ {
+		line := strings.TrimSpace(s.Text())
+		if line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, ";") {
+			continue
+		}
+		if strings.HasPrefix(line, "[") {
+			if !strings.HasSuffix(line, "]") {
+				return nil, fmt.Errorf("line %d: invalid section %q", n, line)
+			}
+			section, kind = strings.Trim(line, "[]"), ""
+			if i := strings.Index(section, ":"); i >= 0 {
+				section, kind = section[:i], section[i+1:]
+			}
+			continue
+		}
+		fields := strings.Fields(line)
+		switch kind {
+		case "":
+			host := fields[0]
+			var port string
+			for _, v := range fields[1:] {
+				kv := strings.SplitN(v, "=", 2)
+				if len(kv) != 2 {
+					continue
+				}
+				switch kv[0] {
+				case "ansible_host", "ansible_ssh_host":
+					host = kv[1]
+				case "ansible_port", "ansible_ssh_port":
+					port = kv[1]
+				}
+			}
+			if port != "" {
+				host = net.JoinHostPort(host, port)
+			}
+			if _, ok := members[host]; !ok {
+				order = append(order, host)
+				members[host] = nil
+			}
+			members[host] = append(members[host], section)
+		case "children":
+			children[section] = append(children[section], fields[0])
+		case "vars":
+		default:
+			return nil, fmt.Errorf("line %d: unknown section type %q", n, kind)
+		}
+	}
+	if err := s.Err(); err != nil {
+		return nil, err
+	}
+	if group == "" || group == "all" {
+		return order, nil
+	}
+
+	groups := map[string]bool{group: true}
+	for queue := []string{group}; len(queue) > 0; queue = queue[1:] {
+		for _, c := range children[queue[0]] {
+			if !groups[c] {
+				groups[c] = true
+				queue = append(queue, c)
+			}
+		}
+	}
+	var hosts []string
+	for _, h := range order {
+		for _, g := range members[h] {
+			if groups[g] {
+				hosts = append(hosts, h)
+				break
+			}
+		}
+	}
+	if len(hosts) == 0 {
+		return nil, fmt.Errorf("no hosts in group %q", group)
+	}
+	return hosts, nil
+}
+
+// Validate returns the results of adding "hosts" to the environments in "c".
+// Hosts of unknown environments or host groups, hosts which environments resolve from their inventories
+// and hosts which the environments or "hosts" already have are skipped.
+func Validate(c config.Config, hosts []Host) []Result {
+	seen := make(map[string]bool)
+	results := make([]Result, 0, len(hosts))
+	for _, h := range hosts {
+		r := Result{Host: h, Status: StatusAdded}
+		if err := validate(c, h); err != nil {
+			r.Status, r.Error = StatusInvalid, err.Error()
+		} else if key := h.Project + "\x00" + h.Environment + "\x00" + h.Host; seen[key] {
+			r.Status, r.Error = StatusDuplicate, "listed more than once"
+		} else {
+			seen[key] = true
+			env, _ := environment(c, h)
+			if contains(env.Hosts, h.Host) {
+				r.Status, r.Error = StatusDuplicate, fmt.Sprintf("already in %s-%s", h.Project, h.Environment)
+			}
+		}
+		results = append(results, r)
+	}
+	return results
+}
+
+func validate(c config.Config, h Host) error {
+	if h.Host == "" {
+		return fmt.Errorf("empty host")
+	}
+	if strings.ContainsAny(h.Host, " \t/@") {
+		return fmt.Errorf("invalid host %q", h.Host)
+	}
+	env, err := environment(c, h)
+	if err != nil {
+		return err
+	}
+	if env.Inventory != nil {
+		return fmt.Errorf("hosts of %s-%s are resolved from its inventory", h.Project, h.Environment)
+	}
+	if h.Group == "" {
+		return nil
+	}
+	for _, g := range env.HostGroups {
+		if g.Name == h.Group {
+			return nil
+		}
+	}
+	return fmt.Errorf("no host group %q in %s-%s", h.Group, h.Project, h.Environment)
+}
+
+func environment(c config.Config, h Host) (config.Environment, error) {
+	proj, err := config.ProjectFromName(c.Projects, h.Project)
+	if err != nil {
+		return config.Environment{}, fmt.Errorf("no project %q", h.Project)
+	}
+	env, err := config.EnvironmentFromName(c.Projects, proj.Name, h.Environment)
+	if err != nil {
+		return config.Environment{}, fmt.Errorf("no environment %q in %s", h.Environment, h.Project)
+	}
+	return *env, nil
+}
+
+// Check marks the results to be added as unreachable if the SSH ports of their hosts do not accept TCP connections within "timeout".
+func Check(results []Result, timeout time.Duration) {
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, maxConcurrentChecks)
+	for i := range results {
+		if results[i].Status != StatusAdded {
+			continue
+		}
+		wg.Add(1)
+		go func(r *Result) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+			conn, err := net.DialTimeout("tcp", withPort(r.Host.Host), timeout)
+			if err != nil {
+				r.Status, r.Error = StatusUnreachable, err.Error()
+				return
+			}
+			conn.Close()
+		}(&results[i])
+	}
+	wg.Wait()
+}
+
+// Apply adds the hosts of the results to be added to the projects in "c".
+// It returns the changed projects in the order of their names.
+func Apply(c config.Config, results []Result) []config.Project {
+	changed := make(map[string]*config.Project)
+	for _, r := range results {
+		if r.Status != StatusAdded {
+			continue
+		}
+		p, ok := changed[r.Project]
+		if !ok {
+			proj, err := config.ProjectFromName(c.Projects, r.Project)
+			if err != nil {
+				continue
+			}
+			p = copyProject(proj)
+			changed[r.Project] = p
+		}
+		for i := range p.Environments {
+			env := &p.Environments[i]
+			if env.Name != r.Environment {
+				continue
+			}
+			env.Hosts = append(env.Hosts, r.Host.Host)
+			for j := range env.HostGroups {
+				if env.HostGroups[j].Name == r.Group {
+					env.HostGroups[j].Hosts = append(env.HostGroups[j].Hosts, r.Host.Host)
+				}
+			}
+		}
+	}
+	var names []string
+	for name := range changed {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	projects := make([]config.Project, 0, len(names))
+	for _, name := range names {
+		projects = append(projects, *changed[name])
+	}
+	return projects
+}
+
+// copyProject returns a copy of "p" whose hosts can be appended without changing "p".
+func copyProject(p config.Project) *config.Project {
+	envs := make([]config.Environment, len(p.Environments))
+	for i, e := range p.Environments {
+		e.Hosts = append([]string(nil), e.Hosts...)
+		groups := make([]config.HostGroup, len(e.HostGroups))
+		for j, g := range e.HostGroups {
+			g.Hosts = append([]string(nil), g.Hosts...)
+			groups[j] = g
+		}
+		if e.HostGroups != nil {
+			e.HostGroups = groups
+		}
+		envs[i] = e
+	}
+	p.Environments = envs
+	return &p
+}
+
+// withPort returns "host" with the port of SSH if it does not have a port.
+func withPort(host string) string {
+	if _, _, err := net.SplitHostPort(host); err == nil {
+		return host
+	}
+	return net.JoinHostPort(host, sshPort)
+}
+
+func contains(hosts []string, host string) bool {
+	for _, h := range hosts {
+		if h == host {
+			return true
+		}
+	}
+	return false
+}
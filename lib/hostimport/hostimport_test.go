@@ -0,0 +1,149 @@
+package hostimport
+
+import (
+	"net"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gengo/goship/lib/config"
+)
+
+func TestParseCSV(t *testing.T) {
+	in := `# hosts of the new datacenter
+environment, project, host, group
+production, proj, web-1.example.com, web
+staging, proj, 10.0.0.1:2222
+`
+	got, err := ParseCSV(strings.NewReader(in))
+	if err != nil {
+		t.Fatalf("ParseCSV(%q) failed with %v; want success", in, err)
+	}
+	want := []Host{
+		{Project: "proj", Environment: "production", Host: "web-1.example.com", Group: "web"},
+		{Project: "proj", Environment: "staging", Host: "10.0.0.1:2222"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ParseCSV(%q) = %#v; want %#v", in, got, want)
+	}
+
+	in = "project,host\nproj,web-1\n"
+	if _, err := ParseCSV(strings.NewReader(in)); err == nil {
+		t.Errorf("ParseCSV(%q) succeeded; want failure without the environment column", in)
+	}
+}
+
+func TestParseAnsible(t *testing.T) {
+	in := `bastion.example.com
+
+[web]
+web-1.example.com
+web-2 ansible_host=10.0.0.2 ansible_port=2222
+
+[workers]
+worker-1.example.com
+
+[app:children]
+web
+workers
+
+[app:vars]
+ntp_server=ntp.example.com
+`
+	for _, spec := range []struct {
+		group string
+		want  []string
+	}{
+		{group: "", want: []string{"bastion.example.com", "web-1.example.com", "10.0.0.2:2222", "worker-1.example.com"}},
+		{group: "web", want: []string{"web-1.example.com", "10.0.0.2:2222"}},
+		{group: "app", want: []string{"web-1.example.com", "10.0.0.2:2222", "worker-1.example.com"}},
+	} {
+		got, err := ParseAnsible(strings.NewReader(in), spec.group)
+		if err != nil {
+			t.Errorf("ParseAnsible(%q, %q) failed with %v; want success", in, spec.group, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, spec.want) {
+			t.Errorf("ParseAnsible(%q, %q) = %q; want %q", in, spec.group, got, spec.want)
+		}
+	}
+	if _, err := ParseAnsible(strings.NewReader(in), "db"); err == nil {
+		t.Errorf("ParseAnsible(%q, %q) succeeded; want failure for an unknown group", in, "db")
+	}
+}
+
+func TestValidateAndApply(t *testing.T) {
+	c := config.Config{
+		Projects: []config.Project{
+			{
+				Name: "proj",
+				Environments: []config.Environment{
+					{Name: "production", Hosts: []string{"web-1"}, HostGroups: []config.HostGroup{{Name: "web", Hosts: []string{"web-1"}}}},
+					{Name: "dynamic", Inventory: &config.Inventory{}},
+				},
+			},
+		},
+	}
+	hosts := []Host{
+		{Project: "proj", Environment: "production", Host: "web-1", Group: "web"},
+		{Project: "proj", Environment: "production", Host: "web-2", Group: "web"},
+		{Project: "proj", Environment: "production", Host: "web-2", Group: "web"},
+		{Project: "proj", Environment: "production", Host: "worker-1", Group: "workers"},
+		{Project: "proj", Environment: "staging", Host: "web-3"},
+		{Project: "proj", Environment: "dynamic", Host: "web-4"},
+		{Project: "proj", Environment: "production", Host: "root@web-5"},
+		{Project: "proj", Environment: "production", Host: "db-1"},
+	}
+	results := Validate(c, hosts)
+	var got []Status
+	for _, r := range results {
+		got = append(got, r.Status)
+	}
+	want := []Status{StatusDuplicate, StatusAdded, StatusDuplicate, StatusInvalid, StatusInvalid, StatusInvalid, StatusInvalid, StatusAdded}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("statuses of Validate(c, hosts) = %q; want %q", got, want)
+	}
+
+	projects := Apply(c, results)
+	if len(projects) != 1 {
+		t.Fatalf("Apply(c, results) = %#v; want one project", projects)
+	}
+	env := projects[0].Environments[0]
+	if got, want := env.Hosts, []string{"web-1", "web-2", "db-1"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("hosts after Apply(c, results) = %q; want %q", got, want)
+	}
+	if got, want := env.HostGroups[0].Hosts, []string{"web-1", "web-2"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("hosts of group web after Apply(c, results) = %q; want %q", got, want)
+	}
+	if got, want := c.Projects[0].Environments[0].Hosts, []string{"web-1"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Apply(c, results) changed the hosts in c to %q; want %q", got, want)
+	}
+}
+
+func TestCheck(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("net.Listen(%q, %q) failed with %v; want success", "tcp", "127.0.0.1:0", err)
+	}
+	defer l.Close()
+	closed, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("net.Listen(%q, %q) failed with %v; want success", "tcp", "127.0.0.1:0", err)
+	}
+	closed.Close()
+
+	results := []Result{
+		{Host: Host{Host: l.Addr().String()}, Status: StatusAdded},
+		{Host: Host{Host: closed.Addr().String()}, Status: StatusAdded},
+		{Host: Host{Host: closed.Addr().String()}, Status: StatusDuplicate},
+	}
+	Check(results, time.Second)
+	var got []Status
+	for _, r := range results {
+		got = append(got, r.Status)
+	}
+	if want := []Status{StatusAdded, StatusUnreachable, StatusDuplicate}; !reflect.DeepEqual(got, want) {
+		t.Errorf("statuses after Check(results, time.Second) = %q; want %q", got, want)
+	}
+}
@@ -0,0 +1,64 @@
+package inventory
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/gengo/goship/lib/config"
+	"golang.org/x/net/context"
+)
+
+// defaultConsulAddress is the address of the local Consul agent.
+const defaultConsulAddress = "http://127.0.0.1:8500"
+
+// Consul resolves the hosts from the instances of a Consul service which pass their health checks.
+// CONSUL_HTTP_TOKEN is sent as the ACL token if set.
+type Consul struct {
+	// Token is the ACL token of Consul. CONSUL_HTTP_TOKEN is used if empty.
+	Token string
+}
+
+type consulEntry struct {
+	Node struct {
+		Address string
+	}
+	Service struct {
+		Address string
+	}
+}
+
+// Hosts implements Provider.
+func (c Consul) Hosts(ctx context.Context, inv config.Inventory) ([]string, error) {
+	addr := inv.Address
+	if addr == "" {
+		addr = defaultConsulAddress
+	}
+	q := url.Values{"passing": {"1"}}
+	if inv.Tag != "" {
+		q.Set("tag", inv.Tag)
+	}
+	if inv.Datacenter != "" {
+		q.Set("dc", inv.Datacenter)
+	}
+	u := fmt.Sprintf("%s/v1/health/service/%s?%s", strings.TrimSuffix(addr, "/"), url.QueryEscape(inv.Service), q.Encode())
+	req, err := http.NewRequest("GET", u, nil)
+	if err != nil {
+		return nil, err
+	}
+	if token := firstNonEmpty(c.Token, os.Getenv("CONSUL_HTTP_TOKEN")); token != "" {
+		req.Header.Set("X-Consul-Token", token)
+	}
+	var entries []consulEntry
+	if err := getJSON(req, httpClient, &entries); err != nil {
+		return nil, err
+	}
+	var hosts []string
+	for _, e := range entries {
+		// The service address is empty if it is the same as the node address.
+		hosts = append(hosts, firstNonEmpty(e.Service.Address, e.Node.Address))
+	}
+	return hosts, nil
+}
@@ -0,0 +1,104 @@
+package inventory
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"sort"
+	"time"
+
+	"github.com/gengo/goship/lib/awsauth"
+	"github.com/gengo/goship/lib/config"
+	"golang.org/x/net/context"
+)
+
+const ec2APIVersion = "2016-11-15"
+
+// EC2 resolves the hosts from the running EC2 instances with the tags.
+// Credentials are read from AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY and AWS_SESSION_TOKEN,
+// or from the IAM role of the instance if they are not set.
+type EC2 struct {
+	// Endpoint is the base URL of the EC2 API. The one of the region is used if empty.
+	Endpoint string
+}
+
+type ec2DescribeInstancesResponse struct {
+	Reservations []struct {
+		Instances []struct {
+			PrivateIPAddress string `xml:"privateIpAddress"`
+			IPAddress        string `xml:"ipAddress"`
+		} `xml:"instancesSet>item"`
+	} `xml:"reservationSet>item"`
+	NextToken string `xml:"nextToken"`
+}
+
+// Hosts implements Provider.
+func (e EC2) Hosts(ctx context.Context, inv config.Inventory) ([]string, error) {
+	creds, err := awsauth.LoadCredentials()
+	if err != nil {
+		return nil, err
+	}
+	endpoint := firstNonEmpty(e.Endpoint, fmt.Sprintf("https://ec2.%s.amazonaws.com", inv.Region))
+	q := url.Values{
+		"Action":           {"DescribeInstances"},
+		"Version":          {ec2APIVersion},
+		"Filter.1.Name":    {"instance-state-name"},
+		"Filter.1.Value.1": {"running"},
+	}
+	var keys []string
+	for k := range inv.Tags {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for i, k := range keys {
+		q.Set(fmt.Sprintf("Filter.%d.Name", i+2), "tag:"+k)
+		q.Set(fmt.Sprintf("Filter.%d.Value.1", i+2), inv.Tags[k])
+	}
+
+	var hosts []string
+	for {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		req, err := http.NewRequest("GET", endpoint+"/?"+q.Encode(), nil)
+		if err != nil {
+			return nil, err
+		}
+		awsauth.Sign(req, nil, creds, inv.Region, "ec2", time.Now())
+		var page ec2DescribeInstancesResponse
+		if err := getXML(req, &page); err != nil {
+			return nil, err
+		}
+		for _, r := range page.Reservations {
+			for _, in := range r.Instances {
+				addr := in.PrivateIPAddress
+				if inv.PublicAddress {
+					addr = in.IPAddress
+				}
+				if addr != "" {
+					hosts = append(hosts, addr)
+				}
+			}
+		}
+		if page.NextToken == "" {
+			return hosts, nil
+		}
+		q.Set("NextToken", page.NextToken)
+	}
+}
+
+func getXML(req *http.Request, v interface{}) error {
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		// AWS describes the error in the body, e.g. UnauthorizedOperation.
+		body, _ := ioutil.ReadAll(resp.Body)
+		return fmt.Errorf("unexpected HTTP status %d from %s: %s", resp.StatusCode, req.URL.Host, body)
+	}
+	return xml.NewDecoder(resp.Body).Decode(v)
+}
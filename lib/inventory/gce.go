@@ -0,0 +1,140 @@
+package inventory
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+
+	"github.com/gengo/goship/lib/config"
+	"golang.org/x/net/context"
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/google"
+)
+
+const (
+	// ComputeScope is the OAuth2 scope needed to list Compute Engine instances.
+	ComputeScope = "https://www.googleapis.com/auth/compute.readonly"
+
+	computeEndpoint = "https://www.googleapis.com/compute/v1"
+)
+
+// GCE resolves the hosts from the running Compute Engine instances with the labels.
+type GCE struct {
+	// Client is authorized to call the Compute Engine API.
+	// The application default credentials are used if nil.
+	Client *http.Client
+	// Endpoint is the base URL of the Compute Engine API. The public one is used if empty.
+	Endpoint string
+}
+
+type gceInstance struct {
+	Status            string            `json:"status"`
+	Labels            map[string]string `json:"labels"`
+	NetworkInterfaces []struct {
+		NetworkIP     string `json:"networkIP"`
+		AccessConfigs []struct {
+			NatIP string `json:"natIP"`
+		} `json:"accessConfigs"`
+	} `json:"networkInterfaces"`
+}
+
+// gceInstanceList is a page of either zonal or aggregated lists of instances.
+type gceInstanceList struct {
+	Items         json.RawMessage `json:"items"`
+	NextPageToken string          `json:"nextPageToken"`
+}
+
+// Hosts implements Provider.
+func (g GCE) Hosts(ctx context.Context, inv config.Inventory) ([]string, error) {
+	client := g.Client
+	if client == nil {
+		ts, err := google.DefaultTokenSource(ctx, ComputeScope)
+		if err != nil {
+			return nil, err
+		}
+		client = oauth2.NewClient(ctx, ts)
+	}
+	endpoint := firstNonEmpty(g.Endpoint, computeEndpoint)
+	u := fmt.Sprintf("%s/projects/%s/aggregated/instances", endpoint, url.QueryEscape(inv.Project))
+	if inv.Zone != "" {
+		u = fmt.Sprintf("%s/projects/%s/zones/%s/instances", endpoint, url.QueryEscape(inv.Project), url.QueryEscape(inv.Zone))
+	}
+
+	var hosts []string
+	pageToken := ""
+	for {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		req, err := http.NewRequest("GET", u, nil)
+		if err != nil {
+			return nil, err
+		}
+		if pageToken != "" {
+			req.URL.RawQuery = url.Values{"pageToken": {pageToken}}.Encode()
+		}
+		var page gceInstanceList
+		if err := getJSON(req, client, &page); err != nil {
+			return nil, err
+		}
+		instances, err := page.instances(inv.Zone != "")
+		if err != nil {
+			return nil, err
+		}
+		for _, in := range instances {
+			if addr := in.address(inv); addr != "" {
+				hosts = append(hosts, addr)
+			}
+		}
+		if page.NextPageToken == "" {
+			return hosts, nil
+		}
+		pageToken = page.NextPageToken
+	}
+}
+
+// instances decodes the items of "l", which are a list of instances if "zonal", or otherwise a map from zones to lists of instances.
+func (l gceInstanceList) instances(zonal bool) ([]gceInstance, error) {
+	if len(l.Items) == 0 {
+		return nil, nil
+	}
+	if zonal {
+		var instances []gceInstance
+		err := json.Unmarshal(l.Items, &instances)
+		return instances, err
+	}
+	var scopes map[string]struct {
+		Instances []gceInstance `json:"instances"`
+	}
+	if err := json.Unmarshal(l.Items, &scopes); err != nil {
+		return nil, err
+	}
+	var instances []gceInstance
+	for _, s := range scopes {
+		instances = append(instances, s.Instances...)
+	}
+	return instances, nil
+}
+
+// address returns the address of "in" if it is running and has all the labels of "inv", or "" otherwise.
+func (in gceInstance) address(inv config.Inventory) string {
+	if in.Status != "RUNNING" || len(in.NetworkInterfaces) == 0 {
+		return ""
+	}
+	for k, v := range inv.Labels {
+		if in.Labels[k] != v {
+			return ""
+		}
+	}
+	nic := in.NetworkInterfaces[0]
+	if !inv.PublicAddress {
+		return nic.NetworkIP
+	}
+	for _, ac := range nic.AccessConfigs {
+		if ac.NatIP != "" {
+			return ac.NatIP
+		}
+	}
+	return ""
+}
@@ -0,0 +1,143 @@
+// Package inventory resolves the hosts of environments from cloud providers and service catalogs.
+package inventory
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/gengo/goship/lib/config"
+	"golang.org/x/net/context"
+)
+
+// CacheTTL is how long Cached reuses resolved hosts.
+const CacheTTL = time.Minute
+
+// httpClient is used to call the APIs of the providers.
+var httpClient = &http.Client{Timeout: 30 * time.Second}
+
+// Provider resolves hosts from a source of inventories.
+type Provider interface {
+	// Hosts returns the addresses of the instances selected by "inv".
+	Hosts(ctx context.Context, inv config.Inventory) ([]string, error)
+}
+
+// Providers maps the types of inventories to their providers.
+var Providers = map[config.InventoryProvider]Provider{
+	config.InventoryEC2:    EC2{},
+	config.InventoryGCE:    GCE{},
+	config.InventoryConsul: Consul{},
+}
+
+// Resolve returns "env" whose hosts are resolved from its inventory, or "env" itself if it has no inventory.
+// It fails if no instances are found so that a deployment never falls back to the local host by accident.
+func Resolve(ctx context.Context, env config.Environment) (config.Environment, error) {
+	inv := env.Inventory
+	if inv == nil {
+		return env, nil
+	}
+	p, ok := Providers[inv.Provider]
+	if !ok {
+		return env, fmt.Errorf("inventory provider %q not available", inv.Provider)
+	}
+	hosts, err := p.Hosts(ctx, *inv)
+	if err != nil {
+		return env, fmt.Errorf("failed to resolve hosts of %s from %s: %v", env.Name, inv.Provider, err)
+	}
+	if len(hosts) == 0 {
+		return env, fmt.Errorf("no hosts of %s found in %s", env.Name, inv.Provider)
+	}
+	hosts = unique(hosts)
+	cache.store(*inv, hosts)
+	env.Hosts = hosts
+	return env, nil
+}
+
+// Cached is like Resolve but reuses the hosts resolved within CacheTTL.
+// It is for pages which show the hosts without deploying to them.
+func Cached(ctx context.Context, env config.Environment) (config.Environment, error) {
+	if env.Inventory == nil {
+		return env, nil
+	}
+	if hosts, ok := cache.load(*env.Inventory, time.Now()); ok {
+		env.Hosts = hosts
+		return env, nil
+	}
+	return Resolve(ctx, env)
+}
+
+// unique returns the sorted hosts without duplicates so that the order is stable across resolutions.
+func unique(hosts []string) []string {
+	sort.Strings(hosts)
+	var uniq []string
+	for i, h := range hosts {
+		if i > 0 && h == hosts[i-1] {
+			continue
+		}
+		uniq = append(uniq, h)
+	}
+	return uniq
+}
+
+type cachedHosts struct {
+	hosts    []string
+	resolved time.Time
+}
+
+type hostCache struct {
+	mu      sync.Mutex
+	entries map[string]cachedHosts
+}
+
+var cache = &hostCache{entries: make(map[string]cachedHosts)}
+
+// key identifies "inv" by its contents because environments with the same inventory share the hosts.
+func key(inv config.Inventory) string {
+	buf, err := json.Marshal(inv)
+	if err != nil {
+		// Never happens because Inventory consists of strings and maps of strings.
+		panic(err)
+	}
+	return string(buf)
+}
+
+func (c *hostCache) store(inv config.Inventory, hosts []string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries[key(inv)] = cachedHosts{hosts: hosts, resolved: time.Now()}
+}
+
+func (c *hostCache) load(inv config.Inventory, now time.Time) ([]string, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, ok := c.entries[key(inv)]
+	if !ok || now.Sub(e.resolved) > CacheTTL {
+		return nil, false
+	}
+	return e.hosts, true
+}
+
+// getJSON decodes the response to the request into "v".
+func getJSON(req *http.Request, client *http.Client, v interface{}) error {
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected HTTP status %d from %s", resp.StatusCode, req.URL.Host)
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
+
+func firstNonEmpty(s ...string) string {
+	for _, v := range s {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
@@ -0,0 +1,175 @@
+package inventory
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"reflect"
+	"testing"
+
+	"github.com/gengo/goship/lib/config"
+	"golang.org/x/net/context"
+)
+
+type fakeProvider struct {
+	hosts []string
+	err   error
+	calls int
+}
+
+func (p *fakeProvider) Hosts(ctx context.Context, inv config.Inventory) ([]string, error) {
+	p.calls++
+	return p.hosts, p.err
+}
+
+func withProvider(p Provider, f func()) {
+	orig := Providers
+	Providers = map[config.InventoryProvider]Provider{config.InventoryConsul: p}
+	defer func() { Providers = orig }()
+	f()
+}
+
+func TestResolve(t *testing.T) {
+	ctx := context.Background()
+	env := config.Environment{Name: "production", Inventory: &config.Inventory{Provider: config.InventoryConsul, Service: "web"}}
+	p := &fakeProvider{hosts: []string{"10.0.0.2", "10.0.0.1", "10.0.0.2"}}
+	withProvider(p, func() {
+		got, err := Resolve(ctx, env)
+		if err != nil {
+			t.Fatalf("Resolve(ctx, %#v) failed with %v; want success", env, err)
+		}
+		if want := []string{"10.0.0.1", "10.0.0.2"}; !reflect.DeepEqual(got.Hosts, want) {
+			t.Errorf("hosts = %q; want %q", got.Hosts, want)
+		}
+
+		p.hosts = []string{"10.0.0.3"}
+		if got, err = Cached(ctx, env); err != nil {
+			t.Fatalf("Cached(ctx, %#v) failed with %v; want success", env, err)
+		}
+		if want := []string{"10.0.0.1", "10.0.0.2"}; !reflect.DeepEqual(got.Hosts, want) {
+			t.Errorf("cached hosts = %q; want %q", got.Hosts, want)
+		}
+		if p.calls != 1 {
+			t.Errorf("provider called %d times; want 1 because of the cache", p.calls)
+		}
+
+		p.hosts = nil
+		if _, err := Resolve(ctx, env); err == nil {
+			t.Errorf("Resolve(ctx, %#v) succeeded without hosts; want failure", env)
+		}
+		p.err = errors.New("unavailable")
+		if _, err := Resolve(ctx, env); err == nil {
+			t.Errorf("Resolve(ctx, %#v) succeeded although the provider failed; want failure", env)
+		}
+	})
+
+	static := config.Environment{Name: "staging", Hosts: []string{"web1"}}
+	if got, err := Resolve(ctx, static); err != nil || !reflect.DeepEqual(got, static) {
+		t.Errorf("Resolve(ctx, %#v) = %#v, %v; want the environment as is", static, got, err)
+	}
+}
+
+func TestConsul(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if got, want := r.URL.Path, "/v1/health/service/web"; got != want {
+			t.Errorf("path = %q; want %q", got, want)
+		}
+		if got, want := r.URL.Query().Get("tag"), "v2"; got != want {
+			t.Errorf("tag = %q; want %q", got, want)
+		}
+		fmt.Fprint(w, `[
+			{"Node": {"Address": "10.0.0.1"}, "Service": {"Address": ""}},
+			{"Node": {"Address": "10.0.0.2"}, "Service": {"Address": "172.17.0.2"}}
+		]`)
+	}))
+	defer s.Close()
+
+	inv := config.Inventory{Provider: config.InventoryConsul, Address: s.URL, Service: "web", Tag: "v2"}
+	got, err := Consul{}.Hosts(context.Background(), inv)
+	if err != nil {
+		t.Fatalf("Consul{}.Hosts(ctx, %#v) failed with %v; want success", inv, err)
+	}
+	if want := []string{"10.0.0.1", "172.17.0.2"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Consul{}.Hosts(ctx, %#v) = %q; want %q", inv, got, want)
+	}
+}
+
+func TestEC2(t *testing.T) {
+	for k, v := range map[string]string{"AWS_ACCESS_KEY_ID": "AKIDEXAMPLE", "AWS_SECRET_ACCESS_KEY": "secret"} {
+		orig := os.Getenv(k)
+		os.Setenv(k, v)
+		defer os.Setenv(k, orig)
+	}
+
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") == "" {
+			t.Errorf("request not signed")
+		}
+		q := r.URL.Query()
+		if got, want := q.Get("Filter.2.Name"), "tag:role"; got != want {
+			t.Errorf("Filter.2.Name = %q; want %q", got, want)
+		}
+		if q.Get("NextToken") == "" {
+			fmt.Fprint(w, `<DescribeInstancesResponse>
+				<reservationSet><item><instancesSet>
+					<item><privateIpAddress>10.0.0.1</privateIpAddress><ipAddress>54.0.0.1</ipAddress></item>
+					<item><privateIpAddress>10.0.0.2</privateIpAddress></item>
+				</instancesSet></item></reservationSet>
+				<nextToken>page2</nextToken>
+			</DescribeInstancesResponse>`)
+			return
+		}
+		fmt.Fprint(w, `<DescribeInstancesResponse>
+			<reservationSet><item><instancesSet>
+				<item><privateIpAddress>10.0.0.3</privateIpAddress><ipAddress>54.0.0.3</ipAddress></item>
+			</instancesSet></item></reservationSet>
+		</DescribeInstancesResponse>`)
+	}))
+	defer s.Close()
+
+	inv := config.Inventory{Provider: config.InventoryEC2, Region: "us-east-1", Tags: map[string]string{"role": "web"}}
+	got, err := EC2{Endpoint: s.URL}.Hosts(context.Background(), inv)
+	if err != nil {
+		t.Fatalf("EC2.Hosts(ctx, %#v) failed with %v; want success", inv, err)
+	}
+	if want := []string{"10.0.0.1", "10.0.0.2", "10.0.0.3"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("EC2.Hosts(ctx, %#v) = %q; want %q", inv, got, want)
+	}
+
+	inv.PublicAddress = true
+	if got, err = (EC2{Endpoint: s.URL}).Hosts(context.Background(), inv); err != nil {
+		t.Fatalf("EC2.Hosts(ctx, %#v) failed with %v; want success", inv, err)
+	}
+	if want := []string{"54.0.0.1", "54.0.0.3"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("EC2.Hosts(ctx, %#v) = %q; want %q", inv, got, want)
+	}
+}
+
+func TestGCE(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if got, want := r.URL.Path, "/projects/my-project/aggregated/instances"; got != want {
+			t.Errorf("path = %q; want %q", got, want)
+		}
+		fmt.Fprint(w, `{"items": {
+			"zones/us-central1-a": {"instances": [
+				{"status": "RUNNING", "labels": {"role": "web"}, "networkInterfaces": [{"networkIP": "10.128.0.2", "accessConfigs": [{"natIP": "35.0.0.2"}]}]},
+				{"status": "TERMINATED", "labels": {"role": "web"}, "networkInterfaces": [{"networkIP": "10.128.0.3"}]},
+				{"status": "RUNNING", "labels": {"role": "db"}, "networkInterfaces": [{"networkIP": "10.128.0.4"}]}
+			]},
+			"zones/us-central1-b": {"warning": {"code": "NO_RESULTS_ON_PAGE"}}
+		}}`)
+	}))
+	defer s.Close()
+
+	inv := config.Inventory{Provider: config.InventoryGCE, Project: "my-project", Labels: map[string]string{"role": "web"}}
+	g := GCE{Client: http.DefaultClient, Endpoint: s.URL}
+	got, err := g.Hosts(context.Background(), inv)
+	if err != nil {
+		t.Fatalf("g.Hosts(ctx, %#v) failed with %v; want success", inv, err)
+	}
+	if want := []string{"10.128.0.2"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("g.Hosts(ctx, %#v) = %q; want %q", inv, got, want)
+	}
+}
@@ -0,0 +1,134 @@
+// Package issue opens GitHub issues of environments which fail deployments repeatedly.
+package issue
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/gengo/goship/lib/config"
+	githublib "github.com/gengo/goship/lib/github"
+	"github.com/google/go-github/github"
+)
+
+// maxOutputLines is the maximum number of the last lines of output kept for each failure.
+const maxOutputLines = 50
+
+// Deployment is the result of a deployment into an environment.
+type Deployment struct {
+	Environment string
+	User        string
+	Revision    string
+	Success     bool
+	Time        time.Time
+	// Output is the output of the deployment.
+	Output []string
+}
+
+// Tracker counts consecutive failures of environments, and opens an issue in the source repository of the project
+// when an environment fails as many times as FailureIssue.Threshold.
+// The issue gets a comment on each further failure, and gets closed on the next success.
+type Tracker struct {
+	ecl config.ETCDInterface
+	gcl githublib.Client
+}
+
+// New returns a new Tracker which stores streaks of failures in "ecl" and opens issues with "gcl".
+func New(ecl config.ETCDInterface, gcl githublib.Client) Tracker {
+	return Tracker{ecl: ecl, gcl: gcl}
+}
+
+// Record records "d" into the streak of failures of the environment of "proj".
+// It does nothing if "proj" has no FailureIssue.
+func (t Tracker) Record(proj config.Project, d Deployment) error {
+	if proj.FailureIssue == nil {
+		return nil
+	}
+	streak, err := config.LoadFailureStreak(t.ecl, proj.Name, d.Environment)
+	if err != nil {
+		return err
+	}
+	repo := proj.SourceRepo()
+	if d.Success {
+		if streak == nil {
+			return nil
+		}
+		if streak.Issue != 0 {
+			body := fmt.Sprintf("Deployment of %s by %s succeeded at %s after %d consecutive failures.", d.Revision, d.User, d.Time.UTC().Format(time.RFC3339), streak.Count)
+			if _, _, err := t.gcl.CreateIssueComment(repo.RepoOwner, repo.RepoName, streak.Issue, &github.IssueComment{Body: github.String(body)}); err != nil {
+				return err
+			}
+			if _, _, err := t.gcl.EditIssue(repo.RepoOwner, repo.RepoName, streak.Issue, &github.IssueRequest{State: github.String("closed")}); err != nil {
+				return err
+			}
+		}
+		return config.ClearFailureStreak(t.ecl, proj.Name, d.Environment)
+	}
+
+	if streak == nil {
+		streak = new(config.FailureStreak)
+	}
+	f := config.FailedDeploy{User: d.User, Revision: d.Revision, Time: d.Time, Output: tail(d.Output, maxOutputLines)}
+	threshold := proj.FailureIssue.FailureThreshold()
+	streak.Count++
+	streak.Failures = append(streak.Failures, f)
+	if len(streak.Failures) > threshold {
+		streak.Failures = streak.Failures[len(streak.Failures)-threshold:]
+	}
+	switch {
+	case streak.Issue != 0:
+		body := fmt.Sprintf("Failed again (%d consecutive failures).\n\n%s", streak.Count, describe(f))
+		if _, _, err := t.gcl.CreateIssueComment(repo.RepoOwner, repo.RepoName, streak.Issue, &github.IssueComment{Body: github.String(body)}); err != nil {
+			return err
+		}
+	case streak.Count >= threshold:
+		req := &github.IssueRequest{
+			Title: github.String(fmt.Sprintf("Deployments to %s of %s failed %d times in a row", d.Environment, proj.Name, streak.Count)),
+			Body:  github.String(issueBody(proj, d.Environment, *streak)),
+		}
+		if labels := proj.FailureIssue.Labels; len(labels) > 0 {
+			req.Labels = &labels
+		}
+		i, _, err := t.gcl.CreateIssue(repo.RepoOwner, repo.RepoName, req)
+		if err != nil {
+			return err
+		}
+		if i.Number != nil {
+			streak.Issue = *i.Number
+		}
+		if i.HTMLURL != nil {
+			streak.IssueURL = *i.HTMLURL
+		}
+	}
+	return config.SaveFailureStreak(t.ecl, proj.Name, d.Environment, *streak)
+}
+
+// issueBody returns the body of the issue which reports "streak".
+func issueBody(proj config.Project, env string, streak config.FailureStreak) string {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "The last %d deployments to %s of %s failed. This issue is closed automatically on the next successful deployment.\n", streak.Count, env, proj.Name)
+	for _, f := range streak.Failures {
+		fmt.Fprintf(&buf, "\n%s", describe(f))
+	}
+	return buf.String()
+}
+
+// describe returns a Markdown description of "f" with its output.
+func describe(f config.FailedDeploy) string {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "### %s by %s at %s\n", f.Revision, f.User, f.Time.UTC().Format(time.RFC3339))
+	if len(f.Output) > 0 {
+		// indent the output so that it is rendered as a code block whatever it contains
+		fmt.Fprintf(&buf, "\n    %s\n", strings.Join(f.Output, "\n    "))
+	}
+	return buf.String()
+}
+
+// tail returns the last "n" lines of "lines".
+func tail(lines []string, n int) []string {
+	if len(lines) > n {
+		lines = lines[len(lines)-n:]
+	}
+	return append([]string(nil), lines...)
+}
@@ -0,0 +1,109 @@
+package issue
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gengo/goship/lib/config"
+	githublib "github.com/gengo/goship/lib/github"
+	"github.com/google/go-github/github"
+)
+
+// fakeIssues records the issue API calls.
+type fakeIssues struct {
+	githublib.Client
+	created  []*github.IssueRequest
+	edited   []*github.IssueRequest
+	comments []string
+}
+
+func (f *fakeIssues) CreateIssue(owner, repo string, issue *github.IssueRequest) (*github.Issue, *github.Response, error) {
+	f.created = append(f.created, issue)
+	return &github.Issue{Number: github.Int(42), HTMLURL: github.String("https://github.com/owner/repo/issues/42")}, nil, nil
+}
+
+func (f *fakeIssues) EditIssue(owner, repo string, number int, issue *github.IssueRequest) (*github.Issue, *github.Response, error) {
+	f.edited = append(f.edited, issue)
+	return &github.Issue{Number: github.Int(number)}, nil, nil
+}
+
+func (f *fakeIssues) CreateIssueComment(owner, repo string, number int, comment *github.IssueComment) (*github.IssueComment, *github.Response, error) {
+	f.comments = append(f.comments, *comment.Body)
+	return comment, nil, nil
+}
+
+func TestTracker(t *testing.T) {
+	s := config.NewMemoryStore()
+	gcl := new(fakeIssues)
+	tr := New(s, gcl)
+	proj := config.Project{
+		Name:         "proj",
+		Repo:         config.Repo{RepoOwner: "owner", RepoName: "repo"},
+		FailureIssue: &config.FailureIssue{Threshold: 2, Labels: []string{"deploy-failure"}},
+	}
+	d := Deployment{Environment: "production", User: "alice", Revision: "abc123", Time: time.Date(2015, time.November, 10, 23, 0, 0, 0, time.UTC)}
+
+	d.Output = []string{"permission denied"}
+	if err := tr.Record(proj, d); err != nil {
+		t.Fatalf("tr.Record(proj, %#v) failed with %v; want success", d, err)
+	}
+	if len(gcl.created) != 0 {
+		t.Errorf("issue opened after the first failure; want no issue below the threshold")
+	}
+
+	d.Output = []string{"disk full"}
+	if err := tr.Record(proj, d); err != nil {
+		t.Fatalf("tr.Record(proj, %#v) failed with %v; want success", d, err)
+	}
+	if len(gcl.created) != 1 {
+		t.Fatalf("%d issues opened after reaching the threshold; want 1", len(gcl.created))
+	}
+	body := *gcl.created[0].Body
+	for _, want := range []string{"permission denied", "disk full"} {
+		if !strings.Contains(body, want) {
+			t.Errorf("issue body = %q; want it to contain %q", body, want)
+		}
+	}
+	if got := gcl.created[0].Labels; got == nil || len(*got) != 1 || (*got)[0] != "deploy-failure" {
+		t.Errorf("issue labels = %v; want [deploy-failure]", got)
+	}
+	streak, err := config.LoadFailureStreak(s, "proj", "production")
+	if err != nil || streak == nil {
+		t.Fatalf("config.LoadFailureStreak = %#v, %v; want a streak", streak, err)
+	}
+	if streak.Issue != 42 || streak.Count != 2 {
+		t.Errorf("streak.Issue, streak.Count = %d, %d; want 42, 2", streak.Issue, streak.Count)
+	}
+
+	d.Output = []string{"timeout"}
+	if err := tr.Record(proj, d); err != nil {
+		t.Fatalf("tr.Record(proj, %#v) failed with %v; want success", d, err)
+	}
+	if len(gcl.created) != 1 || len(gcl.comments) != 1 || !strings.Contains(gcl.comments[0], "timeout") {
+		t.Errorf("created %d issues and comments %q after another failure; want 1 issue and a comment with the output", len(gcl.created), gcl.comments)
+	}
+
+	d.Success, d.Output = true, nil
+	if err := tr.Record(proj, d); err != nil {
+		t.Fatalf("tr.Record(proj, %#v) failed with %v; want success", d, err)
+	}
+	if len(gcl.edited) != 1 || gcl.edited[0].State == nil || *gcl.edited[0].State != "closed" {
+		t.Errorf("edits = %#v; want the issue closed", gcl.edited)
+	}
+	if streak, err := config.LoadFailureStreak(s, "proj", "production"); err != nil || streak != nil {
+		t.Errorf("config.LoadFailureStreak = %#v, %v after success; want nil, nil", streak, err)
+	}
+}
+
+func TestTrackerWithoutFailureIssue(t *testing.T) {
+	s := config.NewMemoryStore()
+	tr := New(s, new(fakeIssues))
+	d := Deployment{Environment: "production"}
+	if err := tr.Record(config.Project{Name: "proj"}, d); err != nil {
+		t.Fatalf("tr.Record failed with %v; want success", err)
+	}
+	if streak, err := config.LoadFailureStreak(s, "proj", "production"); err != nil || streak != nil {
+		t.Errorf("config.LoadFailureStreak = %#v, %v; want nil, nil without failure_issue", streak, err)
+	}
+}
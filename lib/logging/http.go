@@ -0,0 +1,81 @@
+package logging
+
+import (
+	"bufio"
+	"net"
+	"net/http"
+	"regexp"
+	"time"
+
+	"golang.org/x/net/context"
+)
+
+// RequestIDHeader is the header of requests and responses which carries the correlation ID of the request.
+// IDs given by clients or proxies in requests are kept so that their lines can be grouped with the lines of Goship.
+const RequestIDHeader = "X-Request-Id"
+
+// validRequestID matches request IDs which are kept as correlation IDs.
+var validRequestID = regexp.MustCompile(`^[0-9A-Za-z._-]{1,64}$`)
+
+// Handler returns a new http.Handler which assigns a correlation ID to each request in RequestIDHeader, tells it in the response,
+// and logs the request at Debug level when "h" has served it.
+// Handlers get the ID with FromRequest.
+func Handler(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(RequestIDHeader)
+		if !validRequestID.MatchString(id) {
+			id = NewID()
+			r.Header.Set(RequestIDHeader, id)
+		}
+		w.Header().Set(RequestIDHeader, id)
+		if !Enabled(Debug) {
+			h.ServeHTTP(w, r)
+			return
+		}
+		start := time.Now()
+		sw := &statusWriter{ResponseWriter: w, status: http.StatusOK}
+		h.ServeHTTP(sw, r)
+		root.With(CorrelationIDKey, id).Debugf("%s %s %d %v", r.Method, r.URL.Path, sw.status, time.Since(start))
+	})
+}
+
+// FromRequest returns a new context whose log lines carry the correlation ID of "r" which Handler assigned.
+func FromRequest(ctx context.Context, r *http.Request) context.Context {
+	id := r.Header.Get(RequestIDHeader)
+	if !validRequestID.MatchString(id) {
+		id = NewID()
+	}
+	return WithCorrelationID(ctx, id)
+}
+
+// statusWriter records the status of a response.
+// It keeps http.Flusher, http.CloseNotifier and http.Hijacker of the original writer available to streaming handlers.
+type statusWriter struct {
+	http.ResponseWriter
+	status int
+}
+
+func (w *statusWriter) WriteHeader(code int) {
+	w.status = code
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *statusWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (w *statusWriter) CloseNotify() <-chan bool {
+	if cn, ok := w.ResponseWriter.(http.CloseNotifier); ok {
+		return cn.CloseNotify()
+	}
+	return nil
+}
+
+func (w *statusWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	if hj, ok := w.ResponseWriter.(http.Hijacker); ok {
+		return hj.Hijack()
+	}
+	return nil, nil, http.ErrNotSupported
+}
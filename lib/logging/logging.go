@@ -0,0 +1,244 @@
+// Package logging writes leveled, structured logs whose lines carry fields from contexts,
+// e.g. the correlation ID of a request or a deployment, so that log aggregators can group the lines of a deployment
+// from its HTTP request to its output and notifications.
+package logging
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang/glog"
+	"golang.org/x/net/context"
+)
+
+// Level is the severity of a log line.
+type Level int
+
+// Levels of log lines from the least severe.
+const (
+	Debug Level = iota
+	Info
+	Warning
+	Error
+)
+
+var levelNames = []string{"debug", "info", "warning", "error"}
+
+func (l Level) String() string {
+	if l < Debug || l > Error {
+		return fmt.Sprintf("Level(%d)", int(l))
+	}
+	return levelNames[l]
+}
+
+// ParseLevel returns the level named "s", e.g. "info".
+func ParseLevel(s string) (Level, error) {
+	for i, name := range levelNames {
+		if strings.EqualFold(s, name) {
+			return Level(i), nil
+		}
+	}
+	if strings.EqualFold(s, "warn") {
+		return Warning, nil
+	}
+	return 0, fmt.Errorf("unknown log level %q", s)
+}
+
+// Formats of log lines.
+const (
+	// FormatGlog writes lines with glog, appending the fields in "key=value" form.
+	FormatGlog = "glog"
+	// FormatText writes lines like "2015-11-10T03:00:00.000Z info Starting deployment correlation_id=5f2b...".
+	FormatText = "text"
+	// FormatJSON writes a JSON object per line with "time", "level", "msg" and the fields.
+	FormatJSON = "json"
+)
+
+// CorrelationIDKey is the field of the correlation ID, which is shared by the lines of a request or a deployment.
+const CorrelationIDKey = "correlation_id"
+
+var (
+	mu       sync.Mutex
+	out      io.Writer = os.Stderr
+	format             = FormatGlog
+	minLevel           = Info
+)
+
+// Configure makes lines at "level" or more severe written in "f" into "w".
+// "w" is not used in FormatGlog, which writes lines wherever glog is configured to.
+func Configure(f string, level Level, w io.Writer) error {
+	switch f {
+	case FormatGlog, FormatText, FormatJSON:
+	default:
+		return fmt.Errorf("unknown log format %q", f)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	format, minLevel, out = f, level, w
+	return nil
+}
+
+// Enabled returns true if lines at "level" are written.
+func Enabled(level Level) bool {
+	mu.Lock()
+	defer mu.Unlock()
+	return level >= minLevel
+}
+
+// field is a key and a value attached to log lines.
+type field struct {
+	key, value string
+}
+
+// Logger writes log lines with its fields.
+// The zero value writes lines without fields.
+type Logger struct {
+	fields []field
+}
+
+// With returns a Logger which adds "keyvals", alternating keys and values, to the fields of "l".
+// Fields of the same keys are replaced.
+func (l *Logger) With(keyvals ...string) *Logger {
+	fields := make([]field, 0, len(l.fields)+len(keyvals)/2)
+	fields = append(fields, l.fields...)
+	for i := 0; i+1 < len(keyvals); i += 2 {
+		f := field{key: keyvals[i], value: keyvals[i+1]}
+		replaced := false
+		for j := range fields {
+			if fields[j].key == f.key {
+				fields[j], replaced = f, true
+			}
+		}
+		if !replaced {
+			fields = append(fields, f)
+		}
+	}
+	return &Logger{fields: fields}
+}
+
+// Value returns the value of the field "key", or an empty string if "l" does not have it.
+func (l *Logger) Value(key string) string {
+	for _, f := range l.fields {
+		if f.key == key {
+			return f.value
+		}
+	}
+	return ""
+}
+
+// Debugf writes a line at Debug level.
+func (l *Logger) Debugf(format string, args ...interface{}) { l.output(Debug, format, args...) }
+
+// Infof writes a line at Info level.
+func (l *Logger) Infof(format string, args ...interface{}) { l.output(Info, format, args...) }
+
+// Warningf writes a line at Warning level.
+func (l *Logger) Warningf(format string, args ...interface{}) { l.output(Warning, format, args...) }
+
+// Errorf writes a line at Error level.
+func (l *Logger) Errorf(format string, args ...interface{}) { l.output(Error, format, args...) }
+
+// glogDepth is the depth of the callers of Logger methods from glog.
+const glogDepth = 2
+
+func (l *Logger) output(level Level, f string, args ...interface{}) {
+	mu.Lock()
+	defer mu.Unlock()
+	if level < minLevel {
+		return
+	}
+	msg := fmt.Sprintf(f, args...)
+	switch format {
+	case FormatGlog:
+		s := l.text(msg)
+		switch level {
+		case Error:
+			glog.ErrorDepth(glogDepth, s)
+		case Warning:
+			glog.WarningDepth(glogDepth, s)
+		default:
+			glog.InfoDepth(glogDepth, s)
+		}
+	case FormatText:
+		fmt.Fprintf(out, "%s %s %s\n", time.Now().UTC().Format(timeFormat), level, l.text(msg))
+	case FormatJSON:
+		obj := map[string]string{"time": time.Now().UTC().Format(timeFormat), "level": level.String(), "msg": msg}
+		for _, f := range l.fields {
+			obj[f.key] = f.value
+		}
+		buf, err := json.Marshal(obj)
+		if err != nil {
+			return
+		}
+		out.Write(append(buf, '\n'))
+	}
+}
+
+// timeFormat is the format of times in FormatText and FormatJSON.
+const timeFormat = "2006-01-02T15:04:05.000Z07:00"
+
+// text returns "msg" followed by the fields in "key=value" form.
+func (l *Logger) text(msg string) string {
+	var b bytes.Buffer
+	b.WriteString(msg)
+	for _, f := range l.fields {
+		v := f.value
+		if v == "" || strings.ContainsAny(v, " \t\"=") {
+			v = fmt.Sprintf("%q", v)
+		}
+		fmt.Fprintf(&b, " %s=%s", f.key, v)
+	}
+	return b.String()
+}
+
+type contextKey int
+
+const loggerKey contextKey = 0
+
+// root is the Logger without fields.
+var root = new(Logger)
+
+// NewContext returns a new context which carries "l".
+func NewContext(ctx context.Context, l *Logger) context.Context {
+	return context.WithValue(ctx, loggerKey, l)
+}
+
+// FromContext returns the Logger carried by "ctx", or a Logger without fields if "ctx" does not carry one.
+func FromContext(ctx context.Context) *Logger {
+	if l, ok := ctx.Value(loggerKey).(*Logger); ok {
+		return l
+	}
+	return root
+}
+
+// With returns a new context whose Logger adds "keyvals", alternating keys and values, to the fields of the Logger of "ctx".
+func With(ctx context.Context, keyvals ...string) context.Context {
+	return NewContext(ctx, FromContext(ctx).With(keyvals...))
+}
+
+// WithCorrelationID returns a new context whose log lines carry "id" as the correlation ID.
+func WithCorrelationID(ctx context.Context, id string) context.Context {
+	return With(ctx, CorrelationIDKey, id)
+}
+
+// CorrelationID returns the correlation ID of "ctx", or an empty string if "ctx" does not have one.
+func CorrelationID(ctx context.Context) string {
+	return FromContext(ctx).Value(CorrelationIDKey)
+}
+
+// NewID returns a new random correlation ID.
+func NewID() string {
+	var b [8]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return fmt.Sprintf("%016x", time.Now().UnixNano())
+	}
+	return hex.EncodeToString(b[:])
+}
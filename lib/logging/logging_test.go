@@ -0,0 +1,109 @@
+package logging
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"golang.org/x/net/context"
+)
+
+// configure makes lines written in "f" into a buffer at "level" until the returned function is called.
+func configure(t *testing.T, f string, level Level) (*bytes.Buffer, func()) {
+	var buf bytes.Buffer
+	if err := Configure(f, level, &buf); err != nil {
+		t.Fatalf("Configure(%q, %v, &buf) failed with %v; want success", f, level, err)
+	}
+	return &buf, func() { Configure(FormatGlog, Info, nil) }
+}
+
+func TestJSON(t *testing.T) {
+	buf, restore := configure(t, FormatJSON, Info)
+	defer restore()
+
+	ctx := WithCorrelationID(context.Background(), "abc123")
+	ctx = With(ctx, "project", "proj", "environment", "production")
+	ctx = With(ctx, "environment", "staging")
+	FromContext(ctx).Debugf("not written")
+	FromContext(ctx).Warningf("deploying %s", "v1")
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 1 {
+		t.Fatalf("lines = %q; want only the warning", lines)
+	}
+	var got map[string]string
+	if err := json.Unmarshal([]byte(lines[0]), &got); err != nil {
+		t.Fatalf("json.Unmarshal(%q, &got) failed with %v; want success", lines[0], err)
+	}
+	for k, want := range map[string]string{
+		"level":          "warning",
+		"msg":            "deploying v1",
+		"correlation_id": "abc123",
+		"project":        "proj",
+		"environment":    "staging",
+	} {
+		if got[k] != want {
+			t.Errorf("%s = %q; want %q", k, got[k], want)
+		}
+	}
+	if got, want := CorrelationID(ctx), "abc123"; got != want {
+		t.Errorf("CorrelationID(ctx) = %q; want %q", got, want)
+	}
+}
+
+func TestText(t *testing.T) {
+	buf, restore := configure(t, FormatText, Debug)
+	defer restore()
+
+	ctx := With(context.Background(), "deploy_id", "3", "reason", "hot fix")
+	FromContext(ctx).Debugf("output line")
+	if got, want := buf.String(), ` debug output line deploy_id=3 reason="hot fix"`+"\n"; !strings.HasSuffix(got, want) {
+		t.Errorf("line = %q; want suffix %q", got, want)
+	}
+}
+
+func TestHandler(t *testing.T) {
+	_, restore := configure(t, FormatText, Debug)
+	defer restore()
+
+	var ids []string
+	h := Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ids = append(ids, CorrelationID(FromRequest(context.Background(), r)))
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	for _, given := range []string{"", "from-proxy.1", "invalid id"} {
+		r, err := http.NewRequest("GET", "/deploy", nil)
+		if err != nil {
+			t.Fatalf("http.NewRequest(%q, %q, nil) failed with %v; want success", "GET", "/deploy", err)
+		}
+		if given != "" {
+			r.Header.Set(RequestIDHeader, given)
+		}
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		got := w.Header().Get(RequestIDHeader)
+		if got == "" || got != ids[len(ids)-1] {
+			t.Errorf("%s of the response = %q; want the correlation ID of the request %q", RequestIDHeader, got, ids[len(ids)-1])
+		}
+		if given == "from-proxy.1" && got != given {
+			t.Errorf("%s of the response = %q; want %q given in the request", RequestIDHeader, got, given)
+		}
+		if given == "invalid id" && got == given {
+			t.Errorf("%s of the response = %q; want a new ID instead of the invalid one", RequestIDHeader, got)
+		}
+	}
+}
+
+func TestParseLevel(t *testing.T) {
+	for s, want := range map[string]Level{"debug": Debug, "INFO": Info, "warn": Warning, "error": Error} {
+		if got, err := ParseLevel(s); err != nil || got != want {
+			t.Errorf("ParseLevel(%q) = %v, %v; want %v, nil", s, got, err, want)
+		}
+	}
+	if _, err := ParseLevel("verbose"); err == nil {
+		t.Errorf("ParseLevel(%q) succeeded; want failure", "verbose")
+	}
+}
@@ -0,0 +1,187 @@
+// Package markdown renders a safe subset of Markdown into HTML, e.g. for comments written by users.
+// HTML in the source is escaped, and links are limited to http, https and mailto URLs and relative paths.
+package markdown
+
+import (
+	"bytes"
+	"fmt"
+	"html/template"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+var (
+	headingPattern = regexp.MustCompile(`^(#{1,6})\s+(.*?)\s*#*\s*$`)
+	bulletPattern  = regexp.MustCompile(`^\s{0,3}[-*+]\s+(.*)$`)
+	numberPattern  = regexp.MustCompile(`^\s{0,3}\d{1,9}[.)]\s+(.*)$`)
+	quotePattern   = regexp.MustCompile(`^\s{0,3}>\s?(.*)$`)
+	fencePattern   = regexp.MustCompile("^\\s{0,3}(```+|~~~+)")
+	linkPattern    = regexp.MustCompile(`^\[([^\]]+)\]\(([^)\s]+)\)`)
+	urlPattern     = regexp.MustCompile(`^https?://[^\s<>"]+`)
+)
+
+// Render returns the HTML of "src".
+// It supports paragraphs with line breaks, ATX headings, bullet and numbered lists, blockquotes, fenced and indented code blocks,
+// emphasis, strong emphasis, code spans, links and URLs, which are linked automatically.
+func Render(src string) template.HTML {
+	src = strings.Replace(src, "\r\n", "\n", -1)
+	var b bytes.Buffer
+	renderBlocks(&b, strings.Split(src, "\n"))
+	return template.HTML(b.String())
+}
+
+// renderBlocks writes the HTML of the blocks in "lines" into "b".
+func renderBlocks(b *bytes.Buffer, lines []string) {
+	var para []string
+	flush := func() {
+		if len(para) == 0 {
+			return
+		}
+		var inlines []string
+		for _, l := range para {
+			inlines = append(inlines, inline(strings.TrimSpace(l)))
+		}
+		fmt.Fprintf(b, "<p>%s</p>\n", strings.Join(inlines, "<br>\n"))
+		para = nil
+	}
+
+	for i := 0; i < len(lines); i++ {
+		l := lines[i]
+		switch {
+		case strings.TrimSpace(l) == "":
+			flush()
+		case fencePattern.MatchString(l):
+			flush()
+			fence := fencePattern.FindStringSubmatch(l)[1]
+			var code []string
+			for i++; i < len(lines) && !strings.HasPrefix(strings.TrimSpace(lines[i]), fence); i++ {
+				code = append(code, lines[i])
+			}
+			writeCode(b, code)
+		case len(para) == 0 && strings.HasPrefix(l, "    "):
+			var code []string
+			for ; i < len(lines) && (strings.HasPrefix(lines[i], "    ") || strings.TrimSpace(lines[i]) == ""); i++ {
+				code = append(code, strings.TrimPrefix(lines[i], "    "))
+			}
+			i--
+			for len(code) > 0 && strings.TrimSpace(code[len(code)-1]) == "" {
+				code = code[:len(code)-1]
+			}
+			writeCode(b, code)
+		case headingPattern.MatchString(l):
+			flush()
+			m := headingPattern.FindStringSubmatch(l)
+			fmt.Fprintf(b, "<h%d>%s</h%d>\n", len(m[1]), inline(m[2]), len(m[1]))
+		case quotePattern.MatchString(l):
+			flush()
+			var quoted []string
+			for ; i < len(lines) && quotePattern.MatchString(lines[i]); i++ {
+				quoted = append(quoted, quotePattern.FindStringSubmatch(lines[i])[1])
+			}
+			i--
+			b.WriteString("<blockquote>\n")
+			renderBlocks(b, quoted)
+			b.WriteString("</blockquote>\n")
+		case bulletPattern.MatchString(l) || numberPattern.MatchString(l):
+			flush()
+			tag, pattern := "ul", bulletPattern
+			if !bulletPattern.MatchString(l) {
+				tag, pattern = "ol", numberPattern
+			}
+			fmt.Fprintf(b, "<%s>\n", tag)
+			for i < len(lines) && pattern.MatchString(lines[i]) {
+				item := []string{pattern.FindStringSubmatch(lines[i])[1]}
+				// indented lines continue the item
+				for i++; i < len(lines) && strings.HasPrefix(lines[i], "  ") && strings.TrimSpace(lines[i]) != "" && !pattern.MatchString(lines[i]); i++ {
+					item = append(item, strings.TrimSpace(lines[i]))
+				}
+				fmt.Fprintf(b, "<li>%s</li>\n", inline(strings.Join(item, " ")))
+			}
+			i--
+			fmt.Fprintf(b, "</%s>\n", tag)
+		default:
+			para = append(para, l)
+		}
+	}
+	flush()
+}
+
+func writeCode(b *bytes.Buffer, lines []string) {
+	b.WriteString("<pre><code>")
+	for _, l := range lines {
+		b.WriteString(template.HTMLEscapeString(l))
+		b.WriteByte('\n')
+	}
+	b.WriteString("</code></pre>\n")
+}
+
+// inline returns the HTML of the inline elements in "s".
+func inline(s string) string {
+	var b bytes.Buffer
+	for i := 0; i < len(s); {
+		rest := s[i:]
+		switch {
+		case rest[0] == '\\' && len(rest) > 1 && strings.IndexByte("\\`*_[]()#+-.!>", rest[1]) >= 0:
+			b.WriteString(template.HTMLEscapeString(rest[1:2]))
+			i += 2
+			continue
+		case rest[0] == '`':
+			if end := strings.IndexByte(rest[1:], '`'); end >= 0 {
+				fmt.Fprintf(&b, "<code>%s</code>", template.HTMLEscapeString(rest[1:end+1]))
+				i += end + 2
+				continue
+			}
+		case rest[0] == '[':
+			if m := linkPattern.FindStringSubmatch(rest); m != nil && safeURL(m[2]) {
+				fmt.Fprintf(&b, `<a href="%s" rel="nofollow">%s</a>`, template.HTMLEscapeString(m[2]), inline(m[1]))
+				i += len(m[0])
+				continue
+			}
+		case strings.HasPrefix(rest, "**") || strings.HasPrefix(rest, "__"):
+			if end := strings.Index(rest[2:], rest[:2]); end > 0 {
+				fmt.Fprintf(&b, "<strong>%s</strong>", inline(rest[2:end+2]))
+				i += end + 4
+				continue
+			}
+		case rest[0] == '*' || rest[0] == '_':
+			// underscores in words, e.g. snake_case, are not emphasis
+			if rest[0] == '*' || i == 0 || !isWordByte(s[i-1]) {
+				if end := strings.IndexByte(rest[1:], rest[0]); end > 0 && rest[1] != ' ' {
+					fmt.Fprintf(&b, "<em>%s</em>", inline(rest[1:end+1]))
+					i += end + 2
+					continue
+				}
+			}
+		case rest[0] == 'h' && (i == 0 || !isWordByte(s[i-1])):
+			if u := urlPattern.FindString(rest); u != "" {
+				u = strings.TrimRight(u, ".,;:!?)")
+				fmt.Fprintf(&b, `<a href="%s" rel="nofollow">%s</a>`, template.HTMLEscapeString(u), template.HTMLEscapeString(u))
+				i += len(u)
+				continue
+			}
+		}
+		b.WriteString(template.HTMLEscapeString(rest[:1]))
+		i++
+	}
+	return b.String()
+}
+
+func isWordByte(c byte) bool {
+	return c == '_' || '0' <= c && c <= '9' || 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z'
+}
+
+// safeURL returns true if "s" is a URL which links can point to.
+func safeURL(s string) bool {
+	u, err := url.Parse(s)
+	if err != nil {
+		return false
+	}
+	switch strings.ToLower(u.Scheme) {
+	case "":
+		return !strings.HasPrefix(s, "//")
+	case "http", "https", "mailto":
+		return true
+	}
+	return false
+}
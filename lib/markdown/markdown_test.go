@@ -0,0 +1,40 @@
+package markdown
+
+import (
+	"testing"
+)
+
+func TestRender(t *testing.T) {
+	for _, spec := range []struct {
+		src, want string
+	}{
+		{
+			src:  "Rolled back because of **OOM** in `worker`.\nSee https://example.com/issues/1.",
+			want: "<p>Rolled back because of <strong>OOM</strong> in <code>worker</code>.<br>\nSee <a href=\"https://example.com/issues/1\" rel=\"nofollow\">https://example.com/issues/1</a>.</p>\n",
+		},
+		{
+			src:  "# Postmortem\n\n- *first* item\n  continued\n- [runbook](/docs/runbook)\n\n1. one\n2. two",
+			want: "<h1>Postmortem</h1>\n<ul>\n<li><em>first</em> item continued</li>\n<li><a href=\"/docs/runbook\" rel=\"nofollow\">runbook</a></li>\n</ul>\n<ol>\n<li>one</li>\n<li>two</li>\n</ol>\n",
+		},
+		{
+			src:  "> quoted\n> text\n\n```\n<script>alert(1)</script>\n```",
+			want: "<blockquote>\n<p>quoted<br>\ntext</p>\n</blockquote>\n<pre><code>&lt;script&gt;alert(1)&lt;/script&gt;\n</code></pre>\n",
+		},
+		{
+			src:  "<b>raw</b> [x](javascript:alert(1)) snake_case_name \\*not emphasis\\* 日本語",
+			want: "<p>&lt;b&gt;raw&lt;/b&gt; [x](javascript:alert(1)) snake_case_name *not emphasis* 日本語</p>\n",
+		},
+		{
+			src:  "    indented code\n\nafter",
+			want: "<pre><code>indented code\n</code></pre>\n<p>after</p>\n",
+		},
+		{
+			src:  "",
+			want: "",
+		},
+	} {
+		if got := string(Render(spec.src)); got != spec.want {
+			t.Errorf("Render(%q) = %q; want %q", spec.src, got, spec.want)
+		}
+	}
+}
@@ -0,0 +1,140 @@
+// Package metrics provides a minimal set of Prometheus-compatible metrics.
+//
+// Metrics are exposed in the Prometheus text exposition format.
+// See also http://prometheus.io/docs/instrumenting/exposition_formats/
+package metrics
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/golang/glog"
+)
+
+// DefBuckets are the default upper bounds of histogram buckets in seconds.
+var DefBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// DeployBuckets are upper bounds of histogram buckets suitable for durations of deployments in seconds.
+var DeployBuckets = []float64{5, 15, 30, 60, 120, 300, 600, 1200, 1800, 3600}
+
+// Registry is a set of metrics.
+type Registry struct {
+	mu      sync.Mutex
+	metrics []collector
+}
+
+// Default is the registry which the constructors of metrics register the new metrics to.
+var Default = new(Registry)
+
+type collector interface {
+	name() string
+	write(w io.Writer)
+}
+
+func (r *Registry) register(c collector) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.metrics = append(r.metrics, c)
+}
+
+// WriteTo writes all the metrics in "r" into "w" in the text exposition format.
+func (r *Registry) WriteTo(w io.Writer) (int64, error) {
+	r.mu.Lock()
+	metrics := append([]collector(nil), r.metrics...)
+	r.mu.Unlock()
+	sort.Sort(byName(metrics))
+
+	var buf bytes.Buffer
+	for _, m := range metrics {
+		m.write(&buf)
+	}
+	return buf.WriteTo(w)
+}
+
+// Handler returns an http.Handler which exposes the metrics in "r".
+func (r *Registry) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+		if _, err := r.WriteTo(w); err != nil {
+			glog.Errorf("Failed to send metrics: %v", err)
+		}
+	})
+}
+
+// Handler returns an http.Handler which exposes the metrics in Default.
+func Handler() http.Handler {
+	return Default.Handler()
+}
+
+type byName []collector
+
+func (s byName) Len() int           { return len(s) }
+func (s byName) Less(i, j int) bool { return s[i].name() < s[j].name() }
+func (s byName) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
+
+// desc is the common part of metric vectors
+type desc struct {
+	fqName string
+	help   string
+	labels []string
+}
+
+func (d desc) name() string { return d.fqName }
+
+func (d desc) key(values []string) string {
+	if len(values) != len(d.labels) {
+		panic(fmt.Sprintf("metric %s has %d labels but got %d values", d.fqName, len(d.labels), len(values)))
+	}
+	return strings.Join(values, "\x00")
+}
+
+func (d desc) header(w io.Writer, typ string) {
+	fmt.Fprintf(w, "# HELP %s %s\n", d.fqName, d.help)
+	fmt.Fprintf(w, "# TYPE %s %s\n", d.fqName, typ)
+}
+
+// labelPairs formats label names and values, optionally followed by an extra pair.
+func (d desc) labelPairs(values []string, extra ...string) string {
+	var pairs []string
+	for i, l := range d.labels {
+		pairs = append(pairs, fmt.Sprintf(`%s="%s"`, l, escapeLabel(values[i])))
+	}
+	for i := 0; i+1 < len(extra); i += 2 {
+		pairs = append(pairs, fmt.Sprintf(`%s="%s"`, extra[i], escapeLabel(extra[i+1])))
+	}
+	if len(pairs) == 0 {
+		return ""
+	}
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+// labelEscaper escapes label values in the text format, which unlike Go strings leaves other characters as they are.
+var labelEscaper = strings.NewReplacer("\\", `\\`, "\"", `\"`, "\n", `\n`)
+
+// escapeLabel returns "v" escaped as a label value.
+func escapeLabel(v string) string {
+	return labelEscaper.Replace(v)
+}
+
+// sortedKeys returns keys of series in a stable order.
+func sortedKeys(m map[string][]string) []string {
+	var keys []string
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func formatFloat(v float64) string {
+	if math.IsInf(v, +1) {
+		return "+Inf"
+	}
+	return fmt.Sprint(v)
+}
@@ -0,0 +1,55 @@
+package metrics
+
+import (
+	"bytes"
+	"testing"
+)
+
+func withRegistry(f func(r *Registry)) {
+	saved := Default
+	defer func() { Default = saved }()
+	Default = new(Registry)
+	f(Default)
+}
+
+func TestWriteTo(t *testing.T) {
+	withRegistry(func(r *Registry) {
+		c := NewCounterVec("test_deploys_total", "Number of deployments.", "project")
+		g := NewGaugeVec("test_connections", "Number of connections.")
+		h := NewHistogramVec("test_duration_seconds", "Duration.", []float64{1, 10}, "project")
+
+		c.Inc("example")
+		c.Add(2, "example")
+		c.Inc(`quoted"name`)
+		c.Inc("café\\new\nline")
+		g.Add(3)
+		g.Add(-1)
+		h.Observe(0.5, "example")
+		h.Observe(5, "example")
+		h.Observe(50, "example")
+
+		var buf bytes.Buffer
+		if _, err := r.WriteTo(&buf); err != nil {
+			t.Fatalf("r.WriteTo(&buf) failed with %v; want success", err)
+		}
+		want := `# HELP test_connections Number of connections.
+# TYPE test_connections gauge
+test_connections 2
+# HELP test_deploys_total Number of deployments.
+# TYPE test_deploys_total counter
+test_deploys_total{project="café\\new\nline"} 1
+test_deploys_total{project="example"} 3
+test_deploys_total{project="quoted\"name"} 1
+# HELP test_duration_seconds Duration.
+# TYPE test_duration_seconds histogram
+test_duration_seconds_bucket{project="example",le="1"} 1
+test_duration_seconds_bucket{project="example",le="10"} 2
+test_duration_seconds_bucket{project="example",le="+Inf"} 3
+test_duration_seconds_sum{project="example"} 55.5
+test_duration_seconds_count{project="example"} 3
+`
+		if got := buf.String(); got != want {
+			t.Errorf("r.WriteTo(&buf) wrote %s; want %s", got, want)
+		}
+	})
+}
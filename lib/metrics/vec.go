@@ -0,0 +1,158 @@
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"sync"
+)
+
+// CounterVec is a set of counters partitioned by label values.
+type CounterVec struct {
+	desc
+	mu     sync.Mutex
+	labels map[string][]string
+	values map[string]float64
+}
+
+// NewCounterVec returns a new CounterVec registered to Default.
+func NewCounterVec(name, help string, labels ...string) *CounterVec {
+	c := &CounterVec{
+		desc:   desc{fqName: name, help: help, labels: labels},
+		labels: make(map[string][]string),
+		values: make(map[string]float64),
+	}
+	Default.register(c)
+	return c
+}
+
+// Inc increments the counter identified by "values" by 1.
+func (c *CounterVec) Inc(values ...string) {
+	c.Add(1, values...)
+}
+
+// Add increments the counter identified by "values" by "v".
+func (c *CounterVec) Add(v float64, values ...string) {
+	k := c.key(values)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.labels[k] = values
+	c.values[k] += v
+}
+
+func (c *CounterVec) write(w io.Writer) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.header(w, "counter")
+	for _, k := range sortedKeys(c.labels) {
+		fmt.Fprintf(w, "%s%s %s\n", c.fqName, c.labelPairs(c.labels[k]), formatFloat(c.values[k]))
+	}
+}
+
+// GaugeVec is a set of gauges partitioned by label values.
+type GaugeVec struct {
+	desc
+	mu     sync.Mutex
+	labels map[string][]string
+	values map[string]float64
+}
+
+// NewGaugeVec returns a new GaugeVec registered to Default.
+func NewGaugeVec(name, help string, labels ...string) *GaugeVec {
+	g := &GaugeVec{
+		desc:   desc{fqName: name, help: help, labels: labels},
+		labels: make(map[string][]string),
+		values: make(map[string]float64),
+	}
+	Default.register(g)
+	return g
+}
+
+// Set sets the gauge identified by "values" to "v".
+func (g *GaugeVec) Set(v float64, values ...string) {
+	k := g.key(values)
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.labels[k] = values
+	g.values[k] = v
+}
+
+// Add adds "v" to the gauge identified by "values".
+func (g *GaugeVec) Add(v float64, values ...string) {
+	k := g.key(values)
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.labels[k] = values
+	g.values[k] += v
+}
+
+func (g *GaugeVec) write(w io.Writer) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.header(w, "gauge")
+	for _, k := range sortedKeys(g.labels) {
+		fmt.Fprintf(w, "%s%s %s\n", g.fqName, g.labelPairs(g.labels[k]), formatFloat(g.values[k]))
+	}
+}
+
+// HistogramVec is a set of histograms partitioned by label values.
+type HistogramVec struct {
+	desc
+	buckets []float64
+	mu      sync.Mutex
+	labels  map[string][]string
+	series  map[string]*histogram
+}
+
+type histogram struct {
+	counts []uint64
+	sum    float64
+	count  uint64
+}
+
+// NewHistogramVec returns a new HistogramVec registered to Default.
+// "buckets" are upper bounds of the buckets in increasing order.
+func NewHistogramVec(name, help string, buckets []float64, labels ...string) *HistogramVec {
+	h := &HistogramVec{
+		desc:    desc{fqName: name, help: help, labels: labels},
+		buckets: buckets,
+		labels:  make(map[string][]string),
+		series:  make(map[string]*histogram),
+	}
+	Default.register(h)
+	return h
+}
+
+// Observe adds a single observation "v" to the histogram identified by "values".
+func (h *HistogramVec) Observe(v float64, values ...string) {
+	k := h.key(values)
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	s, ok := h.series[k]
+	if !ok {
+		s = &histogram{counts: make([]uint64, len(h.buckets))}
+		h.series[k] = s
+		h.labels[k] = values
+	}
+	for i, b := range h.buckets {
+		if v <= b {
+			s.counts[i]++
+		}
+	}
+	s.sum += v
+	s.count++
+}
+
+func (h *HistogramVec) write(w io.Writer) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.header(w, "histogram")
+	for _, k := range sortedKeys(h.labels) {
+		s, values := h.series[k], h.labels[k]
+		for i, b := range h.buckets {
+			fmt.Fprintf(w, "%s_bucket%s %d\n", h.fqName, h.labelPairs(values, "le", formatFloat(b)), s.counts[i])
+		}
+		fmt.Fprintf(w, "%s_bucket%s %d\n", h.fqName, h.labelPairs(values, "le", "+Inf"), s.count)
+		fmt.Fprintf(w, "%s_sum%s %s\n", h.fqName, h.labelPairs(values), formatFloat(s.sum))
+		fmt.Fprintf(w, "%s_count%s %d\n", h.fqName, h.labelPairs(values), s.count)
+	}
+}
@@ -0,0 +1,149 @@
+package notification
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gengo/goship/lib/config"
+	"github.com/golang/glog"
+	"golang.org/x/net/context"
+)
+
+// Digests keeps the events which NotifyAll summarizes for notifiers in digest mode.
+// It keeps them in memory unless the main program replaces it with a Digester in etcd.
+var Digests = NewDigester(config.NewMemoryStore())
+
+// Digester collects results of deployments per project and notifier in "client", and sends them as Digest events periodically.
+type Digester struct {
+	client config.ETCDInterface
+}
+
+// NewDigester returns a new Digester which keeps pending digests in "client".
+func NewDigester(client config.ETCDInterface) *Digester {
+	return &Digester{client: client}
+}
+
+// digested returns true if events of "t" are summarized in digests.
+// The other events except the starts of deployments are notified immediately even in digest mode.
+func (t EventType) digested() bool {
+	switch t {
+	case DeploySucceeded, DeployFailed, DeployRolledBack, RestartSucceeded, RestartFailed, ActionSucceeded, ActionFailed:
+		return true
+	}
+	return false
+}
+
+// skippedInDigest returns true if events of "t" are not notified at all in digest mode because digests cover them.
+func (t EventType) skippedInDigest() bool {
+	return t == DeployStarted || t == RestartStarted || t == ActionStarted
+}
+
+// Add adds "ev" of "project" to the digest sent to "cfg".
+// Failures are logged but not returned because notifications are best-effort.
+func (d *Digester) Add(cfg config.Notifier, project string, ev Event) {
+	buf, err := json.Marshal(ev)
+	if err == nil {
+		err = config.AddDigestEvent(d.client, project, cfg, cfg.Digest.Next(ev.Time), buf)
+	}
+	if err != nil {
+		glog.Errorf("Failed to add %s event of %s to the %s digest: %v", ev.Type, project, cfg.Digest, err)
+	}
+}
+
+// Flush sends the digests due at "now".
+// Only one of the instances of Goship sharing the same etcd sends each digest.
+func (d *Digester) Flush(ctx context.Context, now time.Time) {
+	due, err := config.TakeDueDigests(d.client, now)
+	if err != nil {
+		glog.Errorf("Failed to take due digests: %v", err)
+	}
+	for _, p := range due {
+		events := make([]Event, 0, len(p.Events))
+		for _, buf := range p.Events {
+			var ev Event
+			if err := json.Unmarshal(buf, &ev); err != nil {
+				glog.Errorf("Failed to decode an event in the %s digest of %s: %v", p.Notifier.Digest, p.Project, err)
+				continue
+			}
+			events = append(events, ev)
+		}
+		if len(events) == 0 {
+			continue
+		}
+		ev := newDigest(p.Notifier.Digest, events, p.Due)
+		n, err := NewNotifier(p.Notifier)
+		if err != nil {
+			glog.Errorf("Failed to build notifier for %s: %v", ev.Project, err)
+			continue
+		}
+		if err := n.Notify(ctx, ev); err != nil {
+			glog.Errorf("Failed to notify %s digest of %s to %s: %v", p.Notifier.Digest, ev.Project, p.Notifier.Type, err)
+		}
+	}
+}
+
+// Run flushes due digests every "interval" until "ctx" is done.
+func (d *Digester) Run(ctx context.Context, interval time.Duration) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(interval):
+		}
+		d.Flush(ctx, time.Now())
+	}
+}
+
+// newDigest returns a Digest event which summarizes "events" of a project in the period of "interval" ending at "end".
+func newDigest(interval config.DigestInterval, events []Event, end time.Time) Event {
+	return Event{
+		Type:    Digest,
+		Project: events[0].Project,
+		Reason:  string(interval),
+		Time:    end,
+		Events:  events,
+	}
+}
+
+// digestMessage returns a human-readable summary of the events in the Digest event "e".
+func digestMessage(e Event) string {
+	type summary struct {
+		succeeded, failed int
+		last              Event
+	}
+	envs := make(map[string]*summary)
+	var names []string
+	var succeeded, failed int
+	for _, ev := range e.Events {
+		s, ok := envs[ev.Environment]
+		if !ok {
+			s = new(summary)
+			envs[ev.Environment] = s
+			names = append(names, ev.Environment)
+		}
+		switch ev.Type {
+		case DeploySucceeded, RestartSucceeded, ActionSucceeded:
+			s.succeeded++
+			succeeded++
+		case DeployFailed, RestartFailed, ActionFailed:
+			s.failed++
+			failed++
+		}
+		s.last = ev
+	}
+	sort.Strings(names)
+
+	lines := []string{fmt.Sprintf("%s digest of %s: %d succeeded, %d failed.", strings.Title(e.Reason), e.Project, succeeded, failed)}
+	for _, name := range names {
+		s := envs[name]
+		line := fmt.Sprintf("*%s*: %d succeeded, %d failed", name, s.succeeded, s.failed)
+		if s.last.To != "" {
+			line = fmt.Sprintf("%s; last %s by %s", line, s.last.To.Short(), s.last.User)
+		}
+		lines = append(lines, line)
+	}
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,83 @@
+package notification
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/gengo/goship/lib/config"
+	"golang.org/x/net/context"
+)
+
+func TestDigest(t *testing.T) {
+	start := time.Date(2015, time.November, 10, 23, 10, 0, 0, time.UTC)
+	withRecorder(t, func(url string, received <-chan []byte) {
+		proj := config.Project{
+			Name:      "proj",
+			Notifiers: []config.Notifier{{Type: config.NotifierTypeWebhook, URL: url, Digest: config.DigestHourly}},
+		}
+		for i, typ := range []EventType{DeployStarted, DeploySucceeded, DeployStarted, DeployFailed} {
+			NotifyAll(context.Background(), proj, Event{Type: typ, Project: "proj", Environment: "production", User: "alice", To: "abc123", Time: start.Add(time.Duration(i) * time.Minute)})
+		}
+		select {
+		case buf := <-received:
+			t.Fatalf("received %s before the digest is due; want nothing", buf)
+		default:
+		}
+
+		Digests.Flush(context.Background(), start.Add(49*time.Minute))
+		select {
+		case buf := <-received:
+			t.Fatalf("received %s before the digest is due; want nothing", buf)
+		default:
+		}
+
+		Digests.Flush(context.Background(), start.Add(50*time.Minute))
+		var got Event
+		if err := json.Unmarshal(<-received, &got); err != nil {
+			t.Fatalf("json.Unmarshal failed with %v; want success", err)
+		}
+		if got.Type != Digest || len(got.Events) != 2 || !got.Time.Equal(start.Add(50*time.Minute)) {
+			t.Errorf("received %#v; want a digest of 2 events at %s", got, start.Add(50*time.Minute))
+		}
+		if got, want := got.Message(), "Hourly digest of proj: 1 succeeded, 1 failed.\n*production*: 1 succeeded, 1 failed; last abc123 by alice"; got != want {
+			t.Errorf("got.Message() = %q; want %q", got, want)
+		}
+
+		// other events are notified immediately
+		NotifyAll(context.Background(), proj, Event{Type: EnvironmentLocked, Project: "proj", Environment: "production", User: "alice"})
+		if err := json.Unmarshal(<-received, &got); err != nil {
+			t.Fatalf("json.Unmarshal failed with %v; want success", err)
+		}
+		if got.Type != EnvironmentLocked {
+			t.Errorf("got.Type = %q; want %q", got.Type, EnvironmentLocked)
+		}
+	})
+}
+
+func TestDigestAcrossInstances(t *testing.T) {
+	start := time.Date(2015, time.November, 10, 23, 10, 0, 0, time.UTC)
+	withRecorder(t, func(url string, received <-chan []byte) {
+		cfg := config.Notifier{Type: config.NotifierTypeWebhook, URL: url, Digest: config.DigestHourly}
+		s := config.NewMemoryStore()
+		NewDigester(s).Add(cfg, "proj", Event{Type: DeploySucceeded, Project: "proj", Environment: "production", User: "alice", To: "abc123", Time: start})
+		NewDigester(s).Add(cfg, "proj", Event{Type: DeployFailed, Project: "proj", Environment: "staging", User: "bob", To: "def456", Time: start.Add(time.Minute)})
+
+		// another instance, e.g. the leader after a restart, sends the digest once
+		leader := NewDigester(s)
+		leader.Flush(context.Background(), start.Add(50*time.Minute))
+		var got Event
+		if err := json.Unmarshal(<-received, &got); err != nil {
+			t.Fatalf("json.Unmarshal failed with %v; want success", err)
+		}
+		if got.Type != Digest || len(got.Events) != 2 {
+			t.Errorf("received %#v; want a digest of 2 events", got)
+		}
+		leader.Flush(context.Background(), start.Add(51*time.Minute))
+		select {
+		case buf := <-received:
+			t.Errorf("received %s after the digest was sent; want nothing", buf)
+		default:
+		}
+	})
+}
@@ -0,0 +1,46 @@
+package notification
+
+import (
+	"net/smtp"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/gengo/goship/lib/config"
+)
+
+func TestSendEmail(t *testing.T) {
+	var (
+		gotAddr, gotFrom string
+		gotTo            []string
+		gotMsg           []byte
+	)
+	sendMail = func(addr string, a smtp.Auth, from string, to []string, msg []byte) error {
+		gotAddr, gotFrom, gotTo, gotMsg = addr, from, to, msg
+		return nil
+	}
+	defer func() { sendMail = smtp.SendMail }()
+
+	cfg := config.SMTPConfig{Address: "smtp.example.com:587", From: "goship@example.com"}
+	to := []string{"alice@example.com", "bob@example.com"}
+	if err := SendEmail(cfg, to, "Weekly deploy report", "line 1\nline 2\n"); err != nil {
+		t.Fatalf("SendEmail failed with %v; want success", err)
+	}
+	if gotAddr != cfg.Address || gotFrom != cfg.From || !reflect.DeepEqual(gotTo, to) {
+		t.Errorf("sent to %q from %q to %q; want to %q from %q to %q", gotAddr, gotFrom, gotTo, cfg.Address, cfg.From, to)
+	}
+	msg := string(gotMsg)
+	for _, want := range []string{
+		"To: alice@example.com, bob@example.com\r\n",
+		"Subject: Weekly deploy report\r\n",
+		"\r\n\r\nline 1\r\nline 2\r\n",
+	} {
+		if !strings.Contains(msg, want) {
+			t.Errorf("message = %q; want it to contain %q", msg, want)
+		}
+	}
+
+	if err := SendEmail(cfg, nil, "subject", "body"); err == nil {
+		t.Errorf("SendEmail succeeded without recipients; want failure")
+	}
+}
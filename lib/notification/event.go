@@ -0,0 +1,227 @@
+package notification
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/gengo/goship/lib/revision"
+)
+
+// EventType is a kind of events which goship notifies to outbound destinations.
+type EventType string
+
+const (
+	// DeployStarted is notified when a deployment command starts.
+	DeployStarted = EventType("deploy_started")
+	// DeploySucceeded is notified when a deployment command finishes successfully.
+	DeploySucceeded = EventType("deploy_succeeded")
+	// DeployFailed is notified when a deployment command fails.
+	DeployFailed = EventType("deploy_failed")
+	// DeployRolledBack is notified when a deployment fails its health check and the previous revision is deployed again.
+	DeployRolledBack = EventType("deploy_rolled_back")
+	// DeployDurationAnomaly is notified when a deployment takes significantly longer or shorter than usual.
+	DeployDurationAnomaly = EventType("deploy_duration_anomaly")
+	// DeployHandedOff is notified when a running deployment is handed off to another owner.
+	DeployHandedOff = EventType("deploy_handed_off")
+	// EnvironmentLocked is notified when an environment gets locked.
+	EnvironmentLocked = EventType("locked")
+	// EnvironmentUnlocked is notified when an environment gets unlocked.
+	EnvironmentUnlocked = EventType("unlocked")
+	// EnvironmentPinned is notified when an environment gets pinned to a revision.
+	EnvironmentPinned = EventType("pinned")
+	// EnvironmentUnpinned is notified when an environment gets unpinned.
+	EnvironmentUnpinned = EventType("unpinned")
+	// RestartStarted is notified when a restart of an environment starts.
+	RestartStarted = EventType("restart_started")
+	// RestartSucceeded is notified when a restart of an environment finishes successfully.
+	RestartSucceeded = EventType("restart_succeeded")
+	// RestartFailed is notified when a restart of an environment fails.
+	RestartFailed = EventType("restart_failed")
+	// ActionStarted is notified when a quick action of an environment starts.
+	ActionStarted = EventType("action_started")
+	// ActionSucceeded is notified when a quick action of an environment finishes successfully.
+	ActionSucceeded = EventType("action_succeeded")
+	// ActionFailed is notified when a quick action of an environment fails.
+	ActionFailed = EventType("action_failed")
+	// CommandRequested is notified when a remote command waits for an approval.
+	CommandRequested = EventType("command_requested")
+	// CommandRejected is notified when a remote command gets rejected.
+	CommandRejected = EventType("command_rejected")
+	// CommandStarted is notified when a remote command starts on hosts of an environment.
+	CommandStarted = EventType("command_started")
+	// CommandSucceeded is notified when a remote command finishes successfully on all the hosts.
+	CommandSucceeded = EventType("command_succeeded")
+	// CommandFailed is notified when a remote command fails on any of the hosts.
+	CommandFailed = EventType("command_failed")
+	// ScheduleAdded is notified when a recurring action gets scheduled in an environment.
+	ScheduleAdded = EventType("schedule_added")
+	// ScheduleRemoved is notified when a recurring action gets unscheduled from an environment.
+	ScheduleRemoved = EventType("schedule_removed")
+	// ScheduleSkipped is notified when a scheduled deploy is skipped because its revision cannot be deployed.
+	ScheduleSkipped = EventType("schedule_skipped")
+	// EnvironmentReserved is notified when an environment gets reserved for a time window.
+	EnvironmentReserved = EventType("reserved")
+	// ReservationCanceled is notified when a reservation of an environment gets canceled.
+	ReservationCanceled = EventType("reservation_canceled")
+	// ApprovalRequested is notified when a deployment waits for an approval.
+	ApprovalRequested = EventType("approval_requested")
+	// DeployApproved is notified when a deployment gets approved.
+	DeployApproved = EventType("deploy_approved")
+	// DeployRejected is notified when a deployment gets rejected.
+	DeployRejected = EventType("deploy_rejected")
+	// DeployPaused is notified when a deployment waits for a manual confirmation before a host group.
+	DeployPaused = EventType("deploy_paused")
+	// DeployContinued is notified when a paused deployment gets continued.
+	DeployContinued = EventType("deploy_continued")
+	// DeployAborted is notified when a paused deployment gets aborted.
+	DeployAborted = EventType("deploy_aborted")
+	// Digest summarizes results of deployments of a project to notifiers in digest mode.
+	Digest = EventType("digest")
+)
+
+// Event describes something which happened to an environment of a project.
+type Event struct {
+	Type        EventType `json:"type"`
+	Project     string    `json:"project"`
+	Environment string    `json:"environment"`
+	// User is the name of the user who caused the event.
+	User string `json:"user"`
+	// Owner is the name of the user responsible for the deployment.
+	// It is empty for events which are not related to deployment.
+	Owner string `json:"owner,omitempty"`
+	// From is the revision deployed before the deployment.
+	// It is empty for events which are not related to deployment.
+	From revision.Revision `json:"from_revision,omitempty"`
+	// To is the revision to be deployed.
+	// It is empty for events which are not related to deployment.
+	To revision.Revision `json:"to_revision,omitempty"`
+	// DiffURL is an optional URL to a human-readable diff between From and To.
+	DiffURL string `json:"diff_url,omitempty"`
+	// Reason is an optional justification given by the user.
+	// For schedule events, it describes the scheduled action instead, for ScheduleSkipped, why it was skipped,
+	// for DeployPaused, what to confirm, for DeployDurationAnomaly, how the duration deviates,
+	// and for Digest, the interval of the digest.
+	Reason string    `json:"reason,omitempty"`
+	Time   time.Time `json:"time"`
+	// ApprovalID identifies the approval request of approval events.
+	ApprovalID string `json:"approval_id,omitempty"`
+	// Window describes the time window of reservation events.
+	Window string `json:"window,omitempty"`
+	// Labels are the labels of the deployment.
+	Labels []string `json:"labels,omitempty"`
+	// Step is the host group before which the deployment paused, for pause events.
+	Step string `json:"step,omitempty"`
+	// Events are the events summarized in Digest.
+	Events []Event `json:"events,omitempty"`
+	// Changes summarize the pull requests or the commits shipped by the deployment, one per line.
+	Changes []string `json:"changes,omitempty"`
+	// Skipped are the preceding environments which the revision of the deployment had not been deployed to.
+	Skipped []string `json:"skipped,omitempty"`
+	// Action is the name of the quick action of action events, or the scheduled action of ScheduleSkipped.
+	Action string `json:"action,omitempty"`
+	// Command is the remote command of command events.
+	Command string `json:"command,omitempty"`
+	// Hosts are the hosts which the remote command of command events runs on.
+	Hosts []string `json:"hosts,omitempty"`
+}
+
+// Message returns a human-readable description of the event.
+func (e Event) Message() string {
+	var msg string
+	switch e.Type {
+	case DeployStarted:
+		msg = withReason(fmt.Sprintf("%s is deploying %s to *%s*.", e.User, e.Project, e.Environment), e.Reason)
+	case DeploySucceeded:
+		msg = withOwner(fmt.Sprintf("%s successfully deployed to *%s*.", e.Project, e.Environment), e)
+	case DeployFailed:
+		msg = withReason(withOwner(fmt.Sprintf("%s deployment to *%s* failed.", e.Project, e.Environment), e), e.Reason)
+	case DeployRolledBack:
+		return withReason(fmt.Sprintf("%s in *%s* was rolled back to %s.", e.Project, e.Environment, e.To.Short()), e.Reason)
+	case DeployDurationAnomaly:
+		return fmt.Sprintf("The deployment of %s to *%s* by %s %s.", e.Project, e.Environment, e.Owner, e.Reason)
+	case DeployHandedOff:
+		return withReason(fmt.Sprintf("%s handed off the deployment of %s to *%s* to %s.", e.User, e.Project, e.Environment, e.Owner), e.Reason)
+	case EnvironmentLocked:
+		return withReason(fmt.Sprintf("%s locked %s in *%s*.", e.User, e.Project, e.Environment), e.Reason)
+	case EnvironmentUnlocked:
+		return withReason(fmt.Sprintf("%s unlocked %s in *%s*.", e.User, e.Project, e.Environment), e.Reason)
+	case EnvironmentPinned:
+		return withReason(fmt.Sprintf("%s pinned %s in *%s* to %s.", e.User, e.Project, e.Environment, e.To.Short()), e.Reason)
+	case EnvironmentUnpinned:
+		return withReason(fmt.Sprintf("%s unpinned %s in *%s*.", e.User, e.Project, e.Environment), e.Reason)
+	case RestartStarted:
+		return withReason(fmt.Sprintf("%s is restarting %s in *%s*.", e.User, e.Project, e.Environment), e.Reason)
+	case RestartSucceeded:
+		return fmt.Sprintf("%s successfully restarted in *%s*.", e.Project, e.Environment)
+	case RestartFailed:
+		return fmt.Sprintf("%s restart in *%s* failed.", e.Project, e.Environment)
+	case ActionStarted:
+		return fmt.Sprintf("%s is running %s of %s in *%s*.", e.User, e.Action, e.Project, e.Environment)
+	case ActionSucceeded:
+		return fmt.Sprintf("%s of %s in *%s* finished successfully.", e.Action, e.Project, e.Environment)
+	case ActionFailed:
+		return fmt.Sprintf("%s of %s in *%s* failed.", e.Action, e.Project, e.Environment)
+	case CommandRequested:
+		return withReason(fmt.Sprintf("%s requests approval to run `%s` on %s of %s in *%s*.", e.User, e.Command, strings.Join(e.Hosts, ", "), e.Project, e.Environment), e.Reason)
+	case CommandRejected:
+		return fmt.Sprintf("%s rejected `%s` on %s of %s in *%s* requested by %s.", e.User, e.Command, strings.Join(e.Hosts, ", "), e.Project, e.Environment, e.Owner)
+	case CommandStarted:
+		return withReason(fmt.Sprintf("%s is running `%s` on %s of %s in *%s*.", e.User, e.Command, strings.Join(e.Hosts, ", "), e.Project, e.Environment), e.Reason)
+	case CommandSucceeded:
+		return fmt.Sprintf("`%s` by %s on %s of %s in *%s* finished successfully.", e.Command, e.Owner, strings.Join(e.Hosts, ", "), e.Project, e.Environment)
+	case CommandFailed:
+		return fmt.Sprintf("`%s` by %s on %s of %s in *%s* failed.", e.Command, e.Owner, strings.Join(e.Hosts, ", "), e.Project, e.Environment)
+	case EnvironmentReserved:
+		return withReason(fmt.Sprintf("%s reserved %s in *%s* for %s.", e.User, e.Project, e.Environment, e.Window), e.Reason)
+	case ReservationCanceled:
+		return fmt.Sprintf("%s canceled the reservation of %s in *%s* for %s.", e.User, e.Project, e.Environment, e.Window)
+	case ApprovalRequested:
+		msg = fmt.Sprintf("%s requests approval to deploy %s to *%s*.", e.User, e.Project, e.Environment)
+	case DeployApproved:
+		msg = fmt.Sprintf("%s approved the deployment of %s to *%s* requested by %s.", e.User, e.Project, e.Environment, e.Owner)
+	case DeployRejected:
+		msg = fmt.Sprintf("%s rejected the deployment of %s to *%s* requested by %s.", e.User, e.Project, e.Environment, e.Owner)
+	case DeployPaused:
+		return fmt.Sprintf("The deployment of %s to *%s* by %s is waiting for confirmation before %s: %s", e.Project, e.Environment, e.Owner, e.Step, e.Reason)
+	case DeployContinued:
+		return fmt.Sprintf("%s continued the deployment of %s to *%s* before %s.", e.User, e.Project, e.Environment, e.Step)
+	case DeployAborted:
+		return fmt.Sprintf("%s aborted the deployment of %s to *%s* before %s.", e.User, e.Project, e.Environment, e.Step)
+	case Digest:
+		return digestMessage(e)
+	case ScheduleAdded:
+		return fmt.Sprintf("%s scheduled %s of %s in *%s*.", e.User, e.Reason, e.Project, e.Environment)
+	case ScheduleRemoved:
+		return fmt.Sprintf("%s unscheduled %s of %s in *%s*.", e.User, e.Reason, e.Project, e.Environment)
+	case ScheduleSkipped:
+		return withReason(fmt.Sprintf("The scheduled %s of %s to *%s* by %s was skipped.", e.Action, e.Project, e.Environment, e.User), e.Reason)
+	default:
+		return fmt.Sprintf("%s: %s in *%s* by %s", e.Type, e.Project, e.Environment, e.User)
+	}
+	if e.From != "" || e.To != "" {
+		msg = fmt.Sprintf("%s (%s...%s)", msg, e.From.Short(), e.To.Short())
+	}
+	if len(e.Skipped) > 0 {
+		msg = fmt.Sprintf("%s Warning: skipped %s.", msg, strings.Join(e.Skipped, ", "))
+	}
+	for _, c := range e.Changes {
+		msg = fmt.Sprintf("%s\n- %s", msg, c)
+	}
+	return msg
+}
+
+// withOwner mentions the owner of the deployment in "msg" if the deployment has been handed off.
+func withOwner(msg string, e Event) string {
+	if e.Owner == "" || e.Owner == e.User {
+		return msg
+	}
+	return fmt.Sprintf("%s Owner: %s", msg, e.Owner)
+}
+
+func withReason(msg, reason string) string {
+	if reason == "" {
+		return msg
+	}
+	return fmt.Sprintf("%s Reason: %s", msg, reason)
+}
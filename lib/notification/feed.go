@@ -0,0 +1,47 @@
+package notification
+
+import (
+	"sync"
+)
+
+const (
+	// activitySize is the number of events kept in Activity.
+	activitySize = 200
+)
+
+// Activity is the feed of recent events of all projects.
+// NotifyAll records events into it.
+var Activity = NewFeed(activitySize)
+
+// Feed keeps a fixed number of recent events in memory.
+type Feed struct {
+	mu     sync.Mutex
+	size   int
+	events []Event
+}
+
+// NewFeed returns a new empty Feed which keeps at most "size" events.
+func NewFeed(size int) *Feed {
+	return &Feed{size: size}
+}
+
+// Add records "ev" into the feed, discarding the oldest event if the feed is full.
+func (f *Feed) Add(ev Event) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.events = append(f.events, ev)
+	if len(f.events) > f.size {
+		f.events = f.events[len(f.events)-f.size:]
+	}
+}
+
+// Events returns the events in the feed, newest first.
+func (f *Feed) Events() []Event {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	events := make([]Event, 0, len(f.events))
+	for i := len(f.events) - 1; i >= 0; i-- {
+		events = append(events, f.events[i])
+	}
+	return events
+}
@@ -0,0 +1,23 @@
+package notification
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestFeed(t *testing.T) {
+	f := NewFeed(2)
+	if got := f.Events(); len(got) != 0 {
+		t.Errorf("f.Events() = %#v; want empty", got)
+	}
+	for _, env := range []string{"qa", "staging", "production"} {
+		f.Add(Event{Type: DeployStarted, Project: "proj", Environment: env})
+	}
+	want := []Event{
+		{Type: DeployStarted, Project: "proj", Environment: "production"},
+		{Type: DeployStarted, Project: "proj", Environment: "staging"},
+	}
+	if got := f.Events(); !reflect.DeepEqual(got, want) {
+		t.Errorf("f.Events() = %#v; want %#v", got, want)
+	}
+}
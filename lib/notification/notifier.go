@@ -0,0 +1,91 @@
+package notification
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gengo/goship/lib/config"
+	"github.com/gengo/goship/lib/logging"
+	"golang.org/x/net/context"
+)
+
+const (
+	// notifyTimeout is the maximum duration to wait for a response from a notification destination.
+	notifyTimeout = 10 * time.Second
+)
+
+// Notifier sends events to an outbound destination.
+type Notifier interface {
+	Notify(ctx context.Context, ev Event) error
+}
+
+// NewNotifier returns a new Notifier which sends events to the destination described in "cfg".
+func NewNotifier(cfg config.Notifier) (Notifier, error) {
+	switch cfg.Type {
+	case config.NotifierTypeSlack:
+		return slackNotifier{url: cfg.URL, channel: cfg.Channel}, nil
+	case config.NotifierTypeWebhook:
+		return webhookNotifier{url: cfg.URL}, nil
+	}
+	return nil, fmt.Errorf("unknown notifier type %q", cfg.Type)
+}
+
+// Recorder, if not nil, receives every event which NotifyAll sends, e.g. to keep an audit log.
+var Recorder func(Event)
+
+// NotifyAll records "ev" into Activity and Recorder and sends it to all the destinations configured in "proj" which accept the labels of "ev".
+// Results of deployments are added to Digests instead for destinations in digest mode.
+// Failures are logged but not returned because notifications are best-effort.
+func NotifyAll(ctx context.Context, proj config.Project, ev Event) {
+	if ev.Time.IsZero() {
+		ev.Time = time.Now()
+	}
+	Activity.Add(ev)
+	if Recorder != nil {
+		Recorder(ev)
+	}
+	for _, cfg := range proj.Notifiers {
+		if !cfg.Accepts(ev.Labels) {
+			continue
+		}
+		if cfg.Digest != "" {
+			if ev.Type.digested() {
+				Digests.Add(cfg, proj.Name, ev)
+				continue
+			}
+			if ev.Type.skippedInDigest() {
+				continue
+			}
+		}
+		n, err := NewNotifier(cfg)
+		if err != nil {
+			logging.FromContext(ctx).Errorf("Failed to build notifier for %s: %v", proj.Name, err)
+			continue
+		}
+		if err := n.Notify(ctx, ev); err != nil {
+			logging.FromContext(ctx).Errorf("Failed to notify %s event of %s (%s) to %s: %v", ev.Type, ev.Project, ev.Environment, cfg.Type, err)
+		}
+	}
+}
+
+var httpClient = &http.Client{Timeout: notifyTimeout}
+
+// postJSON posts "obj" to "url" as a JSON document.
+func postJSON(url string, obj interface{}) error {
+	buf, err := json.Marshal(obj)
+	if err != nil {
+		return err
+	}
+	resp, err := httpClient.Post(url, "application/json", bytes.NewReader(buf))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if code := resp.StatusCode; code < http.StatusOK || http.StatusMultipleChoices <= code {
+		return fmt.Errorf("Unexpected HTTP status %d from %s", code, url)
+	}
+	return nil
+}
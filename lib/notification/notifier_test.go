@@ -0,0 +1,131 @@
+package notification
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/gengo/goship/lib/config"
+	"golang.org/x/net/context"
+)
+
+func withRecorder(t *testing.T, f func(url string, received <-chan []byte)) {
+	ch := make(chan []byte, 1)
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if got, want := r.Header.Get("Content-Type"), "application/json"; got != want {
+			t.Errorf("Content-Type = %q; want %q", got, want)
+		}
+		var buf json.RawMessage
+		if err := json.NewDecoder(r.Body).Decode(&buf); err != nil {
+			t.Errorf("json.NewDecoder(r.Body).Decode(&buf) failed with %v; want success", err)
+		}
+		ch <- buf
+	}))
+	defer s.Close()
+	f(s.URL, ch)
+}
+
+func TestWebhookNotifier(t *testing.T) {
+	ev := Event{
+		Type:        DeploySucceeded,
+		Project:     "example-project",
+		Environment: "staging",
+		User:        "T-800",
+		From:        "abcdef0123456789",
+		To:          "0123456789abcdef",
+	}
+	withRecorder(t, func(url string, received <-chan []byte) {
+		n, err := NewNotifier(config.Notifier{Type: config.NotifierTypeWebhook, URL: url})
+		if err != nil {
+			t.Fatalf("NewNotifier(%q) failed with %v; want success", url, err)
+		}
+		if err := n.Notify(context.Background(), ev); err != nil {
+			t.Fatalf("n.Notify(ctx, %#v) failed with %v; want success", ev, err)
+		}
+		var got Event
+		if err := json.Unmarshal(<-received, &got); err != nil {
+			t.Fatalf("json.Unmarshal failed with %v; want success", err)
+		}
+		if !reflect.DeepEqual(got, ev) {
+			t.Errorf("received %#v; want %#v", got, ev)
+		}
+	})
+}
+
+func TestSlackNotifier(t *testing.T) {
+	ev := Event{
+		Type:        DeployStarted,
+		Project:     "example-project",
+		Environment: "staging",
+		User:        "T-800",
+		From:        "abcdef0123456789",
+		To:          "0123456789abcdef",
+		DiffURL:     "https://github.com/owner/repo/compare/abcdef0...0123456",
+	}
+	withRecorder(t, func(url string, received <-chan []byte) {
+		n, err := NewNotifier(config.Notifier{Type: config.NotifierTypeSlack, URL: url, Channel: "#deploy"})
+		if err != nil {
+			t.Fatalf("NewNotifier(%q) failed with %v; want success", url, err)
+		}
+		if err := n.Notify(context.Background(), ev); err != nil {
+			t.Fatalf("n.Notify(ctx, %#v) failed with %v; want success", ev, err)
+		}
+		var got slackMessage
+		if err := json.Unmarshal(<-received, &got); err != nil {
+			t.Fatalf("json.Unmarshal failed with %v; want success", err)
+		}
+		want := slackMessage{
+			Text:    "T-800 is deploying example-project to *staging*. (abcdef0...0123456) <https://github.com/owner/repo/compare/abcdef0...0123456|diff>",
+			Channel: "#deploy",
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("received %#v; want %#v", got, want)
+		}
+	})
+}
+
+func TestSlackNotifierApprovalButtons(t *testing.T) {
+	ev := Event{
+		Type:        ApprovalRequested,
+		Project:     "example-project",
+		Environment: "production",
+		User:        "T-800",
+		ApprovalID:  "3",
+	}
+	withRecorder(t, func(url string, received <-chan []byte) {
+		n := slackNotifier{url: url}
+		if err := n.Notify(context.Background(), ev); err != nil {
+			t.Fatalf("n.Notify(ctx, %#v) failed with %v; want success", ev, err)
+		}
+		var got slackMessage
+		if err := json.Unmarshal(<-received, &got); err != nil {
+			t.Fatalf("json.Unmarshal failed with %v; want success", err)
+		}
+		if len(got.Attachments) != 1 {
+			t.Fatalf("attachments = %#v; want 1 attachment", got.Attachments)
+		}
+		var names []string
+		for _, a := range got.Attachments[0].Actions {
+			if want := "example-project/production/3"; a.Value != want {
+				t.Errorf("value of %q = %q; want %q", a.Name, a.Value, want)
+			}
+			names = append(names, a.Name)
+		}
+		if want := []string{SlackApprove, SlackReject}; !reflect.DeepEqual(names, want) {
+			t.Errorf("actions = %q; want %q", names, want)
+		}
+	})
+}
+
+func TestNotifierErrorStatus(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "unavailable", http.StatusServiceUnavailable)
+	}))
+	defer s.Close()
+	n := webhookNotifier{url: s.URL}
+	if err := n.Notify(context.Background(), Event{Type: EnvironmentLocked}); err == nil {
+		t.Errorf("n.Notify(ctx, ev) succeeded; want failure")
+	}
+}
@@ -0,0 +1,113 @@
+package notification
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"golang.org/x/net/context"
+)
+
+const (
+	// SlackApprovalCallback is the callback ID of the buttons in approval requests.
+	SlackApprovalCallback = "goship_approval"
+	// SlackApprove and SlackReject are the names of the buttons in approval requests.
+	SlackApprove = "approve"
+	SlackReject  = "reject"
+
+	// maxSlackClockSkew is the maximum age of requests from Slack to prevent replays.
+	maxSlackClockSkew = 5 * time.Minute
+)
+
+// slackNotifier posts events to a Slack incoming webhook.
+type slackNotifier struct {
+	url     string
+	channel string
+}
+
+// slackMessage is a payload of Slack incoming webhooks.
+// See also https://api.slack.com/incoming-webhooks
+type slackMessage struct {
+	Text        string            `json:"text"`
+	Channel     string            `json:"channel,omitempty"`
+	Attachments []slackAttachment `json:"attachments,omitempty"`
+}
+
+// slackAttachment is an attachment of interactive Slack messages.
+// See also https://api.slack.com/docs/message-buttons
+type slackAttachment struct {
+	Text       string        `json:"text"`
+	CallbackID string        `json:"callback_id"`
+	Actions    []slackAction `json:"actions"`
+}
+
+type slackAction struct {
+	Name  string `json:"name"`
+	Text  string `json:"text"`
+	Type  string `json:"type"`
+	Value string `json:"value"`
+	Style string `json:"style,omitempty"`
+}
+
+func (n slackNotifier) Notify(ctx context.Context, ev Event) error {
+	text := ev.Message()
+	if ev.DiffURL != "" {
+		text = fmt.Sprintf("%s <%s|diff>", text, ev.DiffURL)
+	}
+	msg := slackMessage{Text: text, Channel: n.channel}
+	if ev.Type == ApprovalRequested {
+		value := SlackApprovalValue(ev.Project, ev.Environment, ev.ApprovalID)
+		msg.Attachments = []slackAttachment{
+			{
+				Text:       "Approve this deployment?",
+				CallbackID: SlackApprovalCallback,
+				Actions: []slackAction{
+					{Name: SlackApprove, Text: "Approve", Type: "button", Value: value, Style: "primary"},
+					{Name: SlackReject, Text: "Reject", Type: "button", Value: value, Style: "danger"},
+				},
+			},
+		}
+	}
+	return postJSON(n.url, msg)
+}
+
+// SlackApprovalValue returns the value of the buttons for the approval request "id" in "env" of "proj".
+func SlackApprovalValue(proj, env, id string) string {
+	return fmt.Sprintf("%s/%s/%s", proj, env, id)
+}
+
+// VerifySlackRequest verifies the signature of a request from Slack, whose body is "body", with the signing secret "secret".
+// See also https://api.slack.com/docs/verifying-requests-from-slack
+func VerifySlackRequest(secret string, header http.Header, body []byte, now time.Time) error {
+	ts := header.Get("X-Slack-Request-Timestamp")
+	sec, err := strconv.ParseInt(ts, 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid timestamp %q", ts)
+	}
+	if d := now.Sub(time.Unix(sec, 0)); d > maxSlackClockSkew || d < -maxSlackClockSkew {
+		return fmt.Errorf("stale timestamp %q", ts)
+	}
+	mac := hmac.New(sha256.New, []byte(secret))
+	fmt.Fprintf(mac, "v0:%s:%s", ts, body)
+	want := "v0=" + hex.EncodeToString(mac.Sum(nil))
+	if !hmac.Equal([]byte(header.Get("X-Slack-Signature")), []byte(want)) {
+		return fmt.Errorf("signature mismatch")
+	}
+	return nil
+}
+
+// slackReply is a delayed response to a slash command.
+type slackReply struct {
+	ResponseType string `json:"response_type"`
+	Text         string `json:"text"`
+}
+
+// ReplySlack posts "text" to the channel where a slash command was invoked, through its "responseURL".
+// See also https://api.slack.com/interactivity/handling#message_responses
+func ReplySlack(responseURL, text string) error {
+	return postJSON(responseURL, slackReply{ResponseType: "in_channel", Text: text})
+}
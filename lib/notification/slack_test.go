@@ -0,0 +1,41 @@
+package notification
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestVerifySlackRequest(t *testing.T) {
+	const secret = "8f742231b10e8888abcd99yyyzzz85a5"
+	body := []byte("command=%2Fgoship&text=status+my-project")
+	now := time.Unix(1531420618, 0)
+	sign := func(ts int64, secret string) http.Header {
+		mac := hmac.New(sha256.New, []byte(secret))
+		fmt.Fprintf(mac, "v0:%d:%s", ts, body)
+		h := make(http.Header)
+		h.Set("X-Slack-Request-Timestamp", fmt.Sprint(ts))
+		h.Set("X-Slack-Signature", "v0="+hex.EncodeToString(mac.Sum(nil)))
+		return h
+	}
+
+	if err := VerifySlackRequest(secret, sign(now.Unix(), secret), body, now); err != nil {
+		t.Errorf("VerifySlackRequest failed with %v; want success", err)
+	}
+	for _, spec := range []struct {
+		name   string
+		header http.Header
+	}{
+		{name: "wrong secret", header: sign(now.Unix(), "other")},
+		{name: "stale timestamp", header: sign(now.Add(-10*time.Minute).Unix(), secret)},
+		{name: "no signature", header: http.Header{"X-Slack-Request-Timestamp": {fmt.Sprint(now.Unix())}}},
+	} {
+		if err := VerifySlackRequest(secret, spec.header, body, now); err == nil {
+			t.Errorf("VerifySlackRequest succeeded with %s; want failure", spec.name)
+		}
+	}
+}
@@ -0,0 +1,14 @@
+package notification
+
+import (
+	"golang.org/x/net/context"
+)
+
+// webhookNotifier posts events as JSON documents to a generic HTTP endpoint.
+type webhookNotifier struct {
+	url string
+}
+
+func (n webhookNotifier) Notify(ctx context.Context, ev Event) error {
+	return postJSON(n.url, ev)
+}
@@ -1,10 +1,21 @@
 package notification
 
 import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gengo/goship/lib/metrics"
+	"github.com/golang/glog"
 	"golang.org/x/net/context"
 	"golang.org/x/net/websocket"
 )
 
+var activeConnections = metrics.NewGaugeVec("goship_websocket_connections", "Number of active websocket connections.")
+
+// maxBacklog is the number of the latest messages which the hub keeps so that reconnecting clients can resume.
+const maxBacklog = 1000
+
 // NewHub returns a new hub which is accepting notifications and connections.
 // The hub stops accepting new requests when "ctx" is canceled.
 func NewHub(ctx context.Context) *Hub {
@@ -12,14 +23,23 @@ func NewHub(ctx context.Context) *Hub {
 		broadcast:   make(chan string),
 		register:    make(chan *connection),
 		connections: make(map[*connection]context.CancelFunc),
+		done:        make(chan struct{}),
 	}
 	go h.run(ctx)
 	return h
 }
 
-// Hub is a hub of websocket connections.
+// Hub is a hub of websocket connections and server-sent event streams.
 // Those connections are connected to each other via this hub.
 type Hub struct {
+	// Replay optionally returns messages which describe the current state of "environment" of "project",
+	// e.g. the output of the deployment in progress. They are sent to clients which subscribe to the environment when they connect.
+	// It must be set before the hub accepts connections.
+	Replay func(project, environment string) []string
+	// Relay optionally forwards broadcast messages to other servers, e.g. the other instances of a cluster.
+	// It must not block. It must be set before the hub accepts notifications.
+	Relay func(msg string)
+
 	// connections are the registered connections.
 	connections map[*connection]context.CancelFunc
 
@@ -28,84 +48,254 @@ type Hub struct {
 
 	// register accepts new connections to be registered
 	register chan *connection
+
+	// seq is the sequence number of the last broadcast message.
+	seq uint64
+	// backlog are the latest broadcast messages, oldest first.
+	backlog []Message
+
+	// done is closed when the hub has stopped and closed all the connections.
+	done chan struct{}
+}
+
+// Message is a broadcast message with its sequence number, which increases by one for each message.
+type Message struct {
+	Seq  uint64 `json:"seq"`
+	Data string `json:"data"`
 }
 
 // AcceptConnection receives a websocket connection and register it as a subscriber of broadcast notifications.
+//
+// Clients which connect with "seq=true" receive messages wrapped in Message, while other clients receive bare messages for compatibility.
+// Clients which reconnect with "since", the sequence number of the last message they received,
+// receive the messages they missed first as long as the hub still keeps them.
+// Clients which connect with "project" and "environment" also receive the messages which Replay returns.
+//
+// e.g. ws://127.0.0.1:8000/web_push?seq=true&since=120&project=my-project&environment=staging
 func (h *Hub) AcceptConnection(ws *websocket.Conn) {
-	r, w := make(chan string, 256), h.broadcast
-	c := connection{ws: ws, r: r, w: w, closed: make(chan struct{})}
-	h.register <- &c
+	c := newConnection(ws.Request(), h.broadcast)
+	c.ws = ws
+	if c.resume || ws.Request().FormValue("seq") == "true" {
+		c.send = func(m Message) error { return websocket.JSON.Send(ws, m) }
+	} else {
+		c.send = func(m Message) error { return websocket.Message.Send(ws, m.Data) }
+	}
+	h.register <- c
 	<-c.closed
 }
 
-// Broadcast sends "msg" to the registered connections.
+// ServeEvents streams broadcast notifications as server-sent events, for clients which cannot use websockets, e.g. behind proxies.
+// The ID of each event is the sequence number of the message, so that clients resume from "Last-Event-ID" when they reconnect.
+// It also takes "since", "project" and "environment" in the same way as AcceptConnection.
+//
+// e.g. GET http://127.0.0.1:8000/events?project=my-project&environment=staging
+func (h *Hub) ServeEvents(w http.ResponseWriter, r *http.Request) {
+	h.ServeFilteredEvents(w, r, nil)
+}
+
+// ServeFilteredEvents is ServeEvents which streams only the messages for which "allow" returns true, including replayed ones.
+// It streams all the messages if "allow" is nil.
+func (h *Hub) ServeFilteredEvents(w http.ResponseWriter, r *http.Request, allow func(msg string) bool) {
+	f, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming not supported", http.StatusInternalServerError)
+		return
+	}
+	c := newConnection(r, nil)
+	c.allow = allow
+	if since, err := strconv.ParseUint(r.Header.Get("Last-Event-ID"), 10, 64); err == nil {
+		c.resume, c.since = true, since
+	}
+	if cn, ok := w.(http.CloseNotifier); ok {
+		c.gone = cn.CloseNotify()
+	}
+	c.send = func(m Message) error {
+		if err := writeEvent(w, m); err != nil {
+			return err
+		}
+		f.Flush()
+		return nil
+	}
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	f.Flush()
+	h.register <- c
+	<-c.closed
+}
+
+// Done returns a channel which is closed when the hub has stopped after its context was canceled and closed all the connections.
+func (h *Hub) Done() <-chan struct{} {
+	return h.done
+}
+
+// Broadcast sends "msg" to the registered connections and passes it to Relay if set. It does nothing after the hub has stopped.
 func (h *Hub) Broadcast(msg string) {
-	h.broadcast <- msg
+	if h.Relay != nil {
+		h.Relay(msg)
+	}
+	h.BroadcastLocal(msg)
+}
+
+// BroadcastLocal sends "msg" to the registered connections without passing it to Relay,
+// e.g. for messages relayed from other servers. It does nothing after the hub has stopped.
+func (h *Hub) BroadcastLocal(msg string) {
+	select {
+	case h.broadcast <- msg:
+	case <-h.done:
+	}
 }
 
 func (h *Hub) run(ctx context.Context) {
 	for {
 		select {
 		case <-ctx.Done():
+			for c := range h.connections {
+				<-c.closed
+			}
+			close(h.done)
 			return
 		case c := <-h.register:
-			h.connections[c] = c.start(ctx)
+			h.connections[c] = c.start(ctx, h.replay(c))
 		case m := <-h.broadcast:
+			h.seq++
+			msg := Message{Seq: h.seq, Data: m}
+			if h.backlog = append(h.backlog, msg); len(h.backlog) > maxBacklog {
+				h.backlog = h.backlog[1:]
+			}
 			for c, cancel := range h.connections {
 				select {
 				case <-c.closed:
 					delete(h.connections, c)
-				case c.r <- m:
+				case c.r <- msg:
 				default:
+					glog.Warningf("Dropped a slow client of push notifications from %s at message %d", c.remoteAddr, msg.Seq)
 					delete(h.connections, c)
 					cancel()
 				}
 			}
 		}
+		activeConnections.Set(float64(len(h.connections)))
+	}
+}
+
+// replay returns the messages which "c" should receive before new ones:
+// the messages after the one which "c" received last, and the ones which Replay returns.
+func (h *Hub) replay(c *connection) []Message {
+	var msgs []Message
+	if c.resume {
+		for _, m := range h.backlog {
+			if m.Seq > c.since {
+				msgs = append(msgs, m)
+			}
+		}
+	}
+	if h.Replay != nil && c.project != "" && c.environment != "" {
+		for _, m := range h.Replay(c.project, c.environment) {
+			msgs = append(msgs, Message{Seq: h.seq, Data: m})
+		}
 	}
+	return msgs
 }
 
-// connection is a bidirectional pipe between a websocket connection and go channels.
+// connection is a subscriber of the hub.
 type connection struct {
-	// The websocket connection.
+	// send sends a message to the client.
+	send func(Message) error
+
+	// The websocket connection, or nil for server-sent events.
 	ws *websocket.Conn
+	// gone is closed when the client of server-sent events disconnects.
+	gone <-chan bool
 
-	r chan string
+	remoteAddr string
+	// resume is true if the client gave since, the sequence number of the last message it received.
+	resume bool
+	since  uint64
+	// project and environment are the environment whose state the client wants to be replayed.
+	project, environment string
+	// allow returns true if the client can receive a message. The client receives all the messages if nil.
+	allow func(msg string) bool
+
+	r chan Message
 	w chan<- string
 
 	// closed is a channel which is closed when this connection is being closed
 	closed chan struct{}
 }
 
-// start starts forwarding between internal channels and the websocket connection
+// newConnection returns a connection for the client which sent "r". Messages from the client are sent to "w".
+func newConnection(r *http.Request, w chan<- string) *connection {
+	c := &connection{
+		remoteAddr:  r.RemoteAddr,
+		project:     r.FormValue("project"),
+		environment: r.FormValue("environment"),
+		r:           make(chan Message, 256),
+		w:           w,
+		closed:      make(chan struct{}),
+	}
+	if s := r.FormValue("since"); s != "" {
+		if since, err := strconv.ParseUint(s, 10, 64); err == nil {
+			c.resume, c.since = true, since
+		}
+	}
+	return c
+}
+
+// start starts forwarding between internal channels and the client after sending "replay".
 // The forwarding loop stops when "ctx" is canceled or the function which this function returns is called.
-func (c *connection) start(ctx context.Context) context.CancelFunc {
+func (c *connection) start(ctx context.Context, replay []Message) context.CancelFunc {
 	ctx, cancel := context.WithCancel(ctx)
+	written := make(chan struct{})
 	go func() {
+		defer close(written)
 		defer cancel()
-		c.writerLoop(ctx)
-	}()
-	go func() {
-		defer cancel()
-		c.readerLoop(ctx)
+		c.writerLoop(ctx, replay)
 	}()
+	if c.ws != nil {
+		go func() {
+			defer cancel()
+			c.readerLoop(ctx)
+		}()
+	} else {
+		go func() {
+			defer cancel()
+			select {
+			case <-ctx.Done():
+			case <-c.gone:
+			}
+		}()
+	}
 	go func() {
 		<-ctx.Done()
+		// Server-sent events must not be written after ServeEvents returns.
+		<-written
 		close(c.closed)
 	}()
 	return cancel
 }
 
-func (c *connection) writerLoop(ctx context.Context) {
+func (c *connection) writerLoop(ctx context.Context, replay []Message) {
+	for _, m := range replay {
+		if c.allow != nil && !c.allow(m.Data) {
+			continue
+		}
+		if err := c.send(m); err != nil {
+			glog.V(1).Infof("Failed to replay push notifications to %s: %v", c.remoteAddr, err)
+			return
+		}
+	}
 	for {
 		select {
 		case <-ctx.Done():
 			return
 		case message := <-c.r:
-			err := websocket.Message.Send(c.ws, message)
-			if err != nil {
-				// TODO(yugui) add logging
-				break
+			if c.allow != nil && !c.allow(message.Data) {
+				continue
+			}
+			if err := c.send(message); err != nil {
+				glog.V(1).Infof("Failed to send a push notification to %s: %v", c.remoteAddr, err)
+				return
 			}
 		}
 	}
@@ -119,7 +309,7 @@ func (c *connection) readerLoop(ctx context.Context) {
 			var message string
 			err := websocket.Message.Receive(c.ws, &message)
 			if err != nil {
-				// TODO(yugui) add logging
+				glog.V(1).Infof("Websocket connection from %s closed: %v", c.remoteAddr, err)
 				close(ch)
 				return
 			}
@@ -140,3 +330,13 @@ func (c *connection) readerLoop(ctx context.Context) {
 		}
 	}
 }
+
+// writeEvent writes "m" to "w" as a server-sent event.
+func writeEvent(w http.ResponseWriter, m Message) error {
+	buf := []byte("id: " + strconv.FormatUint(m.Seq, 10) + "\n")
+	for _, l := range strings.Split(m.Data, "\n") {
+		buf = append(buf, "data: "+l+"\n"...)
+	}
+	_, err := w.Write(append(buf, '\n'))
+	return err
+}
@@ -1,10 +1,13 @@
 package notification
 
 import (
+	"bufio"
 	"fmt"
 	"io"
+	"net/http"
 	"net/http/httptest"
 	"net/url"
+	"strings"
 	"testing"
 	"time"
 
@@ -274,3 +277,174 @@ func TestHub(t *testing.T) {
 		}
 	})
 }
+
+func TestResume(t *testing.T) {
+	ctx := context.Background()
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	h := NewHub(ctx)
+	for _, msg := range []string{"example 1", "example 2", "example 3"} {
+		h.Broadcast(msg)
+	}
+	withStubServer(t, h, func(s *stubServer) {
+		s.location.RawQuery = "since=1"
+		ws := s.Dial(t)
+		defer ws.Close()
+
+		if err := waitForConnectionEstablished(h, 1); err != nil {
+			t.Errorf("waitForConnectionEstablished(h, 1) failed with %v; want success", err)
+			return
+		}
+		h.Broadcast("example 4")
+		for _, want := range []Message{{Seq: 2, Data: "example 2"}, {Seq: 3, Data: "example 3"}, {Seq: 4, Data: "example 4"}} {
+			var got Message
+			if err := websocket.JSON.Receive(ws, &got); err != nil {
+				t.Fatalf("websocket.JSON.Receive(ws, &got) failed with %v; want success", err)
+			}
+			if got != want {
+				t.Errorf("got = %#v; want %#v", got, want)
+			}
+		}
+	})
+}
+
+func TestReplay(t *testing.T) {
+	ctx := context.Background()
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	h := NewHub(ctx)
+	h.Replay = func(project, environment string) []string {
+		return []string{fmt.Sprintf("state of %s-%s", project, environment)}
+	}
+	withStubServer(t, h, func(s *stubServer) {
+		s.location.RawQuery = "project=proj&environment=env"
+		ws := s.Dial(t)
+		defer ws.Close()
+
+		if err := waitForConnectionEstablished(h, 1); err != nil {
+			t.Errorf("waitForConnectionEstablished(h, 1) failed with %v; want success", err)
+			return
+		}
+		h.Broadcast("example 1")
+		for _, want := range []string{"state of proj-env", "example 1"} {
+			var got string
+			if err := websocket.Message.Receive(ws, &got); err != nil {
+				t.Fatalf("websocket.Message.Receive(ws, &got) failed with %v; want success", err)
+			}
+			if got != want {
+				t.Errorf("got = %q; want %q", got, want)
+			}
+		}
+	})
+}
+
+func TestServeEvents(t *testing.T) {
+	ctx := context.Background()
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	h := NewHub(ctx)
+	h.Broadcast("example 1")
+	h.Broadcast("example 2")
+
+	s := httptest.NewServer(http.HandlerFunc(h.ServeEvents))
+	defer s.Close()
+	req, err := http.NewRequest("GET", s.URL, nil)
+	if err != nil {
+		t.Fatalf("http.NewRequest(%q, %q, nil) failed with %v; want success", "GET", s.URL, err)
+	}
+	req.Header.Set("Last-Event-ID", "1")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("http.DefaultClient.Do(req) failed with %v; want success", err)
+	}
+	defer resp.Body.Close()
+	if got, want := resp.Header.Get("Content-Type"), "text/event-stream"; got != want {
+		t.Errorf("Content-Type = %q; want %q", got, want)
+	}
+
+	if err := waitForConnectionEstablished(h, 1); err != nil {
+		t.Fatalf("waitForConnectionEstablished(h, 1) failed with %v; want success", err)
+	}
+	h.Broadcast("example 3\nsecond line")
+	r := bufio.NewReader(resp.Body)
+	for _, want := range []string{"id: 2", "data: example 2", "", "id: 3", "data: example 3", "data: second line", ""} {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			t.Fatalf("r.ReadString('\\n') failed with %v; want success", err)
+		}
+		if got := strings.TrimSuffix(line, "\n"); got != want {
+			t.Errorf("line = %q; want %q", got, want)
+		}
+	}
+}
+
+func TestServeFilteredEvents(t *testing.T) {
+	ctx := context.Background()
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	h := NewHub(ctx)
+	h.Broadcast("hidden 1")
+	h.Broadcast("visible 2")
+
+	allow := func(msg string) bool { return strings.HasPrefix(msg, "visible") }
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		h.ServeFilteredEvents(w, r, allow)
+	}))
+	defer s.Close()
+	req, err := http.NewRequest("GET", s.URL+"?since=0", nil)
+	if err != nil {
+		t.Fatalf("http.NewRequest(%q, %q, nil) failed with %v; want success", "GET", s.URL+"?since=0", err)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("http.DefaultClient.Do(req) failed with %v; want success", err)
+	}
+	defer resp.Body.Close()
+
+	if err := waitForConnectionEstablished(h, 1); err != nil {
+		t.Fatalf("waitForConnectionEstablished(h, 1) failed with %v; want success", err)
+	}
+	h.Broadcast("hidden 3")
+	h.Broadcast("visible 4")
+	r := bufio.NewReader(resp.Body)
+	for _, want := range []string{"id: 2", "data: visible 2", "", "id: 4", "data: visible 4", ""} {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			t.Fatalf("r.ReadString('\\n') failed with %v; want success", err)
+		}
+		if got := strings.TrimSuffix(line, "\n"); got != want {
+			t.Errorf("line = %q; want %q", got, want)
+		}
+	}
+}
+
+func TestRelay(t *testing.T) {
+	ctx := context.Background()
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	h := NewHub(ctx)
+	var relayed []string
+	h.Relay = func(msg string) { relayed = append(relayed, msg) }
+	withStubServer(t, h, func(s *stubServer) {
+		ws := s.Dial(t)
+		defer ws.Close()
+		if err := waitForConnectionEstablished(h, 1); err != nil {
+			t.Fatalf("waitForConnectionEstablished(h, 1) failed with %v; want success", err)
+		}
+
+		h.Broadcast("local")
+		h.BroadcastLocal("from peer")
+		for _, want := range []string{"local", "from peer"} {
+			var msg string
+			if err := websocket.Message.Receive(ws, &msg); err != nil {
+				t.Fatalf("websocket.Message.Receive(ws, &msg) failed with %v; want success", err)
+			}
+			if msg != want {
+				t.Errorf("msg = %q; want %q", msg, want)
+			}
+		}
+		if want := []string{"local"}; len(relayed) != 1 || relayed[0] != want[0] {
+			t.Errorf("relayed = %q; want %q", relayed, want)
+		}
+	})
+}
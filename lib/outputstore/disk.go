@@ -0,0 +1,77 @@
+package outputstore
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+type disk struct {
+	dir string
+}
+
+// NewDisk returns a Store which keeps objects as files under "dir".
+func NewDisk(dir string) Store {
+	return disk{dir: dir}
+}
+
+func (d disk) path(key string) string {
+	return filepath.Join(d.dir, filepath.FromSlash(key))
+}
+
+func (d disk) Put(key string, r io.Reader) error {
+	p := d.path(key)
+	if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+		return err
+	}
+	f, err := os.Create(p)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(f, r); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func (d disk) Get(key string) (io.ReadCloser, error) {
+	f, err := os.Open(d.path(key))
+	if os.IsNotExist(err) {
+		return nil, ErrNotFound
+	}
+	return f, err
+}
+
+func (d disk) List(prefix string) ([]Object, error) {
+	var objs []Object
+	err := filepath.Walk(d.dir, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			return nil
+		}
+		rel, err := filepath.Rel(d.dir, p)
+		if err != nil {
+			return err
+		}
+		if key := filepath.ToSlash(rel); strings.HasPrefix(key, prefix) {
+			objs = append(objs, Object{Key: key, Modified: info.ModTime()})
+		}
+		return nil
+	})
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	return objs, err
+}
+
+func (d disk) Delete(key string) error {
+	err := os.Remove(d.path(key))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	return err
+}
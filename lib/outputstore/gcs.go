@@ -0,0 +1,130 @@
+package outputstore
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// GCSScope is the OAuth2 scope which clients passed to NewGCS need.
+const GCSScope = "https://www.googleapis.com/auth/devstorage.read_write"
+
+const gcsEndpoint = "https://storage.googleapis.com"
+
+type gcsStore struct {
+	client   *http.Client
+	endpoint string
+	bucket   string
+	prefix   string
+}
+
+// NewGCS returns a Store which keeps objects in "bucket" of Google Cloud Storage with "prefix" prepended to their names.
+// "client" must be authorized with GCSScope.
+func NewGCS(client *http.Client, bucket, prefix string) Store {
+	return gcsStore{client: client, endpoint: gcsEndpoint, bucket: bucket, prefix: prefix}
+}
+
+// objectURL returns the URL of the metadata of "key", or its content if "media" is true.
+func (s gcsStore) objectURL(key string, media bool) string {
+	name := strings.Replace(url.QueryEscape(joinKey(s.prefix, key)), "+", "%20", -1)
+	u := fmt.Sprintf("%s/storage/v1/b/%s/o/%s", s.endpoint, s.bucket, name)
+	if media {
+		u += "?alt=media"
+	}
+	return u
+}
+
+func (s gcsStore) Put(key string, r io.Reader) error {
+	q := url.Values{"uploadType": {"media"}, "name": {joinKey(s.prefix, key)}}
+	u := fmt.Sprintf("%s/upload/storage/v1/b/%s/o?%s", s.endpoint, s.bucket, q.Encode())
+	resp, err := s.do("POST", u, r)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+func (s gcsStore) Get(key string) (io.ReadCloser, error) {
+	resp, err := s.do("GET", s.objectURL(key, true), nil)
+	if err != nil {
+		return nil, err
+	}
+	return resp.Body, nil
+}
+
+func (s gcsStore) Delete(key string) error {
+	resp, err := s.do("DELETE", s.objectURL(key, false), nil)
+	if err == ErrNotFound {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+// gcsObjects is the response of objects.list.
+type gcsObjects struct {
+	Items []struct {
+		Name    string    `json:"name"`
+		Updated time.Time `json:"updated"`
+	} `json:"items"`
+	NextPageToken string `json:"nextPageToken"`
+}
+
+func (s gcsStore) List(prefix string) ([]Object, error) {
+	var objs []Object
+	q := url.Values{"prefix": {joinKey(s.prefix, prefix)}, "fields": {"items(name,updated),nextPageToken"}}
+	for {
+		resp, err := s.do("GET", fmt.Sprintf("%s/storage/v1/b/%s/o?%s", s.endpoint, s.bucket, q.Encode()), nil)
+		if err != nil {
+			return nil, err
+		}
+		var res gcsObjects
+		err = json.NewDecoder(resp.Body).Decode(&res)
+		resp.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		for _, item := range res.Items {
+			objs = append(objs, Object{Key: trimKey(s.prefix, item.Name), Modified: item.Updated})
+		}
+		if res.NextPageToken == "" {
+			return objs, nil
+		}
+		q.Set("pageToken", res.NextPageToken)
+	}
+}
+
+// do sends a request to the JSON API of Cloud Storage.
+// It returns ErrNotFound on 404, and an error on other unsuccessful statuses.
+func (s gcsStore) do(method, rawurl string, body io.Reader) (*http.Response, error) {
+	req, err := http.NewRequest(method, rawurl, body)
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "text/plain; charset=utf-8")
+	}
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		resp.Body.Close()
+		return nil, ErrNotFound
+	case resp.StatusCode/100 != 2:
+		msg, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 1024))
+		resp.Body.Close()
+		return nil, fmt.Errorf("%s %s failed with %s: %s", method, req.URL.Path, resp.Status, msg)
+	}
+	return resp, nil
+}
@@ -0,0 +1,61 @@
+package outputstore
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestGCSStore(t *testing.T) {
+	objects := make(map[string]string)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		const objectPath = "/storage/v1/b/bucket/o/"
+		switch {
+		case r.Method == "POST" && r.URL.Path == "/upload/storage/v1/b/bucket/o":
+			buf, _ := ioutil.ReadAll(r.Body)
+			objects[r.FormValue("name")] = string(buf)
+			fmt.Fprint(w, "{}")
+		case r.Method == "GET" && r.URL.Path == "/storage/v1/b/bucket/o":
+			var res gcsObjects
+			for k := range objects {
+				if strings.HasPrefix(k, r.FormValue("prefix")) {
+					res.Items = append(res.Items, struct {
+						Name    string    `json:"name"`
+						Updated time.Time `json:"updated"`
+					}{Name: k, Updated: time.Now()})
+				}
+			}
+			json.NewEncoder(w).Encode(res)
+		case strings.HasPrefix(r.URL.Path, objectPath):
+			name := strings.TrimPrefix(r.URL.Path, objectPath)
+			content, ok := objects[name]
+			if !ok {
+				http.NotFound(w, r)
+				return
+			}
+			if r.Method == "DELETE" {
+				delete(objects, name)
+				w.WriteHeader(http.StatusNoContent)
+				return
+			}
+			if r.FormValue("alt") != "media" {
+				t.Errorf("object fetched without alt=media")
+			}
+			fmt.Fprint(w, content)
+		default:
+			http.Error(w, "unexpected request", http.StatusBadRequest)
+		}
+	}))
+	defer srv.Close()
+
+	s := gcsStore{client: http.DefaultClient, endpoint: srv.URL, bucket: "bucket", prefix: "goship"}
+	testStore(t, s)
+	if _, ok := objects["goship/proj-other/3 4.log"]; !ok {
+		t.Errorf("objects = %q; want the remaining object with the prefix", objects)
+	}
+}
@@ -0,0 +1,247 @@
+package outputstore
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// S3Config configures a Store in Amazon S3.
+// The credential is read from AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY and optionally AWS_SESSION_TOKEN.
+type S3Config struct {
+	Bucket string
+	// Prefix is prepended to the keys of objects with a slash.
+	Prefix string
+	// Region is the region of the bucket. "us-east-1" if empty.
+	Region string
+	// Endpoint is the base URL of an S3-compatible service. Buckets are addressed in path style if set.
+	Endpoint string
+}
+
+type s3Store struct {
+	cfg                         S3Config
+	accessKey, secretKey, token string
+	client                      *http.Client
+	now                         func() time.Time
+}
+
+// NewS3 returns a Store which keeps objects in the S3 bucket configured in "cfg".
+func NewS3(cfg S3Config) (Store, error) {
+	if cfg.Bucket == "" {
+		return nil, errors.New("bucket is required for S3")
+	}
+	if cfg.Region == "" {
+		cfg.Region = "us-east-1"
+	}
+	if cfg.Endpoint == "" {
+		cfg.Endpoint = fmt.Sprintf("https://s3.%s.amazonaws.com", cfg.Region)
+	}
+	s := s3Store{
+		cfg:       cfg,
+		accessKey: os.Getenv("AWS_ACCESS_KEY_ID"),
+		secretKey: os.Getenv("AWS_SECRET_ACCESS_KEY"),
+		token:     os.Getenv("AWS_SESSION_TOKEN"),
+		client:    http.DefaultClient,
+		now:       time.Now,
+	}
+	if s.accessKey == "" || s.secretKey == "" {
+		return nil, errors.New("AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY are required for S3")
+	}
+	return s, nil
+}
+
+// objectURL returns the URL of "key" in the bucket in path style, with its path escaped as S3 expects.
+func (s s3Store) objectURL(key string, query url.Values) string {
+	p := "/" + s.cfg.Bucket
+	if key != "" {
+		p += "/" + escapePath(joinKey(s.cfg.Prefix, key))
+	}
+	u := strings.TrimSuffix(s.cfg.Endpoint, "/") + p
+	if len(query) > 0 {
+		u += "?" + strings.Replace(query.Encode(), "+", "%20", -1)
+	}
+	return u
+}
+
+func (s s3Store) Put(key string, r io.Reader) error {
+	// the payload is signed, so it needs to be read in advance
+	body, err := ioutil.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	resp, err := s.do("PUT", s.objectURL(key, nil), body)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+func (s s3Store) Get(key string) (io.ReadCloser, error) {
+	resp, err := s.do("GET", s.objectURL(key, nil), nil)
+	if err != nil {
+		return nil, err
+	}
+	return resp.Body, nil
+}
+
+func (s s3Store) Delete(key string) error {
+	resp, err := s.do("DELETE", s.objectURL(key, nil), nil)
+	if err == ErrNotFound {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+// listBucketResult is the response of ListObjectsV2.
+type listBucketResult struct {
+	Contents []struct {
+		Key          string
+		LastModified time.Time
+	}
+	IsTruncated           bool
+	NextContinuationToken string
+}
+
+func (s s3Store) List(prefix string) ([]Object, error) {
+	var objs []Object
+	q := url.Values{"list-type": {"2"}, "prefix": {joinKey(s.cfg.Prefix, prefix)}}
+	for {
+		resp, err := s.do("GET", s.objectURL("", q), nil)
+		if err != nil {
+			return nil, err
+		}
+		var res listBucketResult
+		err = xml.NewDecoder(resp.Body).Decode(&res)
+		resp.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		for _, c := range res.Contents {
+			objs = append(objs, Object{Key: trimKey(s.cfg.Prefix, c.Key), Modified: c.LastModified})
+		}
+		if !res.IsTruncated {
+			return objs, nil
+		}
+		q.Set("continuation-token", res.NextContinuationToken)
+	}
+}
+
+// do sends a request signed with AWS Signature Version 4.
+// It returns ErrNotFound on 404, and an error on other unsuccessful statuses.
+func (s s3Store) do(method, rawurl string, body []byte) (*http.Response, error) {
+	req, err := http.NewRequest(method, rawurl, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	s.sign(req, body)
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		resp.Body.Close()
+		return nil, ErrNotFound
+	case resp.StatusCode/100 != 2:
+		msg, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 1024))
+		resp.Body.Close()
+		return nil, fmt.Errorf("%s %s failed with %s: %s", method, req.URL.Path, resp.Status, msg)
+	}
+	return resp, nil
+}
+
+// sign adds the headers of AWS Signature Version 4 to "req".
+func (s s3Store) sign(req *http.Request, body []byte) {
+	now := s.now().UTC()
+	date, stamp := now.Format("20060102"), now.Format("20060102T150405Z")
+	payload := sha256.Sum256(body)
+	req.Header.Set("x-amz-date", stamp)
+	req.Header.Set("x-amz-content-sha256", hex.EncodeToString(payload[:]))
+	if s.token != "" {
+		req.Header.Set("x-amz-security-token", s.token)
+	}
+
+	names := []string{"host"}
+	for name := range req.Header {
+		names = append(names, strings.ToLower(name))
+	}
+	sort.Strings(names)
+	var canonicalHeaders bytes.Buffer
+	for _, name := range names {
+		v := req.Host
+		if v == "" {
+			v = req.URL.Host
+		}
+		if name != "host" {
+			v = strings.TrimSpace(req.Header.Get(name))
+		}
+		fmt.Fprintf(&canonicalHeaders, "%s:%s\n", name, v)
+	}
+	signedHeaders := strings.Join(names, ";")
+	canonical := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		req.URL.RawQuery,
+		canonicalHeaders.String(),
+		signedHeaders,
+		hex.EncodeToString(payload[:]),
+	}, "\n")
+
+	scope := fmt.Sprintf("%s/%s/s3/aws4_request", date, s.cfg.Region)
+	hash := sha256.Sum256([]byte(canonical))
+	toSign := strings.Join([]string{"AWS4-HMAC-SHA256", stamp, scope, hex.EncodeToString(hash[:])}, "\n")
+	key := hmacSHA256([]byte("AWS4"+s.secretKey), date)
+	for _, part := range []string{s.cfg.Region, "s3", "aws4_request"} {
+		key = hmacSHA256(key, part)
+	}
+	signature := hex.EncodeToString(hmacSHA256(key, toSign))
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s", s.accessKey, scope, signedHeaders, signature))
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	m := hmac.New(sha256.New, key)
+	m.Write([]byte(data))
+	return m.Sum(nil)
+}
+
+// escapePath escapes each segment of "key" as URI-encoded in AWS Signature Version 4.
+func escapePath(key string) string {
+	segments := strings.Split(key, "/")
+	for i, seg := range segments {
+		segments[i] = strings.Replace(url.QueryEscape(seg), "+", "%20", -1)
+	}
+	return strings.Join(segments, "/")
+}
+
+// joinKey prepends "prefix" to "key" with a slash if "prefix" is not empty.
+func joinKey(prefix, key string) string {
+	if prefix == "" {
+		return key
+	}
+	return prefix + "/" + key
+}
+
+// trimKey removes "prefix" and the following slash from "key".
+func trimKey(prefix, key string) string {
+	if prefix == "" {
+		return key
+	}
+	return strings.TrimPrefix(key, prefix+"/")
+}
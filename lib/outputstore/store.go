@@ -0,0 +1,117 @@
+// Package outputstore persists outputs of finished deployments in the local disk, Amazon S3 or Google Cloud Storage.
+package outputstore
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ErrNotFound is returned when an object does not exist in a Store.
+var ErrNotFound = errors.New("no such output")
+
+// Object is an output stored in a Store.
+type Object struct {
+	// Key is the slash-separated name of the object, e.g. "proj-env/2015-11-10 23:00:00 +0000 UTC.log".
+	Key string
+	// Modified is the time when the object was stored.
+	Modified time.Time
+}
+
+// Store keeps outputs of deployments by keys.
+type Store interface {
+	// Put stores the content of "r" as "key", replacing the existing object if any.
+	Put(key string, r io.Reader) error
+	// Get returns a reader of the object "key", which the caller must close.
+	// It returns ErrNotFound if the object does not exist.
+	Get(key string) (io.ReadCloser, error)
+	// List returns the objects whose keys start with "prefix".
+	List(prefix string) ([]Object, error)
+	// Delete removes the object "key".
+	Delete(key string) error
+}
+
+// New returns a Store at "rawurl".
+//
+// "rawurl" is either "s3://bucket/prefix", "gs://bucket/prefix" or a path to a local directory.
+// S3 buckets take the region in "region" query parameter, e.g. "s3://bucket/prefix?region=us-west-2",
+// and optionally an S3-compatible endpoint in "endpoint", e.g. "s3://bucket?endpoint=http://minio:9000".
+// "gcs" is an HTTP client authorized to read and write Google Cloud Storage, and is used only for "gs://" URLs.
+func New(rawurl string, gcs *http.Client) (Store, error) {
+	u, err := url.Parse(rawurl)
+	if err != nil {
+		return nil, err
+	}
+	prefix := strings.Trim(u.Path, "/")
+	switch u.Scheme {
+	case "s3":
+		q := u.Query()
+		return NewS3(S3Config{Bucket: u.Host, Prefix: prefix, Region: q.Get("region"), Endpoint: q.Get("endpoint")})
+	case "gs":
+		if gcs == nil {
+			return nil, fmt.Errorf("no credential for %s", rawurl)
+		}
+		return NewGCS(gcs, u.Host, prefix), nil
+	case "":
+		return NewDisk(rawurl), nil
+	}
+	return nil, fmt.Errorf("unsupported output store %q", rawurl)
+}
+
+// Retention decides which outputs are removed by Compact.
+type Retention struct {
+	// KeepLast is the number of the latest outputs kept in each directory. All outputs are kept if zero.
+	KeepLast int
+	// MaxAge is the maximum age of outputs. Outputs are kept regardless of their age if zero.
+	MaxAge time.Duration
+}
+
+// Compact removes the outputs in "s" which "r" does not keep, and returns the number of removed outputs.
+// Only objects whose keys end with "suffix" are considered, and outputs are counted per directory, i.e. per environment.
+func Compact(s Store, r Retention, suffix string, now time.Time) (int, error) {
+	if r.KeepLast <= 0 && r.MaxAge <= 0 {
+		return 0, nil
+	}
+	objs, err := s.List("")
+	if err != nil {
+		return 0, err
+	}
+	dirs := make(map[string][]Object)
+	for _, o := range objs {
+		if !strings.HasSuffix(o.Key, suffix) {
+			continue
+		}
+		dir := ""
+		if i := strings.LastIndex(o.Key, "/"); i >= 0 {
+			dir = o.Key[:i]
+		}
+		dirs[dir] = append(dirs[dir], o)
+	}
+
+	var n int
+	for _, objs := range dirs {
+		sort.Sort(sort.Reverse(byModified(objs)))
+		for i, o := range objs {
+			expired := r.MaxAge > 0 && now.Sub(o.Modified) > r.MaxAge
+			if !expired && (r.KeepLast <= 0 || i < r.KeepLast) {
+				continue
+			}
+			if err := s.Delete(o.Key); err != nil {
+				return n, err
+			}
+			n++
+		}
+	}
+	return n, nil
+}
+
+type byModified []Object
+
+func (os byModified) Len() int           { return len(os) }
+func (os byModified) Swap(i, j int)      { os[i], os[j] = os[j], os[i] }
+func (os byModified) Less(i, j int) bool { return os[i].Modified.Before(os[j].Modified) }
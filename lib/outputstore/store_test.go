@@ -0,0 +1,108 @@
+package outputstore
+
+import (
+	"io"
+	"io/ioutil"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testStore tests the basic operations of "s", which must be empty.
+func testStore(t *testing.T, s Store) {
+	if _, err := s.Get("proj-env/1 2.log"); err != ErrNotFound {
+		t.Errorf("s.Get of a missing object failed with %v; want ErrNotFound", err)
+	}
+	for key, content := range map[string]string{
+		"proj-env/1 2.log":   "line1\nline2\n",
+		"proj-other/3 4.log": "line3\n",
+	} {
+		if err := s.Put(key, strings.NewReader(content)); err != nil {
+			t.Fatalf("s.Put(%q, r) failed with %v; want success", key, err)
+		}
+	}
+	r, err := s.Get("proj-env/1 2.log")
+	if err != nil {
+		t.Fatalf("s.Get failed with %v; want success", err)
+	}
+	buf, err := ioutil.ReadAll(r)
+	r.Close()
+	if err != nil || string(buf) != "line1\nline2\n" {
+		t.Errorf("content = %q, %v; want %q", buf, err, "line1\nline2\n")
+	}
+
+	objs, err := s.List("proj-env/")
+	if err != nil {
+		t.Fatalf("s.List failed with %v; want success", err)
+	}
+	if len(objs) != 1 || objs[0].Key != "proj-env/1 2.log" {
+		t.Errorf("s.List(%q) = %#v; want proj-env/1 2.log", "proj-env/", objs)
+	}
+
+	if err := s.Delete("proj-env/1 2.log"); err != nil {
+		t.Fatalf("s.Delete failed with %v; want success", err)
+	}
+	if _, err := s.Get("proj-env/1 2.log"); err != ErrNotFound {
+		t.Errorf("s.Get of a deleted object failed with %v; want ErrNotFound", err)
+	}
+}
+
+func TestDisk(t *testing.T) {
+	dir, err := ioutil.TempDir("", "outputstore")
+	if err != nil {
+		t.Fatalf("ioutil.TempDir failed with %v", err)
+	}
+	defer os.RemoveAll(dir)
+	testStore(t, NewDisk(dir))
+}
+
+// memory is a Store in memory with fixed modification times.
+type memory map[string]time.Time
+
+func (m memory) Put(key string, r io.Reader) error     { m[key] = time.Time{}; return nil }
+func (m memory) Get(key string) (io.ReadCloser, error) { return nil, ErrNotFound }
+func (m memory) Delete(key string) error               { delete(m, key); return nil }
+func (m memory) List(prefix string) ([]Object, error) {
+	var objs []Object
+	for k, t := range m {
+		objs = append(objs, Object{Key: k, Modified: t})
+	}
+	return objs, nil
+}
+
+func TestCompact(t *testing.T) {
+	now := time.Date(2015, time.November, 10, 23, 0, 0, 0, time.UTC)
+	day := 24 * time.Hour
+	for _, spec := range []struct {
+		r    Retention
+		want []string
+	}{
+		{r: Retention{}, want: []string{"a.json", "a/1.log", "a/2.log", "a/3.log", "b/1.log"}},
+		{r: Retention{KeepLast: 2}, want: []string{"a.json", "a/2.log", "a/3.log", "b/1.log"}},
+		{r: Retention{MaxAge: 2 * day}, want: []string{"a.json", "a/3.log"}},
+		{r: Retention{KeepLast: 1, MaxAge: 10 * day}, want: []string{"a.json", "a/3.log", "b/1.log"}},
+	} {
+		m := memory{
+			"a.json":  now.Add(-10 * day),
+			"a/1.log": now.Add(-3 * day),
+			"a/2.log": now.Add(-2*day - time.Hour),
+			"a/3.log": now.Add(-day),
+			"b/1.log": now.Add(-5 * day),
+		}
+		if _, err := Compact(m, spec.r, ".log", now); err != nil {
+			t.Errorf("Compact(m, %#v, .log, now) failed with %v; want success", spec.r, err)
+			continue
+		}
+		var got []string
+		for k := range m {
+			got = append(got, k)
+		}
+		sort.Strings(got)
+		if !reflect.DeepEqual(got, spec.want) {
+			t.Errorf("objects after Compact(m, %#v, .log, now) = %q; want %q", spec.r, got, spec.want)
+		}
+	}
+}
@@ -0,0 +1,183 @@
+// Package report summarizes deployments over a period, e.g. for weekly reports to teams.
+package report
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/gengo/goship/lib/revision"
+)
+
+const (
+	// MaxSlowest is the number of the slowest deployments listed in a summary.
+	MaxSlowest = 3
+	// MaxContributors is the number of the top contributors listed in a summary.
+	MaxContributors = 3
+)
+
+// Deploy is a finished deployment.
+type Deploy struct {
+	Project     string            `json:"project"`
+	Environment string            `json:"environment"`
+	User        string            `json:"user"`
+	To          revision.Revision `json:"to"`
+	Success     bool              `json:"success"`
+	Time        time.Time         `json:"time"`
+	// Duration is zero if unknown.
+	Duration time.Duration `json:"duration"`
+	// Skipped are the preceding environments which the revision had not been deployed to.
+	Skipped []string `json:"skipped,omitempty"`
+}
+
+// Report summarizes deployments of projects from Start until End.
+type Report struct {
+	// Team is the name of the team which the report is sent to, or empty for a report of a single project.
+	Team     string    `json:"team,omitempty"`
+	Start    time.Time `json:"start"`
+	End      time.Time `json:"end"`
+	Projects []Summary `json:"projects"`
+}
+
+// Summary summarizes deployments of a project.
+type Summary struct {
+	Project  string `json:"project"`
+	Deploys  int    `json:"deploys"`
+	Failures int    `json:"failures"`
+	// Skipped is the number of deployments which skipped preceding environments.
+	Skipped int `json:"skipped"`
+	// Environments are the counts per environment, sorted by name.
+	Environments []Count `json:"environments"`
+	// Slowest are the slowest deployments whose durations are known, slowest first.
+	Slowest []Deploy `json:"slowest"`
+	// Contributors are the users who deployed most, with the numbers of their deployments.
+	Contributors []Count `json:"contributors"`
+}
+
+// Count is the number of deployments of an environment or by a user.
+type Count struct {
+	Name     string `json:"name"`
+	Deploys  int    `json:"deploys"`
+	Failures int    `json:"failures"`
+	Skipped  int    `json:"skipped,omitempty"`
+}
+
+// Week returns the last whole week before "t", from Monday midnight to the next Monday midnight in the location of "t".
+func Week(t time.Time) (start, end time.Time) {
+	// Weekday counts from Sunday but weeks start on Monday.
+	days := (int(t.Weekday()) + 6) % 7
+	end = time.Date(t.Year(), t.Month(), t.Day()-days, 0, 0, 0, 0, t.Location())
+	return end.AddDate(0, 0, -7), end
+}
+
+// Summarize summarizes "deploys" of "project".
+func Summarize(project string, deploys []Deploy) Summary {
+	s := Summary{Project: project, Environments: []Count{}, Slowest: []Deploy{}, Contributors: []Count{}}
+	envs := make(map[string]*Count)
+	users := make(map[string]*Count)
+	var timed []Deploy
+	for _, d := range deploys {
+		s.Deploys++
+		add(envs, d.Environment, d)
+		add(users, d.User, d)
+		if !d.Success {
+			s.Failures++
+		}
+		if len(d.Skipped) > 0 {
+			s.Skipped++
+		}
+		if d.Duration > 0 {
+			timed = append(timed, d)
+		}
+	}
+	for _, c := range envs {
+		s.Environments = append(s.Environments, *c)
+	}
+	sort.Sort(byName(s.Environments))
+
+	sort.Sort(bySlowness(timed))
+	if len(timed) > MaxSlowest {
+		timed = timed[:MaxSlowest]
+	}
+	s.Slowest = append(s.Slowest, timed...)
+
+	for _, c := range users {
+		s.Contributors = append(s.Contributors, *c)
+	}
+	sort.Sort(byDeploys(s.Contributors))
+	if len(s.Contributors) > MaxContributors {
+		s.Contributors = s.Contributors[:MaxContributors]
+	}
+	return s
+}
+
+func add(counts map[string]*Count, name string, d Deploy) {
+	c, ok := counts[name]
+	if !ok {
+		c = &Count{Name: name}
+		counts[name] = c
+	}
+	c.Deploys++
+	if !d.Success {
+		c.Failures++
+	}
+	if len(d.Skipped) > 0 {
+		c.Skipped++
+	}
+}
+
+// Text renders "r" as the plain text body of an email.
+func (r Report) Text() string {
+	var buf bytes.Buffer
+	const layout = "Jan 2, 2006"
+	fmt.Fprintf(&buf, "Deployments from %s to %s\n", r.Start.Format(layout), r.End.AddDate(0, 0, -1).Format(layout))
+	for _, s := range r.Projects {
+		fmt.Fprintf(&buf, "\n%s: %d deployments, %d failed\n", s.Project, s.Deploys, s.Failures)
+		if s.Deploys == 0 {
+			continue
+		}
+		for _, e := range s.Environments {
+			fmt.Fprintf(&buf, "  %s: %d deployments, %d failed", e.Name, e.Deploys, e.Failures)
+			if e.Skipped > 0 {
+				fmt.Fprintf(&buf, ", %d skipped preceding environments", e.Skipped)
+			}
+			fmt.Fprintln(&buf)
+		}
+		if len(s.Slowest) > 0 {
+			fmt.Fprintln(&buf, "  Slowest deployments:")
+			for _, d := range s.Slowest {
+				fmt.Fprintf(&buf, "    %s to %s by %s on %s: %s\n", d.To.Short(), d.Environment, d.User, d.Time.Format(layout), d.Duration/time.Second*time.Second)
+			}
+		}
+		fmt.Fprintln(&buf, "  Top contributors:")
+		for _, c := range s.Contributors {
+			fmt.Fprintf(&buf, "    %s: %d deployments\n", c.Name, c.Deploys)
+		}
+	}
+	return buf.String()
+}
+
+type byName []Count
+
+func (cs byName) Len() int           { return len(cs) }
+func (cs byName) Swap(i, j int)      { cs[i], cs[j] = cs[j], cs[i] }
+func (cs byName) Less(i, j int) bool { return cs[i].Name < cs[j].Name }
+
+// byDeploys sorts counts by the numbers of deployments in descending order, and then by names.
+type byDeploys []Count
+
+func (cs byDeploys) Len() int      { return len(cs) }
+func (cs byDeploys) Swap(i, j int) { cs[i], cs[j] = cs[j], cs[i] }
+func (cs byDeploys) Less(i, j int) bool {
+	if cs[i].Deploys != cs[j].Deploys {
+		return cs[i].Deploys > cs[j].Deploys
+	}
+	return cs[i].Name < cs[j].Name
+}
+
+type bySlowness []Deploy
+
+func (ds bySlowness) Len() int           { return len(ds) }
+func (ds bySlowness) Swap(i, j int)      { ds[i], ds[j] = ds[j], ds[i] }
+func (ds bySlowness) Less(i, j int) bool { return ds[i].Duration > ds[j].Duration }
@@ -0,0 +1,63 @@
+package report
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWeek(t *testing.T) {
+	loc := time.FixedZone("JST", 9*60*60)
+	wantStart := time.Date(2015, 11, 2, 0, 0, 0, 0, loc)
+	wantEnd := time.Date(2015, 11, 9, 0, 0, 0, 0, loc)
+	for _, now := range []time.Time{
+		time.Date(2015, 11, 9, 0, 0, 0, 0, loc),
+		time.Date(2015, 11, 11, 15, 4, 5, 0, loc),
+		time.Date(2015, 11, 15, 23, 59, 59, 0, loc),
+	} {
+		start, end := Week(now)
+		if !start.Equal(wantStart) || !end.Equal(wantEnd) {
+			t.Errorf("Week(%v) = %v, %v; want %v, %v", now, start, end, wantStart, wantEnd)
+		}
+	}
+}
+
+func TestSummarize(t *testing.T) {
+	at := time.Date(2015, 11, 3, 10, 0, 0, 0, time.UTC)
+	deploys := []Deploy{
+		{Environment: "staging", User: "alice", Success: true, Time: at, Duration: 2 * time.Minute},
+		{Environment: "staging", User: "bob", Success: false, Time: at, Duration: 9 * time.Minute},
+		{Environment: "production", User: "alice", Success: true, Time: at, Duration: 5 * time.Minute},
+		{Environment: "production", User: "carol", Success: true, Time: at},
+		{Environment: "production", User: "dave", Success: true, Time: at, Duration: time.Minute},
+		{Environment: "production", User: "alice", Success: true, Time: at, Duration: 3 * time.Minute, Skipped: []string{"staging"}},
+	}
+	s := Summarize("example-project", deploys)
+	if s.Deploys != 6 || s.Failures != 1 || s.Skipped != 1 {
+		t.Errorf("deploys, failures, skipped = %d, %d, %d; want 6, 1, 1", s.Deploys, s.Failures, s.Skipped)
+	}
+	wantEnvs := []Count{{Name: "production", Deploys: 4, Skipped: 1}, {Name: "staging", Deploys: 2, Failures: 1}}
+	if !reflect.DeepEqual(s.Environments, wantEnvs) {
+		t.Errorf("environments = %#v; want %#v", s.Environments, wantEnvs)
+	}
+	var slowest []time.Duration
+	for _, d := range s.Slowest {
+		slowest = append(slowest, d.Duration)
+	}
+	if want := []time.Duration{9 * time.Minute, 5 * time.Minute, 3 * time.Minute}; !reflect.DeepEqual(slowest, want) {
+		t.Errorf("durations of the slowest = %v; want %v", slowest, want)
+	}
+	wantContributors := []Count{{Name: "alice", Deploys: 3, Skipped: 1}, {Name: "bob", Deploys: 1, Failures: 1}, {Name: "carol", Deploys: 1}}
+	if !reflect.DeepEqual(s.Contributors, wantContributors) {
+		t.Errorf("contributors = %#v; want %#v", s.Contributors, wantContributors)
+	}
+
+	r := Report{Start: at, End: at.AddDate(0, 0, 7), Projects: []Summary{s, Summarize("quiet-project", nil)}}
+	text := r.Text()
+	for _, want := range []string{"example-project: 6 deployments, 1 failed", "to staging by bob", "production: 4 deployments, 0 failed, 1 skipped preceding environments", "alice: 3 deployments", "quiet-project: 0 deployments"} {
+		if !strings.Contains(text, want) {
+			t.Errorf("r.Text() = %q; want it to contain %q", text, want)
+		}
+	}
+}
@@ -0,0 +1,171 @@
+package report
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/gengo/goship/lib/revision"
+)
+
+// Timeline is deployments from Start until End counted in buckets of a fixed width, in the shape which charting libraries take:
+// Buckets are the x-axis, and each of Series has a value for each bucket.
+type Timeline struct {
+	Start time.Time `json:"start"`
+	End   time.Time `json:"end"`
+	// BucketSeconds is the width of each bucket.
+	BucketSeconds int64 `json:"bucket_seconds"`
+	// Buckets are the start times of the buckets, oldest first.
+	Buckets []time.Time `json:"buckets"`
+	// Series are the counts per environment, sorted by project and environment.
+	Series []Series `json:"series"`
+	// Deploys are the deployments in the order of their start times, for timelines which draw each deployment as a bar.
+	Deploys []Span `json:"deploys"`
+}
+
+// Series is the deployments to an environment per bucket of a timeline.
+type Series struct {
+	Project     string `json:"project"`
+	Environment string `json:"environment"`
+	Successes   []int  `json:"successes"`
+	Failures    []int  `json:"failures"`
+	// AvgDurationSeconds are the average durations of the deployments with known durations, or nil for buckets without them.
+	AvgDurationSeconds []*float64 `json:"avg_duration_seconds"`
+}
+
+// Span is a deployment on a timeline.
+type Span struct {
+	Project     string            `json:"project"`
+	Environment string            `json:"environment"`
+	User        string            `json:"user"`
+	To          revision.Revision `json:"to"`
+	Success     bool              `json:"success"`
+	Start       time.Time         `json:"start"`
+	// End is the same as Start if the duration is unknown.
+	End time.Time `json:"end"`
+}
+
+// MaxBuckets is the maximum number of buckets in a timeline.
+const MaxBuckets = 1000
+
+// NewTimeline counts "deploys" from "start" until "end" in buckets of "bucket".
+// Buckets are aligned to multiples of "bucket" since the zero time in UTC, e.g. to midnights in UTC for a day.
+// Deployments out of the range are ignored.
+func NewTimeline(deploys []Deploy, start, end time.Time, bucket time.Duration) (Timeline, error) {
+	if bucket < time.Minute {
+		return Timeline{}, fmt.Errorf("bucket %s shorter than a minute", bucket)
+	}
+	if !start.Before(end) {
+		return Timeline{}, fmt.Errorf("start %s not before end %s", start.Format(time.RFC3339), end.Format(time.RFC3339))
+	}
+	first := start.UTC().Truncate(bucket)
+	n := int((end.Sub(first) + bucket - 1) / bucket)
+	if n > MaxBuckets {
+		return Timeline{}, fmt.Errorf("%d buckets of %s from %s until %s exceed %d", n, bucket, start.Format(time.RFC3339), end.Format(time.RFC3339), MaxBuckets)
+	}
+	t := Timeline{
+		Start:         start,
+		End:           end,
+		BucketSeconds: int64(bucket / time.Second),
+		Buckets:       make([]time.Time, n),
+		Series:        []Series{},
+		Deploys:       []Span{},
+	}
+	for i := range t.Buckets {
+		t.Buckets[i] = first.Add(time.Duration(i) * bucket)
+	}
+
+	series := make(map[string]*Series)
+	totals := make(map[string][]time.Duration)
+	timed := make(map[string][]int)
+	for _, d := range deploys {
+		if d.Time.Before(start) || !d.Time.Before(end) {
+			continue
+		}
+		key := d.Project + "\x00" + d.Environment
+		s, ok := series[key]
+		if !ok {
+			s = &Series{Project: d.Project, Environment: d.Environment, Successes: make([]int, n), Failures: make([]int, n), AvgDurationSeconds: make([]*float64, n)}
+			series[key] = s
+			totals[key], timed[key] = make([]time.Duration, n), make([]int, n)
+		}
+		i := int(d.Time.Sub(first) / bucket)
+		if d.Success {
+			s.Successes[i]++
+		} else {
+			s.Failures[i]++
+		}
+		if d.Duration > 0 {
+			totals[key][i] += d.Duration
+			timed[key][i]++
+		}
+		t.Deploys = append(t.Deploys, Span{
+			Project:     d.Project,
+			Environment: d.Environment,
+			User:        d.User,
+			To:          d.To,
+			Success:     d.Success,
+			Start:       d.Time,
+			End:         d.Time.Add(d.Duration),
+		})
+	}
+	for key, s := range series {
+		for i, c := range timed[key] {
+			if c > 0 {
+				avg := (totals[key][i] / time.Duration(c)).Seconds()
+				s.AvgDurationSeconds[i] = &avg
+			}
+		}
+		t.Series = append(t.Series, *s)
+	}
+	sort.Sort(bySeries(t.Series))
+	sort.Sort(bySpanStart(t.Deploys))
+	return t, nil
+}
+
+// Datapoints is a time series in the format of Grafana's JSON data sources:
+// each datapoint is a pair of a value and a time in milliseconds since the epoch.
+type Datapoints struct {
+	Target     string       `json:"target"`
+	Datapoints [][2]float64 `json:"datapoints"`
+}
+
+// Datapoints returns the successes, failures and average durations of each environment as time series for Grafana,
+// whose targets are "project/environment successes", "project/environment failures" and "project/environment avg_duration_seconds".
+// Buckets without deployments of known durations have no datapoints of average durations.
+func (t Timeline) Datapoints() []Datapoints {
+	ds := []Datapoints{}
+	for _, s := range t.Series {
+		name := s.Project + "/" + s.Environment
+		successes := Datapoints{Target: name + " successes", Datapoints: [][2]float64{}}
+		failures := Datapoints{Target: name + " failures", Datapoints: [][2]float64{}}
+		durations := Datapoints{Target: name + " avg_duration_seconds", Datapoints: [][2]float64{}}
+		for i, b := range t.Buckets {
+			ms := float64(b.UnixNano() / int64(time.Millisecond))
+			successes.Datapoints = append(successes.Datapoints, [2]float64{float64(s.Successes[i]), ms})
+			failures.Datapoints = append(failures.Datapoints, [2]float64{float64(s.Failures[i]), ms})
+			if avg := s.AvgDurationSeconds[i]; avg != nil {
+				durations.Datapoints = append(durations.Datapoints, [2]float64{*avg, ms})
+			}
+		}
+		ds = append(ds, successes, failures, durations)
+	}
+	return ds
+}
+
+type bySeries []Series
+
+func (ss bySeries) Len() int      { return len(ss) }
+func (ss bySeries) Swap(i, j int) { ss[i], ss[j] = ss[j], ss[i] }
+func (ss bySeries) Less(i, j int) bool {
+	if ss[i].Project != ss[j].Project {
+		return ss[i].Project < ss[j].Project
+	}
+	return ss[i].Environment < ss[j].Environment
+}
+
+type bySpanStart []Span
+
+func (ss bySpanStart) Len() int           { return len(ss) }
+func (ss bySpanStart) Swap(i, j int)      { ss[i], ss[j] = ss[j], ss[i] }
+func (ss bySpanStart) Less(i, j int) bool { return ss[i].Start.Before(ss[j].Start) }
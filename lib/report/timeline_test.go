@@ -0,0 +1,82 @@
+package report
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestNewTimeline(t *testing.T) {
+	start := time.Date(2015, 11, 2, 9, 30, 0, 0, time.UTC)
+	end := time.Date(2015, 11, 4, 0, 0, 0, 0, time.UTC)
+	at := func(day, hour int) time.Time { return time.Date(2015, 11, day, hour, 0, 0, 0, time.UTC) }
+	deploys := []Deploy{
+		{Project: "web", Environment: "staging", Success: true, Time: at(2, 12), Duration: 2 * time.Minute},
+		{Project: "web", Environment: "staging", Success: false, Time: at(2, 10), Duration: 4 * time.Minute},
+		{Project: "web", Environment: "production", Success: true, Time: at(3, 1)},
+		{Project: "api", Environment: "production", Success: true, Time: at(3, 23), Duration: time.Minute},
+		// out of the range
+		{Project: "web", Environment: "staging", Success: true, Time: at(2, 9)},
+		{Project: "web", Environment: "staging", Success: true, Time: at(4, 0)},
+	}
+	tl, err := NewTimeline(deploys, start, end, 24*time.Hour)
+	if err != nil {
+		t.Fatalf("NewTimeline(deploys, %v, %v, 24h) failed with %v; want success", start, end, err)
+	}
+	if want := []time.Time{at(2, 0), at(3, 0)}; !reflect.DeepEqual(tl.Buckets, want) {
+		t.Errorf("tl.Buckets = %v; want %v", tl.Buckets, want)
+	}
+	if tl.BucketSeconds != 86400 {
+		t.Errorf("tl.BucketSeconds = %d; want 86400", tl.BucketSeconds)
+	}
+
+	var names []string
+	for _, s := range tl.Series {
+		names = append(names, s.Project+"/"+s.Environment)
+	}
+	if want := []string{"api/production", "web/production", "web/staging"}; !reflect.DeepEqual(names, want) {
+		t.Fatalf("series = %q; want %q", names, want)
+	}
+	staging := tl.Series[2]
+	if want := []int{1, 0}; !reflect.DeepEqual(staging.Successes, want) {
+		t.Errorf("staging.Successes = %v; want %v", staging.Successes, want)
+	}
+	if want := []int{1, 0}; !reflect.DeepEqual(staging.Failures, want) {
+		t.Errorf("staging.Failures = %v; want %v", staging.Failures, want)
+	}
+	if avg := staging.AvgDurationSeconds; avg[0] == nil || *avg[0] != 180 || avg[1] != nil {
+		t.Errorf("staging.AvgDurationSeconds = %v; want [180 nil]", avg)
+	}
+	if avg := tl.Series[1].AvgDurationSeconds; avg[1] != nil {
+		t.Errorf("average duration of deployments without durations = %v; want nil", *avg[1])
+	}
+
+	if len(tl.Deploys) != 4 || !tl.Deploys[0].Start.Equal(at(2, 10)) || !tl.Deploys[0].End.Equal(at(2, 10).Add(4*time.Minute)) {
+		t.Errorf("tl.Deploys = %#v; want the 4 deployments in the range in order", tl.Deploys)
+	}
+
+	ds := tl.Datapoints()
+	if len(ds) != 9 || ds[0].Target != "api/production successes" {
+		t.Fatalf("tl.Datapoints() = %#v; want 3 series per environment", ds)
+	}
+	ms := float64(at(3, 0).Unix() * 1000)
+	if got, want := ds[0].Datapoints, [][2]float64{{0, ms - 86400000}, {1, ms}}; !reflect.DeepEqual(got, want) {
+		t.Errorf("datapoints of %s = %v; want %v", ds[0].Target, got, want)
+	}
+	if got, want := ds[2].Datapoints, [][2]float64{{60, ms}}; !reflect.DeepEqual(got, want) {
+		t.Errorf("datapoints of %s = %v; want %v", ds[2].Target, got, want)
+	}
+
+	for _, spec := range []struct {
+		start, end time.Time
+		bucket     time.Duration
+	}{
+		{start: end, end: start, bucket: time.Hour},
+		{start: start, end: end, bucket: time.Second},
+		{start: start, end: start.AddDate(1, 0, 0), bucket: time.Minute},
+	} {
+		if _, err := NewTimeline(nil, spec.start, spec.end, spec.bucket); err == nil {
+			t.Errorf("NewTimeline(nil, %v, %v, %s) succeeded; want failure", spec.start, spec.end, spec.bucket)
+		}
+	}
+}
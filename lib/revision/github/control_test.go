@@ -5,10 +5,11 @@ import (
 
 	"github.com/gengo/goship/lib/config"
 	"github.com/gengo/goship/lib/revision"
+	"github.com/gengo/goship/lib/ssh"
 )
 
 func TestSourceDiffURL(t *testing.T) {
-	var ctl control
+	ctl := New(nil, ssh.SSH{})
 	for _, tt := range []struct {
 		p        config.Project
 		from, to revision.Revision
@@ -0,0 +1,82 @@
+package scm
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/gengo/goship/lib/config"
+	"github.com/gengo/goship/lib/revision"
+	scmlib "github.com/gengo/goship/lib/scm"
+	"github.com/gengo/goship/lib/ssh"
+	"github.com/golang/glog"
+	"golang.org/x/net/context"
+)
+
+type control struct {
+	scm scmlib.Client
+	ssh ssh.SSH
+}
+
+// New returns a new git-based implementation of revision.Control which reads source repositories through "scm".
+func New(scm scmlib.Client, ssh ssh.SSH) revision.Control {
+	return control{scm: scm, ssh: ssh}
+}
+
+// Latest returns the latest commit in the given reference.
+func (c control) Latest(ctx context.Context, proj config.Project, env config.Environment) (rev, srcRev revision.Revision, err error) {
+	rev, err = c.scm.Latest(ctx, proj.Repo, env.Branch)
+	if err != nil {
+		glog.Errorf("Failed to get the latest commit in %s of %s/%s: %v", env.Branch, proj.RepoOwner, proj.RepoName, err)
+		return "", "", err
+	}
+	return rev, rev, nil
+}
+
+// LatestDeployed returns the latest commit deployed into the host.
+func (c control) LatestDeployed(ctx context.Context, hostname string, proj config.Project, env config.Environment) (rev, srcRev revision.Revision, err error) {
+	cmd := fmt.Sprintf("git --git-dir=%s rev-parse HEAD", env.RepoPath)
+	switch {
+	case env.IsK8sDeployment():
+		containers := "*"
+		if env.K8sContainer != "" {
+			containers = fmt.Sprintf("?(@.name==%q)", env.K8sContainer)
+		}
+		cmd = fmt.Sprintf("kubectl get deployment %s --namespace=%s -o jsonpath='{.spec.template.spec.containers[%s].image}'", env.K8sDeployment, env.K8sNamespace, containers)
+	case proj.HostType == config.HostTypeK8s:
+		cmd = fmt.Sprintf("kubectl get %s -L git_version --no-headers -l name=%s --namespace=%s | awk '{printf $NF}'", proj.K8sResource, proj.K8sSelector, env.K8sNamespace)
+	}
+	buf, err := c.ssh.Output(ctx, hostname, cmd)
+	if err != nil {
+		glog.Errorf("Failed to get latest deployed commit from %s:%s : %v", hostname, env.RepoPath, err)
+		return "", "", err
+	}
+	rev = revision.Revision(strings.TrimSpace(string(buf)))
+	if env.IsK8sDeployment() {
+		rev = imageTag(string(rev))
+	}
+	return rev, rev, nil
+}
+
+// imageTag returns the tag of the first image in a space-separated list of images.
+func imageTag(images string) revision.Revision {
+	image := strings.SplitN(images, " ", 2)[0]
+	if i := strings.LastIndex(image, ":"); i > strings.LastIndex(image, "/") {
+		return revision.Revision(image[i+1:])
+	}
+	return ""
+}
+
+func (c control) RevisionURL(p config.Project, rev revision.Revision) string {
+	return c.scm.CommitURL(p.Repo, rev)
+}
+
+func (c control) SourceDiffURL(p config.Project, from, to revision.Revision) string {
+	if from == to {
+		return ""
+	}
+	return c.scm.CompareURL(p.SourceRepo(), from, to)
+}
+
+func (c control) SourceRevMessage(ctx context.Context, p config.Project, rev revision.Revision) (string, error) {
+	return c.scm.Message(ctx, p.SourceRepo(), rev)
+}
@@ -0,0 +1,23 @@
+package scm
+
+import (
+	"testing"
+
+	"github.com/gengo/goship/lib/revision"
+)
+
+func TestImageTag(t *testing.T) {
+	for _, spec := range []struct {
+		images string
+		want   revision.Revision
+	}{
+		{images: "gcr.io/example/app:abc123", want: "abc123"},
+		{images: "localhost:5000/app:abc123 gcr.io/example/sidecar:v1", want: "abc123"},
+		{images: "localhost:5000/app", want: ""},
+		{images: "", want: ""},
+	} {
+		if got := imageTag(spec.images); got != spec.want {
+			t.Errorf("imageTag(%q) = %q; want %q", spec.images, got, spec.want)
+		}
+	}
+}
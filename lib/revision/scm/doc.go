@@ -0,0 +1,7 @@
+/*
+Package scm provides an implementation of revision.Control on top of source code management services.
+
+It assumes that a target of deployment uniquely corresponds to a source repository,
+and that the deployed revision can be read from a git repository or a k8s resource in the deploy target hosts.
+*/
+package scm
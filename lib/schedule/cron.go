@@ -0,0 +1,111 @@
+// Package schedule runs recurring actions in environments.
+package schedule
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Cron is a parsed cron expression.
+type Cron struct {
+	minute, hour, dom, month, dow uint64
+	// domStar and dowStar are true if the day of month or the day of week is "*".
+	domStar, dowStar bool
+}
+
+var aliases = map[string]string{
+	"@hourly":  "0 * * * *",
+	"@daily":   "0 0 * * *",
+	"@nightly": "0 0 * * *",
+	"@weekly":  "0 0 * * 0",
+	"@monthly": "0 0 1 * *",
+}
+
+// Parse parses a standard cron expression with five fields: minute, hour, day of month, month and day of week.
+// Each field accepts "*", numbers, ranges like "1-5", steps like "*/15" and lists of them separated by commas.
+// It also accepts aliases like "@daily" and "@nightly".
+func Parse(expr string) (Cron, error) {
+	if a, ok := aliases[expr]; ok {
+		expr = a
+	}
+	fields := strings.Fields(expr)
+	if len(fields) != 5 {
+		return Cron{}, fmt.Errorf("cron expression %q must have 5 fields", expr)
+	}
+	var c Cron
+	for _, spec := range []struct {
+		name     string
+		bits     *uint64
+		min, max int
+	}{
+		{name: "minute", bits: &c.minute, min: 0, max: 59},
+		{name: "hour", bits: &c.hour, min: 0, max: 23},
+		{name: "day of month", bits: &c.dom, min: 1, max: 31},
+		{name: "month", bits: &c.month, min: 1, max: 12},
+		{name: "day of week", bits: &c.dow, min: 0, max: 7},
+	} {
+		var err error
+		if *spec.bits, err = parseField(fields[0], spec.min, spec.max); err != nil {
+			return Cron{}, fmt.Errorf("invalid %s in %q: %v", spec.name, expr, err)
+		}
+		fields = fields[1:]
+	}
+	// Both 0 and 7 mean Sunday.
+	if c.dow&(1<<7) != 0 {
+		c.dow |= 1
+	}
+	f := strings.Fields(expr)
+	c.domStar, c.dowStar = f[2] == "*", f[4] == "*"
+	return c, nil
+}
+
+func parseField(field string, min, max int) (uint64, error) {
+	var bits uint64
+	for _, item := range strings.Split(field, ",") {
+		rng, step := item, 1
+		if i := strings.Index(item, "/"); i >= 0 {
+			var err error
+			if step, err = strconv.Atoi(item[i+1:]); err != nil || step <= 0 {
+				return 0, fmt.Errorf("invalid step %q", item[i+1:])
+			}
+			rng = item[:i]
+		}
+		lo, hi := min, max
+		if rng != "*" {
+			bounds := strings.SplitN(rng, "-", 2)
+			var err error
+			if lo, err = strconv.Atoi(bounds[0]); err != nil {
+				return 0, fmt.Errorf("invalid value %q", bounds[0])
+			}
+			hi = lo
+			if len(bounds) == 2 {
+				if hi, err = strconv.Atoi(bounds[1]); err != nil {
+					return 0, fmt.Errorf("invalid value %q", bounds[1])
+				}
+			}
+		}
+		if lo < min || hi > max || lo > hi {
+			return 0, fmt.Errorf("%q out of range %d-%d", rng, min, max)
+		}
+		for v := lo; v <= hi; v += step {
+			bits |= 1 << uint(v)
+		}
+	}
+	return bits, nil
+}
+
+// Matches returns true if the action should run in the minute of "t".
+func (c Cron) Matches(t time.Time) bool {
+	if c.minute&(1<<uint(t.Minute())) == 0 || c.hour&(1<<uint(t.Hour())) == 0 || c.month&(1<<uint(t.Month())) == 0 {
+		return false
+	}
+	dom := c.dom&(1<<uint(t.Day())) != 0
+	dow := c.dow&(1<<uint(t.Weekday())) != 0
+	// Same as the traditional cron, the day matches either of the fields if both are restricted.
+	if !c.domStar && !c.dowStar {
+		return dom || dow
+	}
+	return dom && dow
+}
@@ -0,0 +1,61 @@
+package schedule
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCronMatches(t *testing.T) {
+	// 2015-11-10 is a Tuesday.
+	at := func(day, hour, min int) time.Time {
+		return time.Date(2015, time.November, day, hour, min, 0, 0, time.UTC)
+	}
+	for _, spec := range []struct {
+		expr  string
+		t     time.Time
+		match bool
+	}{
+		{expr: "* * * * *", t: at(10, 12, 34), match: true},
+		{expr: "0 3 * * *", t: at(10, 3, 0), match: true},
+		{expr: "0 3 * * *", t: at(10, 3, 1), match: false},
+		{expr: "@nightly", t: at(10, 0, 0), match: true},
+		{expr: "*/15 * * * *", t: at(10, 1, 45), match: true},
+		{expr: "*/15 * * * *", t: at(10, 1, 50), match: false},
+		{expr: "0 9-17/2 * * *", t: at(10, 11, 0), match: true},
+		{expr: "0 9-17/2 * * *", t: at(10, 12, 0), match: false},
+		{expr: "0 0 * * 1-5", t: at(10, 0, 0), match: true},
+		{expr: "0 0 * * 0,6", t: at(10, 0, 0), match: false},
+		{expr: "0 0 * * 7", t: at(15, 0, 0), match: true},
+		{expr: "0 0 1 * 2", t: at(10, 0, 0), match: true},
+		{expr: "0 0 1 * 3", t: at(10, 0, 0), match: false},
+		{expr: "0 0 10 12 *", t: at(10, 0, 0), match: false},
+	} {
+		c, err := Parse(spec.expr)
+		if err != nil {
+			t.Errorf("Parse(%q) failed with %v; want success", spec.expr, err)
+			continue
+		}
+		if got := c.Matches(spec.t); got != spec.match {
+			t.Errorf("Parse(%q).Matches(%v) = %v; want %v", spec.expr, spec.t, got, spec.match)
+		}
+	}
+}
+
+func TestParseError(t *testing.T) {
+	for _, expr := range []string{
+		"",
+		"* * * *",
+		"60 * * * *",
+		"* 24 * * *",
+		"* * 0 * *",
+		"* * * 13 *",
+		"* * * * 8",
+		"5-1 * * * *",
+		"*/0 * * * *",
+		"a * * * *",
+	} {
+		if _, err := Parse(expr); err == nil {
+			t.Errorf("Parse(%q) succeeded; want failure", expr)
+		}
+	}
+}
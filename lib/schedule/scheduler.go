@@ -0,0 +1,74 @@
+package schedule
+
+import (
+	"time"
+
+	"github.com/gengo/goship/lib/clock"
+	"github.com/gengo/goship/lib/config"
+	"github.com/golang/glog"
+	"golang.org/x/net/context"
+)
+
+// Runner runs the action of "s" in "env" of "proj".
+type Runner func(ctx context.Context, proj config.Project, env config.Environment, s config.Schedule)
+
+// Run runs the schedules stored in "ecl" at the beginning of every minute of "clk" until "ctx" is done.
+func Run(ctx context.Context, ecl config.ETCDInterface, clk clock.Clock, run Runner) {
+	t := clk.Now().Truncate(time.Minute)
+	for {
+		t = t.Add(time.Minute)
+		select {
+		case <-ctx.Done():
+			return
+		case <-clk.After(t.Sub(clk.Now())):
+		}
+		if err := Tick(ctx, ecl, t, run); err != nil {
+			glog.Errorf("Failed to run schedules at %v: %v", t, err)
+		}
+	}
+}
+
+// Tick runs the schedules which are due in the minute of "t".
+// One-off schedules are removed once they are due.
+// Actions run in their own goroutines so that long actions do not delay others.
+func Tick(ctx context.Context, ecl config.ETCDInterface, t time.Time, run Runner) error {
+	c, err := config.Load(ecl)
+	if err != nil {
+		return err
+	}
+	for _, proj := range c.Projects {
+		for _, env := range proj.Environments {
+			schedules, err := config.LoadSchedules(ecl, proj.Name, env.Name)
+			if err != nil {
+				glog.Errorf("Failed to load schedules of %s-%s: %v", proj.Name, env.Name, err)
+				continue
+			}
+			for _, s := range schedules {
+				if s.OneOff() {
+					if s.At.After(t) {
+						continue
+					}
+					// Removed before running so that the action runs only once.
+					if err := config.RemoveSchedule(ecl, proj.Name, env.Name, s.ID); err != nil {
+						glog.Errorf("Failed to remove one-off schedule %s of %s-%s: %v", s.ID, proj.Name, env.Name, err)
+						continue
+					}
+					glog.Infof("Running scheduled %s of %s-%s (at %v)", s.Action, proj.Name, env.Name, s.At)
+					go run(ctx, proj, env, s)
+					continue
+				}
+				cron, err := Parse(s.Cron)
+				if err != nil {
+					glog.Errorf("Invalid schedule %s of %s-%s: %v", s.ID, proj.Name, env.Name, err)
+					continue
+				}
+				if !cron.Matches(t) {
+					continue
+				}
+				glog.Infof("Running scheduled %s of %s-%s (%s)", s.Action, proj.Name, env.Name, s.Cron)
+				go run(ctx, proj, env, s)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,143 @@
+package schedule
+
+import (
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gengo/goship/lib/clock"
+	"github.com/gengo/goship/lib/config"
+	"golang.org/x/net/context"
+)
+
+func TestTick(t *testing.T) {
+	s := config.NewMemoryStore()
+	cfg := config.Config{
+		Projects: []config.Project{
+			{
+				Name: "proj",
+				Environments: []config.Environment{
+					{Name: "production"},
+					{Name: "staging"},
+				},
+			},
+		},
+	}
+	if err := config.Store(s, cfg); err != nil {
+		t.Fatalf("config.Store(s, %#v) failed with %v; want success", cfg, err)
+	}
+	for env, sc := range map[string]config.Schedule{
+		"production": {Cron: "0 3 * * *", Action: config.ScheduleRestart},
+		"staging":    {Cron: "0 4 * * *", Action: config.ScheduleRedeploy},
+	} {
+		if _, err := config.AddSchedule(s, "proj", env, sc); err != nil {
+			t.Fatalf("config.AddSchedule(s, %q, %q, %#v) failed with %v; want success", "proj", env, sc, err)
+		}
+	}
+
+	var (
+		mu  sync.Mutex
+		wg  sync.WaitGroup
+		ran []string
+	)
+	run := func(ctx context.Context, proj config.Project, env config.Environment, sc config.Schedule) {
+		defer wg.Done()
+		mu.Lock()
+		defer mu.Unlock()
+		ran = append(ran, env.Name+":"+string(sc.Action))
+	}
+	wg.Add(1)
+	now := time.Date(2015, time.November, 10, 3, 0, 0, 0, time.UTC)
+	if err := Tick(context.Background(), s, now, run); err != nil {
+		t.Fatalf("Tick(ctx, s, %v, run) failed with %v; want success", now, err)
+	}
+	wg.Wait()
+	if got, want := ran, []string{"production:restart"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("actions run by Tick(ctx, s, %v, run) = %q; want %q", now, got, want)
+	}
+}
+
+func TestTickOneOff(t *testing.T) {
+	s := config.NewMemoryStore()
+	cfg := config.Config{
+		Projects: []config.Project{
+			{Name: "proj", Environments: []config.Environment{{Name: "production"}}},
+		},
+	}
+	if err := config.Store(s, cfg); err != nil {
+		t.Fatalf("config.Store(s, %#v) failed with %v; want success", cfg, err)
+	}
+	at := time.Date(2015, time.November, 10, 3, 0, 0, 0, time.UTC)
+	sc := config.Schedule{Action: config.ScheduleDeploy, At: at, Revision: "abc123"}
+	if _, err := config.AddSchedule(s, "proj", "production", sc); err != nil {
+		t.Fatalf("config.AddSchedule(s, %q, %q, %#v) failed with %v; want success", "proj", "production", sc, err)
+	}
+
+	ran := make(chan config.Schedule, 2)
+	run := func(ctx context.Context, proj config.Project, env config.Environment, sc config.Schedule) {
+		ran <- sc
+	}
+	for _, now := range []time.Time{at.Add(-time.Minute), at, at.Add(time.Minute)} {
+		if err := Tick(context.Background(), s, now, run); err != nil {
+			t.Fatalf("Tick(ctx, s, %v, run) failed with %v; want success", now, err)
+		}
+	}
+	if got := (<-ran).Revision; got != "abc123" {
+		t.Errorf("revision of the scheduled deploy = %q; want %q", got, "abc123")
+	}
+	select {
+	case sc := <-ran:
+		t.Errorf("Tick(ctx, s, t, run) ran %#v again; want only once", sc)
+	case <-time.After(10 * time.Millisecond):
+	}
+	if got, err := config.LoadSchedules(s, "proj", "production"); err != nil || len(got) != 0 {
+		t.Errorf("config.LoadSchedules(s, %q, %q) = %#v, %v; want no schedules", "proj", "production", got, err)
+	}
+}
+
+func TestRun(t *testing.T) {
+	s := config.NewMemoryStore()
+	cfg := config.Config{
+		Projects: []config.Project{
+			{Name: "proj", Environments: []config.Environment{{Name: "production"}}},
+		},
+	}
+	if err := config.Store(s, cfg); err != nil {
+		t.Fatalf("config.Store(s, %#v) failed with %v; want success", cfg, err)
+	}
+	sc := config.Schedule{Cron: "0 3 * * *", Action: config.ScheduleRestart}
+	if _, err := config.AddSchedule(s, "proj", "production", sc); err != nil {
+		t.Fatalf("config.AddSchedule(s, %q, %q, %#v) failed with %v; want success", "proj", "production", sc, err)
+	}
+
+	clk := clock.NewFake(time.Date(2015, time.November, 10, 2, 58, 30, 0, time.UTC))
+	ran := make(chan config.Schedule, 1)
+	run := func(ctx context.Context, proj config.Project, env config.Environment, sc config.Schedule) {
+		ran <- sc
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go Run(ctx, s, clk, run)
+
+	// advance passes 02:59 and then 03:00 once Run waits for the next minute
+	for i := 0; i < 2; i++ {
+		for clk.Waiters() == 0 {
+			time.Sleep(time.Millisecond)
+		}
+		select {
+		case sc := <-ran:
+			t.Fatalf("Run ran %#v at %v; want at 03:00", sc, clk.Now())
+		default:
+		}
+		clk.Advance(time.Minute)
+	}
+	select {
+	case got := <-ran:
+		if got.Action != config.ScheduleRestart {
+			t.Errorf("action run at 03:00 = %q; want %q", got.Action, config.ScheduleRestart)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("Run did not run the schedule at 03:00")
+	}
+}
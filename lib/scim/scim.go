@@ -0,0 +1,732 @@
+// Package scim provisions users and groups of Goship by the identity provider with SCIM 2.0 (RFC 7643 and RFC 7644).
+//
+// Users are identified by their names in Goship. Deprovisioning a user deactivates the user,
+// and the display names of the groups are the groups of their members in access control.
+package scim
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gengo/goship/lib/audit"
+	"github.com/gengo/goship/lib/config"
+	"github.com/gengo/goship/lib/users"
+	"github.com/golang/glog"
+)
+
+// Schemas of resources and messages.
+const (
+	UserSchema         = "urn:ietf:params:scim:schemas:core:2.0:User"
+	GroupSchema        = "urn:ietf:params:scim:schemas:core:2.0:Group"
+	ListResponseSchema = "urn:ietf:params:scim:api:messages:2.0:ListResponse"
+	PatchOpSchema      = "urn:ietf:params:scim:api:messages:2.0:PatchOp"
+	ErrorSchema        = "urn:ietf:params:scim:api:messages:2.0:Error"
+)
+
+// Actor is the name of the identity provider in the audit log and in deactivations.
+const Actor = "scim"
+
+// Meta is the metadata of a resource.
+type Meta struct {
+	ResourceType string    `json:"resourceType"`
+	Created      time.Time `json:"created"`
+	LastModified time.Time `json:"lastModified"`
+}
+
+// Ref refers to a user in a group, or to a group of a user.
+type Ref struct {
+	Value   string `json:"value"`
+	Display string `json:"display,omitempty"`
+}
+
+// User is the SCIM representation of a user.
+type User struct {
+	Schemas     []string `json:"schemas"`
+	ID          string   `json:"id,omitempty"`
+	ExternalID  string   `json:"externalId,omitempty"`
+	UserName    string   `json:"userName"`
+	DisplayName string   `json:"displayName,omitempty"`
+	// Active is true unless the user is deactivated. Nil in requests means true.
+	Active *bool `json:"active,omitempty"`
+	// Groups are read-only; memberships are changed through groups.
+	Groups []Ref `json:"groups,omitempty"`
+	Meta   *Meta `json:"meta,omitempty"`
+}
+
+// Group is the SCIM representation of a group.
+type Group struct {
+	Schemas     []string `json:"schemas"`
+	ID          string   `json:"id,omitempty"`
+	ExternalID  string   `json:"externalId,omitempty"`
+	DisplayName string   `json:"displayName"`
+	Members     []Ref    `json:"members"`
+	Meta        *Meta    `json:"meta,omitempty"`
+}
+
+// ListResponse is a page of the resources which match a query.
+type ListResponse struct {
+	Schemas      []string    `json:"schemas"`
+	TotalResults int         `json:"totalResults"`
+	StartIndex   int         `json:"startIndex"`
+	ItemsPerPage int         `json:"itemsPerPage"`
+	Resources    interface{} `json:"Resources"`
+}
+
+// PatchOp is a request to modify a resource partially.
+type PatchOp struct {
+	Schemas    []string    `json:"schemas"`
+	Operations []Operation `json:"Operations"`
+}
+
+// Operation is an operation in PatchOp.
+type Operation struct {
+	// Op is "add", "remove" or "replace", in any case.
+	Op    string          `json:"op"`
+	Path  string          `json:"path,omitempty"`
+	Value json.RawMessage `json:"value,omitempty"`
+}
+
+// Error is an error with the HTTP status to respond with.
+type Error struct {
+	Status int
+	// ScimType is the detail error keyword defined in RFC 7644, if any.
+	ScimType string
+	Detail   string
+}
+
+func (e *Error) Error() string {
+	return e.Detail
+}
+
+// MarshalJSON encodes "e" as a SCIM error message, whose status is a string.
+func (e *Error) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Schemas  []string `json:"schemas"`
+		Status   string   `json:"status"`
+		ScimType string   `json:"scimType,omitempty"`
+		Detail   string   `json:"detail"`
+	}{[]string{ErrorSchema}, fmt.Sprint(e.Status), e.ScimType, e.Detail})
+}
+
+func badRequest(scimType, format string, args ...interface{}) *Error {
+	return &Error{Status: http.StatusBadRequest, ScimType: scimType, Detail: fmt.Sprintf(format, args...)}
+}
+
+func notFound(kind, id string) *Error {
+	return &Error{Status: http.StatusNotFound, Detail: fmt.Sprintf("%s %q not found", kind, id)}
+}
+
+// Filter selects resources whose Attribute equals Value.
+// Only the "eq" operator is supported, which identity providers use to find existing resources.
+type Filter struct {
+	Attribute string
+	Value     string
+}
+
+var filterPattern = regexp.MustCompile(`(?i)\A\s*(\w+)\s+eq\s+"((?:[^"\\]|\\.)*)"\s*\z`)
+
+// ParseFilter parses "s" of the form `attribute eq "value"`. An empty "s" matches all the resources.
+func ParseFilter(s string) (Filter, error) {
+	if strings.TrimSpace(s) == "" {
+		return Filter{}, nil
+	}
+	m := filterPattern.FindStringSubmatch(s)
+	if m == nil {
+		return Filter{}, badRequest("invalidFilter", "unsupported filter: %s", s)
+	}
+	var v string
+	if err := json.Unmarshal([]byte(`"`+m[2]+`"`), &v); err != nil {
+		return Filter{}, badRequest("invalidFilter", "invalid value in filter %s: %v", s, err)
+	}
+	return Filter{Attribute: m[1], Value: v}, nil
+}
+
+// match returns true if "f" matches the resource with "attrs", which maps attribute names to their values.
+func (f Filter) match(attrs map[string]string) bool {
+	if f.Attribute == "" {
+		return true
+	}
+	for k, v := range attrs {
+		if strings.EqualFold(k, f.Attribute) {
+			return v == f.Value
+		}
+	}
+	return false
+}
+
+// Provisioner applies requests from the identity provider to Goship.
+type Provisioner struct {
+	ecl config.EditableStore
+	c   config.Config
+}
+
+// New returns a new Provisioner which stores users and groups into "ecl".
+// "c" must have SCIM configured.
+func New(ecl config.EditableStore, c config.Config) Provisioner {
+	return Provisioner{ecl: ecl, c: c}
+}
+
+// Users returns the provisioned users which match "f".
+func (p Provisioner) Users(f Filter) ([]User, error) {
+	stored, err := config.LoadSCIMUsers(p.ecl)
+	if err != nil {
+		return nil, err
+	}
+	groups, err := config.LoadSCIMGroups(p.ecl)
+	if err != nil {
+		return nil, err
+	}
+	resources := []User{}
+	for _, u := range stored {
+		if !f.match(map[string]string{"id": u.UserName, "userName": u.UserName, "externalId": u.ExternalID, "displayName": u.DisplayName}) {
+			continue
+		}
+		res, err := p.userResource(u, groups)
+		if err != nil {
+			return nil, err
+		}
+		resources = append(resources, res)
+	}
+	return resources, nil
+}
+
+// User returns the provisioned user whose ID is "id".
+func (p Provisioner) User(id string) (User, error) {
+	u, err := p.loadUser(id)
+	if err != nil {
+		return User{}, err
+	}
+	groups, err := config.LoadSCIMGroups(p.ecl)
+	if err != nil {
+		return User{}, err
+	}
+	return p.userResource(*u, groups)
+}
+
+func (p Provisioner) loadUser(id string) (*config.SCIMUser, error) {
+	u, err := config.LoadSCIMUser(p.ecl, id)
+	if err != nil {
+		return nil, err
+	}
+	if u == nil {
+		return nil, notFound("user", id)
+	}
+	return u, nil
+}
+
+func (p Provisioner) userResource(u config.SCIMUser, groups []config.SCIMGroup) (User, error) {
+	d, err := config.LoadDeactivation(p.ecl, u.UserName)
+	if err != nil {
+		return User{}, err
+	}
+	active := d == nil || d.Active()
+	res := User{
+		Schemas:     []string{UserSchema},
+		ID:          u.UserName,
+		ExternalID:  u.ExternalID,
+		UserName:    u.UserName,
+		DisplayName: u.DisplayName,
+		Active:      &active,
+		Meta:        &Meta{ResourceType: "User", Created: u.Created, LastModified: u.Modified},
+	}
+	for _, g := range groups {
+		if contains(g.Members, u.UserName) {
+			res.Groups = append(res.Groups, Ref{Value: g.ID, Display: g.DisplayName})
+		}
+	}
+	return res, nil
+}
+
+// CreateUser provisions the user "u". Its name must not be provisioned yet.
+func (p Provisioner) CreateUser(u User) (User, error) {
+	if err := validUserName(u.UserName); err != nil {
+		return User{}, err
+	}
+	prev, err := config.LoadSCIMUser(p.ecl, u.UserName)
+	if err != nil {
+		return User{}, err
+	}
+	if prev != nil {
+		return User{}, &Error{Status: http.StatusConflict, ScimType: "uniqueness", Detail: fmt.Sprintf("user %q already exists", u.UserName)}
+	}
+	now := time.Now()
+	stored := config.SCIMUser{UserName: u.UserName, ExternalID: u.ExternalID, DisplayName: u.DisplayName, Created: now, Modified: now}
+	if err := p.storeUser(stored, u.Active, "created"); err != nil {
+		return User{}, err
+	}
+	return p.User(u.UserName)
+}
+
+// ReplaceUser replaces the attributes of the user whose ID is "id" with "u".
+// The name of a user cannot be changed because it identifies the user in Goship.
+func (p Provisioner) ReplaceUser(id string, u User) (User, error) {
+	stored, err := p.loadUser(id)
+	if err != nil {
+		return User{}, err
+	}
+	if u.UserName != "" && u.UserName != stored.UserName {
+		return User{}, badRequest("mutability", "userName of %q cannot be changed to %q", stored.UserName, u.UserName)
+	}
+	stored.ExternalID, stored.DisplayName, stored.Modified = u.ExternalID, u.DisplayName, time.Now()
+	if err := p.storeUser(*stored, u.Active, "changed"); err != nil {
+		return User{}, err
+	}
+	return p.User(id)
+}
+
+// PatchUser applies "ops" to the user whose ID is "id".
+func (p Provisioner) PatchUser(id string, ops []Operation) (User, error) {
+	u, err := p.User(id)
+	if err != nil {
+		return User{}, err
+	}
+	for _, op := range ops {
+		if err := patchUser(&u, op); err != nil {
+			return User{}, err
+		}
+	}
+	return p.ReplaceUser(id, u)
+}
+
+// DeleteUser deprovisions the user whose ID is "id".
+// The user is deactivated and removed from all the groups.
+func (p Provisioner) DeleteUser(id string) error {
+	u, err := p.loadUser(id)
+	if err != nil {
+		return err
+	}
+	if err := p.setActive(u.UserName, false); err != nil {
+		return err
+	}
+	groups, err := config.LoadSCIMGroups(p.ecl)
+	if err != nil {
+		return err
+	}
+	for _, g := range groups {
+		if !contains(g.Members, u.UserName) {
+			continue
+		}
+		g.Members, g.Modified = remove(g.Members, u.UserName), time.Now()
+		if err := config.StoreSCIMGroup(p.ecl, g); err != nil {
+			return err
+		}
+	}
+	if err := config.DeleteSCIMUser(p.ecl, u.UserName); err != nil {
+		return err
+	}
+	p.record(audit.ActionUserProvisioned, "deleted user %s", u.UserName)
+	return p.sync(u.UserName)
+}
+
+// storeUser stores "u" and deactivates or reactivates the user following "active".
+func (p Provisioner) storeUser(u config.SCIMUser, active *bool, verb string) error {
+	if err := config.StoreSCIMUser(p.ecl, u); err != nil {
+		return err
+	}
+	p.record(audit.ActionUserProvisioned, "%s user %s", verb, u.UserName)
+	return p.setActive(u.UserName, active == nil || *active)
+}
+
+// setActive deactivates or reactivates "user" unless the user is already in the state.
+// The schedules of deactivated users are reassigned to the user configured for SCIM.
+func (p Provisioner) setActive(user string, active bool) error {
+	d, err := config.LoadDeactivation(p.ecl, user)
+	if err != nil {
+		return err
+	}
+	switch deactivated := d != nil && !d.Active(); {
+	case active && deactivated:
+		_, err = users.Reactivate(p.ecl, user, Actor)
+	case !active && !deactivated:
+		_, err = users.Deactivate(p.ecl, p.c, user, Actor, "deprovisioned by the identity provider", p.c.SCIM.ReassignTo)
+	}
+	return err
+}
+
+// Groups returns the provisioned groups which match "f".
+func (p Provisioner) Groups(f Filter) ([]Group, error) {
+	stored, err := config.LoadSCIMGroups(p.ecl)
+	if err != nil {
+		return nil, err
+	}
+	resources := []Group{}
+	for _, g := range stored {
+		if f.match(map[string]string{"id": g.ID, "displayName": g.DisplayName, "externalId": g.ExternalID}) {
+			resources = append(resources, groupResource(g))
+		}
+	}
+	return resources, nil
+}
+
+// Group returns the provisioned group whose ID is "id".
+func (p Provisioner) Group(id string) (Group, error) {
+	g, err := p.loadGroup(id)
+	if err != nil {
+		return Group{}, err
+	}
+	return groupResource(*g), nil
+}
+
+func (p Provisioner) loadGroup(id string) (*config.SCIMGroup, error) {
+	g, err := config.LoadSCIMGroup(p.ecl, id)
+	if err != nil {
+		return nil, err
+	}
+	if g == nil {
+		return nil, notFound("group", id)
+	}
+	return g, nil
+}
+
+func groupResource(g config.SCIMGroup) Group {
+	res := Group{
+		Schemas:     []string{GroupSchema},
+		ID:          g.ID,
+		ExternalID:  g.ExternalID,
+		DisplayName: g.DisplayName,
+		Members:     []Ref{},
+		Meta:        &Meta{ResourceType: "Group", Created: g.Created, LastModified: g.Modified},
+	}
+	for _, m := range g.Members {
+		res.Members = append(res.Members, Ref{Value: m, Display: m})
+	}
+	return res
+}
+
+// CreateGroup provisions the group "g". Its display name must be unique.
+func (p Provisioner) CreateGroup(g Group) (Group, error) {
+	id, err := newID()
+	if err != nil {
+		return Group{}, err
+	}
+	now := time.Now()
+	stored := config.SCIMGroup{ID: id, Created: now}
+	if err := p.storeGroup(stored, g, "created"); err != nil {
+		return Group{}, err
+	}
+	return p.Group(id)
+}
+
+// ReplaceGroup replaces the attributes and the members of the group whose ID is "id" with "g".
+func (p Provisioner) ReplaceGroup(id string, g Group) (Group, error) {
+	stored, err := p.loadGroup(id)
+	if err != nil {
+		return Group{}, err
+	}
+	if err := p.storeGroup(*stored, g, "changed"); err != nil {
+		return Group{}, err
+	}
+	return p.Group(id)
+}
+
+// PatchGroup applies "ops" to the group whose ID is "id".
+func (p Provisioner) PatchGroup(id string, ops []Operation) (Group, error) {
+	g, err := p.Group(id)
+	if err != nil {
+		return Group{}, err
+	}
+	for _, op := range ops {
+		if err := patchGroup(&g, op); err != nil {
+			return Group{}, err
+		}
+	}
+	return p.ReplaceGroup(id, g)
+}
+
+// DeleteGroup deletes the group whose ID is "id". Its members lose the group.
+func (p Provisioner) DeleteGroup(id string) error {
+	g, err := p.loadGroup(id)
+	if err != nil {
+		return err
+	}
+	if err := config.DeleteSCIMGroup(p.ecl, id); err != nil {
+		return err
+	}
+	p.record(audit.ActionGroupProvisioned, "deleted group %s", g.DisplayName)
+	return p.sync(g.Members...)
+}
+
+// storeGroup replaces "stored" with the attributes and the members of "g", and updates the groups of the affected users.
+func (p Provisioner) storeGroup(stored config.SCIMGroup, g Group, verb string) error {
+	if g.DisplayName == "" {
+		return badRequest("invalidValue", "displayName not specified")
+	}
+	groups, err := config.LoadSCIMGroups(p.ecl)
+	if err != nil {
+		return err
+	}
+	for _, other := range groups {
+		if other.ID != stored.ID && other.DisplayName == g.DisplayName {
+			return &Error{Status: http.StatusConflict, ScimType: "uniqueness", Detail: fmt.Sprintf("group %q already exists", g.DisplayName)}
+		}
+	}
+	var members []string
+	for _, m := range g.Members {
+		u, err := config.LoadSCIMUser(p.ecl, m.Value)
+		if err != nil {
+			return err
+		}
+		if u == nil {
+			return badRequest("invalidValue", "member %q is not a provisioned user", m.Value)
+		}
+		if !contains(members, m.Value) {
+			members = append(members, m.Value)
+		}
+	}
+	sort.Strings(members)
+
+	affected := append(append([]string{}, stored.Members...), members...)
+	if stored.DisplayName == g.DisplayName {
+		// only the users who joined or left the group are affected
+		affected = nil
+		for _, m := range stored.Members {
+			if !contains(members, m) {
+				affected = append(affected, m)
+			}
+		}
+		for _, m := range members {
+			if !contains(stored.Members, m) {
+				affected = append(affected, m)
+			}
+		}
+	}
+	stored.ExternalID, stored.DisplayName, stored.Members, stored.Modified = g.ExternalID, g.DisplayName, members, time.Now()
+	if err := config.StoreSCIMGroup(p.ecl, stored); err != nil {
+		return err
+	}
+	p.record(audit.ActionGroupProvisioned, "%s group %s with %d member(s)", verb, stored.DisplayName, len(members))
+	return p.sync(affected...)
+}
+
+// sync stores the display names of the provisioned groups of each of "names" as the groups of the user in access control.
+func (p Provisioner) sync(names ...string) error {
+	groups, err := config.LoadSCIMGroups(p.ecl)
+	if err != nil {
+		return err
+	}
+	for _, name := range names {
+		var gs []string
+		for _, g := range groups {
+			if contains(g.Members, name) {
+				gs = append(gs, g.DisplayName)
+			}
+		}
+		sort.Strings(gs)
+		if err := config.StoreUserGroups(p.ecl, name, gs); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (p Provisioner) record(action, format string, args ...interface{}) {
+	rec := audit.Record{Actor: Actor, Action: action, Result: audit.ResultSuccess, Detail: fmt.Sprintf(format, args...)}
+	if err := audit.Append(p.ecl, rec); err != nil {
+		glog.Errorf("Failed to record %q in the audit log: %v", rec.Detail, err)
+	}
+}
+
+// UserGroups returns the groups of "user" and true if the user is provisioned by the identity provider.
+// The groups of provisioned users follow the identity provider rather than the groups given at login.
+func UserGroups(ecl config.ETCDInterface, user string) ([]string, bool) {
+	u, err := config.LoadSCIMUser(ecl, user)
+	if err != nil {
+		glog.Errorf("Failed to load scim user %s: %v", user, err)
+		return nil, false
+	}
+	if u == nil {
+		return nil, false
+	}
+	groups, err := config.LoadUserGroups(ecl, user)
+	if err != nil {
+		glog.Errorf("Failed to load groups of %s: %v", user, err)
+		return nil, false
+	}
+	return groups, true
+}
+
+// patchUser applies "op" to "u".
+func patchUser(u *User, op Operation) error {
+	attrs := map[string]interface{}{}
+	if op.Path == "" {
+		if err := json.Unmarshal(op.Value, &attrs); err != nil {
+			return badRequest("invalidValue", "value of %s must be an object: %v", op.Op, err)
+		}
+	} else {
+		attrs[op.Path] = rawValue(op.Value)
+	}
+	removing := strings.EqualFold(op.Op, "remove")
+	if !removing && !strings.EqualFold(op.Op, "add") && !strings.EqualFold(op.Op, "replace") {
+		return badRequest("invalidSyntax", "unsupported op %q", op.Op)
+	}
+	for k, v := range attrs {
+		switch strings.ToLower(k) {
+		case "active":
+			active := true
+			if !removing {
+				b, err := boolValue(v)
+				if err != nil {
+					return err
+				}
+				active = b
+			}
+			u.Active = &active
+		case "displayname":
+			u.DisplayName = ""
+			if !removing {
+				u.DisplayName = fmt.Sprint(v)
+			}
+		case "externalid":
+			u.ExternalID = ""
+			if !removing {
+				u.ExternalID = fmt.Sprint(v)
+			}
+		case "username":
+			if !removing {
+				u.UserName = fmt.Sprint(v)
+			}
+		case "schemas", "id", "meta":
+		default:
+			return badRequest("invalidPath", "unsupported attribute %q of users", k)
+		}
+	}
+	return nil
+}
+
+var memberPathPattern = regexp.MustCompile(`(?i)\Amembers\[\s*value\s+eq\s+"([^"]*)"\s*\]\z`)
+
+// patchGroup applies "op" to "g".
+func patchGroup(g *Group, op Operation) error {
+	switch {
+	case strings.EqualFold(op.Op, "add"), strings.EqualFold(op.Op, "replace"):
+		attrs := map[string]json.RawMessage{}
+		if op.Path == "" {
+			if err := json.Unmarshal(op.Value, &attrs); err != nil {
+				return badRequest("invalidValue", "value of %s must be an object: %v", op.Op, err)
+			}
+		} else {
+			attrs[op.Path] = op.Value
+		}
+		for k, v := range attrs {
+			switch strings.ToLower(k) {
+			case "members":
+				var refs []Ref
+				if err := json.Unmarshal(v, &refs); err != nil {
+					return badRequest("invalidValue", "invalid members: %v", err)
+				}
+				if strings.EqualFold(op.Op, "replace") {
+					g.Members = nil
+				}
+				g.Members = append(g.Members, refs...)
+			case "displayname":
+				g.DisplayName = fmt.Sprint(rawValue(v))
+			case "externalid":
+				g.ExternalID = fmt.Sprint(rawValue(v))
+			case "schemas", "id", "meta":
+			default:
+				return badRequest("invalidPath", "unsupported attribute %q of groups", k)
+			}
+		}
+	case strings.EqualFold(op.Op, "remove"):
+		if m := memberPathPattern.FindStringSubmatch(op.Path); m != nil {
+			g.Members = removeRefs(g.Members, m[1])
+			return nil
+		}
+		if !strings.EqualFold(op.Path, "members") {
+			return badRequest("invalidPath", "unsupported path %q to remove from groups", op.Path)
+		}
+		if len(op.Value) == 0 {
+			g.Members = nil
+			return nil
+		}
+		var refs []Ref
+		if err := json.Unmarshal(op.Value, &refs); err != nil {
+			return badRequest("invalidValue", "invalid members: %v", err)
+		}
+		for _, r := range refs {
+			g.Members = removeRefs(g.Members, r.Value)
+		}
+	default:
+		return badRequest("invalidSyntax", "unsupported op %q", op.Op)
+	}
+	return nil
+}
+
+// rawValue decodes "v" into a Go value, or returns it as a string if it is not valid JSON.
+func rawValue(v json.RawMessage) interface{} {
+	var x interface{}
+	if err := json.Unmarshal(v, &x); err != nil {
+		return string(v)
+	}
+	return x
+}
+
+// boolValue accepts booleans and their string representations, which some identity providers send.
+func boolValue(v interface{}) (bool, error) {
+	switch b := v.(type) {
+	case bool:
+		return b, nil
+	case string:
+		switch strings.ToLower(b) {
+		case "true":
+			return true, nil
+		case "false":
+			return false, nil
+		}
+	}
+	return false, badRequest("invalidValue", "invalid boolean %v", v)
+}
+
+func validUserName(name string) error {
+	if name == "" {
+		return badRequest("invalidValue", "userName not specified")
+	}
+	if strings.Contains(name, "/") || name == "." || name == ".." {
+		return badRequest("invalidValue", "userName %q must not contain '/'", name)
+	}
+	return nil
+}
+
+func newID() (string, error) {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buf), nil
+}
+
+func contains(list []string, s string) bool {
+	for _, x := range list {
+		if x == s {
+			return true
+		}
+	}
+	return false
+}
+
+func remove(list []string, s string) []string {
+	var rest []string
+	for _, x := range list {
+		if x != s {
+			rest = append(rest, x)
+		}
+	}
+	return rest
+}
+
+func removeRefs(refs []Ref, value string) []Ref {
+	var rest []Ref
+	for _, r := range refs {
+		if r.Value != value {
+			rest = append(rest, r)
+		}
+	}
+	return rest
+}
@@ -0,0 +1,171 @@
+package scim
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"testing"
+
+	"github.com/gengo/goship/lib/config"
+)
+
+func newTestProvisioner() (*config.MemoryStore, Provisioner) {
+	s := config.NewMemoryStore()
+	c := config.Config{SCIM: &config.SCIMConfig{Token: "secret", ReassignTo: "admin"}}
+	return s, New(s, c)
+}
+
+func TestParseFilter(t *testing.T) {
+	for _, spec := range []struct {
+		filter string
+		want   Filter
+	}{
+		{filter: "", want: Filter{}},
+		{filter: `userName eq "alice"`, want: Filter{Attribute: "userName", Value: "alice"}},
+		{filter: `displayName EQ "dev \"ops\""`, want: Filter{Attribute: "displayName", Value: `dev "ops"`}},
+	} {
+		got, err := ParseFilter(spec.filter)
+		if err != nil {
+			t.Errorf("ParseFilter(%q) failed with %v; want success", spec.filter, err)
+			continue
+		}
+		if got != spec.want {
+			t.Errorf("ParseFilter(%q) = %#v; want %#v", spec.filter, got, spec.want)
+		}
+	}
+	for _, filter := range []string{`userName sw "a"`, `userName eq alice`, `userName eq "a" and active eq true`} {
+		if _, err := ParseFilter(filter); err == nil {
+			t.Errorf("ParseFilter(%q) succeeded; want failure", filter)
+		}
+	}
+}
+
+func TestProvisionUsers(t *testing.T) {
+	s, p := newTestProvisioner()
+	u, err := p.CreateUser(User{UserName: "alice", ExternalID: "00u1", DisplayName: "Alice"})
+	if err != nil {
+		t.Fatalf("p.CreateUser(alice) failed with %v; want success", err)
+	}
+	if u.ID != "alice" || u.Active == nil || !*u.Active {
+		t.Errorf("p.CreateUser(alice) = %#v; want an active user whose ID is %q", u, "alice")
+	}
+	_, err = p.CreateUser(User{UserName: "alice"})
+	if e, ok := err.(*Error); !ok || e.Status != http.StatusConflict {
+		t.Errorf("p.CreateUser(alice) again = %v; want an error with status %d", err, http.StatusConflict)
+	}
+	if _, err := p.CreateUser(User{UserName: "a/b"}); err == nil {
+		t.Errorf("p.CreateUser(a/b) succeeded; want failure")
+	}
+
+	f, err := ParseFilter(`externalId eq "00u1"`)
+	if err != nil {
+		t.Fatalf("ParseFilter failed with %v", err)
+	}
+	found, err := p.Users(f)
+	if err != nil {
+		t.Fatalf("p.Users(%#v) failed with %v; want success", f, err)
+	}
+	if len(found) != 1 || found[0].UserName != "alice" {
+		t.Errorf("p.Users(%#v) = %#v; want alice", f, found)
+	}
+
+	ops := []Operation{{Op: "Replace", Path: "active", Value: json.RawMessage(`"False"`)}}
+	u, err = p.PatchUser("alice", ops)
+	if err != nil {
+		t.Fatalf("p.PatchUser(alice, %#v) failed with %v; want success", ops, err)
+	}
+	if *u.Active {
+		t.Errorf("active of alice = true; want false after deprovisioning")
+	}
+	d, err := config.LoadDeactivation(s, "alice")
+	if err != nil {
+		t.Fatalf("config.LoadDeactivation(s, alice) failed with %v", err)
+	}
+	if d == nil || d.Active() || d.By != Actor {
+		t.Errorf("deactivation of alice = %#v; want deactivated by %q", d, Actor)
+	}
+
+	ops = []Operation{{Op: "replace", Value: json.RawMessage(`{"active":true,"displayName":"Alice A."}`)}}
+	u, err = p.PatchUser("alice", ops)
+	if err != nil {
+		t.Fatalf("p.PatchUser(alice, %#v) failed with %v; want success", ops, err)
+	}
+	if !*u.Active || u.DisplayName != "Alice A." {
+		t.Errorf("p.PatchUser(alice, %#v) = %#v; want active with the new display name", ops, u)
+	}
+
+	if _, err := p.ReplaceUser("alice", User{UserName: "alicia"}); err == nil {
+		t.Errorf("p.ReplaceUser(alice, alicia) succeeded; want failure because userName is immutable")
+	}
+	if err := p.DeleteUser("alice"); err != nil {
+		t.Fatalf("p.DeleteUser(alice) failed with %v; want success", err)
+	}
+	if _, err := p.User("alice"); err == nil {
+		t.Errorf("p.User(alice) succeeded after deletion; want failure")
+	}
+	if d, err := config.LoadDeactivation(s, "alice"); err != nil || d == nil || d.Active() {
+		t.Errorf("deactivation of alice = %#v, %v; want deactivated after deletion", d, err)
+	}
+}
+
+func TestProvisionGroups(t *testing.T) {
+	s, p := newTestProvisioner()
+	for _, name := range []string{"alice", "bob"} {
+		if _, err := p.CreateUser(User{UserName: name}); err != nil {
+			t.Fatalf("p.CreateUser(%s) failed with %v; want success", name, err)
+		}
+	}
+	g, err := p.CreateGroup(Group{DisplayName: "deployers", Members: []Ref{{Value: "alice"}}})
+	if err != nil {
+		t.Fatalf("p.CreateGroup(deployers) failed with %v; want success", err)
+	}
+	if _, err := p.CreateGroup(Group{DisplayName: "deployers"}); err == nil {
+		t.Errorf("p.CreateGroup(deployers) again succeeded; want failure")
+	}
+	if _, err := p.CreateGroup(Group{DisplayName: "ops", Members: []Ref{{Value: "carol"}}}); err == nil {
+		t.Errorf("p.CreateGroup(ops) with an unknown member succeeded; want failure")
+	}
+	assertGroups := func(user string, want []string) {
+		got, ok := UserGroups(s, user)
+		if !ok {
+			t.Errorf("UserGroups(s, %q) = _, false; want true for provisioned users", user)
+			return
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("UserGroups(s, %q) = %q; want %q", user, got, want)
+		}
+	}
+	assertGroups("alice", []string{"deployers"})
+	assertGroups("bob", nil)
+
+	ops := []Operation{
+		{Op: "add", Path: "members", Value: json.RawMessage(`[{"value":"bob"}]`)},
+		{Op: "remove", Path: `members[value eq "alice"]`},
+	}
+	if _, err := p.PatchGroup(g.ID, ops); err != nil {
+		t.Fatalf("p.PatchGroup(%q, %#v) failed with %v; want success", g.ID, ops, err)
+	}
+	assertGroups("alice", nil)
+	assertGroups("bob", []string{"deployers"})
+
+	ops = []Operation{{Op: "replace", Value: json.RawMessage(`{"displayName":"releasers"}`)}}
+	if _, err := p.PatchGroup(g.ID, ops); err != nil {
+		t.Fatalf("p.PatchGroup(%q, %#v) failed with %v; want success", g.ID, ops, err)
+	}
+	assertGroups("bob", []string{"releasers"})
+	u, err := p.User("bob")
+	if err != nil {
+		t.Fatalf("p.User(bob) failed with %v; want success", err)
+	}
+	if want := []Ref{{Value: g.ID, Display: "releasers"}}; !reflect.DeepEqual(u.Groups, want) {
+		t.Errorf("groups of bob = %#v; want %#v", u.Groups, want)
+	}
+
+	if err := p.DeleteGroup(g.ID); err != nil {
+		t.Fatalf("p.DeleteGroup(%q) failed with %v; want success", g.ID, err)
+	}
+	assertGroups("bob", nil)
+	if _, ok := UserGroups(s, "carol"); ok {
+		t.Errorf("UserGroups(s, %q) = _, true; want false for users not provisioned", "carol")
+	}
+}
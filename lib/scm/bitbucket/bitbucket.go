@@ -0,0 +1,254 @@
+// Package bitbucket provides an implementation of scm.Client and acl.AccessControl on top of Bitbucket Cloud.
+package bitbucket
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/gengo/goship/lib/config"
+	"github.com/gengo/goship/lib/revision"
+	"github.com/gengo/goship/lib/scm"
+	"github.com/golang/glog"
+	"golang.org/x/net/context"
+)
+
+const (
+	// DefaultAPIURL is the URL of Bitbucket Cloud APIs.
+	DefaultAPIURL = "https://api.bitbucket.org"
+	webURL        = "https://bitbucket.org"
+
+	// maxPages limits the number of pages to fetch on listing commits.
+	maxPages = 10
+)
+
+// Client is a client of Bitbucket Cloud APIs.
+// It implements scm.Client and acl.AccessControl.
+type Client struct {
+	apiURL             string
+	username, password string
+	hc                 *http.Client
+}
+
+var _ scm.Client = (*Client)(nil)
+
+// New returns a new Client of the Bitbucket Cloud APIs at "apiURL".
+// "username" and "appPassword" must be a pair of a user name and an app password with "repository" and "account" permissions.
+func New(apiURL, username, appPassword string) *Client {
+	return &Client{
+		apiURL:   strings.TrimSuffix(apiURL, "/"),
+		username: username,
+		password: appPassword,
+		hc:       &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+type commit struct {
+	Hash    string `json:"hash"`
+	Message string `json:"message"`
+	Author  struct {
+		Raw string `json:"raw"`
+	} `json:"author"`
+}
+
+type commitPage struct {
+	Values []commit `json:"values"`
+	Next   string   `json:"next"`
+}
+
+func repoPath(repo config.Repo) string {
+	return fmt.Sprintf("/2.0/repositories/%s/%s", url.QueryEscape(repo.RepoOwner), url.QueryEscape(repo.RepoName))
+}
+
+// get sends a GET request to "u", which is either an absolute URL or an API path, and decodes the response into "resp".
+func (c *Client) get(u string, resp interface{}) error {
+	if strings.HasPrefix(u, "/") {
+		u = c.apiURL + u
+	}
+	req, err := http.NewRequest("GET", u, nil)
+	if err != nil {
+		return err
+	}
+	req.SetBasicAuth(c.username, c.password)
+	res, err := c.hc.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return fmt.Errorf("GET %s returned %s", u, res.Status)
+	}
+	return json.NewDecoder(res.Body).Decode(resp)
+}
+
+func (c *Client) Latest(ctx context.Context, repo config.Repo, ref string) (revision.Revision, error) {
+	var page commitPage
+	if err := c.get(repoPath(repo)+"/commits/"+url.QueryEscape(ref)+"?pagelen=1", &page); err != nil {
+		return "", err
+	}
+	if len(page.Values) == 0 {
+		return "", fmt.Errorf("no commits in the branch %s", ref)
+	}
+	return revision.Revision(page.Values[0].Hash), nil
+}
+
+func (c *Client) Message(ctx context.Context, repo config.Repo, rev revision.Revision) (string, error) {
+	var cm commit
+	if err := c.get(repoPath(repo)+"/commit/"+url.QueryEscape(string(rev)), &cm); err != nil {
+		return "", err
+	}
+	return cm.Message, nil
+}
+
+// Commits returns the commits which are reachable from "to" but not from "from", from the newest.
+// It fetches at most maxPages pages of commits.
+func (c *Client) Commits(ctx context.Context, repo config.Repo, from, to revision.Revision) ([]scm.Commit, error) {
+	var commits []scm.Commit
+	u := repoPath(repo) + "/commits/" + url.QueryEscape(string(to)) + "?" + url.Values{"exclude": {string(from)}}.Encode()
+	for i := 0; u != "" && i < maxPages; i++ {
+		var page commitPage
+		if err := c.get(u, &page); err != nil {
+			return nil, err
+		}
+		for _, cm := range page.Values {
+			commits = append(commits, scm.Commit{
+				Revision: revision.Revision(cm.Hash),
+				Message:  cm.Message,
+				Author:   cm.Author.Raw,
+			})
+		}
+		u = page.Next
+	}
+	return commits, nil
+}
+
+func (c *Client) Compare(ctx context.Context, repo config.Repo, from, to revision.Revision) (scm.Comparison, error) {
+	commits, err := c.Commits(ctx, repo, from, to)
+	if err != nil {
+		return scm.Comparison{}, err
+	}
+	// Commits lists commits from the newest
+	comp := scm.Comparison{Commits: make([]scm.Commit, 0, len(commits))}
+	for i := len(commits) - 1; i >= 0; i-- {
+		comp.Commits = append(comp.Commits, commits[i])
+	}
+	// "to..from" is the changes in "to" since its common ancestor with "from"
+	u := repoPath(repo) + "/diffstat/" + url.QueryEscape(string(to)) + ".." + url.QueryEscape(string(from))
+	for i := 0; u != "" && i < maxPages; i++ {
+		var page struct {
+			Values []struct {
+				Status       string `json:"status"`
+				LinesAdded   int    `json:"lines_added"`
+				LinesRemoved int    `json:"lines_removed"`
+				Old          *struct {
+					Path string `json:"path"`
+				} `json:"old"`
+				New *struct {
+					Path string `json:"path"`
+				} `json:"new"`
+			} `json:"values"`
+			Next string `json:"next"`
+		}
+		if err := c.get(u, &page); err != nil {
+			return scm.Comparison{}, err
+		}
+		for _, d := range page.Values {
+			f := scm.File{Status: d.Status, Additions: d.LinesAdded, Deletions: d.LinesRemoved}
+			switch {
+			case d.New != nil:
+				f.Path = d.New.Path
+			case d.Old != nil:
+				f.Path = d.Old.Path
+			}
+			comp.Files = append(comp.Files, f)
+		}
+		u = page.Next
+	}
+	return comp, nil
+}
+
+func (c *Client) Statuses(ctx context.Context, repo config.Repo, rev revision.Revision) ([]scm.Status, error) {
+	var statuses []scm.Status
+	u := repoPath(repo) + "/commit/" + url.QueryEscape(string(rev)) + "/statuses"
+	for i := 0; u != "" && i < maxPages; i++ {
+		var page struct {
+			Values []struct {
+				Key   string `json:"key"`
+				State string `json:"state"`
+				URL   string `json:"url"`
+			} `json:"values"`
+			Next string `json:"next"`
+		}
+		if err := c.get(u, &page); err != nil {
+			return nil, err
+		}
+		for _, st := range page.Values {
+			s := scm.Status{Context: st.Key, URL: st.URL}
+			switch st.State {
+			case "SUCCESSFUL":
+				s.State = scm.StatusSuccess
+			case "INPROGRESS":
+				s.State = scm.StatusPending
+			default:
+				// FAILED and STOPPED
+				s.State = scm.StatusFailure
+			}
+			statuses = append(statuses, s)
+		}
+		u = page.Next
+	}
+	return statuses, nil
+}
+
+func (c *Client) CommitURL(repo config.Repo, rev revision.Revision) string {
+	return fmt.Sprintf("%s/%s/%s/commits/%s", webURL, repo.RepoOwner, repo.RepoName, rev)
+}
+
+func (c *Client) CompareURL(repo config.Repo, from, to revision.Revision) string {
+	return fmt.Sprintf("%s/%s/%s/branches/compare/%s%%0D%s", webURL, repo.RepoOwner, repo.RepoName, to, from)
+}
+
+func (c *Client) PullRequestURL(repo config.Repo, number int) string {
+	return fmt.Sprintf("%s/%s/%s/pull-requests/%d", webURL, repo.RepoOwner, repo.RepoName, number)
+}
+
+// permission returns the permission of "user" on "$owner/$repo", which is one of "read", "write", "admin" or empty.
+func (c *Client) permission(owner, repo, user string) (string, error) {
+	var page struct {
+		Values []struct {
+			Permission string `json:"permission"`
+		} `json:"values"`
+	}
+	q := url.Values{"q": {fmt.Sprintf("user.nickname=%q", user)}}
+	u := fmt.Sprintf("/2.0/workspaces/%s/permissions/repositories/%s?%s", url.QueryEscape(owner), url.QueryEscape(repo), q.Encode())
+	if err := c.get(u, &page); err != nil {
+		return "", err
+	}
+	if len(page.Values) == 0 {
+		return "", nil
+	}
+	return page.Values[0].Permission, nil
+}
+
+// Readable determines if "user" has any permission on "$owner/$repo".
+func (c *Client) Readable(owner, repo, user string) bool {
+	p, err := c.permission(owner, repo, user)
+	if err != nil {
+		glog.Errorf("Failed to get permission of %s on %s/%s: %v", user, owner, repo, err)
+		return false
+	}
+	return p != ""
+}
+
+// Deployable determines if "user" has write permission on "$owner/$repo".
+func (c *Client) Deployable(owner, repo, user string) bool {
+	p, err := c.permission(owner, repo, user)
+	if err != nil {
+		glog.Errorf("Failed to get permission of %s on %s/%s: %v", user, owner, repo, err)
+		return false
+	}
+	return p == "write" || p == "admin"
+}
@@ -0,0 +1,131 @@
+package bitbucket
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/gengo/goship/lib/config"
+	"github.com/gengo/goship/lib/revision"
+	"github.com/gengo/goship/lib/scm"
+	"golang.org/x/net/context"
+)
+
+var testRepo = config.Repo{RepoOwner: "team", RepoName: "repo"}
+
+func newTestServer(t *testing.T) *httptest.Server {
+	var s *httptest.Server
+	responses := map[string]func() string{
+		"/2.0/repositories/team/repo/commits/master?pagelen=1": func() string {
+			return `{"values": [{"hash": "abc123", "message": "latest"}]}`
+		},
+		"/2.0/repositories/team/repo/commit/abc123": func() string {
+			return `{"hash": "abc123", "message": "latest"}`
+		},
+		"/2.0/repositories/team/repo/commits/abc123?exclude=abc000": func() string {
+			return fmt.Sprintf(`{"values": [{"hash": "abc123", "message": "latest", "author": {"raw": "bob"}}], "next": "%s/page2"}`, s.URL)
+		},
+		"/page2": func() string {
+			return `{"values": [{"hash": "abc100", "message": "first", "author": {"raw": "alice"}}]}`
+		},
+		"/2.0/repositories/team/repo/diffstat/abc123..abc000": func() string {
+			return `{"values": [
+				{"status": "modified", "lines_added": 3, "lines_removed": 1, "old": {"path": "main.go"}, "new": {"path": "main.go"}},
+				{"status": "removed", "lines_added": 0, "lines_removed": 10, "old": {"path": "old.go"}, "new": null}
+			]}`
+		},
+		`/2.0/workspaces/team/permissions/repositories/repo?q=user.nickname%3D%22reader%22`: func() string {
+			return `{"values": [{"permission": "read"}]}`
+		},
+		`/2.0/workspaces/team/permissions/repositories/repo?q=user.nickname%3D%22writer%22`: func() string {
+			return `{"values": [{"permission": "write"}]}`
+		},
+		`/2.0/workspaces/team/permissions/repositories/repo?q=user.nickname%3D%22stranger%22`: func() string {
+			return `{"values": []}`
+		},
+	}
+	s = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if u, p, ok := r.BasicAuth(); !ok || u != "user" || p != "password" {
+			t.Errorf("r.BasicAuth() = %q, %q, %v; want %q, %q, true", u, p, ok, "user", "password")
+		}
+		resp, ok := responses[r.URL.RequestURI()]
+		if !ok {
+			t.Errorf("unexpected request %s", r.URL.RequestURI())
+			http.NotFound(w, r)
+			return
+		}
+		fmt.Fprint(w, resp())
+	}))
+	return s
+}
+
+func TestClient(t *testing.T) {
+	s := newTestServer(t)
+	defer s.Close()
+	c := New(s.URL, "user", "password")
+	ctx := context.Background()
+
+	rev, err := c.Latest(ctx, testRepo, "master")
+	if err != nil {
+		t.Errorf("c.Latest(ctx, %#v, %q) failed with %v; want success", testRepo, "master", err)
+	} else if got, want := rev, revision.Revision("abc123"); got != want {
+		t.Errorf("c.Latest(ctx, %#v, %q) = %q; want %q", testRepo, "master", got, want)
+	}
+
+	msg, err := c.Message(ctx, testRepo, "abc123")
+	if err != nil {
+		t.Errorf("c.Message(ctx, %#v, %q) failed with %v; want success", testRepo, "abc123", err)
+	} else if got, want := msg, "latest"; got != want {
+		t.Errorf("c.Message(ctx, %#v, %q) = %q; want %q", testRepo, "abc123", got, want)
+	}
+
+	commits, err := c.Commits(ctx, testRepo, "abc000", "abc123")
+	if err != nil {
+		t.Errorf("c.Commits(ctx, %#v, %q, %q) failed with %v; want success", testRepo, "abc000", "abc123", err)
+	}
+	want := []scm.Commit{
+		{Revision: "abc123", Message: "latest", Author: "bob"},
+		{Revision: "abc100", Message: "first", Author: "alice"},
+	}
+	if !reflect.DeepEqual(commits, want) {
+		t.Errorf("c.Commits(ctx, %#v, %q, %q) = %#v; want %#v", testRepo, "abc000", "abc123", commits, want)
+	}
+
+	comp, err := c.Compare(ctx, testRepo, "abc000", "abc123")
+	if err != nil {
+		t.Errorf("c.Compare(ctx, %#v, %q, %q) failed with %v; want success", testRepo, "abc000", "abc123", err)
+	}
+	wantComp := scm.Comparison{
+		Commits: []scm.Commit{want[1], want[0]},
+		Files: []scm.File{
+			{Path: "main.go", Status: "modified", Additions: 3, Deletions: 1},
+			{Path: "old.go", Status: "removed", Deletions: 10},
+		},
+	}
+	if !reflect.DeepEqual(comp, wantComp) {
+		t.Errorf("c.Compare(ctx, %#v, %q, %q) = %#v; want %#v", testRepo, "abc000", "abc123", comp, wantComp)
+	}
+}
+
+func TestAccessControl(t *testing.T) {
+	s := newTestServer(t)
+	defer s.Close()
+	c := New(s.URL, "user", "password")
+	for _, spec := range []struct {
+		user                 string
+		readable, deployable bool
+	}{
+		{user: "reader", readable: true, deployable: false},
+		{user: "writer", readable: true, deployable: true},
+		{user: "stranger", readable: false, deployable: false},
+	} {
+		if got, want := c.Readable("team", "repo", spec.user), spec.readable; got != want {
+			t.Errorf("c.Readable(%q, %q, %q) = %v; want %v", "team", "repo", spec.user, got, want)
+		}
+		if got, want := c.Deployable("team", "repo", spec.user), spec.deployable; got != want {
+			t.Errorf("c.Deployable(%q, %q, %q) = %v; want %v", "team", "repo", spec.user, got, want)
+		}
+	}
+}
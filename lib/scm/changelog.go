@@ -0,0 +1,116 @@
+package scm
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/gengo/goship/lib/config"
+	"github.com/gengo/goship/lib/revision"
+	"golang.org/x/net/context"
+)
+
+// PullRequest is a pull request, or a merge request, merged between two revisions.
+type PullRequest struct {
+	Number int    `json:"number"`
+	Title  string `json:"title"`
+	URL    string `json:"url"`
+}
+
+// Changelog describes what is shipped by deploying "To" over "From".
+type Changelog struct {
+	From revision.Revision `json:"from"`
+	To   revision.Revision `json:"to"`
+	// Commits are the commits to ship, from the newest.
+	Commits []Commit `json:"commits"`
+	// PullRequests are the pull requests merged in Commits, found in their commit messages.
+	PullRequests []PullRequest `json:"pull_requests"`
+	Files        []File        `json:"files"`
+	CompareURL   string        `json:"compare_url"`
+}
+
+// pullRequestPatterns find the number of a pull request in the message of the commit which merged it.
+var pullRequestPatterns = []*regexp.Regexp{
+	// merge commits and squashed commits on GitHub
+	regexp.MustCompile(`\AMerge pull request #(\d+)`),
+	regexp.MustCompile(`\A[^\n]*\(#(\d+)\)\n`),
+	// merge commits on GitLab
+	regexp.MustCompile(`See merge request \S*!(\d+)`),
+	// merge commits on Bitbucket
+	regexp.MustCompile(`\AMerged in [^\n]*\(pull request #(\d+)\)`),
+}
+
+// pullRequest returns the number and the title of the pull request which "c" merged, or 0 if "c" does not merge any.
+func pullRequest(c Commit) (int, string) {
+	msg := strings.TrimSpace(c.Message) + "\n"
+	for _, re := range pullRequestPatterns {
+		m := re.FindStringSubmatch(msg)
+		if m == nil {
+			continue
+		}
+		n, err := strconv.Atoi(m[1])
+		if err != nil {
+			continue
+		}
+		lines := strings.Split(msg, "\n")
+		subject := lines[0]
+		if !strings.HasPrefix(subject, "Merge") {
+			// squashed commits have the title of the pull request in their subject
+			return n, strings.TrimSpace(strings.TrimSuffix(subject, fmt.Sprintf("(#%d)", n)))
+		}
+		// merge commits have the title after the subject
+		for _, l := range lines[1:] {
+			if l = strings.TrimSpace(l); l != "" {
+				return n, l
+			}
+		}
+		return n, subject
+	}
+	return 0, ""
+}
+
+// Subject returns the first line of the commit message.
+func (c Commit) Subject() string {
+	return strings.SplitN(c.Message, "\n", 2)[0]
+}
+
+// NewChangelog returns the changelog between "from" and "to" in "repo".
+func NewChangelog(ctx context.Context, c Client, repo config.Repo, from, to revision.Revision) (Changelog, error) {
+	comp, err := c.Compare(ctx, repo, from, to)
+	if err != nil {
+		return Changelog{}, err
+	}
+	cl := Changelog{
+		From:       from,
+		To:         to,
+		Files:      comp.Files,
+		CompareURL: c.CompareURL(repo, from, to),
+	}
+	for i := len(comp.Commits) - 1; i >= 0; i-- {
+		commit := comp.Commits[i]
+		cl.Commits = append(cl.Commits, commit)
+		if n, title := pullRequest(commit); n > 0 {
+			cl.PullRequests = append(cl.PullRequests, PullRequest{Number: n, Title: title, URL: c.PullRequestURL(repo, n)})
+		}
+	}
+	return cl, nil
+}
+
+// Summary returns at most "max" lines which describe the changes, i.e. the pull requests if any, or the commits otherwise.
+// The last line tells the number of the omitted changes if any.
+func (cl Changelog) Summary(max int) []string {
+	var lines []string
+	for _, pr := range cl.PullRequests {
+		lines = append(lines, fmt.Sprintf("#%d %s", pr.Number, pr.Title))
+	}
+	if len(lines) == 0 {
+		for _, c := range cl.Commits {
+			lines = append(lines, fmt.Sprintf("%s %s", c.Revision.Short(), c.Subject()))
+		}
+	}
+	if max > 0 && len(lines) > max {
+		lines = append(lines[:max-1], fmt.Sprintf("and %d more", len(lines)-max+1))
+	}
+	return lines
+}
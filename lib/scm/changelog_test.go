@@ -0,0 +1,70 @@
+package scm
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/gengo/goship/lib/config"
+	"github.com/gengo/goship/lib/revision"
+	"golang.org/x/net/context"
+)
+
+// compareClient is a Client which compares any revisions into the given comparison.
+type compareClient struct {
+	Client
+	comp Comparison
+}
+
+func (c compareClient) Compare(ctx context.Context, repo config.Repo, from, to revision.Revision) (Comparison, error) {
+	return c.comp, nil
+}
+
+func (c compareClient) CompareURL(repo config.Repo, from, to revision.Revision) string {
+	return fmt.Sprintf("https://example.com/%s/compare/%s...%s", repo.RepoName, from, to)
+}
+
+func (c compareClient) PullRequestURL(repo config.Repo, number int) string {
+	return fmt.Sprintf("https://example.com/%s/pull/%d", repo.RepoName, number)
+}
+
+func TestNewChangelog(t *testing.T) {
+	c := compareClient{comp: Comparison{
+		Commits: []Commit{
+			{Revision: "aaa1111", Message: "Fix typo"},
+			{Revision: "bbb2222", Message: "Merge pull request #12 from alice/feature\n\nAdd a feature"},
+			{Revision: "ccc3333", Message: "Improve performance (#13)\n\n* Cache results"},
+			{Revision: "ddd4444", Message: "Merge branch 'fix' into 'master'\n\nFix a bug\n\nSee merge request group/project!14"},
+			{Revision: "eee5555", Message: "Merged in hotfix (pull request #15)\n\nHotfix"},
+		},
+		Files: []File{{Path: "main.go", Status: "modified", Additions: 1}},
+	}}
+	repo := config.Repo{RepoOwner: "owner", RepoName: "repo"}
+	cl, err := NewChangelog(context.Background(), c, repo, "abc000", "abc123")
+	if err != nil {
+		t.Fatalf("NewChangelog(ctx, c, repo, %q, %q) failed with %v; want success", "abc000", "abc123", err)
+	}
+	if got, want := cl.Commits[0].Revision, revision.Revision("eee5555"); got != want {
+		t.Errorf("cl.Commits[0].Revision = %q; want %q, the newest", got, want)
+	}
+	want := []PullRequest{
+		{Number: 15, Title: "Hotfix", URL: "https://example.com/repo/pull/15"},
+		{Number: 14, Title: "Fix a bug", URL: "https://example.com/repo/pull/14"},
+		{Number: 13, Title: "Improve performance", URL: "https://example.com/repo/pull/13"},
+		{Number: 12, Title: "Add a feature", URL: "https://example.com/repo/pull/12"},
+	}
+	if !reflect.DeepEqual(cl.PullRequests, want) {
+		t.Errorf("cl.PullRequests = %#v; want %#v", cl.PullRequests, want)
+	}
+	if got, want := cl.CompareURL, "https://example.com/repo/compare/abc000...abc123"; got != want {
+		t.Errorf("cl.CompareURL = %q; want %q", got, want)
+	}
+
+	if got, want := cl.Summary(3), []string{"#15 Hotfix", "#14 Fix a bug", "and 2 more"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("cl.Summary(3) = %q; want %q", got, want)
+	}
+	cl.PullRequests = nil
+	if got, want := cl.Summary(2), []string{"eee5555 Merged in hotfix (pull request #15)", "and 4 more"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("cl.Summary(2) = %q; want %q", got, want)
+	}
+}
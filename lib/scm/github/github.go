@@ -0,0 +1,140 @@
+// Package github provides an implementation of scm.Client on top of GitHub.
+package github
+
+import (
+	"fmt"
+
+	"github.com/gengo/goship/lib/config"
+	githublib "github.com/gengo/goship/lib/github"
+	"github.com/gengo/goship/lib/revision"
+	"github.com/gengo/goship/lib/scm"
+	"github.com/google/go-github/github"
+	"golang.org/x/net/context"
+)
+
+type client struct {
+	gcl githublib.Client
+}
+
+// New returns a new scm.Client which accesses to GitHub through "gcl".
+func New(gcl githublib.Client) scm.Client {
+	return client{gcl: gcl}
+}
+
+func (c client) Latest(ctx context.Context, repo config.Repo, ref string) (revision.Revision, error) {
+	commits, _, err := c.gcl.ListCommits(repo.RepoOwner, repo.RepoName, &github.CommitsListOptions{SHA: ref})
+	if err != nil {
+		return "", err
+	}
+	if len(commits) == 0 {
+		return "", fmt.Errorf("no commits in the branch %s", ref)
+	}
+	return revision.Revision(*commits[0].SHA), nil
+}
+
+func (c client) Message(ctx context.Context, repo config.Repo, rev revision.Revision) (string, error) {
+	commit, _, err := c.gcl.GetCommit(repo.RepoOwner, repo.RepoName, string(rev))
+	if err != nil {
+		return "", err
+	}
+	if commit.Message == nil {
+		return "", nil
+	}
+	return *commit.Message, nil
+}
+
+func (c client) Commits(ctx context.Context, repo config.Repo, from, to revision.Revision) ([]scm.Commit, error) {
+	comp, err := c.Compare(ctx, repo, from, to)
+	if err != nil {
+		return nil, err
+	}
+	return comp.Commits, nil
+}
+
+func (c client) Compare(ctx context.Context, repo config.Repo, from, to revision.Revision) (scm.Comparison, error) {
+	comp, _, err := c.gcl.CompareCommits(repo.RepoOwner, repo.RepoName, string(from), string(to))
+	if err != nil {
+		return scm.Comparison{}, err
+	}
+	var result scm.Comparison
+	for _, rc := range comp.Commits {
+		var commit scm.Commit
+		if rc.SHA != nil {
+			commit.Revision = revision.Revision(*rc.SHA)
+		}
+		if gc := rc.Commit; gc != nil {
+			if gc.Message != nil {
+				commit.Message = *gc.Message
+			}
+			if gc.Author != nil && gc.Author.Name != nil {
+				commit.Author = *gc.Author.Name
+			}
+		}
+		result.Commits = append(result.Commits, commit)
+	}
+	for _, cf := range comp.Files {
+		var f scm.File
+		if cf.Filename != nil {
+			f.Path = *cf.Filename
+		}
+		if cf.Status != nil {
+			f.Status = *cf.Status
+		}
+		if cf.Additions != nil {
+			f.Additions = *cf.Additions
+		}
+		if cf.Deletions != nil {
+			f.Deletions = *cf.Deletions
+		}
+		result.Files = append(result.Files, f)
+	}
+	return result, nil
+}
+
+func (c client) Statuses(ctx context.Context, repo config.Repo, rev revision.Revision) ([]scm.Status, error) {
+	var statuses []scm.Status
+	opt := &github.ListOptions{PerPage: 100}
+	for {
+		combined, resp, err := c.gcl.GetCombinedStatus(repo.RepoOwner, repo.RepoName, string(rev), opt)
+		if err != nil {
+			return nil, err
+		}
+		for _, st := range combined.Statuses {
+			if st.Context == nil || st.State == nil {
+				continue
+			}
+			s := scm.Status{Context: *st.Context, State: statusState(*st.State)}
+			if st.TargetURL != nil {
+				s.URL = *st.TargetURL
+			}
+			statuses = append(statuses, s)
+		}
+		if resp == nil || resp.NextPage == 0 {
+			return statuses, nil
+		}
+		opt.Page = resp.NextPage
+	}
+}
+
+// statusState converts a state of a GitHub commit status into scm.StatusState.
+func statusState(state string) scm.StatusState {
+	switch state {
+	case "success":
+		return scm.StatusSuccess
+	case "pending":
+		return scm.StatusPending
+	}
+	return scm.StatusFailure
+}
+
+func (c client) CommitURL(repo config.Repo, rev revision.Revision) string {
+	return fmt.Sprintf("https://github.com/%s/%s/commit/%s", repo.RepoOwner, repo.RepoName, rev)
+}
+
+func (c client) CompareURL(repo config.Repo, from, to revision.Revision) string {
+	return fmt.Sprintf("https://github.com/%s/%s/compare/%s...%s", repo.RepoOwner, repo.RepoName, from, to)
+}
+
+func (c client) PullRequestURL(repo config.Repo, number int) string {
+	return fmt.Sprintf("https://github.com/%s/%s/pull/%d", repo.RepoOwner, repo.RepoName, number)
+}
@@ -0,0 +1,231 @@
+// Package gitlab provides an implementation of scm.Client and acl.AccessControl on top of GitLab.
+package gitlab
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/gengo/goship/lib/config"
+	"github.com/gengo/goship/lib/revision"
+	"github.com/gengo/goship/lib/scm"
+	"github.com/golang/glog"
+	"golang.org/x/net/context"
+)
+
+const (
+	// DefaultURL is the URL of gitlab.com.
+	DefaultURL = "https://gitlab.com"
+
+	// Access levels of project members in GitLab.
+	accessLevelReporter  = 20
+	accessLevelDeveloper = 30
+)
+
+// Client is a client of GitLab APIs.
+// It implements scm.Client and acl.AccessControl.
+type Client struct {
+	baseURL string
+	token   string
+	hc      *http.Client
+}
+
+var _ scm.Client = (*Client)(nil)
+
+// New returns a new Client of the GitLab installation at "baseURL".
+// "token" must be a personal access token with "read_api" scope.
+func New(baseURL, token string) *Client {
+	return &Client{
+		baseURL: strings.TrimSuffix(baseURL, "/"),
+		token:   token,
+		hc:      &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+type commit struct {
+	ID         string `json:"id"`
+	Message    string `json:"message"`
+	AuthorName string `json:"author_name"`
+}
+
+// projectPath returns the API path of "repo".
+func projectPath(repo config.Repo) string {
+	return "/projects/" + url.QueryEscape(repo.RepoOwner+"/"+repo.RepoName)
+}
+
+// get sends a GET request to the API at "path" with "query" and decodes the response into "resp".
+func (c *Client) get(path string, query url.Values, resp interface{}) error {
+	u := fmt.Sprintf("%s/api/v4%s", c.baseURL, path)
+	if len(query) > 0 {
+		u += "?" + query.Encode()
+	}
+	req, err := http.NewRequest("GET", u, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("PRIVATE-TOKEN", c.token)
+	res, err := c.hc.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return fmt.Errorf("GET %s returned %s", path, res.Status)
+	}
+	return json.NewDecoder(res.Body).Decode(resp)
+}
+
+func (c *Client) Latest(ctx context.Context, repo config.Repo, ref string) (revision.Revision, error) {
+	var commits []commit
+	q := url.Values{"ref_name": {ref}, "per_page": {"1"}}
+	if err := c.get(projectPath(repo)+"/repository/commits", q, &commits); err != nil {
+		return "", err
+	}
+	if len(commits) == 0 {
+		return "", fmt.Errorf("no commits in the branch %s", ref)
+	}
+	return revision.Revision(commits[0].ID), nil
+}
+
+func (c *Client) Message(ctx context.Context, repo config.Repo, rev revision.Revision) (string, error) {
+	var cm commit
+	if err := c.get(projectPath(repo)+"/repository/commits/"+url.QueryEscape(string(rev)), nil, &cm); err != nil {
+		return "", err
+	}
+	return cm.Message, nil
+}
+
+func (c *Client) Commits(ctx context.Context, repo config.Repo, from, to revision.Revision) ([]scm.Commit, error) {
+	comp, err := c.Compare(ctx, repo, from, to)
+	if err != nil {
+		return nil, err
+	}
+	return comp.Commits, nil
+}
+
+func (c *Client) Compare(ctx context.Context, repo config.Repo, from, to revision.Revision) (scm.Comparison, error) {
+	var comp struct {
+		Commits []commit `json:"commits"`
+		Diffs   []struct {
+			NewPath     string `json:"new_path"`
+			NewFile     bool   `json:"new_file"`
+			RenamedFile bool   `json:"renamed_file"`
+			DeletedFile bool   `json:"deleted_file"`
+			Diff        string `json:"diff"`
+		} `json:"diffs"`
+	}
+	q := url.Values{"from": {string(from)}, "to": {string(to)}}
+	if err := c.get(projectPath(repo)+"/repository/compare", q, &comp); err != nil {
+		return scm.Comparison{}, err
+	}
+	var result scm.Comparison
+	for _, cm := range comp.Commits {
+		result.Commits = append(result.Commits, scm.Commit{
+			Revision: revision.Revision(cm.ID),
+			Message:  cm.Message,
+			Author:   cm.AuthorName,
+		})
+	}
+	for _, d := range comp.Diffs {
+		f := scm.File{Path: d.NewPath, Status: "modified"}
+		switch {
+		case d.NewFile:
+			f.Status = "added"
+		case d.DeletedFile:
+			f.Status = "removed"
+		case d.RenamedFile:
+			f.Status = "renamed"
+		}
+		// the diff consists of hunks without file headers
+		for _, line := range strings.Split(d.Diff, "\n") {
+			switch {
+			case strings.HasPrefix(line, "+"):
+				f.Additions++
+			case strings.HasPrefix(line, "-"):
+				f.Deletions++
+			}
+		}
+		result.Files = append(result.Files, f)
+	}
+	return result, nil
+}
+
+func (c *Client) Statuses(ctx context.Context, repo config.Repo, rev revision.Revision) ([]scm.Status, error) {
+	var resp []struct {
+		Name      string `json:"name"`
+		Status    string `json:"status"`
+		TargetURL string `json:"target_url"`
+	}
+	q := url.Values{"per_page": {"100"}}
+	if err := c.get(projectPath(repo)+"/repository/commits/"+url.QueryEscape(string(rev))+"/statuses", q, &resp); err != nil {
+		return nil, err
+	}
+	var statuses []scm.Status
+	for _, st := range resp {
+		s := scm.Status{Context: st.Name, URL: st.TargetURL}
+		switch st.Status {
+		case "success", "skipped":
+			s.State = scm.StatusSuccess
+		case "failed", "canceled":
+			s.State = scm.StatusFailure
+		default:
+			// created, pending, running and manual
+			s.State = scm.StatusPending
+		}
+		statuses = append(statuses, s)
+	}
+	return statuses, nil
+}
+
+func (c *Client) CommitURL(repo config.Repo, rev revision.Revision) string {
+	return fmt.Sprintf("%s/%s/%s/-/commit/%s", c.baseURL, repo.RepoOwner, repo.RepoName, rev)
+}
+
+func (c *Client) CompareURL(repo config.Repo, from, to revision.Revision) string {
+	return fmt.Sprintf("%s/%s/%s/-/compare/%s...%s", c.baseURL, repo.RepoOwner, repo.RepoName, from, to)
+}
+
+func (c *Client) PullRequestURL(repo config.Repo, number int) string {
+	return fmt.Sprintf("%s/%s/%s/-/merge_requests/%d", c.baseURL, repo.RepoOwner, repo.RepoName, number)
+}
+
+// accessLevel returns the access level of "user" in "$owner/$repo" including inherited memberships.
+func (c *Client) accessLevel(owner, repo, user string) (int, error) {
+	var members []struct {
+		Username    string `json:"username"`
+		AccessLevel int    `json:"access_level"`
+	}
+	p := projectPath(config.Repo{RepoOwner: owner, RepoName: repo}) + "/members/all"
+	if err := c.get(p, url.Values{"query": {user}}, &members); err != nil {
+		return 0, err
+	}
+	for _, m := range members {
+		if m.Username == user {
+			return m.AccessLevel, nil
+		}
+	}
+	return 0, nil
+}
+
+// Readable determines if "user" is a member of "$owner/$repo" with Reporter or higher access level.
+func (c *Client) Readable(owner, repo, user string) bool {
+	l, err := c.accessLevel(owner, repo, user)
+	if err != nil {
+		glog.Errorf("Failed to get access level of %s in %s/%s: %v", user, owner, repo, err)
+		return false
+	}
+	return l >= accessLevelReporter
+}
+
+// Deployable determines if "user" is a member of "$owner/$repo" with Developer or higher access level.
+func (c *Client) Deployable(owner, repo, user string) bool {
+	l, err := c.accessLevel(owner, repo, user)
+	if err != nil {
+		glog.Errorf("Failed to get access level of %s in %s/%s: %v", user, owner, repo, err)
+		return false
+	}
+	return l >= accessLevelDeveloper
+}
@@ -0,0 +1,116 @@
+package gitlab
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/gengo/goship/lib/config"
+	"github.com/gengo/goship/lib/revision"
+	"github.com/gengo/goship/lib/scm"
+	"golang.org/x/net/context"
+)
+
+var testRepo = config.Repo{RepoOwner: "group", RepoName: "project"}
+
+func newTestServer(t *testing.T) *httptest.Server {
+	responses := map[string]string{
+		"/api/v4/projects/group%2Fproject/repository/commits?per_page=1&ref_name=master": `[{"id": "abc123", "message": "latest"}]`,
+		"/api/v4/projects/group%2Fproject/repository/commits/abc123":                     `{"id": "abc123", "message": "latest"}`,
+		"/api/v4/projects/group%2Fproject/repository/compare?from=abc000&to=abc123": `{"commits": [
+			{"id": "abc100", "message": "first", "author_name": "alice"},
+			{"id": "abc123", "message": "latest", "author_name": "bob"}
+		]}`,
+		"/api/v4/projects/group%2Fproject/repository/commits/abc123/statuses?per_page=100": `[
+			{"name": "build", "status": "success", "target_url": "https://ci.example.com/1"},
+			{"name": "test", "status": "running"}
+		]`,
+		"/api/v4/projects/group%2Fproject/members/all?query=reporter":  `[{"username": "reporter", "access_level": 20}]`,
+		"/api/v4/projects/group%2Fproject/members/all?query=developer": `[{"username": "developer", "access_level": 30}]`,
+		"/api/v4/projects/group%2Fproject/members/all?query=stranger":  `[]`,
+	}
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if got, want := r.Header.Get("PRIVATE-TOKEN"), "token"; got != want {
+			t.Errorf("PRIVATE-TOKEN = %q; want %q", got, want)
+		}
+		resp, ok := responses[r.URL.RequestURI()]
+		if !ok {
+			t.Errorf("unexpected request %s", r.URL.RequestURI())
+			http.NotFound(w, r)
+			return
+		}
+		fmt.Fprint(w, resp)
+	}))
+}
+
+func TestClient(t *testing.T) {
+	s := newTestServer(t)
+	defer s.Close()
+	c := New(s.URL, "token")
+	ctx := context.Background()
+
+	rev, err := c.Latest(ctx, testRepo, "master")
+	if err != nil {
+		t.Errorf("c.Latest(ctx, %#v, %q) failed with %v; want success", testRepo, "master", err)
+	} else if got, want := rev, revision.Revision("abc123"); got != want {
+		t.Errorf("c.Latest(ctx, %#v, %q) = %q; want %q", testRepo, "master", got, want)
+	}
+
+	msg, err := c.Message(ctx, testRepo, "abc123")
+	if err != nil {
+		t.Errorf("c.Message(ctx, %#v, %q) failed with %v; want success", testRepo, "abc123", err)
+	} else if got, want := msg, "latest"; got != want {
+		t.Errorf("c.Message(ctx, %#v, %q) = %q; want %q", testRepo, "abc123", got, want)
+	}
+
+	commits, err := c.Commits(ctx, testRepo, "abc000", "abc123")
+	if err != nil {
+		t.Errorf("c.Commits(ctx, %#v, %q, %q) failed with %v; want success", testRepo, "abc000", "abc123", err)
+	}
+	want := []scm.Commit{
+		{Revision: "abc100", Message: "first", Author: "alice"},
+		{Revision: "abc123", Message: "latest", Author: "bob"},
+	}
+	if !reflect.DeepEqual(commits, want) {
+		t.Errorf("c.Commits(ctx, %#v, %q, %q) = %#v; want %#v", testRepo, "abc000", "abc123", commits, want)
+	}
+
+	statuses, err := c.Statuses(ctx, testRepo, "abc123")
+	if err != nil {
+		t.Errorf("c.Statuses(ctx, %#v, %q) failed with %v; want success", testRepo, "abc123", err)
+	}
+	wantStatuses := []scm.Status{
+		{Context: "build", State: scm.StatusSuccess, URL: "https://ci.example.com/1"},
+		{Context: "test", State: scm.StatusPending},
+	}
+	if !reflect.DeepEqual(statuses, wantStatuses) {
+		t.Errorf("c.Statuses(ctx, %#v, %q) = %#v; want %#v", testRepo, "abc123", statuses, wantStatuses)
+	}
+
+	if got, want := c.CompareURL(testRepo, "abc000", "abc123"), s.URL+"/group/project/-/compare/abc000...abc123"; got != want {
+		t.Errorf("c.CompareURL(%#v, %q, %q) = %q; want %q", testRepo, "abc000", "abc123", got, want)
+	}
+}
+
+func TestAccessControl(t *testing.T) {
+	s := newTestServer(t)
+	defer s.Close()
+	c := New(s.URL, "token")
+	for _, spec := range []struct {
+		user                 string
+		readable, deployable bool
+	}{
+		{user: "reporter", readable: true, deployable: false},
+		{user: "developer", readable: true, deployable: true},
+		{user: "stranger", readable: false, deployable: false},
+	} {
+		if got, want := c.Readable("group", "project", spec.user), spec.readable; got != want {
+			t.Errorf("c.Readable(%q, %q, %q) = %v; want %v", "group", "project", spec.user, got, want)
+		}
+		if got, want := c.Deployable("group", "project", spec.user), spec.deployable; got != want {
+			t.Errorf("c.Deployable(%q, %q, %q) = %v; want %v", "group", "project", spec.user, got, want)
+		}
+	}
+}
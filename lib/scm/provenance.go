@@ -0,0 +1,70 @@
+package scm
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/gengo/goship/lib/config"
+	"github.com/gengo/goship/lib/revision"
+	"golang.org/x/net/context"
+)
+
+// Provenance is the result of checking that a revision comes from a protected branch.
+type Provenance struct {
+	Revision revision.Revision `json:"revision"`
+	Verified bool              `json:"verified"`
+	// Branch is the protected branch which contains Revision if verified.
+	Branch string `json:"branch,omitempty"`
+	// Reason describes why Revision is not verified.
+	Reason string `json:"reason,omitempty"`
+}
+
+// CheckProvenance checks that "rev" is reachable from any of "branches" in "repo",
+// i.e. it has been merged into a protected branch rather than only pushed to another branch or a fork.
+func CheckProvenance(ctx context.Context, c Client, repo config.Repo, branches []string, rev revision.Revision) Provenance {
+	p := Provenance{Revision: rev}
+	var errs []string
+	for _, b := range branches {
+		// commits reachable from "rev" but not from the branch
+		commits, err := c.Commits(ctx, repo, revision.Revision(b), rev)
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("%s: %v", b, err))
+			continue
+		}
+		if len(commits) == 0 {
+			p.Verified, p.Branch = true, b
+			return p
+		}
+	}
+	p.Reason = fmt.Sprintf("%s is not reachable from %s", rev.Short(), strings.Join(branches, ", "))
+	if len(errs) > 0 {
+		p.Reason = fmt.Sprintf("%s (%s)", p.Reason, strings.Join(errs, "; "))
+	}
+	return p
+}
+
+// SourceRevision returns the revision in the source repository of "proj" from which "rev" is built, or empty if unknown.
+// "src" is the source revision given by the user, if any.
+func SourceRevision(proj config.Project, rev, src revision.Revision) revision.Revision {
+	if src != "" || proj.RepoType != config.RepoTypeGithub {
+		return src
+	}
+	return rev
+}
+
+// VerifyProvenance checks that "rev" of "proj" is reachable from the protected branches of "env" with the client in "clients" for the project.
+// "rev" must be a revision in the source repository of the project.
+// Any revisions are verified if the environment has no protected branches.
+func VerifyProvenance(ctx context.Context, clients map[config.SCMType]Client, proj config.Project, env config.Environment, rev revision.Revision) Provenance {
+	if len(env.ProtectedBranches) == 0 {
+		return Provenance{Revision: rev, Verified: true}
+	}
+	if rev == "" {
+		return Provenance{Reason: "source revision is unknown"}
+	}
+	c, ok := clients[proj.SCM]
+	if !ok {
+		return Provenance{Revision: rev, Reason: fmt.Sprintf("scm %q not configured", proj.SCM)}
+	}
+	return CheckProvenance(ctx, c, proj.SourceRepo(), env.ProtectedBranches, rev)
+}
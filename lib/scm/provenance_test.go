@@ -0,0 +1,62 @@
+package scm
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/gengo/goship/lib/config"
+	"github.com/gengo/goship/lib/revision"
+	"golang.org/x/net/context"
+)
+
+// branchClient is a Client whose branches contain the listed revisions.
+type branchClient struct {
+	Client
+	branches map[string][]revision.Revision
+}
+
+func (c branchClient) Commits(ctx context.Context, repo config.Repo, from, to revision.Revision) ([]Commit, error) {
+	revs, ok := c.branches[string(from)]
+	if !ok {
+		return nil, errors.New("no such branch")
+	}
+	for _, rev := range revs {
+		if rev == to {
+			return nil, nil
+		}
+	}
+	return []Commit{{Revision: to}}, nil
+}
+
+func TestVerifyProvenance(t *testing.T) {
+	clients := map[config.SCMType]Client{
+		config.SCMGithub: branchClient{branches: map[string][]revision.Revision{
+			"master":  {"abc123"},
+			"release": {"abc123", "def456"},
+		}},
+	}
+	proj := config.Project{Name: "proj", SCM: config.SCMGithub}
+	for _, tt := range []struct {
+		branches []string
+		rev      revision.Revision
+		scm      config.SCMType
+		want     Provenance
+	}{
+		{rev: "fork000", want: Provenance{Revision: "fork000", Verified: true}},
+		{branches: []string{"master"}, rev: "abc123", want: Provenance{Revision: "abc123", Verified: true, Branch: "master"}},
+		{branches: []string{"master", "release"}, rev: "def456", want: Provenance{Revision: "def456", Verified: true, Branch: "release"}},
+		{branches: []string{"master"}, rev: "fork000", want: Provenance{Revision: "fork000", Reason: "fork000 is not reachable from master"}},
+		{branches: []string{"nobranch"}, rev: "abc123", want: Provenance{Revision: "abc123", Reason: "abc123 is not reachable from nobranch (nobranch: no such branch)"}},
+		{branches: []string{"master"}, want: Provenance{Reason: "source revision is unknown"}},
+		{branches: []string{"master"}, rev: "abc123", scm: config.SCMGitLab, want: Provenance{Revision: "abc123", Reason: `scm "gitlab" not configured`}},
+	} {
+		p := proj
+		if tt.scm != "" {
+			p.SCM = tt.scm
+		}
+		env := config.Environment{Name: "production", ProtectedBranches: tt.branches}
+		if got := VerifyProvenance(context.Background(), clients, p, env, tt.rev); got != tt.want {
+			t.Errorf("VerifyProvenance(ctx, clients, proj, env, %q) = %#v; want %#v; branches = %q", tt.rev, got, tt.want, tt.branches)
+		}
+	}
+}
@@ -0,0 +1,73 @@
+// Package scm provides an abstraction of source code management services like GitHub, GitLab or Bitbucket.
+package scm
+
+import (
+	"github.com/gengo/goship/lib/config"
+	"github.com/gengo/goship/lib/revision"
+	"golang.org/x/net/context"
+)
+
+// Commit is a commit in a source repository.
+type Commit struct {
+	Revision revision.Revision `json:"revision"`
+	Message  string            `json:"message"`
+	Author   string            `json:"author"`
+}
+
+// File is a file changed between two revisions.
+type File struct {
+	Path string `json:"path"`
+	// Status is one of "added", "modified", "removed" or "renamed".
+	Status string `json:"status"`
+	// Additions and Deletions are the numbers of changed lines if known.
+	Additions int `json:"additions"`
+	Deletions int `json:"deletions"`
+}
+
+// Comparison is the difference between two revisions.
+type Comparison struct {
+	// Commits are reachable from the newer revision but not from the older one, from the oldest.
+	Commits []Commit
+	Files   []File
+}
+
+// A StatusState is the state of a CI status of a commit.
+type StatusState string
+
+const (
+	// StatusSuccess means the build or the check passed.
+	StatusSuccess = StatusState("success")
+	// StatusPending means the build or the check has not finished yet.
+	StatusPending = StatusState("pending")
+	// StatusFailure means the build or the check failed, errored or was canceled.
+	StatusFailure = StatusState("failure")
+)
+
+// Status is a CI status of a commit, e.g. the result of a build.
+type Status struct {
+	// Context identifies the CI job which reported the status, e.g. "ci/travis" or "build".
+	Context string
+	State   StatusState
+	// URL is the URL of a web page which shows the details, if any.
+	URL string
+}
+
+// Client provides access to source repositories in a source code management service.
+type Client interface {
+	// Latest returns the latest revision in "ref" of "repo".
+	Latest(ctx context.Context, repo config.Repo, ref string) (revision.Revision, error)
+	// Message returns the commit message of "rev" in "repo".
+	Message(ctx context.Context, repo config.Repo, rev revision.Revision) (string, error)
+	// Commits returns the commits which are reachable from "to" but not from "from".
+	Commits(ctx context.Context, repo config.Repo, from, to revision.Revision) ([]Commit, error)
+	// Compare returns the commits which are reachable from "to" but not from "from", and the files changed between them.
+	Compare(ctx context.Context, repo config.Repo, from, to revision.Revision) (Comparison, error)
+	// Statuses returns the latest CI status of each context of "rev" in "repo".
+	Statuses(ctx context.Context, repo config.Repo, rev revision.Revision) ([]Status, error)
+	// CommitURL returns the URL of a web page which shows "rev".
+	CommitURL(repo config.Repo, rev revision.Revision) string
+	// CompareURL returns the URL of a web page which shows the difference between "from" and "to".
+	CompareURL(repo config.Repo, from, to revision.Revision) string
+	// PullRequestURL returns the URL of a web page which shows the pull request, or the merge request, "number".
+	PullRequestURL(repo config.Repo, number int) string
+}
@@ -0,0 +1,78 @@
+package scm
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/gengo/goship/lib/config"
+	"github.com/gengo/goship/lib/revision"
+	"golang.org/x/net/context"
+)
+
+// BuildStatus is the result of checking the required CI statuses of a revision.
+type BuildStatus struct {
+	Revision revision.Revision `json:"revision"`
+	// State is StatusSuccess only if all the required contexts succeeded.
+	// It is StatusFailure if any of them failed, and StatusPending otherwise.
+	State StatusState `json:"state"`
+	// Failed and Pending are the required contexts which failed and which have not succeeded yet.
+	Failed  []string `json:"failed,omitempty"`
+	Pending []string `json:"pending,omitempty"`
+	// Reason describes why State is not StatusSuccess.
+	Reason string `json:"reason,omitempty"`
+}
+
+// CheckStatuses checks that all the "required" contexts of CI statuses of "rev" in "repo" succeeded.
+// Required contexts which have not reported any status are pending.
+func CheckStatuses(ctx context.Context, c Client, repo config.Repo, required []string, rev revision.Revision) BuildStatus {
+	b := BuildStatus{Revision: rev}
+	statuses, err := c.Statuses(ctx, repo, rev)
+	if err != nil {
+		b.State, b.Reason = StatusPending, fmt.Sprintf("failed to get statuses of %s: %v", rev.Short(), err)
+		return b
+	}
+	latest := make(map[string]StatusState)
+	for _, st := range statuses {
+		// statuses are listed from the newest
+		if _, ok := latest[st.Context]; !ok {
+			latest[st.Context] = st.State
+		}
+	}
+	for _, name := range required {
+		switch latest[name] {
+		case StatusSuccess:
+		case StatusFailure:
+			b.Failed = append(b.Failed, name)
+		default:
+			b.Pending = append(b.Pending, name)
+		}
+	}
+	switch {
+	case len(b.Failed) > 0:
+		b.State = StatusFailure
+		b.Reason = fmt.Sprintf("%s failed for %s", strings.Join(b.Failed, ", "), rev.Short())
+	case len(b.Pending) > 0:
+		b.State = StatusPending
+		b.Reason = fmt.Sprintf("%s not succeeded yet for %s", strings.Join(b.Pending, ", "), rev.Short())
+	default:
+		b.State = StatusSuccess
+	}
+	return b
+}
+
+// VerifyStatuses checks that the CI statuses required by "proj" succeeded for "rev" with the client in "clients" for the project.
+// "rev" must be a revision in the source repository of the project.
+// Any revisions succeed if the project requires no statuses.
+func VerifyStatuses(ctx context.Context, clients map[config.SCMType]Client, proj config.Project, rev revision.Revision) BuildStatus {
+	if len(proj.RequiredStatuses) == 0 {
+		return BuildStatus{Revision: rev, State: StatusSuccess}
+	}
+	if rev == "" {
+		return BuildStatus{State: StatusPending, Reason: "source revision is unknown"}
+	}
+	c, ok := clients[proj.SCM]
+	if !ok {
+		return BuildStatus{Revision: rev, State: StatusPending, Reason: fmt.Sprintf("scm %q not configured", proj.SCM)}
+	}
+	return CheckStatuses(ctx, c, proj.SourceRepo(), proj.RequiredStatuses, rev)
+}
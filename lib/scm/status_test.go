@@ -0,0 +1,54 @@
+package scm
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/gengo/goship/lib/config"
+	"github.com/gengo/goship/lib/revision"
+	"golang.org/x/net/context"
+)
+
+// statusClient is a Client which reports the listed statuses of revisions.
+type statusClient struct {
+	Client
+	statuses map[revision.Revision][]Status
+}
+
+func (c statusClient) Statuses(ctx context.Context, repo config.Repo, rev revision.Revision) ([]Status, error) {
+	statuses, ok := c.statuses[rev]
+	if !ok {
+		return nil, errors.New("no such commit")
+	}
+	return statuses, nil
+}
+
+func TestVerifyStatuses(t *testing.T) {
+	clients := map[config.SCMType]Client{
+		config.SCMGithub: statusClient{statuses: map[revision.Revision][]Status{
+			"green00": {{Context: "build", State: StatusSuccess}, {Context: "lint", State: StatusFailure}},
+			"red0000": {{Context: "build", State: StatusFailure}, {Context: "build", State: StatusSuccess}},
+			"retried": {{Context: "build", State: StatusSuccess}, {Context: "build", State: StatusFailure}},
+			"running": {{Context: "build", State: StatusPending}},
+		}},
+	}
+	for _, tt := range []struct {
+		required []string
+		rev      revision.Revision
+		want     BuildStatus
+	}{
+		{rev: "red0000", want: BuildStatus{Revision: "red0000", State: StatusSuccess}},
+		{required: []string{"build"}, rev: "green00", want: BuildStatus{Revision: "green00", State: StatusSuccess}},
+		{required: []string{"build"}, rev: "retried", want: BuildStatus{Revision: "retried", State: StatusSuccess}},
+		{required: []string{"build"}, rev: "red0000", want: BuildStatus{Revision: "red0000", State: StatusFailure, Failed: []string{"build"}, Reason: "build failed for red0000"}},
+		{required: []string{"build", "test"}, rev: "running", want: BuildStatus{Revision: "running", State: StatusPending, Pending: []string{"build", "test"}, Reason: "build, test not succeeded yet for running"}},
+		{required: []string{"build"}, rev: "unknown", want: BuildStatus{Revision: "unknown", State: StatusPending, Reason: "failed to get statuses of unknown: no such commit"}},
+		{required: []string{"build"}, want: BuildStatus{State: StatusPending, Reason: "source revision is unknown"}},
+	} {
+		proj := config.Project{Name: "proj", SCM: config.SCMGithub, RequiredStatuses: tt.required}
+		if got := VerifyStatuses(context.Background(), clients, proj, tt.rev); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("VerifyStatuses(ctx, clients, proj, %q) = %#v; want %#v; required = %q", tt.rev, got, tt.want, tt.required)
+		}
+	}
+}
@@ -0,0 +1,68 @@
+package secrets
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"time"
+
+	"github.com/gengo/goship/lib/awsauth"
+	"github.com/gengo/goship/lib/config"
+	"golang.org/x/net/context"
+)
+
+// AWSSecretsManager fetches secrets from AWS Secrets Manager.
+// Credentials are read from the environment variables or the IAM role of the instance as described in awsauth.LoadCredentials.
+type AWSSecretsManager struct {
+	// Endpoint is the URL of the API. The one of the region of the secret is used if empty.
+	Endpoint string
+}
+
+// Fetch implements Provider.
+func (a AWSSecretsManager) Fetch(ctx context.Context, s config.Secret) (string, error) {
+	creds, err := awsauth.LoadCredentials()
+	if err != nil {
+		return "", err
+	}
+	endpoint := a.Endpoint
+	if endpoint == "" {
+		endpoint = fmt.Sprintf("https://secretsmanager.%s.amazonaws.com/", s.Region)
+	}
+	body, err := json.Marshal(map[string]string{"SecretId": s.Path})
+	if err != nil {
+		return "", err
+	}
+	req, err := http.NewRequest("POST", endpoint, bytes.NewReader(body))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-amz-json-1.1")
+	req.Header.Set("X-Amz-Target", "secretsmanager.GetSecretValue")
+	awsauth.Sign(req, body, creds, s.Region, "secretsmanager", time.Now())
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		// AWS describes the error in the body, e.g. ResourceNotFoundException, which never contains the value.
+		msg, _ := ioutil.ReadAll(resp.Body)
+		return "", fmt.Errorf("unexpected HTTP status %d from secrets manager: %s", resp.StatusCode, msg)
+	}
+	var secret struct {
+		SecretString *string
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&secret); err != nil {
+		return "", err
+	}
+	if secret.SecretString == nil {
+		return "", fmt.Errorf("binary secrets are not supported")
+	}
+	if s.Key == "" {
+		return *secret.SecretString, nil
+	}
+	return field(json.RawMessage(*secret.SecretString), s.Key)
+}
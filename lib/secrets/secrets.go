@@ -0,0 +1,107 @@
+// Package secrets fetches secrets given to deploy commands from secret stores, and redacts them from outputs.
+package secrets
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gengo/goship/lib/config"
+	"golang.org/x/net/context"
+)
+
+// Mask replaces the values of secrets in outputs.
+const Mask = "[REDACTED]"
+
+// httpClient is used to call the APIs of the secret stores.
+var httpClient = &http.Client{Timeout: 30 * time.Second}
+
+// Provider fetches secrets from a secret store.
+type Provider interface {
+	// Fetch returns the value of "s".
+	Fetch(ctx context.Context, s config.Secret) (string, error)
+}
+
+// Providers maps the types of secret stores to their providers.
+var Providers = map[config.SecretProvider]Provider{
+	config.SecretVault:   Vault{},
+	config.SecretAWS:     AWSSecretsManager{},
+	config.SecretEnvFile: EnvFile{},
+}
+
+// Resolve fetches "secrets" and returns them as environment variables in the form of KEY=VALUE, and their values.
+func Resolve(ctx context.Context, secrets []config.Secret) (env, values []string, err error) {
+	for _, s := range secrets {
+		p, ok := Providers[s.Provider]
+		if !ok {
+			return nil, nil, fmt.Errorf("secret provider %q not available", s.Provider)
+		}
+		v, err := p.Fetch(ctx, s)
+		if err != nil {
+			// The error never contains the value.
+			return nil, nil, fmt.Errorf("failed to fetch secret %s from %s: %v", s.Env, s.Provider, err)
+		}
+		env = append(env, s.Env+"="+v)
+		values = append(values, v)
+	}
+	return env, values, nil
+}
+
+// Redactor replaces values of secrets in lines of outputs with Mask.
+// A nil Redactor redacts nothing.
+type Redactor struct {
+	// values are sorted longest first so that a value containing another is redacted as a whole.
+	values []string
+}
+
+// NewRedactor returns a Redactor of "values".
+// Each line of multi-line values is redacted separately because outputs are redacted line by line.
+func NewRedactor(values []string) *Redactor {
+	r := new(Redactor)
+	for _, v := range values {
+		for _, l := range strings.Split(v, "\n") {
+			if l = strings.TrimSpace(l); l != "" {
+				r.values = append(r.values, l)
+			}
+		}
+	}
+	sort.Sort(byLength(r.values))
+	return r
+}
+
+// Redact returns "line" whose secrets are replaced with Mask.
+func (r *Redactor) Redact(line string) string {
+	if r == nil {
+		return line
+	}
+	for _, v := range r.values {
+		line = strings.Replace(line, v, Mask, -1)
+	}
+	return line
+}
+
+type byLength []string
+
+func (s byLength) Len() int           { return len(s) }
+func (s byLength) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
+func (s byLength) Less(i, j int) bool { return len(s[i]) > len(s[j]) }
+
+// field returns the string "key" in the JSON object "data".
+func field(data json.RawMessage, key string) (string, error) {
+	var fields map[string]interface{}
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return "", fmt.Errorf("secret is not a JSON object")
+	}
+	v, ok := fields[key]
+	if !ok {
+		return "", fmt.Errorf("no key %s in the secret", key)
+	}
+	s, ok := v.(string)
+	if !ok {
+		return "", fmt.Errorf("key %s in the secret is not a string", key)
+	}
+	return s, nil
+}
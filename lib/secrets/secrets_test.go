@@ -0,0 +1,130 @@
+package secrets
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"reflect"
+	"testing"
+
+	"github.com/gengo/goship/lib/config"
+	"golang.org/x/net/context"
+)
+
+func TestRedactor(t *testing.T) {
+	r := NewRedactor([]string{"s3cr3t", "s3cr3t-longer", "line1\nline2", ""})
+	for _, spec := range []struct {
+		line, want string
+	}{
+		{line: "token=s3cr3t", want: "token=" + Mask},
+		{line: "token=s3cr3t-longer and s3cr3t", want: "token=" + Mask + " and " + Mask},
+		{line: "key: line2", want: "key: " + Mask},
+		{line: "nothing to hide", want: "nothing to hide"},
+	} {
+		if got := r.Redact(spec.line); got != spec.want {
+			t.Errorf("r.Redact(%q) = %q; want %q", spec.line, got, spec.want)
+		}
+	}
+	var nilRedactor *Redactor
+	if got := nilRedactor.Redact("s3cr3t"); got != "s3cr3t" {
+		t.Errorf("nil.Redact(%q) = %q; want it as is", "s3cr3t", got)
+	}
+}
+
+func TestVault(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if got, want := r.Header.Get("X-Vault-Token"), "root"; got != want {
+			t.Errorf("X-Vault-Token = %q; want %q", got, want)
+		}
+		switch r.URL.Path {
+		case "/v1/secret/data/my-project":
+			fmt.Fprint(w, `{"data": {"data": {"API_TOKEN": "v2-token"}, "metadata": {"version": 3}}}`)
+		case "/v1/kv/my-project":
+			fmt.Fprint(w, `{"data": {"token": "v1-token"}}`)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer s.Close()
+
+	v := Vault{Address: s.URL, Token: "root"}
+	for _, spec := range []struct {
+		secret config.Secret
+		want   string
+	}{
+		{secret: config.Secret{Env: "API_TOKEN", Path: "secret/data/my-project"}, want: "v2-token"},
+		{secret: config.Secret{Env: "API_TOKEN", Path: "kv/my-project", Key: "token"}, want: "v1-token"},
+	} {
+		got, err := v.Fetch(context.Background(), spec.secret)
+		if err != nil {
+			t.Errorf("v.Fetch(ctx, %#v) failed with %v; want success", spec.secret, err)
+			continue
+		}
+		if got != spec.want {
+			t.Errorf("v.Fetch(ctx, %#v) = %q; want %q", spec.secret, got, spec.want)
+		}
+	}
+	if _, err := v.Fetch(context.Background(), config.Secret{Env: "API_TOKEN", Path: "secret/data/unknown"}); err == nil {
+		t.Errorf("v.Fetch(ctx, unknown) succeeded; want failure")
+	}
+}
+
+func TestAWSSecretsManager(t *testing.T) {
+	for k, v := range map[string]string{"AWS_ACCESS_KEY_ID": "AKIDEXAMPLE", "AWS_SECRET_ACCESS_KEY": "secret"} {
+		orig := os.Getenv(k)
+		os.Setenv(k, v)
+		defer os.Setenv(k, orig)
+	}
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if got, want := r.Header.Get("X-Amz-Target"), "secretsmanager.GetSecretValue"; got != want {
+			t.Errorf("X-Amz-Target = %q; want %q", got, want)
+		}
+		if r.Header.Get("Authorization") == "" {
+			t.Errorf("request not signed")
+		}
+		fmt.Fprint(w, `{"Name": "my-project", "SecretString": "{\"password\": \"p4ss\"}"}`)
+	}))
+	defer s.Close()
+
+	a := AWSSecretsManager{Endpoint: s.URL}
+	secret := config.Secret{Env: "DB_PASSWORD", Provider: config.SecretAWS, Path: "my-project", Key: "password", Region: "us-east-1"}
+	if got, err := a.Fetch(context.Background(), secret); err != nil || got != "p4ss" {
+		t.Errorf("a.Fetch(ctx, %#v) = %q, %v; want %q, nil", secret, got, err, "p4ss")
+	}
+	secret.Key = ""
+	if got, err := a.Fetch(context.Background(), secret); err != nil || got != `{"password": "p4ss"}` {
+		t.Errorf("a.Fetch(ctx, %#v) = %q, %v; want the whole secret string", secret, got, err)
+	}
+}
+
+func TestResolveEnvFile(t *testing.T) {
+	f, err := ioutil.TempFile("", "goship-secrets")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+	fmt.Fprint(f, "# tokens\nexport API_TOKEN=\"abc def\"\nDB_PASSWORD=p4ss\n")
+	f.Close()
+
+	secrets := []config.Secret{
+		{Env: "API_TOKEN", Provider: config.SecretEnvFile, Path: f.Name()},
+		{Env: "PGPASSWORD", Provider: config.SecretEnvFile, Path: f.Name(), Key: "DB_PASSWORD"},
+	}
+	env, values, err := Resolve(context.Background(), secrets)
+	if err != nil {
+		t.Fatalf("Resolve(ctx, %#v) failed with %v; want success", secrets, err)
+	}
+	if want := []string{"API_TOKEN=abc def", "PGPASSWORD=p4ss"}; !reflect.DeepEqual(env, want) {
+		t.Errorf("env = %q; want %q", env, want)
+	}
+	if want := []string{"abc def", "p4ss"}; !reflect.DeepEqual(values, want) {
+		t.Errorf("values = %q; want %q", values, want)
+	}
+
+	missing := []config.Secret{{Env: "MISSING", Provider: config.SecretEnvFile, Path: f.Name()}}
+	if _, _, err := Resolve(context.Background(), missing); err == nil {
+		t.Errorf("Resolve(ctx, %#v) succeeded; want failure", missing)
+	}
+}
@@ -0,0 +1,67 @@
+package secrets
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/gengo/goship/lib/config"
+	"golang.org/x/net/context"
+)
+
+// Vault fetches secrets from the KV secrets engine of HashiCorp Vault, either version 1 or 2.
+// It connects to VAULT_ADDR with VAULT_TOKEN unless Address and Token are given.
+type Vault struct {
+	Address string
+	Token   string
+}
+
+// Fetch implements Provider.
+func (v Vault) Fetch(ctx context.Context, s config.Secret) (string, error) {
+	addr, token := v.Address, v.Token
+	if addr == "" {
+		addr = os.Getenv("VAULT_ADDR")
+	}
+	if token == "" {
+		token = os.Getenv("VAULT_TOKEN")
+	}
+	if addr == "" || token == "" {
+		return "", errors.New("VAULT_ADDR and VAULT_TOKEN must be set")
+	}
+	req, err := http.NewRequest("GET", fmt.Sprintf("%s/v1/%s", strings.TrimSuffix(addr, "/"), strings.TrimPrefix(s.Path, "/")), nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("X-Vault-Token", token)
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("unexpected HTTP status %d from vault", resp.StatusCode)
+	}
+	var secret struct {
+		Data json.RawMessage `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&secret); err != nil {
+		return "", err
+	}
+	// Version 2 of the KV secrets engine nests the data with its metadata.
+	var versioned struct {
+		Data     json.RawMessage `json:"data"`
+		Metadata json.RawMessage `json:"metadata"`
+	}
+	data := secret.Data
+	if err := json.Unmarshal(data, &versioned); err == nil && versioned.Data != nil && versioned.Metadata != nil {
+		data = versioned.Data
+	}
+	key := s.Key
+	if key == "" {
+		key = s.Env
+	}
+	return field(data, key)
+}
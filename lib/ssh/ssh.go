@@ -3,13 +3,21 @@ package ssh
 
 import (
 	"bytes"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"net"
+	"os"
 	"strings"
 
+	"github.com/gengo/goship/lib/config"
+	"github.com/gengo/goship/lib/logging"
 	"github.com/golang/glog"
 	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
 	"golang.org/x/net/context"
 )
 
@@ -19,49 +27,169 @@ const (
 )
 
 type SSH struct {
-	cfg ssh.ClientConfig
+	user string
+	// key is an optional private key.
+	key ssh.Signer
+	// agentSock is the socket of ssh-agent, or empty if the agent is not used.
+	agentSock string
+	// jumpHost is an optional bastion host in "[user@]host[:port]" form.
+	jumpHost string
 }
 
+// New returns an SSH which connects to hosts as configured in "c".
+// It offers the keys in the ssh-agent at SSH_AUTH_SOCK if the agent is running, and then c.KeyFile.
+// c.KeyFile is optional if the agent is running.
+func New(c config.SSH) (SSH, error) {
+	s := SSH{user: c.User, agentSock: os.Getenv("SSH_AUTH_SOCK"), jumpHost: c.JumpHost}
+	if c.KeyFile != "" {
+		key, err := parseKeyFile(c.KeyFile, c.PassphraseEnv)
+		switch {
+		case err == nil:
+			s.key = key
+		case s.agentSock == "":
+			return SSH{}, err
+		default:
+			glog.V(1).Infof("Using only ssh-agent because of failure in loading %s: %v", c.KeyFile, err)
+		}
+	}
+	if s.key == nil && s.agentSock == "" {
+		return SSH{}, errors.New("neither a private key nor ssh-agent is available")
+	}
+	return s, nil
+}
+
+// WithPrivateKeyFile returns an SSH which logs in as "user" with the private key in "fname".
 func WithPrivateKeyFile(user, fname string) (SSH, error) {
+	return New(config.SSH{User: user, KeyFile: fname})
+}
+
+// parseKeyFile reads a private key from "fname".
+// The key is decrypted with the value of environment variable "passphraseEnv" if it is encrypted.
+func parseKeyFile(fname, passphraseEnv string) (ssh.Signer, error) {
 	p, err := ioutil.ReadFile(fname)
 	if err != nil {
-		return SSH{}, err
+		return nil, err
+	}
+	if block, _ := pem.Decode(p); block != nil && x509.IsEncryptedPEMBlock(block) {
+		if passphraseEnv == "" {
+			return nil, fmt.Errorf("%s is encrypted but passphrase_env is not configured", fname)
+		}
+		der, err := x509.DecryptPEMBlock(block, []byte(os.Getenv(passphraseEnv)))
+		if err != nil {
+			return nil, fmt.Errorf("cannot decrypt %s with $%s: %v", fname, passphraseEnv, err)
+		}
+		p = pem.EncodeToMemory(&pem.Block{Type: block.Type, Bytes: der})
+	}
+	return ssh.ParsePrivateKey(p)
+}
+
+// withPort returns "host" with the well-known port of SSH if it does not have a port.
+func withPort(host string) string {
+	// TODO(yugui) Support IPv6 address without port number
+	if !strings.Contains(host, ":") {
+		return net.JoinHostPort(host, fmt.Sprintf("%d", wellKnownPort))
+	}
+	return host
+}
+
+// parseJumpHost splits "s" in "[user@]host[:port]" form into a user and an address.
+// The user is "defaultUser" if omitted.
+func parseJumpHost(s, defaultUser string) (user, addr string) {
+	user = defaultUser
+	if i := strings.LastIndex(s, "@"); i >= 0 {
+		user, s = s[:i], s[i+1:]
+	}
+	return user, withPort(s)
+}
+
+// dial connects to "host", through the jump host if configured.
+// The returned function closes all the connections.
+func (s SSH) dial(host string) (*ssh.Client, func(), error) {
+	var closers []func() error
+	closeAll := func() {
+		for i := len(closers) - 1; i >= 0; i-- {
+			closers[i]()
+		}
+	}
+
+	cfg := ssh.ClientConfig{User: s.user}
+	if s.agentSock != "" {
+		conn, err := net.Dial("unix", s.agentSock)
+		if err != nil {
+			glog.Errorf("Failed to connect to ssh-agent at %s: %v", s.agentSock, err)
+		} else {
+			closers = append(closers, conn.Close)
+			cfg.Auth = append(cfg.Auth, ssh.PublicKeysCallback(agent.NewClient(conn).Signers))
+		}
+	}
+	if s.key != nil {
+		cfg.Auth = append(cfg.Auth, ssh.PublicKeys(s.key))
+	}
+
+	if s.jumpHost == "" {
+		client, err := ssh.Dial("tcp", host, &cfg)
+		if err != nil {
+			closeAll()
+			return nil, nil, err
+		}
+		closers = append(closers, client.Close)
+		return client, closeAll, nil
+	}
+
+	user, addr := parseJumpHost(s.jumpHost, s.user)
+	jcfg := cfg
+	jcfg.User = user
+	jump, err := ssh.Dial("tcp", addr, &jcfg)
+	if err != nil {
+		closeAll()
+		return nil, nil, fmt.Errorf("cannot connect to jump host %s: %v", addr, err)
+	}
+	closers = append(closers, jump.Close)
+	conn, err := jump.Dial("tcp", host)
+	if err != nil {
+		closeAll()
+		return nil, nil, fmt.Errorf("cannot connect to %s through jump host %s: %v", host, addr, err)
 	}
-	s, err := ssh.ParsePrivateKey(p)
+	closers = append(closers, conn.Close)
+	c, chans, reqs, err := ssh.NewClientConn(conn, host, &cfg)
 	if err != nil {
-		return SSH{}, err
-	}
-	return SSH{
-		cfg: ssh.ClientConfig{
-			User: user,
-			Auth: []ssh.AuthMethod{ssh.PublicKeys(s)},
-		},
-	}, nil
+		closeAll()
+		return nil, nil, err
+	}
+	client := ssh.NewClient(c, chans, reqs)
+	closers = append(closers, client.Close)
+	return client, closeAll, nil
 }
 
 // Output runs the given command on the remote server.
 // It returns the stdout outputs of the command.
 func (s SSH) Output(ctx context.Context, host, cmd string) ([]byte, error) {
-	// TODO(yugui) Support IPv6 address without port number
-	if !strings.Contains(host, ":") {
-		host = net.JoinHostPort(host, fmt.Sprintf("%d", wellKnownPort))
+	var outBuf, errBuf bytes.Buffer
+	if err := s.Run(ctx, host, cmd, &outBuf, &errBuf); err != nil {
+		return nil, fmt.Errorf("%v: %s", err, errBuf.String())
 	}
-	glog.V(1).Infof("Running %q in %s@%s", cmd, s.cfg.User, host)
-	client, err := ssh.Dial("tcp", host, &s.cfg)
+	return outBuf.Bytes(), nil
+}
+
+// Run runs the given command on the remote server.
+// It streams the stdout and stderr outputs of the command into "stdout" and "stderr" while the command runs.
+func (s SSH) Run(ctx context.Context, host, cmd string, stdout, stderr io.Writer) error {
+	host = withPort(host)
+	logging.FromContext(ctx).Debugf("Running %q in %s@%s", cmd, s.user, host)
+	client, closeAll, err := s.dial(host)
 	if err != nil {
-		return nil, err
+		return err
 	}
-	defer client.Close()
+	defer closeAll()
 
 	session, err := client.NewSession()
 	if err != nil {
-		return nil, err
+		return err
 	}
 	defer session.Close()
 
-	var outBuf, errBuf bytes.Buffer
-	session.Stdout = &outBuf
-	session.Stderr = &errBuf
+	session.Stdout = stdout
+	session.Stderr = stderr
 
 	done := make(chan struct{})
 	go func() {
@@ -70,7 +198,7 @@ func (s SSH) Output(ctx context.Context, host, cmd string) ([]byte, error) {
 			return
 		case <-ctx.Done():
 			if err := session.Signal(ssh.SIGHUP); err != nil {
-				glog.Errorf("Failed to send SIGHUP to the remote session (%s@%s)", s.cfg.User, host)
+				logging.FromContext(ctx).Errorf("Failed to send SIGHUP to the remote session (%s@%s)", s.user, host)
 			}
 		}
 	}()
@@ -79,7 +207,7 @@ func (s SSH) Output(ctx context.Context, host, cmd string) ([]byte, error) {
 		return session.Run(cmd)
 	}()
 	if err != nil {
-		return nil, fmt.Errorf("cannot run cmd %q on host %s: %v: %s", cmd, host, err, errBuf.String())
+		return fmt.Errorf("cannot run cmd %q on host %s: %v", cmd, host, err)
 	}
-	return outBuf.Bytes(), nil
+	return nil
 }
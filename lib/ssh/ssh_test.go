@@ -0,0 +1,38 @@
+package ssh
+
+import (
+	"os"
+	"testing"
+
+	"github.com/gengo/goship/lib/config"
+)
+
+func TestParseJumpHost(t *testing.T) {
+	for _, spec := range []struct {
+		jumpHost   string
+		user, addr string
+	}{
+		{jumpHost: "jump.example.com", user: "deploy", addr: "jump.example.com:22"},
+		{jumpHost: "bastion@jump.example.com", user: "bastion", addr: "jump.example.com:22"},
+		{jumpHost: "bastion@jump.example.com:2222", user: "bastion", addr: "jump.example.com:2222"},
+		{jumpHost: "10.0.0.1:2222", user: "deploy", addr: "10.0.0.1:2222"},
+	} {
+		user, addr := parseJumpHost(spec.jumpHost, "deploy")
+		if user != spec.user || addr != spec.addr {
+			t.Errorf("parseJumpHost(%q, %q) = %q, %q; want %q, %q", spec.jumpHost, "deploy", user, addr, spec.user, spec.addr)
+		}
+	}
+}
+
+func TestNewWithoutCredentials(t *testing.T) {
+	sock := os.Getenv("SSH_AUTH_SOCK")
+	os.Unsetenv("SSH_AUTH_SOCK")
+	defer os.Setenv("SSH_AUTH_SOCK", sock)
+
+	if _, err := New(config.SSH{User: "deploy"}); err == nil {
+		t.Errorf("New without key file and ssh-agent succeeded; want failure")
+	}
+	if _, err := New(config.SSH{User: "deploy", KeyFile: "/no/such/key"}); err == nil {
+		t.Errorf("New with a missing key file succeeded; want failure")
+	}
+}
@@ -0,0 +1,134 @@
+// Package users deactivates and reactivates users of Goship.
+package users
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/gengo/goship/lib/audit"
+	"github.com/gengo/goship/lib/config"
+	"github.com/golang/glog"
+)
+
+var (
+	// ErrDeactivated is returned when deactivating a user who is already deactivated.
+	ErrDeactivated = errors.New("user already deactivated")
+	// ErrActive is returned when reactivating a user who is not deactivated.
+	ErrActive = errors.New("user not deactivated")
+)
+
+// Offboarding is the result of deactivating a user.
+type Offboarding struct {
+	Deactivation config.Deactivation `json:"deactivation"`
+	// RejectedApprovals is the number of pending approval requests of the user which were rejected.
+	RejectedApprovals int `json:"rejected_approvals"`
+	// ReassignedSchedules is the number of schedules of the user which were reassigned.
+	ReassignedSchedules int `json:"reassigned_schedules"`
+	// ReassignedTo is the user who the schedules were reassigned to.
+	ReassignedTo string `json:"reassigned_to,omitempty"`
+}
+
+// Deactivate deactivates "user" on behalf of the admin "by".
+// The sessions of the user are revoked, the pending approval requests of the user are rejected,
+// and the schedules which the user added are reassigned to "reassignTo", or to "by" if empty.
+// Past records such as deploy logs and the audit log keep the name of the user.
+func Deactivate(ecl config.ETCDInterface, c config.Config, user, by, reason, reassignTo string) (Offboarding, error) {
+	prev, err := config.LoadDeactivation(ecl, user)
+	if err != nil {
+		return Offboarding{}, err
+	}
+	if prev != nil && !prev.Active() {
+		return Offboarding{}, ErrDeactivated
+	}
+	if reassignTo == "" {
+		reassignTo = by
+	}
+	if reassignTo == user {
+		return Offboarding{}, fmt.Errorf("cannot reassign schedules of %s to the user", user)
+	}
+	o := Offboarding{
+		Deactivation: config.Deactivation{User: user, By: by, Reason: reason, Time: time.Now()},
+		ReassignedTo: reassignTo,
+	}
+	// Deactivates first so that the user cannot request more while offboarding.
+	if err := config.StoreDeactivation(ecl, o.Deactivation); err != nil {
+		return Offboarding{}, err
+	}
+
+	var errs []error
+	for _, p := range c.Projects {
+		for _, e := range p.Environments {
+			approvals, err := config.LoadApprovals(ecl, p.Name, e.Name)
+			if err != nil {
+				errs = append(errs, err)
+				continue
+			}
+			for _, a := range approvals {
+				if a.User != user || a.State != config.ApprovalPending {
+					continue
+				}
+				if _, err := config.DecideApproval(ecl, p.Name, e.Name, a.ID, by, false); err != nil && err != config.ErrAlreadyDecided {
+					errs = append(errs, err)
+					continue
+				}
+				o.RejectedApprovals++
+			}
+			n, err := config.ReassignSchedules(ecl, p.Name, e.Name, user, reassignTo)
+			if err != nil {
+				errs = append(errs, err)
+			}
+			o.ReassignedSchedules += n
+		}
+	}
+
+	rec := audit.Record{
+		Actor:  by,
+		Action: audit.ActionUserDeactivated,
+		Result: audit.ResultSuccess,
+		Detail: fmt.Sprintf("deactivated %s: %s; rejected %d approval request(s); reassigned %d schedule(s) to %s", user, reason, o.RejectedApprovals, o.ReassignedSchedules, reassignTo),
+	}
+	if len(errs) > 0 {
+		rec.Result = audit.ResultFailure
+		rec.Detail = fmt.Sprintf("%s; %d error(s) in offboarding: %v", rec.Detail, len(errs), errs[0])
+	}
+	if err := audit.Append(ecl, rec); err != nil {
+		glog.Errorf("Failed to record deactivation of %s in the audit log: %v", user, err)
+	}
+	if len(errs) > 0 {
+		return o, fmt.Errorf("deactivated %s but failed to offboard: %v", user, errs[0])
+	}
+	return o, nil
+}
+
+// Reactivate reactivates "user" on behalf of the admin "by".
+// The sessions which started before the deactivation stay revoked.
+func Reactivate(ecl config.ETCDInterface, user, by string) (config.Deactivation, error) {
+	d, err := config.LoadDeactivation(ecl, user)
+	if err != nil {
+		return config.Deactivation{}, err
+	}
+	if d == nil || d.Active() {
+		return config.Deactivation{}, ErrActive
+	}
+	d.ReactivatedAt, d.ReactivatedBy = time.Now(), by
+	if err := config.StoreDeactivation(ecl, *d); err != nil {
+		return config.Deactivation{}, err
+	}
+	rec := audit.Record{Actor: by, Action: audit.ActionUserReactivated, Result: audit.ResultSuccess, Detail: fmt.Sprintf("reactivated %s", user)}
+	if err := audit.Append(ecl, rec); err != nil {
+		glog.Errorf("Failed to record reactivation of %s in the audit log: %v", user, err)
+	}
+	return *d, nil
+}
+
+// Revoked returns true if the session of "user" which started at "login" is revoked by a deactivation.
+// It fails open, i.e. returns false, if the deactivation cannot be read.
+func Revoked(ecl config.ETCDInterface, user string, login time.Time) bool {
+	d, err := config.LoadDeactivation(ecl, user)
+	if err != nil {
+		glog.Errorf("Failed to load deactivation of %s: %v", user, err)
+		return false
+	}
+	return d != nil && d.Revokes(login)
+}
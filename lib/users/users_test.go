@@ -0,0 +1,87 @@
+package users
+
+import (
+	"testing"
+	"time"
+
+	"github.com/gengo/goship/lib/config"
+)
+
+func TestDeactivate(t *testing.T) {
+	s := config.NewMemoryStore()
+	c := config.Config{Projects: []config.Project{
+		{Name: "proj", Environments: []config.Environment{{Name: "staging"}, {Name: "production"}}},
+	}}
+	now := time.Now()
+	for _, a := range []config.Approval{{User: "alice", To: "abc123"}, {User: "bob", To: "def456"}} {
+		if _, err := config.RequestApproval(s, "proj", "production", a); err != nil {
+			t.Fatalf("config.RequestApproval(s, %q, %q, %#v) failed with %v; want success", "proj", "production", a, err)
+		}
+	}
+	for _, sc := range []config.Schedule{
+		{Cron: "0 3 * * *", Action: config.ScheduleRedeploy, User: "alice", Time: now},
+		{Cron: "0 4 * * *", Action: config.ScheduleRestart, User: "bob", Time: now},
+	} {
+		if _, err := config.AddSchedule(s, "proj", "staging", sc); err != nil {
+			t.Fatalf("config.AddSchedule(s, %q, %q, %#v) failed with %v; want success", "proj", "staging", sc, err)
+		}
+	}
+
+	o, err := Deactivate(s, c, "alice", "admin", "left the company", "carol")
+	if err != nil {
+		t.Fatalf("Deactivate(s, c, %q, %q, %q, %q) failed with %v; want success", "alice", "admin", "left the company", "carol", err)
+	}
+	if got, want := o.RejectedApprovals, 1; got != want {
+		t.Errorf("o.RejectedApprovals = %d; want %d", got, want)
+	}
+	if got, want := o.ReassignedSchedules, 1; got != want {
+		t.Errorf("o.ReassignedSchedules = %d; want %d", got, want)
+	}
+
+	approvals, err := config.LoadApprovals(s, "proj", "production")
+	if err != nil {
+		t.Fatalf("config.LoadApprovals(s, %q, %q) failed with %v; want success", "proj", "production", err)
+	}
+	for _, a := range approvals {
+		want := config.ApprovalPending
+		if a.User == "alice" {
+			want = config.ApprovalRejected
+		}
+		if a.State != want {
+			t.Errorf("state of the approval request by %s = %q; want %q", a.User, a.State, want)
+		}
+	}
+	schedules, err := config.LoadSchedules(s, "proj", "staging")
+	if err != nil {
+		t.Fatalf("config.LoadSchedules(s, %q, %q) failed with %v; want success", "proj", "staging", err)
+	}
+	if got, want := schedules[0].User, "carol"; got != want {
+		t.Errorf("user of the schedule of alice = %q; want %q", got, want)
+	}
+	if got, want := schedules[1].User, "bob"; got != want {
+		t.Errorf("user of the schedule of bob = %q; want %q", got, want)
+	}
+
+	if _, err := Deactivate(s, c, "alice", "admin", "again", ""); err != ErrDeactivated {
+		t.Errorf("Deactivate(s, c, %q, ...) = %v; want %v", "alice", err, ErrDeactivated)
+	}
+	if !Revoked(s, "alice", now) || !Revoked(s, "alice", time.Now()) {
+		t.Errorf("Revoked(s, %q, ...) = false; want true while deactivated", "alice")
+	}
+	if Revoked(s, "bob", now) {
+		t.Errorf("Revoked(s, %q, ...) = true; want false for active users", "bob")
+	}
+
+	if _, err := Reactivate(s, "alice", "admin"); err != nil {
+		t.Fatalf("Reactivate(s, %q, %q) failed with %v; want success", "alice", "admin", err)
+	}
+	if !Revoked(s, "alice", now) {
+		t.Errorf("Revoked(s, %q, %v) = false; want true for sessions before the deactivation", "alice", now)
+	}
+	if Revoked(s, "alice", time.Now()) {
+		t.Errorf("Revoked(s, %q, now) = true; want false for new sessions after reactivation", "alice")
+	}
+	if _, err := Reactivate(s, "alice", "admin"); err != ErrActive {
+		t.Errorf("Reactivate(s, %q, %q) = %v; want %v", "alice", "admin", err, ErrActive)
+	}
+}
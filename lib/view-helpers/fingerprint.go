@@ -0,0 +1,128 @@
+package viewhelpers
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// fingerprintLen is the number of hex digits of the hash of the content in fingerprinted file names.
+	fingerprintLen = 10
+	// immutableCacheControl lets browsers cache fingerprinted files for a year without revalidating them,
+	// because their names change with their contents.
+	immutableCacheControl = "public, max-age=31536000, immutable"
+)
+
+// hashEntry is the cached hash of the content of a file.
+type hashEntry struct {
+	modTime time.Time
+	size    int64
+	hash    string
+}
+
+var (
+	hashesMu sync.Mutex
+	// hashes caches the hashes of files by their paths until the files are modified.
+	hashes = make(map[string]hashEntry)
+)
+
+// Fingerprint returns "name" with the hash of "content" inserted before its extension, e.g. "styles.0123456789.css" for "styles.css".
+func Fingerprint(name string, content []byte) string {
+	return fingerprinted(name, contentHash(content))
+}
+
+func contentHash(content []byte) string {
+	sum := sha256.Sum256(content)
+	return hex.EncodeToString(sum[:])[:fingerprintLen]
+}
+
+func fingerprinted(name, hash string) string {
+	ext := path.Ext(name)
+	return strings.TrimSuffix(name, ext) + "." + hash + ext
+}
+
+// splitFingerprint returns the original name of the fingerprinted "name" and its hash, or false if "name" is not fingerprinted.
+func splitFingerprint(name string) (string, string, bool) {
+	ext := path.Ext(name)
+	stem := strings.TrimSuffix(name, ext)
+	hash := path.Ext(stem)
+	if len(hash) != fingerprintLen+1 {
+		return "", "", false
+	}
+	if _, err := hex.DecodeString(hash[1:]); err != nil {
+		return "", "", false
+	}
+	return strings.TrimSuffix(stem, hash) + ext, hash[1:], true
+}
+
+// fileHash returns the hash in fingerprints of the content of the file at "fp".
+func fileHash(fp string) (string, error) {
+	fi, err := os.Stat(fp)
+	if err != nil {
+		return "", err
+	}
+	hashesMu.Lock()
+	e, ok := hashes[fp]
+	hashesMu.Unlock()
+	if ok && e.modTime.Equal(fi.ModTime()) && e.size == fi.Size() {
+		return e.hash, nil
+	}
+	buf, err := ioutil.ReadFile(fp)
+	if err != nil {
+		return "", err
+	}
+	hash := contentHash(buf)
+	hashesMu.Lock()
+	hashes[fp] = hashEntry{modTime: fi.ModTime(), size: fi.Size(), hash: hash}
+	hashesMu.Unlock()
+	return hash, nil
+}
+
+// fingerprintFile returns the base name of the file at "fp" with the hash of its content, or the base name as is if it cannot be read.
+func fingerprintFile(fp string) string {
+	hash, err := fileHash(fp)
+	if err != nil {
+		return filepath.Base(fp)
+	}
+	return fingerprinted(filepath.Base(fp), hash)
+}
+
+// Handler returns a new http.Handler which serves the static files under "prefix" of URLs.
+// Fingerprinted names of files, which Templates refers to, are cached by browsers as immutable.
+// Other names are served as they are and revalidated by browsers as usual.
+func (a Assets) Handler(prefix string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		name := path.Clean("/" + strings.TrimPrefix(r.URL.Path, prefix))
+		fp := filepath.Join(a.dir, filepath.FromSlash(name))
+		if _, err := os.Stat(fp); err == nil {
+			http.ServeFile(w, r, fp)
+			return
+		}
+		orig, hash, ok := splitFingerprint(name)
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		fp = filepath.Join(a.dir, filepath.FromSlash(orig))
+		current, err := fileHash(fp)
+		if err != nil {
+			http.NotFound(w, r)
+			return
+		}
+		// pages rendered before the file changed get the current content, which must not be cached as the old one
+		if hash == current {
+			w.Header().Set("Cache-Control", immutableCacheControl)
+		} else {
+			w.Header().Set("Cache-Control", "no-cache")
+		}
+		http.ServeFile(w, r, fp)
+	})
+}
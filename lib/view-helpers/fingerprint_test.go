@@ -0,0 +1,73 @@
+package viewhelpers
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestFingerprint(t *testing.T) {
+	for _, name := range []string{"styles.css", "jquery.min.js"} {
+		got := Fingerprint(name, []byte("body {}"))
+		orig, hash, ok := splitFingerprint(got)
+		if !ok || orig != name || hash != contentHash([]byte("body {}")) {
+			t.Errorf("splitFingerprint(%q) = %q, %q, %t; want %q, %q, true", got, orig, hash, ok, name, contentHash([]byte("body {}")))
+		}
+		if other := Fingerprint(name, []byte("body { color: red; }")); other == got {
+			t.Errorf("Fingerprint(%q, ...) = %q for different contents; want different names", name, got)
+		}
+	}
+	for _, name := range []string{"styles.css", "jquery.min.js", "styles.0123.css", "styles.xyzxyzxyzx.css"} {
+		if orig, hash, ok := splitFingerprint(name); ok {
+			t.Errorf("splitFingerprint(%q) = %q, %q, true; want false", name, orig, hash)
+		}
+	}
+}
+
+func TestHandler(t *testing.T) {
+	dir, err := ioutil.TempDir("", "goship-static")
+	if err != nil {
+		t.Fatalf("ioutil.TempDir failed with %v; want success", err)
+	}
+	defer os.RemoveAll(dir)
+	if err := os.Mkdir(filepath.Join(dir, "css"), 0755); err != nil {
+		t.Fatalf("os.Mkdir failed with %v; want success", err)
+	}
+	content := []byte("body {}")
+	if err := ioutil.WriteFile(filepath.Join(dir, "css", "styles.css"), content, 0644); err != nil {
+		t.Fatalf("ioutil.WriteFile failed with %v; want success", err)
+	}
+	h := New(dir).Handler("/static/")
+
+	for _, spec := range []struct {
+		path         string
+		code         int
+		cacheControl string
+	}{
+		{path: "/static/css/" + Fingerprint("styles.css", content), code: http.StatusOK, cacheControl: immutableCacheControl},
+		{path: "/static/css/" + Fingerprint("styles.css", []byte("old")), code: http.StatusOK, cacheControl: "no-cache"},
+		{path: "/static/css/styles.css", code: http.StatusOK},
+		{path: "/static/css/missing.css", code: http.StatusNotFound},
+		{path: "/static/css/" + Fingerprint("missing.css", content), code: http.StatusNotFound},
+		{path: "/static/../fingerprint_test.go", code: http.StatusNotFound},
+	} {
+		req, err := http.NewRequest("GET", "http://goship.example"+spec.path, nil)
+		if err != nil {
+			t.Fatalf("http.NewRequest(%q, %q, nil) failed with %v; want success", "GET", spec.path, err)
+		}
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, req)
+		if w.Code != spec.code {
+			t.Errorf("w.Code = %d for %s; want %d", w.Code, spec.path, spec.code)
+		}
+		if got := w.HeaderMap.Get("Cache-Control"); got != spec.cacheControl {
+			t.Errorf("Cache-Control = %q for %s; want %q", got, spec.path, spec.cacheControl)
+		}
+		if spec.code == http.StatusOK && w.Body.String() != string(content) {
+			t.Errorf("w.Body = %q for %s; want %q", w.Body.String(), spec.path, content)
+		}
+	}
+}
@@ -61,7 +61,7 @@ func makeJavascriptTemplate(folderpath string) template.HTML {
 	fps := getJavascriptFiles(folderpath)
 	var str string = ""
 	for _, fp := range fps {
-		str += fmt.Sprintf(javascriptTag, fp)
+		str += fmt.Sprintf(javascriptTag, fingerprintFile(filepath.Join(folderpath, fp)))
 	}
 	return template.HTML(str)
 }
@@ -70,7 +70,7 @@ func makeStylesheetTemplate(folderpath string) template.HTML {
 	fps := getStylesheetFiles(folderpath)
 	var str string = ""
 	for _, fp := range fps {
-		str += fmt.Sprintf(stylesheetTag, fp)
+		str += fmt.Sprintf(stylesheetTag, fingerprintFile(filepath.Join(folderpath, fp)))
 	}
 	return template.HTML(str)
 }
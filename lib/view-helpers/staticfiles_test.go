@@ -1,7 +1,9 @@
 package viewhelpers
 
 import (
+	"fmt"
 	"html/template"
+	"io/ioutil"
 	"path/filepath"
 	"testing"
 )
@@ -42,11 +44,12 @@ func TestGetFilePaths(t *testing.T) {
 
 var stylesheetTemplateTests = []struct {
 	currentPath string
-	expected    string
-	expectErr   error
+	// file is the name of the expected file, which is fingerprinted in the template
+	file      string
+	expectErr error
 }{
 	{"../../static/js", "", nil},
-	{"../../static/css", "<link href='/static/css/styles.css' rel='stylesheet'>", nil},
+	{"../../static/css", "styles.css", nil},
 }
 
 func TestMakeStylesheetTemplate(t *testing.T) {
@@ -56,7 +59,10 @@ func TestMakeStylesheetTemplate(t *testing.T) {
 			t.Fatalf("Failed to get absolute file path of %s. err: %s", tt.currentPath, err)
 		}
 		tmpl := makeStylesheetTemplate(rootPath)
-		var expectTmpl = template.HTML(tt.expected)
+		var expectTmpl template.HTML
+		if tt.file != "" {
+			expectTmpl = template.HTML(fmt.Sprintf(stylesheetTag, fingerprintOf(t, filepath.Join(rootPath, tt.file))))
+		}
 		if tmpl != expectTmpl {
 			t.Errorf("Failed to make right HTML template structure for stylesheet. got: %v, want: %v", tmpl, expectTmpl)
 		}
@@ -65,10 +71,11 @@ func TestMakeStylesheetTemplate(t *testing.T) {
 
 var javascriptTemplateTests = []struct {
 	currentPath string
-	expected    string
-	expectErr   error
+	// file is the name of the expected file, which is fingerprinted in the template
+	file      string
+	expectErr error
 }{
-	{"../../static/js", "<script src='/static/js/pivotal.js'></script>", nil},
+	{"../../static/js", "pivotal.js", nil},
 	{"../../static/css", "", nil},
 }
 
@@ -79,9 +86,21 @@ func TestMakeJavascriptTemplate(t *testing.T) {
 			t.Fatalf("Failed to get absolute file path of %s. err: %s", tt.currentPath, err)
 		}
 		tmpl := makeJavascriptTemplate(rootPath)
-		var expectTmpl = template.HTML(tt.expected)
+		var expectTmpl template.HTML
+		if tt.file != "" {
+			expectTmpl = template.HTML(fmt.Sprintf(javascriptTag, fingerprintOf(t, filepath.Join(rootPath, tt.file))))
+		}
 		if tmpl != expectTmpl {
 			t.Errorf("Failed to make right HTML template structure for javascript. got: %v, want: %v", tmpl, expectTmpl)
 		}
 	}
 }
+
+// fingerprintOf returns the fingerprinted base name of the file at "fp".
+func fingerprintOf(t *testing.T, fp string) string {
+	buf, err := ioutil.ReadFile(fp)
+	if err != nil {
+		t.Fatalf("ioutil.ReadFile(%q) failed with %v; want success", fp, err)
+	}
+	return Fingerprint(filepath.Base(fp), buf)
+}
@@ -2,6 +2,7 @@ package viewhelpers
 
 import (
 	"bytes"
+	"encoding/json"
 	"html/template"
 	"io"
 	"net/http"
@@ -24,3 +25,21 @@ func RespondWithTemplate(w http.ResponseWriter, contentType string, tmpl *templa
 		return
 	}
 }
+
+// RespondWithJSON writes an http response to "w" with the status "code" and "obj" encoded in JSON.
+// The content type is "application/json" unless the caller has set another one, e.g. "application/scim+json".
+func RespondWithJSON(w http.ResponseWriter, code int, obj interface{}) {
+	buf, err := json.Marshal(obj)
+	if err != nil {
+		glog.Errorf("Failed to marshal response: %v", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if w.Header().Get("Content-Type") == "" {
+		w.Header().Set("Content-Type", "application/json")
+	}
+	w.WriteHeader(code)
+	if _, err := w.Write(buf); err != nil {
+		glog.Errorf("Failed to send response: %v", err)
+	}
+}
@@ -0,0 +1,38 @@
+package viewhelpers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRespondWithJSON(t *testing.T) {
+	w := httptest.NewRecorder()
+	RespondWithJSON(w, http.StatusCreated, map[string]string{"name": "admin"})
+	if w.Code != http.StatusCreated {
+		t.Errorf("RespondWithJSON responded %d; want %d", w.Code, http.StatusCreated)
+	}
+	if got, want := w.Header().Get("Content-Type"), "application/json"; got != want {
+		t.Errorf("Content-Type = %q; want %q", got, want)
+	}
+	if got, want := w.Body.String(), `{"name":"admin"}`; got != want {
+		t.Errorf("RespondWithJSON wrote %q; want %q", got, want)
+	}
+}
+
+func TestRespondWithJSONContentType(t *testing.T) {
+	w := httptest.NewRecorder()
+	w.Header().Set("Content-Type", "application/scim+json")
+	RespondWithJSON(w, http.StatusOK, map[string]string{"name": "admin"})
+	if got, want := w.Header().Get("Content-Type"), "application/scim+json"; got != want {
+		t.Errorf("Content-Type = %q; want %q", got, want)
+	}
+}
+
+func TestRespondWithJSONUnmarshalable(t *testing.T) {
+	w := httptest.NewRecorder()
+	RespondWithJSON(w, http.StatusOK, make(chan int))
+	if w.Code != http.StatusInternalServerError {
+		t.Errorf("RespondWithJSON responded %d for a channel; want %d", w.Code, http.StatusInternalServerError)
+	}
+}
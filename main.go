@@ -7,34 +7,78 @@ import (
 	"io/ioutil"
 	"net/http"
 	"os"
+	"os/signal"
+	"path"
 	"regexp"
 	"strings"
+	"syscall"
+	"time"
 
 	"github.com/coreos/go-etcd/etcd"
 	docker "github.com/fsouza/go-dockerclient"
+	"github.com/gengo/goship/handlers/activity"
+	"github.com/gengo/goship/handlers/approvals"
+	audithandler "github.com/gengo/goship/handlers/audit"
+	"github.com/gengo/goship/handlers/cancel"
+	"github.com/gengo/goship/handlers/changelog"
 	"github.com/gengo/goship/handlers/comment"
 	"github.com/gengo/goship/handlers/commits"
+	"github.com/gengo/goship/handlers/deactivation"
 	deploypage "github.com/gengo/goship/handlers/deploy-page"
+	"github.com/gengo/goship/handlers/handoff"
+	"github.com/gengo/goship/handlers/hosts"
+	"github.com/gengo/goship/handlers/keys"
+	"github.com/gengo/goship/handlers/kiosk"
 	"github.com/gengo/goship/handlers/lock"
+	"github.com/gengo/goship/handlers/output"
+	"github.com/gengo/goship/handlers/pause"
+	"github.com/gengo/goship/handlers/pin"
+	"github.com/gengo/goship/handlers/preferences"
+	"github.com/gengo/goship/handlers/progress"
+	"github.com/gengo/goship/handlers/projects"
+	"github.com/gengo/goship/handlers/provenance"
+	"github.com/gengo/goship/handlers/reservations"
+	"github.com/gengo/goship/handlers/schedules"
+	scimhandler "github.com/gengo/goship/handlers/scim"
+	"github.com/gengo/goship/handlers/vars"
 	"github.com/gengo/goship/lib/acl"
+	"github.com/gengo/goship/lib/audit"
 	"github.com/gengo/goship/lib/auth"
+	"github.com/gengo/goship/lib/clock"
+	"github.com/gengo/goship/lib/cluster"
 	"github.com/gengo/goship/lib/config"
+	deploypkg "github.com/gengo/goship/lib/deploy"
+	"github.com/gengo/goship/lib/federation"
+	"github.com/gengo/goship/lib/ghdeploy"
 	githublib "github.com/gengo/goship/lib/github"
+	"github.com/gengo/goship/lib/issue"
+	"github.com/gengo/goship/lib/logging"
+	"github.com/gengo/goship/lib/metrics"
 	"github.com/gengo/goship/lib/notification"
+	"github.com/gengo/goship/lib/outputstore"
 	"github.com/gengo/goship/lib/revision/gcr"
+	"github.com/gengo/goship/lib/schedule"
+	"github.com/gengo/goship/lib/scim"
+	"github.com/gengo/goship/lib/scm"
+	"github.com/gengo/goship/lib/scm/bitbucket"
+	githubscm "github.com/gengo/goship/lib/scm/github"
+	"github.com/gengo/goship/lib/scm/gitlab"
+	"github.com/gengo/goship/lib/users"
 	helpers "github.com/gengo/goship/lib/view-helpers"
 	_ "github.com/gengo/goship/plugins"
+	"github.com/gengo/goship/plugins/external"
 	"github.com/golang/glog"
 	ghandlers "github.com/gorilla/handlers"
 	"golang.org/x/net/context"
 	"golang.org/x/net/websocket"
+	"golang.org/x/oauth2"
 	googleoauth "golang.org/x/oauth2/google"
 )
 
 var (
 	bindAddress       = flag.String("b", "localhost:8000", "Address to bind (default localhost:8000)")
 	sshPort           = "22"
-	keyPath           = flag.String("k", "id_rsa", "Path to private SSH key (default id_rsa)")
+	keyPath           = flag.String("k", "id_rsa", "Path to private SSH key used for hosts without their own key_file (default id_rsa)")
 	gcpJWTConfig      = flag.String("gcp-jwt-config", "", "Path to a JSON file which contains a JWT configuration of a service account in Google Cloud Platform")
 	dataPath          = flag.String("d", "data/", "Path to data directory (default ./data/)")
 	staticFilePath    = flag.String("s", "static/", "Path to directory for static files (default ./static/)")
@@ -44,11 +88,47 @@ var (
 	defaultAvatar     = flag.String("a", "https://camo.githubusercontent.com/33a7d9a138ac73ece82dee977c216eb13dffc984/687474703a2f2f692e696d6775722e636f6d2f524c766b486b612e706e67", "Default Avatar (default goship gopher image)")
 	confirmDeployFlag = flag.Bool("f", true, "Flag to always ask for confirmation before deploying")
 	requestLog        = flag.String("request-log", "-", "destination of request log. '-' means stdout")
+	chaosFaults       = flag.String("chaos-faults", "", "Path to a YAML file of faults. If specified, deployments are simulated with the faults injected instead of running deploy commands")
+	gcInterval        = flag.Duration("gc-interval", 0, "Interval of checking etcd for locks, comments, pins and schedules of deleted projects and environments. Disabled if zero")
+	gcRemove          = flag.Bool("gc-remove", false, "Remove orphaned keys found by -gc-interval instead of just reporting them")
+	pluginDir         = flag.String("plugin-dir", "", "Directory of external plugin executables. Disabled if empty")
+	pluginTimeout     = flag.Duration("plugin-timeout", 10*time.Second, "Maximum duration of each invocation of an external plugin")
+	authProvider      = flag.String("auth", "github", "Identity provider of authentication: github, oidc or ldap")
+	auditExport       = flag.String("audit-export", "", "Destination of audit records for SIEM: syslog+tcp://host:port, syslog+udp://host:port or an http(s) URL. Disabled if empty")
+	auditExportFormat = flag.String("audit-export-format", "json", "Format of exported audit records: json or cef")
+	auditInterval     = flag.Duration("audit-interval", time.Minute, "Interval of exporting and pruning audit records")
+	auditRetention    = flag.Duration("audit-retention", 0, "Maximum age of audit records kept in etcd. Records are kept until exported if -audit-export is set. Kept forever if zero")
+	tlsCert           = flag.String("tls-cert", "", "Path to a PEM-encoded certificate to serve HTTPS. Requires -tls-key")
+	tlsKey            = flag.String("tls-key", "", "Path to the PEM-encoded private key of -tls-cert")
+	tlsClientCA       = flag.String("tls-client-ca", "", "Path to PEM-encoded CA certificates which verify client certificates of machine clients of the APIs. Requires HTTPS")
+	autocertDomains   = flag.String("autocert-domains", "", "Comma-separated domains to serve HTTPS with certificates obtained from Let's Encrypt. Exclusive with -tls-cert")
+	autocertEmail     = flag.String("autocert-email", "", "Contact email address registered to Let's Encrypt with -autocert-domains")
+	httpRedirect      = flag.String("http-redirect", "", "Address to serve plain HTTP which redirects to HTTPS, e.g. :80. Disabled if empty")
+	outputStoreURL    = flag.String("output-store", "", "Where outputs of finished deployments are moved: s3://bucket/prefix?region=..., gs://bucket/prefix or a local directory. Kept in the data directory if empty")
+	outputKeep        = flag.Int("output-keep", 0, "Number of the latest deployment outputs kept per environment. All kept if zero")
+	outputMaxAge      = flag.Duration("output-max-age", 0, "Maximum age of deployment outputs. Kept regardless of their age if zero")
+	shutdownTimeout   = flag.Duration("shutdown-timeout", 5*time.Minute, "Maximum duration of waiting for deployments in flight on SIGTERM or SIGINT before canceling them")
+	clusterAdvertise  = flag.String("cluster-advertise", "", "URL at which the other instances reach this instance, e.g. http://10.0.0.1:8000. Runs as a member of the cluster of instances sharing etcd if specified")
+	clusterID         = flag.String("cluster-id", "", "ID of this instance in the cluster (default hostname)")
+	clusterToken      = flag.String("cluster-token", "", "Secret shared by the instances of the cluster to authenticate relayed notifications. Required with -cluster-advertise")
+	clusterTTL        = flag.Duration("cluster-ttl", 15*time.Second, "Duration after which the membership, leadership and deploy leases of an unresponsive instance expire")
+	githubCacheSize   = flag.Int("github-cache-size", 10000, "Maximum number of github API responses cached. Disabled if zero")
+	githubCacheTTL    = flag.Duration("github-cache-ttl", time.Minute, "How long github API responses are served from the cache before they are revalidated with github")
+	githubStatusTTL   = flag.Duration("github-status-cache-ttl", 10*time.Second, "How long commit statuses of github are served from the cache before they are revalidated")
+	githubRefresh     = flag.Duration("github-refresh-interval", 30*time.Second, "Interval of revalidating cached github responses used in the last hour, e.g. the latest commits on the home page. Disabled if zero")
+	demoStart         = flag.String("demo-start", "", "RFC3339 time at which the simulated clock of the demo mode starts, e.g. 2015-11-13T17:55:00Z. Real time if empty")
+	hostCheckTimeout  = flag.Duration("host-check-timeout", 5*time.Second, "How long imports of hosts wait for their SSH ports to accept connections")
+	demoSpeed         = flag.Float64("demo-speed", 1, "How many times as fast as the real time the simulated clock of the demo mode runs")
+	logFormat         = flag.String("log-format", logging.FormatGlog, "Format of logs: glog, text or json. text and json are written to stderr with the fields of the lines, e.g. correlation_id of requests and deployments")
+	logLevel          = flag.String("log-level", "info", "Minimum level of logs: debug, info, warning or error. debug includes served requests and outputs of deployments")
 )
 
+// githubRefreshWithin is how recently cached github responses must have been used to be refreshed in the background.
+const githubRefreshWithin = time.Hour
+
 var validPathWithEnv = regexp.MustCompile("^/(deployLog|commits)/(.*)$")
 
-func extractDeployLogHandler(ac acl.AccessControl, ecl *etcd.Client, fn func(http.ResponseWriter, *http.Request, string, config.Environment, string)) http.HandlerFunc {
+func extractDeployLogHandler(ac acl.AccessControl, ecl config.ETCDInterface, fn func(http.ResponseWriter, *http.Request, string, config.Environment, string)) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		m := validPathWithEnv.FindStringSubmatch(r.URL.Path)
 		if m == nil {
@@ -67,7 +147,7 @@ func extractDeployLogHandler(ac acl.AccessControl, ecl *etcd.Client, fn func(htt
 			glog.Error("Failed to get a user while deploying in Auth Mode: %v", err)
 			http.Error(w, err.Error(), http.StatusUnauthorized)
 		}
-		c.Projects = acl.ReadableProjects(ac, c.Projects, u)
+		c.Projects = c.VisibleProjects(acl.ReadableProjects(ac, c.Projects, u), u.Name)
 		// get project name and env from url
 		a := strings.Split(m[2], "-")
 		l := len(a)
@@ -102,64 +182,351 @@ func extractOutputHandler(fn func(http.ResponseWriter, *http.Request, string, st
 }
 
 const (
-	gitHubAPITokenEnvVar = "GITHUB_API_TOKEN"
+	gitHubAPITokenEnvVar       = "GITHUB_API_TOKEN"
+	gitLabAPITokenEnvVar       = "GITLAB_API_TOKEN"
+	gitLabURLEnvVar            = "GITLAB_URL"
+	bitbucketUsernameEnvVar    = "BITBUCKET_USERNAME"
+	bitbucketAppPasswordEnvVar = "BITBUCKET_APP_PASSWORD"
 )
 
-func newGithubClient() (githublib.Client, error) {
+// newAuthProvider returns the auth.Provider selected by -auth flag and configured by environment variables.
+// It returns nil for github, which auth.Initialize configures by itself.
+func newAuthProvider() (auth.Provider, error) {
+	switch *authProvider {
+	case "github":
+		return nil, nil
+	case "oidc":
+		return auth.NewOIDC(auth.OIDCConfig{
+			Issuer:       os.Getenv("OIDC_ISSUER"),
+			ClientID:     os.Getenv("OIDC_CLIENT_ID"),
+			ClientSecret: os.Getenv("OIDC_CLIENT_SECRET"),
+			UserClaim:    os.Getenv("OIDC_USER_CLAIM"),
+			GroupsClaim:  os.Getenv("OIDC_GROUPS_CLAIM"),
+		})
+	case "ldap":
+		return auth.NewLDAP(auth.LDAPConfig{
+			URL:            os.Getenv("LDAP_URL"),
+			StartTLS:       os.Getenv("LDAP_START_TLS") == "true",
+			BindDN:         os.Getenv("LDAP_BIND_DN"),
+			BindPassword:   os.Getenv("LDAP_BIND_PASSWORD"),
+			BaseDN:         os.Getenv("LDAP_BASE_DN"),
+			UserFilter:     os.Getenv("LDAP_USER_FILTER"),
+			GroupAttribute: os.Getenv("LDAP_GROUP_ATTRIBUTE"),
+		})
+	}
+	return nil, fmt.Errorf("unknown auth provider %q", *authProvider)
+}
+
+// newGithubClient returns a github client which caches responses in "cache" unless it is nil.
+func newGithubClient(cache *githublib.Cache) (githublib.Client, error) {
 	gt := os.Getenv(gitHubAPITokenEnvVar)
 	if gt == "" {
 		return nil, fmt.Errorf("environment variable %s not defined", gitHubAPITokenEnvVar)
 	}
-	return githublib.NewClient(gt), nil
+	return githublib.NewClient(gt, cache), nil
+}
+
+// newSCMs returns clients and access controls of the source code management services configured in environment variables.
+// GitHub is always available, and its API calls are instrumented separately for each feature.
+func newSCMs(gcl githublib.Client) (map[config.SCMType]scm.Client, map[config.SCMType]acl.AccessControl) {
+	scms := map[config.SCMType]scm.Client{config.SCMGithub: githubscm.New(githublib.Instrument(gcl, githublib.FeatureCommits))}
+	acls := map[config.SCMType]acl.AccessControl{config.SCMGithub: acl.NewGithub(githublib.Instrument(gcl, githublib.FeatureACL))}
+	if token := os.Getenv(gitLabAPITokenEnvVar); token != "" {
+		u := os.Getenv(gitLabURLEnvVar)
+		if u == "" {
+			u = gitlab.DefaultURL
+		}
+		c := gitlab.New(u, token)
+		scms[config.SCMGitLab], acls[config.SCMGitLab] = c, c
+	}
+	if user := os.Getenv(bitbucketUsernameEnvVar); user != "" {
+		c := bitbucket.New(bitbucket.DefaultAPIURL, user, os.Getenv(bitbucketAppPasswordEnvVar))
+		scms[config.SCMBitbucket], acls[config.SCMBitbucket] = c, c
+	}
+	return scms, acls
+}
+
+// backend is a set of external dependencies of the handlers.
+type backend struct {
+	ac         acl.AccessControl
+	ecl        config.ETCDInterface
+	newControl commits.ControlFactory
+	executor   deploypkg.Executor
+	// gcl opens issues of repeated deployment failures and reports deployments. It is nil if Goship does not write to github.
+	gcl githublib.Client
+	// githubCache caches responses to gcl. It is nil if github responses are not cached.
+	githubCache *githublib.Cache
+	// outputStore keeps outputs of finished deployments. They are kept in the data directory if nil.
+	outputStore outputstore.Store
+	// scms verify the provenance of revisions in the source repositories of projects.
+	scms map[config.SCMType]scm.Client
+	// runRemote runs one-off remote commands on hosts of environments.
+	runRemote remoteRunner
+	// demo is true if the backend simulates github, deploy target hosts and notifications.
+	demo bool
 }
 
-func buildHandler(ctx context.Context) (http.Handler, error) {
-	gcl, err := newGithubClient()
+// newBackend returns a backend which accesses to the real etcd, github, docker and deploy target hosts.
+func newBackend() (backend, error) {
+	var cache *githublib.Cache
+	if *githubCacheSize > 0 {
+		cache = githublib.NewCache(githublib.CacheTTLs{Default: *githubCacheTTL, Statuses: *githubStatusTTL}, *githubCacheSize)
+	}
+	gcl, err := newGithubClient(cache)
 	if err != nil {
 		glog.Errorf("Failed to build github client: %v", err)
-		return nil, err
+		return backend{}, err
 	}
 
+	ecl := etcd.NewClient([]string{*ETCDServer})
+	scms, acls := newSCMs(gcl)
 	ac := acl.Null
-	if auth.Enabled() {
-		ac = acl.NewGithub(gcl)
+	switch {
+	case auth.Enabled() && *authProvider == "github":
+		ac = acl.NewBySCM(acls, ecl)
+	case auth.Enabled():
+		ac = acl.NewByGroups(ecl)
 	}
 
 	dcl, err := docker.NewClientFromEnv()
 	if err != nil {
-		return nil, err
+		return backend{}, err
 	}
 
+	return backend{
+		ac:          ac,
+		ecl:         ecl,
+		newControl:  commits.NewControlFactory(scms, dcl, *keyPath),
+		executor:    deploypkg.Command,
+		gcl:         gcl,
+		githubCache: cache,
+		scms:        scms,
+		runRemote: func(ctx context.Context, cfg config.SSH, host, cmd string, stdout, stderr io.Writer) error {
+			return sshRemote{cfg: cfg}.Run(ctx, host, cmd, stdout, stderr)
+		},
+	}, nil
+}
+
+// buildHandler returns the handler of all the endpoints. "cl" is the cluster which this instance joins, or nil if it runs alone.
+func buildHandler(ctx context.Context, b backend, cl *cluster.Cluster) (http.Handler, inflight, error) {
+	cache := config.NewCache(b.ecl)
+	if w, ok := b.ecl.(config.Watcher); ok {
+		go cache.Watch(ctx, w)
+	} else {
+		glog.Warningf("Configuration changes are not watched because %T cannot watch keys; use /admin/reload after changing it", b.ecl)
+	}
+	ac, ecl := acl.WithKiosks(b.ac, cache), config.ETCDInterface(cache)
 	hub := notification.NewHub(ctx)
-	ecl := etcd.NewClient([]string{*ETCDServer})
+	notification.Recorder = func(ev notification.Event) {
+		if err := audit.Append(ecl, audit.FromEvent(ev)); err != nil {
+			glog.Errorf("Failed to record %s event of %s (%s) in the audit log: %v", ev.Type, ev.Project, ev.Environment, err)
+		}
+	}
+	notification.Digests = notification.NewDigester(ecl)
+	if *authProvider != "github" {
+		auth.OnLogin = func(u auth.User) {
+			if _, ok := scim.UserGroups(ecl, u.Name); ok {
+				// the identity provider keeps the groups of provisioned users in sync
+				return
+			}
+			if err := config.StoreUserGroups(ecl, u.Name, u.Groups); err != nil {
+				glog.Errorf("Failed to store groups of %s: %v", u.Name, err)
+			}
+		}
+	}
+	auth.Revoked = func(name string, login time.Time) bool {
+		return users.Revoked(ecl, name, login)
+	}
+	auth.Groups = func(name string) ([]string, bool) {
+		return scim.UserGroups(ecl, name)
+	}
+	if *tlsClientCA != "" {
+		auth.ClientCertUser = clientCertUser(ecl)
+	}
+	auth.KioskUser = kioskUser(ecl)
 	assets := helpers.New(*staticFilePath)
 
 	mux := http.NewServeMux()
 	mux.Handle("/", auth.Authenticate(HomeHandler{ac: ac, ecl: ecl, assets: assets}))
-	mux.HandleFunc("/static/", func(w http.ResponseWriter, r *http.Request) {
-		http.ServeFile(w, r, r.URL.Path[1:])
-	})
+	mux.Handle("/static/", assets.Handler("/static/"))
 
-	dph, err := deploypage.New(assets, fmt.Sprintf("ws://%s/web_push", *bindAddress))
+	dph, err := deploypage.New(assets, pushAddress())
 	if err != nil {
 		glog.Errorf("Failed to build deploy page handler: %v", err)
-		return nil, err
+		return nil, inflight{}, err
 	}
 	mux.Handle("/deploy", auth.Authenticate(dph))
 	mux.Handle("/web_push", websocket.Handler(hub.AcceptConnection))
 
-	dlh := DeployLogHandler{assets: assets}
+	dlh := DeployLogHandler{ecl: ecl, assets: assets}
 	mux.Handle("/deployLog/", auth.AuthenticateFunc(extractDeployLogHandler(ac, ecl, dlh.ServeHTTP)))
-	mux.Handle("/output/", auth.AuthenticateFunc(extractOutputHandler(DeployOutputHandler)))
-	mux.Handle("/commits/", auth.Authenticate(commits.New(ac, ecl, gcl, dcl, *keyPath)))
-	mux.Handle("/deploy_handler", auth.Authenticate(DeployHandler{ecl: ecl, hub: hub}))
-	mux.Handle("/lock", auth.Authenticate(lock.NewLock(ecl)))
-	mux.Handle("/unlock", auth.Authenticate(lock.NewUnlock(ecl)))
-	mux.Handle("/comment", auth.Authenticate(comment.New(ecl)))
-	mux.HandleFunc("/auth/github/login", auth.LoginHandler)
-	mux.HandleFunc("/auth/github/callback", auth.CallbackHandler)
+	mux.Handle("/output/", auth.AuthenticateFunc(extractOutputHandler(newDeployOutputHandler(b.outputStore))))
+	mux.Handle("/commits/", auth.Authenticate(commits.New(ac, ecl, b.newControl)))
+	tracker, running, outputs := deploypkg.NewTracker(), deploypkg.NewRunning(), deploypkg.NewOutputs()
+	if cl != nil {
+		running = deploypkg.NewRunningWithPrefix(cl.Self().ID)
+	}
+	in := inflight{deploys: deploypkg.NewDrain(), running: running, hub: hub, cluster: cl}
+	dh := DeployHandler{ac: ac, ecl: ecl, hub: hub, executor: b.executor, queue: deploypkg.NewQueue(), progress: tracker, running: running, outputs: outputs, drain: in.deploys, cluster: cl, outputStore: b.outputStore, scms: b.scms}
+	if b.gcl != nil {
+		issues := issue.New(ecl, githublib.Instrument(b.gcl, githublib.FeatureIssues))
+		dh.issues = &issues
+		deployments := ghdeploy.New(githublib.Instrument(b.gcl, githublib.FeatureDeployments))
+		dh.deployments = &deployments
+	}
+	mux.Handle("/deploy_handler", auth.Authenticate(dh))
+	hub.Replay = dh.replay
+	mux.Handle("/events", auth.AuthenticateFunc(kioskEvents(ecl, hub)))
+	lead(ctx, cl, func(ctx context.Context) { schedule.Run(ctx, ecl, clock.Default, dh.runSchedule) })
+	lead(ctx, cl, func(ctx context.Context) { notification.Digests.Run(ctx, time.Minute) })
+	lead(ctx, cl, func(ctx context.Context) { runWeeklyReports(ctx, ecl, time.Minute) })
+	actions := http.Handler(deployActions{
+		"cancel":   cancel.New(ac, ecl, running),
+		"handoff":  handoff.New(ac, ecl, running, dh.handedOff),
+		"output":   output.New(ac, ecl, outputs),
+		"continue": pause.New(ac, ecl, running),
+		"abort":    pause.New(ac, ecl, running),
+	})
+	if cl != nil {
+		hub.Relay = cl.Relay
+		mux.Handle(cluster.RelayPath, cl.RelayHandler(dh.relayed))
+		mux.Handle("/api/cluster", auth.Authenticate(ClusterHandler{ac: ac, ecl: ecl, cluster: cl}))
+		actions = cl.Forward(actions)
+	}
+	mux.Handle("/deploys/", auth.Authenticate(actions))
+	mux.Handle("/api/progress", auth.Authenticate(progress.New(ac, ecl, tracker)))
+	// peers authenticate with the federation token instead of a session
+	mux.Handle(federation.StatusPath, StatusHandler{ac: ac, ecl: ecl, tracker: tracker})
+	mux.Handle("/federation", auth.Authenticate(FederationHandler{ac: ac, ecl: ecl, tracker: tracker, client: federation.NewClient(peerTimeout), assets: assets}))
+	mux.Handle("/lock", auth.Authenticate(lock.NewLock(ac, ecl)))
+	mux.Handle("/unlock", auth.Authenticate(lock.NewUnlock(ac, ecl)))
+	mux.Handle("/api/locks", auth.Authenticate(lock.NewStatus(ac, ecl)))
+	mux.Handle("/action", auth.Authenticate(ActionHandler{dh: dh}))
+	mux.Handle("/api/actions", auth.Authenticate(ActionListHandler{ac: ac, ecl: ecl}))
+	mux.Handle("/api/commands", auth.Authenticate(CommandHandler{dh: dh, run: b.runRemote}))
+	mux.Handle("/comment", auth.Authenticate(comment.New(ac, ecl, hasDeployment)))
+	mux.Handle("/api/comments", auth.Authenticate(comment.NewThread(ac, ecl, hasDeployment)))
+	mux.Handle("/labels", auth.Authenticate(LabelsHandler{ac: ac, ecl: ecl}))
+	mux.Handle("/pin", auth.Authenticate(pin.NewPin(ac, ecl)))
+	mux.Handle("/unpin", auth.Authenticate(pin.NewUnpin(ac, ecl)))
+	mux.Handle("/preferences", auth.Authenticate(preferences.New(ecl)))
+	mux.Handle("/api/schedules", auth.Authenticate(schedules.New(ac, ecl)))
+	mux.Handle("/api/reservations", auth.Authenticate(reservations.New(ac, ecl)))
+	mux.Handle("/calendar/reservations.ics", auth.Authenticate(reservations.NewCalendar(ac, ecl)))
+	mux.Handle("/api/provenance", auth.Authenticate(provenance.New(ac, ecl, b.scms)))
+	mux.Handle("/api/changelog", auth.Authenticate(changelog.New(ac, ecl, b.scms)))
+	mux.Handle("/changelog", auth.Authenticate(changelog.NewPage(ac, ecl, b.scms, assets)))
+	mux.Handle("/api/approvals", auth.Authenticate(approvals.New(ac, ecl, dh.runApproved)))
+	mux.Handle("/api/vars", auth.Authenticate(vars.New(ac, ecl)))
+	mux.Handle("/vars", auth.Authenticate(vars.NewPage(ac, ecl, assets)))
+	mux.Handle("/slack/interactions", approvals.NewSlack(ac, ecl, dh.runApproved))
+	mux.Handle("/api/deployable", DeployabilityHandler{ac: ac, ecl: ecl, dh: dh})
+	mux.Handle("/slack/command", SlackCommandHandler{ac: ac, ecl: ecl, dh: dh, newControl: b.newControl})
+	mux.Handle("/promote", auth.Authenticate(PromoteHandler{ecl: ecl, dh: dh, newControl: b.newControl}))
+	if _, ok := b.ecl.(config.Deleter); ok {
+		mux.Handle("/api/projects", auth.Authenticate(projects.New(cache)))
+		mux.Handle("/admin/projects", auth.Authenticate(projects.NewPage(ecl, assets)))
+		mux.Handle("/api/keys", auth.Authenticate(keys.New(cache)))
+		mux.Handle("/admin/keys", auth.Authenticate(keys.NewPage(ecl, assets)))
+		mux.Handle("/api/hosts/import", auth.Authenticate(hosts.NewImport(cache, *hostCheckTimeout)))
+		mux.Handle("/api/kiosk/tokens", auth.Authenticate(kiosk.New(cache)))
+		// the identity provider authenticates with the scim token instead of a session
+		mux.Handle(scimhandler.Prefix, scimhandler.New(cache))
+	} else {
+		glog.Warningf("Project editor, key browser, host imports, kiosk tokens and SCIM provisioning are disabled because %T cannot delete keys", b.ecl)
+	}
+	mux.Handle("/admin/reload", auth.Authenticate(ReloadHandler{cache: cache}))
+	mux.Handle("/api/system/diagnostics", auth.Authenticate(DiagnosticsHandler{ecl: ecl, checks: diagnosticChecks(b, ecl)}))
+	// wall displays authenticate with the kiosk token instead of a session
+	mux.Handle("/kiosk", KioskHandler{ecl: ecl})
+	mux.Handle("/api/users/deactivate", auth.Authenticate(deactivation.NewDeactivate(ecl)))
+	mux.Handle("/api/users/reactivate", auth.Authenticate(deactivation.NewReactivate(ecl)))
+	mux.Handle("/api/dryrun", auth.Authenticate(projects.NewDryRun(ac, ecl)))
+	mux.Handle("/audit", auth.Authenticate(audithandler.New(ac, ecl, assets)))
+	mux.Handle("/api/reports/weekly", auth.Authenticate(WeeklyReportHandler{ac: ac, ecl: ecl}))
+	mux.Handle("/api/reports/timeline", auth.Authenticate(TimelineHandler{ac: ac, ecl: ecl}))
+	mux.Handle("/api/activity", auth.Authenticate(activity.New(ac, ecl, notification.Activity)))
+	mux.Handle("/metrics", metrics.Handler())
+	mux.Handle("/api/github/usage", auth.Authenticate(githublib.DefaultUsage.Handler()))
+	mux.HandleFunc(auth.LoginPath(), auth.LoginHandler)
+	mux.HandleFunc(auth.CallbackPath(), auth.CallbackHandler)
+
+	return logging.Handler(mux), in, nil
+}
 
-	return mux, nil
+// deployActions dispatches requests to /deploys/{id}/{action} by the action.
+type deployActions map[string]http.Handler
+
+func (a deployActions) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h, ok := a[path.Base(r.URL.Path)]
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	h.ServeHTTP(w, r)
+}
+
+// maintainAudit exports new audit records to "sink" if not nil, and removes records older than "retention" if positive, every "interval".
+func maintainAudit(ctx context.Context, ecl config.ETCDInterface, sink audit.Sink, interval, retention time.Duration) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(interval):
+		}
+		if sink != nil {
+			n, err := audit.Export(ecl, sink)
+			if n > 0 {
+				glog.V(1).Infof("Exported %d audit record(s)", n)
+			}
+			if err != nil {
+				glog.Errorf("Failed to export audit records: %v", err)
+				continue
+			}
+		}
+		if retention > 0 {
+			n, err := audit.Prune(ecl, retention, time.Now(), sink != nil)
+			if err != nil {
+				glog.Errorf("Failed to prune audit records: %v", err)
+				continue
+			}
+			if n > 0 {
+				glog.Infof("Removed %d audit record(s) older than %s", n, retention)
+			}
+		}
+	}
+}
+
+// collectGarbage periodically reports orphaned keys in "ecl", and removes them if "remove" is true.
+func collectGarbage(ctx context.Context, ecl config.ETCDInterface, interval time.Duration, remove bool) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(interval):
+		}
+		orphans, err := config.FindOrphans(ecl)
+		if err != nil {
+			glog.Errorf("Failed to find orphaned keys: %v", err)
+			continue
+		}
+		for _, o := range orphans {
+			glog.Warningf("Orphaned key %s: %s", o.Key, o.Reason)
+		}
+		if !remove || len(orphans) == 0 {
+			continue
+		}
+		d, ok := ecl.(config.Deleter)
+		if !ok {
+			glog.Errorf("Cannot remove orphaned keys from %T", ecl)
+			continue
+		}
+		if err := config.RemoveOrphans(d, orphans); err != nil {
+			glog.Errorf("Failed to remove orphaned keys: %v", err)
+			continue
+		}
+		glog.Infof("Removed %d orphaned key(s)", len(orphans))
+	}
 }
 
 func initGCP(ctx context.Context) error {
@@ -178,27 +545,144 @@ func initGCP(ctx context.Context) error {
 	return nil
 }
 
+// newOutputStore returns the store at -output-store.
+func newOutputStore(ctx context.Context) (outputstore.Store, error) {
+	var gcs *http.Client
+	if strings.HasPrefix(*outputStoreURL, "gs://") {
+		ts, err := gcsTokenSource(ctx)
+		if err != nil {
+			return nil, err
+		}
+		gcs = oauth2.NewClient(ctx, ts)
+	}
+	return outputstore.New(*outputStoreURL, gcs)
+}
+
+// gcsTokenSource returns a token source for Google Cloud Storage from -gcp-jwt-config, or the default credential if it is empty.
+func gcsTokenSource(ctx context.Context) (oauth2.TokenSource, error) {
+	if *gcpJWTConfig == "" {
+		return googleoauth.DefaultTokenSource(ctx, outputstore.GCSScope)
+	}
+	buf, err := ioutil.ReadFile(*gcpJWTConfig)
+	if err != nil {
+		return nil, err
+	}
+	cfg, err := googleoauth.JWTConfigFromJSON(buf, outputstore.GCSScope)
+	if err != nil {
+		return nil, err
+	}
+	return cfg.TokenSource(ctx), nil
+}
+
+// configureLogging configures logging with -log-format and -log-level.
+func configureLogging() error {
+	level, err := logging.ParseLevel(*logLevel)
+	if err != nil {
+		return err
+	}
+	return logging.Configure(*logFormat, level, os.Stderr)
+}
+
 func main() {
 	flag.Parse()
+	if err := configureLogging(); err != nil {
+		glog.Fatal(err)
+	}
 	glog.Infof("Starting Goship...")
 
 	ctx := context.Background()
 	ctx, cancel := context.WithCancel(ctx)
 	defer cancel()
 
-	auth.Initialize(auth.User{Name: *defaultUser, Avatar: *defaultAvatar}, []byte(*cookieSessionHash))
+	if err := validateTLSFlags(); err != nil {
+		glog.Fatal(err)
+	}
+	ap, err := newAuthProvider()
+	if err != nil {
+		glog.Fatalf("Failed to configure authentication: %v", err)
+	}
+	auth.Initialize(ap, auth.User{Name: *defaultUser, Avatar: *defaultAvatar}, []byte(*cookieSessionHash))
+	if tlsEnabled() {
+		auth.SecureCookies()
+	}
 	if err := initGCP(ctx); err != nil {
 		glog.Fatal("Failed to load Google Service Account credential: %v", err)
 	}
 
+	var b backend
+	switch mode := flag.Arg(0); mode {
+	case "":
+		b, err = newBackend()
+	case "demo":
+		b, err = newDemoBackend()
+	default:
+		err = fmt.Errorf("unknown mode %q", mode)
+	}
+	if err != nil {
+		glog.Fatal(err)
+	}
+	if *chaosFaults != "" {
+		faults, err := deploypkg.LoadFaults(*chaosFaults)
+		if err != nil {
+			glog.Fatalf("Failed to load faults from %s: %v", *chaosFaults, err)
+		}
+		glog.Warningf("Simulating deployments with %d fault(s) injected", len(faults))
+		b.executor = deploypkg.NewChaosExecutor(faults)
+	}
+
 	if err := os.Mkdir(*dataPath, 0777); err != nil && !os.IsExist(err) {
 		glog.Fatal("could not create data dir: %v", err)
 	}
+	if *outputStoreURL != "" {
+		if b.outputStore, err = newOutputStore(ctx); err != nil {
+			glog.Fatalf("Failed to configure output store: %v", err)
+		}
+	}
+	if *pluginDir != "" {
+		plugins, err := external.Load(*pluginDir, *pluginTimeout)
+		if err != nil {
+			glog.Fatalf("Failed to load plugins from %s: %v", *pluginDir, err)
+		}
+		glog.Infof("Loaded %d external plugin(s)", len(plugins))
+	}
 
-	h, err := buildHandler(ctx)
+	var cl *cluster.Cluster
+	if *clusterAdvertise != "" {
+		if cl, err = newCluster(b.ecl); err != nil {
+			glog.Fatalf("Failed to join the cluster: %v", err)
+		}
+		go cl.Run(ctx)
+		glog.Infof("Joined the cluster as %s", cl.Self().ID)
+	}
+	h, in, err := buildHandler(ctx, b, cl)
 	if err != nil {
 		glog.Fatal(err)
 	}
+	go logDiagnostics(ctx, diagnosticChecks(b, b.ecl))
+	if b.githubCache != nil && *githubRefresh > 0 {
+		go b.githubCache.Refresh(ctx, *githubRefresh, githubRefreshWithin)
+	}
+	if *gcInterval > 0 {
+		lead(ctx, cl, func(ctx context.Context) { collectGarbage(ctx, b.ecl, *gcInterval, *gcRemove) })
+	}
+	if *auditExport != "" || *auditRetention > 0 {
+		var sink audit.Sink
+		if *auditExport != "" {
+			if sink, err = audit.NewSink(*auditExport, audit.Format(*auditExportFormat)); err != nil {
+				glog.Fatalf("Failed to configure audit export: %v", err)
+			}
+		}
+		lead(ctx, cl, func(ctx context.Context) { maintainAudit(ctx, b.ecl, sink, *auditInterval, *auditRetention) })
+	}
+	if *outputKeep > 0 || *outputMaxAge > 0 {
+		store := b.outputStore
+		if store == nil {
+			store = outputstore.NewDisk(*dataPath)
+		}
+		lead(ctx, cl, func(ctx context.Context) {
+			compactOutputs(ctx, store, outputstore.Retention{KeepLast: *outputKeep, MaxAge: *outputMaxAge}, time.Hour)
+		})
+	}
 	w := io.WriteCloser(os.Stdout)
 	if *requestLog != "-" {
 		w, err = os.OpenFile(*requestLog, os.O_APPEND|os.O_CREATE, 0644)
@@ -209,12 +693,26 @@ func main() {
 	}
 	h = ghandlers.CombinedLoggingHandler(w, h)
 
-	fmt.Printf("Running on %s\n", *bindAddress)
 	s := &http.Server{
 		Addr:    *bindAddress,
 		Handler: h,
 	}
-	if err := s.ListenAndServe(); err != nil {
+	l, err := listen(s)
+	if err != nil {
+		glog.Fatal(err)
+	}
+	fmt.Printf("Running on %s\n", *bindAddress)
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, syscall.SIGTERM, os.Interrupt)
+	errc := make(chan error, 1)
+	go func() {
+		errc <- s.Serve(l)
+	}()
+	select {
+	case err := <-errc:
 		glog.Fatal(err)
+	case sig := <-sigs:
+		glog.Infof("Received %v; shutting down", sig)
 	}
+	os.Exit(shutdown(s, l, in, cancel))
 }
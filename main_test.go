@@ -3,6 +3,8 @@ package main
 import (
 	"testing"
 	"time"
+
+	"github.com/gengo/goship/lib/config"
 )
 
 func TestStripANSICodes(t *testing.T) {
@@ -46,3 +48,56 @@ func TestFormatTime(t *testing.T) {
 		}
 	}
 }
+
+func TestHistoryQueryMatch(t *testing.T) {
+	e := DeployLogEntry{
+		Range:         RevRange{From: "abc123", To: "def456"},
+		ToRevisionMsg: "Fix the login form",
+		User:          "alice",
+		Owner:         "bob",
+		Success:       true,
+		Time:          time.Date(2015, time.November, 10, 23, 0, 0, 0, time.UTC),
+		Labels:        []string{"hotfix"},
+		Reason:        "Customer escalation",
+	}
+	for _, tt := range []struct {
+		q    historyQuery
+		want bool
+	}{
+		{q: historyQuery{}, want: true},
+		{q: historyQuery{User: "bob"}, want: true},
+		{q: historyQuery{User: "carol"}, want: false},
+		{q: historyQuery{Result: "success"}, want: true},
+		{q: historyQuery{Result: "failure"}, want: false},
+		{q: historyQuery{SHA: "def"}, want: true},
+		{q: historyQuery{SHA: "456"}, want: false},
+		{q: historyQuery{Label: "hotfix"}, want: true},
+		{q: historyQuery{Since: time.Date(2015, time.November, 10, 0, 0, 0, 0, time.UTC)}, want: true},
+		{q: historyQuery{Until: time.Date(2015, time.November, 10, 0, 0, 0, 0, time.UTC)}, want: false},
+		{q: historyQuery{Text: "ESCALATION"}, want: true},
+		{q: historyQuery{Text: "login"}, want: true},
+		{q: historyQuery{Text: "logout"}, want: false},
+	} {
+		if got := tt.q.match(e, searchText(e)); got != tt.want {
+			t.Errorf("%#v.match(e) = %v; want %v", tt.q, got, tt.want)
+		}
+	}
+}
+
+func TestKioskMessage(t *testing.T) {
+	token := config.KioskToken{Name: "office-wall", Projects: []string{"storefront"}}
+	for _, tt := range []struct {
+		msg  string
+		want bool
+	}{
+		{msg: `{"Project":"storefront","Environment":"production","State":"deploying"}`, want: true},
+		{msg: `{"Project":"billing","Environment":"production","State":"deploying"}`, want: false},
+		{msg: `{"Project":"storefront","Environment":"production","StdoutLine":"password=secret"}`, want: false},
+		{msg: `{"Environment":"production"}`, want: false},
+		{msg: `not json`, want: false},
+	} {
+		if got := kioskMessage(token, tt.msg); got != tt.want {
+			t.Errorf("kioskMessage(%#v, %q) = %v; want %v", token, tt.msg, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,267 @@
+// Package external runs plugins as external executables so that they can be added without recompiling Goship.
+//
+// A plugin is an executable file in the plugins directory.
+// Goship invokes it with a hook name as the only argument, writes a JSON request to its stdin and reads a JSON response from its stdout.
+// A non-zero exit status means a failure of the plugin, and its stderr is logged.
+//
+// At startup, Goship invokes "describe" with no request. The plugin responds with its name and the hooks it implements:
+//
+//	{"name": "ci-status", "hooks": ["columns", "pre_deploy", "post_deploy"]}
+//
+// "columns" receives {"project": {"name": ..., "repo_owner": ..., "repo_name": ..., "environments": [...]}}
+// and responds with additional columns of the project in the home page. Header and detail are plain text, and detail links to url if given:
+//
+//	{"columns": [{"header": "CI", "detail": "passing", "url": "https://ci.example.com/my-project"}]}
+//
+// "pre_deploy" receives a plugin.Deployment before the deployment starts and responds whether it may start:
+//
+//	{"allow": false, "reason": "CI is failing"}
+//
+// "post_deploy" receives a plugin.Deployment after the deployment finishes. Its response is ignored.
+package external
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html/template"
+	"io/ioutil"
+	"os/exec"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/gengo/goship/lib/config"
+	"github.com/gengo/goship/plugins/plugin"
+	"github.com/golang/glog"
+)
+
+// Hooks which external plugins can implement.
+const (
+	HookColumns    = "columns"
+	HookPreDeploy  = "pre_deploy"
+	HookPostDeploy = "post_deploy"
+)
+
+var errTimeout = errors.New("plugin timed out")
+
+// columnsTTL is how long columns of a project are reused before invoking the plugin again.
+const columnsTTL = 30 * time.Second
+
+// Plugin is an external plugin.
+type Plugin struct {
+	path    string
+	name    string
+	hooks   map[string]bool
+	timeout time.Duration
+
+	mu      sync.Mutex
+	columns map[string]cachedColumns
+}
+
+type cachedColumns struct {
+	columns []plugin.Column
+	expires time.Time
+}
+
+type description struct {
+	Name  string   `json:"name"`
+	Hooks []string `json:"hooks"`
+}
+
+// Open describes the executable at "path" and returns a Plugin which invokes it.
+// Each invocation is killed after "timeout".
+func Open(path string, timeout time.Duration) (*Plugin, error) {
+	p := &Plugin{path: path, hooks: make(map[string]bool), timeout: timeout, columns: make(map[string]cachedColumns)}
+	var desc description
+	if err := p.call("describe", nil, &desc); err != nil {
+		return nil, err
+	}
+	if desc.Name == "" {
+		return nil, fmt.Errorf("plugin %s has no name", path)
+	}
+	p.name = desc.Name
+	for _, h := range desc.Hooks {
+		switch h {
+		case HookColumns, HookPreDeploy, HookPostDeploy:
+			p.hooks[h] = true
+		default:
+			return nil, fmt.Errorf("unknown hook %q in plugin %s", h, path)
+		}
+	}
+	return p, nil
+}
+
+// Load opens all the executable files in "dir" and registers them by their hooks.
+// It fails if any of the plugins fails to be opened, so that pre-deploy checks are not skipped silently.
+func Load(dir string, timeout time.Duration) ([]*Plugin, error) {
+	infos, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	var plugins []*Plugin
+	for _, fi := range infos {
+		if fi.IsDir() || fi.Mode()&0111 == 0 {
+			continue
+		}
+		p, err := Open(filepath.Join(dir, fi.Name()), timeout)
+		if err != nil {
+			return nil, err
+		}
+		if p.hooks[HookColumns] {
+			plugin.RegisterPlugin(p)
+		}
+		if p.hooks[HookPreDeploy] {
+			plugin.RegisterChecker(p)
+		}
+		if p.hooks[HookPostDeploy] {
+			plugin.RegisterHook(p)
+		}
+		glog.Infof("Loaded plugin %s from %s", p.name, p.path)
+		plugins = append(plugins, p)
+	}
+	return plugins, nil
+}
+
+// Name returns the name of the plugin.
+func (p *Plugin) Name() string {
+	return p.name
+}
+
+type projectInfo struct {
+	Name         string   `json:"name"`
+	RepoOwner    string   `json:"repo_owner"`
+	RepoName     string   `json:"repo_name"`
+	Environments []string `json:"environments"`
+}
+
+type columnsRequest struct {
+	Project projectInfo `json:"project"`
+}
+
+type columnsResponse struct {
+	Columns []column `json:"columns"`
+}
+
+// Apply returns the columns of "proj" reported by the plugin.
+// It returns a column which shows the failure instead of an error if the plugin fails, so that the home page keeps working.
+func (p *Plugin) Apply(proj config.Project) ([]plugin.Column, error) {
+	p.mu.Lock()
+	cached, ok := p.columns[proj.Name]
+	p.mu.Unlock()
+	if ok && time.Now().Before(cached.expires) {
+		return cached.columns, nil
+	}
+
+	repo := proj.SourceRepo()
+	req := columnsRequest{Project: projectInfo{Name: proj.Name, RepoOwner: repo.RepoOwner, RepoName: repo.RepoName}}
+	for _, e := range proj.Environments {
+		req.Project.Environments = append(req.Project.Environments, e.Name)
+	}
+	var resp columnsResponse
+	if err := p.call(HookColumns, req, &resp); err != nil {
+		glog.Errorf("Failed to get columns of %s from plugin %s: %v", proj.Name, p.name, err)
+		return []plugin.Column{column{Header: p.name, Detail: "unavailable"}}, nil
+	}
+	cols := make([]plugin.Column, 0, len(resp.Columns))
+	for _, c := range resp.Columns {
+		cols = append(cols, c)
+	}
+	p.mu.Lock()
+	p.columns[proj.Name] = cachedColumns{columns: cols, expires: time.Now().Add(columnsTTL)}
+	p.mu.Unlock()
+	return cols, nil
+}
+
+type checkResponse struct {
+	Allow  bool   `json:"allow"`
+	Reason string `json:"reason"`
+}
+
+// CheckDeploy returns an error if the plugin does not allow "d", or if the plugin fails.
+func (p *Plugin) CheckDeploy(d plugin.Deployment) error {
+	var resp checkResponse
+	if err := p.call(HookPreDeploy, d, &resp); err != nil {
+		return fmt.Errorf("plugin %s failed: %v", p.name, err)
+	}
+	if !resp.Allow {
+		if resp.Reason == "" {
+			resp.Reason = "no reason given"
+		}
+		return fmt.Errorf("rejected by plugin %s: %s", p.name, resp.Reason)
+	}
+	return nil
+}
+
+// AfterDeploy sends "d" to the plugin.
+func (p *Plugin) AfterDeploy(d plugin.Deployment) error {
+	return p.call(HookPostDeploy, d, nil)
+}
+
+// call invokes the plugin with "hook" and "req", and decodes its response into "resp" unless "resp" is nil.
+func (p *Plugin) call(hook string, req, resp interface{}) error {
+	var stdin []byte
+	if req != nil {
+		var err error
+		if stdin, err = json.Marshal(req); err != nil {
+			return err
+		}
+	}
+	cmd := exec.Command(p.path, hook)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = bytes.NewReader(stdin), &stdout, &stderr
+	prepareKill(cmd)
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	done := make(chan error, 1)
+	go func() { done <- cmd.Wait() }()
+	select {
+	case err := <-done:
+		if err != nil {
+			return fmt.Errorf("%s %s: %v: %s", p.path, hook, err, bytes.TrimSpace(stderr.Bytes()))
+		}
+	case <-time.After(p.timeout):
+		if err := kill(cmd); err != nil {
+			glog.Errorf("Failed to kill %s: %v", p.path, err)
+		}
+		<-done
+		return errTimeout
+	}
+	if resp == nil {
+		return nil
+	}
+	if err := json.Unmarshal(stdout.Bytes(), resp); err != nil {
+		return fmt.Errorf("invalid response from %s %s: %v", p.path, hook, err)
+	}
+	return nil
+}
+
+// column is a column reported by an external plugin.
+type column struct {
+	Header string `json:"header"`
+	Detail string `json:"detail"`
+	URL    string `json:"url,omitempty"`
+}
+
+var (
+	headerTemplate = template.Must(template.New("header").Parse(`<th>{{.Header}}</th>`))
+	detailTemplate = template.Must(template.New("detail").Parse(`<td>{{if .URL}}<a href="{{.URL}}">{{.Detail}}</a>{{else}}{{.Detail}}{{end}}</td>`))
+)
+
+func (c column) RenderHeader() (template.HTML, error) {
+	return render(headerTemplate, c)
+}
+
+func (c column) RenderDetail() (template.HTML, error) {
+	return render(detailTemplate, c)
+}
+
+func render(t *template.Template, c column) (template.HTML, error) {
+	var buf bytes.Buffer
+	if err := t.Execute(&buf, c); err != nil {
+		return "", err
+	}
+	return template.HTML(buf.String()), nil
+}
@@ -0,0 +1,113 @@
+package external
+
+import (
+	"html/template"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gengo/goship/lib/config"
+	"github.com/gengo/goship/plugins/plugin"
+)
+
+const testPlugin = `#!/bin/sh
+case "$1" in
+describe)
+	echo '{"name": "test", "hooks": ["columns", "pre_deploy", "post_deploy"]}' ;;
+columns)
+	echo '{"columns": [{"header": "CI", "detail": "<passing>", "url": "https://ci.example.com"}]}' ;;
+pre_deploy)
+	if grep -q '"environment":"production"' ; then
+		echo '{"allow": false, "reason": "CI is failing"}'
+	else
+		echo '{"allow": true}'
+	fi ;;
+post_deploy)
+	cat > "$(dirname "$0")/post_deploy.json" ;;
+slow)
+	sleep 10 ;;
+*)
+	echo "unknown hook $1" >&2
+	exit 1 ;;
+esac
+`
+
+func writePlugin(t *testing.T) (dir, path string) {
+	dir, err := ioutil.TempDir("", "goship-plugin-test")
+	if err != nil {
+		t.Fatalf("ioutil.TempDir failed with %v", err)
+	}
+	path = filepath.Join(dir, "test-plugin")
+	if err := ioutil.WriteFile(path, []byte(testPlugin), 0755); err != nil {
+		t.Fatalf("ioutil.WriteFile(%q) failed with %v", path, err)
+	}
+	return dir, path
+}
+
+func TestPlugin(t *testing.T) {
+	dir, path := writePlugin(t)
+	defer os.RemoveAll(dir)
+
+	p, err := Open(path, time.Second)
+	if err != nil {
+		t.Fatalf("Open(%q) failed with %v; want success", path, err)
+	}
+	if got, want := p.Name(), "test"; got != want {
+		t.Errorf("p.Name() = %q; want %q", got, want)
+	}
+
+	cols, err := p.Apply(config.Project{Name: "proj"})
+	if err != nil {
+		t.Fatalf("p.Apply failed with %v; want success", err)
+	}
+	if len(cols) != 1 {
+		t.Fatalf("len(cols) = %d; want 1", len(cols))
+	}
+	detail, err := cols[0].RenderDetail()
+	if err != nil {
+		t.Fatalf("cols[0].RenderDetail() failed with %v; want success", err)
+	}
+	if got, want := detail, template.HTML(`<td><a href="https://ci.example.com">&lt;passing&gt;</a></td>`); got != want {
+		t.Errorf("cols[0].RenderDetail() = %q; want %q", got, want)
+	}
+
+	d := plugin.Deployment{Project: "proj", Environment: "staging", User: "alice", From: "abc", To: "def"}
+	if err := p.CheckDeploy(d); err != nil {
+		t.Errorf("p.CheckDeploy(%#v) failed with %v; want success", d, err)
+	}
+	d.Environment = "production"
+	if err := p.CheckDeploy(d); err == nil || !strings.Contains(err.Error(), "CI is failing") {
+		t.Errorf("p.CheckDeploy(%#v) returned %v; want rejection with the reason", d, err)
+	}
+
+	d.Success = true
+	if err := p.AfterDeploy(d); err != nil {
+		t.Errorf("p.AfterDeploy(%#v) failed with %v; want success", d, err)
+	}
+	buf, err := ioutil.ReadFile(filepath.Join(dir, "post_deploy.json"))
+	if err != nil {
+		t.Fatalf("post_deploy hook did not receive the deployment: %v", err)
+	}
+	if got, want := string(buf), `"success":true`; !strings.Contains(got, want) {
+		t.Errorf("post_deploy hook received %s; want it to contain %s", got, want)
+	}
+}
+
+func TestPluginFailure(t *testing.T) {
+	dir, path := writePlugin(t)
+	defer os.RemoveAll(dir)
+
+	p, err := Open(path, 100*time.Millisecond)
+	if err != nil {
+		t.Fatalf("Open(%q) failed with %v; want success", path, err)
+	}
+	if err := p.call("unknown", nil, nil); err == nil || !strings.Contains(err.Error(), "unknown hook") {
+		t.Errorf("p.call(%q) returned %v; want failure with stderr", "unknown", err)
+	}
+	if err := p.call("slow", nil, nil); err != errTimeout {
+		t.Errorf("p.call(%q) returned %v; want %v", "slow", err, errTimeout)
+	}
+}
@@ -29,3 +29,7 @@ func (c HelloWorldColumn) RenderDetail() (template.HTML, error) {
 func (p HelloWorldPlugin) Apply(proj config.Project) ([]plugin.Column, error) {
 	return []plugin.Column{p.Column}, nil
 }
+
+func (p HelloWorldPlugin) Name() string {
+	return "helloworld"
+}
@@ -29,3 +29,7 @@ func (c StoryColumn) RenderDetail() (template.HTML, error) {
 func (p PivotalPlugin) Apply(proj config.Project) ([]plugin.Column, error) {
 	return []plugin.Column{p.Column}, nil
 }
+
+func (p PivotalPlugin) Name() string {
+	return "pivotal"
+}
@@ -15,11 +15,71 @@ type Plugin interface {
 	Apply(p config.Project) ([]Column, error)
 }
 
+// Named is an optional interface of Plugin.
+// Columns of named plugins can be shown, hidden or reordered by the name in the home page.
+type Named interface {
+	// Name returns a unique name of the plugin
+	Name() string
+}
+
+// Unnamed is the name of the plugins which do not implement Named.
+const Unnamed = "plugins"
+
+// Name returns the name of "p", or Unnamed if "p" does not implement Named.
+func Name(p Plugin) string {
+	if n, ok := p.(Named); ok {
+		return n.Name()
+	}
+	return Unnamed
+}
+
 // RegisterPlugin registers "p" to Goship.
 func RegisterPlugin(p Plugin) {
 	Plugins = append(Plugins, p)
 }
 
+// Deployment describes a deployment passed to Checker and Hook.
+type Deployment struct {
+	Project     string `json:"project"`
+	Environment string `json:"environment"`
+	User        string `json:"user"`
+	From        string `json:"from"`
+	To          string `json:"to"`
+	// Restart is true if the deployment restarts the environment instead of deploying a revision.
+	Restart bool `json:"restart,omitempty"`
+	// Success tells if the deployment has succeeded. It is meaningful only in Hook.
+	Success bool `json:"success"`
+	// Labels are tags of the deployment given by the user, e.g. "hotfix".
+	Labels []string `json:"labels,omitempty"`
+}
+
+// Checker is the interface which plugins checking deployments before they start must implement.
+type Checker interface {
+	// CheckDeploy returns an error if "d" must not start.
+	CheckDeploy(d Deployment) error
+}
+
+// Hook is the interface which plugins acting after deployments must implement.
+type Hook interface {
+	// AfterDeploy is called when "d" has finished, whether it succeeded or not.
+	AfterDeploy(d Deployment) error
+}
+
+var (
+	Checkers []Checker
+	Hooks    []Hook
+)
+
+// RegisterChecker registers "c" to Goship.
+func RegisterChecker(c Checker) {
+	Checkers = append(Checkers, c)
+}
+
+// RegisterHook registers "h" to Goship.
+func RegisterHook(h Hook) {
+	Hooks = append(Hooks, h)
+}
+
 // Column is an interface that demands a RenderHeader and RenderDetails method to be able to generate a table column (with header and body)
 // See templates/index.html to see how the Header and Render methods are used
 type Column interface {
@@ -51,3 +51,7 @@ func (p TravisPlugin) Apply(proj config.Project) ([]plugin.Column, error) {
 	}
 	return []plugin.Column{c}, nil
 }
+
+func (p TravisPlugin) Name() string {
+	return "travis"
+}
@@ -0,0 +1,169 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/gengo/goship/handlers/commits"
+	"github.com/gengo/goship/lib/auth"
+	"github.com/gengo/goship/lib/config"
+	"github.com/gengo/goship/lib/inventory"
+	"github.com/gengo/goship/lib/logging"
+	"github.com/gengo/goship/lib/revision"
+	"golang.org/x/net/context"
+)
+
+// PromoteHandler promotes a stage of the pipeline of a project:
+// it deploys the revision running in the previous stage, only if the revision has passed through all the earlier stages.
+//
+// e.g. POST http://127.0.0.1:8000/promote?project=admin&environment=production
+type PromoteHandler struct {
+	ecl        config.ETCDInterface
+	dh         DeployHandler
+	newControl commits.ControlFactory
+}
+
+func (h PromoteHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	ctx := logging.FromRequest(context.Background(), r)
+	log := logging.FromContext(ctx)
+
+	c, err := config.Load(h.ecl)
+	if err != nil {
+		log.Errorf("Failed to fetch latest configuration: %v", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	u, err := auth.CurrentUser(r)
+	if err != nil {
+		log.Errorf("Failed to fetch current user: %v", err)
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return
+	}
+	projName, envName := r.FormValue("project"), r.FormValue("environment")
+	proj, err := config.ProjectFromName(c.Projects, projName)
+	if err != nil {
+		http.Error(w, "no such project", http.StatusNotFound)
+		return
+	}
+	env, err := config.EnvironmentFromName(c.Projects, projName, envName)
+	if err != nil {
+		http.Error(w, "no such project/environment", http.StatusNotFound)
+		return
+	}
+	dr, status, err := h.promotion(ctx, c, proj, *env)
+	if err != nil {
+		log.Infof("Rejected promotion of %s-%s requested by %s: %v", projName, envName, u.Name, err)
+		// shows the reason in the deploy page
+		h.dh.broadcast(outputMessage{Project: projName, Environment: envName, StdoutLine: err.Error()})
+		http.Error(w, err.Error(), status)
+		return
+	}
+	dr.labels, dr.reason = r.FormValue("labels"), r.FormValue("reason")
+	log.Infof("%s is promoting %s to %s-%s", u.Name, dr.deploy.To, projName, envName)
+	h.dh.serve(ctx, w, c, u, dr)
+}
+
+// promotion returns the deployment which promotes "env" of "proj".
+// It returns the HTTP status which describes the error if "env" cannot be promoted.
+func (h PromoteHandler) promotion(ctx context.Context, c config.Config, proj config.Project, env config.Environment) (deployRequest, int, error) {
+	stages := proj.EarlierStages(env.Name)
+	if len(stages) == 0 {
+		return deployRequest{}, http.StatusBadRequest, fmt.Errorf("%s is not a stage after the first in the pipeline of %s", env.Name, proj.Name)
+	}
+	prev := stages[len(stages)-1]
+	rev, err := lastDeployed(fmt.Sprintf("%s-%s", proj.Name, prev))
+	if err != nil {
+		return deployRequest{}, http.StatusInternalServerError, err
+	}
+	if rev == "" {
+		return deployRequest{}, http.StatusConflict, fmt.Errorf("nothing has been deployed to %s successfully", prev)
+	}
+	missing, err := undeployed(proj, stages[:len(stages)-1], rev)
+	if err != nil {
+		return deployRequest{}, http.StatusInternalServerError, err
+	}
+	if len(missing) > 0 {
+		return deployRequest{}, http.StatusConflict, fmt.Errorf("%s in %s has not passed through %s", rev.Short(), prev, strings.Join(missing, ", "))
+	}
+
+	dr := deployRequest{project: proj.Name, environment: env.Name, deploy: RevRange{To: rev}}
+	if err := fillRevisions(ctx, h.newControl, c, proj, env, &dr); err != nil {
+		return deployRequest{}, http.StatusInternalServerError, err
+	}
+	if dr.deploy.From == rev {
+		return deployRequest{}, http.StatusConflict, fmt.Errorf("%s is already deployed to %s", rev.Short(), env.Name)
+	}
+	prevEnv, err := config.EnvironmentFromName(c.Projects, proj.Name, prev)
+	if err != nil {
+		return deployRequest{}, http.StatusInternalServerError, err
+	}
+	if dr.src.To, err = h.sourceRevision(ctx, c, proj, *prevEnv, rev); err != nil {
+		// the revision is still deployable without its source revision, e.g. its commit message
+		logging.FromContext(ctx).Warningf("Failed to find the source revision of %s in %s-%s: %v", rev, proj.Name, prev, err)
+	}
+	return dr, 0, nil
+}
+
+// sourceRevision returns the source revision of "rev" running on the first host of "env",
+// or an empty revision if the host runs another revision.
+func (h PromoteHandler) sourceRevision(ctx context.Context, c config.Config, proj config.Project, env config.Environment, rev revision.Revision) (revision.Revision, error) {
+	env, err := inventory.Cached(ctx, env)
+	if err != nil {
+		return "", err
+	}
+	if len(env.Hosts) == 0 {
+		return "", nil
+	}
+	ctrl, err := h.newControl(proj, c.SSHConfig(proj, env))
+	if err != nil {
+		return "", err
+	}
+	running, src, err := ctrl.LatestDeployed(ctx, env.Hosts[0], proj, env)
+	if err != nil || running != rev {
+		return "", err
+	}
+	return src, nil
+}
+
+// pipelineStage is the state of a stage in a pipeline shown in the home page.
+type pipelineStage struct {
+	Name string
+	// Revision is the revision deployed by the last successful deployment of the stage, or empty if none.
+	Revision revision.Revision
+	// Promotable is true if the previous stage runs another revision, which can be promoted to the stage unless Blocked.
+	Promotable bool
+	// Blocked describes why the revision in the previous stage cannot be promoted, if so.
+	Blocked string
+}
+
+// pipelineStages returns the states of the stages in the pipeline of "proj" in order.
+func pipelineStages(proj config.Project) ([]pipelineStage, error) {
+	var stages []pipelineStage
+	for i, name := range proj.Pipeline {
+		rev, err := lastDeployed(fmt.Sprintf("%s-%s", proj.Name, name))
+		if err != nil {
+			return nil, err
+		}
+		s := pipelineStage{Name: name, Revision: rev}
+		if i > 0 {
+			prev := stages[i-1].Revision
+			s.Promotable = prev != "" && prev != rev
+		}
+		if s.Promotable {
+			missing, err := undeployed(proj, proj.Pipeline[:i-1], stages[i-1].Revision)
+			if err != nil {
+				return nil, err
+			}
+			if len(missing) > 0 {
+				s.Blocked = fmt.Sprintf("%s has not passed through %s", stages[i-1].Revision.Short(), strings.Join(missing, ", "))
+			}
+		}
+		stages = append(stages, s)
+	}
+	return stages, nil
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gengo/goship/lib/audit"
+	"github.com/gengo/goship/lib/auth"
+	"github.com/gengo/goship/lib/config"
+	helpers "github.com/gengo/goship/lib/view-helpers"
+	"github.com/golang/glog"
+)
+
+// ReloadHandler reloads the cached configuration from etcd.
+// It is a fallback for the cases where the cache misses changes, e.g. when etcd cannot be watched.
+// Only admins can reload, with POST.
+//
+// e.g. POST http://127.0.0.1:8000/admin/reload
+type ReloadHandler struct {
+	cache *config.Cache
+}
+
+type reloadResponse struct {
+	Projects int       `json:"projects"`
+	LoadedAt time.Time `json:"loaded_at"`
+}
+
+func (h ReloadHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	u, err := auth.CurrentUser(r)
+	if err != nil {
+		glog.Errorf("Failed to get current user: %v", err)
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return
+	}
+	c, err := h.cache.Config()
+	if err != nil {
+		glog.Errorf("Failed to load configuration: %v", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if !c.IsAdmin(u.Name) {
+		glog.Errorf("%s is not allowed to reload the configuration", u.Name)
+		http.Error(w, "only admins can reload the configuration", http.StatusForbidden)
+		return
+	}
+
+	rec := audit.Record{Actor: u.Name, Action: audit.ActionConfigReloaded, Result: audit.ResultSuccess}
+	c, reloadErr := h.cache.Reload()
+	if reloadErr != nil {
+		rec.Result, rec.Detail = audit.ResultFailure, reloadErr.Error()
+	}
+	if err := audit.Append(h.cache, rec); err != nil {
+		glog.Errorf("Failed to record reload by %s in the audit log: %v", u.Name, err)
+	}
+	if reloadErr != nil {
+		glog.Errorf("Failed to reload configuration: %v", reloadErr)
+		http.Error(w, reloadErr.Error(), http.StatusInternalServerError)
+		return
+	}
+	glog.Infof("%s reloaded the configuration", u.Name)
+
+	helpers.RespondWithJSON(w, http.StatusOK, reloadResponse{Projects: len(c.Projects), LoadedAt: h.cache.LoadedAt()})
+}
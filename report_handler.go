@@ -0,0 +1,186 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gengo/goship/lib/acl"
+	"github.com/gengo/goship/lib/auth"
+	"github.com/gengo/goship/lib/config"
+	"github.com/gengo/goship/lib/notification"
+	"github.com/gengo/goship/lib/report"
+	helpers "github.com/gengo/goship/lib/view-helpers"
+	"github.com/golang/glog"
+	"golang.org/x/net/context"
+)
+
+// WeeklyReportHandler serves the summary of deployments in a week, which is also emailed to teams every Monday.
+// It summarizes the projects in the weekly report of "team", or a single "project".
+// "week" is any day of the week to summarize in YYYY-MM-DD. The last whole week is summarized if omitted.
+// Only the projects and environments the user can see are summarized.
+//
+// e.g. GET http://127.0.0.1:8000/api/reports/weekly?team=platform&week=2015-11-02
+type WeeklyReportHandler struct {
+	ac  acl.AccessControl
+	ecl config.ETCDInterface
+}
+
+func (h WeeklyReportHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	u, err := auth.CurrentUser(r)
+	if err != nil {
+		glog.Errorf("Failed to get current user: %v", err)
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return
+	}
+	now := time.Now()
+	if s := r.FormValue("week"); s != "" {
+		day, err := time.ParseInLocation("2006-01-02", s, time.Local)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("invalid week %q", s), http.StatusBadRequest)
+			return
+		}
+		now = day.AddDate(0, 0, 7)
+	}
+	c, err := config.Load(h.ecl)
+	if err != nil {
+		glog.Errorf("Failed to load configuration: %v", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	readable := c.VisibleProjects(acl.ReadableProjects(h.ac, c.Projects, u), u.Name)
+	var projs []config.Project
+	team, projName := r.FormValue("team"), r.FormValue("project")
+	switch {
+	case team != "" && projName == "":
+		wr, err := c.WeeklyReportFromTeam(team)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		for _, name := range wr.Projects {
+			if p, err := config.ProjectFromName(readable, name); err == nil {
+				projs = append(projs, p)
+			}
+		}
+	case projName != "" && team == "":
+		p, err := config.ProjectFromName(readable, projName)
+		if err != nil {
+			http.Error(w, "no such project", http.StatusNotFound)
+			return
+		}
+		projs = []config.Project{p}
+	default:
+		http.Error(w, "either team or project must be specified", http.StatusBadRequest)
+		return
+	}
+
+	start, end := report.Week(now)
+	rep, err := weeklyReport(team, projs, start, end)
+	if err != nil {
+		glog.Errorf("Failed to summarize deployments: %v", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	helpers.RespondWithJSON(w, http.StatusOK, rep)
+}
+
+// weeklyReport summarizes the deployments to "projs" from "start" until "end" in the deploy logs.
+func weeklyReport(team string, projs []config.Project, start, end time.Time) (report.Report, error) {
+	rep := report.Report{Team: team, Start: start, End: end, Projects: []report.Summary{}}
+	for _, p := range projs {
+		deploys, err := deploysBetween(p, start, end)
+		if err != nil {
+			return report.Report{}, err
+		}
+		rep.Projects = append(rep.Projects, report.Summarize(p.Name, deploys))
+	}
+	return rep, nil
+}
+
+// deploysBetween returns the deployments to the environments of "p" from "start" until "end" in the deploy logs.
+func deploysBetween(p config.Project, start, end time.Time) ([]report.Deploy, error) {
+	var deploys []report.Deploy
+	for _, e := range p.Environments {
+		entries, err := history.between(fmt.Sprintf("%s-%s", p.Name, e.Name), start, end)
+		if err != nil {
+			return nil, err
+		}
+		for _, entry := range entries {
+			deploys = append(deploys, report.Deploy{
+				Project:     p.Name,
+				Environment: e.Name,
+				User:        entry.User,
+				To:          entry.Range.To,
+				Success:     entry.Success,
+				Time:        entry.Time,
+				Duration:    entry.Duration,
+				Skipped:     entry.Skipped,
+			})
+		}
+	}
+	return deploys, nil
+}
+
+// sendWeeklyReports emails the weekly reports of the last week to the teams which have not received them yet.
+// Reports are sent at most once per week even if Goship restarts because the last week sent is recorded in etcd.
+func sendWeeklyReports(ecl config.ETCDInterface, now time.Time) {
+	c, err := config.Load(ecl)
+	if err != nil {
+		glog.Errorf("Failed to load configuration: %v", err)
+		return
+	}
+	if c.SMTP == nil {
+		return
+	}
+	start, end := report.Week(now)
+	for _, wr := range c.WeeklyReports {
+		sent, err := config.LoadReportSent(ecl, wr.Team)
+		if err != nil {
+			glog.Errorf("Failed to load the last weekly report sent to %s: %v", wr.Team, err)
+			continue
+		}
+		if !sent.Before(end) {
+			continue
+		}
+		var projs []config.Project
+		for _, name := range wr.Projects {
+			p, err := config.ProjectFromName(c.Projects, name)
+			if err != nil {
+				glog.Errorf("Weekly report of %s refers to unknown project %s", wr.Team, name)
+				continue
+			}
+			projs = append(projs, p)
+		}
+		rep, err := weeklyReport(wr.Team, projs, start, end)
+		if err != nil {
+			glog.Errorf("Failed to summarize deployments for %s: %v", wr.Team, err)
+			continue
+		}
+		subject := fmt.Sprintf("[goship] Weekly deploy report of %s, %s", wr.Team, start.Format("Jan 2, 2006"))
+		if err := notification.SendEmail(*c.SMTP, wr.To, subject, rep.Text()); err != nil {
+			glog.Errorf("Failed to send the weekly report to %s: %v", wr.Team, err)
+			continue
+		}
+		if err := config.StoreReportSent(ecl, wr.Team, end); err != nil {
+			glog.Errorf("Failed to record the weekly report sent to %s: %v", wr.Team, err)
+		}
+	}
+}
+
+// runWeeklyReports sends the weekly reports every "interval" until "ctx" is done.
+func runWeeklyReports(ctx context.Context, ecl config.ETCDInterface, interval time.Duration) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(interval):
+		}
+		sendWeeklyReports(ecl, time.Now())
+	}
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/gengo/goship/lib/cluster"
+	deploypkg "github.com/gengo/goship/lib/deploy"
+	"github.com/gengo/goship/lib/notification"
+	"github.com/golang/glog"
+	"golang.org/x/net/context"
+)
+
+const (
+	// canceledDeployTimeout is how long shutdowns wait for deployments to record their failures after canceling them.
+	canceledDeployTimeout = 30 * time.Second
+	// hubCloseTimeout is how long shutdowns wait for push notification connections to close.
+	hubCloseTimeout = 5 * time.Second
+)
+
+// inflight is the work of the server which graceful shutdowns wait for.
+type inflight struct {
+	deploys *deploypkg.Drain
+	running *deploypkg.Running
+	hub     *notification.Hub
+	// cluster is the cluster which this instance leaves on shutdown, or nil if it runs alone.
+	cluster *cluster.Cluster
+}
+
+// shutdown stops serving "s" on "l", waits up to -shutdown-timeout for the deployments in flight and their outputs to be written,
+// and then closes push notifications and background loops by calling "stop" and leaves the cluster.
+// Deployments still running after the timeout are canceled so that they are recorded as failed rather than killed silently.
+// It returns the exit status of the process: 0 if all the deployments finished, and 1 otherwise.
+func shutdown(s *http.Server, l net.Listener, in inflight, stop context.CancelFunc) int {
+	status := 0
+	s.SetKeepAlivesEnabled(false)
+	if err := l.Close(); err != nil {
+		glog.Errorf("Failed to close the listener: %v", err)
+	}
+	if n := in.deploys.InFlight(); n > 0 {
+		glog.Infof("Waiting up to %s for %d deployment(s) in flight", *shutdownTimeout, n)
+	}
+	if !in.deploys.Wait(*shutdownTimeout) {
+		status = 1
+		for _, r := range in.running.All() {
+			glog.Warningf("Canceling deployment %s of %s-%s by %s", r.ID, r.Project, r.Environment, r.Owner)
+			if err := in.running.Cancel(r.ID, "goship shutdown"); err != nil {
+				glog.Errorf("Failed to cancel deployment %s: %v", r.ID, err)
+			}
+		}
+		if !in.deploys.Wait(canceledDeployTimeout) {
+			glog.Errorf("%d deployment(s) did not finish after being canceled", in.deploys.InFlight())
+		}
+	}
+
+	stop()
+	if in.cluster != nil {
+		in.cluster.Leave()
+	}
+	select {
+	case <-in.hub.Done():
+	case <-time.After(hubCloseTimeout):
+		glog.Warningf("Timed out closing push notification connections")
+	}
+	glog.Infof("Goship stopped")
+	glog.Flush()
+	return status
+}
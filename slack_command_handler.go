@@ -0,0 +1,249 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/gengo/goship/handlers/commits"
+	"github.com/gengo/goship/lib/acl"
+	"github.com/gengo/goship/lib/auth"
+	"github.com/gengo/goship/lib/config"
+	"github.com/gengo/goship/lib/inventory"
+	"github.com/gengo/goship/lib/notification"
+	"github.com/gengo/goship/lib/revision"
+	helpers "github.com/gengo/goship/lib/view-helpers"
+	"github.com/golang/glog"
+	"golang.org/x/net/context"
+)
+
+// maxSlackCommandPayload is the maximum size of slash command requests from Slack.
+const maxSlackCommandPayload = 1 << 20
+
+// slackCommandUsage describes the slash commands.
+const slackCommandUsage = "Usage:\n" +
+	"`/goship deploy <project> <environment> [revision]` deploys the revision, or the latest one of the environment if omitted.\n" +
+	"`/goship status <project>` shows the deployments, locks and pins of the environments."
+
+// SlackCommandHandler serves slash commands of Slack, e.g. "/goship deploy myproject staging".
+// Requests must be signed with the signing secret of the Slack app, and the Slack user must be mapped to an active Goship user,
+// whose permissions are checked in the same way as in the web UI.
+// Results are posted to the channel where the command was invoked.
+// It must not be wrapped by auth.Authenticate because Slack calls it directly.
+type SlackCommandHandler struct {
+	ac         acl.AccessControl
+	ecl        config.ETCDInterface
+	dh         DeployHandler
+	newControl commits.ControlFactory
+}
+
+// slackCommandResponse is the immediate response to a slash command.
+type slackCommandResponse struct {
+	ResponseType string `json:"response_type"`
+	Text         string `json:"text"`
+}
+
+func (h SlackCommandHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	c, err := config.Load(h.ecl)
+	if err != nil {
+		glog.Errorf("Failed to load configuration: %v", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if c.Slack == nil || c.Slack.SigningSecret == "" {
+		http.Error(w, "slack is not configured", http.StatusNotFound)
+		return
+	}
+	body, err := ioutil.ReadAll(http.MaxBytesReader(w, r.Body, maxSlackCommandPayload))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := notification.VerifySlackRequest(c.Slack.SigningSecret, r.Header, body, time.Now()); err != nil {
+		glog.Errorf("Rejected a slash command from Slack: %v", err)
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return
+	}
+	form, err := url.ParseQuery(string(body))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	slackUser := form.Get("user_id")
+	name, ok := c.Slack.Users[slackUser]
+	if !ok {
+		glog.Errorf("Slack user %s (%s) is not mapped to any Goship user", slackUser, form.Get("user_name"))
+		writeSlackCommandResponse(w, "ephemeral", fmt.Sprintf("Slack user %s is not mapped to any Goship user.", form.Get("user_name")))
+		return
+	}
+	u, err := loadUser(h.ecl, name)
+	if err != nil {
+		writeSlackCommandResponse(w, "ephemeral", err.Error())
+		return
+	}
+
+	args := strings.Fields(form.Get("text"))
+	switch {
+	case len(args) == 2 && args[0] == "status":
+		// only the user sees the status, which is limited to the projects the user can read
+		writeSlackCommandResponse(w, "ephemeral", h.status(c, u, args[1]))
+	case (len(args) == 3 || len(args) == 4) && args[0] == "deploy":
+		proj, env, err := h.visibleEnvironment(c, u, args[1], args[2])
+		if err != nil {
+			writeSlackCommandResponse(w, "ephemeral", err.Error())
+			return
+		}
+		var rev revision.Revision
+		if len(args) == 4 {
+			rev = revision.Revision(args[3])
+		}
+		glog.Infof("%s requested deployment of %s to %s-%s from Slack", u.Name, rev, proj.Name, env.Name)
+		go h.deploy(c, u, proj, env, rev, form.Get("response_url"))
+		// the channel is told about the deployment once it is allowed
+		writeSlackCommandResponse(w, "ephemeral", fmt.Sprintf("Preparing to deploy %s to *%s*.", proj.Name, env.Name))
+	default:
+		writeSlackCommandResponse(w, "ephemeral", slackCommandUsage)
+	}
+}
+
+// visibleProject returns the project "projName" if "u" can read and see it.
+// Its error describes projects which do not exist and ones hidden from "u" in the same way.
+func (h SlackCommandHandler) visibleProject(c config.Config, u auth.User, projName string) (config.Project, error) {
+	projs := c.VisibleProjects(acl.ReadableProjects(h.ac, c.Projects, u), u.Name)
+	proj, err := config.ProjectFromName(projs, projName)
+	if err != nil {
+		return config.Project{}, fmt.Errorf("No such project %s.", projName)
+	}
+	return proj, nil
+}
+
+// visibleEnvironment returns the environment "envName" of "projName" if "u" can see them.
+// Its error describes environments which do not exist and ones hidden from "u" in the same way.
+func (h SlackCommandHandler) visibleEnvironment(c config.Config, u auth.User, projName, envName string) (config.Project, config.Environment, error) {
+	proj, err := h.visibleProject(c, u, projName)
+	if err != nil {
+		return config.Project{}, config.Environment{}, err
+	}
+	env, err := config.EnvironmentFromName([]config.Project{proj}, projName, envName)
+	if err != nil || !c.EnvironmentVisible(*env, u.Name) {
+		return config.Project{}, config.Environment{}, fmt.Errorf("No such project/environment %s-%s.", projName, envName)
+	}
+	return proj, *env, nil
+}
+
+// status describes the environments of "projName" which "u" can see.
+func (h SlackCommandHandler) status(c config.Config, u auth.User, projName string) string {
+	proj, err := h.visibleProject(c, u, projName)
+	if err != nil {
+		return err.Error()
+	}
+	s := localStatus(h.ecl, h.dh.progress, c, []config.Project{proj})
+	lines := []string{fmt.Sprintf("*%s*", proj.Name)}
+	for _, e := range s.Projects[0].Environments {
+		line := fmt.Sprintf("• *%s*: ", e.Name)
+		if d := e.LastDeploy; d != nil {
+			result := "succeeded"
+			if !d.Success {
+				result = "failed"
+			}
+			line += fmt.Sprintf("%s by %s %s (%s)", d.To.Short(), d.User, formatTime(d.Time), result)
+		} else {
+			line += "never deployed"
+		}
+		if e.State != "" {
+			line += fmt.Sprintf(", %s now", e.State)
+		}
+		if e.Lock != nil {
+			line += fmt.Sprintf(", locked by %s: %s", e.Lock.User, e.Lock.Reason)
+		}
+		pin, err := config.LoadPin(h.ecl, proj.Name, e.Name)
+		if err != nil {
+			glog.Errorf("Failed to load pin of %s-%s: %v", proj.Name, e.Name, err)
+		}
+		if pin != nil {
+			line += fmt.Sprintf(", pinned to %s by %s", revision.Revision(pin.Revision).Short(), pin.User)
+		}
+		lines = append(lines, line)
+	}
+	return strings.Join(lines, "\n")
+}
+
+// deploy deploys "rev", or the latest revision of the environment if empty, to "env" of "proj" on behalf of "u".
+// It posts the progress to "responseURL" because deployments take longer than Slack waits for the response.
+func (h SlackCommandHandler) deploy(c config.Config, u auth.User, proj config.Project, env config.Environment, rev revision.Revision, responseURL string) {
+	reply := func(format string, args ...interface{}) {
+		if err := notification.ReplySlack(responseURL, fmt.Sprintf(format, args...)); err != nil {
+			glog.Errorf("Failed to reply to a slash command: %v", err)
+		}
+	}
+	ctx := context.Background()
+	projName, envName := proj.Name, env.Name
+	dr := deployRequest{project: projName, environment: envName, deploy: RevRange{To: rev}}
+	if err := fillRevisions(ctx, h.newControl, c, proj, env, &dr); err != nil {
+		glog.Errorf("Failed to find revisions of %s-%s: %v", projName, envName, err)
+		reply("Failed to find the revisions to deploy %s to *%s*: %v", projName, envName, err)
+		return
+	}
+	req, _, err := h.dh.prepare(ctx, c, u, dr)
+	if err != nil {
+		reply("Cannot deploy %s to *%s*: %v", projName, envName, err)
+		return
+	}
+	if env.RequireApproval {
+		a, err := h.dh.requestApproval(ctx, req, dr)
+		if err != nil {
+			reply("Failed to request approval to deploy %s to *%s*: %v", projName, envName, err)
+			return
+		}
+		reply("%s requested to deploy %s to *%s* (%s...%s), which is waiting for approval %s.", u.Name, projName, envName, req.From.Short(), req.To.Short(), a.ID)
+		return
+	}
+	reply("%s is deploying %s to *%s* (%s...%s).", u.Name, projName, envName, req.From.Short(), req.To.Short())
+	d, err := h.dh.deploy(ctx, c, req, dr.src)
+	switch {
+	case err != nil:
+		reply("The deployment of %s to *%s* failed: %v", projName, envName, err)
+	case !d.Success:
+		reply("The deployment of %s to *%s* failed.", projName, envName)
+	default:
+		reply("%s successfully deployed to *%s*.", projName, envName)
+	}
+}
+
+// fillRevisions fills the revisions of "dr" which are not given: the latest revision of "env" to deploy,
+// and the revision deployed by the last successful deployment, or the one on the first host if Goship has never deployed it.
+func fillRevisions(ctx context.Context, newControl commits.ControlFactory, c config.Config, proj config.Project, env config.Environment, dr *deployRequest) error {
+	ctrl, err := newControl(proj, c.SSHConfig(proj, env))
+	if err != nil {
+		return err
+	}
+	if dr.deploy.To == "" {
+		if dr.deploy.To, dr.src.To, err = ctrl.Latest(ctx, proj, env); err != nil {
+			return err
+		}
+	}
+	if dr.deploy.From, err = lastDeployed(fmt.Sprintf("%s-%s", proj.Name, env.Name)); err != nil {
+		return err
+	}
+	if env, err = inventory.Cached(ctx, env); err != nil {
+		return err
+	}
+	if dr.deploy.From == "" && len(env.Hosts) > 0 {
+		if dr.deploy.From, _, err = ctrl.LatestDeployed(ctx, env.Hosts[0], proj, env); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func writeSlackCommandResponse(w http.ResponseWriter, responseType, text string) {
+	helpers.RespondWithJSON(w, http.StatusOK, slackCommandResponse{ResponseType: responseType, Text: text})
+}
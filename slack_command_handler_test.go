@@ -0,0 +1,44 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/gengo/goship/lib/auth"
+	"github.com/gengo/goship/lib/config"
+)
+
+func TestSlackVisibleEnvironment(t *testing.T) {
+	c := config.Config{
+		Admins: []string{"alice"},
+		Projects: []config.Project{{
+			Name: "proj",
+			Environments: []config.Environment{
+				{Name: "staging"},
+				{Name: "production", Hidden: true},
+			},
+		}},
+	}
+	h := SlackCommandHandler{ac: readers{}}
+	tests := []struct {
+		user, proj, env string
+		want            string
+	}{
+		{user: "bob", proj: "proj", env: "staging"},
+		{user: "alice", proj: "proj", env: "production"},
+		{user: "bob", proj: "proj", env: "production", want: "No such project/environment proj-production."},
+		{user: "bob", proj: "proj", env: "qa", want: "No such project/environment proj-qa."},
+		{user: "bob", proj: "other", env: "staging", want: "No such project other."},
+	}
+	for _, tt := range tests {
+		proj, env, err := h.visibleEnvironment(c, auth.User{Name: tt.user}, tt.proj, tt.env)
+		if tt.want == "" {
+			if err != nil || proj.Name != tt.proj || env.Name != tt.env {
+				t.Errorf("visibleEnvironment(%q, %q) for %s = %q, %q, %v; want success", tt.proj, tt.env, tt.user, proj.Name, env.Name, err)
+			}
+			continue
+		}
+		if err == nil || err.Error() != tt.want {
+			t.Errorf("visibleEnvironment(%q, %q) for %s failed with %v; want %q", tt.proj, tt.env, tt.user, err, tt.want)
+		}
+	}
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gengo/goship/lib/acl"
+	"github.com/gengo/goship/lib/auth"
+	"github.com/gengo/goship/lib/config"
+	"github.com/gengo/goship/lib/report"
+	helpers "github.com/gengo/goship/lib/view-helpers"
+	"github.com/golang/glog"
+)
+
+const (
+	// defaultTimelineRange is the range of timelines whose "since" is omitted.
+	defaultTimelineRange = 30 * 24 * time.Hour
+	// defaultTimelineBucket is the width of buckets of timelines whose "bucket" is omitted.
+	defaultTimelineBucket = 24 * time.Hour
+)
+
+// TimelineHandler serves deployments counted per environment in time buckets, for charts of deployment timelines.
+// "project" and "environment" optionally narrow down the environments. All the projects the user can see are counted otherwise.
+// "since" and "until" are in RFC 3339 or in milliseconds since the epoch, e.g. $__from and $__to of Grafana.
+// They default to the last 30 days. "bucket" is the width of buckets, e.g. "1h", and defaults to a day.
+// With "format=grafana", the counts are served as time series of Grafana's JSON data sources instead of report.Timeline.
+//
+// e.g. GET http://127.0.0.1:8000/api/reports/timeline?project=admin&since=2015-11-01T00:00:00Z&until=2015-11-08T00:00:00Z&bucket=6h
+type TimelineHandler struct {
+	ac  acl.AccessControl
+	ecl config.ETCDInterface
+}
+
+func (h TimelineHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	u, err := auth.CurrentUser(r)
+	if err != nil {
+		glog.Errorf("Failed to get current user: %v", err)
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return
+	}
+	until, err := parseTimelineTime(r.FormValue("until"), time.Now())
+	if err != nil {
+		http.Error(w, fmt.Sprintf("invalid until: %v", err), http.StatusBadRequest)
+		return
+	}
+	since, err := parseTimelineTime(r.FormValue("since"), until.Add(-defaultTimelineRange))
+	if err != nil {
+		http.Error(w, fmt.Sprintf("invalid since: %v", err), http.StatusBadRequest)
+		return
+	}
+	bucket := defaultTimelineBucket
+	if s := r.FormValue("bucket"); s != "" {
+		if bucket, err = time.ParseDuration(s); err != nil {
+			http.Error(w, fmt.Sprintf("invalid bucket %q", s), http.StatusBadRequest)
+			return
+		}
+	}
+	c, err := config.Load(h.ecl)
+	if err != nil {
+		glog.Errorf("Failed to load configuration: %v", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	projs := c.VisibleProjects(acl.ReadableProjects(h.ac, c.Projects, u), u.Name)
+	projName, envName := r.FormValue("project"), r.FormValue("environment")
+	switch {
+	case projName != "":
+		p, err := config.ProjectFromName(projs, projName)
+		if err != nil {
+			http.Error(w, "no such project", http.StatusNotFound)
+			return
+		}
+		if envName != "" {
+			e, err := config.EnvironmentFromName(projs, projName, envName)
+			if err != nil {
+				http.Error(w, "no such project/environment", http.StatusNotFound)
+				return
+			}
+			p.Environments = []config.Environment{*e}
+		}
+		projs = []config.Project{p}
+	case envName != "":
+		http.Error(w, "environment requires project", http.StatusBadRequest)
+		return
+	}
+
+	var deploys []report.Deploy
+	for _, p := range projs {
+		d, err := deploysBetween(p, since, until)
+		if err != nil {
+			glog.Errorf("Failed to read deployments of %s: %v", p.Name, err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		deploys = append(deploys, d...)
+	}
+	tl, err := report.NewTimeline(deploys, since, until, bucket)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	var obj interface{} = tl
+	if r.FormValue("format") == "grafana" {
+		obj = tl.Datapoints()
+	}
+	helpers.RespondWithJSON(w, http.StatusOK, obj)
+}
+
+// parseTimelineTime parses "s" in RFC 3339 or in milliseconds since the epoch. It returns "def" if "s" is empty.
+func parseTimelineTime(s string, def time.Time) (time.Time, error) {
+	if s == "" {
+		return def, nil
+	}
+	if ms, err := strconv.ParseInt(s, 10, 64); err == nil {
+		return time.Unix(ms/1000, ms%1000*int64(time.Millisecond)), nil
+	}
+	return time.Parse(time.RFC3339, s)
+}
@@ -0,0 +1,198 @@
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/gengo/goship/lib/auth"
+	"github.com/gengo/goship/lib/config"
+	"github.com/golang/glog"
+	"golang.org/x/crypto/acme/autocert"
+)
+
+// tlsEnabled returns true if Goship serves HTTPS.
+func tlsEnabled() bool {
+	return *tlsCert != "" || *autocertDomains != ""
+}
+
+// validateTLSFlags returns an error if the TLS flags are inconsistent.
+func validateTLSFlags() error {
+	if (*tlsCert == "") != (*tlsKey == "") {
+		return errors.New("-tls-cert and -tls-key must be given together")
+	}
+	if *tlsCert != "" && *autocertDomains != "" {
+		return errors.New("-tls-cert and -autocert-domains are exclusive")
+	}
+	if *httpRedirect != "" && !tlsEnabled() {
+		return errors.New("-http-redirect requires -tls-cert or -autocert-domains")
+	}
+	if *tlsClientCA != "" && !tlsEnabled() {
+		return errors.New("-tls-client-ca requires -tls-cert or -autocert-domains")
+	}
+	return nil
+}
+
+// pushAddress returns the absolute URL of the websocket endpoint of push notifications.
+// It is "wss" if TLS is enabled.
+func pushAddress() string {
+	scheme := "ws"
+	if tlsEnabled() {
+		scheme = "wss"
+	}
+	addr := *bindAddress
+	if host, port, err := net.SplitHostPort(addr); err == nil && host == "" {
+		host = "localhost"
+		if domains := autocertHosts(); len(domains) > 0 {
+			host = domains[0]
+		}
+		addr = net.JoinHostPort(host, port)
+	}
+	return fmt.Sprintf("%s://%s/web_push", scheme, addr)
+}
+
+// autocertHosts returns the domains given in -autocert-domains.
+func autocertHosts() []string {
+	var hosts []string
+	for _, d := range strings.Split(*autocertDomains, ",") {
+		if d = strings.TrimSpace(d); d != "" {
+			hosts = append(hosts, d)
+		}
+	}
+	return hosts
+}
+
+// listen returns the listener of "s", which accepts HTTPS if enabled and plain HTTP otherwise.
+// It also starts serving redirects to HTTPS at -http-redirect if given.
+// The caller serves "s" on the listener, and closes it to stop accepting connections.
+func listen(s *http.Server) (net.Listener, error) {
+	tl, err := net.Listen("tcp", s.Addr)
+	if err != nil {
+		return nil, err
+	}
+	l := net.Listener(tcpKeepAliveListener{tl.(*net.TCPListener)})
+	if !tlsEnabled() {
+		return l, nil
+	}
+
+	cfg := new(tls.Config)
+	redirect := http.Handler(http.HandlerFunc(redirectToHTTPS))
+	if hosts := autocertHosts(); len(hosts) > 0 {
+		m := &autocert.Manager{
+			Prompt:     autocert.AcceptTOS,
+			Cache:      autocert.DirCache(filepath.Join(*dataPath, "autocert")),
+			HostPolicy: autocert.HostWhitelist(hosts...),
+			Email:      *autocertEmail,
+		}
+		cfg.GetCertificate = m.GetCertificate
+		// Let's Encrypt verifies the domains through the HTTP port
+		redirect = m.HTTPHandler(redirect)
+	} else {
+		cert, err := tls.LoadX509KeyPair(*tlsCert, *tlsKey)
+		if err != nil {
+			l.Close()
+			return nil, err
+		}
+		cfg.Certificates = []tls.Certificate{cert}
+	}
+	if *tlsClientCA != "" {
+		pool, err := loadCertPool(*tlsClientCA)
+		if err != nil {
+			l.Close()
+			return nil, err
+		}
+		// browsers without certificates still log in with sessions
+		cfg.ClientCAs, cfg.ClientAuth = pool, tls.VerifyClientCertIfGiven
+	}
+	if *httpRedirect != "" {
+		go func() {
+			glog.Infof("Redirecting HTTP on %s to HTTPS", *httpRedirect)
+			if err := http.ListenAndServe(*httpRedirect, redirect); err != nil {
+				glog.Errorf("Failed to serve HTTP redirects: %v", err)
+			}
+		}()
+	}
+	s.TLSConfig = cfg
+	return tls.NewListener(l, cfg), nil
+}
+
+// loadCertPool returns the pool of the PEM-encoded certificates in the file at "path".
+func loadCertPool(path string) (*x509.CertPool, error) {
+	buf, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(buf) {
+		return nil, fmt.Errorf("no certificates in %s", path)
+	}
+	return pool, nil
+}
+
+// machinePath returns true if machine clients can be authenticated by client certificates at "path".
+// They are the APIs and the endpoints which trigger deployments, quick actions and locks.
+// Users log in with sessions everywhere else even if their browsers send certificates.
+func machinePath(path string) bool {
+	switch path {
+	case "/deploy_handler", "/promote", "/action", "/lock", "/unlock":
+		return true
+	}
+	return strings.HasPrefix(path, "/api/")
+}
+
+// clientCertUser returns a function which returns the user mapped to the verified client certificate of a request in client_certs of the configuration in "ecl".
+// It refuses certificates which are mapped to no user.
+func clientCertUser(ecl config.ETCDInterface) func(*http.Request, *x509.Certificate) (auth.User, bool, error) {
+	return func(r *http.Request, cert *x509.Certificate) (auth.User, bool, error) {
+		if !machinePath(r.URL.Path) {
+			return auth.User{}, false, nil
+		}
+		c, err := config.Load(ecl)
+		if err != nil {
+			glog.Errorf("Failed to load configuration: %v", err)
+			return auth.User{}, true, err
+		}
+		name, ok := c.ClientCertUser(cert.Subject.CommonName)
+		if !ok {
+			glog.Warningf("Refused a client certificate of %q which is mapped to no user", cert.Subject.CommonName)
+			return auth.User{}, true, fmt.Errorf("no user of the client certificate of %q", cert.Subject.CommonName)
+		}
+		u, err := loadUser(ecl, name)
+		return u, true, err
+	}
+}
+
+// tcpKeepAliveListener enables TCP keep-alives of accepted connections in the same way as http.ListenAndServe,
+// so that connections of clients which have gone away are eventually closed.
+type tcpKeepAliveListener struct {
+	*net.TCPListener
+}
+
+func (l tcpKeepAliveListener) Accept() (net.Conn, error) {
+	c, err := l.AcceptTCP()
+	if err != nil {
+		return nil, err
+	}
+	c.SetKeepAlive(true)
+	c.SetKeepAlivePeriod(3 * time.Minute)
+	return c, nil
+}
+
+// redirectToHTTPS redirects "r" to the same URL in HTTPS on the port of -b.
+func redirectToHTTPS(w http.ResponseWriter, r *http.Request) {
+	host := r.Host
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	if _, port, err := net.SplitHostPort(*bindAddress); err == nil && port != "443" {
+		host = net.JoinHostPort(host, port)
+	}
+	http.Redirect(w, r, "https://"+host+r.URL.RequestURI(), http.StatusMovedPermanently)
+}
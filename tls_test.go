@@ -0,0 +1,79 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// setFlags sets the flags in "values" and returns a function which restores them.
+func setFlags(values map[*string]string) func() {
+	saved := make(map[*string]string)
+	for f, v := range values {
+		saved[f], *f = *f, v
+	}
+	return func() {
+		for f, v := range saved {
+			*f = v
+		}
+	}
+}
+
+func TestValidateTLSFlags(t *testing.T) {
+	tests := []struct {
+		cert, key, domains, redirect, clientCA string
+		wantErr                                bool
+	}{
+		{},
+		{cert: "cert.pem", key: "key.pem"},
+		{cert: "cert.pem", key: "key.pem", redirect: ":80", clientCA: "ca.pem"},
+		{domains: "goship.example.com", redirect: ":80"},
+		{cert: "cert.pem", wantErr: true},
+		{key: "key.pem", wantErr: true},
+		{cert: "cert.pem", key: "key.pem", domains: "goship.example.com", wantErr: true},
+		{redirect: ":80", wantErr: true},
+		{clientCA: "ca.pem", wantErr: true},
+	}
+	for _, tt := range tests {
+		restore := setFlags(map[*string]string{
+			tlsCert:         tt.cert,
+			tlsKey:          tt.key,
+			autocertDomains: tt.domains,
+			httpRedirect:    tt.redirect,
+			tlsClientCA:     tt.clientCA,
+		})
+		err := validateTLSFlags()
+		restore()
+		if got := err != nil; got != tt.wantErr {
+			t.Errorf("validateTLSFlags() with %+v = %v, want error %t", tt, err, tt.wantErr)
+		}
+	}
+}
+
+func TestRedirectToHTTPS(t *testing.T) {
+	tests := []struct {
+		bind, host, uri string
+		want            string
+	}{
+		{":443", "goship.example.com", "/", "https://goship.example.com/"},
+		{":443", "goship.example.com:80", "/deploy?project=admin&environment=staging", "https://goship.example.com/deploy?project=admin&environment=staging"},
+		{"0.0.0.0:8443", "goship.example.com:8080", "/history", "https://goship.example.com:8443/history"},
+		{"localhost:8000", "localhost", "/", "https://localhost:8000/"},
+	}
+	for _, tt := range tests {
+		restore := setFlags(map[*string]string{bindAddress: tt.bind})
+		r, err := http.NewRequest("GET", "http://"+tt.host+tt.uri, nil)
+		if err != nil {
+			t.Fatalf("http.NewRequest(%q) failed: %v", tt.uri, err)
+		}
+		w := httptest.NewRecorder()
+		redirectToHTTPS(w, r)
+		restore()
+		if w.Code != http.StatusMovedPermanently {
+			t.Errorf("redirectToHTTPS(%s%s) with -b=%s responded %d, want %d", tt.host, tt.uri, tt.bind, w.Code, http.StatusMovedPermanently)
+		}
+		if got := w.Header().Get("Location"); got != tt.want {
+			t.Errorf("redirectToHTTPS(%s%s) with -b=%s redirected to %q, want %q", tt.host, tt.uri, tt.bind, got, tt.want)
+		}
+	}
+}
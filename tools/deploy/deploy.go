@@ -15,7 +15,9 @@ import (
 
 	"github.com/coreos/go-etcd/etcd"
 	gsconfig "github.com/gengo/goship/lib/config"
+	"github.com/gengo/goship/lib/inventory"
 	"github.com/golang/glog"
+	"golang.org/x/net/context"
 	yaml "gopkg.in/yaml.v2"
 )
 
@@ -157,8 +159,12 @@ func main() {
 		if err != nil {
 			glog.Fatalf("Error getting project %s %s %s", *deployProj, *deployEnv, err)
 		}
+		env, err := inventory.Resolve(context.Background(), *projectEnv)
+		if err != nil {
+			glog.Fatalf("Error resolving hosts of %s %s: %s", *deployProj, *deployEnv, err)
+		}
 		glog.Infof("Deploying project name: %s environment Name: %s", *deployEnv, projectEnv.Name)
-		for _, h := range projectEnv.Hosts {
+		for _, h := range env.Hosts {
 			var cmd []string
 			if *bootstrap {
 				cmd = []string{
@@ -2,10 +2,12 @@ package main
 
 import (
 	"flag"
+	"fmt"
 	"io/ioutil"
 	"os"
 
 	"github.com/coreos/go-etcd/etcd"
+	"github.com/gengo/goship/lib/audit"
 	"github.com/gengo/goship/lib/config"
 	"github.com/golang/glog"
 	yaml "gopkg.in/yaml.v2"
@@ -16,6 +18,8 @@ var (
 	dump     = flag.Bool("dump", false, "dumps configs from etcd")
 	dumpV1   = flag.Bool("dump-v1", false, "same as -dump but reads from old structure of etcd directory")
 	store    = flag.Bool("store", false, "store configs into etcd")
+	gc       = flag.Bool("gc", false, "reports locks, comments, pins and schedules of deleted projects and environments")
+	remove   = flag.Bool("remove", false, "removes the keys reported by -gc")
 )
 
 func dumpCfg(cfg config.Config, err error) error {
@@ -43,7 +47,30 @@ func storeCfg(ecl *etcd.Client) error {
 		glog.Errorf("Failed to marshal config: %v", err)
 		return err
 	}
-	return config.Store(ecl, cfg)
+	err = config.Store(ecl, cfg)
+	rec := audit.Record{Actor: os.Getenv("USER"), Action: audit.ActionConfigStored, Result: audit.ResultSuccess}
+	if err != nil {
+		rec.Result, rec.Detail = audit.ResultFailure, err.Error()
+	}
+	if err := audit.Append(ecl, rec); err != nil {
+		glog.Errorf("Failed to record the change in the audit log: %v", err)
+	}
+	return err
+}
+
+func collectGarbage(ecl *etcd.Client) error {
+	orphans, err := config.FindOrphans(ecl)
+	if err != nil {
+		glog.Errorf("Failed to find orphaned keys: %v", err)
+		return err
+	}
+	for _, o := range orphans {
+		fmt.Printf("%s\t%s\n", o.Key, o.Reason)
+	}
+	if !*remove {
+		return nil
+	}
+	return config.RemoveOrphans(ecl, orphans)
 }
 
 func main() {
@@ -64,8 +91,12 @@ func main() {
 		if err := storeCfg(ecl); err != nil {
 			glog.Fatal(err)
 		}
+	case *gc:
+		if err := collectGarbage(ecl); err != nil {
+			glog.Fatal(err)
+		}
 	default:
-		glog.Errorf("either -dump, -dump-v1 or -store must be specified")
+		glog.Errorf("either -dump, -dump-v1, -store or -gc must be specified")
 		flag.CommandLine.PrintDefaults()
 		os.Exit(1)
 	}
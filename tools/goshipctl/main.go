@@ -0,0 +1,265 @@
+// Command goshipctl is a command line client of Goship.
+//
+// Usage:
+//
+//	goshipctl [-server URL] [-session COOKIE] logs [-follow] [-offset N] (-id ID | -project PROJECT -environment ENV)
+//	goshipctl [-server URL] [-session COOKIE] import-hosts [-dry-run] [-check=false] [-format ansible -project PROJECT -environment ENV [-group GROUP] [-ansible-group GROUP]] FILE
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/gengo/goship/lib/deploy"
+	"github.com/golang/glog"
+)
+
+var (
+	server  = flag.String("server", "http://localhost:8000", "URL of the Goship server")
+	session = flag.String("session", "", "value of the \"goship\" session cookie, if Goship requires login")
+)
+
+// reconnectInterval is the interval of reconnecting after a stream is disconnected.
+const reconnectInterval = time.Second
+
+// errGone is returned when the output of a deployment is no longer available.
+var errGone = errors.New("output of the deployment is not available; it may have been discarded or Goship may have restarted")
+
+func get(path string, query url.Values) (*http.Response, error) {
+	req, err := http.NewRequest("GET", *server+path+"?"+query.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+	if *session != "" {
+		req.AddCookie(&http.Cookie{Name: "goship", Value: *session})
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		if resp.StatusCode == http.StatusNotFound {
+			return nil, errGone
+		}
+		return nil, fmt.Errorf("unexpected status %s from %s", resp.Status, req.URL)
+	}
+	return resp, nil
+}
+
+// latestDeploy returns the ID of the latest deployment of "env" of "proj".
+func latestDeploy(proj, env string) (string, error) {
+	resp, err := get("/api/progress", url.Values{"project": {proj}, "environment": {env}})
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	var progresses []deploy.Progress
+	if err := json.NewDecoder(resp.Body).Decode(&progresses); err != nil {
+		return "", err
+	}
+	for _, p := range progresses {
+		if p.DeployID != "" {
+			return p.DeployID, nil
+		}
+	}
+	return "", fmt.Errorf("no deployment of %s-%s found", proj, env)
+}
+
+// stream copies the output of deployment "id", or of its "host" if not empty, to "w" from "offset" line.
+// It returns the number of lines copied, and io.EOF if the output has been sent completely.
+func stream(w io.Writer, id, host string, offset int, follow bool) (int, error) {
+	q := url.Values{"offset": {fmt.Sprint(offset)}}
+	if follow {
+		q.Set("follow", "true")
+	}
+	if host != "" {
+		q.Set("host", host)
+	}
+	resp, err := get(fmt.Sprintf("/deploys/%s/output", url.QueryEscape(id)), q)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	// a clean end of the response means the end of the output, and anything else means a disconnection.
+	r := bufio.NewReader(resp.Body)
+	n := 0
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			if err == io.EOF && line == "" {
+				return n, io.EOF
+			}
+			if err == io.EOF {
+				err = io.ErrUnexpectedEOF
+			}
+			return n, err
+		}
+		if _, err := io.WriteString(w, line); err != nil {
+			return n, err
+		}
+		n++
+	}
+}
+
+func logs(args []string) error {
+	fs := flag.NewFlagSet("logs", flag.ExitOnError)
+	var (
+		follow = fs.Bool("follow", false, "keep streaming the output until the deployment finishes, reconnecting if disconnected")
+		offset = fs.Int("offset", 0, "number of lines to skip")
+		host   = fs.String("host", "", "show only the output of this host, without the host name in each line")
+		id     = fs.String("id", "", "ID of the deployment")
+		proj   = fs.String("project", "", "project of the deployment, used with -environment instead of -id")
+		env    = fs.String("environment", "", "environment of the deployment, used with -project instead of -id")
+	)
+	fs.Parse(args)
+
+	if *id == "" {
+		if *proj == "" || *env == "" {
+			return errors.New("either -id or both -project and -environment must be given")
+		}
+		var err error
+		if *id, err = latestDeploy(*proj, *env); err != nil {
+			return err
+		}
+	}
+	for {
+		n, err := stream(os.Stdout, *id, *host, *offset, *follow)
+		*offset += n
+		if err == io.EOF {
+			return nil
+		}
+		if !*follow || err == errGone {
+			return err
+		}
+		glog.Warningf("Disconnected from the output of deployment %s: %v; reconnecting from line %d", *id, err, *offset)
+		time.Sleep(reconnectInterval)
+	}
+}
+
+// post sends "body" to "path" and returns the response, which the caller must close.
+func post(path string, query url.Values, body io.Reader) (*http.Response, error) {
+	req, err := http.NewRequest("POST", *server+path+"?"+query.Encode(), body)
+	if err != nil {
+		return nil, err
+	}
+	if *session != "" {
+		req.AddCookie(&http.Cookie{Name: "goship", Value: *session})
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		msg, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 1024))
+		return nil, fmt.Errorf("unexpected status %s from %s: %s", resp.Status, req.URL, bytes.TrimSpace(msg))
+	}
+	return resp, nil
+}
+
+// importResult is the result of an import of hosts.
+type importResult struct {
+	Hosts []struct {
+		Project     string `json:"project"`
+		Environment string `json:"environment"`
+		Host        string `json:"host"`
+		Group       string `json:"group"`
+		Status      string `json:"status"`
+		Error       string `json:"error"`
+	} `json:"hosts"`
+	Added  int  `json:"added"`
+	DryRun bool `json:"dry_run"`
+}
+
+func importHosts(args []string) error {
+	fs := flag.NewFlagSet("import-hosts", flag.ExitOnError)
+	var (
+		dryRun   = fs.Bool("dry-run", false, "only preview the hosts to be added without changing the configuration")
+		check    = fs.Bool("check", true, "skip hosts whose SSH ports do not accept connections")
+		format   = fs.String("format", "csv", "format of the file: csv or ansible")
+		proj     = fs.String("project", "", "project to add the hosts of an Ansible inventory to")
+		env      = fs.String("environment", "", "environment to add the hosts of an Ansible inventory to")
+		group    = fs.String("group", "", "host group of the environment to add the hosts of an Ansible inventory to")
+		ansGroup = fs.String("ansible-group", "", "group of the Ansible inventory whose hosts are added. All the hosts if empty")
+	)
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		return errors.New("a CSV file or an Ansible inventory must be given")
+	}
+	f, err := os.Open(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	q := url.Values{
+		"format":        {*format},
+		"project":       {*proj},
+		"environment":   {*env},
+		"group":         {*group},
+		"ansible_group": {*ansGroup},
+		"dry_run":       {fmt.Sprint(*dryRun)},
+		"check":         {fmt.Sprint(*check)},
+	}
+	resp, err := post("/api/hosts/import", q, f)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	var res importResult
+	if err := json.NewDecoder(resp.Body).Decode(&res); err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(w, "PROJECT\tENVIRONMENT\tGROUP\tHOST\tSTATUS\tERROR")
+	for _, h := range res.Hosts {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n", h.Project, h.Environment, h.Group, h.Host, h.Status, h.Error)
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	if res.DryRun {
+		fmt.Printf("%d host(s) would be added; run without -dry-run to add them\n", res.Added)
+	} else {
+		fmt.Printf("%d host(s) added\n", res.Added)
+	}
+	return nil
+}
+
+func main() {
+	flag.Parse()
+	defer glog.Flush()
+
+	if flag.NArg() < 1 {
+		fmt.Fprintln(os.Stderr, "usage: goshipctl [flags] logs [-follow] [-offset N] [-host HOST] (-id ID | -project PROJECT -environment ENV)")
+		fmt.Fprintln(os.Stderr, "       goshipctl [flags] import-hosts [-dry-run] [-check=false] [-format ansible -project PROJECT -environment ENV] FILE")
+		os.Exit(2)
+	}
+	var err error
+	switch cmd := flag.Arg(0); cmd {
+	case "logs":
+		err = logs(flag.Args()[1:])
+	case "import-hosts":
+		err = importHosts(flag.Args()[1:])
+	default:
+		err = fmt.Errorf("unknown command %q", cmd)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}